@@ -0,0 +1,57 @@
+package exchanges
+
+import (
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
+)
+
+// Structs
+
+type DefaultExchangeAdapterFactory struct {
+	cfg *config.Config
+	ps  *pubsub.EventsPubSub
+}
+
+// Factories
+
+func NewDefaultExchangeAdapterFactory(
+	cfg *config.Config,
+	ps *pubsub.EventsPubSub,
+) *DefaultExchangeAdapterFactory {
+	return &DefaultExchangeAdapterFactory{
+		cfg: cfg,
+		ps:  ps,
+	}
+}
+
+// ExchangeAdapterFactory implementation
+
+func (f *DefaultExchangeAdapterFactory) NewAdapter(
+	apiKey *entities.ApiKey,
+) (ExchangeAdapter, error) {
+	credentials := &valueobjects.ExchangeCredentials{
+		APIKey:    apiKey.Key,
+		APISecret: apiKey.Secret,
+	}
+	switch apiKey.Service {
+	case constants.ApiKeyServiceTypeBinance:
+		return NewBinanceExchangeAdapter(
+			exchange.NewSapiClient(f.cfg, credentials),
+			exchange.NewUserGeneralClient(f.cfg, credentials),
+			f.ps,
+		), nil
+	case constants.ApiKeyServiceTypeKraken:
+		return NewKrakenExchangeAdapter(
+			exchange.NewKrakenClient(f.cfg, credentials),
+			f.ps,
+			f.cfg.Kraken.PollInterval,
+		), nil
+	default:
+		return nil, errors.ErrExchangeNotSupported
+	}
+}
@@ -0,0 +1,695 @@
+package exchanges
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	binanceSapiConnector "github.com/binance/binance-connector-go"
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+// --- ExchangeAdapterFactory Tests ---
+
+func TestNewAdapterReturnsAdapterForEachExchange(t *testing.T) {
+	// Arrange
+	factory := NewDefaultExchangeAdapterFactory(config.GetConfig(), nil)
+
+	for _, service := range constants.ExchangeServiceTypes {
+		// Act
+		adapter, err := factory.NewAdapter(&entities.ApiKey{
+			ID:      uuid.New(),
+			UserID:  uuid.New(),
+			Service: service,
+			Key:     "key",
+			Secret:  "c2VjcmV0",
+		})
+
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, service, adapter.Name())
+		assert.NotNil(t, adapter.KlineStream())
+	}
+}
+
+func TestNewAdapterReturnsErrorIfServiceIsNotAnExchange(t *testing.T) {
+	// Arrange
+	factory := NewDefaultExchangeAdapterFactory(config.GetConfig(), nil)
+
+	// Act
+	adapter, err := factory.NewAdapter(&entities.ApiKey{
+		Service: constants.ApiKeyServiceTypeTelegram,
+	})
+
+	// Assert
+	assert.Nil(t, adapter)
+	assert.Equal(t, errors.ErrExchangeNotSupported, err)
+}
+
+// --- Symbol normalisation Tests ---
+
+func TestKrakenNormalizeSymbol(t *testing.T) {
+	// Arrange
+	adapter := newTestKrakenAdapter(newFixtureServer(t, nil))
+	cases := map[string]string{
+		"XXBTZUSD": "BTCUSD",
+		"XETHZEUR": "ETHEUR",
+		"XXDGZUSD": "DOGEUSD",
+		"XBT/USDT": "BTCUSDT",
+		"XDG/EUR":  "DOGEEUR",
+		"XBTUSDT":  "BTCUSDT",
+		"SOLUSD":   "SOLUSD",
+		"ethusdc":  "ETHUSDC",
+	}
+
+	for exchangeSymbol, expected := range cases {
+		// Act
+		symbol, err := adapter.NormalizeSymbol(exchangeSymbol)
+
+		// Assert
+		assert.Nil(t, err, exchangeSymbol)
+		assert.Equal(t, expected, symbol.String(), exchangeSymbol)
+	}
+}
+
+func TestKrakenNormalizeSymbolReturnsErrorIfQuoteIsUnknown(t *testing.T) {
+	// Arrange
+	adapter := newTestKrakenAdapter(newFixtureServer(t, nil))
+
+	// Act
+	_, err := adapter.NormalizeSymbol("XBTXYZ")
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidSymbol, err)
+}
+
+func TestKrakenExchangeSymbolUsesKrakenAssetNames(t *testing.T) {
+	// Arrange
+	adapter := newTestKrakenAdapter(newFixtureServer(t, nil))
+
+	// Act
+	btc := adapter.ExchangeSymbol(valueobjects.NewMarketSymbol("BTC", "USD"))
+	doge := adapter.ExchangeSymbol(valueobjects.NewMarketSymbol("DOGE", "USDT"))
+	eth := adapter.ExchangeSymbol(valueobjects.NewMarketSymbol("ETH", "EUR"))
+
+	// Assert
+	assert.Equal(t, "XBTUSD", btc)
+	assert.Equal(t, "XDGUSDT", doge)
+	assert.Equal(t, "ETHEUR", eth)
+}
+
+func TestBinanceNormalizeSymbolIsCanonical(t *testing.T) {
+	// Arrange
+	adapter := NewBinanceExchangeAdapter(nil, nil, nil)
+
+	// Act
+	symbol, err := adapter.NormalizeSymbol("ethfdusd")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, valueobjects.NewMarketSymbol("ETH", "FDUSD"), symbol)
+	assert.Equal(t, "ETHFDUSD", adapter.ExchangeSymbol(symbol))
+}
+
+// --- Kraken adapter Tests ---
+
+func TestKrakenGetTicker(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker": "kraken/ticker.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	ticker, err := adapter.GetTicker(newTestContext(), "BTCUSD")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "BTCUSD", ticker.Symbol)
+	assert.Equal(t, 64250.0, ticker.Price)
+	assert.Equal(t, 2431.91602571, ticker.Volume)
+	assert.InDelta(t, 2.8, ticker.PricePercentageChange, 0.0001)
+	assert.Equal(t, "pair=XBTUSD", server.Requests()[0].Query)
+}
+
+func TestKrakenGetTickerReturnsErrorIfRequestFails(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker": "kraken/error.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	_, err := adapter.GetTicker(newTestContext(), "BTCUSD")
+
+	// Assert
+	assert.Equal(t, errors.ErrTickerNotAvailable, err)
+}
+
+func TestKrakenGetKlines(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/OHLC": "kraken/ohlc.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+	from := time.Unix(1718236800, 0)
+
+	// Act
+	klines, err := adapter.GetKlines(newTestContext(), "BTCUSD", "1h", from, time.Unix(1718240400, 0))
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Len(t, *klines, 2)
+	first := (*klines)[0]
+	assert.Equal(t, from.UTC(), first.OpenTime)
+	assert.Equal(t, 66900.0, first.Open)
+	assert.Equal(t, 67100.5, first.High)
+	assert.Equal(t, 66850.2, first.Low)
+	assert.Equal(t, 67050.0, first.Close)
+	assert.Equal(t, 12.5, first.Volume)
+	assert.Equal(t, int64(410), first.Trades)
+	query, _ := url.ParseQuery(server.Requests()[0].Query)
+	assert.Equal(t, "60", query.Get("interval"))
+	assert.Equal(t, "XBTUSD", query.Get("pair"))
+	assert.Equal(t, "1718236800", query.Get("since"))
+}
+
+func TestKrakenGetKlinesReturnsErrorIfIntervalIsNotSupported(t *testing.T) {
+	// Arrange
+	adapter := newTestKrakenAdapter(newFixtureServer(t, nil))
+
+	// Act
+	_, err := adapter.GetKlines(newTestContext(), "BTCUSD", "3m", time.Now(), time.Now())
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidKlineInterval, err)
+}
+
+func TestKrakenGetBalances(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/BalanceEx": "kraken/balance_ex.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	balances, err := adapter.GetBalances(newTestContext())
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Len(t, *balances, 2)
+	balance, err := adapter.GetBalance(newTestContext(), "btc")
+	assert.Nil(t, err)
+	assert.Equal(t, "BTC", balance.Asset)
+	assert.InDelta(t, 0.4, balance.Free, 1e-9)
+	assert.InDelta(t, 0.1, balance.Locked, 1e-9)
+	_, err = adapter.GetBalance(newTestContext(), "ETH")
+	assert.Equal(t, errors.ErrBalanceNotFound, err)
+}
+
+func TestKrakenPlaceMarketOrderSignsRequest(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/AddOrder":    "kraken/add_order.json",
+		"/0/private/QueryOrders": "kraken/query_orders.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	order, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSD", constants.ExchangeOrderSideBuy, 0.01)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "OUF4EM-FRGI2-MQMWZD", order.ID)
+	assert.Equal(t, "BTCUSD", order.Symbol)
+	assert.Equal(t, constants.ExchangeOrderTypeMarket, order.Type)
+	request := server.Requests()[0]
+	assert.Equal(t, "nonce=1616492376594&ordertype=market&pair=XBTUSD&type=buy&volume=0.01", request.Body)
+	assert.Equal(t, "test-key", request.Header.Get("API-Key"))
+	signature, _ := adapter.client.Signature("/0/private/AddOrder", "1616492376594", request.Body)
+	assert.Equal(t, signature, request.Header.Get("API-Sign"))
+}
+
+func TestKrakenPlaceMarketOrderReportsTheFill(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/AddOrder":    "kraken/add_order.json",
+		"/0/private/QueryOrders": "kraken/query_orders.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	order, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSDT", constants.ExchangeOrderSideSell, 0.01)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", order.Status)
+	assert.InDelta(t, 0.01, order.ExecutedQuantity, 1e-9)
+	assert.InDelta(t, 64250.0, order.Price, 1e-6)
+	assert.InDelta(t, 1.6705, order.Fee, 1e-9)
+	requests := server.Requests()
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "nonce=1616492376594&txid=OUF4EM-FRGI2-MQMWZD", requests[1].Body)
+}
+
+func TestKrakenPlaceMarketOrderReturnsOrderIfFillNotAvailable(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/AddOrder": "kraken/add_order.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	order, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSDT", constants.ExchangeOrderSideSell, 0.01)
+
+	// Assert
+	assert.Equal(t, errors.ErrOrderFillNotAvailable, err)
+	assert.Equal(t, "OUF4EM-FRGI2-MQMWZD", order.ID)
+	assert.Equal(t, "pending", order.Status)
+}
+
+func TestKrakenSignatureMatchesDocumentedExample(t *testing.T) {
+	// Arrange
+	adapter := newTestKrakenAdapter(newFixtureServer(t, nil))
+
+	// Act
+	signature, err := adapter.client.Signature(
+		"/0/private/AddOrder",
+		"1616492376594",
+		"nonce=1616492376594&ordertype=limit&pair=XBTUSD&price=37500&type=buy&volume=1.25",
+	)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "4/dpxb3iT4tp/ZCVEwSnEsLxx0bqyhLpdfOpc6fn7OR8+UClSV5n9E6aSS8MPtnRfp32bAb0nmbRn6H8ndwLUQ==", signature)
+}
+
+func TestKrakenPlaceMarketOrderReturnsErrorIfRejected(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/AddOrder": "kraken/error.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	_, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSD", constants.ExchangeOrderSideSell, 1)
+
+	// Assert
+	assert.Equal(t, errors.ErrOrderNotPlaced, err)
+}
+
+func TestKrakenPlaceMarketOrderReturnsErrorIfSideIsInvalid(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, nil)
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	_, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSD", "hold", 1)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidOrderSide, err)
+	assert.Empty(t, server.Requests())
+}
+
+func TestKrakenGetApiKeyPermissionsProbesEachPermission(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/BalanceEx":       "kraken/balance_ex.json",
+		"/0/private/AddOrder":        "kraken/error.json",
+		"/0/private/WithdrawMethods": "kraken/permission_denied.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	permissions, err := adapter.GetApiKeyPermissions(newTestContext())
+
+	// Assert
+	assert.Nil(t, err)
+	assert.True(t, permissions.CanRead)
+	// Lacking funds still means the order got past the permission check
+	assert.True(t, permissions.CanTrade)
+	assert.False(t, permissions.CanWithdraw)
+	assert.Contains(t, server.Requests()[1].Body, "validate=true")
+}
+
+func TestKrakenGetApiKeyPermissionsReportsWithdrawals(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/BalanceEx":       "kraken/balance_ex.json",
+		"/0/private/AddOrder":        "kraken/permission_denied.json",
+		"/0/private/WithdrawMethods": "kraken/withdraw_methods.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	permissions, err := adapter.GetApiKeyPermissions(newTestContext())
+
+	// Assert
+	assert.Nil(t, err)
+	assert.False(t, permissions.CanTrade)
+	assert.True(t, permissions.CanWithdraw)
+}
+
+func TestKrakenGetApiKeyPermissionsReturnsErrorIfKeyIsRejected(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/private/BalanceEx": "kraken/invalid_key.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	_, err := adapter.GetApiKeyPermissions(newTestContext())
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyRejected, err)
+	assert.Len(t, server.Requests(), 1)
+}
+
+func TestKrakenPrepareOrderQuantityRoundsToLotDecimals(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/AssetPairs": "kraken/asset_pairs.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	quantity, err := adapter.PrepareOrderQuantity(newTestContext(), "BTCUSDT", 0.123456, 64250)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 0.1234, quantity)
+	assert.Equal(t, "pair=XBTUSDT", server.Requests()[0].Query)
+}
+
+func TestKrakenPrepareOrderQuantityReturnsErrorIfBelowOrderMinimum(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/AssetPairs": "kraken/asset_pairs.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	_, err := adapter.PrepareOrderQuantity(newTestContext(), "BTCUSDT", 0.00005, 64250)
+
+	// Assert
+	assert.Equal(t, errors.ErrOrderQuantityBelowMinimum, err)
+}
+
+func TestKrakenGetConversionQuotePricesAtTickers(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker": "kraken/ticker.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+
+	// Act
+	quote, err := adapter.GetConversionQuote(newTestContext(), "BTC", constants.LedgerQuoteAsset, 0.01, "spot")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Contains(t, quote.ID, "kraken-")
+	assert.InDelta(t, 642.5, quote.ToAmount, 1e-9)
+	assert.Equal(t, "pair=XBTUSDT", server.Requests()[0].Query)
+}
+
+// krakenOrderBodies returns the bodies of the orders the server was sent.
+func krakenOrderBodies(server *fixtureServer) []string {
+	bodies := make([]string, 0)
+	for _, request := range server.Requests() {
+		if request.Path == "/0/private/AddOrder" {
+			bodies = append(bodies, request.Body)
+		}
+	}
+	return bodies
+}
+
+func TestKrakenAcceptConversionQuoteSellsIntoTheQuoteAsset(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker":       "kraken/ticker.json",
+		"/0/public/AssetPairs":   "kraken/asset_pairs.json",
+		"/0/private/AddOrder":    "kraken/add_order.json",
+		"/0/private/QueryOrders": "kraken/query_orders.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+	quote := &entities.ExchangeConversionQuote{
+		ID:         "kraken-quote",
+		FromAsset:  "BTC",
+		ToAsset:    constants.LedgerQuoteAsset,
+		FromAmount: 0.01,
+		ToAmount:   642.5,
+	}
+
+	// Act
+	order, err := adapter.AcceptConversionQuote(newTestContext(), quote)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "OUF4EM-FRGI2-MQMWZD", order.ID)
+	assert.False(t, order.IsPartial())
+	assert.Equal(t, constants.LedgerQuoteAsset, order.ToAsset)
+	assert.InDelta(t, 0.01, order.FromAmount, 1e-9)
+	assert.InDelta(t, 640.8295, order.ToAmount, 1e-9)
+	assert.InDelta(t, 1.6705, order.Fee, 1e-9)
+	assert.Equal(t, []string{
+		"nonce=1616492376594&ordertype=market&pair=XBTUSDT&type=sell&volume=0.01",
+	}, krakenOrderBodies(server))
+}
+
+func TestKrakenAcceptConversionQuoteBuysWhatItConvertsTo(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker":       "kraken/ticker.json",
+		"/0/public/AssetPairs":   "kraken/asset_pairs.json",
+		"/0/private/AddOrder":    "kraken/add_order.json",
+		"/0/private/QueryOrders": "kraken/query_orders.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+	quote := &entities.ExchangeConversionQuote{
+		ID:         "kraken-quote",
+		FromAsset:  constants.LedgerQuoteAsset,
+		ToAsset:    "BTC",
+		FromAmount: 1000,
+		ToAmount:   0.0155642,
+	}
+
+	// Act
+	order, err := adapter.AcceptConversionQuote(newTestContext(), quote)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "BTC", order.ToAsset)
+	assert.InDelta(t, 0.01, order.ToAmount, 1e-9)
+	// Sized at the ticker, keeping back the fee of the buy
+	assert.Equal(t, []string{
+		"nonce=1616492376594&ordertype=market&pair=XBTUSDT&type=buy&volume=0.0154",
+	}, krakenOrderBodies(server))
+}
+
+func TestKrakenAcceptConversionQuoteBuysWithTheProceedsOfTheSale(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker":       "kraken/ticker.json",
+		"/0/public/AssetPairs":   "kraken/asset_pairs.json",
+		"/0/private/AddOrder":    "kraken/add_order.json",
+		"/0/private/QueryOrders": "kraken/query_orders.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+	quote := &entities.ExchangeConversionQuote{
+		ID:         "kraken-quote",
+		FromAsset:  "BTC",
+		ToAsset:    "ETH",
+		FromAmount: 0.01,
+		// Quoted higher than the sale pays for
+		ToAmount: 0.2,
+	}
+
+	// Act
+	order, err := adapter.AcceptConversionQuote(newTestContext(), quote)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.False(t, order.IsPartial())
+	assert.Equal(t, "ETH", order.ToAsset)
+	assert.InDelta(t, 3.341, order.Fee, 1e-9)
+	assert.Equal(t, []string{
+		"nonce=1616492376594&ordertype=market&pair=XBTUSDT&type=sell&volume=0.01",
+		"nonce=1616492376594&ordertype=market&pair=ETHUSDT&type=buy&volume=0.0099",
+	}, krakenOrderBodies(server))
+}
+
+func TestKrakenAcceptConversionQuoteReportsPartialConversionIfBuyFails(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/Ticker":       "kraken/ticker.json",
+		"/0/public/AssetPairs":   "kraken/asset_pairs.json",
+		"/0/private/AddOrder":    "kraken/add_order.json",
+		"/0/private/QueryOrders": "kraken/query_orders_partial.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+	quote := &entities.ExchangeConversionQuote{
+		ID:         "kraken-quote",
+		FromAsset:  "BTC",
+		ToAsset:    "ETH",
+		FromAmount: 0.01,
+		ToAmount:   0.2,
+	}
+
+	// Act
+	order, err := adapter.AcceptConversionQuote(newTestContext(), quote)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.True(t, order.IsPartial())
+	assert.Equal(t, constants.LedgerQuoteAsset, order.ToAsset)
+	assert.InDelta(t, 0.00001, order.FromAmount, 1e-12)
+	assert.InDelta(t, 0.64083, order.ToAmount, 1e-9)
+	// The proceeds buy less than the pair minimum, nothing is bought
+	assert.Len(t, krakenOrderBodies(server), 1)
+}
+
+func TestNewKrakenClientNoncesIncreaseAcrossClients(t *testing.T) {
+	// Arrange
+	credentials := &valueobjects.ExchangeCredentials{APIKey: "key", APISecret: "c2VjcmV0"}
+	first := exchange.NewKrakenClient(config.GetConfig(), credentials)
+	second := exchange.NewKrakenClient(config.GetConfig(), credentials)
+
+	// Act
+	nonces := []int64{first.Nonce(), second.Nonce(), first.Nonce()}
+
+	// Assert
+	assert.Less(t, nonces[0], nonces[1])
+	assert.Less(t, nonces[1], nonces[2])
+	assert.Greater(t, nonces[0], time.Now().Add(-time.Hour).UnixNano())
+}
+
+func TestKrakenKlineStreamPollsCandles(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/0/public/OHLC": "kraken/ohlc.json",
+	})
+	adapter := newTestKrakenAdapter(server)
+	stream := adapter.KlineStream()
+
+	// Act
+	klines, err := stream.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"btcusd"},
+		Intervals: []string{"1h"},
+	})
+	assert.Nil(t, err)
+	received := make([]valueobjects.WebSocketKline, 0)
+	for len(received) < 4 {
+		select {
+		case kline := <-klines:
+			received = append(received, kline)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for klines")
+		}
+	}
+	connected := stream.IsConnected()
+	stream.Close()
+
+	// Assert
+	assert.True(t, connected)
+	assert.Equal(t, "BTCUSD", received[0].Symbol)
+	assert.Equal(t, "1h", received[0].Interval)
+	assert.True(t, received[0].IsFinal)
+	assert.True(t, received[1].IsFinal)
+	assert.False(t, received[2].IsFinal)
+	// Final candles are sent once, the open candle on every poll
+	assert.False(t, received[3].IsFinal)
+	assert.Equal(t, received[2].OpenTime, received[3].OpenTime)
+}
+
+func TestKrakenKlineStreamReturnsErrorIfIntervalIsNotSupported(t *testing.T) {
+	// Arrange
+	adapter := newTestKrakenAdapter(newFixtureServer(t, nil))
+
+	// Act
+	_, err := adapter.KlineStream().SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSD"},
+		Intervals: []string{"3m"},
+	})
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidKlineInterval, err)
+}
+
+// --- Binance adapter Tests ---
+
+func TestBinanceGetTicker(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/api/v3/ticker/24hr": "binance/ticker_24hr.json",
+	})
+	adapter := NewBinanceExchangeAdapter(
+		binanceSapiConnector.NewClient("key", "secret", server.URL),
+		nil,
+		nil,
+	)
+
+	// Act
+	ticker, err := adapter.GetTicker(newTestContext(), "btcusdt")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "BTCUSDT", ticker.Symbol)
+	assert.Equal(t, 64250.0, ticker.Price)
+	assert.Equal(t, 2431.91602571, ticker.Volume)
+	assert.Equal(t, 1.983, ticker.PricePercentageChange)
+}
+
+func TestBinancePlaceMarketOrder(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/api/v3/ticker/24hr":  "binance/ticker_24hr.json",
+		"/api/v3/exchangeInfo": "binance/exchange_info.json",
+		"/api/v3/order":        "binance/order.json",
+	})
+	adapter := newTestBinanceAdapter(server)
+
+	// Act
+	order, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSDT", constants.ExchangeOrderSideBuy, 0.0100049)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "28457921", order.ID)
+	assert.Equal(t, "filled", order.Status)
+	assert.Equal(t, 0.01, order.Quantity)
+	assert.Equal(t, 0.01, order.ExecutedQuantity)
+	assert.InDelta(t, 64250.0, order.Price, 1e-6)
+	assert.Equal(t, time.UnixMilli(1718236800123).UTC(), order.CreatedAt)
+	requests := server.Requests()
+	request := requests[len(requests)-1]
+	assert.Equal(t, "/api/v3/order", request.Path)
+	assert.Contains(t, request.Query+request.Body, "side=BUY")
+	assert.Contains(t, request.Query+request.Body, "type=MARKET")
+	assert.Contains(t, request.Query+request.Body, "quantity=0.01&")
+}
+
+func TestBinancePlaceMarketOrderReturnsErrorIfBelowMinimumNotional(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/api/v3/ticker/24hr":  "binance/ticker_24hr.json",
+		"/api/v3/exchangeInfo": "binance/exchange_info.json",
+		"/api/v3/order":        "binance/order.json",
+	})
+	adapter := newTestBinanceAdapter(server)
+
+	// Act
+	_, err := adapter.PlaceMarketOrder(newTestContext(), "BTCUSDT", constants.ExchangeOrderSideSell, 0.00005)
+
+	// Assert
+	assert.Equal(t, errors.ErrOrderNotionalBelowMinimum, err)
+	for _, request := range server.Requests() {
+		assert.NotEqual(t, "/api/v3/order", request.Path)
+	}
+}
@@ -0,0 +1,183 @@
+package exchanges
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	binance "github.com/adshao/go-binance/v2"
+	binanceSapiConnector "github.com/binance/binance-connector-go"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
+)
+
+// Structs
+
+type BinanceExchangeAdapter struct {
+	exchangeService *DefaultExchangeService
+	dataService     *DefaultExchangeDataService
+	generalClient   *binance.Client
+	stream          ExchangeWebSocketService
+}
+
+// Factories
+
+func NewBinanceExchangeAdapter(
+	sapiClient *binanceSapiConnector.Client,
+	generalClient *binance.Client,
+	ps *pubsub.EventsPubSub,
+) *BinanceExchangeAdapter {
+	return &BinanceExchangeAdapter{
+		exchangeService: NewDefaultExchangeService(sapiClient, generalClient),
+		dataService:     NewDefaultExchangeDataService(sapiClient, generalClient),
+		generalClient:   generalClient,
+		stream:          NewDefaultExchangeWebSocketService(ps, binance.WsCombinedKlineServe),
+	}
+}
+
+// ExchangeAdapter implementation
+
+func (a *BinanceExchangeAdapter) Name() string {
+	return constants.ApiKeyServiceTypeBinance
+}
+
+func (a *BinanceExchangeAdapter) NormalizeSymbol(
+	exchangeSymbol string,
+) (valueobjects.MarketSymbol, error) {
+	return valueobjects.ParseMarketSymbol(exchangeSymbol)
+}
+
+func (a *BinanceExchangeAdapter) ExchangeSymbol(
+	symbol valueobjects.MarketSymbol,
+) string {
+	return symbol.String()
+}
+
+func (a *BinanceExchangeAdapter) GetBalance(
+	ctx echo.Context,
+	asset string,
+) (*valueobjects.ExchangeBalance, error) {
+	return a.exchangeService.GetBalance(ctx, strings.ToUpper(asset))
+}
+
+func (a *BinanceExchangeAdapter) GetBalances(
+	ctx echo.Context,
+) (*[]valueobjects.ExchangeBalance, error) {
+	return a.exchangeService.GetBalances(ctx)
+}
+
+func (a *BinanceExchangeAdapter) GetTicker(
+	ctx echo.Context,
+	symbol string,
+) (*valueobjects.ExchangeTicker, error) {
+	return a.exchangeService.GetTicker(ctx, strings.ToUpper(symbol))
+}
+
+func (a *BinanceExchangeAdapter) GetKlines(
+	ctx echo.Context,
+	symbol string,
+	interval string,
+	from time.Time,
+	to time.Time,
+) (*[]valueobjects.ExchangeKline, error) {
+	return a.dataService.GetKlines(ctx, strings.ToUpper(symbol), interval, from, to)
+}
+
+func (a *BinanceExchangeAdapter) PlaceMarketOrder(
+	ctx echo.Context,
+	symbol string,
+	side string,
+	quantity float64,
+) (*entities.ExchangeOrder, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	order := entities.ExchangeOrder{
+		Symbol:   strings.ToUpper(symbol),
+		Side:     side,
+		Type:     constants.ExchangeOrderTypeMarket,
+		Quantity: quantity,
+	}
+	err := order.Validate()
+	if err != nil {
+		return nil, err
+	}
+	ticker, err := a.exchangeService.GetTicker(ctx, order.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	order.Quantity, err = a.exchangeService.PrepareOrderQuantity(
+		ctx,
+		order.Symbol,
+		quantity,
+		ticker.Price,
+	)
+	if err != nil {
+		return nil, err
+	}
+	response, err := a.generalClient.NewCreateOrderService().
+		Symbol(order.Symbol).
+		Side(binance.SideType(strings.ToUpper(side))).
+		Type(binance.OrderTypeMarket).
+		Quantity(strconv.FormatFloat(order.Quantity, 'f', -1, 64)).
+		Do(ctx.Request().Context())
+	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
+		logger.Errorf("Error placing %s order for %s: %s", side, order.Symbol, err)
+		return nil, errors.ErrOrderNotPlaced
+	}
+	executed, err := strconv.ParseFloat(response.ExecutedQuantity, 64)
+	if err != nil {
+		logger.Errorf("Error parsing executed quantity: %s", err)
+		return nil, errors.ErrInvalidOrderAmount
+	}
+	quote, err := strconv.ParseFloat(response.CummulativeQuoteQuantity, 64)
+	if err != nil {
+		logger.Errorf("Error parsing cummulative quote quantity: %s", err)
+		return nil, errors.ErrInvalidPrice
+	}
+	order.ID = strconv.FormatInt(response.OrderID, 10)
+	order.ExecutedQuantity = executed
+	order.Status = strings.ToLower(string(response.Status))
+	order.CreatedAt = time.UnixMilli(response.TransactTime).UTC()
+	if executed > 0 {
+		order.Price = quote / executed
+	}
+	return &order, nil
+}
+
+func (a *BinanceExchangeAdapter) PrepareOrderQuantity(
+	ctx echo.Context,
+	symbol string,
+	quantity float64,
+	price float64,
+) (float64, error) {
+	return a.exchangeService.PrepareOrderQuantity(ctx, strings.ToUpper(symbol), quantity, price)
+}
+
+func (a *BinanceExchangeAdapter) GetConversionQuote(
+	ctx echo.Context,
+	fromAsset string,
+	toAsset string,
+	fromAmount float64,
+	walletType string,
+) (*entities.ExchangeConversionQuote, error) {
+	return a.exchangeService.GetConversionQuote(ctx, fromAsset, toAsset, fromAmount, walletType)
+}
+
+func (a *BinanceExchangeAdapter) AcceptConversionQuote(
+	ctx echo.Context,
+	quote *entities.ExchangeConversionQuote,
+) (*entities.ExchangeConversionOrder, error) {
+	return a.exchangeService.AcceptConversionQuote(ctx, quote.ID)
+}
+
+func (a *BinanceExchangeAdapter) KlineStream() ExchangeWebSocketService {
+	return a.stream
+}
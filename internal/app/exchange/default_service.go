@@ -1,12 +1,14 @@
 package exchanges
 
 import (
-	"fmt"
+	"context"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	binance "github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
 	binanceSapiConnector "github.com/binance/binance-connector-go"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -15,14 +17,20 @@ import (
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
 )
 
+// defaultHeartbeatTimeout stands in for heartbeat timeouts too short for the
+// supervisor to check twice within.
+const defaultHeartbeatTimeout = time.Minute
+
 // Structs
 
 type DefaultExchangeService struct {
 	sapiClient    *binanceSapiConnector.Client
 	generalClient *binance.Client
+	symbolFilters *SymbolFiltersCache
 }
 
 type DefaultExchangeDataService struct {
@@ -31,7 +39,35 @@ type DefaultExchangeDataService struct {
 }
 
 type DefaultExchangeWebSocketService struct {
-	ps *pubsub.EventsPubSub
+	ps               *pubsub.EventsPubSub
+	serve            KlineStreamServer
+	minBackoff       time.Duration
+	maxBackoff       time.Duration
+	maxReconnects    int64
+	heartbeatTimeout time.Duration
+	mutex            sync.RWMutex
+	wg               sync.WaitGroup
+	streams          map[string]*klineStream
+	klines           chan valueobjects.WebSocketKline
+	errs             chan error
+	closed           bool
+}
+
+// KlineStreamServer opens a combined kline stream for the given symbol to
+// interval pairs. binance.WsCombinedKlineServe satisfies it.
+type KlineStreamServer func(
+	symbolIntervalPair map[string]string,
+	handler binance.WsKlineHandler,
+	errHandler binance.ErrHandler,
+) (doneC, stopC chan struct{}, err error)
+
+// klineStream holds the state of the combined stream serving one interval.
+type klineStream struct {
+	symbols     map[string]bool
+	connected   bool
+	lastMessage time.Time
+	restartC    chan struct{}
+	stopC       chan struct{}
 }
 
 // Factories
@@ -43,6 +79,7 @@ func NewDefaultExchangeService(
 	return &DefaultExchangeService{
 		sapiClient:    sapiClient,
 		generalClient: generalClient,
+		symbolFilters: GetSymbolFiltersCache(),
 	}
 }
 
@@ -58,9 +95,36 @@ func NewDefaultExchangeDataService(
 
 func NewDefaultExchangeWebSocketService(
 	ps *pubsub.EventsPubSub,
+	serve KlineStreamServer,
 ) *DefaultExchangeWebSocketService {
+	conf := config.GetConfig()
+	heartbeatTimeout := conf.Binance.WebSocketHeartbeatTimeout
+	if heartbeatTimeout/2 <= 0 {
+		config.GetLogger().Warnf(
+			"WebSocket heartbeat timeout %s is too short, using %s",
+			heartbeatTimeout,
+			defaultHeartbeatTimeout,
+		)
+		heartbeatTimeout = defaultHeartbeatTimeout
+	}
 	return &DefaultExchangeWebSocketService{
-		ps: ps,
+		ps:               ps,
+		serve:            serve,
+		minBackoff:       conf.Binance.WebSocketMinBackoff,
+		maxBackoff:       conf.Binance.WebSocketMaxBackoff,
+		maxReconnects:    conf.Binance.WebSocketMaxReconnects,
+		heartbeatTimeout: heartbeatTimeout,
+		streams:          make(map[string]*klineStream),
+		klines:           make(chan valueobjects.WebSocketKline, conf.Binance.WebSocketBufferSize),
+		errs:             make(chan error, conf.Binance.WebSocketBufferSize),
+	}
+}
+
+func newKlineStream() *klineStream {
+	return &klineStream{
+		symbols:  make(map[string]bool),
+		restartC: make(chan struct{}, 1),
+		stopC:    make(chan struct{}),
 	}
 }
 
@@ -91,6 +155,9 @@ func (s *DefaultExchangeService) GetBalances(
 		Do(ctx.Request().Context())
 	logger.Infof("Account: %+v", account)
 	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
 		logger.Errorf("Error getting account: %s", err)
 		return nil, errors.ErrAccountNotAvailable
 	}
@@ -122,6 +189,44 @@ func (s *DefaultExchangeService) GetBalances(
 	return &balances, nil
 }
 
+// GetApiKeyPermissions reads the permissions of the client's key from both
+// the account and the API restrictions, withdrawals being allowed if either
+// says so. A key the exchange refuses fails with ErrApiKeyRejected.
+func (s *DefaultExchangeService) GetApiKeyPermissions(
+	ctx echo.Context,
+) (*valueobjects.ExchangeApiKeyPermissions, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	account, err := s.generalClient.
+		NewGetAccountService().
+		Do(ctx.Request().Context())
+	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
+		logger.Errorf("Error getting account: %s", err)
+		if common.IsAPIError(err) {
+			return nil, errors.ErrApiKeyRejected
+		}
+		return nil, errors.ErrAccountNotAvailable
+	}
+	restrictions, err := s.generalClient.
+		NewGetAPIKeyPermission().
+		Do(ctx.Request().Context())
+	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
+		logger.Errorf("Error getting api key restrictions: %s", err)
+		return nil, errors.ErrApiKeyPermissionsNotAvailable
+	}
+	return &valueobjects.ExchangeApiKeyPermissions{
+		CanRead:      restrictions.EnableReading,
+		CanTrade:     account.CanTrade && restrictions.EnableSpotAndMarginTrading,
+		CanWithdraw:  account.CanWithdraw || restrictions.EnableWithdrawals,
+		IPRestricted: restrictions.IPRestrict,
+	}, nil
+}
+
 func (s *DefaultExchangeService) GetTicker(
 	ctx echo.Context,
 	symbol string,
@@ -130,6 +235,9 @@ func (s *DefaultExchangeService) GetTicker(
 	tickerService := s.sapiClient.NewTicker24hrService()
 	tickers, err := tickerService.Symbol(symbol).Do(ctx.Request().Context())
 	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
 		logger.Errorf("Error getting ticker: %s", err)
 		return nil, errors.ErrTickerNotAvailable
 	}
@@ -173,12 +281,20 @@ func (s *DefaultExchangeService) GetAvailableSymbols(
 	var activeSymbols []valueobjects.ExchangeAvailableSymbol
 	for _, symbol := range exchangeInfo.Symbols {
 		if symbol.Status == "TRADING" {
+			filters, err := parseSymbolFilters(symbol)
+			if err != nil {
+				logger.Errorf("Error parsing filters of %s: %s", symbol.Symbol, err)
+				return nil, errors.ErrSymbolFiltersNotAvailable
+			}
+			s.symbolFilters.Set(filters)
 			as := valueobjects.ExchangeAvailableSymbol{
-				Symbol:         symbol.Symbol,
-				BaseAsset:      symbol.BaseAsset,
-				QuoteAsset:     symbol.QuoteAsset,
-				Status:         symbol.Status,
-				QuotePrecision: symbol.QuotePrecision,
+				Symbol:             symbol.Symbol,
+				BaseAsset:          symbol.BaseAsset,
+				QuoteAsset:         symbol.QuoteAsset,
+				Status:             symbol.Status,
+				BaseAssetPrecision: symbol.BaseAssetPrecision,
+				QuotePrecision:     symbol.QuotePrecision,
+				Filters:            filters,
 			}
 			activeSymbols = append(activeSymbols, as)
 		}
@@ -186,6 +302,62 @@ func (s *DefaultExchangeService) GetAvailableSymbols(
 	return &activeSymbols, nil
 }
 
+func (s *DefaultExchangeService) GetSymbolFilters(
+	ctx echo.Context,
+	symbol string,
+) (*valueobjects.ExchangeSymbolFilters, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	if filters, ok := s.symbolFilters.Get(symbol); ok {
+		return filters, nil
+	}
+	exchangeInfo, err := s.sapiClient.NewExchangeInfoService().
+		Symbol(symbol).
+		Do(ctx.Request().Context())
+	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
+		logger.Errorf("Error getting exchange info for %s: %s", symbol, err)
+		return nil, errors.ErrSymbolFiltersNotAvailable
+	}
+	for _, info := range exchangeInfo.Symbols {
+		if info.Symbol != symbol {
+			continue
+		}
+		filters, err := parseSymbolFilters(info)
+		if err != nil {
+			logger.Errorf("Error parsing filters of %s: %s", symbol, err)
+			return nil, errors.ErrSymbolFiltersNotAvailable
+		}
+		s.symbolFilters.Set(filters)
+		return &filters, nil
+	}
+	logger.Errorf("No exchange info found for symbol: %s", symbol)
+	return nil, errors.ErrSymbolFiltersNotAvailable
+}
+
+// PrepareOrderQuantity rounds a quantity down to the symbol step size and
+// validates it against the symbol filters, using price to check the notional.
+func (s *DefaultExchangeService) PrepareOrderQuantity(
+	ctx echo.Context,
+	symbol string,
+	quantity float64,
+	price float64,
+) (float64, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	filters, err := s.GetSymbolFilters(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	rounded := filters.RoundQuantity(quantity)
+	err = filters.ValidateQuantity(rounded, price)
+	if err != nil {
+		logger.Warnf("Quantity %f of %s rejected by symbol filters: %s", quantity, symbol, err)
+		return 0, err
+	}
+	return rounded, nil
+}
+
 func (s *DefaultExchangeService) GetConversionQuote(
 	ctx echo.Context,
 	fromAsset string,
@@ -202,6 +374,9 @@ func (s *DefaultExchangeService) GetConversionQuote(
 		WalletType(walletType).
 		Do(ctx.Request().Context())
 	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
 		if strings.Contains(err.Error(), "insufficient balance") {
 			return nil, errors.ErrInsufficientBalance
 		}
@@ -255,6 +430,9 @@ func (s *DefaultExchangeService) AcceptConversionQuote(
 		QuoteId(id).
 		Do(ctx.Request().Context())
 	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
 		if strings.Contains(err.Error(), "quote expired") {
 			return nil, errors.ErrQuoteExpired
 		}
@@ -313,6 +491,9 @@ func (s *DefaultExchangeDataService) GetKlines(
 		EndTime(to.UnixMilli()).
 		Do(ctx.Request().Context())
 	if err != nil {
+		if rateLimitErr := exchange.RateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
 		logger.Errorf("Error getting klines: %s", err)
 		return nil, errors.ErrKlinesNotAvailable
 	}
@@ -359,55 +540,347 @@ func (s *DefaultExchangeDataService) GetKlines(
 
 // ExchangeWebSocketService implementation
 
+func (s *DefaultExchangeWebSocketService) SubscribeToKlines(
+	ctx context.Context,
+	subscription valueobjects.WebSocketSubscription,
+) (<-chan valueobjects.WebSocketKline, error) {
+	logger := config.GetLogger()
+	if len(subscription.Symbols) == 0 || len(subscription.Intervals) == 0 {
+		return nil, errors.ErrWebSocketSubscriptionFailed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.ErrWebSocketSubscriptionFailed
+	}
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, errors.ErrWebSocketStreamClosed
+	}
+	pending := make(map[string]*klineStream)
+	for _, interval := range subscription.Intervals {
+		stream, ok := s.streams[interval]
+		if !ok {
+			stream = newKlineStream()
+			s.streams[interval] = stream
+			pending[interval] = stream
+		}
+		changed := false
+		for _, symbol := range subscription.Symbols {
+			symbol = strings.ToUpper(symbol)
+			if !stream.symbols[symbol] {
+				stream.symbols[symbol] = true
+				changed = true
+			}
+		}
+		if ok && changed {
+			// Combined streams are URL based, so the supervisor reconnects
+			// with the new symbol set.
+			stream.restart()
+		}
+	}
+	s.mutex.Unlock()
+	for interval, stream := range pending {
+		doneC, stopC, err := s.serve(
+			s.symbolIntervalPairs(interval, stream),
+			s.KlineHandler,
+			s.ErrorHandler,
+		)
+		if err != nil {
+			logger.Errorf("Error subscribing to %s klines: %s", interval, err)
+			s.mutex.Lock()
+			delete(s.streams, interval)
+			s.mutex.Unlock()
+			return nil, errors.ErrWebSocketConnectionFailed
+		}
+		s.setConnected(stream, true)
+		s.wg.Add(1)
+		go s.supervise(interval, stream, doneC, stopC)
+	}
+	return s.klines, nil
+}
+
+func (s *DefaultExchangeWebSocketService) UnsubscribeFromKlines(
+	ctx context.Context,
+	subscription valueobjects.WebSocketSubscription,
+) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.ErrWebSocketStreamClosed
+	}
+	for _, interval := range subscription.Intervals {
+		stream, ok := s.streams[interval]
+		if !ok {
+			return errors.ErrWebSocketSubscriptionFailed
+		}
+		for _, symbol := range subscription.Symbols {
+			delete(stream.symbols, strings.ToUpper(symbol))
+		}
+		if len(stream.symbols) == 0 {
+			close(stream.stopC)
+			delete(s.streams, interval)
+			continue
+		}
+		stream.restart()
+	}
+	return nil
+}
+
+func (s *DefaultExchangeWebSocketService) IsConnected() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed || len(s.streams) == 0 {
+		return false
+	}
+	for _, stream := range s.streams {
+		if !stream.connected {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *DefaultExchangeWebSocketService) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	for interval, stream := range s.streams {
+		close(stream.stopC)
+		delete(s.streams, interval)
+	}
+	s.mutex.Unlock()
+	// Supervisors wait for their readers to exit, so no handler can write
+	// to the channels once they are done.
+	s.wg.Wait()
+	close(s.klines)
+	close(s.errs)
+	return nil
+}
+
+// Errors returns the channel where connection and message errors are
+// reported. It is closed together with the klines channel.
+func (s *DefaultExchangeWebSocketService) Errors() <-chan error {
+	return s.errs
+}
+
+// Stream handlers
+
 func (s *DefaultExchangeWebSocketService) KlineHandler(
 	event *binance.WsKlineEvent,
 ) {
-	// Echo context
-	ctx := echo.New().NewContext(nil, nil)
 	logger := config.GetLogger()
-	ctx.Set("logger", logger)
+	s.touch(event.Kline.Interval)
+	kline, err := s.parseKline(event)
+	if err != nil {
+		logger.Errorf("Error parsing kline for %s: %s", event.Symbol, err)
+		s.reportError(errors.ErrWebSocketMessageInvalid)
+		return
+	}
+	select {
+	case s.klines <- *kline:
+	default:
+		logger.Warnf("Klines buffer full. Dropping %s %s kline", kline.Symbol, kline.Interval)
+	}
+	if s.ps == nil {
+		return
+	}
 	marketDataEventFactory := events.MarketDataEventFactory{}
-	ev, err := marketDataEventFactory.NewRawMarketDataEvent(
+	ev := marketDataEventFactory.NewMarketDataEvent(
 		uuid.Nil,
-		event.Kline.Symbol,
-		time.Unix(event.Kline.StartTime, 0),
-		event.Kline.Open,
-		event.Kline.High,
-		event.Kline.Low,
-		event.Kline.Close,
-		event.Kline.Volume,
-		event.Kline.IsFinal,
+		kline.Symbol,
+		kline.OpenTime,
+		kline.Open,
+		kline.High,
+		kline.Low,
+		kline.Close,
+		kline.Volume,
+		kline.IsFinal,
 	)
-	if err != nil {
-		logger.Fatalf("Error creating market data: %s", err)
-	}
 	err = ev.Dispatch(s.ps)
 	if err != nil {
-		logger.Fatalf("Error dispatching market data event: %s", err)
+		logger.Errorf("Error dispatching market data event: %s", err)
+		s.reportError(err)
 	}
 }
 
 func (s *DefaultExchangeWebSocketService) ErrorHandler(
 	err error,
 ) {
-	fmt.Printf("Error: %s\n", err)
+	logger := config.GetLogger()
+	logger.Errorf("WebSocket error: %s", err)
+	s.reportError(errors.ErrWebSocketMessageInvalid)
 }
 
-func (s *DefaultExchangeWebSocketService) Subscribe(
-	ctx echo.Context,
-	symbols []string,
+// Helpers
+
+func (s *DefaultExchangeWebSocketService) supervise(
 	interval string,
+	stream *klineStream,
+	doneC chan struct{},
+	stopC chan struct{},
 ) {
-	logger := config.GetLoggerFromContext(ctx)
-	for _, symbol := range symbols {
-		_, _, err := binance.WsKlineServe(
-			symbol,
-			interval,
-			s.KlineHandler,
-			s.ErrorHandler,
-		)
-		if err != nil {
-			logger.Errorf("Error subscribing to klines: %s", err)
+	defer s.wg.Done()
+	logger := config.GetLogger()
+	heartbeat := time.NewTicker(s.heartbeatTimeout / 2)
+	defer heartbeat.Stop()
+	for {
+		attempts := int64(0)
+		select {
+		case <-stream.stopC:
+			close(stopC)
+			<-doneC
+			s.setConnected(stream, false)
+			return
+		case <-stream.restartC:
+			close(stopC)
+			<-doneC
+		case <-doneC:
+			logger.Warnf("Kline stream for %s dropped. Reconnecting...", interval)
+			s.reportError(errors.ErrWebSocketStreamClosed)
+			attempts++
+		case <-heartbeat.C:
+			if !s.isStale(stream) {
+				continue
+			}
+			logger.Warnf("Kline stream for %s missed its heartbeat. Reconnecting...", interval)
+			close(stopC)
+			<-doneC
+			attempts++
+		}
+		s.setConnected(stream, false)
+		for {
+			if attempts > 0 {
+				select {
+				case <-stream.stopC:
+					return
+				case <-time.After(s.reconnectBackoff(attempts)):
+				}
+			}
+			var err error
+			doneC, stopC, err = s.serve(
+				s.symbolIntervalPairs(interval, stream),
+				s.KlineHandler,
+				s.ErrorHandler,
+			)
+			if err == nil {
+				break
+			}
+			logger.Errorf("Error reconnecting %s kline stream: %s", interval, err)
+			attempts++
+			if attempts > s.maxReconnects {
+				s.reportError(errors.ErrWebSocketReconnectionFailed)
+				s.mutex.Lock()
+				if s.streams[interval] == stream {
+					delete(s.streams, interval)
+				}
+				s.mutex.Unlock()
+				return
+			}
 		}
+		s.setConnected(stream, true)
+	}
+}
+
+func (s *DefaultExchangeWebSocketService) reconnectBackoff(attempt int64) time.Duration {
+	backoff := s.minBackoff
+	for i := int64(1); i < attempt && backoff < s.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > s.maxBackoff {
+		return s.maxBackoff
+	}
+	return backoff
+}
+
+func (s *DefaultExchangeWebSocketService) symbolIntervalPairs(
+	interval string,
+	stream *klineStream,
+) map[string]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	pairs := make(map[string]string, len(stream.symbols))
+	for symbol := range stream.symbols {
+		pairs[symbol] = interval
+	}
+	return pairs
+}
+
+func (s *DefaultExchangeWebSocketService) setConnected(stream *klineStream, connected bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stream.connected = connected
+	stream.lastMessage = time.Now()
+}
+
+func (s *DefaultExchangeWebSocketService) touch(interval string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stream, ok := s.streams[interval]; ok {
+		stream.lastMessage = time.Now()
+	}
+}
+
+func (s *DefaultExchangeWebSocketService) isStale(stream *klineStream) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return time.Since(stream.lastMessage) > s.heartbeatTimeout
+}
+
+func (s *DefaultExchangeWebSocketService) reportError(err error) {
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
+
+func (s *DefaultExchangeWebSocketService) parseKline(
+	event *binance.WsKlineEvent,
+) (*valueobjects.WebSocketKline, error) {
+	open, err := strconv.ParseFloat(event.Kline.Open, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidOpen
+	}
+	high, err := strconv.ParseFloat(event.Kline.High, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidHigh
+	}
+	low, err := strconv.ParseFloat(event.Kline.Low, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidLow
+	}
+	close, err := strconv.ParseFloat(event.Kline.Close, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidClose
+	}
+	volume, err := strconv.ParseFloat(event.Kline.Volume, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidVolume
+	}
+	symbol := event.Kline.Symbol
+	if symbol == "" {
+		symbol = event.Symbol
+	}
+	return &valueobjects.WebSocketKline{
+		Symbol:    symbol,
+		Interval:  event.Kline.Interval,
+		OpenTime:  time.UnixMilli(event.Kline.StartTime).UTC(),
+		CloseTime: time.UnixMilli(event.Kline.EndTime).UTC(),
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     close,
+		Volume:    volume,
+		Trades:    event.Kline.TradeNum,
+		IsFinal:   event.Kline.IsFinal,
+	}, nil
+}
+
+func (k *klineStream) restart() {
+	select {
+	case k.restartC <- struct{}{}:
+	default:
 	}
 }
@@ -0,0 +1,528 @@
+package exchanges
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	binance "github.com/adshao/go-binance/v2"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/stretchr/testify/assert"
+)
+
+func newKlineEvent(symbol string, interval string, close string) *binance.WsKlineEvent {
+	return &binance.WsKlineEvent{
+		Event:  "kline",
+		Symbol: symbol,
+		Kline: binance.WsKline{
+			StartTime: 1700000000000,
+			EndTime:   1700000059999,
+			Symbol:    symbol,
+			Interval:  interval,
+			Open:      "100.5",
+			High:      "101",
+			Low:       "99.5",
+			Close:     close,
+			Volume:    "12.3",
+			TradeNum:  42,
+			IsFinal:   true,
+		},
+	}
+}
+
+// --- ExchangeWebSocketService Tests ---
+
+func TestSubscribeToKlinesReturnsErrorIfSubscriptionEmpty(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+
+	// Act
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{})
+
+	// Assert
+	assert.Equal(t, errors.ErrWebSocketSubscriptionFailed, err)
+}
+
+func TestSubscribeToKlinesReturnsErrorIfConnectionFails(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{failures: 1}
+	service := newTestWebSocketService(server)
+
+	// Act
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.Equal(t, errors.ErrWebSocketConnectionFailed, err)
+	assert.False(t, service.IsConnected())
+}
+
+func TestSubscribeToKlinesUsesOneCombinedStreamPerInterval(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+
+	// Act
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"btcusdt", "ETHUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, service.IsConnected())
+	assert.Equal(t, 1, len(server.Connections()))
+	assert.Equal(t, map[string]string{"BTCUSDT": "1m", "ETHUSDT": "1m"}, server.Last().pairs)
+}
+
+func TestSubscribeToKlinesDeliversParsedKlines(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	klines, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	server.Last().handler(newKlineEvent("BTCUSDT", "1m", "100.75"))
+
+	// Assert
+	select {
+	case kline := <-klines:
+		assert.Equal(t, "BTCUSDT", kline.Symbol)
+		assert.Equal(t, "1m", kline.Interval)
+		assert.Equal(t, 100.75, kline.Close)
+		assert.Equal(t, int64(42), kline.Trades)
+		assert.Equal(t, time.UnixMilli(1700000000000).UTC(), kline.OpenTime)
+		assert.True(t, kline.IsFinal)
+	case <-time.After(time.Second):
+		t.Fatal("kline not delivered")
+	}
+}
+
+func TestKlineHandlerReportsInvalidMessagesWithoutExiting(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	klines, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	server.Last().handler(newKlineEvent("BTCUSDT", "1m", "not-a-number"))
+
+	// Assert
+	select {
+	case err := <-service.Errors():
+		assert.Equal(t, errors.ErrWebSocketMessageInvalid, err)
+	case <-time.After(time.Second):
+		t.Fatal("error not reported")
+	}
+	assert.Equal(t, 0, len(klines))
+	assert.True(t, service.IsConnected())
+}
+
+func TestKlineStreamReconnectsAfterDrop(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	close(server.Last().dropC)
+
+	// Assert
+	assert.True(t, server.WaitForConnections(2))
+	assert.Equal(t, errors.ErrWebSocketStreamClosed, <-service.Errors())
+	assert.Equal(t, map[string]string{"BTCUSDT": "1m"}, server.Last().pairs)
+}
+
+func TestKlineStreamGivesUpAfterMaxReconnects(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+	server.mutex.Lock()
+	server.failures = 10
+	server.mutex.Unlock()
+
+	// Act
+	close(server.Last().dropC)
+
+	// Assert
+	reported := []error{}
+	timeout := time.After(2 * time.Second)
+	for len(reported) < 2 {
+		select {
+		case err := <-service.Errors():
+			reported = append(reported, err)
+		case <-timeout:
+			t.Fatal("reconnection failure not reported")
+		}
+	}
+	assert.Equal(t, errors.ErrWebSocketReconnectionFailed, reported[1])
+	assert.False(t, service.IsConnected())
+}
+
+func TestKlineStreamReconnectsWhenHeartbeatIsMissed(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	service.heartbeatTimeout = 20 * time.Millisecond
+	defer service.Close()
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	stale := server.Last()
+
+	// Assert
+	assert.True(t, server.WaitForConnections(2))
+	select {
+	case <-stale.doneC:
+	case <-time.After(time.Second):
+		t.Fatal("stale connection not stopped")
+	}
+}
+
+func TestKlineStreamFallsBackWithoutHeartbeatTimeout(t *testing.T) {
+	// Arrange
+	conf := &config.GetConfig().Binance
+	previous := conf.WebSocketHeartbeatTimeout
+	conf.WebSocketHeartbeatTimeout = 0
+	t.Cleanup(func() { conf.WebSocketHeartbeatTimeout = previous })
+	server := &fakeKlineServer{}
+	service := NewDefaultExchangeWebSocketService(nil, server.Serve)
+	defer service.Close()
+
+	// Act
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, defaultHeartbeatTimeout, service.heartbeatTimeout)
+	assert.True(t, server.WaitForConnections(1))
+}
+
+func TestSubscribeToKlinesAddsSymbolsAtRuntime(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	_, err = service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"ETHUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, server.WaitForConnections(2))
+	assert.Equal(t, map[string]string{"BTCUSDT": "1m", "ETHUSDT": "1m"}, server.Last().pairs)
+}
+
+func TestUnsubscribeFromKlinesRemovesSymbolsAtRuntime(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT", "ETHUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	err = service.UnsubscribeFromKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"ETHUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, server.WaitForConnections(2))
+	assert.Equal(t, map[string]string{"BTCUSDT": "1m"}, server.Last().pairs)
+}
+
+func TestUnsubscribeFromKlinesStopsStreamWhenNoSymbolsLeft(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+	_, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.NoError(t, err)
+	connection := server.Last()
+
+	// Act
+	err = service.UnsubscribeFromKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-connection.doneC:
+	case <-time.After(time.Second):
+		t.Fatal("connection not stopped")
+	}
+	assert.False(t, service.IsConnected())
+}
+
+func TestUnsubscribeFromKlinesReturnsErrorIfIntervalNotSubscribed(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	defer service.Close()
+
+	// Act
+	err := service.UnsubscribeFromKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+
+	// Assert
+	assert.Equal(t, errors.ErrWebSocketSubscriptionFailed, err)
+}
+
+func TestCloseStopsStreamsAndClosesChannel(t *testing.T) {
+	// Arrange
+	server := &fakeKlineServer{}
+	service := newTestWebSocketService(server)
+	klines, err := service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m", "5m"},
+	})
+	assert.NoError(t, err)
+
+	// Act
+	err = service.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, service.IsConnected())
+	_, open := <-klines
+	assert.False(t, open)
+	for _, connection := range server.Connections() {
+		_, open := <-connection.doneC
+		assert.False(t, open)
+	}
+	_, err = service.SubscribeToKlines(context.Background(), valueobjects.WebSocketSubscription{
+		Symbols:   []string{"BTCUSDT"},
+		Intervals: []string{"1m"},
+	})
+	assert.Equal(t, errors.ErrWebSocketStreamClosed, err)
+}
+
+// --- ExchangeService symbol filters Tests ---
+
+func newSymbolFiltersServer(t *testing.T) *fixtureServer {
+	return newFixtureServer(t, map[string]string{
+		"/api/v3/exchangeInfo": "binance/exchange_info.json",
+	})
+}
+
+func TestGetSymbolFiltersParsesExchangeInfo(t *testing.T) {
+	// Arrange
+	service := newTestBinanceAdapter(newSymbolFiltersServer(t)).exchangeService
+
+	// Act
+	filters, err := service.GetSymbolFilters(newTestContext(), "BTCUSDT")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "BTCUSDT", filters.Symbol)
+	assert.Equal(t, 0.00001, filters.MinQuantity)
+	// MARKET_LOT_SIZE is tighter than LOT_SIZE
+	assert.Equal(t, 83.53498318, filters.MaxQuantity)
+	assert.Equal(t, 0.00001, filters.StepSize)
+	assert.Equal(t, int64(5), filters.QuantityPrecision)
+	assert.Equal(t, 5.0, filters.MinNotional)
+	// NOTIONAL max does not apply to market orders
+	assert.Equal(t, 0.0, filters.MaxNotional)
+	assert.Equal(t, int64(2), filters.PricePrecision)
+}
+
+func TestGetSymbolFiltersIsCached(t *testing.T) {
+	// Arrange
+	server := newSymbolFiltersServer(t)
+	service := newTestBinanceAdapter(server).exchangeService
+	_, err := service.GetSymbolFilters(newTestContext(), "BTCUSDT")
+	assert.Nil(t, err)
+
+	// Act
+	_, err = service.GetSymbolFilters(newTestContext(), "BTCUSDT")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Len(t, server.Requests(), 1)
+}
+
+func TestGetSymbolFiltersReturnsErrorIfSymbolIsUnknown(t *testing.T) {
+	// Arrange
+	service := newTestBinanceAdapter(newSymbolFiltersServer(t)).exchangeService
+
+	// Act
+	_, err := service.GetSymbolFilters(newTestContext(), "DOGEUSDT")
+
+	// Assert
+	assert.Equal(t, errors.ErrSymbolFiltersNotAvailable, err)
+}
+
+func TestGetAvailableSymbolsReturnsFiltersAndFillsCache(t *testing.T) {
+	// Arrange
+	server := newSymbolFiltersServer(t)
+	service := newTestBinanceAdapter(server).exchangeService
+
+	// Act
+	symbols, err := service.GetAvailableSymbols(newTestContext())
+	_, cacheErr := service.GetSymbolFilters(newTestContext(), "BTCUSDT")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Len(t, *symbols, 1)
+	assert.Equal(t, int64(8), (*symbols)[0].BaseAssetPrecision)
+	assert.Equal(t, 5.0, (*symbols)[0].Filters.MinNotional)
+	assert.Nil(t, cacheErr)
+	assert.Len(t, server.Requests(), 1)
+}
+
+func TestPrepareOrderQuantityRoundsDownToStepSize(t *testing.T) {
+	// Arrange
+	service := newTestBinanceAdapter(newSymbolFiltersServer(t)).exchangeService
+
+	// Act
+	quantity, err := service.PrepareOrderQuantity(newTestContext(), "BTCUSDT", 0.123456789, 64250)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 0.12345, quantity)
+}
+
+func TestPrepareOrderQuantityNeverRoundsUp(t *testing.T) {
+	// Arrange
+	service := newTestBinanceAdapter(newSymbolFiltersServer(t)).exchangeService
+	cases := map[float64]float64{
+		0.3:                 0.3,
+		0.00003:             0.00003,
+		0.12345:             0.12345,
+		0.12345999999:       0.12345,
+		0.00002999999999999: 0.00002,
+	}
+
+	for quantity, expected := range cases {
+		// Act
+		rounded, err := service.PrepareOrderQuantity(newTestContext(), "BTCUSDT", quantity, 0)
+
+		// Assert
+		assert.Nil(t, err, quantity)
+		assert.Equal(t, expected, rounded, quantity)
+		assert.LessOrEqual(t, rounded, quantity, quantity)
+	}
+}
+
+func TestPrepareOrderQuantityValidatesSymbolFilters(t *testing.T) {
+	// Arrange
+	service := newTestBinanceAdapter(newSymbolFiltersServer(t)).exchangeService
+	cases := []struct {
+		quantity float64
+		price    float64
+		err      error
+	}{
+		{0.000009, 64250, errors.ErrOrderQuantityBelowMinimum},
+		{0, 64250, errors.ErrOrderQuantityBelowMinimum},
+		{100, 64250, errors.ErrOrderQuantityAboveMaximum},
+		{0.00005, 64250, errors.ErrOrderNotionalBelowMinimum},
+		{0.00005, 0, nil},
+	}
+
+	for _, c := range cases {
+		// Act
+		_, err := service.PrepareOrderQuantity(newTestContext(), "BTCUSDT", c.quantity, c.price)
+
+		// Assert
+		assert.Equal(t, c.err, err, c.quantity)
+	}
+}
+
+// --- ExchangeService api key permissions Tests ---
+
+func TestGetApiKeyPermissionsReadsAccountAndRestrictions(t *testing.T) {
+	// Arrange
+	server := newFixtureServer(t, map[string]string{
+		"/api/v3/account":                  "binance/account.json",
+		"/sapi/v1/account/apiRestrictions": "binance/api_restrictions.json",
+	})
+	service := newTestBinanceAdapter(server).exchangeService
+
+	// Act
+	permissions, err := service.GetApiKeyPermissions(newTestContext())
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, valueobjects.ExchangeApiKeyPermissions{
+		CanRead:      true,
+		CanTrade:     true,
+		CanWithdraw:  false,
+		IPRestricted: true,
+	}, *permissions)
+}
+
+func TestGetApiKeyPermissionsReturnsErrorIfKeyIsRejected(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":-2015,"msg":"Invalid API-key, IP, or permissions for action."}`))
+	}))
+	t.Cleanup(server.Close)
+	generalClient := binance.NewClient("key", "secret")
+	generalClient.BaseURL = server.URL
+	generalClient.HTTPClient = server.Client()
+	service := NewDefaultExchangeService(nil, generalClient)
+
+	// Act
+	_, err := service.GetApiKeyPermissions(newTestContext())
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyRejected, err)
+}
@@ -0,0 +1,761 @@
+package exchanges
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
+)
+
+// Kraken still answers with the legacy X/Z prefixed codes for the assets it
+// listed first.
+var krakenLegacyAssets = map[string]string{
+	"XXBT": "BTC",
+	"XETH": "ETH",
+	"XLTC": "LTC",
+	"XXRP": "XRP",
+	"XXLM": "XLM",
+	"XXMR": "XMR",
+	"XZEC": "ZEC",
+	"XETC": "ETC",
+	"XREP": "REP",
+	"XMLN": "MLN",
+	"XXDG": "DOGE",
+	"ZUSD": "USD",
+	"ZEUR": "EUR",
+	"ZGBP": "GBP",
+	"ZCAD": "CAD",
+	"ZJPY": "JPY",
+	"ZAUD": "AUD",
+	"ZCHF": "CHF",
+}
+
+// Kraken names that differ from the canonical asset code.
+var krakenAssetAliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// Kline intervals in minutes, keyed by their canonical (Binance) name.
+var krakenIntervals = map[string]int64{
+	"1m":  1,
+	"5m":  5,
+	"15m": 15,
+	"30m": 30,
+	"1h":  60,
+	"4h":  240,
+	"1d":  1440,
+	"1w":  10080,
+}
+
+// The order validated, never placed, to find out whether a key can trade.
+const (
+	krakenProbePair   = "XBTUSD"
+	krakenProbeVolume = "0.0001"
+)
+
+// How long a market order is waited for to close before its fill is
+// reported as it stands.
+const (
+	krakenFillAttempts = 5
+	krakenFillInterval = 500 * time.Millisecond
+)
+
+// Statuses of orders Kraken hasn't closed yet.
+const (
+	krakenOrderStatusPending = "pending"
+	krakenOrderStatusOpen    = "open"
+)
+
+// Share of the proceeds of a conversion kept back when buying, since Kraken
+// charges the fee of a buy in the quote asset on top of its cost.
+const krakenBuyFeeReserve = 0.005
+
+// Structs
+
+type KrakenExchangeAdapter struct {
+	client *exchange.KrakenClient
+	stream *KrakenKlineStreamService
+}
+
+// Factories
+
+func NewKrakenExchangeAdapter(
+	client *exchange.KrakenClient,
+	ps *pubsub.EventsPubSub,
+	pollInterval time.Duration,
+) *KrakenExchangeAdapter {
+	adapter := &KrakenExchangeAdapter{
+		client: client,
+	}
+	adapter.stream = NewKrakenKlineStreamService(adapter, ps, pollInterval)
+	return adapter
+}
+
+// ExchangeAdapter implementation
+
+func (a *KrakenExchangeAdapter) Name() string {
+	return constants.ApiKeyServiceTypeKraken
+}
+
+// NormalizeSymbol accepts the websocket name (XBT/USDT), the legacy pair
+// name (XXBTZUSD) and the alternative name (XBTUSDT) of a Kraken pair.
+func (a *KrakenExchangeAdapter) NormalizeSymbol(
+	exchangeSymbol string,
+) (valueobjects.MarketSymbol, error) {
+	exchangeSymbol = strings.ToUpper(exchangeSymbol)
+	if base, quote, found := strings.Cut(exchangeSymbol, "/"); found {
+		if base == "" || quote == "" {
+			return valueobjects.MarketSymbol{}, errors.ErrInvalidSymbol
+		}
+		return valueobjects.NewMarketSymbol(
+			normalizeKrakenAsset(base),
+			normalizeKrakenAsset(quote),
+		), nil
+	}
+	if len(exchangeSymbol) == 8 {
+		base, baseFound := krakenLegacyAssets[exchangeSymbol[:4]]
+		quote, quoteFound := krakenLegacyAssets[exchangeSymbol[4:]]
+		if baseFound && quoteFound {
+			return valueobjects.NewMarketSymbol(base, quote), nil
+		}
+	}
+	symbol, err := valueobjects.ParseMarketSymbol(exchangeSymbol)
+	if err != nil {
+		return valueobjects.MarketSymbol{}, err
+	}
+	return valueobjects.NewMarketSymbol(
+		normalizeKrakenAsset(symbol.Base),
+		symbol.Quote,
+	), nil
+}
+
+func (a *KrakenExchangeAdapter) ExchangeSymbol(
+	symbol valueobjects.MarketSymbol,
+) string {
+	return krakenAsset(symbol.Base) + krakenAsset(symbol.Quote)
+}
+
+func (a *KrakenExchangeAdapter) GetBalance(
+	ctx echo.Context,
+	asset string,
+) (*valueobjects.ExchangeBalance, error) {
+	balances, err := a.GetBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	asset = strings.ToUpper(asset)
+	for _, balance := range *balances {
+		if balance.Asset == asset {
+			return &balance, nil
+		}
+	}
+	return nil, errors.ErrBalanceNotFound
+}
+
+func (a *KrakenExchangeAdapter) GetBalances(
+	ctx echo.Context,
+) (*[]valueobjects.ExchangeBalance, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	krakenBalances, err := a.client.GetExtendedBalance(ctx.Request().Context())
+	if err != nil {
+		logger.Errorf("Error getting Kraken balances: %s", err)
+		return nil, errors.ErrAccountNotAvailable
+	}
+	balances := make([]valueobjects.ExchangeBalance, 0)
+	for asset, krakenBalance := range krakenBalances {
+		// Staked and opt-in rewards balances (e.g. ETH.F) are not tradable
+		if strings.Contains(asset, ".") {
+			continue
+		}
+		total, err := strconv.ParseFloat(krakenBalance.Balance, 64)
+		if err != nil {
+			logger.Errorf("Error parsing balance: %s", err)
+			return nil, errors.ErrInvalidBalance
+		}
+		locked := 0.0
+		if krakenBalance.HoldTrade != "" {
+			locked, err = strconv.ParseFloat(krakenBalance.HoldTrade, 64)
+			if err != nil {
+				logger.Errorf("Error parsing held balance: %s", err)
+				return nil, errors.ErrInvalidBalance
+			}
+		}
+		if total == 0 && locked == 0 {
+			continue
+		}
+		balances = append(balances, valueobjects.ExchangeBalance{
+			Asset:  normalizeKrakenAsset(asset),
+			Free:   total - locked,
+			Locked: locked,
+		})
+	}
+	if len(balances) == 0 {
+		logger.Errorf("No balances available")
+		return nil, errors.ErrNoBalance
+	}
+	return &balances, nil
+}
+
+func (a *KrakenExchangeAdapter) GetTicker(
+	ctx echo.Context,
+	symbol string,
+) (*valueobjects.ExchangeTicker, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	marketSymbol, err := valueobjects.ParseMarketSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	tickers, err := a.client.GetTicker(
+		ctx.Request().Context(),
+		a.ExchangeSymbol(marketSymbol),
+	)
+	if err != nil {
+		logger.Errorf("Error getting ticker: %s", err)
+		return nil, errors.ErrTickerNotAvailable
+	}
+	if len(tickers) != 1 {
+		logger.Errorf("No ticker found for symbol: %s", symbol)
+		return nil, errors.ErrTickerNotAvailable
+	}
+	for _, ticker := range tickers {
+		if len(ticker.Last) == 0 || len(ticker.Volume) < 2 {
+			return nil, errors.ErrTickerNotAvailable
+		}
+		price, err := strconv.ParseFloat(ticker.Last[0], 64)
+		if err != nil {
+			logger.Errorf("Error parsing price: %s", err)
+			return nil, errors.ErrInvalidPrice
+		}
+		// Index 1 holds the rolling 24 hours volume
+		volume, err := strconv.ParseFloat(ticker.Volume[1], 64)
+		if err != nil {
+			logger.Errorf("Error parsing volume: %s", err)
+			return nil, errors.ErrInvalidVolume
+		}
+		open, err := strconv.ParseFloat(ticker.Open, 64)
+		if err != nil || open == 0 {
+			logger.Errorf("Error parsing opening price: %s", err)
+			return nil, errors.ErrInvalidPricePercentageChange
+		}
+		return &valueobjects.ExchangeTicker{
+			Symbol:                marketSymbol.String(),
+			Price:                 price,
+			Volume:                volume,
+			PricePercentageChange: (price - open) / open * 100,
+		}, nil
+	}
+	return nil, errors.ErrTickerNotAvailable
+}
+
+func (a *KrakenExchangeAdapter) GetKlines(
+	ctx echo.Context,
+	symbol string,
+	interval string,
+	from time.Time,
+	to time.Time,
+) (*[]valueobjects.ExchangeKline, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	klines, err := a.fetchKlines(ctx.Request().Context(), symbol, interval, from)
+	if err != nil {
+		logger.Errorf("Error getting klines: %s", err)
+		return nil, err
+	}
+	kls := make([]valueobjects.ExchangeKline, 0, len(klines))
+	for _, kline := range klines {
+		if !to.IsZero() && kline.OpenTime.After(to) {
+			break
+		}
+		kls = append(kls, valueobjects.ExchangeKline{
+			OpenTime: kline.OpenTime,
+			Open:     kline.Open,
+			High:     kline.High,
+			Low:      kline.Low,
+			Close:    kline.Close,
+			Volume:   kline.Volume,
+			Trades:   kline.Trades,
+		})
+	}
+	return &kls, nil
+}
+
+func (a *KrakenExchangeAdapter) PlaceMarketOrder(
+	ctx echo.Context,
+	symbol string,
+	side string,
+	quantity float64,
+) (*entities.ExchangeOrder, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	marketSymbol, err := valueobjects.ParseMarketSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	order := entities.ExchangeOrder{
+		Symbol:    marketSymbol.String(),
+		Side:      side,
+		Type:      constants.ExchangeOrderTypeMarket,
+		Quantity:  quantity,
+		Status:    "pending",
+		CreatedAt: time.Now().UTC(),
+	}
+	err = order.Validate()
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.client.AddMarketOrder(
+		ctx.Request().Context(),
+		a.ExchangeSymbol(marketSymbol),
+		side,
+		strconv.FormatFloat(quantity, 'f', -1, 64),
+	)
+	if err != nil {
+		logger.Errorf("Error placing %s order for %s: %s", side, order.Symbol, err)
+		return nil, errors.ErrOrderNotPlaced
+	}
+	if len(result.TxIDs) == 0 {
+		logger.Errorf("Kraken returned no transaction ID for %s", result.Description.Order)
+		return nil, errors.ErrOrderNotPlaced
+	}
+	// Kraken only acknowledges the order, its fill has to be asked for
+	order.ID = result.TxIDs[0]
+	err = a.fillOrder(ctx, &order)
+	if err != nil {
+		logger.Errorf("Error getting the fill of order %s for %s: %s", order.ID, order.Symbol, err)
+		return &order, errors.ErrOrderFillNotAvailable
+	}
+	return &order, nil
+}
+
+// PrepareOrderQuantity rounds a quantity down to the lot decimals of the
+// pair and validates it against the pair minimums, using price to check the
+// cost.
+func (a *KrakenExchangeAdapter) PrepareOrderQuantity(
+	ctx echo.Context,
+	symbol string,
+	quantity float64,
+	price float64,
+) (float64, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	marketSymbol, err := valueobjects.ParseMarketSymbol(symbol)
+	if err != nil {
+		return 0, err
+	}
+	filters, err := a.symbolFilters(ctx, marketSymbol)
+	if err != nil {
+		return 0, err
+	}
+	rounded := filters.RoundQuantity(quantity)
+	err = filters.ValidateQuantity(rounded, price)
+	if err != nil {
+		logger.Warnf("Quantity %f of %s rejected by pair minimums: %s", quantity, symbol, err)
+		return 0, err
+	}
+	return rounded, nil
+}
+
+// GetConversionQuote prices the conversion at the tickers of both assets
+// against the ledger quote asset, since Kraken has no conversion API.
+func (a *KrakenExchangeAdapter) GetConversionQuote(
+	ctx echo.Context,
+	fromAsset string,
+	toAsset string,
+	fromAmount float64,
+	walletType string,
+) (*entities.ExchangeConversionQuote, error) {
+	fromPrice, err := a.quotePrice(ctx, fromAsset)
+	if err != nil {
+		return nil, err
+	}
+	toPrice, err := a.quotePrice(ctx, toAsset)
+	if err != nil {
+		return nil, err
+	}
+	factory := entities.ExchangeConversionQuoteFactory{}
+	quote := factory.NewSimulatedConversionQuote(fromAsset, toAsset, fromAmount, fromPrice, toPrice)
+	quote.ID = fmt.Sprintf("kraken-%s", uuid.New())
+	return quote, quote.Validate()
+}
+
+// AcceptConversionQuote converts through the ledger quote asset with market
+// orders: it sells what is converted from, then buys what it converts to
+// with what the sale brought in. The orders aren't atomic, so a buy failing
+// after the sale leaves a partial conversion, stopped at the ledger quote
+// asset, for the caller to book.
+func (a *KrakenExchangeAdapter) AcceptConversionQuote(
+	ctx echo.Context,
+	quote *entities.ExchangeConversionQuote,
+) (*entities.ExchangeConversionOrder, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	if quote.FromAsset == quote.ToAsset {
+		return nil, errors.ErrInvalidSymbol
+	}
+	converted := &entities.ExchangeConversionOrder{
+		ID:         quote.ID,
+		CreatedAt:  time.Now().UTC(),
+		FromAmount: quote.FromAmount,
+		ToAsset:    constants.LedgerQuoteAsset,
+		ToAmount:   quote.FromAmount,
+		FeeAsset:   constants.LedgerQuoteAsset,
+	}
+	if quote.FromAsset != constants.LedgerQuoteAsset {
+		sold, err := a.sellAll(ctx, quote.FromAsset+constants.LedgerQuoteAsset, quote.FromAmount)
+		if err != nil {
+			return nil, err
+		}
+		converted.ID = sold.ID
+		converted.CreatedAt = sold.CreatedAt
+		converted.Status = sold.Status
+		converted.FromAmount = sold.ExecutedQuantity
+		converted.ToAmount = sold.ExecutedQuantity*sold.Price - sold.Fee
+		converted.Fee = sold.Fee
+	}
+	if quote.ToAsset == constants.LedgerQuoteAsset {
+		return converted, nil
+	}
+	bought, err := a.buyWith(ctx, quote.ToAsset+constants.LedgerQuoteAsset, converted.ToAmount)
+	if err != nil {
+		if quote.FromAsset == constants.LedgerQuoteAsset {
+			return nil, err
+		}
+		logger.Errorf(
+			"Conversion %s stopped at %s after selling %f %s: %s",
+			quote.ID,
+			constants.LedgerQuoteAsset,
+			converted.FromAmount,
+			quote.FromAsset,
+			err,
+		)
+		converted.Status = constants.ExchangeConversionStatusPartial
+		return converted, nil
+	}
+	converted.ID = bought.ID
+	converted.CreatedAt = bought.CreatedAt
+	converted.Status = bought.Status
+	converted.ToAsset = quote.ToAsset
+	converted.ToAmount = bought.ExecutedQuantity
+	converted.Fee += bought.Fee
+	return converted, nil
+}
+
+// GetApiKeyPermissions probes what the client's key may do, since Kraken
+// has no call reporting it: reading balances, validating an order without
+// placing it and listing withdrawal methods each need their own permission.
+// A key Kraken refuses fails with ErrApiKeyRejected.
+func (a *KrakenExchangeAdapter) GetApiKeyPermissions(
+	ctx echo.Context,
+) (*valueobjects.ExchangeApiKeyPermissions, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	requestCtx := ctx.Request().Context()
+	_, err := a.client.GetExtendedBalance(requestCtx)
+	if err != nil {
+		logger.Errorf("Error getting Kraken balances: %s", err)
+		if _, ok := err.(*exchange.KrakenError); ok {
+			return nil, errors.ErrApiKeyRejected
+		}
+		return nil, errors.ErrAccountNotAvailable
+	}
+	canTrade, err := krakenPermission(a.client.ValidateMarketOrder(
+		requestCtx,
+		krakenProbePair,
+		constants.ExchangeOrderSideBuy,
+		krakenProbeVolume,
+	))
+	if err != nil {
+		logger.Errorf("Error validating Kraken order: %s", err)
+		return nil, errors.ErrApiKeyPermissionsNotAvailable
+	}
+	_, err = a.client.GetWithdrawMethods(requestCtx)
+	canWithdraw, err := krakenPermission(err)
+	if err != nil {
+		logger.Errorf("Error getting Kraken withdrawal methods: %s", err)
+		return nil, errors.ErrApiKeyPermissionsNotAvailable
+	}
+	return &valueobjects.ExchangeApiKeyPermissions{
+		CanRead:     true,
+		CanTrade:    canTrade,
+		CanWithdraw: canWithdraw,
+	}, nil
+}
+
+func (a *KrakenExchangeAdapter) KlineStream() ExchangeWebSocketService {
+	return a.stream
+}
+
+// Helpers
+
+// fetchKlines returns the candles of a canonical symbol since the given time,
+// oldest first. The most recent candle is still open and is not final.
+func (a *KrakenExchangeAdapter) fetchKlines(
+	ctx context.Context,
+	symbol string,
+	interval string,
+	since time.Time,
+) ([]valueobjects.WebSocketKline, error) {
+	minutes, ok := krakenIntervals[interval]
+	if !ok {
+		return nil, errors.ErrInvalidKlineInterval
+	}
+	marketSymbol, err := valueobjects.ParseMarketSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	ohlc, err := a.client.GetOHLC(ctx, a.ExchangeSymbol(marketSymbol), minutes, since)
+	if err != nil {
+		return nil, errors.ErrKlinesNotAvailable
+	}
+	klines := make([]valueobjects.WebSocketKline, 0)
+	for _, candles := range ohlc {
+		for i, candle := range candles {
+			kline, err := parseKrakenCandle(candle)
+			if err != nil {
+				return nil, err
+			}
+			kline.Symbol = marketSymbol.String()
+			kline.Interval = interval
+			kline.CloseTime = kline.OpenTime.Add(time.Duration(minutes) * time.Minute)
+			kline.IsFinal = i < len(candles)-1
+			klines = append(klines, *kline)
+		}
+	}
+	return klines, nil
+}
+
+// parseKrakenCandle reads [time, open, high, low, close, vwap, volume, count].
+func parseKrakenCandle(candle []interface{}) (*valueobjects.WebSocketKline, error) {
+	if len(candle) < 8 {
+		return nil, errors.ErrKlinesNotAvailable
+	}
+	timestamp, ok := candle[0].(float64)
+	if !ok {
+		return nil, errors.ErrKlinesNotAvailable
+	}
+	values := make([]float64, 0, 5)
+	for _, index := range []int{1, 2, 3, 4, 6} {
+		raw, ok := candle[index].(string)
+		if !ok {
+			return nil, errors.ErrKlinesNotAvailable
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.ErrKlinesNotAvailable
+		}
+		values = append(values, value)
+	}
+	trades, ok := candle[7].(float64)
+	if !ok {
+		return nil, errors.ErrInvalidTrades
+	}
+	return &valueobjects.WebSocketKline{
+		OpenTime: time.Unix(int64(timestamp), 0).UTC(),
+		Open:     values[0],
+		High:     values[1],
+		Low:      values[2],
+		Close:    values[3],
+		Volume:   values[4],
+		Trades:   int64(trades),
+	}, nil
+}
+
+// symbolFilters reads the trading rules of a pair from its asset pair info.
+func (a *KrakenExchangeAdapter) symbolFilters(
+	ctx echo.Context,
+	symbol valueobjects.MarketSymbol,
+) (*valueobjects.ExchangeSymbolFilters, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	pairs, err := a.client.GetAssetPairs(ctx.Request().Context(), a.ExchangeSymbol(symbol))
+	if err != nil {
+		logger.Errorf("Error getting asset pair of %s: %s", symbol, err)
+		return nil, errors.ErrSymbolFiltersNotAvailable
+	}
+	for _, pair := range pairs {
+		minQuantity, err := strconv.ParseFloat(pair.OrderMin, 64)
+		if err != nil {
+			logger.Errorf("Error parsing order minimum of %s: %s", symbol, err)
+			return nil, errors.ErrSymbolFiltersNotAvailable
+		}
+		minNotional := 0.0
+		if pair.CostMin != "" {
+			minNotional, err = strconv.ParseFloat(pair.CostMin, 64)
+			if err != nil {
+				logger.Errorf("Error parsing cost minimum of %s: %s", symbol, err)
+				return nil, errors.ErrSymbolFiltersNotAvailable
+			}
+		}
+		return &valueobjects.ExchangeSymbolFilters{
+			Symbol:            symbol.String(),
+			MinQuantity:       minQuantity,
+			StepSize:          math.Pow10(-int(pair.LotDecimals)),
+			MinNotional:       minNotional,
+			QuantityPrecision: pair.LotDecimals,
+			PricePrecision:    pair.PairDecimals,
+		}, nil
+	}
+	logger.Errorf("No asset pair found for symbol: %s", symbol)
+	return nil, errors.ErrSymbolFiltersNotAvailable
+}
+
+// fillOrder waits for a placed order to close and fills in what it
+// executed. Orders still open after the last attempt are reported as they
+// stand.
+func (a *KrakenExchangeAdapter) fillOrder(
+	ctx echo.Context,
+	order *entities.ExchangeOrder,
+) error {
+	requestCtx := ctx.Request().Context()
+	for attempt := 1; ; attempt++ {
+		orders, err := a.client.QueryOrders(requestCtx, order.ID)
+		if err != nil {
+			return err
+		}
+		krakenOrder, ok := orders[order.ID]
+		if !ok {
+			return errors.ErrOrderFillNotAvailable
+		}
+		executed, err := strconv.ParseFloat(krakenOrder.VolumeExecuted, 64)
+		if err != nil {
+			return err
+		}
+		cost, err := strconv.ParseFloat(krakenOrder.Cost, 64)
+		if err != nil {
+			return err
+		}
+		fee := 0.0
+		if krakenOrder.Fee != "" {
+			fee, err = strconv.ParseFloat(krakenOrder.Fee, 64)
+			if err != nil {
+				return err
+			}
+		}
+		order.Status = krakenOrder.Status
+		order.ExecutedQuantity = executed
+		order.Fee = fee
+		if executed > 0 {
+			order.Price = cost / executed
+		}
+		open := krakenOrder.Status == krakenOrderStatusPending || krakenOrder.Status == krakenOrderStatusOpen
+		if !open || attempt == krakenFillAttempts {
+			return nil
+		}
+		select {
+		case <-requestCtx.Done():
+			return requestCtx.Err()
+		case <-time.After(krakenFillInterval):
+		}
+	}
+}
+
+// sellAll sells the quantity of the symbol, fitted to the pair rules at the
+// current price. A sale that executed nothing fails with ErrOrderNotFilled.
+func (a *KrakenExchangeAdapter) sellAll(
+	ctx echo.Context,
+	symbol string,
+	quantity float64,
+) (*entities.ExchangeOrder, error) {
+	ticker, err := a.GetTicker(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantity, err = a.PrepareOrderQuantity(ctx, symbol, quantity, ticker.Price)
+	if err != nil {
+		return nil, err
+	}
+	sold, err := a.PlaceMarketOrder(ctx, symbol, constants.ExchangeOrderSideSell, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if sold.ExecutedQuantity == 0 {
+		return nil, errors.ErrOrderNotFilled
+	}
+	return sold, nil
+}
+
+// buyWith buys as much of the symbol as the amount of ledger quote asset
+// pays for at the current price, keeping back the fee of the buy.
+func (a *KrakenExchangeAdapter) buyWith(
+	ctx echo.Context,
+	symbol string,
+	amount float64,
+) (*entities.ExchangeOrder, error) {
+	ticker, err := a.GetTicker(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if ticker.Price <= 0 {
+		return nil, errors.ErrInvalidPrice
+	}
+	quantity, err := a.PrepareOrderQuantity(
+		ctx,
+		symbol,
+		amount*(1-krakenBuyFeeReserve)/ticker.Price,
+		ticker.Price,
+	)
+	if err != nil {
+		return nil, err
+	}
+	bought, err := a.PlaceMarketOrder(ctx, symbol, constants.ExchangeOrderSideBuy, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if bought.ExecutedQuantity == 0 {
+		return nil, errors.ErrOrderNotFilled
+	}
+	return bought, nil
+}
+
+// quotePrice returns the price of an asset in the ledger quote asset.
+func (a *KrakenExchangeAdapter) quotePrice(ctx echo.Context, asset string) (float64, error) {
+	if asset == constants.LedgerQuoteAsset {
+		return 1, nil
+	}
+	ticker, err := a.GetTicker(ctx, asset+constants.LedgerQuoteAsset)
+	if err != nil {
+		return 0, err
+	}
+	return ticker.Price, nil
+}
+
+// krakenPermission tells from the outcome of a probe whether the key has the
+// permission it needs. Kraken errors other than a denied permission mean the
+// request got past the permission check, e.g. for lack of funds.
+func krakenPermission(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	krakenErr, ok := err.(*exchange.KrakenError)
+	if !ok {
+		return false, err
+	}
+	return !krakenErr.IsPermissionDenied(), nil
+}
+
+func normalizeKrakenAsset(asset string) string {
+	asset = strings.ToUpper(asset)
+	if canonical, ok := krakenLegacyAssets[asset]; ok {
+		return canonical
+	}
+	if canonical, ok := krakenAssetAliases[asset]; ok {
+		return canonical
+	}
+	return asset
+}
+
+func krakenAsset(asset string) string {
+	for krakenName, canonical := range krakenAssetAliases {
+		if canonical == asset {
+			return krakenName
+		}
+	}
+	return asset
+}
@@ -0,0 +1,264 @@
+package exchanges
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
+)
+
+// Structs
+
+// KrakenKlineStreamService feeds klines by polling the OHLC endpoint, so
+// consumers can treat Kraken like any other ExchangeWebSocketService.
+type KrakenKlineStreamService struct {
+	adapter      *KrakenExchangeAdapter
+	ps           *pubsub.EventsPubSub
+	pollInterval time.Duration
+	mutex        sync.RWMutex
+	wg           sync.WaitGroup
+	// Open time of the last final candle emitted, keyed by interval and symbol
+	subscriptions map[string]map[string]time.Time
+	klines        chan valueobjects.WebSocketKline
+	errs          chan error
+	stopC         chan struct{}
+	running       bool
+	connected     bool
+	closed        bool
+}
+
+// Factories
+
+func NewKrakenKlineStreamService(
+	adapter *KrakenExchangeAdapter,
+	ps *pubsub.EventsPubSub,
+	pollInterval time.Duration,
+) *KrakenKlineStreamService {
+	conf := config.GetConfig()
+	return &KrakenKlineStreamService{
+		adapter:       adapter,
+		ps:            ps,
+		pollInterval:  pollInterval,
+		subscriptions: make(map[string]map[string]time.Time),
+		klines:        make(chan valueobjects.WebSocketKline, conf.Binance.WebSocketBufferSize),
+		errs:          make(chan error, conf.Binance.WebSocketBufferSize),
+		stopC:         make(chan struct{}),
+	}
+}
+
+// ExchangeWebSocketService implementation
+
+func (s *KrakenKlineStreamService) SubscribeToKlines(
+	ctx context.Context,
+	subscription valueobjects.WebSocketSubscription,
+) (<-chan valueobjects.WebSocketKline, error) {
+	if len(subscription.Symbols) == 0 || len(subscription.Intervals) == 0 {
+		return nil, errors.ErrWebSocketSubscriptionFailed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.ErrWebSocketSubscriptionFailed
+	}
+	for _, interval := range subscription.Intervals {
+		if _, ok := krakenIntervals[interval]; !ok {
+			return nil, errors.ErrInvalidKlineInterval
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil, errors.ErrWebSocketStreamClosed
+	}
+	for _, interval := range subscription.Intervals {
+		symbols, ok := s.subscriptions[interval]
+		if !ok {
+			symbols = make(map[string]time.Time)
+			s.subscriptions[interval] = symbols
+		}
+		for _, symbol := range subscription.Symbols {
+			symbol = strings.ToUpper(symbol)
+			if _, ok := symbols[symbol]; !ok {
+				symbols[symbol] = time.Time{}
+			}
+		}
+	}
+	if !s.running {
+		s.running = true
+		s.connected = true
+		s.wg.Add(1)
+		go s.poll()
+	}
+	return s.klines, nil
+}
+
+func (s *KrakenKlineStreamService) UnsubscribeFromKlines(
+	ctx context.Context,
+	subscription valueobjects.WebSocketSubscription,
+) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.ErrWebSocketStreamClosed
+	}
+	for _, interval := range subscription.Intervals {
+		symbols, ok := s.subscriptions[interval]
+		if !ok {
+			return errors.ErrWebSocketSubscriptionFailed
+		}
+		for _, symbol := range subscription.Symbols {
+			delete(symbols, strings.ToUpper(symbol))
+		}
+		if len(symbols) == 0 {
+			delete(s.subscriptions, interval)
+		}
+	}
+	return nil
+}
+
+func (s *KrakenKlineStreamService) IsConnected() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return !s.closed && s.connected && len(s.subscriptions) > 0
+}
+
+func (s *KrakenKlineStreamService) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stopC)
+	s.mutex.Unlock()
+	s.wg.Wait()
+	close(s.klines)
+	close(s.errs)
+	return nil
+}
+
+// Errors returns the channel where polling errors are reported. It is
+// closed together with the klines channel.
+func (s *KrakenKlineStreamService) Errors() <-chan error {
+	return s.errs
+}
+
+// Helpers
+
+func (s *KrakenKlineStreamService) poll() {
+	defer s.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stopC
+		cancel()
+	}()
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		s.pollOnce(ctx)
+		select {
+		case <-s.stopC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *KrakenKlineStreamService) pollOnce(ctx context.Context) {
+	logger := config.GetLogger()
+	failed := false
+	for interval, symbols := range s.snapshot() {
+		for symbol, since := range symbols {
+			if ctx.Err() != nil {
+				return
+			}
+			klines, err := s.adapter.fetchKlines(ctx, symbol, interval, since)
+			if err != nil {
+				logger.Errorf("Error polling %s %s klines: %s", symbol, interval, err)
+				s.reportError(errors.ErrExchangeRequestFailed)
+				failed = true
+				continue
+			}
+			for _, kline := range klines {
+				// Kraken includes the candle at "since" again, skip what was sent
+				if kline.IsFinal && !kline.OpenTime.After(since) {
+					continue
+				}
+				s.emit(kline)
+				if kline.IsFinal {
+					since = kline.OpenTime
+				}
+			}
+			s.advance(interval, symbol, since)
+		}
+	}
+	s.mutex.Lock()
+	s.connected = !failed
+	s.mutex.Unlock()
+}
+
+func (s *KrakenKlineStreamService) snapshot() map[string]map[string]time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	snapshot := make(map[string]map[string]time.Time, len(s.subscriptions))
+	for interval, symbols := range s.subscriptions {
+		snapshot[interval] = make(map[string]time.Time, len(symbols))
+		for symbol, since := range symbols {
+			snapshot[interval][symbol] = since
+		}
+	}
+	return snapshot
+}
+
+func (s *KrakenKlineStreamService) advance(interval string, symbol string, since time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// The symbol may have been unsubscribed while polling
+	if symbols, ok := s.subscriptions[interval]; ok {
+		if _, ok := symbols[symbol]; ok {
+			symbols[symbol] = since
+		}
+	}
+}
+
+func (s *KrakenKlineStreamService) emit(kline valueobjects.WebSocketKline) {
+	logger := config.GetLogger()
+	select {
+	case s.klines <- kline:
+	default:
+		logger.Warnf("Klines buffer full. Dropping %s %s kline", kline.Symbol, kline.Interval)
+	}
+	if s.ps == nil {
+		return
+	}
+	marketDataEventFactory := events.MarketDataEventFactory{}
+	ev := marketDataEventFactory.NewMarketDataEvent(
+		uuid.Nil,
+		kline.Symbol,
+		kline.OpenTime,
+		kline.Open,
+		kline.High,
+		kline.Low,
+		kline.Close,
+		kline.Volume,
+		kline.IsFinal,
+	)
+	err := ev.Dispatch(s.ps)
+	if err != nil {
+		logger.Errorf("Error dispatching market data event: %s", err)
+		s.reportError(err)
+	}
+}
+
+func (s *KrakenKlineStreamService) reportError(err error) {
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
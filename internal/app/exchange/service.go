@@ -12,8 +12,11 @@ import (
 type ExchangeService interface {
 	GetBalance(ctx echo.Context, asset string) (*valueobjects.ExchangeBalance, error)
 	GetBalances(ctx echo.Context) (*[]valueobjects.ExchangeBalance, error)
+	GetApiKeyPermissions(ctx echo.Context) (*valueobjects.ExchangeApiKeyPermissions, error)
 	GetTicker(ctx echo.Context, symbol string) (*valueobjects.ExchangeTicker, error)
 	GetAvailableSymbols(ctx echo.Context) (*[]valueobjects.ExchangeAvailableSymbol, error)
+	GetSymbolFilters(ctx echo.Context, symbol string) (*valueobjects.ExchangeSymbolFilters, error)
+	PrepareOrderQuantity(ctx echo.Context, symbol string, quantity float64, price float64) (float64, error)
 	GetConversionQuote(ctx echo.Context, fromAsset string, toAsset string, fromAmount float64, walletType string) (*entities.ExchangeConversionQuote, error)
 	AcceptConversionQuote(ctx echo.Context, id string) (*entities.ExchangeConversionOrder, error)
 	ConvertAsset(ctx echo.Context, userID string, fromAsset string, toAsset string, fromAmount float64, walletType string) (*entities.ExchangeConversionOrder, error)
@@ -29,3 +32,24 @@ type ExchangeWebSocketService interface {
 	IsConnected() bool
 	Close() error
 }
+
+// ExchangeAdapter hides a concrete exchange behind canonical symbols
+// (BASEQUOTE, e.g. BTCUSDT), canonical assets and Binance-style intervals.
+type ExchangeAdapter interface {
+	Name() string
+	NormalizeSymbol(exchangeSymbol string) (valueobjects.MarketSymbol, error)
+	ExchangeSymbol(symbol valueobjects.MarketSymbol) string
+	GetBalance(ctx echo.Context, asset string) (*valueobjects.ExchangeBalance, error)
+	GetBalances(ctx echo.Context) (*[]valueobjects.ExchangeBalance, error)
+	GetTicker(ctx echo.Context, symbol string) (*valueobjects.ExchangeTicker, error)
+	GetKlines(ctx echo.Context, symbol string, interval string, from time.Time, to time.Time) (*[]valueobjects.ExchangeKline, error)
+	PlaceMarketOrder(ctx echo.Context, symbol string, side string, quantity float64) (*entities.ExchangeOrder, error)
+	PrepareOrderQuantity(ctx echo.Context, symbol string, quantity float64, price float64) (float64, error)
+	GetConversionQuote(ctx echo.Context, fromAsset string, toAsset string, fromAmount float64, walletType string) (*entities.ExchangeConversionQuote, error)
+	AcceptConversionQuote(ctx echo.Context, quote *entities.ExchangeConversionQuote) (*entities.ExchangeConversionOrder, error)
+	KlineStream() ExchangeWebSocketService
+}
+
+type ExchangeAdapterFactory interface {
+	NewAdapter(apiKey *entities.ApiKey) (ExchangeAdapter, error)
+}
@@ -1 +1,184 @@
 package exchanges
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	binance "github.com/adshao/go-binance/v2"
+	binanceSapiConnector "github.com/binance/binance-connector-go"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
+)
+
+// fakeKlineConnection mimics a combined stream opened by binance.WsCombinedKlineServe.
+type fakeKlineConnection struct {
+	pairs   map[string]string
+	handler binance.WsKlineHandler
+	doneC   chan struct{}
+	stopC   chan struct{}
+	dropC   chan struct{}
+}
+
+type fakeKlineServer struct {
+	mutex       sync.Mutex
+	connections []*fakeKlineConnection
+	failures    int
+}
+
+func (f *fakeKlineServer) Serve(
+	symbolIntervalPair map[string]string,
+	handler binance.WsKlineHandler,
+	errHandler binance.ErrHandler,
+) (doneC, stopC chan struct{}, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return nil, nil, errors.New("dial failed")
+	}
+	connection := &fakeKlineConnection{
+		pairs:   symbolIntervalPair,
+		handler: handler,
+		doneC:   make(chan struct{}),
+		stopC:   make(chan struct{}),
+		dropC:   make(chan struct{}),
+	}
+	go func() {
+		defer close(connection.doneC)
+		select {
+		case <-connection.stopC:
+		case <-connection.dropC:
+		}
+	}()
+	f.connections = append(f.connections, connection)
+	return connection.doneC, connection.stopC, nil
+}
+
+func (f *fakeKlineServer) Connections() []*fakeKlineConnection {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*fakeKlineConnection{}, f.connections...)
+}
+
+func (f *fakeKlineServer) Last() *fakeKlineConnection {
+	connections := f.Connections()
+	return connections[len(connections)-1]
+}
+
+func (f *fakeKlineServer) WaitForConnections(count int) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(f.Connections()) >= count {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func newTestWebSocketService(server *fakeKlineServer) *DefaultExchangeWebSocketService {
+	service := NewDefaultExchangeWebSocketService(nil, server.Serve)
+	service.minBackoff = time.Millisecond
+	service.maxBackoff = 5 * time.Millisecond
+	service.maxReconnects = 3
+	service.heartbeatTimeout = time.Second
+	return service
+}
+
+// fixtureServer replays recorded exchange responses from testdata, keyed by
+// request path, and keeps the requests it received.
+type fixtureServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []recordedRequest
+}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+func newFixtureServer(t *testing.T, fixtures map[string]string) *fixtureServer {
+	server := &fixtureServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mutex.Lock()
+		server.requests = append(server.requests, recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   string(body),
+		})
+		server.mutex.Unlock()
+		fixture, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		payload, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(payload)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fixtureServer) Requests() []recordedRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]recordedRequest{}, f.requests...)
+}
+
+func newTestKrakenAdapter(server *fixtureServer) *KrakenExchangeAdapter {
+	client := &exchange.KrakenClient{
+		BaseURL: server.URL,
+		APIKey:  "test-key",
+		// Secret from the Kraken API documentation signing example
+		APISecret:  "kQH5HW/8p1uGOVjbgWA7FunAmGO8lsSUXNsu3eow76sz84Q18fWxnyRzBHCd3pd5nE9qa99HAZtuZuj6F1huXg==",
+		HTTPClient: server.Client(),
+		Nonce: func() int64 {
+			return 1616492376594
+		},
+	}
+	return NewKrakenExchangeAdapter(client, nil, 10*time.Millisecond)
+}
+
+// newTestBinanceAdapter points both Binance clients at the fixture server
+// and gives the adapter its own symbol filters cache.
+func newTestBinanceAdapter(server *fixtureServer) *BinanceExchangeAdapter {
+	sapiClient := binanceSapiConnector.NewClient("key", "secret", server.URL)
+	sapiClient.HTTPClient = server.Client()
+	generalClient := binance.NewClient("key", "secret")
+	generalClient.BaseURL = server.URL
+	generalClient.HTTPClient = server.Client()
+	adapter := NewBinanceExchangeAdapter(sapiClient, generalClient, nil)
+	adapter.exchangeService.symbolFilters = NewSymbolFiltersCache(time.Hour)
+	return adapter
+}
+
+func newTestContext() echo.Context {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	return echo.New().NewContext(request, httptest.NewRecorder())
+}
+
+func TestMain(m *testing.M) {
+	logger := config.GetLogger()
+	logger.Info("Running exchange service tests...")
+	os.Exit(m.Run())
+}
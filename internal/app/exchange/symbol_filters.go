@@ -0,0 +1,186 @@
+package exchanges
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	binanceSapiConnector "github.com/binance/binance-connector-go"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
+
+const (
+	// Binance filter types
+	symbolFilterPrice         = "PRICE_FILTER"
+	symbolFilterLotSize       = "LOT_SIZE"
+	symbolFilterMarketLotSize = "MARKET_LOT_SIZE"
+	symbolFilterMinNotional   = "MIN_NOTIONAL"
+	symbolFilterNotional      = "NOTIONAL"
+)
+
+var (
+	symbolFiltersCache     *SymbolFiltersCache
+	symbolFiltersCacheOnce sync.Once
+)
+
+// Structs
+
+// SymbolFiltersCache keeps the trading rules of each symbol so they are not
+// fetched from exchange info on every trade.
+type SymbolFiltersCache struct {
+	ttl     time.Duration
+	mutex   sync.RWMutex
+	entries map[string]symbolFiltersEntry
+}
+
+type symbolFiltersEntry struct {
+	filters   valueobjects.ExchangeSymbolFilters
+	fetchedAt time.Time
+}
+
+// Factories
+
+func NewSymbolFiltersCache(ttl time.Duration) *SymbolFiltersCache {
+	return &SymbolFiltersCache{
+		ttl:     ttl,
+		entries: make(map[string]symbolFiltersEntry),
+	}
+}
+
+// GetSymbolFiltersCache returns the cache shared by every exchange service,
+// since trading rules are the same for all users.
+func GetSymbolFiltersCache() *SymbolFiltersCache {
+	symbolFiltersCacheOnce.Do(func() {
+		symbolFiltersCache = NewSymbolFiltersCache(config.GetConfig().Binance.SymbolFiltersTTL)
+	})
+	return symbolFiltersCache
+}
+
+// Receivers
+
+func (c *SymbolFiltersCache) Get(symbol string) (*valueobjects.ExchangeSymbolFilters, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.entries[symbol]
+	if !ok || time.Since(entry.fetchedAt) > c.ttl {
+		return nil, false
+	}
+	filters := entry.filters
+	return &filters, true
+}
+
+func (c *SymbolFiltersCache) Set(filters valueobjects.ExchangeSymbolFilters) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[filters.Symbol] = symbolFiltersEntry{
+		filters:   filters,
+		fetchedAt: time.Now(),
+	}
+}
+
+// Helpers
+
+func parseSymbolFilters(
+	symbol *binanceSapiConnector.SymbolInfo,
+) (valueobjects.ExchangeSymbolFilters, error) {
+	filters := valueobjects.ExchangeSymbolFilters{
+		Symbol:            symbol.Symbol,
+		QuantityPrecision: symbol.BaseAssetPrecision,
+		PricePrecision:    symbol.QuotePrecision,
+	}
+	for _, filter := range symbol.Filters {
+		if filter == nil {
+			continue
+		}
+		values, err := parseFilterValues(filter)
+		if err != nil {
+			return filters, err
+		}
+		switch filter.FilterType {
+		case symbolFilterPrice:
+			if values.tickSize > 0 {
+				filters.PricePrecision = stepPrecision(filter.TickSize)
+			}
+		case symbolFilterLotSize:
+			filters.MinQuantity = math.Max(filters.MinQuantity, values.minQty)
+			filters.MaxQuantity = tighterMaximum(filters.MaxQuantity, values.maxQty)
+			filters.StepSize = values.stepSize
+			if values.stepSize > 0 {
+				filters.QuantityPrecision = stepPrecision(filter.StepSize)
+			}
+		case symbolFilterMarketLotSize:
+			// We only place market orders, so the stricter bounds win
+			filters.MinQuantity = math.Max(filters.MinQuantity, values.minQty)
+			filters.MaxQuantity = tighterMaximum(filters.MaxQuantity, values.maxQty)
+		case symbolFilterMinNotional:
+			filters.MinNotional = math.Max(filters.MinNotional, values.minNotional)
+		case symbolFilterNotional:
+			if filter.ApplyMinToMarket {
+				filters.MinNotional = math.Max(filters.MinNotional, values.minNotional)
+			}
+			if filter.ApplyMaxToMarket {
+				filters.MaxNotional = tighterMaximum(filters.MaxNotional, values.maxNotional)
+			}
+		}
+	}
+	return filters, nil
+}
+
+type symbolFilterValues struct {
+	tickSize    float64
+	minQty      float64
+	maxQty      float64
+	stepSize    float64
+	minNotional float64
+	maxNotional float64
+}
+
+func parseFilterValues(
+	filter *binanceSapiConnector.SymbolFilter,
+) (symbolFilterValues, error) {
+	values := symbolFilterValues{}
+	fields := []struct {
+		raw    string
+		target *float64
+	}{
+		{filter.TickSize, &values.tickSize},
+		{filter.MinQty, &values.minQty},
+		{filter.MaxQty, &values.maxQty},
+		{filter.StepSize, &values.stepSize},
+		{filter.MinNotional, &values.minNotional},
+		{filter.MaxNotional, &values.maxNotional},
+	}
+	for _, field := range fields {
+		if field.raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(field.raw, 64)
+		if err != nil {
+			return values, err
+		}
+		*field.target = value
+	}
+	return values, nil
+}
+
+// stepPrecision returns the decimals of a step such as "0.00010000".
+func stepPrecision(step string) int64 {
+	_, decimals, found := strings.Cut(step, ".")
+	if !found {
+		return 0
+	}
+	return int64(len(strings.TrimRight(decimals, "0")))
+}
+
+func tighterMaximum(current float64, candidate float64) float64 {
+	if candidate <= 0 {
+		return current
+	}
+	if current <= 0 || candidate < current {
+		return candidate
+	}
+	return current
+}
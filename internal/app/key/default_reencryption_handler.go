@@ -0,0 +1,77 @@
+package keys
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+)
+
+// Structs
+
+type DefaultReencryptionHandler struct {
+	keyService *DefaultKeyService
+	period     time.Duration
+	batchSize  int
+}
+
+// Factories
+
+func NewDefaultReencryptionHandler(keyService *DefaultKeyService) *DefaultReencryptionHandler {
+	conf := config.GetConfig().Encryption
+	return &DefaultReencryptionHandler{
+		keyService: keyService,
+		period:     conf.ReencryptPeriod,
+		batchSize:  conf.ReencryptBatchSize,
+	}
+}
+
+// Receivers
+
+// Loop re-encrypts stale keys right away and then every period until the
+// process is told to stop. The first pass doubles as the migration of the
+// plaintext rows written before encryption, and later ones pick up master
+// key rotations.
+func (h *DefaultReencryptionHandler) Loop() {
+	logger := config.GetLogger()
+	logger.Info("Starting key re-encryption loop...")
+	ticker := time.NewTicker(h.period)
+	defer ticker.Stop()
+	// Graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	h.tick()
+	for {
+		select {
+		case <-ticker.C:
+			h.tick()
+		case <-sigChan:
+			logger.Info("Key re-encryption loop shut down.")
+			return
+		}
+	}
+}
+
+// HandleTick re-encrypts every stale key.
+func (h *DefaultReencryptionHandler) HandleTick(ctx echo.Context) error {
+	reencrypted, err := h.keyService.ReencryptKeys(ctx, h.batchSize)
+	if reencrypted > 0 {
+		config.GetLoggerFromContext(ctx).Infof("Re-encrypted %d api keys.", reencrypted)
+	}
+	return err
+}
+
+// Helpers
+
+func (h *DefaultReencryptionHandler) tick() {
+	logger := config.GetLogger()
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("logger", logger)
+	err := h.HandleTick(ctx)
+	if err != nil {
+		logger.Errorf("Error re-encrypting api keys: %s", err)
+	}
+}
@@ -1,29 +1,65 @@
 package keys
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	exchanges "github.com/sergiovirahonda/endurance-api/internal/app/exchange"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
+	"github.com/sergiovirahonda/endurance-api/internal/app/users"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/exchange"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/key"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+	"gorm.io/gorm"
 )
 
 // Structs
 
 type DefaultKeyService struct {
+	KeyRepository     key.KeyRepository
+	KeyVerifier       KeyVerifier
+	SecondFactorGuard users.SecondFactorGuard
+	UacService        uacs.UacService
+}
+
+type DefaultKeyVerifier struct {
+	cfg *config.Config
+}
+
+type DefaultCredentialResolver struct {
 	KeyRepository key.KeyRepository
-	UacService    uacs.UacService
 }
 
 // Factories
 
 func NewDefaultKeyService(
 	keyRepository key.KeyRepository,
+	keyVerifier KeyVerifier,
+	secondFactorGuard users.SecondFactorGuard,
 	uacService uacs.UacService,
 ) *DefaultKeyService {
-	return &DefaultKeyService{KeyRepository: keyRepository, UacService: uacService}
+	return &DefaultKeyService{
+		KeyRepository:     keyRepository,
+		KeyVerifier:       keyVerifier,
+		SecondFactorGuard: secondFactorGuard,
+		UacService:        uacService,
+	}
+}
+
+func NewDefaultKeyVerifier(cfg *config.Config) *DefaultKeyVerifier {
+	return &DefaultKeyVerifier{cfg: cfg}
+}
+
+func NewDefaultCredentialResolver(keyRepository key.KeyRepository) *DefaultCredentialResolver {
+	return &DefaultCredentialResolver{KeyRepository: keyRepository}
 }
 
 // KeyService implementation
@@ -60,10 +96,18 @@ func (d *DefaultKeyService) Create(
 	if err != nil {
 		return nil, err
 	}
+	err = d.SecondFactorGuard.RequireSecondFactor(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, err
+	}
 	err = apiKey.Validate()
 	if err != nil {
 		return nil, err
 	}
+	err = d.verify(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
 	return d.KeyRepository.Create(ctx, apiKey)
 }
 
@@ -75,10 +119,18 @@ func (d *DefaultKeyService) Update(
 	if err != nil {
 		return nil, err
 	}
+	err = d.SecondFactorGuard.RequireSecondFactor(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, err
+	}
 	err = apiKey.Validate()
 	if err != nil {
 		return nil, err
 	}
+	err = d.verify(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
 	return d.KeyRepository.Update(ctx, apiKey)
 }
 
@@ -93,25 +145,150 @@ func (d *DefaultKeyService) Delete(
 	return d.KeyRepository.Delete(ctx, id)
 }
 
-func (d *DefaultKeyService) GetTelegramKeys(
+// ReencryptKeys rewrites, batch by batch, every key not encrypted with the
+// current master key: plaintext rows left from before encryption and rows
+// wrapped by a rotated out key. It runs as a system job, with no owner.
+func (d *DefaultKeyService) ReencryptKeys(
 	ctx echo.Context,
-) (*entities.ApiKey, error) {
-	filters := filtering.NewComplexFilter(
-		ctx,
-		map[string]interface{}{
-			"type": "telegram",
-		},
-		"created_at",
-		"desc",
-		1,
-		1,
-	)
-	keys, err := d.GetAll(ctx, filters)
+	batchSize int,
+) (int, error) {
+	reencrypted := 0
+	for {
+		stale, err := d.KeyRepository.GetStale(ctx, batchSize)
+		if err != nil {
+			return reencrypted, err
+		}
+		if len(*stale) == 0 {
+			return reencrypted, nil
+		}
+		for i := range *stale {
+			_, err = d.KeyRepository.Update(ctx, &(*stale)[i])
+			if err != nil {
+				return reencrypted, err
+			}
+			reencrypted++
+		}
+	}
+}
+
+// CredentialResolver implementation
+
+func (r *DefaultCredentialResolver) ResolveExchangeCredentials(
+	ctx echo.Context,
+	userID uuid.UUID,
+	service string,
+) (*valueobjects.ExchangeCredentials, error) {
+	if !lib.SliceContains(constants.ExchangeServiceTypes, service) {
+		return nil, errors.ErrExchangeNotSupported
+	}
+	apiKey, err := r.KeyRepository.GetLatestByService(ctx, userID, service)
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrApiKeyNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	if len(*keys) == 0 {
+	return &valueobjects.ExchangeCredentials{
+		APIKey:    apiKey.Key,
+		APISecret: apiKey.Secret,
+	}, nil
+}
+
+// ResolveExchangeKey returns the most recently created key the user has for
+// any exchange, which is the exchange the user trades on.
+func (r *DefaultCredentialResolver) ResolveExchangeKey(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.ApiKey, error) {
+	var latest *entities.ApiKey
+	for _, service := range constants.ExchangeServiceTypes {
+		apiKey, err := r.KeyRepository.GetLatestByService(ctx, userID, service)
+		if err == gorm.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || apiKey.CreatedAt.After(latest.CreatedAt) {
+			latest = apiKey
+		}
+	}
+	if latest == nil {
+		return nil, errors.ErrApiKeyNotFound
+	}
+	return latest, nil
+}
+
+// ResolveTelegramCredentials reads the bot token from the key and the chat
+// ID from the secret.
+func (r *DefaultCredentialResolver) ResolveTelegramCredentials(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*valueobjects.TelegramCredentials, error) {
+	apiKey, err := r.KeyRepository.GetLatestByService(ctx, userID, constants.ApiKeyServiceTypeTelegram)
+	if err == gorm.ErrRecordNotFound {
 		return nil, errors.ErrApiKeyTelegramNotFound
 	}
-	return &(*keys)[0], nil
+	if err != nil {
+		return nil, err
+	}
+	chatID, err := strconv.ParseInt(apiKey.Secret, 10, 64)
+	if err != nil {
+		return nil, errors.ErrApiKeyInvalid
+	}
+	return &valueobjects.TelegramCredentials{
+		BotToken: apiKey.Key,
+		ChatID:   chatID,
+	}, nil
+}
+
+// KeyVerifier implementation
+
+func (v *DefaultKeyVerifier) Verify(
+	ctx echo.Context,
+	apiKey *entities.ApiKey,
+) (*valueobjects.ExchangeApiKeyPermissions, error) {
+	credentials := &valueobjects.ExchangeCredentials{
+		APIKey:    apiKey.Key,
+		APISecret: apiKey.Secret,
+	}
+	switch apiKey.Service {
+	case constants.ApiKeyServiceTypeBinance:
+		exchangeService := exchanges.NewDefaultExchangeService(
+			exchange.NewSapiClient(v.cfg, credentials),
+			exchange.NewUserGeneralClient(v.cfg, credentials),
+		)
+		return exchangeService.GetApiKeyPermissions(ctx)
+	case constants.ApiKeyServiceTypeKraken:
+		adapter := exchanges.NewKrakenExchangeAdapter(
+			exchange.NewKrakenClient(v.cfg, credentials),
+			nil,
+			v.cfg.Kraken.PollInterval,
+		)
+		return adapter.GetApiKeyPermissions(ctx)
+	case constants.ApiKeyServiceTypeTelegram:
+		// Bot tokens aren't exchange keys, there is nothing to verify
+		return nil, nil
+	default:
+		return nil, errors.ErrExchangeNotSupported
+	}
+}
+
+// Helpers
+
+// verify stores the permissions the exchange reports for the key, clearing
+// the ones of a previous verification for services that can't be verified.
+func (d *DefaultKeyService) verify(ctx echo.Context, apiKey *entities.ApiKey) error {
+	permissions, err := d.KeyVerifier.Verify(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	if permissions == nil {
+		apiKey.CanTrade = false
+		apiKey.CanWithdraw = false
+		apiKey.IPRestricted = false
+		apiKey.VerifiedAt = nil
+		return nil
+	}
+	return apiKey.Verify(permissions, time.Now().UTC())
 }
@@ -1,15 +1,20 @@
 package keys
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/key"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -221,6 +226,175 @@ func TestCreateApiKeyReturnsApiKey(t *testing.T) {
 	assert.Equal(t, apiKey.ID, ak.ID)
 }
 
+func TestCreateApiKeyStoresVerifiedPermissions(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	keyVerifier.permissions = &valueobjects.ExchangeApiKeyPermissions{
+		CanRead:      true,
+		CanTrade:     true,
+		IPRestricted: true,
+	}
+	t.Cleanup(func() { keyVerifier.permissions = nil })
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		userID,
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+
+	// Act
+	_, err := keyService.Create(ctx, apiKey)
+
+	// Assert
+	assert.NoError(t, err)
+	foundApiKey, err := keyService.GetByID(ctx, apiKey.ID)
+	assert.NoError(t, err)
+	assert.True(t, foundApiKey.CanTrade)
+	assert.False(t, foundApiKey.CanWithdraw)
+	assert.True(t, foundApiKey.IPRestricted)
+	assert.NotNil(t, foundApiKey.VerifiedAt)
+}
+
+func TestCreateApiKeyReturnsErrorIfWithdrawalsEnabled(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	keyVerifier.permissions = &valueobjects.ExchangeApiKeyPermissions{
+		CanRead:     true,
+		CanTrade:    true,
+		CanWithdraw: true,
+	}
+	t.Cleanup(func() { keyVerifier.permissions = nil })
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		userID,
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+
+	// Act
+	_, err := keyService.Create(ctx, apiKey)
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyWithdrawEnabled, err)
+	_, err = keyRepository.GetByID(ctx, apiKey.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestCreateApiKeyReturnsErrorIfSecondFactorMissing(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	secondFactorGuard.err = errors.ErrMFARequired
+	t.Cleanup(func() { secondFactorGuard.err = nil })
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		userID,
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+
+	// Act
+	_, err := keyService.Create(ctx, apiKey)
+
+	// Assert
+	assert.Equal(t, errors.ErrMFARequired, err)
+	_, err = keyRepository.GetByID(ctx, apiKey.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestCreateApiKeyReturnsErrorIfTradingDisabled(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	keyVerifier.permissions = &valueobjects.ExchangeApiKeyPermissions{CanRead: true}
+	t.Cleanup(func() { keyVerifier.permissions = nil })
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		userID,
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+
+	// Act
+	_, err := keyService.Create(ctx, apiKey)
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyTradingDisabled, err)
+}
+
+func TestCreateApiKeyReturnsErrorIfExchangeRejectsKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	keyVerifier.err = errors.ErrApiKeyRejected
+	t.Cleanup(func() { keyVerifier.err = nil })
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		userID,
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+
+	// Act
+	_, err := keyService.Create(ctx, apiKey)
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyRejected, err)
+}
+
+func TestApiKeyJSONMasksSecret(t *testing.T) {
+	// Arrange
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret-1234",
+	)
+
+	// Act
+	payload, err := json.Marshal(apiKey)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(payload), `"key":"test-api-key"`)
+	assert.Contains(t, string(payload), `"secret":"****************1234"`)
+	assert.NotContains(t, string(payload), "test-api-secret")
+	assert.Equal(t, "test-api-secret-1234", apiKey.Secret)
+}
+
+func TestApiKeyJSONMasksTelegramBotToken(t *testing.T) {
+	// Arrange
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeTelegram,
+		"123456:telegram-bot-token",
+		"",
+	)
+
+	// Act
+	payload, err := json.Marshal(apiKey)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, string(payload), `"key":"*********************oken"`)
+	assert.NotContains(t, string(payload), "telegram-bot")
+	assert.Equal(t, "123456:telegram-bot-token", apiKey.Key)
+}
+
 func TestCreateApiKeyReturnsErrorIfNotOwner(t *testing.T) {
 	// Arrange
 	ctx := echo.New().NewContext(nil, nil)
@@ -538,3 +712,192 @@ func TestCreateApiKeyWithDifferentUsers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(*user2Keys))
 }
+
+func TestReencryptKeysEncryptsPlaintextKeys(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+	dto := dtos.ApiKey{}
+	dto.FromEntity(apiKey)
+	database.Create(&dto)
+
+	// Act
+	reencrypted, err := keyService.ReencryptKeys(ctx, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, reencrypted, 1)
+	stored := dtos.ApiKey{}
+	database.Where("id = ?", apiKey.ID).First(&stored)
+	assert.Equal(t, "test", stored.MasterKeyID)
+	assert.NotEqual(t, "test-api-secret", stored.Secret)
+	foundApiKey, err := keyRepository.GetByID(ctx, apiKey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-api-secret", foundApiKey.Secret)
+}
+
+func TestReencryptKeysMovesKeysToTheCurrentMasterKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		userID,
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+	_, err := keyService.Create(ctx, apiKey)
+	assert.NoError(t, err)
+	keyring, err := lib.NewKeyring("next", map[string][]byte{
+		"next": []byte("abcdef0123456789abcdef0123456789"),
+		"test": testMasterKey,
+	})
+	assert.NoError(t, err)
+	rotatedRepository := key.NewDefaultKeyRepository(database, keyring)
+	rotatedService := NewDefaultKeyService(rotatedRepository, keyVerifier, secondFactorGuard, uacService)
+
+	// Act
+	_, err = rotatedService.ReencryptKeys(ctx, 10)
+
+	// Assert
+	assert.NoError(t, err)
+	stale, err := rotatedRepository.GetStale(ctx, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, *stale)
+	foundApiKey, err := rotatedService.GetByID(ctx, apiKey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-api-secret", foundApiKey.Secret)
+	_, err = keyService.GetByID(ctx, apiKey.ID)
+	assert.Equal(t, errors.ErrEncryptionKeyUnknown, err)
+}
+
+// --- CredentialResolver Tests ---
+
+func TestResolveTelegramCredentialsWithoutUserInContext(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	older := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeTelegram, "old-bot-token", "111")
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	_, err := keyRepository.Create(ctx, older)
+	assert.NoError(t, err)
+	latest := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeTelegram, "bot-token", "424242")
+	_, err = keyRepository.Create(ctx, latest)
+	assert.NoError(t, err)
+	binanceKey := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeBinance, "test-api-key", "test-api-secret")
+	_, err = keyRepository.Create(ctx, binanceKey)
+	assert.NoError(t, err)
+
+	// Act
+	credentials, err := credentialResolver.ResolveTelegramCredentials(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, valueobjects.TelegramCredentials{BotToken: "bot-token", ChatID: 424242}, *credentials)
+}
+
+func TestResolveTelegramCredentialsReturnsErrorIfNotFound(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := credentialResolver.ResolveTelegramCredentials(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyTelegramNotFound, err)
+}
+
+func TestResolveTelegramCredentialsReturnsErrorIfChatIDInvalid(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	_, err := keyRepository.Create(
+		ctx,
+		apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeTelegram, "bot-token", "not-a-chat"),
+	)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = credentialResolver.ResolveTelegramCredentials(ctx, userID)
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyInvalid, err)
+}
+
+func TestResolveExchangeCredentialsReturnsKeyPair(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New()})
+	userID := uuid.New()
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	_, err := keyRepository.Create(
+		ctx,
+		apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeBinance, "test-api-key", "test-api-secret"),
+	)
+	assert.NoError(t, err)
+
+	// Act
+	credentials, err := credentialResolver.ResolveExchangeCredentials(ctx, userID, constants.ApiKeyServiceTypeBinance)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, valueobjects.ExchangeCredentials{APIKey: "test-api-key", APISecret: "test-api-secret"}, *credentials)
+	_, err = credentialResolver.ResolveExchangeCredentials(ctx, userID, constants.ApiKeyServiceTypeKraken)
+	assert.Equal(t, errors.ErrApiKeyNotFound, err)
+}
+
+func TestResolveExchangeCredentialsReturnsErrorIfNotAnExchange(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := credentialResolver.ResolveExchangeCredentials(ctx, uuid.New(), constants.ApiKeyServiceTypeTelegram)
+
+	// Assert
+	assert.Equal(t, errors.ErrExchangeNotSupported, err)
+}
+
+func TestResolveExchangeKeyReturnsLatestExchangeKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	binanceKey := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeBinance, "binance-key", "binance-secret")
+	binanceKey.CreatedAt = binanceKey.CreatedAt.Add(-time.Hour)
+	krakenKey := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeKraken, "kraken-key", "kraken-secret")
+	telegramKey := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeTelegram, "bot-token", "424242")
+	telegramKey.CreatedAt = telegramKey.CreatedAt.Add(time.Hour)
+	for _, apiKey := range []*entities.ApiKey{binanceKey, krakenKey, telegramKey} {
+		_, err := keyRepository.Create(ctx, apiKey)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	apiKey, err := credentialResolver.ResolveExchangeKey(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, krakenKey.ID, apiKey.ID)
+	assert.Equal(t, "kraken-secret", apiKey.Secret)
+}
+
+func TestResolveExchangeKeyReturnsErrorIfNotFound(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := credentialResolver.ResolveExchangeKey(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyNotFound, err)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 )
 
@@ -13,5 +14,20 @@ type KeyService interface {
 	Create(ctx echo.Context, apiKey *entities.ApiKey) (*entities.ApiKey, error)
 	Update(ctx echo.Context, apiKey *entities.ApiKey) (*entities.ApiKey, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
-	GetTelegramKeys(ctx echo.Context) (*entities.ApiKey, error)
+	ReencryptKeys(ctx echo.Context, batchSize int) (int, error)
+}
+
+// CredentialResolver looks up the credentials a user keeps for a service.
+// It doesn't check who is asking, so system jobs can resolve them on behalf
+// of any user.
+type CredentialResolver interface {
+	ResolveExchangeCredentials(ctx echo.Context, userID uuid.UUID, service string) (*valueobjects.ExchangeCredentials, error)
+	ResolveExchangeKey(ctx echo.Context, userID uuid.UUID) (*entities.ApiKey, error)
+	ResolveTelegramCredentials(ctx echo.Context, userID uuid.UUID) (*valueobjects.TelegramCredentials, error)
+}
+
+// KeyVerifier asks the exchange of a key what the key is allowed to do. It
+// returns no permissions for telegram keys, which belong to no exchange.
+type KeyVerifier interface {
+	Verify(ctx echo.Context, apiKey *entities.ApiKey) (*valueobjects.ExchangeApiKeyPermissions, error)
 }
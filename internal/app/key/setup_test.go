@@ -4,19 +4,32 @@ import (
 	"os"
 	"testing"
 
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/key"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gorm.io/gorm"
 )
 
 var (
+	// Master key of the test keyring, known so tests can check the rows
+	testMasterKey = []byte("0123456789abcdef0123456789abcdef")
 	database      *gorm.DB
 	keyRepository key.KeyRepository
 	uacService    uacs.UacService
 	keyService    KeyService
+	keyVerifier   *stubKeyVerifier
+	// Second factor
+	secondFactorGuard *stubSecondFactorGuard
+	// Credential resolver
+	credentialResolver CredentialResolver
 )
 
 func TestMain(m *testing.M) {
@@ -31,9 +44,48 @@ func TestMain(m *testing.M) {
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
 	logger.Info("Migrations completed. Running tests...")
-	keyRepository = key.NewDefaultKeyRepository(database)
+	keyring, err := lib.NewKeyring("test", map[string][]byte{"test": testMasterKey})
+	if err != nil {
+		logger.Fatalf("Error building test keyring: %s", err)
+	}
+	keyRepository = key.NewDefaultKeyRepository(database, keyring)
 	uacService = uacs.NewDefaultUacService()
-	keyService = NewDefaultKeyService(keyRepository, uacService)
+	keyVerifier = &stubKeyVerifier{}
+	secondFactorGuard = &stubSecondFactorGuard{}
+	keyService = NewDefaultKeyService(keyRepository, keyVerifier, secondFactorGuard, uacService)
+	credentialResolver = NewDefaultCredentialResolver(keyRepository)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
+
+// stubKeyVerifier reports the permissions of a spot trading key without
+// withdrawals for binance keys, unless told otherwise.
+type stubKeyVerifier struct {
+	permissions *valueobjects.ExchangeApiKeyPermissions
+	err         error
+}
+
+func (v *stubKeyVerifier) Verify(
+	ctx echo.Context,
+	apiKey *entities.ApiKey,
+) (*valueobjects.ExchangeApiKeyPermissions, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if apiKey.Service != constants.ApiKeyServiceTypeBinance {
+		return nil, nil
+	}
+	if v.permissions != nil {
+		return v.permissions, nil
+	}
+	return &valueobjects.ExchangeApiKeyPermissions{CanRead: true, CanTrade: true}, nil
+}
+
+// stubSecondFactorGuard lets every action through, unless told otherwise.
+type stubSecondFactorGuard struct {
+	err error
+}
+
+func (g *stubSecondFactorGuard) RequireSecondFactor(ctx echo.Context, userID uuid.UUID) error {
+	return g.err
+}
@@ -17,8 +17,10 @@ import (
 // Structs
 
 type DefaultMarketDataEventHandler struct {
-	marketDataService MarketDataService
-	eventsPubSub      *pubsub.EventsPubSub
+	marketDataService   MarketDataService
+	marketRegimeService MarketRegimeService
+	alertRuleService    AlertRuleService
+	eventsPubSub        *pubsub.EventsPubSub
 }
 
 type DefaultMarketDataEventRegistry struct {
@@ -29,11 +31,15 @@ type DefaultMarketDataEventRegistry struct {
 
 func NewDefaultMarketDataEventHandler(
 	marketDataService MarketDataService,
+	marketRegimeService MarketRegimeService,
+	alertRuleService AlertRuleService,
 	eventsPubSub *pubsub.EventsPubSub,
 ) *DefaultMarketDataEventHandler {
 	return &DefaultMarketDataEventHandler{
-		marketDataService: marketDataService,
-		eventsPubSub:      eventsPubSub,
+		marketDataService:   marketDataService,
+		marketRegimeService: marketRegimeService,
+		alertRuleService:    alertRuleService,
+		eventsPubSub:        eventsPubSub,
 	}
 }
 
@@ -166,6 +172,21 @@ func (h *DefaultMarketDataEventHandler) HandlePartialMarketData(
 		logger.Error("Error updating market data: %s", err)
 		return err
 	}
+	// Alerts are a side effect of the datapoint, they must not fail it
+	fired, err := h.alertRuleService.Evaluate(ctx, newMarketData)
+	if err != nil {
+		logger.Errorf("Error evaluating alert rules: %s", err)
+	} else if fired > 0 {
+		logger.Infof("Fired %d alert rules on %s", fired, newMarketData.Symbol)
+	}
+	// The regime is measured across all markets, so it is only re-evaluated
+	// once per interval. Failing to do so must not fail the datapoint.
+	regime, err := h.marketRegimeService.Refresh(ctx)
+	if err != nil {
+		logger.Errorf("Error refreshing market regime: %s", err)
+		return nil
+	}
+	logger.Infof("Market regime is %s", regime.Regime)
 	return nil
 }
 
@@ -9,12 +9,15 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/sdcoffey/big"
 	"github.com/sdcoffey/techan"
+	"github.com/sergiovirahonda/endurance-api/internal/app/notifications"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/market"
+	"gorm.io/gorm"
 )
 
 // Structs
@@ -24,6 +27,19 @@ type DefaultMarketDataService struct {
 	UacService           uacs.UacService
 }
 
+type DefaultMarketRegimeService struct {
+	MarketRepository       market.MarketRepository
+	MarketDataRepository   market.MarketDataRepository
+	MarketRegimeRepository market.MarketRegimeRepository
+	cfg                    *config.Config
+}
+
+type DefaultAlertRuleService struct {
+	AlertRuleRepository market.AlertRuleRepository
+	NotificationService *notifications.DefaultNotificationService
+	UacService          uacs.UacService
+}
+
 // Factories
 
 func NewDefaultMarketDataService(
@@ -36,6 +52,32 @@ func NewDefaultMarketDataService(
 	}
 }
 
+func NewDefaultMarketRegimeService(
+	marketRepository market.MarketRepository,
+	marketDataRepository market.MarketDataRepository,
+	marketRegimeRepository market.MarketRegimeRepository,
+	cfg *config.Config,
+) *DefaultMarketRegimeService {
+	return &DefaultMarketRegimeService{
+		MarketRepository:       marketRepository,
+		MarketDataRepository:   marketDataRepository,
+		MarketRegimeRepository: marketRegimeRepository,
+		cfg:                    cfg,
+	}
+}
+
+func NewDefaultAlertRuleService(
+	alertRuleRepository market.AlertRuleRepository,
+	notificationService *notifications.DefaultNotificationService,
+	uacService uacs.UacService,
+) *DefaultAlertRuleService {
+	return &DefaultAlertRuleService{
+		AlertRuleRepository: alertRuleRepository,
+		NotificationService: notificationService,
+		UacService:          uacService,
+	}
+}
+
 // MarketService implementation
 
 func (s *DefaultMarketDataService) GetByID(
@@ -68,7 +110,7 @@ func (s *DefaultMarketDataService) GetLatest(
 		},
 		"created_at",
 		"desc",
-		1,
+		0,
 		1,
 	)
 	marketDatas, err := s.GetAll(ctx, filters)
@@ -103,7 +145,7 @@ func (s *DefaultMarketDataService) Create(
 		},
 		"created_at",
 		"desc",
-		1,
+		0,
 		100,
 	)
 	marketDatas, err := s.MarketDataRepository.GetAll(ctx, cf)
@@ -180,8 +222,8 @@ func (s *DefaultMarketDataService) GetScores(
 	sort.Slice(scores, func(i, j int) bool {
 		return scores[i].Score > scores[j].Score
 	})
-	for i, score := range scores {
-		score.Ranking = i + 1
+	for i := range scores {
+		scores[i].Ranking = i + 1
 	}
 	return scores, nil
 }
@@ -197,7 +239,7 @@ func (s *DefaultMarketDataService) GetSymbolScore(
 		},
 		"created_at",
 		"desc",
-		1,
+		0,
 		1,
 	)
 	marketDatas, err := s.MarketDataRepository.GetAll(ctx, filters)
@@ -1089,3 +1131,319 @@ func (s *DefaultMarketDataService) CalculateVolatilityScore(atr, close float64)
 
 	return score / 100.0 // Normalize to 0-1
 }
+
+// Market Regime Service
+
+// regimeWindowSize bounds the datapoints of a symbol measured per evaluation.
+const regimeWindowSize = 1000
+
+// Evaluate measures the stress of the enabled markets over the lookback window
+// out of the stored market data, classifies the regime and records it.
+func (s *DefaultMarketRegimeService) Evaluate(
+	ctx echo.Context,
+) (*entities.MarketRegime, error) {
+	since := time.Now().UTC().Add(-s.cfg.Regime.Lookback)
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"enabled": true,
+		},
+		"created_at",
+		"desc",
+		0,
+		1000,
+	)
+	mks, err := s.MarketRepository.GetAll(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	measured, below := 0, 0
+	spikes := make([]float64, 0)
+	for _, mk := range *mks {
+		marketDatas, err := s.getWindow(ctx, mk.Symbol, since)
+		if err != nil {
+			return nil, err
+		}
+		if len(marketDatas) == 0 {
+			continue
+		}
+		latest := marketDatas[len(marketDatas)-1]
+		if latest.SMA50 != nil {
+			measured++
+			if latest.Close < *latest.SMA50 {
+				below++
+			}
+		}
+		if spike, ok := atrSpike(marketDatas); ok {
+			spikes = append(spikes, spike)
+		}
+	}
+	benchmark, err := s.getWindow(ctx, s.cfg.Regime.BenchmarkSymbol, since)
+	if err != nil {
+		return nil, err
+	}
+	if measured == 0 && len(spikes) == 0 && len(benchmark) == 0 {
+		return nil, errors.ErrMarketDataInsufficient
+	}
+	breadth := 0.0
+	if measured > 0 {
+		breadth = float64(below) / float64(measured) * 100
+	}
+	spike := 0.0
+	if len(spikes) > 0 {
+		spike = mean(spikes)
+	}
+	drawdown := drawdownFromHigh(benchmark)
+	factory := entities.MarketRegimeFactory{}
+	regime := factory.NewMarketRegime(
+		regimeThresholds(s.cfg).Classify(breadth, spike, drawdown),
+		breadth,
+		spike,
+		drawdown,
+		measured,
+	)
+	if err := regime.Validate(); err != nil {
+		return nil, err
+	}
+	return s.MarketRegimeRepository.Create(ctx, regime)
+}
+
+// Refresh returns the latest regime, evaluating a new one when it is older
+// than the evaluation interval.
+func (s *DefaultMarketRegimeService) Refresh(
+	ctx echo.Context,
+) (*entities.MarketRegime, error) {
+	latest, err := s.GetLatest(ctx)
+	if err != nil && err != errors.ErrMarketRegimeNotFound {
+		return nil, err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.cfg.Regime.EvaluationInterval {
+		return latest, nil
+	}
+	return s.Evaluate(ctx)
+}
+
+func (s *DefaultMarketRegimeService) GetLatest(
+	ctx echo.Context,
+) (*entities.MarketRegime, error) {
+	regime, err := s.MarketRegimeRepository.GetLatest(ctx)
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrMarketRegimeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return regime, nil
+}
+
+func (s *DefaultMarketRegimeService) GetHistory(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.MarketRegimes, error) {
+	filters.SetMetaParameters()
+	return s.MarketRegimeRepository.GetAll(ctx, filters)
+}
+
+// getWindow returns the latest market data of the symbol since the given time,
+// up to regimeWindowSize datapoints, in chronological order.
+func (s *DefaultMarketRegimeService) getWindow(
+	ctx echo.Context,
+	symbol string,
+	since time.Time,
+) (entities.MarketDatas, error) {
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"symbol":         symbol,
+			"timestamp__gte": since,
+		},
+		"timestamp",
+		"desc",
+		0,
+		regimeWindowSize,
+	)
+	marketDatas, err := s.MarketDataRepository.GetAll(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	// Newest first keeps the limit from cutting off the latest datapoints
+	window := *marketDatas
+	for i, j := 0, len(window)-1; i < j; i, j = i+1, j-1 {
+		window[i], window[j] = window[j], window[i]
+	}
+	return window, nil
+}
+
+// Alert Rule Service
+
+func (s *DefaultAlertRuleService) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.AlertRule, error) {
+	rule, err := s.AlertRuleRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.ErrAlertRuleNotFound
+	}
+	if err := s.UacService.IsResourceOwner(ctx, rule.UserID); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *DefaultAlertRuleService) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.AlertRules, error) {
+	filters.SetMetaParameters()
+	filters.NarrowUserFilters("user_id")
+	return s.AlertRuleRepository.GetAll(ctx, filters)
+}
+
+func (s *DefaultAlertRuleService) Create(
+	ctx echo.Context,
+	rule *entities.AlertRule,
+) (*entities.AlertRule, error) {
+	err := rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, rule.UserID); err != nil {
+		return nil, err
+	}
+	return s.AlertRuleRepository.Create(ctx, rule)
+}
+
+// Update stores the rule as the user left it. The last value seen is kept,
+// unless the rule now watches another symbol or field.
+func (s *DefaultAlertRuleService) Update(
+	ctx echo.Context,
+	rule *entities.AlertRule,
+) (*entities.AlertRule, error) {
+	stored, err := s.GetByID(ctx, rule.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	rule.UserID = stored.UserID
+	rule.LastValue = stored.LastValue
+	rule.LastTriggeredAt = stored.LastTriggeredAt
+	if rule.Symbol != stored.Symbol || rule.Field != stored.Field {
+		rule.LastValue = nil
+	}
+	rule.CreatedAt = stored.CreatedAt
+	rule.UpdatedAt = time.Now().UTC()
+	return s.AlertRuleRepository.Update(ctx, rule)
+}
+
+func (s *DefaultAlertRuleService) Delete(
+	ctx echo.Context,
+	id uuid.UUID,
+) error {
+	_, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.AlertRuleRepository.Delete(ctx, id)
+}
+
+// Evaluate observes the datapoint with the enabled rules on its symbol, and
+// notifies the owners of the rules it fires. Rules are evaluated on behalf
+// of each owner, and one failing doesn't block the rest. Returns the number
+// of rules fired.
+func (s *DefaultAlertRuleService) Evaluate(
+	ctx echo.Context,
+	marketData *entities.MarketData,
+) (int, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	rules, err := s.AlertRuleRepository.GetEnabledBySymbol(ctx, marketData.Symbol)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	fired := 0
+	for i := range *rules {
+		rule := &(*rules)[i]
+		value, ok := marketData.Field(rule.Field)
+		if !ok {
+			continue
+		}
+		triggered := rule.Observe(value, now)
+		_, err := s.AlertRuleRepository.Update(ctx, rule)
+		if err != nil {
+			logger.Errorf("Error updating alert rule %s: %s", rule.ID, err)
+			continue
+		}
+		if !triggered {
+			continue
+		}
+		fired++
+		err = s.NotificationService.SendAlertNotification(
+			uacs.NewUserContext(ctx, rule.UserID),
+			rule,
+			marketData,
+			value,
+		)
+		if err != nil {
+			logger.Errorf("Error sending alert of rule %s: %s", rule.ID, err)
+		}
+	}
+	return fired, nil
+}
+
+// Helpers
+
+func regimeThresholds(cfg *config.Config) valueobjects.RegimeThresholds {
+	return valueobjects.RegimeThresholds{
+		RiskOffBreadth:     cfg.Regime.RiskOffBreadth,
+		RiskOnBreadth:      cfg.Regime.RiskOnBreadth,
+		RiskOffATRSpike:    cfg.Regime.RiskOffATRSpike,
+		RiskOffBTCDrawdown: cfg.Regime.RiskOffBTCDrawdown,
+		RiskOnBTCDrawdown:  cfg.Regime.RiskOnBTCDrawdown,
+	}
+}
+
+// atrSpike returns the ratio of the latest ATR to the mean ATR of the window.
+func atrSpike(marketDatas entities.MarketDatas) (float64, bool) {
+	atrs := make([]float64, 0, len(marketDatas))
+	for _, marketData := range marketDatas {
+		if marketData.ATR != nil {
+			atrs = append(atrs, *marketData.ATR)
+		}
+	}
+	if len(atrs) < 2 {
+		return 0, false
+	}
+	average := mean(atrs)
+	if average <= 0 {
+		return 0, false
+	}
+	return atrs[len(atrs)-1] / average, true
+}
+
+// drawdownFromHigh returns how far the latest close is under the highest high
+// of the window, as a percentage.
+func drawdownFromHigh(marketDatas entities.MarketDatas) float64 {
+	if len(marketDatas) == 0 {
+		return 0
+	}
+	high := 0.0
+	for _, marketData := range marketDatas {
+		high = math.Max(high, marketData.High)
+	}
+	close := marketDatas[len(marketDatas)-1].Close
+	if high <= 0 || close >= high {
+		return 0
+	}
+	return (high - close) / high * 100
+}
+
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
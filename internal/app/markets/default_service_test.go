@@ -7,7 +7,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 	"github.com/stretchr/testify/assert"
@@ -1273,3 +1276,382 @@ func generateMarketData(count int) *entities.MarketDatas {
 
 	return &marketDatas
 }
+
+// --- marketRegimeService Tests ---
+
+// newRegimeService returns a regime service measuring the drawdown on its own
+// benchmark, so the market data of other tests does not leak into it.
+func newRegimeService(benchmark string) *DefaultMarketRegimeService {
+	cfg := *config.GetConfig()
+	cfg.Regime.BenchmarkSymbol = benchmark
+	return NewDefaultMarketRegimeService(marketRepository, MarketDataRepository, regimeRepository, &cfg)
+}
+
+// newRegimeMarket enables a market for the duration of the test and stores a
+// datapoint per close, one minute apart, ending now.
+func newRegimeMarket(
+	t *testing.T,
+	symbol string,
+	closes []float64,
+	sma50 *float64,
+	atrs []float64,
+) {
+	ctx := echo.New().NewContext(nil, nil)
+	mk := &entities.Market{
+		ID:        uuid.New(),
+		Symbol:    symbol,
+		Enabled:   true,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	_, err := marketRepository.Create(ctx, mk)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		mk.Enabled = false
+		marketRepository.Update(ctx, mk)
+	})
+	newRegimeMarketData(t, symbol, closes, sma50, atrs)
+}
+
+func newRegimeMarketData(
+	t *testing.T,
+	symbol string,
+	closes []float64,
+	sma50 *float64,
+	atrs []float64,
+) {
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MarketDataFactory{}
+	start := time.Now().UTC().Add(-time.Duration(len(closes)) * time.Minute)
+	for i, close := range closes {
+		marketData := factory.NewRawMarketData(
+			uuid.New(),
+			symbol,
+			start.Add(time.Duration(i+1)*time.Minute),
+			close,
+			close,
+			close,
+			close,
+			1000,
+		)
+		marketData.SMA50 = sma50
+		if atrs != nil {
+			atr := atrs[i]
+			marketData.ATR = &atr
+		}
+		_, err := MarketDataRepository.Create(ctx, marketData)
+		assert.NoError(t, err)
+	}
+}
+
+func TestEvaluateMarketRegimeIsRiskOffWhenBreadthCollapses(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	sma50 := 100.0
+	newRegimeMarket(t, "RGMAUSDT", []float64{100, 95, 90}, &sma50, nil)
+	newRegimeMarket(t, "RGMBUSDT", []float64{100, 97, 94}, &sma50, nil)
+	newRegimeMarketData(t, "RGMBTC1USDT", []float64{100, 100}, nil, nil)
+	service := newRegimeService("RGMBTC1USDT")
+
+	regime, err := service.Evaluate(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.MarketRegimeRiskOff, regime.Regime)
+	assert.Equal(t, 100.0, regime.Breadth)
+	assert.Equal(t, 2, regime.MarketsMeasured)
+	stored, err := service.GetLatest(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, regime.ID, stored.ID)
+}
+
+func TestEvaluateMarketRegimeIsRiskOnWhenMarketsHoldUp(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	sma50 := 100.0
+	newRegimeMarket(t, "RGMCUSDT", []float64{101, 105, 110}, &sma50, []float64{1, 1, 1})
+	newRegimeMarket(t, "RGMDUSDT", []float64{99, 102, 104}, &sma50, []float64{2, 2, 2})
+	newRegimeMarketData(t, "RGMBTC2USDT", []float64{100, 102, 101}, nil, nil)
+	service := newRegimeService("RGMBTC2USDT")
+
+	regime, err := service.Evaluate(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.MarketRegimeRiskOn, regime.Regime)
+	assert.Equal(t, 0.0, regime.Breadth)
+	assert.Equal(t, 1.0, regime.ATRSpike)
+	assert.InDelta(t, 100*(102.0-101.0)/102.0, regime.BTCDrawdown, 0.0001)
+}
+
+func TestEvaluateMarketRegimeIsRiskOffOnATRSpike(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	sma50 := 100.0
+	newRegimeMarket(t, "RGMEUSDT", []float64{110, 110, 110, 110}, &sma50, []float64{1, 1, 1, 5})
+	newRegimeMarketData(t, "RGMBTC3USDT", []float64{100}, nil, nil)
+	service := newRegimeService("RGMBTC3USDT")
+
+	regime, err := service.Evaluate(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.MarketRegimeRiskOff, regime.Regime)
+	assert.Equal(t, 0.0, regime.Breadth)
+	assert.Equal(t, 2.5, regime.ATRSpike)
+}
+
+func TestEvaluateMarketRegimeIsRiskOffOnBenchmarkDrawdown(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	newRegimeMarketData(t, "RGMBTC4USDT", []float64{100, 90, 80}, nil, nil)
+	service := newRegimeService("RGMBTC4USDT")
+
+	regime, err := service.Evaluate(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, constants.MarketRegimeRiskOff, regime.Regime)
+	assert.Equal(t, 20.0, regime.BTCDrawdown)
+	assert.Equal(t, 0, regime.MarketsMeasured)
+}
+
+func TestEvaluateMarketRegimeIgnoresDisabledMarkets(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	sma50 := 100.0
+	newRegimeMarket(t, "RGMFUSDT", []float64{110}, &sma50, nil)
+	newRegimeMarketData(t, "RGMGUSDT", []float64{50}, &sma50, nil)
+	_, err := marketRepository.Create(ctx, &entities.Market{
+		ID:        uuid.New(),
+		Symbol:    "RGMGUSDT",
+		Enabled:   false,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	})
+	assert.NoError(t, err)
+	service := newRegimeService("RGMBTC5USDT")
+
+	regime, err := service.Evaluate(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, regime.MarketsMeasured)
+	assert.Equal(t, 0.0, regime.Breadth)
+}
+
+func TestEvaluateMarketRegimeWithoutDataReturnsError(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	service := newRegimeService("RGMBTC6USDT")
+
+	_, err := service.Evaluate(ctx)
+
+	assert.Equal(t, errors.ErrMarketDataInsufficient, err)
+}
+
+func TestRefreshMarketRegimeReusesRecentRegime(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	newRegimeMarketData(t, "RGMBTC7USDT", []float64{100}, nil, nil)
+	service := newRegimeService("RGMBTC7USDT")
+	service.cfg.Regime.EvaluationInterval = time.Hour
+	evaluated, err := service.Evaluate(ctx)
+	assert.NoError(t, err)
+
+	refreshed, err := service.Refresh(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, evaluated.ID, refreshed.ID)
+	service.cfg.Regime.EvaluationInterval = 0
+	refreshed, err = service.Refresh(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, evaluated.ID, refreshed.ID)
+}
+
+func TestGetRegimeWindowKeepsTheLatestDatapoints(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	closes := make([]float64, regimeWindowSize+5)
+	for i := range closes {
+		closes[i] = float64(i + 1)
+	}
+	newRegimeMarketData(t, "RGMBTC8USDT", closes, nil, nil)
+	service := newRegimeService("RGMBTC8USDT")
+
+	window, err := service.getWindow(ctx, "RGMBTC8USDT", time.Now().UTC().Add(-24*time.Hour))
+
+	assert.NoError(t, err)
+	assert.Len(t, window, regimeWindowSize)
+	assert.Equal(t, 6.0, window[0].Close)
+	assert.Equal(t, float64(len(closes)), window[len(window)-1].Close)
+}
+
+func TestGetMarketRegimeHistoryFiltersByRegime(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	newRegimeMarketData(t, "RGMBTC8USDT", []float64{100, 50}, nil, nil)
+	service := newRegimeService("RGMBTC8USDT")
+	regime, err := service.Evaluate(ctx)
+	assert.NoError(t, err)
+
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"regime":            constants.MarketRegimeRiskOff,
+		"btc_drawdown__gte": 50,
+	}, "created_at", "desc", 0, 10)
+	history, err := service.GetHistory(ctx, filters)
+
+	assert.NoError(t, err)
+	assert.Len(t, *history, 1)
+	assert.Equal(t, regime.ID, (*history)[0].ID)
+}
+
+// --- alertRuleService Tests ---
+
+// newTestAlertRule stores the rule for the user, who gets notified through a
+// webhook channel.
+func newTestAlertRule(
+	t *testing.T,
+	service *DefaultAlertRuleService,
+	userID uuid.UUID,
+	symbol string,
+	field string,
+	condition string,
+	threshold float64,
+	cooldownMinutes int,
+) *entities.AlertRule {
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: userID})
+	channelFactory := &entities.NotificationChannelFactory{}
+	_, err := service.NotificationService.CreateChannel(ctx, channelFactory.NewNotificationChannel(
+		userID,
+		constants.NotificationChannelWebhook,
+		"https://example.com/alerts",
+		"signing-secret",
+		nil,
+	))
+	assert.NoError(t, err)
+	ruleFactory := &entities.AlertRuleFactory{}
+	rule, err := service.Create(ctx, ruleFactory.NewAlertRule(userID, symbol, field, condition, threshold, cooldownMinutes))
+	assert.NoError(t, err)
+	return rule
+}
+
+func newAlertTestMarketData(symbol string, close float64, score *float64) *entities.MarketData {
+	return &entities.MarketData{
+		ID:        uuid.New(),
+		Symbol:    symbol,
+		Timestamp: time.Now().UTC(),
+		Close:     close,
+		Score:     score,
+	}
+}
+
+func TestEvaluateAlertRulesFiresOnCrossing(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	notifier := &recordingNotifier{}
+	service := newTestAlertRuleService(notifier)
+	rule := newTestAlertRule(t, service, uuid.New(), "ALERTAUSDT", constants.AlertFieldScore, constants.AlertConditionAbove, 0.8, 0)
+	scores := []float64{0.7, 0.85, 0.9}
+	fired := make([]int, len(scores))
+
+	// Act
+	for i := range scores {
+		count, err := service.Evaluate(ctx, newAlertTestMarketData("ALERTAUSDT", 100, &scores[i]))
+		assert.NoError(t, err)
+		fired[i] = count
+	}
+
+	// Assert
+	// The first value is the baseline, staying above the threshold isn't a crossing
+	assert.Equal(t, []int{0, 1, 0}, fired)
+	assert.Len(t, notifier.messages, 1)
+	assert.Equal(t, constants.NotificationKindAlert, notifier.messages[0].Kind)
+	assert.Equal(t, "Alert on ALERTAUSDT", notifier.messages[0].Subject)
+	assert.Contains(t, notifier.messages[0].Text, "score crossed above 0.8")
+	assert.Contains(t, notifier.messages[0].Text, "Value: 0.85")
+	stored, err := alertRuleRepository.GetByID(ctx, rule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.9, *stored.LastValue)
+	assert.NotNil(t, stored.LastTriggeredAt)
+}
+
+func TestEvaluateAlertRulesHoldsBackCrossingsInCooldown(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	notifier := &recordingNotifier{}
+	service := newTestAlertRuleService(notifier)
+	newTestAlertRule(t, service, uuid.New(), "ALERTBUSDT", constants.AlertFieldClose, constants.AlertConditionBelow, 100, 60)
+	fired := 0
+
+	// Act
+	for _, close := range []float64{110, 90, 110, 95} {
+		count, err := service.Evaluate(ctx, newAlertTestMarketData("ALERTBUSDT", close, nil))
+		assert.NoError(t, err)
+		fired += count
+	}
+
+	// Assert
+	assert.Equal(t, 1, fired)
+	assert.Len(t, notifier.messages, 1)
+}
+
+func TestEvaluateAlertRulesSkipsMissingIndicators(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	service := newTestAlertRuleService(&recordingNotifier{})
+	rule := newTestAlertRule(t, service, uuid.New(), "ALERTCUSDT", constants.AlertFieldRSI6, constants.AlertConditionBelow, 20, 0)
+
+	// Act
+	fired, err := service.Evaluate(ctx, newAlertTestMarketData("ALERTCUSDT", 100, nil))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fired)
+	stored, err := alertRuleRepository.GetByID(ctx, rule.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored.LastValue)
+}
+
+func TestUpdateAlertRuleResetsLastValueOnNewField(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: userID})
+	service := newTestAlertRuleService(&recordingNotifier{})
+	rule := newTestAlertRule(t, service, userID, "ALERTDUSDT", constants.AlertFieldClose, constants.AlertConditionAbove, 100, 0)
+	_, err := service.Evaluate(ctx, newAlertTestMarketData("ALERTDUSDT", 90, nil))
+	assert.NoError(t, err)
+
+	// Act
+	rule.Threshold = 95
+	kept, keptErr := service.Update(ctx, rule)
+	rule.Field = constants.AlertFieldVolume
+	reset, resetErr := service.Update(ctx, rule)
+
+	// Assert
+	assert.NoError(t, keptErr)
+	assert.Equal(t, 90.0, *kept.LastValue)
+	assert.NoError(t, resetErr)
+	assert.Nil(t, reset.LastValue)
+}
+
+func TestCreateAlertRuleReturnsErrorIfInvalid(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: userID})
+	service := newTestAlertRuleService(&recordingNotifier{})
+	ruleFactory := &entities.AlertRuleFactory{}
+
+	// Act
+	_, fieldErr := service.Create(ctx, ruleFactory.NewAlertRule(userID, "ETHUSDT", "sentiment", constants.AlertConditionAbove, 1, 0))
+	_, conditionErr := service.Create(ctx, ruleFactory.NewAlertRule(userID, "ETHUSDT", constants.AlertFieldScore, "equals", 1, 0))
+	_, cooldownErr := service.Create(ctx, ruleFactory.NewAlertRule(userID, "ETHUSDT", constants.AlertFieldScore, constants.AlertConditionAbove, 1, -5))
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidAlertField, fieldErr)
+	assert.Equal(t, errors.ErrInvalidAlertCondition, conditionErr)
+	assert.Equal(t, errors.ErrInvalidAlertCooldown, cooldownErr)
+}
+
+func TestCreateAlertRuleReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New()})
+	service := newTestAlertRuleService(&recordingNotifier{})
+	ruleFactory := &entities.AlertRuleFactory{}
+
+	// Act
+	_, err := service.Create(ctx, ruleFactory.NewAlertRule(uuid.New(), "ETHUSDT", constants.AlertFieldScore, constants.AlertConditionAbove, 1, 0))
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
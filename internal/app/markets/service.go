@@ -34,3 +34,19 @@ type MarketDataService interface {
 	CalculateTrendScore(adx, adxPositive, adxNegative float64) float64
 	CalculateVolatilityScore(atr, close float64) float64
 }
+
+type AlertRuleService interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.AlertRule, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.AlertRules, error)
+	Create(ctx echo.Context, rule *entities.AlertRule) (*entities.AlertRule, error)
+	Update(ctx echo.Context, rule *entities.AlertRule) (*entities.AlertRule, error)
+	Delete(ctx echo.Context, id uuid.UUID) error
+	Evaluate(ctx echo.Context, marketData *entities.MarketData) (int, error)
+}
+
+type MarketRegimeService interface {
+	Evaluate(ctx echo.Context) (*entities.MarketRegime, error)
+	Refresh(ctx echo.Context) (*entities.MarketRegime, error)
+	GetLatest(ctx echo.Context) (*entities.MarketRegime, error)
+	GetHistory(ctx echo.Context, filters filtering.ComplexFilters) (*entities.MarketRegimes, error)
+}
@@ -1,20 +1,30 @@
 package markets
 
 import (
+	"context"
 	"os"
+	"sync"
 	"testing"
 
+	"github.com/sergiovirahonda/endurance-api/internal/app/notifications"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/market"
+	notificationRepository "github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/notification"
 	"gorm.io/gorm"
 )
 
 var (
 	database             *gorm.DB
 	MarketDataRepository market.MarketDataRepository
+	marketRepository     market.MarketRepository
+	regimeRepository     market.MarketRegimeRepository
+	alertRuleRepository  market.AlertRuleRepository
 	marketDataService    MarketDataService
 	uacService           uacs.UacService
 )
@@ -27,13 +37,57 @@ func TestMain(m *testing.M) {
 	logger.Info("Test DB connection established.")
 	models := []interface{}{
 		&dtos.MarketData{},
+		&dtos.Market{},
+		&dtos.MarketRegime{},
+		&dtos.AlertRule{},
+		&dtos.NotificationChannel{},
+		&dtos.NotificationDelivery{},
+		&dtos.NotificationPreference{},
 	}
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
 	logger.Info("Migrations completed. Running tests...")
 	MarketDataRepository = market.NewDefaultMarketDataRepository(database)
+	marketRepository = market.NewDefaultMarketRepository(database)
+	regimeRepository = market.NewDefaultMarketRegimeRepository(database)
+	alertRuleRepository = market.NewDefaultAlertRuleRepository(database)
 	uacService = uacs.NewDefaultUacService()
 	marketDataService = NewDefaultMarketDataService(MarketDataRepository, uacService)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
+
+// newTestAlertRuleService returns a service delivering alerts through the
+// webhook channels of the users to the notifier.
+func newTestAlertRuleService(notifier notifications.Notifier) *DefaultAlertRuleService {
+	notificationService := notifications.NewDefaultNotificationService(
+		nil,
+		notificationRepository.NewDefaultTelegramChatRepository(database),
+		notificationRepository.NewDefaultPairingCodeRepository(database),
+		notificationRepository.NewDefaultNotificationChannelRepository(database),
+		notificationRepository.NewDefaultNotificationDeliveryRepository(database),
+		notificationRepository.NewDefaultNotificationPreferenceRepository(database),
+		notificationRepository.NewDefaultDigestScheduleRepository(database),
+		uacService,
+		nil,
+		map[string]notifications.Notifier{constants.NotificationChannelWebhook: notifier},
+	)
+	return NewDefaultAlertRuleService(alertRuleRepository, notificationService, uacService)
+}
+
+// recordingNotifier keeps the messages it was asked to deliver.
+type recordingNotifier struct {
+	mutex    sync.Mutex
+	messages []valueobjects.NotificationMessage
+}
+
+func (n *recordingNotifier) Notify(
+	ctx context.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.messages = append(n.messages, *message)
+	return nil
+}
@@ -2,6 +2,7 @@ package messaging
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/nats-io/nats.go"
 	"github.com/sergiovirahonda/endurance-api/internal/app/markets"
+	"github.com/sergiovirahonda/endurance-api/internal/app/trades"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
@@ -20,24 +22,32 @@ type DefaultMessagingService struct {
 	eventsPubSub            pubsub.EventsPubSub
 	uacService              uacs.UacService
 	marketDataEventRegistry markets.MarketDataEventRegistry
+	tradingEventRegistry    trades.TradingEventRegistry
 }
 
 func NewDefaultMessagingService(
 	eventsPubSub pubsub.EventsPubSub,
 	uacService uacs.UacService,
 	marketDataEventRegistry markets.MarketDataEventRegistry,
+	tradingEventRegistry trades.TradingEventRegistry,
 ) *DefaultMessagingService {
 	return &DefaultMessagingService{
 		eventsPubSub:            eventsPubSub,
 		uacService:              uacService,
 		marketDataEventRegistry: marketDataEventRegistry,
+		tradingEventRegistry:    tradingEventRegistry,
 	}
 }
 
 func (ms *DefaultMessagingService) EventsLoop() {
 	logger := config.GetLogger()
 	conf := config.GetConfig()
-	ctx := echo.New().NewContext(nil, nil)
+	// Trading handlers reach the exchange, whose calls run under a request
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	if err != nil {
+		logger.Fatalf("Error building events context: %s", err)
+	}
+	ctx := echo.New().NewContext(request, nil)
 	logger.Info("Starting events loop...")
 	ctx.Set("logger", logger)
 	sub, err := streaming.NewSubscriber(
@@ -101,6 +111,10 @@ func (ms DefaultMessagingService) HandleDomainEvent(ctx echo.Context, msg []byte
 	if err != nil {
 		return err
 	}
+	err = ms.tradingEventRegistry.HandleEvent(ctx, msg)
+	if err != nil {
+		return err
+	}
 	// NOTE: Handler other events below
 	return nil
 }
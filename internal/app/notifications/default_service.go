@@ -1,60 +1,429 @@
 package notifications
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	exchanges "github.com/sergiovirahonda/endurance-api/internal/app/exchange"
 	keys "github.com/sergiovirahonda/endurance-api/internal/app/key"
+	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	notificationRepository "github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/notification"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gopkg.in/telebot.v3"
 )
 
+// Inline buttons of trade proposal messages, their data is the proposal ID
+var (
+	ApproveTradeButton = telebot.Btn{Unique: "approve_trade", Text: "✅ Approve"}
+	RejectTradeButton  = telebot.Btn{Unique: "reject_trade", Text: "❌ Reject"}
+)
+
 // Structs
 
+// DefaultNotificationService delivers notifications through the channels
+// each user picked, retrying failed attempts in the background and logging
+// every delivery.
+// Users without channels get them through Telegram. Notifications are
+// rendered in the language of the user, who can opt out of events and hold
+// them during quiet hours.
 type DefaultNotificationService struct {
-	KeyService     keys.KeyService
-	TelegramClient *notification.TelegramClient
+	CredentialResolver     keys.CredentialResolver
+	TelegramChatRepository notificationRepository.TelegramChatRepository
+	PairingCodeRepository  notificationRepository.PairingCodeRepository
+	ChannelRepository      notificationRepository.NotificationChannelRepository
+	DeliveryRepository     notificationRepository.NotificationDeliveryRepository
+	PreferenceRepository   notificationRepository.NotificationPreferenceRepository
+	DigestRepository       notificationRepository.DigestScheduleRepository
+	UacService             uacs.UacService
+	TelegramClient         *notification.TelegramClient
+	Notifiers              map[string]Notifier
+	Renderer               *NotificationRenderer
+	MaxAttempts            int
+	RetryBackoff           time.Duration
+	retries                sync.WaitGroup
+}
+
+// deliveryRetry is a failed delivery queued to be attempted again, with
+// what it is delivered through resolved already.
+type deliveryRetry struct {
+	ctx      echo.Context
+	delivery *entities.NotificationDelivery
+	notifier Notifier
+	target   *entities.NotificationChannel
+	message  *valueobjects.NotificationMessage
 }
 
 // Factories
 
 func NewDefaultNotificationService(
-	keyService keys.KeyService,
+	credentialResolver keys.CredentialResolver,
+	telegramChatRepository notificationRepository.TelegramChatRepository,
+	pairingCodeRepository notificationRepository.PairingCodeRepository,
+	channelRepository notificationRepository.NotificationChannelRepository,
+	deliveryRepository notificationRepository.NotificationDeliveryRepository,
+	preferenceRepository notificationRepository.NotificationPreferenceRepository,
+	digestRepository notificationRepository.DigestScheduleRepository,
+	uacService uacs.UacService,
 	telegramClient *notification.TelegramClient,
+	notifiers map[string]Notifier,
 ) *DefaultNotificationService {
+	conf := config.GetConfig()
 	return &DefaultNotificationService{
-		KeyService:     keyService,
-		TelegramClient: telegramClient,
+		CredentialResolver:     credentialResolver,
+		TelegramChatRepository: telegramChatRepository,
+		PairingCodeRepository:  pairingCodeRepository,
+		ChannelRepository:      channelRepository,
+		DeliveryRepository:     deliveryRepository,
+		PreferenceRepository:   preferenceRepository,
+		DigestRepository:       digestRepository,
+		UacService:             uacService,
+		TelegramClient:         telegramClient,
+		Notifiers:              notifiers,
+		Renderer:               NewNotificationRenderer(exchanges.GetSymbolFiltersCache()),
+		MaxAttempts:            conf.Notifications.MaxAttempts,
+		RetryBackoff:           conf.Notifications.RetryBackoff,
+	}
+}
+
+// NewDefaultNotifiers returns a notifier for every channel, Telegram ones
+// going through the given client.
+func NewDefaultNotifiers(
+	cfg *config.Config,
+	telegramClient *notification.TelegramClient,
+) map[string]Notifier {
+	notifiers := map[string]Notifier{
+		constants.NotificationChannelEmail:   notification.NewEmailClient(cfg),
+		constants.NotificationChannelSlack:   notification.NewSlackClient(cfg),
+		constants.NotificationChannelWebhook: notification.NewWebhookClient(cfg),
+	}
+	if telegramClient != nil {
+		notifiers[constants.NotificationChannelTelegram] = telegramClient
 	}
+	return notifiers
 }
 
 // Receivers
 
-func (s *DefaultNotificationService) SendMessage(
+// Notify renders the event for every channel of the user in context that
+// receives its kind, and delivers it. Events the user opted out of are
+// dropped, and during quiet hours only urgent ones go out, the rest being
+// logged as suppressed. Every channel is tried once, failed attempts being
+// retried in the background, and an error is returned if any of them failed
+// for good; the delivery log tells which.
+func (s *DefaultNotificationService) Notify(
 	ctx echo.Context,
-	message string,
+	event *valueobjects.NotificationEvent,
 ) error {
-	keys, err := s.KeyService.GetTelegramKeys(ctx)
+	failed, total, err := s.notify(ctx, event)
 	if err != nil {
 		return err
 	}
-	s.TelegramClient.Bot.Token = keys.Key
-	secret, err := strconv.ParseInt(keys.Secret, 10, 64)
+	if failed > 0 {
+		return fmt.Errorf("%w through %d of %d channels", errors.ErrNotificationNotDelivered, failed, total)
+	}
+	return nil
+}
+
+// IsTelegramRecipient tells whether the chat is the one the user receives
+// notifications in.
+func (s *DefaultNotificationService) IsTelegramRecipient(
+	ctx echo.Context,
+	userID uuid.UUID,
+	chatID int64,
+) (bool, error) {
+	credentials, err := s.CredentialResolver.ResolveTelegramCredentials(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return s.telegramRecipient(ctx, userID, credentials) == chatID, nil
+}
+
+// Telegram pairing
+
+// CreatePairingCode issues a one-time code the user sends to the bot to pair
+// the chat. Only the code digest is stored, so the plain code is returned
+// just once.
+func (s *DefaultNotificationService) CreatePairingCode(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (string, *entities.PairingCode, error) {
+	err := s.UacService.IsResourceOwner(ctx, userID)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	msg, err := s.TelegramClient.Bot.Send(
-		&telebot.User{ID: secret},
-		message,
+	code, err := lib.RandomCode(constants.TelegramPairingCodeLength, lib.CodeAlphabet)
+	if err != nil {
+		return "", nil, err
+	}
+	hasher := lib.Hasher{}
+	factory := &entities.PairingCodeFactory{}
+	pairingCode := factory.NewPairingCode(
+		userID,
+		hasher.DigestString(code),
+		config.GetConfig().Telegram.PairingCodeTTL,
 	)
-	logger := config.GetLogger()
-	logger.Infof("Telegram message sent: %+v", msg.Text)
+	pairingCode, err = s.PairingCodeRepository.Create(ctx, pairingCode)
+	if err != nil {
+		return "", nil, err
+	}
+	return code, pairingCode, nil
+}
+
+// PairChat links the chat to the owner of the code. It runs on behalf of the
+// bot, so the code is the only proof of ownership: unknown, used and expired
+// codes are rejected alike. Previous links of the user and of the chat are
+// replaced.
+func (s *DefaultNotificationService) PairChat(
+	ctx echo.Context,
+	code string,
+	chatID int64,
+) (*entities.TelegramChat, error) {
+	hasher := lib.Hasher{}
+	digest := hasher.DigestString(strings.ToUpper(strings.TrimSpace(code)))
+	pairingCode, err := s.PairingCodeRepository.GetByCodeDigest(ctx, digest)
+	if err != nil || !pairingCode.IsRedeemable(time.Now().UTC()) {
+		return nil, errors.ErrInvalidPairingCode
+	}
+	pairingCode.Redeem()
+	_, err = s.PairingCodeRepository.Update(ctx, pairingCode)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.TelegramChatRepository.GetByChatID(ctx, chatID)
+	if err == nil {
+		err = s.TelegramChatRepository.Delete(ctx, previous.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	previous, err = s.TelegramChatRepository.GetByUserID(ctx, pairingCode.UserID)
+	if err == nil {
+		err = s.TelegramChatRepository.Delete(ctx, previous.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	factory := &entities.TelegramChatFactory{}
+	chat := factory.NewTelegramChat(pairingCode.UserID, chatID)
+	err = chat.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return s.TelegramChatRepository.Create(ctx, chat)
+}
+
+// UnpairChat removes the link of the user chat, if any.
+func (s *DefaultNotificationService) UnpairChat(
+	ctx echo.Context,
+	userID uuid.UUID,
+) error {
+	err := s.UacService.IsResourceOwner(ctx, userID)
 	if err != nil {
 		return err
 	}
-	return nil
+	chat, err := s.TelegramChatRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return errors.ErrTelegramChatNotPaired
+	}
+	return s.TelegramChatRepository.Delete(ctx, chat.ID)
+}
+
+// GetChatUserID returns the user the chat is paired to.
+func (s *DefaultNotificationService) GetChatUserID(
+	ctx echo.Context,
+	chatID int64,
+) (uuid.UUID, error) {
+	chat, err := s.TelegramChatRepository.GetByChatID(ctx, chatID)
+	if err != nil {
+		return uuid.Nil, errors.ErrTelegramChatNotPaired
+	}
+	return chat.UserID, nil
+}
+
+// Notification channels
+
+func (s *DefaultNotificationService) GetChannels(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.NotificationChannels, error) {
+	err := s.UacService.IsResourceOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ChannelRepository.GetByUserID(ctx, userID)
+}
+
+func (s *DefaultNotificationService) CreateChannel(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+) (*entities.NotificationChannel, error) {
+	err := channel.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = s.UacService.IsResourceOwner(ctx, channel.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ChannelRepository.Create(ctx, channel)
+}
+
+func (s *DefaultNotificationService) UpdateChannel(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+) (*entities.NotificationChannel, error) {
+	err := channel.Validate()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.ChannelRepository.GetByID(ctx, channel.ID)
+	if err != nil {
+		return nil, errors.ErrNotificationChannelNotFound
+	}
+	err = s.UacService.IsResourceOwner(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	channel.UserID = stored.UserID
+	channel.CreatedAt = stored.CreatedAt
+	channel.UpdatedAt = time.Now().UTC()
+	return s.ChannelRepository.Update(ctx, channel)
+}
+
+func (s *DefaultNotificationService) DeleteChannel(
+	ctx echo.Context,
+	id uuid.UUID,
+) error {
+	channel, err := s.ChannelRepository.GetByID(ctx, id)
+	if err != nil {
+		return errors.ErrNotificationChannelNotFound
+	}
+	err = s.UacService.IsResourceOwner(ctx, channel.UserID)
+	if err != nil {
+		return err
+	}
+	return s.ChannelRepository.Delete(ctx, id)
+}
+
+// GetDeliveries returns the delivery log of the user in context.
+func (s *DefaultNotificationService) GetDeliveries(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.NotificationDeliveries, error) {
+	filters.SetMetaParameters()
+	err := filters.NarrowUserFilters("user_id")
+	if err != nil {
+		return nil, err
+	}
+	return s.DeliveryRepository.GetAll(ctx, filters)
+}
+
+// Notification preferences
+
+// GetPreference returns the notification preference of the user, the
+// default one if the user never set it.
+func (s *DefaultNotificationService) GetPreference(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.NotificationPreference, error) {
+	err := s.UacService.IsResourceOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	preference, err := s.PreferenceRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		factory := &entities.NotificationPreferenceFactory{}
+		return factory.NewNotificationPreference(userID), nil
+	}
+	return preference, nil
+}
+
+// UpdatePreference stores the notification preference of the user, creating
+// it on the first update.
+func (s *DefaultNotificationService) UpdatePreference(
+	ctx echo.Context,
+	preference *entities.NotificationPreference,
+) (*entities.NotificationPreference, error) {
+	err := preference.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = s.UacService.IsResourceOwner(ctx, preference.UserID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.PreferenceRepository.GetByUserID(ctx, preference.UserID)
+	if err != nil {
+		return s.PreferenceRepository.Create(ctx, preference)
+	}
+	preference.ID = stored.ID
+	preference.CreatedAt = stored.CreatedAt
+	preference.UpdatedAt = time.Now().UTC()
+	return s.PreferenceRepository.Update(ctx, preference)
+}
+
+// Digest schedules
+
+func (s *DefaultNotificationService) GetDigestSchedule(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.DigestSchedule, error) {
+	err := s.UacService.IsResourceOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.DigestRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.ErrDigestScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// UpdateDigestSchedule stores when the user wants the digest, creating the
+// schedule on the first update. What the last digest reported is kept.
+func (s *DefaultNotificationService) UpdateDigestSchedule(
+	ctx echo.Context,
+	schedule *entities.DigestSchedule,
+) (*entities.DigestSchedule, error) {
+	err := schedule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = s.UacService.IsResourceOwner(ctx, schedule.UserID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.DigestRepository.GetByUserID(ctx, schedule.UserID)
+	if err != nil {
+		return s.DigestRepository.Create(ctx, schedule)
+	}
+	schedule.ID = stored.ID
+	schedule.LastSentAt = stored.LastSentAt
+	schedule.LastValue = stored.LastValue
+	schedule.CreatedAt = stored.CreatedAt
+	schedule.UpdatedAt = time.Now().UTC()
+	return s.DigestRepository.Update(ctx, schedule)
+}
+
+// GetEnabledDigestSchedules returns the schedules of every user, for the
+// digest job to run on behalf of the system.
+func (s *DefaultNotificationService) GetEnabledDigestSchedules(
+	ctx echo.Context,
+) (*entities.DigestSchedules, error) {
+	return s.DigestRepository.GetEnabled(ctx)
 }
 
 // Integrations
@@ -67,19 +436,16 @@ func (s *DefaultNotificationService) SendTradeNotification(
 	profit float64,
 	profitPercentage float64,
 ) error {
-	message := fmt.Sprintf(
-		"❗ Trade operation executed.\n\n"+
-			"💰 %s >> %s\n"+
-			"- Entry price: %f\n"+
-			"- Profit: %f USDT\n"+
-			"- Profit percentage: %f\n",
-		originSymbol,
-		newSymbol,
-		entryPrice,
-		profit,
-		profitPercentage,
-	)
-	return s.SendMessage(ctx, message)
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindTrade,
+		Data: valueobjects.TradeExecutedNotification{
+			FromSymbol:       originSymbol,
+			ToSymbol:         newSymbol,
+			EntryPrice:       entryPrice,
+			Profit:           profit,
+			ProfitPercentage: profitPercentage,
+		},
+	})
 }
 
 func (s *DefaultNotificationService) SendStopLossNotification(
@@ -89,16 +455,370 @@ func (s *DefaultNotificationService) SendStopLossNotification(
 	loss float64,
 	lossPercentage float64,
 ) error {
-	message := fmt.Sprintf(
-		"❗ Stop loss triggered.\n\n"+
-			"💰 %s >> USDT\n"+
-			"- Stop loss price: %f\n"+
-			"- Loss: %f USDT\n"+
-			"- Loss percentage: %f\n",
-		originSymbol,
-		stopLossPrice,
-		loss,
-		lossPercentage,
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindStopLoss,
+		Data: valueobjects.StopLossNotification{
+			Symbol:         originSymbol,
+			StopLossPrice:  stopLossPrice,
+			Loss:           loss,
+			LossPercentage: lossPercentage,
+		},
+	})
+}
+
+func (s *DefaultNotificationService) SendTakeProfitNotification(
+	ctx echo.Context,
+	originSymbol string,
+	targetPrice float64,
+	quantity float64,
+	profit float64,
+	profitPercentage float64,
+) error {
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindTakeProfit,
+		Data: valueobjects.TakeProfitNotification{
+			Symbol:           originSymbol,
+			TargetPrice:      targetPrice,
+			Quantity:         quantity,
+			Profit:           profit,
+			ProfitPercentage: profitPercentage,
+		},
+	})
+}
+
+func (s *DefaultNotificationService) SendPositionOpenedNotification(
+	ctx echo.Context,
+	targetSymbol string,
+	entryPrice float64,
+	quantity float64,
+	amount float64,
+) error {
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindPositionOpened,
+		Data: valueobjects.PositionOpenedNotification{
+			Symbol:     targetSymbol,
+			EntryPrice: entryPrice,
+			Quantity:   quantity,
+			Amount:     amount,
+		},
+	})
+}
+
+func (s *DefaultNotificationService) SendKillSwitchNotification(
+	ctx echo.Context,
+	limit string,
+	symbol string,
+	value float64,
+	threshold float64,
+) error {
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindKillSwitch,
+		Data: valueobjects.RiskBreach{
+			Limit:     limit,
+			Symbol:    symbol,
+			Value:     value,
+			Threshold: threshold,
+		},
+	})
+}
+
+// SendTradeProposalNotification asks the user to approve or reject the
+// proposal with inline buttons.
+func (s *DefaultNotificationService) SendTradeProposalNotification(
+	ctx echo.Context,
+	proposal *entities.TradeProposal,
+) error {
+	markup := &telebot.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(
+			markup.Data(ApproveTradeButton.Text, ApproveTradeButton.Unique, proposal.ID.String()),
+			markup.Data(RejectTradeButton.Text, RejectTradeButton.Unique, proposal.ID.String()),
+		),
 	)
-	return s.SendMessage(ctx, message)
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindTradeProposal,
+		Data: valueobjects.TradeProposalNotification{
+			FromSymbol:    proposal.FromSymbol,
+			ToSymbol:      proposal.ToSymbol,
+			ProposedPrice: proposal.ProposedPrice,
+			ExpiresAt:     proposal.ExpiresAt,
+		},
+		Options: []interface{}{markup},
+	})
+}
+
+func (s *DefaultNotificationService) SendAlertNotification(
+	ctx echo.Context,
+	rule *entities.AlertRule,
+	marketData *entities.MarketData,
+	value float64,
+) error {
+	return s.Notify(ctx, &valueobjects.NotificationEvent{
+		Kind: constants.NotificationKindAlert,
+		Data: valueobjects.AlertNotification{
+			Symbol:    rule.Symbol,
+			Field:     rule.Field,
+			Condition: rule.Condition,
+			Threshold: rule.Threshold,
+			Value:     value,
+			Timestamp: marketData.Timestamp,
+		},
+	})
+}
+
+// SendDigestNotification delivers the digest of the schedule, and records
+// it sent with the portfolio value it reported so the next digest measures
+// the change from there. The schedule is recorded even if some channel
+// failed, which the delivery log tells.
+func (s *DefaultNotificationService) SendDigestNotification(
+	ctx echo.Context,
+	schedule *entities.DigestSchedule,
+	digest *valueobjects.DigestNotification,
+) error {
+	err := s.UacService.IsResourceOwner(ctx, schedule.UserID)
+	if err != nil {
+		return err
+	}
+	failed, total, err := s.notify(ctx, &valueobjects.NotificationEvent{
+		Kind: schedule.Kind(),
+		Data: *digest,
+	})
+	if err != nil {
+		return err
+	}
+	schedule.MarkSent(digest.To, digest.PortfolioValue)
+	_, err = s.DigestRepository.Update(ctx, schedule)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w through %d of %d channels", errors.ErrNotificationNotDelivered, failed, total)
+	}
+	return nil
+}
+
+// Helpers
+
+// telegramRecipient returns the chat paired by the user, falling back to the
+// chat ID of the telegram credentials.
+func (s *DefaultNotificationService) telegramRecipient(
+	ctx echo.Context,
+	userID uuid.UUID,
+	credentials *valueobjects.TelegramCredentials,
+) int64 {
+	if userID != uuid.Nil {
+		chat, err := s.TelegramChatRepository.GetByUserID(ctx, userID)
+		if err == nil {
+			return chat.ChatID
+		}
+	}
+	return credentials.ChatID
+}
+
+// notify delivers the event as Notify does, returning how many of the
+// channels failed.
+func (s *DefaultNotificationService) notify(
+	ctx echo.Context,
+	event *valueobjects.NotificationEvent,
+) (int, int, error) {
+	preference, err := s.recipientPreference(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !preference.Receives(event.Kind) {
+		return 0, 0, nil
+	}
+	quiet := preference.InQuietHours(time.Now()) &&
+		!lib.SliceContains(constants.QuietHoursBypassKinds, event.Kind)
+	channels, err := s.recipientChannels(ctx, event.Kind)
+	if err != nil {
+		return 0, 0, err
+	}
+	failed := 0
+	for _, channel := range channels {
+		message, err := s.Renderer.Render(event, channel.Type, preference)
+		if err != nil {
+			return 0, 0, err
+		}
+		if quiet {
+			s.suppress(ctx, &channel, message)
+			continue
+		}
+		delivery := s.deliver(ctx, &channel, message)
+		if delivery.IsFailed() {
+			failed++
+		}
+	}
+	return failed, len(channels), nil
+}
+
+// recipientPreference returns the notification preference of the user in
+// context, the default one for a context without user.
+func (s *DefaultNotificationService) recipientPreference(
+	ctx echo.Context,
+) (*entities.NotificationPreference, error) {
+	factory := &entities.NotificationPreferenceFactory{}
+	user, ok := ctx.Get("user").(*entities.User)
+	if !ok || user == nil {
+		return factory.NewNotificationPreference(uuid.Nil), nil
+	}
+	preference, err := s.PreferenceRepository.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return factory.NewNotificationPreference(user.ID), nil
+	}
+	return preference, nil
+}
+
+// recipientChannels returns the channels of the user in context that receive
+// the kind. Users without channels, or a context without user, fall back to
+// Telegram.
+func (s *DefaultNotificationService) recipientChannels(
+	ctx echo.Context,
+	kind string,
+) (entities.NotificationChannels, error) {
+	fallback := entities.NotificationChannels{{
+		Type:    constants.NotificationChannelTelegram,
+		Enabled: true,
+	}}
+	user, ok := ctx.Get("user").(*entities.User)
+	if !ok || user == nil {
+		return fallback, nil
+	}
+	fallback[0].UserID = user.ID
+	channels, err := s.ChannelRepository.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(*channels) == 0 {
+		return fallback, nil
+	}
+	recipients := make(entities.NotificationChannels, 0, len(*channels))
+	for _, channel := range *channels {
+		if channel.Receives(kind) {
+			recipients = append(recipients, channel)
+		}
+	}
+	return recipients, nil
+}
+
+// deliver sends the message through the channel and logs the outcome. A
+// failed attempt is queued to be retried in the background, so callers never
+// wait out the backoff.
+func (s *DefaultNotificationService) deliver(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) *entities.NotificationDelivery {
+	logger := config.GetLoggerFromContext(ctx)
+	attempts := 0
+	notifier, ok := s.Notifiers[channel.Type]
+	target, err := s.resolveChannel(ctx, channel)
+	if err == nil && (!ok || notifier == nil) {
+		err = errors.ErrNotifierNotConfigured
+	}
+	if err == nil {
+		attempts++
+		err = notifier.Notify(requestContext(ctx), target, message)
+	}
+	factory := &entities.NotificationDeliveryFactory{}
+	delivery := factory.NewNotificationDelivery(channel, message.Kind, message.Subject, attempts, err)
+	// Only attempts that reached the notifier are worth retrying
+	retry := err != nil && attempts > 0 && attempts < s.MaxAttempts
+	if retry {
+		delivery.Retry()
+		logger.Warnf("Retrying %s notification through %s: %s", message.Kind, channel.Type, err)
+	} else if err != nil {
+		logger.Errorf("Error delivering %s notification through %s: %s", message.Kind, channel.Type, err)
+	}
+	_, logErr := s.DeliveryRepository.Create(ctx, delivery)
+	if logErr != nil {
+		logger.Errorf("Error logging %s notification delivery: %s", channel.Type, logErr)
+	}
+	if retry {
+		// The request is over by the time retries run
+		retryCtx := echo.New().NewContext(nil, nil)
+		retryCtx.Set("logger", logger)
+		queued := *delivery
+		s.queueRetry(&deliveryRetry{
+			ctx:      retryCtx,
+			delivery: &queued,
+			notifier: notifier,
+			target:   target,
+			message:  message,
+		})
+	}
+	return delivery
+}
+
+// queueRetry attempts the delivery again once its backoff, growing with
+// every attempt, has passed.
+func (s *DefaultNotificationService) queueRetry(retry *deliveryRetry) {
+	s.retries.Add(1)
+	time.AfterFunc(s.RetryBackoff*time.Duration(retry.delivery.Attempts), func() {
+		defer s.retries.Done()
+		s.retry(retry)
+	})
+}
+
+// retry makes one more attempt at a queued delivery, updating its log and
+// queueing it again while it has attempts left.
+func (s *DefaultNotificationService) retry(retry *deliveryRetry) {
+	logger := config.GetLoggerFromContext(retry.ctx)
+	delivery := retry.delivery
+	err := retry.notifier.Notify(context.Background(), retry.target, retry.message)
+	delivery.RecordAttempt(err)
+	again := err != nil && delivery.Attempts < s.MaxAttempts
+	if again {
+		delivery.Retry()
+		logger.Warnf("Retrying %s notification through %s: %s", delivery.Kind, delivery.ChannelType, err)
+	} else if err != nil {
+		logger.Errorf("Error delivering %s notification through %s: %s", delivery.Kind, delivery.ChannelType, err)
+	}
+	_, logErr := s.DeliveryRepository.Update(retry.ctx, delivery)
+	if logErr != nil {
+		logger.Errorf("Error logging %s notification delivery: %s", delivery.ChannelType, logErr)
+	}
+	if again {
+		s.queueRetry(retry)
+	}
+}
+
+// suppress logs the message as held back by the quiet hours of the user.
+func (s *DefaultNotificationService) suppress(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) {
+	factory := &entities.NotificationDeliveryFactory{}
+	delivery := factory.NewSuppressedNotificationDelivery(channel, message.Kind, message.Subject)
+	_, err := s.DeliveryRepository.Create(ctx, delivery)
+	if err != nil {
+		config.GetLoggerFromContext(ctx).Errorf("Error logging %s notification delivery: %s", channel.Type, err)
+	}
+}
+
+// resolveChannel fills in what Telegram channels are delivered with: the
+// chat of the channel owner and the bot token from their telegram keys.
+func (s *DefaultNotificationService) resolveChannel(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+) (*entities.NotificationChannel, error) {
+	if channel.Type != constants.NotificationChannelTelegram {
+		return channel, nil
+	}
+	credentials, err := s.CredentialResolver.ResolveTelegramCredentials(ctx, channel.UserID)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *channel
+	resolved.Target = strconv.FormatInt(s.telegramRecipient(ctx, channel.UserID, credentials), 10)
+	resolved.Secret = credentials.BotToken
+	return &resolved, nil
+}
+
+func requestContext(ctx echo.Context) context.Context {
+	if ctx.Request() == nil {
+		return context.Background()
+	}
+	return ctx.Request().Context()
 }
@@ -1 +1,717 @@
 package notifications
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/stretchr/testify/assert"
+)
+
+func newNotificationTestContext(userID uuid.UUID) echo.Context {
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: userID})
+	return ctx
+}
+
+func newTestChannel(
+	t *testing.T,
+	ctx echo.Context,
+	service *DefaultNotificationService,
+	userID uuid.UUID,
+	channelType string,
+	target string,
+	kinds []string,
+) *entities.NotificationChannel {
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(userID, channelType, target, "signing-secret", kinds)
+	_, err := service.CreateChannel(ctx, channel)
+	assert.NoError(t, err)
+	return channel
+}
+
+func getTestDeliveries(t *testing.T, ctx echo.Context, service *DefaultNotificationService) entities.NotificationDeliveries {
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{}, "created_at", "desc", 0, 100)
+	deliveries, err := service.GetDeliveries(ctx, filters)
+	assert.NoError(t, err)
+	return *deliveries
+}
+
+// Notify tests
+
+func TestNotifyDeliversThroughEmailChannel(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newSMTPStandIn(t)
+	cfg := *config.GetConfig()
+	cfg.Notifications.SMTPHost = standIn.Host()
+	cfg.Notifications.SMTPPort = standIn.Port()
+	cfg.Notifications.SMTPFrom = "bot@endurance.local"
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{
+		constants.NotificationChannelEmail: notification.NewEmailClient(&cfg),
+	})
+	channel := newTestChannel(t, ctx, service, userID, constants.NotificationChannelEmail, "user@example.com", nil)
+
+	// Act
+	err := service.SendStopLossNotification(ctx, "BTCUSDT", 55000, 50, 5)
+
+	// Assert
+	assert.NoError(t, err)
+	mails := standIn.Mails()
+	assert.Len(t, mails, 1)
+	assert.Equal(t, "bot@endurance.local", mails[0].From)
+	assert.Equal(t, []string{"user@example.com"}, mails[0].To)
+	assert.Contains(t, mails[0].Data, "Subject: Stop loss triggered")
+	assert.Contains(t, mails[0].Data, "Content-Type: text/html")
+	assert.Contains(t, mails[0].Data, "<b>Stop loss triggered</b>")
+	assert.Contains(t, mails[0].Data, "BTCUSDT &gt;&gt; USDT")
+	assert.Contains(t, mails[0].Data, "Stop loss price: 55,000.00")
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, channel.ID, deliveries[0].ChannelID)
+	assert.Equal(t, constants.NotificationKindStopLoss, deliveries[0].Kind)
+	assert.True(t, deliveries[0].IsDelivered())
+}
+
+func TestNotifyPostsToSlackWebhook(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newWebhookStandIn(t, 0)
+	service := newTestNotificationService(&telegramCredentialResolver{}, NewDefaultNotifiers(config.GetConfig(), nil))
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelSlack, standIn.URL, nil)
+
+	// Act
+	err := service.SendPositionOpenedNotification(ctx, "ETHUSDT", 3000, 1, 3000)
+
+	// Assert
+	assert.NoError(t, err)
+	requests := standIn.Requests()
+	assert.Len(t, requests, 1)
+	payload := map[string]string{}
+	assert.NoError(t, json.Unmarshal(requests[0].Body, &payload))
+	assert.Contains(t, payload["text"], "New position opened")
+	assert.Contains(t, payload["text"], "USDT >> ETHUSDT")
+}
+
+func TestNotifySignsGenericWebhooks(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newWebhookStandIn(t, 0)
+	service := newTestNotificationService(&telegramCredentialResolver{}, NewDefaultNotifiers(config.GetConfig(), nil))
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelWebhook, standIn.URL, nil)
+
+	// Act
+	err := service.SendKillSwitchNotification(ctx, constants.RiskLimitDailyLoss, "", 600, 500)
+
+	// Assert
+	assert.NoError(t, err)
+	requests := standIn.Requests()
+	assert.Len(t, requests, 1)
+	timestamp := requests[0].Header.Get(constants.WebhookTimestampHeader)
+	assert.NotEmpty(t, timestamp)
+	assert.Equal(
+		t,
+		notification.Sign("signing-secret", timestamp, requests[0].Body),
+		requests[0].Header.Get(constants.WebhookSignatureHeader),
+	)
+	assert.NotEqual(
+		t,
+		notification.Sign("other-secret", timestamp, requests[0].Body),
+		requests[0].Header.Get(constants.WebhookSignatureHeader),
+	)
+	payload := map[string]string{}
+	assert.NoError(t, json.Unmarshal(requests[0].Body, &payload))
+	assert.Equal(t, constants.NotificationKindKillSwitch, payload["kind"])
+	assert.Equal(t, "Trading halted, risk limit breached", payload["subject"])
+}
+
+func TestNotifyRetriesFailedDeliveries(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newWebhookStandIn(t, 2)
+	service := newTestNotificationService(&telegramCredentialResolver{}, NewDefaultNotifiers(config.GetConfig(), nil))
+	service.MaxAttempts = 3
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelWebhook, standIn.URL, nil)
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+	service.retries.Wait()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, standIn.Requests(), 3)
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].IsDelivered())
+	assert.Equal(t, 3, deliveries[0].Attempts)
+}
+
+func TestNotifyLogsFailedDeliveriesAfterLastAttempt(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	failing := newWebhookStandIn(t, 100)
+	working := newWebhookStandIn(t, 0)
+	service := newTestNotificationService(&telegramCredentialResolver{}, NewDefaultNotifiers(config.GetConfig(), nil))
+	service.MaxAttempts = 2
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelSlack, failing.URL, nil)
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelWebhook, working.URL, nil)
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+	service.retries.Wait()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, failing.Requests(), 2)
+	assert.Len(t, working.Requests(), 1)
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 2)
+	for _, delivery := range deliveries {
+		if delivery.ChannelType == constants.NotificationChannelSlack {
+			assert.False(t, delivery.IsDelivered())
+			assert.Equal(t, 2, delivery.Attempts)
+			assert.Contains(t, delivery.Error, errors.ErrNotificationRejected.Error())
+		} else {
+			assert.True(t, delivery.IsDelivered())
+		}
+	}
+}
+
+func TestNotifyQueuesRetriesInTheBackground(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newWebhookStandIn(t, 100)
+	service := newTestNotificationService(&telegramCredentialResolver{}, NewDefaultNotifiers(config.GetConfig(), nil))
+	service.MaxAttempts = 3
+	service.RetryBackoff = time.Hour
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelWebhook, standIn.URL, nil)
+	start := time.Now()
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Len(t, standIn.Requests(), 1)
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, constants.NotificationDeliveryStatusRetrying, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+}
+
+func TestNotifyFailsWithoutAttemptsLeft(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newWebhookStandIn(t, 100)
+	service := newTestNotificationService(&telegramCredentialResolver{}, NewDefaultNotifiers(config.GetConfig(), nil))
+	service.MaxAttempts = 1
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelWebhook, standIn.URL, nil)
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+
+	// Assert
+	assert.ErrorIs(t, err, errors.ErrNotificationNotDelivered)
+	assert.Len(t, standIn.Requests(), 1)
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].IsFailed())
+}
+
+func TestNotifyOnlyUsesChannelsReceivingTheKind(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	standIn := newWebhookStandIn(t, 0)
+	telegram := &recordingNotifier{}
+	notifiers := NewDefaultNotifiers(config.GetConfig(), nil)
+	notifiers[constants.NotificationChannelTelegram] = telegram
+	service := newTestNotificationService(&telegramCredentialResolver{chatID: 424242}, notifiers)
+	newTestChannel(t, ctx, service, userID, constants.NotificationChannelWebhook, standIn.URL, []string{constants.NotificationKindTrade})
+
+	// Act
+	tradeErr := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+	stopLossErr := service.SendStopLossNotification(ctx, "ETHUSDT", 2800, 20, 6)
+
+	// Assert
+	assert.NoError(t, tradeErr)
+	assert.NoError(t, stopLossErr)
+	assert.Len(t, standIn.Requests(), 1)
+	assert.Empty(t, telegram.channels)
+	assert.Len(t, getTestDeliveries(t, ctx, service), 1)
+}
+
+func TestNotifyFallsBackToTelegramWithoutChannels(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 424242},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, telegram.channels, 1)
+	assert.Equal(t, "424242", telegram.channels[0].Target)
+	assert.Equal(t, "test-token", telegram.channels[0].Secret)
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, constants.NotificationChannelTelegram, deliveries[0].ChannelType)
+}
+
+func TestNotifyWithoutUserGoesToTheCredentialsChat(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 434343},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, telegram.channels, 1)
+	assert.Equal(t, "434343", telegram.channels[0].Target)
+}
+
+func TestIsTelegramRecipientChecksTheGivenUserWithoutCaller(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	chatFactory := &entities.TelegramChatFactory{}
+	_, err := telegramChatRepository.Create(ctx, chatFactory.NewTelegramChat(userID, 454545))
+	assert.NoError(t, err)
+	service := newTestNotificationService(&telegramCredentialResolver{chatID: 464646}, nil)
+
+	// Act
+	paired, pairedErr := service.IsTelegramRecipient(ctx, userID, 454545)
+	fallback, fallbackErr := service.IsTelegramRecipient(ctx, userID, 464646)
+
+	// Assert
+	assert.NoError(t, pairedErr)
+	assert.NoError(t, fallbackErr)
+	assert.True(t, paired)
+	assert.False(t, fallback)
+}
+
+func TestNotifyLogsMissingTelegramKeys(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+
+	// Act
+	err := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+
+	// Assert
+	assert.ErrorIs(t, err, errors.ErrNotificationNotDelivered)
+	assert.Empty(t, telegram.channels)
+	deliveries := getTestDeliveries(t, ctx, service)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].IsDelivered())
+	assert.Equal(t, 0, deliveries[0].Attempts)
+	assert.Equal(t, errors.ErrApiKeyTelegramNotFound.Error(), deliveries[0].Error)
+}
+
+func TestNotifyRendersMarkdownForTelegram(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 424242},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+
+	// Act
+	err := service.SendTakeProfitNotification(ctx, "PEPEUSDT", 0.000123, 1500000, 25.5, 3.256)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, telegram.messages, 1)
+	message := telegram.messages[0]
+	assert.Equal(t, constants.NotificationFormatMarkdown, message.Format)
+	assert.Equal(t, "Take profit target reached", message.Subject)
+	assert.Contains(t, message.Text, "*Take profit target reached*")
+	assert.Contains(t, message.Text, "Target price: 0.000123\n")
+	assert.Contains(t, message.Text, "Quantity sold: 1,500,000\n")
+	assert.Contains(t, message.Text, "Profit: 25.50 USDT")
+	assert.Contains(t, message.Text, "Profit percentage: 3.26%")
+}
+
+func TestNotifyRendersInUserLocale(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 424242},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	preference := preferenceFactory.NewNotificationPreference(userID)
+	preference.Locale = constants.NotificationLocaleSpanish
+	_, err := service.UpdatePreference(ctx, preference)
+	assert.NoError(t, err)
+
+	// Act
+	err = service.SendStopLossNotification(ctx, "BTCUSDT", 55000.5, 1234.5, 2.25)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, telegram.messages, 1)
+	message := telegram.messages[0]
+	assert.Equal(t, "Stop loss activado", message.Subject)
+	assert.Contains(t, message.Text, "Precio de stop loss: 55.000,50")
+	assert.Contains(t, message.Text, "Pérdida: 1.234,50 USDT")
+	assert.Contains(t, message.Text, "Porcentaje de pérdida: 2,25%")
+}
+
+func TestNotifyDropsEventsUserOptedOutOf(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 424242},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	preference := preferenceFactory.NewNotificationPreference(userID)
+	preference.Events = []string{constants.NotificationKindKillSwitch}
+	_, err := service.UpdatePreference(ctx, preference)
+	assert.NoError(t, err)
+
+	// Act
+	tradeErr := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+	killSwitchErr := service.SendKillSwitchNotification(ctx, constants.RiskLimitDailyLoss, "", 120, 100)
+
+	// Assert
+	assert.NoError(t, tradeErr)
+	assert.NoError(t, killSwitchErr)
+	assert.Len(t, telegram.messages, 1)
+	assert.Equal(t, constants.NotificationKindKillSwitch, telegram.messages[0].Kind)
+	assert.Contains(t, telegram.messages[0].Text, "Limit: Daily loss")
+	assert.Len(t, getTestDeliveries(t, ctx, service), 1)
+}
+
+func TestNotifySuppressesDuringQuietHoursExceptUrgentEvents(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 424242},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+	now := time.Now().UTC()
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	preference := preferenceFactory.NewNotificationPreference(userID)
+	preference.QuietHoursStart = now.Add(-time.Hour).Format("15:04")
+	preference.QuietHoursEnd = now.Add(time.Hour).Format("15:04")
+	_, err := service.UpdatePreference(ctx, preference)
+	assert.NoError(t, err)
+
+	// Act
+	tradeErr := service.SendTradeNotification(ctx, "BTCUSDT", "ETHUSDT", 3000, 10, 1)
+	killSwitchErr := service.SendKillSwitchNotification(ctx, constants.RiskLimitDailyLoss, "", 120, 100)
+
+	// Assert
+	assert.NoError(t, tradeErr)
+	assert.NoError(t, killSwitchErr)
+	assert.Len(t, telegram.messages, 1)
+	assert.Equal(t, constants.NotificationKindKillSwitch, telegram.messages[0].Kind)
+	statuses := map[string]string{}
+	for _, delivery := range getTestDeliveries(t, ctx, service) {
+		statuses[delivery.Kind] = delivery.Status
+	}
+	assert.Equal(t, map[string]string{
+		constants.NotificationKindTrade:      constants.NotificationDeliveryStatusSuppressed,
+		constants.NotificationKindKillSwitch: constants.NotificationDeliveryStatusDelivered,
+	}, statuses)
+}
+
+func TestSendDigestNotificationListsPortfolio(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	telegram := &recordingNotifier{}
+	service := newTestNotificationService(
+		&telegramCredentialResolver{chatID: 424242},
+		map[string]Notifier{constants.NotificationChannelTelegram: telegram},
+	)
+	scheduleFactory := &entities.DigestScheduleFactory{}
+	schedule, err := service.UpdateDigestSchedule(
+		ctx,
+		scheduleFactory.NewDigestSchedule(userID, constants.DigestFrequencyDaily, "23:00", time.Sunday),
+	)
+	assert.NoError(t, err)
+	to := time.Date(2026, 3, 14, 23, 0, 0, 0, time.UTC)
+	digest := &valueobjects.DigestNotification{
+		Frequency:             constants.DigestFrequencyDaily,
+		From:                  to.AddDate(0, 0, -1),
+		To:                    to,
+		PortfolioValue:        1250,
+		ValueChange:           50,
+		ValueChangePercentage: 4.1666,
+		Trades:                3,
+		Fees:                  1.2,
+		RealisedPnL:           -12.345,
+		UnrealisedPnL:         40,
+		Positions: valueobjects.HoldingsPnL{
+			{Symbol: "BTCUSDT", Quantity: 0.015, UnrealisedPnL: 40},
+		},
+		Scores: valueobjects.SymbolScores{
+			{Symbol: "ETHUSDT", Score: 0.8123, Ranking: 1},
+		},
+		RiskEvents: []valueobjects.DigestRiskEvent{
+			{Kind: constants.DigestRiskEventKillSwitch, Limit: constants.RiskLimitDailyLoss, OccurredAt: to.Add(-time.Hour)},
+		},
+	}
+
+	// Act
+	err = service.SendDigestNotification(ctx, schedule, digest)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, telegram.messages, 1)
+	message := telegram.messages[0]
+	assert.Equal(t, "Daily digest 2026-03-14", message.Subject)
+	assert.Contains(t, message.Text, "Portfolio value: 1,250.00 USDT (+50.00 USDT, +4.17%)")
+	assert.Contains(t, message.Text, "Trades: 3")
+	assert.Contains(t, message.Text, "Fees: 1.20 USDT")
+	assert.Contains(t, message.Text, "Realised PnL: -12.35 USDT")
+	assert.Contains(t, message.Text, "BTCUSDT: 0.015, 40.00 USDT")
+	assert.Contains(t, message.Text, "1. ETHUSDT: 0.81")
+	assert.Contains(t, message.Text, "Kill switch tripped")
+	stored, err := service.GetDigestSchedule(ctx, userID)
+	assert.NoError(t, err)
+	assert.True(t, stored.LastSentAt.Equal(to))
+	assert.Equal(t, 1250.0, stored.LastValue)
+}
+
+func TestUpdateDigestScheduleKeepsLastDigest(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	scheduleFactory := &entities.DigestScheduleFactory{}
+	schedule, err := service.UpdateDigestSchedule(
+		ctx,
+		scheduleFactory.NewDigestSchedule(userID, constants.DigestFrequencyDaily, "08:00", time.Monday),
+	)
+	assert.NoError(t, err)
+	sentAt := time.Now().UTC().Truncate(time.Second)
+	schedule.MarkSent(sentAt, 900)
+	_, err = digestRepository.Update(ctx, schedule)
+	assert.NoError(t, err)
+
+	// Act
+	updated, err := service.UpdateDigestSchedule(
+		ctx,
+		scheduleFactory.NewDigestSchedule(userID, constants.DigestFrequencyWeekly, "18:30", time.Friday),
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.ID, updated.ID)
+	stored, err := service.GetDigestSchedule(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.DigestFrequencyWeekly, stored.Frequency)
+	assert.Equal(t, "18:30", stored.Time)
+	assert.Equal(t, time.Friday, stored.Weekday)
+	assert.True(t, stored.LastSentAt.Equal(sentAt))
+	assert.Equal(t, 900.0, stored.LastValue)
+}
+
+func TestUpdateDigestScheduleReturnsErrorIfInvalid(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	scheduleFactory := &entities.DigestScheduleFactory{}
+
+	// Act
+	_, frequencyErr := service.UpdateDigestSchedule(
+		ctx,
+		scheduleFactory.NewDigestSchedule(userID, "monthly", "08:00", time.Monday),
+	)
+	_, timeErr := service.UpdateDigestSchedule(
+		ctx,
+		scheduleFactory.NewDigestSchedule(userID, constants.DigestFrequencyDaily, "25:00", time.Monday),
+	)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidDigestFrequency, frequencyErr)
+	assert.Equal(t, errors.ErrInvalidDigestTime, timeErr)
+}
+
+func TestUpdateDigestScheduleReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	ctx := newNotificationTestContext(uuid.New())
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	scheduleFactory := &entities.DigestScheduleFactory{}
+
+	// Act
+	_, err := service.UpdateDigestSchedule(
+		ctx,
+		scheduleFactory.NewDigestSchedule(uuid.New(), constants.DigestFrequencyDaily, "08:00", time.Monday),
+	)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+// Notification preference tests
+
+func TestGetPreferenceReturnsDefaultIfNotSet(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+
+	// Act
+	preference, err := service.GetPreference(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, userID, preference.UserID)
+	assert.Equal(t, constants.NotificationLocaleEnglish, preference.Locale)
+	assert.True(t, preference.Receives(constants.NotificationKindTrade))
+	assert.False(t, preference.InQuietHours(time.Now()))
+}
+
+func TestUpdatePreferenceReturnsErrorIfInvalidQuietHours(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	preference := preferenceFactory.NewNotificationPreference(userID)
+	preference.QuietHoursStart = "22:00"
+
+	// Act
+	_, err := service.UpdatePreference(ctx, preference)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidQuietHours, err)
+}
+
+func TestUpdatePreferenceReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	ctx := newNotificationTestContext(uuid.New())
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	preference := preferenceFactory.NewNotificationPreference(uuid.New())
+
+	// Act
+	_, err := service.UpdatePreference(ctx, preference)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+// Notification channel tests
+
+func TestCreateChannelReturnsErrorIfInvalidTarget(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	channelFactory := &entities.NotificationChannelFactory{}
+	email := channelFactory.NewNotificationChannel(userID, constants.NotificationChannelEmail, "not an address", "", nil)
+	slack := channelFactory.NewNotificationChannel(userID, constants.NotificationChannelSlack, "ftp://example.com", "", nil)
+	webhook := channelFactory.NewNotificationChannel(userID, constants.NotificationChannelWebhook, "https://example.com", "", nil)
+
+	// Act
+	_, emailErr := service.CreateChannel(ctx, email)
+	_, slackErr := service.CreateChannel(ctx, slack)
+	_, webhookErr := service.CreateChannel(ctx, webhook)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidNotificationTarget, emailErr)
+	assert.Equal(t, errors.ErrInvalidNotificationTarget, slackErr)
+	assert.Equal(t, errors.ErrNotificationWebhookSecretMissing, webhookErr)
+}
+
+func TestCreateChannelReturnsErrorIfInvalidKind(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newNotificationTestContext(userID)
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(userID, constants.NotificationChannelTelegram, "", "", []string{"everything"})
+
+	// Act
+	_, err := service.CreateChannel(ctx, channel)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidNotificationKind, err)
+}
+
+func TestCreateChannelReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	ctx := newNotificationTestContext(uuid.New())
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(uuid.New(), constants.NotificationChannelEmail, "user@example.com", "", nil)
+
+	// Act
+	_, err := service.CreateChannel(ctx, channel)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+func TestDeleteChannelReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	ownerID := uuid.New()
+	service := newTestNotificationService(&telegramCredentialResolver{}, map[string]Notifier{})
+	channel := newTestChannel(
+		t,
+		newNotificationTestContext(ownerID),
+		service,
+		ownerID,
+		constants.NotificationChannelEmail,
+		"owner@example.com",
+		nil,
+	)
+
+	// Act
+	err := service.DeleteChannel(newNotificationTestContext(uuid.New()), channel.ID)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+	channels, err := service.GetChannels(newNotificationTestContext(ownerID), ownerID)
+	assert.NoError(t, err)
+	assert.Len(t, *channels, 1)
+}
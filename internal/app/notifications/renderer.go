@@ -0,0 +1,233 @@
+package notifications
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"math"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	exchanges "github.com/sergiovirahonda/endurance-api/internal/app/exchange"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// Characters legacy Telegram Markdown gives meaning to
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// Structs
+
+// NotificationRenderer renders notification events with the template of
+// their kind, in the format of the channel and the language, timezone and
+// number conventions of the user.
+type NotificationRenderer struct {
+	texts         map[string]*texttemplate.Template
+	htmls         map[string]*htmltemplate.Template
+	symbolFilters *exchanges.SymbolFiltersCache
+}
+
+// Factories
+
+// NewNotificationRenderer parses the notification templates. Prices and
+// quantities take the precision of the symbol from the trading rules the
+// exchange services already fetched.
+func NewNotificationRenderer(symbolFilters *exchanges.SymbolFiltersCache) *NotificationRenderer {
+	renderer := &NotificationRenderer{
+		texts:         make(map[string]*texttemplate.Template),
+		htmls:         make(map[string]*htmltemplate.Template),
+		symbolFilters: symbolFilters,
+	}
+	// Functions are bound on every render, these only let templates parse
+	funcs := renderer.funcs(constants.NotificationFormatText, &entities.NotificationPreference{})
+	for kind, template := range notificationTemplates {
+		renderer.texts[kind] = texttemplate.Must(
+			texttemplate.New(kind).Funcs(texttemplate.FuncMap(funcs)).Parse(
+				`{{define "subject"}}` + template.Subject + `{{end}}` +
+					`{{define "text"}}` + template.Text + `{{end}}`,
+			),
+		)
+		renderer.htmls[kind] = htmltemplate.Must(
+			htmltemplate.New(kind).Funcs(htmltemplate.FuncMap(funcs)).Parse(template.HTML),
+		)
+	}
+	return renderer
+}
+
+// Receivers
+
+// Render returns the message of the event for a channel of the type.
+func (r *NotificationRenderer) Render(
+	event *valueobjects.NotificationEvent,
+	channelType string,
+	preference *entities.NotificationPreference,
+) (*valueobjects.NotificationMessage, error) {
+	text, ok := r.texts[event.Kind]
+	if !ok {
+		return nil, errors.ErrNotificationTemplateMissing
+	}
+	format, ok := channelFormats[channelType]
+	if !ok {
+		format = constants.NotificationFormatText
+	}
+	subject, err := r.execute(text, "subject", constants.NotificationFormatText, preference, event.Data)
+	if err != nil {
+		return nil, err
+	}
+	var body string
+	if format == constants.NotificationFormatHTML {
+		var buffer bytes.Buffer
+		html, err := r.htmls[event.Kind].Clone()
+		if err != nil {
+			return nil, err
+		}
+		err = html.Funcs(htmltemplate.FuncMap(r.funcs(format, preference))).Execute(&buffer, event.Data)
+		if err != nil {
+			return nil, err
+		}
+		body = buffer.String()
+	} else {
+		body, err = r.execute(text, "text", format, preference, event.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &valueobjects.NotificationMessage{
+		Kind:    event.Kind,
+		Format:  format,
+		Subject: strings.TrimSpace(subject),
+		Text:    strings.TrimSpace(body),
+		Options: event.Options,
+	}, nil
+}
+
+// Helpers
+
+func (r *NotificationRenderer) execute(
+	template *texttemplate.Template,
+	name string,
+	format string,
+	preference *entities.NotificationPreference,
+	data interface{},
+) (string, error) {
+	var buffer bytes.Buffer
+	clone, err := template.Clone()
+	if err != nil {
+		return "", err
+	}
+	err = clone.Funcs(texttemplate.FuncMap(r.funcs(format, preference))).ExecuteTemplate(&buffer, name, data)
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (r *NotificationRenderer) funcs(
+	format string,
+	preference *entities.NotificationPreference,
+) map[string]interface{} {
+	locale := preference.Locale
+	separators, ok := notificationSeparators[locale]
+	if !ok {
+		separators = notificationSeparators[constants.NotificationLocaleEnglish]
+	}
+	location := preference.Location()
+	decimal := func(value float64, minDecimals int, maxDecimals int) string {
+		return lib.FormatDecimal(value, minDecimals, maxDecimals, separators[0], separators[1])
+	}
+	return map[string]interface{}{
+		"t": func(key string) string {
+			if text, ok := notificationCatalogue[locale][key]; ok {
+				return text
+			}
+			if text, ok := notificationCatalogue[constants.NotificationLocaleEnglish][key]; ok {
+				return text
+			}
+			return key
+		},
+		"b": func(text string) string {
+			if format != constants.NotificationFormatMarkdown {
+				return text
+			}
+			return "*" + text + "*"
+		},
+		"esc": func(text string) string {
+			if format != constants.NotificationFormatMarkdown {
+				return text
+			}
+			return markdownEscaper.Replace(text)
+		},
+		"price": func(symbol string, value float64) string {
+			return decimal(value, 2, r.pricePrecision(symbol, value))
+		},
+		"quantity": func(symbol string, value float64) string {
+			return decimal(value, 0, r.quantityPrecision(symbol))
+		},
+		"usdt": func(value float64) string {
+			return decimal(value, 2, 2)
+		},
+		"percent": func(value float64) string {
+			return decimal(value, 2, 2)
+		},
+		"number": func(value float64) string {
+			return decimal(value, 0, 8)
+		},
+		"decimal": func(value float64, decimals int) string {
+			return decimal(value, decimals, decimals)
+		},
+		"change": func(value float64) string {
+			return signed(value, decimal(value, 2, 2))
+		},
+		"datetime": func(t time.Time) string {
+			return t.In(location).Format("2006-01-02 15:04 MST")
+		},
+		"date": func(t time.Time) string {
+			return t.In(location).Format("2006-01-02")
+		},
+	}
+}
+
+// signed prefixes gains with a plus sign, losses carry theirs already.
+func signed(value float64, formatted string) string {
+	if value > 0 && strings.Trim(formatted, "0.,") != "" {
+		return "+" + formatted
+	}
+	return formatted
+}
+
+// pricePrecision returns the decimals of the tick size of the symbol, or
+// enough decimals for the magnitude of the price when its rules are unknown.
+func (r *NotificationRenderer) pricePrecision(symbol string, price float64) int {
+	if r.symbolFilters != nil {
+		if filters, ok := r.symbolFilters.Get(symbol); ok && filters.PricePrecision > 0 {
+			return int(filters.PricePrecision)
+		}
+	}
+	switch price = math.Abs(price); {
+	case price >= 1000:
+		return 2
+	case price >= 1:
+		return 4
+	case price >= 0.01:
+		return 6
+	default:
+		return 8
+	}
+}
+
+// quantityPrecision returns the decimals of the step size of the symbol, the
+// exchange base asset precision when its rules are unknown.
+func (r *NotificationRenderer) quantityPrecision(symbol string) int {
+	if symbol == constants.LedgerQuoteAsset {
+		return 2
+	}
+	if r.symbolFilters != nil {
+		if filters, ok := r.symbolFilters.Get(symbol); ok && filters.QuantityPrecision > 0 {
+			return int(filters.QuantityPrecision)
+		}
+	}
+	return 8
+}
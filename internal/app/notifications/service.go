@@ -1 +1,48 @@
 package notifications
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+)
+
+type NotificationService interface {
+	Notify(ctx echo.Context, event *valueobjects.NotificationEvent) error
+	IsTelegramRecipient(ctx echo.Context, userID uuid.UUID, chatID int64) (bool, error)
+	// Telegram pairing
+	CreatePairingCode(ctx echo.Context, userID uuid.UUID) (string, *entities.PairingCode, error)
+	PairChat(ctx echo.Context, code string, chatID int64) (*entities.TelegramChat, error)
+	UnpairChat(ctx echo.Context, userID uuid.UUID) error
+	GetChatUserID(ctx echo.Context, chatID int64) (uuid.UUID, error)
+	// Notification channels
+	GetChannels(ctx echo.Context, userID uuid.UUID) (*entities.NotificationChannels, error)
+	CreateChannel(ctx echo.Context, channel *entities.NotificationChannel) (*entities.NotificationChannel, error)
+	UpdateChannel(ctx echo.Context, channel *entities.NotificationChannel) (*entities.NotificationChannel, error)
+	DeleteChannel(ctx echo.Context, id uuid.UUID) error
+	GetDeliveries(ctx echo.Context, filters filtering.ComplexFilters) (*entities.NotificationDeliveries, error)
+	// Notification preferences
+	GetPreference(ctx echo.Context, userID uuid.UUID) (*entities.NotificationPreference, error)
+	UpdatePreference(ctx echo.Context, preference *entities.NotificationPreference) (*entities.NotificationPreference, error)
+	// Digest schedules
+	GetDigestSchedule(ctx echo.Context, userID uuid.UUID) (*entities.DigestSchedule, error)
+	UpdateDigestSchedule(ctx echo.Context, schedule *entities.DigestSchedule) (*entities.DigestSchedule, error)
+	GetEnabledDigestSchedules(ctx echo.Context) (*entities.DigestSchedules, error)
+	// Integrations
+	SendTradeNotification(ctx echo.Context, originSymbol string, newSymbol string, entryPrice float64, profit float64, profitPercentage float64) error
+	SendStopLossNotification(ctx echo.Context, originSymbol string, stopLossPrice float64, loss float64, lossPercentage float64) error
+	SendTakeProfitNotification(ctx echo.Context, originSymbol string, targetPrice float64, quantity float64, profit float64, profitPercentage float64) error
+	SendPositionOpenedNotification(ctx echo.Context, targetSymbol string, entryPrice float64, quantity float64, amount float64) error
+	SendKillSwitchNotification(ctx echo.Context, limit string, symbol string, value float64, threshold float64) error
+	SendTradeProposalNotification(ctx echo.Context, proposal *entities.TradeProposal) error
+	SendAlertNotification(ctx echo.Context, rule *entities.AlertRule, marketData *entities.MarketData, value float64) error
+	SendDigestNotification(ctx echo.Context, schedule *entities.DigestSchedule, digest *valueobjects.DigestNotification) error
+}
+
+// Notifier delivers a message through one kind of channel.
+type Notifier interface {
+	Notify(ctx context.Context, channel *entities.NotificationChannel, message *valueobjects.NotificationMessage) error
+}
@@ -1 +1,253 @@
 package notifications
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	keys "github.com/sergiovirahonda/endurance-api/internal/app/key"
+	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
+	notificationRepository "github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/notification"
+	"gorm.io/gorm"
+)
+
+var (
+	database               *gorm.DB
+	telegramChatRepository notificationRepository.TelegramChatRepository
+	pairingCodeRepository  notificationRepository.PairingCodeRepository
+	channelRepository      notificationRepository.NotificationChannelRepository
+	deliveryRepository     notificationRepository.NotificationDeliveryRepository
+	preferenceRepository   notificationRepository.NotificationPreferenceRepository
+	digestRepository       notificationRepository.DigestScheduleRepository
+	uacService             uacs.UacService
+)
+
+func TestMain(m *testing.M) {
+	logger := config.GetLogger()
+	logger.Info("Running notification service tests...")
+	logger.Info("Instantiating test database...")
+	database = db.NewTestConnection()
+	logger.Info("Test DB connection established.")
+	models := []interface{}{
+		&dtos.TelegramChat{},
+		&dtos.PairingCode{},
+		&dtos.NotificationChannel{},
+		&dtos.NotificationDelivery{},
+		&dtos.NotificationPreference{},
+		&dtos.DigestSchedule{},
+	}
+	logger.Info("Attempting to run migrations on test database...")
+	db.Migrate(database, models)
+	logger.Info("Migrations completed. Running tests...")
+	telegramChatRepository = notificationRepository.NewDefaultTelegramChatRepository(database)
+	pairingCodeRepository = notificationRepository.NewDefaultPairingCodeRepository(database)
+	channelRepository = notificationRepository.NewDefaultNotificationChannelRepository(database)
+	deliveryRepository = notificationRepository.NewDefaultNotificationDeliveryRepository(database)
+	preferenceRepository = notificationRepository.NewDefaultNotificationPreferenceRepository(database)
+	digestRepository = notificationRepository.NewDefaultDigestScheduleRepository(database)
+	uacService = uacs.NewDefaultUacService()
+	os.Exit(m.Run())
+	logger.Info("Tests completed.")
+}
+
+// newTestNotificationService returns a service delivering through the given
+// notifiers, retrying without backoff.
+func newTestNotificationService(
+	credentialResolver keys.CredentialResolver,
+	notifiers map[string]Notifier,
+) *DefaultNotificationService {
+	service := NewDefaultNotificationService(
+		credentialResolver,
+		telegramChatRepository,
+		pairingCodeRepository,
+		channelRepository,
+		deliveryRepository,
+		preferenceRepository,
+		digestRepository,
+		uacService,
+		nil,
+		notifiers,
+	)
+	service.RetryBackoff = 0
+	return service
+}
+
+// telegramCredentialResolver hands out telegram credentials with the chat
+// ID, or none at all when chatID is zero.
+type telegramCredentialResolver struct {
+	*keys.DefaultCredentialResolver
+	chatID int64
+}
+
+func (r *telegramCredentialResolver) ResolveTelegramCredentials(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*valueobjects.TelegramCredentials, error) {
+	if r.chatID == 0 {
+		return nil, errors.ErrApiKeyTelegramNotFound
+	}
+	return &valueobjects.TelegramCredentials{BotToken: "test-token", ChatID: r.chatID}, nil
+}
+
+// recordingNotifier keeps the channels it was asked to deliver to, and the
+// messages delivered.
+type recordingNotifier struct {
+	mutex    sync.Mutex
+	channels []entities.NotificationChannel
+	messages []valueobjects.NotificationMessage
+}
+
+func (n *recordingNotifier) Notify(
+	ctx context.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.channels = append(n.channels, *channel)
+	n.messages = append(n.messages, *message)
+	return nil
+}
+
+// webhookStandIn answers webhook deliveries, failing the first ones with a
+// server error, and keeps the requests it received.
+type webhookStandIn struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	failures int
+	requests []webhookRequest
+}
+
+type webhookRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+func newWebhookStandIn(t *testing.T, failures int) *webhookStandIn {
+	standIn := &webhookStandIn{failures: failures}
+	standIn.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		standIn.mutex.Lock()
+		defer standIn.mutex.Unlock()
+		standIn.requests = append(standIn.requests, webhookRequest{Header: r.Header, Body: body})
+		if len(standIn.requests) <= standIn.failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(standIn.Close)
+	return standIn
+}
+
+func (s *webhookStandIn) Requests() []webhookRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]webhookRequest{}, s.requests...)
+}
+
+// smtpStandIn is a plain SMTP server keeping the mails it receives. It
+// doesn't offer STARTTLS nor authentication.
+type smtpStandIn struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	mails    []smtpMail
+}
+
+type smtpMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn := &smtpStandIn{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go standIn.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return standIn
+}
+
+func (s *smtpStandIn) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+func (s *smtpStandIn) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *smtpStandIn) Mails() []smtpMail {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]smtpMail{}, s.mails...)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	mail := smtpMail{}
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.To = append(mail.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(bufio.NewReader(text.DotReader()))
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			s.mutex.Lock()
+			s.mails = append(s.mails, mail)
+			s.mutex.Unlock()
+			mail = smtpMail{}
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package notifications
+
+import "github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+
+// notificationTemplate renders a notification kind. Text renders both the
+// Markdown and plain text formats, b and esc adapting to each; HTML is
+// executed as html/template so values are escaped for email clients.
+type notificationTemplate struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Template functions available to every template:
+//   - t: the catalogue entry of the key in the user locale
+//   - b, esc: bold and escaped text in the Markdown format, as is otherwise
+//   - price, quantity: prices and quantities of the symbol, with its precision
+//   - usdt, percent, number, decimal: other numbers, with the precision they
+//     are read with
+//   - change: changes of USDT amounts and percentages, signed
+//   - datetime, date: times in the user timezone
+var notificationTemplates = map[string]notificationTemplate{
+	constants.NotificationKindTrade: {
+		Subject: `{{t "trade.subject"}}`,
+		Text: `❗ {{b (t "trade.subject")}}
+
+💰 {{esc .FromSymbol}} >> {{esc .ToSymbol}}
+- {{t "entry_price"}}: {{price .ToSymbol .EntryPrice}}
+- {{t "profit"}}: {{usdt .Profit}} USDT
+- {{t "profit_percentage"}}: {{percent .ProfitPercentage}}%`,
+		HTML: `<p>❗ <b>{{t "trade.subject"}}</b></p>
+<p>💰 {{.FromSymbol}} &gt;&gt; {{.ToSymbol}}</p>
+<ul>
+<li>{{t "entry_price"}}: {{price .ToSymbol .EntryPrice}}</li>
+<li>{{t "profit"}}: {{usdt .Profit}} USDT</li>
+<li>{{t "profit_percentage"}}: {{percent .ProfitPercentage}}%</li>
+</ul>`,
+	},
+	constants.NotificationKindStopLoss: {
+		Subject: `{{t "stop_loss.subject"}}`,
+		Text: `❗ {{b (t "stop_loss.subject")}}
+
+💰 {{esc .Symbol}} >> USDT
+- {{t "stop_loss_price"}}: {{price .Symbol .StopLossPrice}}
+- {{t "loss"}}: {{usdt .Loss}} USDT
+- {{t "loss_percentage"}}: {{percent .LossPercentage}}%`,
+		HTML: `<p>❗ <b>{{t "stop_loss.subject"}}</b></p>
+<p>💰 {{.Symbol}} &gt;&gt; USDT</p>
+<ul>
+<li>{{t "stop_loss_price"}}: {{price .Symbol .StopLossPrice}}</li>
+<li>{{t "loss"}}: {{usdt .Loss}} USDT</li>
+<li>{{t "loss_percentage"}}: {{percent .LossPercentage}}%</li>
+</ul>`,
+	},
+	constants.NotificationKindTakeProfit: {
+		Subject: `{{t "take_profit.subject"}}`,
+		Text: `❗ {{b (t "take_profit.subject")}}
+
+💰 {{esc .Symbol}} >> USDT
+- {{t "target_price"}}: {{price .Symbol .TargetPrice}}
+- {{t "quantity_sold"}}: {{quantity .Symbol .Quantity}}
+- {{t "profit"}}: {{usdt .Profit}} USDT
+- {{t "profit_percentage"}}: {{percent .ProfitPercentage}}%`,
+		HTML: `<p>❗ <b>{{t "take_profit.subject"}}</b></p>
+<p>💰 {{.Symbol}} &gt;&gt; USDT</p>
+<ul>
+<li>{{t "target_price"}}: {{price .Symbol .TargetPrice}}</li>
+<li>{{t "quantity_sold"}}: {{quantity .Symbol .Quantity}}</li>
+<li>{{t "profit"}}: {{usdt .Profit}} USDT</li>
+<li>{{t "profit_percentage"}}: {{percent .ProfitPercentage}}%</li>
+</ul>`,
+	},
+	constants.NotificationKindPositionOpened: {
+		Subject: `{{t "position_opened.subject"}}`,
+		Text: `❗ {{b (t "position_opened.subject")}}
+
+💰 USDT >> {{esc .Symbol}}
+- {{t "entry_price"}}: {{price .Symbol .EntryPrice}}
+- {{t "quantity_bought"}}: {{quantity .Symbol .Quantity}}
+- {{t "amount"}}: {{usdt .Amount}} USDT`,
+		HTML: `<p>❗ <b>{{t "position_opened.subject"}}</b></p>
+<p>💰 USDT &gt;&gt; {{.Symbol}}</p>
+<ul>
+<li>{{t "entry_price"}}: {{price .Symbol .EntryPrice}}</li>
+<li>{{t "quantity_bought"}}: {{quantity .Symbol .Quantity}}</li>
+<li>{{t "amount"}}: {{usdt .Amount}} USDT</li>
+</ul>`,
+	},
+	constants.NotificationKindKillSwitch: {
+		Subject: `{{t "kill_switch.subject"}}`,
+		Text: `🛑 {{b (t "kill_switch.subject")}}
+
+- {{t "limit"}}: {{t (print "risk_limit." .Limit)}} {{esc .Symbol}}
+- {{t "value"}}: {{number .Value}}
+- {{t "threshold"}}: {{number .Threshold}}
+
+{{t "kill_switch.footer"}}`,
+		HTML: `<p>🛑 <b>{{t "kill_switch.subject"}}</b></p>
+<ul>
+<li>{{t "limit"}}: {{t (print "risk_limit." .Limit)}} {{.Symbol}}</li>
+<li>{{t "value"}}: {{number .Value}}</li>
+<li>{{t "threshold"}}: {{number .Threshold}}</li>
+</ul>
+<p>{{t "kill_switch.footer"}}</p>`,
+	},
+	constants.NotificationKindTradeProposal: {
+		Subject: `{{t "trade_proposal.subject"}}`,
+		Text: `🤔 {{b (t "trade_proposal.subject")}}
+
+💰 {{esc .FromSymbol}} >> {{esc .ToSymbol}}
+- {{t "proposed_price"}}: {{price .ToSymbol .ProposedPrice}}
+- {{t "expires_at"}}: {{datetime .ExpiresAt}}`,
+		HTML: `<p>🤔 <b>{{t "trade_proposal.subject"}}</b></p>
+<p>💰 {{.FromSymbol}} &gt;&gt; {{.ToSymbol}}</p>
+<ul>
+<li>{{t "proposed_price"}}: {{price .ToSymbol .ProposedPrice}}</li>
+<li>{{t "expires_at"}}: {{datetime .ExpiresAt}}</li>
+</ul>`,
+	},
+	constants.NotificationKindAlert: {
+		Subject: `{{t "alert.subject"}} {{.Symbol}}`,
+		Text: `🔔 {{b (t "alert.subject")}} {{esc .Symbol}}
+
+- {{esc .Field}} {{t (print "alert_condition." .Condition)}} {{number .Threshold}}
+- {{t "value"}}: {{number .Value}}
+- {{t "candle_time"}}: {{datetime .Timestamp}}`,
+		HTML: `<p>🔔 <b>{{t "alert.subject"}} {{.Symbol}}</b></p>
+<ul>
+<li>{{.Field}} {{t (print "alert_condition." .Condition)}} {{number .Threshold}}</li>
+<li>{{t "value"}}: {{number .Value}}</li>
+<li>{{t "candle_time"}}: {{datetime .Timestamp}}</li>
+</ul>`,
+	},
+	constants.NotificationKindDailyDigest:  digestTemplate,
+	constants.NotificationKindWeeklyDigest: digestTemplate,
+}
+
+// Daily and weekly digests only differ in their subject
+var digestTemplate = notificationTemplate{
+	Subject: `{{t (print .Frequency "_digest.subject")}} {{date .To}}`,
+	Text: `📅 {{b (t (print .Frequency "_digest.subject"))}} {{date .From}} - {{date .To}}
+
+💼 {{t "portfolio_value"}}: {{usdt .PortfolioValue}} USDT ({{change .ValueChange}} USDT, {{change .ValueChangePercentage}}%)
+- {{t "trades"}}: {{.Trades}}
+- {{t "fees"}}: {{usdt .Fees}} USDT
+- {{t "realised_pnl"}}: {{usdt .RealisedPnL}} USDT
+- {{t "unrealised_pnl"}}: {{usdt .UnrealisedPnL}} USDT
+
+{{b (t "open_positions")}}
+{{range .Positions}}💰 {{esc .Symbol}}: {{quantity .Symbol .Quantity}}, {{usdt .UnrealisedPnL}} USDT
+{{else}}{{t "no_positions"}}
+{{end}}
+{{b (t "watchlist_ranking")}}
+{{range .Scores}}{{.Ranking}}. {{esc .Symbol}}: {{decimal .Score 2}}
+{{else}}{{t "no_watchlist_ranking"}}
+{{end}}
+{{b (t "risk_events")}}
+{{range .RiskEvents}}⚠️ {{t (print "risk_event." .Kind)}}{{if .Limit}}, {{t (print "risk_limit." .Limit)}}{{end}}: {{datetime .OccurredAt}}
+{{else}}{{t "no_risk_events"}}
+{{end}}`,
+	HTML: `<p>📅 <b>{{t (print .Frequency "_digest.subject")}} {{date .From}} - {{date .To}}</b></p>
+<p>💼 {{t "portfolio_value"}}: {{usdt .PortfolioValue}} USDT ({{change .ValueChange}} USDT, {{change .ValueChangePercentage}}%)</p>
+<ul>
+<li>{{t "trades"}}: {{.Trades}}</li>
+<li>{{t "fees"}}: {{usdt .Fees}} USDT</li>
+<li>{{t "realised_pnl"}}: {{usdt .RealisedPnL}} USDT</li>
+<li>{{t "unrealised_pnl"}}: {{usdt .UnrealisedPnL}} USDT</li>
+</ul>
+<p><b>{{t "open_positions"}}</b></p>
+{{if .Positions}}<ul>
+{{range .Positions}}<li>💰 {{.Symbol}}: {{quantity .Symbol .Quantity}}, {{usdt .UnrealisedPnL}} USDT</li>
+{{end}}</ul>{{else}}<p>{{t "no_positions"}}</p>{{end}}
+<p><b>{{t "watchlist_ranking"}}</b></p>
+{{if .Scores}}<ol>
+{{range .Scores}}<li>{{.Symbol}}: {{decimal .Score 2}}</li>
+{{end}}</ol>{{else}}<p>{{t "no_watchlist_ranking"}}</p>{{end}}
+<p><b>{{t "risk_events"}}</b></p>
+{{if .RiskEvents}}<ul>
+{{range .RiskEvents}}<li>⚠️ {{t (print "risk_event." .Kind)}}{{if .Limit}}, {{t (print "risk_limit." .Limit)}}{{end}}: {{datetime .OccurredAt}}</li>
+{{end}}</ul>{{else}}<p>{{t "no_risk_events"}}</p>{{end}}`,
+}
+
+// Catalogue of the texts of the templates by locale. Keys missing in a locale
+// fall back to English.
+var notificationCatalogue = map[string]map[string]string{
+	constants.NotificationLocaleEnglish: {
+		"trade.subject":           "Trade operation executed",
+		"stop_loss.subject":       "Stop loss triggered",
+		"take_profit.subject":     "Take profit target reached",
+		"position_opened.subject": "New position opened",
+		"kill_switch.subject":     "Trading halted, risk limit breached",
+		"kill_switch.footer":      "Trading stays halted until you resume it.",
+		"trade_proposal.subject":  "Trade awaiting your approval",
+		"alert.subject":           "Alert on",
+		"daily_digest.subject":    "Daily digest",
+		"weekly_digest.subject":   "Weekly digest",
+		"portfolio_value":         "Portfolio value",
+		"fees":                    "Fees",
+		"watchlist_ranking":       "Watchlist ranking",
+		"no_watchlist_ranking":    "No watchlist ranking available.",
+		"risk_events":             "Risk events",
+		"no_risk_events":          "No risk events.",
+		"risk_event." + constants.DigestRiskEventKillSwitch: "Kill switch tripped",
+		"risk_event." + constants.DigestRiskEventRiskOff:    "Market regime turned risk-off",
+		"entry_price":       "Entry price",
+		"profit":            "Profit",
+		"profit_percentage": "Profit percentage",
+		"stop_loss_price":   "Stop loss price",
+		"loss":              "Loss",
+		"loss_percentage":   "Loss percentage",
+		"target_price":      "Target price",
+		"quantity_sold":     "Quantity sold",
+		"quantity_bought":   "Quantity bought",
+		"amount":            "Amount",
+		"limit":             "Limit",
+		"value":             "Value",
+		"threshold":         "Threshold",
+		"proposed_price":    "Proposed price",
+		"expires_at":        "Expires at",
+		"trades":            "Trades",
+		"realised_pnl":      "Realised PnL",
+		"unrealised_pnl":    "Unrealised PnL",
+		"open_positions":    "Open positions",
+		"no_positions":      "No open positions.",
+		"candle_time":       "Candle time",
+		"alert_condition." + constants.AlertConditionAbove: "crossed above",
+		"alert_condition." + constants.AlertConditionBelow: "crossed below",
+		"risk_limit." + constants.RiskLimitDailyLoss:       "Daily loss",
+		"risk_limit." + constants.RiskLimitDrawdown:        "Drawdown",
+		"risk_limit." + constants.RiskLimitTradesPerDay:    "Trades per day",
+		"risk_limit." + constants.RiskLimitSymbolExposure:  "Symbol exposure",
+	},
+	constants.NotificationLocaleSpanish: {
+		"trade.subject":           "Operación ejecutada",
+		"stop_loss.subject":       "Stop loss activado",
+		"take_profit.subject":     "Objetivo de take profit alcanzado",
+		"position_opened.subject": "Nueva posición abierta",
+		"kill_switch.subject":     "Trading detenido, límite de riesgo superado",
+		"kill_switch.footer":      "El trading sigue detenido hasta que lo reanudes.",
+		"trade_proposal.subject":  "Operación pendiente de tu aprobación",
+		"alert.subject":           "Alerta en",
+		"daily_digest.subject":    "Resumen diario",
+		"weekly_digest.subject":   "Resumen semanal",
+		"portfolio_value":         "Valor de la cartera",
+		"fees":                    "Comisiones",
+		"watchlist_ranking":       "Ranking de la watchlist",
+		"no_watchlist_ranking":    "Ranking de la watchlist no disponible.",
+		"risk_events":             "Eventos de riesgo",
+		"no_risk_events":          "Sin eventos de riesgo.",
+		"risk_event." + constants.DigestRiskEventKillSwitch: "Kill switch activado",
+		"risk_event." + constants.DigestRiskEventRiskOff:    "Régimen de mercado en risk-off",
+		"entry_price":       "Precio de entrada",
+		"profit":            "Beneficio",
+		"profit_percentage": "Porcentaje de beneficio",
+		"stop_loss_price":   "Precio de stop loss",
+		"loss":              "Pérdida",
+		"loss_percentage":   "Porcentaje de pérdida",
+		"target_price":      "Precio objetivo",
+		"quantity_sold":     "Cantidad vendida",
+		"quantity_bought":   "Cantidad comprada",
+		"amount":            "Importe",
+		"limit":             "Límite",
+		"value":             "Valor",
+		"threshold":         "Umbral",
+		"proposed_price":    "Precio propuesto",
+		"expires_at":        "Expira",
+		"trades":            "Operaciones",
+		"realised_pnl":      "PnL realizado",
+		"unrealised_pnl":    "PnL no realizado",
+		"open_positions":    "Posiciones abiertas",
+		"no_positions":      "Sin posiciones abiertas.",
+		"candle_time":       "Hora de la vela",
+		"alert_condition." + constants.AlertConditionAbove: "cruzó por encima de",
+		"alert_condition." + constants.AlertConditionBelow: "cruzó por debajo de",
+		"risk_limit." + constants.RiskLimitDailyLoss:       "Pérdida diaria",
+		"risk_limit." + constants.RiskLimitDrawdown:        "Drawdown",
+		"risk_limit." + constants.RiskLimitTradesPerDay:    "Operaciones por día",
+		"risk_limit." + constants.RiskLimitSymbolExposure:  "Exposición al símbolo",
+	},
+}
+
+// Number separators by locale, thousands first
+var notificationSeparators = map[string][2]string{
+	constants.NotificationLocaleEnglish: {",", "."},
+	constants.NotificationLocaleSpanish: {".", ","},
+}
+
+// Format each channel renders notifications in
+var channelFormats = map[string]string{
+	constants.NotificationChannelTelegram: constants.NotificationFormatMarkdown,
+	constants.NotificationChannelEmail:    constants.NotificationFormatHTML,
+	constants.NotificationChannelSlack:    constants.NotificationFormatText,
+	constants.NotificationChannelWebhook:  constants.NotificationFormatText,
+}
@@ -0,0 +1,115 @@
+package trades
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/app/notifications"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
+	"gopkg.in/telebot.v3"
+)
+
+// Structs
+
+type DefaultTradeApprovalHandler struct {
+	tradingService *DefaultTradingService
+	telegramClient *notification.TelegramClient
+}
+
+// Factories
+
+func NewDefaultTradeApprovalHandler(
+	tradingService *DefaultTradingService,
+	telegramClient *notification.TelegramClient,
+) *DefaultTradeApprovalHandler {
+	return &DefaultTradeApprovalHandler{
+		tradingService: tradingService,
+		telegramClient: telegramClient,
+	}
+}
+
+// Register binds the inline buttons of trade proposal messages to the bot.
+func (h *DefaultTradeApprovalHandler) Register() {
+	h.telegramClient.Bot.Handle(&notifications.ApproveTradeButton, h.HandleApprove)
+	h.telegramClient.Bot.Handle(&notifications.RejectTradeButton, h.HandleReject)
+}
+
+// Telegram callback handlers
+
+func (h *DefaultTradeApprovalHandler) HandleApprove(c telebot.Context) error {
+	return h.handleDecision(c, true)
+}
+
+func (h *DefaultTradeApprovalHandler) HandleReject(c telebot.Context) error {
+	return h.handleDecision(c, false)
+}
+
+// handleDecision decides the proposal on behalf of its owner, as long as the
+// button was pressed in the chat the owner gets notifications in. The
+// proposal message is edited with the outcome so it can't be answered twice.
+func (h *DefaultTradeApprovalHandler) handleDecision(
+	c telebot.Context,
+	approve bool,
+) error {
+	ctx, err := newBotContext()
+	if err != nil {
+		return err
+	}
+	logger := config.GetLoggerFromContext(ctx)
+	proposalID, err := uuid.Parse(c.Data())
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: errors.ErrTradeProposalNotFound.Error()})
+	}
+	proposal, err := h.tradingService.TradeProposalService.TradeProposalRepository.GetByID(ctx, proposalID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: errors.ErrTradeProposalNotFound.Error()})
+	}
+	ctx.Set("user", &entities.User{ID: proposal.UserID})
+	recipient, err := h.tradingService.NotificationService.IsTelegramRecipient(ctx, proposal.UserID, c.Sender().ID)
+	if err != nil || !recipient {
+		logger.Warnf("Trade proposal %s answered from chat %d, which is not the owner's", proposal.ID, c.Sender().ID)
+		return c.Respond(&telebot.CallbackResponse{Text: errors.ErrForbidden.Error()})
+	}
+	proposal, err = h.tradingService.DecideTradeProposal(ctx, proposalID, approve)
+	outcome := tradeProposalOutcome(proposal, err)
+	if err != nil {
+		logger.Errorf("Error deciding trade proposal %s: %s", proposalID, err)
+	}
+	if err := c.Edit(outcome); err != nil {
+		logger.Errorf("Error editing trade proposal %s message: %s", proposalID, err)
+	}
+	return c.Respond(&telebot.CallbackResponse{Text: outcome})
+}
+
+// Helpers
+
+// newBotContext builds the context bot updates are handled in, with a request
+// for the exchange calls to run under.
+func newBotContext() (echo.Context, error) {
+	request, err := http.NewRequest(http.MethodPost, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	return echo.New().NewContext(request, nil), nil
+}
+
+func tradeProposalOutcome(proposal *entities.TradeProposal, err error) string {
+	if proposal == nil {
+		return fmt.Sprintf("❗ Trade proposal could not be decided: %s", err)
+	}
+	switch proposal.Status {
+	case constants.TradeProposalStatusApproved:
+		return fmt.Sprintf("✅ Trade approved.\n\n💰 %s >> %s\n- Price: %f", proposal.FromSymbol, proposal.ToSymbol, proposal.DecisionPrice)
+	case constants.TradeProposalStatusRejected:
+		return fmt.Sprintf("❌ Trade rejected.\n\n💰 %s >> %s", proposal.FromSymbol, proposal.ToSymbol)
+	case constants.TradeProposalStatusExpired:
+		return fmt.Sprintf("⌛ Trade proposal expired.\n\n💰 %s >> %s", proposal.FromSymbol, proposal.ToSymbol)
+	}
+	return fmt.Sprintf("❗ Trade not executed: %s\n\n💰 %s >> %s", proposal.Reason, proposal.FromSymbol, proposal.ToSymbol)
+}
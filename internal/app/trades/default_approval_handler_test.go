@@ -0,0 +1,93 @@
+package trades
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+)
+
+// newApprovalCallback is the update telegram sends when a proposal button is
+// pressed in the given chat.
+func newApprovalCallback(chatID int64, data string) telebot.Update {
+	return telebot.Update{
+		Callback: &telebot.Callback{
+			ID:     "callback-1",
+			Sender: &telebot.User{ID: chatID},
+			Message: &telebot.Message{
+				ID:   10,
+				Chat: &telebot.Chat{ID: chatID},
+			},
+			Data: data,
+		},
+	}
+}
+
+func TestHandleRejectDecidesProposalAndEditsMessage(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	standIn := newTelegramStandIn(t)
+	tradingService := newApprovalTradingService(t, map[string]float64{}, standIn)
+	handler := NewDefaultTradeApprovalHandler(tradingService, tradingService.NotificationService.TelegramClient)
+	update := newApprovalCallback(approvalTestChatID, proposal.ID.String())
+
+	// Act
+	err := handler.HandleReject(handler.telegramClient.Bot.NewContext(update))
+
+	// Assert
+	assert.NoError(t, err)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusRejected, stored.Status)
+	assert.Len(t, standIn.Requests("editMessageText"), 1)
+	answers := standIn.Requests("answerCallbackQuery")
+	assert.Len(t, answers, 1)
+	assert.Contains(t, answers[0].Params["text"], "Trade rejected")
+}
+
+func TestHandleApproveRefusesOtherChats(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	standIn := newTelegramStandIn(t)
+	tradingService := newApprovalTradingService(t, map[string]float64{"ETHUSDT": 3000}, standIn)
+	handler := NewDefaultTradeApprovalHandler(tradingService, tradingService.NotificationService.TelegramClient)
+	update := newApprovalCallback(approvalTestChatID+1, proposal.ID.String())
+
+	// Act
+	err := handler.HandleApprove(handler.telegramClient.Bot.NewContext(update))
+
+	// Assert
+	assert.NoError(t, err)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusPending, stored.Status)
+	assert.Empty(t, standIn.Requests("editMessageText"))
+	answers := standIn.Requests("answerCallbackQuery")
+	assert.Len(t, answers, 1)
+	assert.Equal(t, errors.ErrForbidden.Error(), answers[0].Params["text"])
+}
+
+func TestHandleApproveAnswersUnknownProposals(t *testing.T) {
+	// Arrange
+	standIn := newTelegramStandIn(t)
+	tradingService := newApprovalTradingService(t, map[string]float64{}, standIn)
+	handler := NewDefaultTradeApprovalHandler(tradingService, tradingService.NotificationService.TelegramClient)
+	update := newApprovalCallback(approvalTestChatID, uuid.New().String())
+
+	// Act
+	err := handler.HandleApprove(handler.telegramClient.Bot.NewContext(update))
+
+	// Assert
+	assert.NoError(t, err)
+	answers := standIn.Requests("answerCallbackQuery")
+	assert.Len(t, answers, 1)
+	assert.Equal(t, errors.ErrTradeProposalNotFound.Error(), answers[0].Params["text"])
+}
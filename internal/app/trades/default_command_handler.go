@@ -0,0 +1,295 @@
+package trades
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"gopkg.in/telebot.v3"
+)
+
+// Number of orders /history replies with
+const telegramHistoryLength = 10
+
+// Structs
+
+type DefaultTelegramCommandHandler struct {
+	tradingService *DefaultTradingService
+	telegramClient *notification.TelegramClient
+}
+
+// Factories
+
+func NewDefaultTelegramCommandHandler(
+	tradingService *DefaultTradingService,
+	telegramClient *notification.TelegramClient,
+) *DefaultTelegramCommandHandler {
+	return &DefaultTelegramCommandHandler{
+		tradingService: tradingService,
+		telegramClient: telegramClient,
+	}
+}
+
+// Register binds the bot commands. Apart from pairing, commands are only
+// answered in chats paired to a user, and act on behalf of that user.
+func (h *DefaultTelegramCommandHandler) Register() {
+	bot := h.telegramClient.Bot
+	bot.Handle(constants.TelegramCommandStart, h.HandlePair)
+	bot.Handle(constants.TelegramCommandPair, h.HandlePair)
+	bot.Handle(constants.TelegramCommandStatus, h.HandleStatus)
+	bot.Handle(constants.TelegramCommandScores, h.HandleScores)
+	bot.Handle(constants.TelegramCommandPause, h.HandlePause)
+	bot.Handle(constants.TelegramCommandResume, h.HandleResume)
+	bot.Handle(constants.TelegramCommandHistory, h.HandleHistory)
+	bot.Handle(constants.TelegramCommandStopLoss, h.HandleStopLoss)
+}
+
+// Telegram command handlers
+
+// HandlePair pairs the chat with the user that issued the code in the
+// payload. Only private chats can be paired, so nobody else reads the
+// notifications or sends commands in the user name.
+func (h *DefaultTelegramCommandHandler) HandlePair(c telebot.Context) error {
+	code := strings.TrimSpace(c.Message().Payload)
+	if code == "" {
+		return c.Send(fmt.Sprintf(
+			"👋 Send %s <code> with a pairing code from your account to link this chat.",
+			constants.TelegramCommandPair,
+		))
+	}
+	if c.Chat().Type != telebot.ChatPrivate {
+		return c.Send(fmt.Sprintf("❗ %s, only private chats can be paired.", errors.ErrInvalidTelegramChat))
+	}
+	ctx, err := newBotContext()
+	if err != nil {
+		return err
+	}
+	_, err = h.tradingService.NotificationService.PairChat(ctx, code, c.Chat().ID)
+	if err != nil {
+		config.GetLoggerFromContext(ctx).Warnf("Error pairing telegram chat %d: %s", c.Chat().ID, err)
+		return c.Send(fmt.Sprintf("❗ %s.", errors.ErrInvalidPairingCode))
+	}
+	return c.Send("🔗 Chat paired, notifications and commands now go through this chat.")
+}
+
+func (h *DefaultTelegramCommandHandler) HandleStatus(c telebot.Context) error {
+	ctx, userID, err := h.pairedContext(c)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	portfolio, err := h.tradingService.GetPortfolio(ctx, userID)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	tradingPreference := portfolio.TradingPreference
+	message := fmt.Sprintf(
+		"📊 Trading %s (%s book).\n",
+		tradingStatus(tradingPreference),
+		tradingPreference.ExecutionMode,
+	)
+	positions := portfolio.Positions()
+	if len(positions) == 0 {
+		return c.Send(message + "\nNo open holdings.")
+	}
+	for _, holding := range positions {
+		pnl, err := h.tradingService.GetHoldingPnL(ctx, &holding)
+		if err != nil {
+			return c.Send(commandFailure(err))
+		}
+		message += fmt.Sprintf(
+			"\n💰 %s\n"+
+				"- Quantity: %f\n"+
+				"- Market value: %f USDT\n"+
+				"- Unrealised PnL: %f USDT\n",
+			pnl.Symbol,
+			pnl.Quantity,
+			pnl.MarketValue,
+			pnl.UnrealisedPnL,
+		)
+	}
+	return c.Send(message)
+}
+
+func (h *DefaultTelegramCommandHandler) HandleScores(c telebot.Context) error {
+	ctx, userID, err := h.pairedContext(c)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	tradingPreference, err := h.tradingService.TradingPreferenceService.GetByUserID(ctx, userID)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	scores, err := h.tradingService.MarketDataService.GetScores(ctx, tradingPreference.Watchlist)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	if len(scores) == 0 {
+		return c.Send("Your watchlist is empty.")
+	}
+	message := "🏆 Watchlist ranking.\n\n"
+	for i, score := range scores {
+		message += fmt.Sprintf("%d. %s: %f\n", i+1, score.Symbol, score.Score)
+	}
+	return c.Send(message)
+}
+
+func (h *DefaultTelegramCommandHandler) HandlePause(c telebot.Context) error {
+	ctx, userID, err := h.pairedContext(c)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	tradingPreference, err := h.tradingService.TradingPreferenceService.GetByUserID(ctx, userID)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	tradingPreference.Operate = false
+	_, err = h.tradingService.TradingPreferenceService.Update(ctx, tradingPreference)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	return c.Send(fmt.Sprintf("⏸️ Trading paused, send %s to resume it.", constants.TelegramCommandResume))
+}
+
+// HandleResume enables trading again. An engaged kill switch is released too,
+// the same way resuming it from the account does. Users who guard sensitive
+// actions with their second factor pass the code in the payload.
+func (h *DefaultTelegramCommandHandler) HandleResume(c telebot.Context) error {
+	ctx, userID, err := h.pairedContext(c)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	ctx.Set("mfa_code", strings.TrimSpace(c.Message().Payload))
+	tradingPreference, err := h.tradingService.TradingPreferenceService.GetByUserID(ctx, userID)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	if tradingPreference.KillSwitchEngaged() {
+		_, err = h.tradingService.RiskService.ResumeTrading(ctx, userID)
+	} else {
+		tradingPreference.Operate = true
+		_, err = h.tradingService.TradingPreferenceService.Update(ctx, tradingPreference)
+	}
+	if err == errors.ErrMFARequired {
+		return c.Send(fmt.Sprintf("🔐 Send %s followed by your authenticator code.", constants.TelegramCommandResume))
+	}
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	return c.Send("▶️ Trading resumed.")
+}
+
+func (h *DefaultTelegramCommandHandler) HandleHistory(c telebot.Context) error {
+	ctx, userID, err := h.pairedContext(c)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id": userID,
+		},
+		"created_at",
+		"desc",
+		1,
+		100,
+	)
+	orders, err := h.tradingService.OrderService.GetAll(ctx, filters)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	if len(*orders) == 0 {
+		return c.Send("No trades yet.")
+	}
+	history := *orders
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].CreatedAt.After(history[j].CreatedAt)
+	})
+	if len(history) > telegramHistoryLength {
+		history = history[:telegramHistoryLength]
+	}
+	message := "🧾 Recent trades.\n"
+	for _, order := range history {
+		message += fmt.Sprintf(
+			"\n%s %s %s\n- Quantity: %f\n- Price: %f\n",
+			order.CreatedAt.Format("2006-01-02 15:04"),
+			order.TradeType,
+			order.Symbol,
+			order.Quantity,
+			order.Price,
+		)
+	}
+	return c.Send(message)
+}
+
+// HandleStopLoss forces the exit of the open position in the symbol of the
+// payload, e.g. /stoploss BTCUSDT.
+func (h *DefaultTelegramCommandHandler) HandleStopLoss(c telebot.Context) error {
+	ctx, userID, err := h.pairedContext(c)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(c.Message().Payload))
+	if symbol == "" {
+		return c.Send(fmt.Sprintf("Send %s <symbol> to exit a position.", constants.TelegramCommandStopLoss))
+	}
+	portfolio, err := h.tradingService.GetPortfolio(ctx, userID)
+	if err != nil {
+		return c.Send(commandFailure(err))
+	}
+	if !portfolio.TradingPreference.Operate {
+		return c.Send(commandFailure(errors.ErrTradingNotActive))
+	}
+	for _, holding := range portfolio.Positions() {
+		if holding.Symbol != symbol && holding.GetAsset() != symbol {
+			continue
+		}
+		err = h.tradingService.ExecuteStopLoss(ctx, &holding, "spot")
+		if err != nil {
+			config.GetLoggerFromContext(ctx).Errorf("Error forcing the exit of %s: %s", holding.Symbol, err)
+			return c.Send(commandFailure(err))
+		}
+		return c.Send(fmt.Sprintf("🛑 Exited %s.", holding.Symbol))
+	}
+	return c.Send(commandFailure(errors.ErrHoldingNotFound))
+}
+
+// Helpers
+
+// pairedContext builds the context commands run in, on behalf of the user the
+// chat is paired to.
+func (h *DefaultTelegramCommandHandler) pairedContext(
+	c telebot.Context,
+) (echo.Context, uuid.UUID, error) {
+	ctx, err := newBotContext()
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	userID, err := h.tradingService.NotificationService.GetChatUserID(ctx, c.Chat().ID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	ctx.Set("user", &entities.User{ID: userID})
+	return ctx, userID, nil
+}
+
+func tradingStatus(tradingPreference *entities.TradingPreference) string {
+	if tradingPreference.KillSwitchEngaged() {
+		return fmt.Sprintf("halted by the %s kill switch", tradingPreference.KillSwitchReason)
+	}
+	if !tradingPreference.Operate {
+		return "paused"
+	}
+	return "active"
+}
+
+func commandFailure(err error) string {
+	return fmt.Sprintf("❗ %s.", err)
+}
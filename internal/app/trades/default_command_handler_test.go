@@ -0,0 +1,283 @@
+package trades
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/telebot.v3"
+)
+
+// newCommandUpdate is the update telegram sends when the command is written
+// in the given private chat.
+func newCommandUpdate(chatID int64, command string, payload string) telebot.Update {
+	text := command
+	if payload != "" {
+		text += " " + payload
+	}
+	return telebot.Update{
+		Message: &telebot.Message{
+			ID:      20,
+			Sender:  &telebot.User{ID: chatID},
+			Chat:    &telebot.Chat{ID: chatID, Type: telebot.ChatPrivate},
+			Text:    text,
+			Payload: payload,
+		},
+	}
+}
+
+// newCommandTestHandler returns a command handler whose replies reach the
+// stand-in, together with a chat paired to the user.
+func newCommandTestHandler(
+	t *testing.T,
+	userID uuid.UUID,
+	prices map[string]float64,
+	standIn *telegramStandIn,
+) (*DefaultTelegramCommandHandler, int64) {
+	tradingService := newApprovalTradingService(t, prices, standIn)
+	tradingService.RiskService = riskService.(*DefaultRiskService)
+	handler := NewDefaultTelegramCommandHandler(tradingService, tradingService.NotificationService.TelegramClient)
+	chatFactory := &entities.TelegramChatFactory{}
+	chat := chatFactory.NewTelegramChat(userID, rand.Int63())
+	_, err := telegramChatRepository.Create(newApprovalTestContext(userID), chat)
+	assert.NoError(t, err)
+	return handler, chat.ChatID
+}
+
+func lastReply(t *testing.T, standIn *telegramStandIn) string {
+	replies := standIn.Requests("sendMessage")
+	assert.NotEmpty(t, replies)
+	if len(replies) == 0 {
+		return ""
+	}
+	return replies[len(replies)-1].Params["text"].(string)
+}
+
+func TestHandlePairLinksChatOnlyOnce(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	standIn := newTelegramStandIn(t)
+	handler, _ := newCommandTestHandler(t, uuid.New(), map[string]float64{}, standIn)
+	notificationService := handler.tradingService.NotificationService
+	code, _, err := notificationService.CreatePairingCode(ctx, userID)
+	assert.NoError(t, err)
+	assert.Len(t, code, constants.TelegramPairingCodeLength)
+	chatID := rand.Int63()
+
+	// Act
+	err = handler.HandlePair(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandPair, code)))
+	paired := lastReply(t, standIn)
+	reuseErr := handler.HandlePair(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID+1, constants.TelegramCommandPair, code)))
+	reused := lastReply(t, standIn)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, reuseErr)
+	assert.Contains(t, paired, "Chat paired")
+	assert.Contains(t, reused, errors.ErrInvalidPairingCode.Error())
+	pairedUserID, err := notificationService.GetChatUserID(ctx, chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, pairedUserID)
+	_, err = notificationService.GetChatUserID(ctx, chatID+1)
+	assert.Equal(t, errors.ErrTelegramChatNotPaired, err)
+}
+
+func TestHandlePairRefusesExpiredCodes(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	standIn := newTelegramStandIn(t)
+	handler, _ := newCommandTestHandler(t, uuid.New(), map[string]float64{}, standIn)
+	code, pairingCode, err := handler.tradingService.NotificationService.CreatePairingCode(ctx, userID)
+	assert.NoError(t, err)
+	pairingCode.ExpiresAt = time.Now().UTC().Add(-time.Minute)
+	_, err = pairingCodeRepository.Update(ctx, pairingCode)
+	assert.NoError(t, err)
+	chatID := rand.Int63()
+
+	// Act
+	err = handler.HandlePair(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandPair, code)))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, lastReply(t, standIn), errors.ErrInvalidPairingCode.Error())
+	_, err = handler.tradingService.NotificationService.GetChatUserID(ctx, chatID)
+	assert.Equal(t, errors.ErrTelegramChatNotPaired, err)
+}
+
+func TestHandlePairReplacesPreviousChatOfUser(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	standIn := newTelegramStandIn(t)
+	handler, previousChatID := newCommandTestHandler(t, userID, map[string]float64{}, standIn)
+	code, _, err := handler.tradingService.NotificationService.CreatePairingCode(ctx, userID)
+	assert.NoError(t, err)
+	chatID := rand.Int63()
+
+	// Act
+	err = handler.HandlePair(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandPair, code)))
+
+	// Assert
+	assert.NoError(t, err)
+	recipient, err := handler.tradingService.NotificationService.IsTelegramRecipient(ctx, userID, chatID)
+	assert.NoError(t, err)
+	assert.True(t, recipient)
+	_, err = handler.tradingService.NotificationService.GetChatUserID(ctx, previousChatID)
+	assert.Equal(t, errors.ErrTelegramChatNotPaired, err)
+}
+
+func TestHandleStatusAsksUnpairedChatsToPair(t *testing.T) {
+	// Arrange
+	standIn := newTelegramStandIn(t)
+	handler, _ := newCommandTestHandler(t, uuid.New(), map[string]float64{}, standIn)
+
+	// Act
+	err := handler.HandleStatus(handler.telegramClient.Bot.NewContext(newCommandUpdate(rand.Int63(), constants.TelegramCommandStatus, "")))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, lastReply(t, standIn), errors.ErrTelegramChatNotPaired.Error())
+}
+
+func TestHandleStatusReportsOpenHoldings(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	_, err := holdingService.Create(ctx, newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000))
+	assert.NoError(t, err)
+	standIn := newTelegramStandIn(t)
+	handler, chatID := newCommandTestHandler(t, userID, map[string]float64{"BTCUSDT": 65000}, standIn)
+
+	// Act
+	err = handler.HandleStatus(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandStatus, "")))
+
+	// Assert
+	assert.NoError(t, err)
+	reply := lastReply(t, standIn)
+	assert.Contains(t, reply, "Trading active")
+	assert.Contains(t, reply, "BTCUSDT")
+	assert.Contains(t, reply, "Unrealised PnL")
+}
+
+func TestHandlePauseAndResumeToggleOperate(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	standIn := newTelegramStandIn(t)
+	handler, chatID := newCommandTestHandler(t, userID, map[string]float64{}, standIn)
+
+	// Act
+	pauseErr := handler.HandlePause(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandPause, "")))
+	paused, _ := tradingPreferenceService.GetByUserID(ctx, userID)
+	resumeErr := handler.HandleResume(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandResume, "")))
+	resumed, _ := tradingPreferenceService.GetByUserID(ctx, userID)
+
+	// Assert
+	assert.NoError(t, pauseErr)
+	assert.NoError(t, resumeErr)
+	assert.False(t, paused.Operate)
+	assert.True(t, resumed.Operate)
+	assert.Contains(t, lastReply(t, standIn), "Trading resumed")
+}
+
+func TestHandleResumeReleasesKillSwitch(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tp.TripKillSwitch(valueobjects.RiskBreach{Limit: constants.RiskLimitDailyLoss})
+	_, err := tradingPreferenceService.Update(ctx, tp)
+	assert.NoError(t, err)
+	standIn := newTelegramStandIn(t)
+	handler, chatID := newCommandTestHandler(t, userID, map[string]float64{}, standIn)
+
+	// Act
+	err = handler.HandleResume(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandResume, "")))
+
+	// Assert
+	assert.NoError(t, err)
+	resumed, err := tradingPreferenceService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.True(t, resumed.Operate)
+	assert.False(t, resumed.KillSwitchEngaged())
+}
+
+func TestHandleResumeAsksForSecondFactor(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tp.Operate = false
+	_, err := tradingPreferenceService.Update(ctx, tp)
+	assert.NoError(t, err)
+	secondFactorGuard.err = errors.ErrMFARequired
+	t.Cleanup(func() { secondFactorGuard.err = nil })
+	standIn := newTelegramStandIn(t)
+	handler, chatID := newCommandTestHandler(t, userID, map[string]float64{}, standIn)
+
+	// Act
+	err = handler.HandleResume(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandResume, " 123456 ")))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "123456", secondFactorGuard.code)
+	messages := standIn.Requests("sendMessage")
+	assert.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Params["text"], "authenticator code")
+	paused, err := tradingPreferenceService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.False(t, paused.Operate)
+}
+
+func TestHandleHistoryListsMostRecentOrders(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	orderFactory := &entities.OrderFactory{}
+	for i := 0; i < telegramHistoryLength+2; i++ {
+		order := orderFactory.NewOrder(userID, "BTCUSDT", 0.1, 60000, constants.OrderTypeEntry)
+		order.CreatedAt = order.CreatedAt.Add(time.Duration(i) * time.Minute)
+		if i == 0 {
+			order.Symbol = "ADAUSDT"
+		}
+		_, err := orderService.Create(ctx, order)
+		assert.NoError(t, err)
+	}
+	standIn := newTelegramStandIn(t)
+	handler, chatID := newCommandTestHandler(t, userID, map[string]float64{}, standIn)
+
+	// Act
+	err := handler.HandleHistory(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandHistory, "")))
+
+	// Assert
+	assert.NoError(t, err)
+	reply := lastReply(t, standIn)
+	assert.Contains(t, reply, "Recent trades")
+	assert.NotContains(t, reply, "ADAUSDT")
+}
+
+func TestHandleStopLossReturnsErrorIfNoOpenPosition(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	standIn := newTelegramStandIn(t)
+	handler, chatID := newCommandTestHandler(t, userID, map[string]float64{}, standIn)
+
+	// Act
+	err := handler.HandleStopLoss(handler.telegramClient.Bot.NewContext(newCommandUpdate(chatID, constants.TelegramCommandStopLoss, "solusdt")))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, lastReply(t, standIn), errors.ErrHoldingNotFound.Error())
+}
@@ -0,0 +1,72 @@
+package trades
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+)
+
+// Structs
+
+type DefaultDigestHandler struct {
+	tradingService *DefaultTradingService
+	period         time.Duration
+}
+
+// Factories
+
+func NewDefaultDigestHandler(tradingService *DefaultTradingService) *DefaultDigestHandler {
+	return &DefaultDigestHandler{
+		tradingService: tradingService,
+		period:         config.GetConfig().Notifications.DigestPeriod,
+	}
+}
+
+// Receivers
+
+// Loop looks for due digests every period until the process is told to stop.
+// Schedules are checked rather than timed, so digests missed while the
+// process was down go out on the first tick.
+func (h *DefaultDigestHandler) Loop() {
+	logger := config.GetLogger()
+	logger.Info("Starting digest loop...")
+	ticker := time.NewTicker(h.period)
+	defer ticker.Stop()
+	// Graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		select {
+		case now := <-ticker.C:
+			ctx, err := newBotContext()
+			if err != nil {
+				logger.Errorf("Error building digest context: %s", err)
+				continue
+			}
+			ctx.Set("logger", logger)
+			err = h.HandleTick(ctx, now)
+			if err != nil {
+				logger.Errorf("Error sending due digests: %s", err)
+			}
+		case <-sigChan:
+			logger.Info("Digest loop shut down.")
+			return
+		}
+	}
+}
+
+// HandleTick sends the digests due at the given time.
+func (h *DefaultDigestHandler) HandleTick(ctx echo.Context, now time.Time) error {
+	sent, err := h.tradingService.SendDueDigests(ctx, now)
+	if err != nil {
+		return err
+	}
+	if sent > 0 {
+		config.GetLoggerFromContext(ctx).Infof("Sent %d digests.", sent)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package trades
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/app/markets"
+	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// Structs
+
+type DefaultTradingEventHandler struct {
+	tradingService    *DefaultTradingService
+	marketDataService *markets.DefaultMarketDataService
+}
+
+type DefaultTradingEventRegistry struct {
+	handlers map[string]func(ctx echo.Context, event events.MarketDataEvent) error
+}
+
+// Factories
+
+func NewDefaultTradingEventHandler(
+	tradingService *DefaultTradingService,
+	marketDataService *markets.DefaultMarketDataService,
+) *DefaultTradingEventHandler {
+	return &DefaultTradingEventHandler{
+		tradingService:    tradingService,
+		marketDataService: marketDataService,
+	}
+}
+
+func NewDefaultTradingEventRegistry(
+	tradingEventHandler TradingEventHandler,
+) *DefaultTradingEventRegistry {
+	return &DefaultTradingEventRegistry{
+		handlers: map[string]func(ctx echo.Context, event events.MarketDataEvent) error{
+			constants.MarketDataPushedEvent: tradingEventHandler.HandleMarketDataPushed,
+		},
+	}
+}
+
+// Trading event handlers
+
+// HandleMarketDataPushed evaluates the protective exits of every open position
+// in the symbol, on partial candles too, using the ATR of the last stored one.
+// Risk limits and the market regime are applied to the position owners once
+// the candle closes, when their unanswered trade proposals expire too and the
+// free cash of the users watching the symbol is allocated. Events carry no
+// user, so each position is handled on behalf of its owner.
+func (h *DefaultTradingEventHandler) HandleMarketDataPushed(
+	ctx echo.Context,
+	event events.MarketDataEvent,
+) error {
+	logger := config.GetLoggerFromContext(ctx)
+	tradingPositions, err := h.tradingService.GetOpenPositionsForSymbol(ctx, event.Symbol)
+	if err != nil {
+		logger.Errorf("Error getting open positions: %s", err)
+		return err
+	}
+	var atr *float64
+	if len(*tradingPositions) > 0 {
+		latest, err := h.marketDataService.GetLatest(ctx, event.Symbol)
+		if err != nil {
+			logger.Errorf("Error getting latest market data, trailing stops skipped: %s", err)
+		} else {
+			atr = latest.ATR
+		}
+	}
+	for i := range *tradingPositions {
+		tradingPosition := &(*tradingPositions)[i]
+		userCtx := uacs.NewUserContext(ctx, tradingPosition.Holding.UserID)
+		_, err := h.tradingService.EvaluateProtectiveExits(userCtx, tradingPosition, event.Close, atr)
+		if err != nil {
+			// A failing position must not block the others
+			logger.Errorf(
+				"Error evaluating protective exits for holding %s: %s",
+				tradingPosition.Holding.ID,
+				err,
+			)
+		}
+	}
+	if !event.CandleClose {
+		return nil
+	}
+	enforced := make(map[uuid.UUID]bool)
+	for _, tradingPosition := range *tradingPositions {
+		userID := tradingPosition.Holding.UserID
+		if enforced[userID] {
+			continue
+		}
+		enforced[userID] = true
+		userCtx := uacs.NewUserContext(ctx, userID)
+		_, err := h.tradingService.EnforceRiskLimits(userCtx, userID)
+		if err != nil {
+			logger.Errorf("Error enforcing risk limits for user %s: %s", userID, err)
+		}
+		_, err = h.tradingService.ApplyMarketRegime(userCtx, userID, "spot") // TODO: Get wallet type from trading preference
+		if err != nil {
+			logger.Errorf("Error applying market regime for user %s: %s", userID, err)
+		}
+		_, err = h.tradingService.ExpireTradeProposals(userCtx, userID)
+		if err != nil {
+			logger.Errorf("Error expiring trade proposals for user %s: %s", userID, err)
+		}
+	}
+	return h.allocateCapital(ctx, event.Symbol)
+}
+
+// Main event handler
+
+func (r DefaultTradingEventRegistry) HandleEvent(
+	ctx echo.Context,
+	msg []byte,
+) error {
+	logger := config.GetLoggerFromContext(ctx)
+	marketDataEvent := events.MarketDataEvent{}
+	err := json.Unmarshal(msg, &marketDataEvent)
+	if err != nil {
+		return nil
+	}
+	handler, ok := r.handlers[marketDataEvent.Type]
+	if !ok {
+		return nil
+	}
+	logger.Info("Trading event received: %s", marketDataEvent.Type)
+	return handler(ctx, marketDataEvent)
+}
+
+// Helpers
+
+// allocateCapital opens positions with the free cash of every operating user
+// watching the symbol, on behalf of each of them.
+func (h *DefaultTradingEventHandler) allocateCapital(
+	ctx echo.Context,
+	symbol string,
+) error {
+	logger := config.GetLoggerFromContext(ctx)
+	tradingPreferences, err := h.tradingService.TradingPreferenceService.GetOperating(ctx)
+	if err != nil {
+		logger.Errorf("Error getting operating trading preferences: %s", err)
+		return err
+	}
+	for _, tp := range *tradingPreferences {
+		if !lib.SliceContains(tp.Watchlist, symbol) {
+			continue
+		}
+		userCtx := uacs.NewUserContext(ctx, tp.UserID)
+		opened, err := h.tradingService.AllocateCapital(userCtx, tp.UserID, "spot") // TODO: Get wallet type from trading preference
+		if err != nil {
+			logger.Infof("Capital of user %s not allocated: %s", tp.UserID, err)
+			continue
+		}
+		if len(*opened) > 0 {
+			logger.Infof("Opened %d positions for user %s", len(*opened), tp.UserID)
+		}
+	}
+	return nil
+}
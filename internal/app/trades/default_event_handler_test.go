@@ -0,0 +1,185 @@
+package trades
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/app/markets"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/market"
+	"github.com/stretchr/testify/assert"
+)
+
+// --- Trading event handler Tests ---
+
+// newSystemContext builds a context like the events loop does, with a
+// request but no user behind it.
+func newSystemContext() echo.Context {
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	return echo.New().NewContext(request, httptest.NewRecorder())
+}
+
+func newEventHandlerPosition(
+	t *testing.T,
+	userID uuid.UUID,
+	symbol string,
+	entryPrice float64,
+) *entities.Holding {
+	ctx := newApprovalTestContext(userID)
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tp.StopLossPercentage = 5
+	tp.ExecutionMode = constants.ExecutionModePaper
+	_, err := tradingPreferenceService.Update(ctx, tp)
+	assert.NoError(t, err)
+	holding := newLedgerTestHolding(userID, symbol, 2, entryPrice)
+	holding.Book = constants.ExecutionModePaper
+	_, err = holdingService.Create(ctx, holding)
+	assert.NoError(t, err)
+	return holding
+}
+
+func newMarketDataPushedMessage(t *testing.T, symbol string, price float64, candleClose bool) []byte {
+	event := events.MarketDataEvent{
+		BaseEvent:   events.BaseEvent{Type: constants.MarketDataPushedEvent},
+		Symbol:      symbol,
+		Close:       price,
+		CandleClose: candleClose,
+	}
+	msg, err := json.Marshal(event)
+	assert.NoError(t, err)
+	return msg
+}
+
+func TestHandleMarketDataPushedExitsThePositionsOfEveryOwner(t *testing.T) {
+	// Arrange
+	ctx := newSystemContext()
+	first := newEventHandlerPosition(t, uuid.New(), "LINKUSDT", 20)
+	second := newEventHandlerPosition(t, uuid.New(), "LINKUSDT", 20)
+	tradingService := newExecutionModeTradingService()
+	tradingService.ExchangeService = newTickerStandIn(t, map[string]float64{
+		"LINK":     18,
+		"LINKUSDT": 18,
+		"USDT":     1,
+	})
+	registry := NewDefaultTradingEventRegistry(NewDefaultTradingEventHandler(
+		tradingService,
+		markets.NewDefaultMarketDataService(market.NewDefaultMarketDataRepository(database), uacService),
+	))
+
+	// Act
+	err := registry.HandleEvent(ctx, newMarketDataPushedMessage(t, "LINKUSDT", 18, false))
+
+	// Assert
+	assert.NoError(t, err)
+	for _, holding := range []*entities.Holding{first, second} {
+		stored, err := holdingRepository.GetByID(ctx, holding.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, constants.HoldingStatusClosed, stored.Status)
+	}
+}
+
+func TestGetOpenPositionsForSymbolWorksWithoutCaller(t *testing.T) {
+	// Arrange
+	ctx := newSystemContext()
+	first := newEventHandlerPosition(t, uuid.New(), "UNIUSDT", 8)
+	second := newEventHandlerPosition(t, uuid.New(), "UNIUSDT", 8)
+
+	// Act
+	positions, err := newExecutionModeTradingService().GetOpenPositionsForSymbol(ctx, "UNIUSDT")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *positions, 2)
+	owners := make(map[uuid.UUID]bool)
+	for _, position := range *positions {
+		assert.Equal(t, position.Holding.UserID, position.TradingPreference.UserID)
+		owners[position.Holding.UserID] = true
+	}
+	assert.True(t, owners[first.UserID])
+	assert.True(t, owners[second.UserID])
+}
+
+func TestHandleMarketDataPushedAllocatesCapitalOnCandleClose(t *testing.T) {
+	// Arrange
+	ctx := newSystemContext()
+	userID := uuid.New()
+	userCtx := newApprovalTestContext(userID)
+	newRiskTestPreference(t, userCtx, userID, func(tp *entities.TradingPreference) {
+		tp.Watchlist = []string{"ALLOCUSDT"}
+		tp.PositionSizingMethod = constants.PositionSizingMethodFixedFraction
+		tp.PositionFraction = 50
+		tp.MaxConcurrentHoldings = 2
+		tp.RiskOffAction = constants.RiskOffActionNone
+	})
+	newExchangeKey(t, userCtx, userID, constants.ApiKeyServiceTypeBinance)
+	marketDataFactory := &entities.MarketDataFactory{}
+	marketData := marketDataFactory.NewMarketDataFromEvent(uuid.New(), "ALLOCUSDT", time.Now().UTC(), 10, 10, 10, 10, 1)
+	score := 0.9
+	marketData.Score = &score
+	_, err := market.NewDefaultMarketDataRepository(database).Create(ctx, marketData)
+	assert.NoError(t, err)
+	adapter := &stubExchangeAdapter{
+		balances: map[string]float64{constants.LedgerQuoteAsset: 1000},
+		prices:   map[string]float64{"ALLOCUSDT": 10},
+	}
+	tradingService := newDigestTradingService(
+		t,
+		map[string]float64{"ALLOCUSDT": 10, constants.LedgerQuoteAsset: 1000},
+		newTelegramStandIn(t),
+	)
+	tradingService.RiskService = riskService.(*DefaultRiskService)
+	tradingService.TaxLotService = taxLotService.(*DefaultTaxLotService)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: adapter}
+	tradingService.CredentialResolver = credentialResolver
+	registry := NewDefaultTradingEventRegistry(NewDefaultTradingEventHandler(
+		tradingService,
+		tradingService.MarketDataService,
+	))
+
+	// Act
+	err = registry.HandleEvent(ctx, newMarketDataPushedMessage(t, "ALLOCUSDT", 10, true))
+
+	// Assert
+	assert.NoError(t, err)
+	accepted := adapter.Accepted()
+	assert.Len(t, accepted, 1)
+	assert.Equal(t, constants.LedgerQuoteAsset, accepted[0].FromAsset)
+	positions, err := tradingService.GetOpenPositionsForSymbol(ctx, "ALLOCUSDT")
+	assert.NoError(t, err)
+	assert.Len(t, *positions, 1)
+	assert.Equal(t, userID, (*positions)[0].Holding.UserID)
+}
+
+func TestGetOpenPositionsForSymbolOnlyHoldsTheExecutionModeBook(t *testing.T) {
+	// Arrange
+	ctx := newSystemContext()
+	userID := uuid.New()
+	shadow := newEventHandlerPosition(t, userID, "AAVEUSDT", 90)
+	userCtx := newApprovalTestContext(userID)
+	tp, err := tradingPreferenceService.GetByUserID(userCtx, userID)
+	assert.NoError(t, err)
+	tp.ExecutionMode = constants.ExecutionModeShadow
+	_, err = tradingPreferenceService.Update(userCtx, tp)
+	assert.NoError(t, err)
+	shadow.Book = constants.ExecutionModeShadow
+	_, err = holdingService.Update(userCtx, shadow)
+	assert.NoError(t, err)
+	live := newLedgerTestHolding(userID, "AAVEUSDT", 1, 90)
+	_, err = holdingService.Create(userCtx, live)
+	assert.NoError(t, err)
+
+	// Act
+	positions, err := newExecutionModeTradingService().GetOpenPositionsForSymbol(ctx, "AAVEUSDT")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *positions, 1)
+	assert.Equal(t, shadow.ID, (*positions)[0].Holding.ID)
+}
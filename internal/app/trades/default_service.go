@@ -1,21 +1,33 @@
 package trades
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	exchanges "github.com/sergiovirahonda/endurance-api/internal/app/exchange"
+	keys "github.com/sergiovirahonda/endurance-api/internal/app/key"
 	"github.com/sergiovirahonda/endurance-api/internal/app/markets"
 	"github.com/sergiovirahonda/endurance-api/internal/app/notifications"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
+	"github.com/sergiovirahonda/endurance-api/internal/app/users"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/aggregate"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/trade"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
 	"github.com/sergiovirahonda/endurance-api/internal/lib"
 )
 
@@ -25,7 +37,14 @@ type DefaultTradingService struct {
 	TradingPreferenceService *DefaultTradingPreferenceService
 	HoldingService           *DefaultHoldingService
 	OrderService             *DefaultOrderService
+	LedgerService            *DefaultLedgerService
+	TaxLotService            *DefaultTaxLotService
+	RiskService              *DefaultRiskService
+	TradeProposalService     *DefaultTradeProposalService
+	MarketRegimeService      *markets.DefaultMarketRegimeService
 	ExchangeService          *exchanges.DefaultExchangeService
+	ExchangeAdapterFactory   exchanges.ExchangeAdapterFactory
+	CredentialResolver       keys.CredentialResolver
 	NotificationService      *notifications.DefaultNotificationService
 	MarketDataService        *markets.DefaultMarketDataService
 	UacService               uacs.UacService
@@ -33,6 +52,7 @@ type DefaultTradingService struct {
 
 type DefaultTradingPreferenceService struct {
 	TradingPreferenceRepository trade.TradingPreferenceRepository
+	SecondFactorGuard           users.SecondFactorGuard
 	UacService                  uacs.UacService
 }
 
@@ -46,13 +66,45 @@ type DefaultOrderService struct {
 	UacService      uacs.UacService
 }
 
+type DefaultLedgerService struct {
+	LedgerEntryRepository trade.LedgerEntryRepository
+	UacService            uacs.UacService
+}
+
+type DefaultTaxLotService struct {
+	TaxLotRepository         trade.TaxLotRepository
+	TaxLotDisposalRepository trade.TaxLotDisposalRepository
+	UacService               uacs.UacService
+}
+
+type DefaultTradeProposalService struct {
+	TradeProposalRepository trade.TradeProposalRepository
+	UacService              uacs.UacService
+}
+
+type DefaultRiskService struct {
+	TradingPreferenceService *DefaultTradingPreferenceService
+	OrderService             *DefaultOrderService
+	LedgerService            *DefaultLedgerService
+	NotificationService      *notifications.DefaultNotificationService
+	EventsPubSub             *pubsub.EventsPubSub
+	UacService               uacs.UacService
+}
+
 // Factories
 
 func NewDefaultTradingService(
 	tradingPreferenceService *DefaultTradingPreferenceService,
 	holdingService *DefaultHoldingService,
 	orderService *DefaultOrderService,
+	ledgerService *DefaultLedgerService,
+	taxLotService *DefaultTaxLotService,
+	riskService *DefaultRiskService,
+	tradeProposalService *DefaultTradeProposalService,
+	marketRegimeService *markets.DefaultMarketRegimeService,
 	exchangeService *exchanges.DefaultExchangeService,
+	exchangeAdapterFactory exchanges.ExchangeAdapterFactory,
+	credentialResolver keys.CredentialResolver,
 	notificationService *notifications.DefaultNotificationService,
 	uacService uacs.UacService,
 ) *DefaultTradingService {
@@ -60,7 +112,14 @@ func NewDefaultTradingService(
 		TradingPreferenceService: tradingPreferenceService,
 		HoldingService:           holdingService,
 		OrderService:             orderService,
+		LedgerService:            ledgerService,
+		TaxLotService:            taxLotService,
+		RiskService:              riskService,
+		TradeProposalService:     tradeProposalService,
+		MarketRegimeService:      marketRegimeService,
 		ExchangeService:          exchangeService,
+		ExchangeAdapterFactory:   exchangeAdapterFactory,
+		CredentialResolver:       credentialResolver,
 		NotificationService:      notificationService,
 		UacService:               uacService,
 	}
@@ -68,10 +127,12 @@ func NewDefaultTradingService(
 
 func NewDefaultTradingPreferenceService(
 	tradingPreferenceRepository trade.TradingPreferenceRepository,
+	secondFactorGuard users.SecondFactorGuard,
 	uacService uacs.UacService,
 ) *DefaultTradingPreferenceService {
 	return &DefaultTradingPreferenceService{
 		TradingPreferenceRepository: tradingPreferenceRepository,
+		SecondFactorGuard:           secondFactorGuard,
 		UacService:                  uacService,
 	}
 }
@@ -96,6 +157,56 @@ func NewDefaultOrderService(
 	}
 }
 
+func NewDefaultLedgerService(
+	ledgerEntryRepository trade.LedgerEntryRepository,
+	uacService uacs.UacService,
+) *DefaultLedgerService {
+	return &DefaultLedgerService{
+		LedgerEntryRepository: ledgerEntryRepository,
+		UacService:            uacService,
+	}
+}
+
+func NewDefaultTaxLotService(
+	taxLotRepository trade.TaxLotRepository,
+	taxLotDisposalRepository trade.TaxLotDisposalRepository,
+	uacService uacs.UacService,
+) *DefaultTaxLotService {
+	return &DefaultTaxLotService{
+		TaxLotRepository:         taxLotRepository,
+		TaxLotDisposalRepository: taxLotDisposalRepository,
+		UacService:               uacService,
+	}
+}
+
+func NewDefaultTradeProposalService(
+	tradeProposalRepository trade.TradeProposalRepository,
+	uacService uacs.UacService,
+) *DefaultTradeProposalService {
+	return &DefaultTradeProposalService{
+		TradeProposalRepository: tradeProposalRepository,
+		UacService:              uacService,
+	}
+}
+
+func NewDefaultRiskService(
+	tradingPreferenceService *DefaultTradingPreferenceService,
+	orderService *DefaultOrderService,
+	ledgerService *DefaultLedgerService,
+	notificationService *notifications.DefaultNotificationService,
+	eventsPubSub *pubsub.EventsPubSub,
+	uacService uacs.UacService,
+) *DefaultRiskService {
+	return &DefaultRiskService{
+		TradingPreferenceService: tradingPreferenceService,
+		OrderService:             orderService,
+		LedgerService:            ledgerService,
+		NotificationService:      notificationService,
+		EventsPubSub:             eventsPubSub,
+		UacService:               uacService,
+	}
+}
+
 // Receivers
 
 // Trading Service
@@ -120,11 +231,25 @@ func (s *DefaultTradingService) PullBackTrade(
 	if err != nil {
 		return err
 	}
-	if scores[0].Symbol == tradingPosition.Holding.Symbol {
+	portfolio, err := s.GetPortfolio(ctx, tradingPosition.Holding.UserID)
+	if err != nil {
+		return err
+	}
+	// Rotate into the best performing asset not held by another position
+	attractiveSymbol := ""
+	for _, score := range scores {
+		if score.Symbol == tradingPosition.Holding.Symbol {
+			break
+		}
+		if !portfolio.Holds(score.Symbol) {
+			attractiveSymbol = score.Symbol
+			break
+		}
+	}
+	if attractiveSymbol == "" {
 		logger.Info("Current holding is the best performing asset in the watchlist")
 		return nil
 	}
-	attractiveSymbol := scores[0].Symbol
 	attractiveMarketData, err := s.MarketDataService.GetLatest(ctx, attractiveSymbol)
 	if err != nil {
 		return err
@@ -145,6 +270,16 @@ func (s *DefaultTradingService) PullBackTrade(
 		}
 		return nil
 	}
+	// Leave the rotation to the user when they asked to approve trades
+	if tradingPosition.TradingPreference.RequireApproval {
+		_, err := s.ProposeTrade(
+			ctx,
+			tradingPosition.Holding,
+			attractiveSymbol,
+			"spot", // TODO: Get wallet type from trading preference
+		)
+		return err
+	}
 	// Execute trade
 	s.ExecuteTrade(
 		ctx,
@@ -156,6 +291,172 @@ func (s *DefaultTradingService) PullBackTrade(
 	return nil
 }
 
+// ProposeTrade stores a proposal to rotate the holding into the symbol and
+// asks the user to approve it. Holdings with a proposal still awaiting
+// approval get no new one.
+func (s *DefaultTradingService) ProposeTrade(
+	ctx echo.Context,
+	holding *entities.Holding,
+	toSymbol string,
+	walletType string,
+) (*entities.TradeProposal, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	tradingPreference, err := s.TradingPreferenceService.GetByUserID(ctx, holding.UserID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.TradeProposalService.GetPending(ctx, holding.UserID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for i := range *pending {
+		proposal := &(*pending)[i]
+		if proposal.HoldingID == holding.ID && !proposal.IsExpired(now) {
+			logger.Infof("Trade proposal %s for %s still awaiting approval", proposal.ID, holding.Symbol)
+			return proposal, nil
+		}
+	}
+	book := executionBook(tradingPreference, holding)
+	ticker, err := s.getTicker(ctx, book, holding.UserID, toSymbol)
+	if err != nil {
+		return nil, err
+	}
+	proposalFactory := entities.TradeProposalFactory{}
+	proposal := proposalFactory.NewTradeProposal(
+		holding.UserID,
+		holding.ID,
+		holding.Symbol,
+		toSymbol,
+		ticker.Price,
+		walletType,
+		time.Duration(tradingPreference.ApprovalTimeoutMinutes)*time.Minute,
+	)
+	_, err = s.TradeProposalService.Create(ctx, proposal)
+	if err != nil {
+		return nil, err
+	}
+	err = s.NotificationService.SendTradeProposalNotification(ctx, proposal)
+	if err != nil {
+		// The proposal expires unanswered
+		logger.Errorf("Error sending trade proposal %s: %s", proposal.ID, err)
+	}
+	return proposal, nil
+}
+
+// DecideTradeProposal approves or rejects a pending proposal. Approved ones
+// are executed only if the price did not drift further than the preference
+// allows since they were proposed. Every outcome is stored on the proposal.
+func (s *DefaultTradingService) DecideTradeProposal(
+	ctx echo.Context,
+	proposalID uuid.UUID,
+	approve bool,
+) (*entities.TradeProposal, error) {
+	proposal, err := s.TradeProposalService.GetByID(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if !proposal.IsPending() {
+		return proposal, errors.ErrTradeProposalDecided
+	}
+	// Concurrent decisions race for the claim, only the winner goes on
+	err = s.TradeProposalService.Claim(ctx, proposal)
+	if err != nil {
+		return proposal, err
+	}
+	if proposal.IsExpired(time.Now().UTC()) {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusExpired, 0, errors.ErrTradeProposalExpired)
+	}
+	if !approve {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusRejected, 0, nil)
+	}
+	tradingPreference, err := s.TradingPreferenceService.GetByUserID(ctx, proposal.UserID)
+	if err != nil {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, 0, err)
+	}
+	if !tradingPreference.Operate {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, 0, errors.ErrTradingNotActive)
+	}
+	holding, err := s.HoldingService.GetByID(ctx, proposal.HoldingID)
+	if err != nil {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, 0, err)
+	}
+	if holding.Status != constants.HoldingStatusOpen {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, 0, errors.ErrHoldingNotFound)
+	}
+	book := executionBook(tradingPreference, holding)
+	ticker, err := s.getTicker(ctx, book, proposal.UserID, proposal.ToSymbol)
+	if err != nil {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, 0, err)
+	}
+	if proposal.PriceDrift(ticker.Price) > tradingPreference.MaxApprovalPriceDrift {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, ticker.Price, errors.ErrTradeProposalPriceDrift)
+	}
+	marketData, err := s.MarketDataService.GetLatest(ctx, proposal.ToSymbol)
+	if err != nil {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, ticker.Price, err)
+	}
+	err = s.ExecuteTrade(ctx, holding, proposal.ToSymbol, marketData, proposal.WalletType)
+	if err != nil {
+		return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusFailed, ticker.Price, err)
+	}
+	return s.closeTradeProposal(ctx, proposal, constants.TradeProposalStatusApproved, ticker.Price, nil)
+}
+
+// ExpireTradeProposals closes the pending proposals of the user whose
+// approval window is over.
+func (s *DefaultTradingService) ExpireTradeProposals(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.TradeProposals, error) {
+	pending, err := s.TradeProposalService.GetPending(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	expired := make(entities.TradeProposals, 0)
+	for _, proposal := range *pending {
+		if !proposal.IsExpired(now) {
+			continue
+		}
+		err := s.TradeProposalService.Claim(ctx, &proposal)
+		if err == errors.ErrTradeProposalDecided {
+			// A decision got to it first
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.closeTradeProposal(ctx, &proposal, constants.TradeProposalStatusExpired, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, proposal)
+	}
+	return &expired, nil
+}
+
+// closeTradeProposal stores the decision on the proposal, with the reason
+// behind it when there is one, and returns that reason as the error.
+func (s *DefaultTradingService) closeTradeProposal(
+	ctx echo.Context,
+	proposal *entities.TradeProposal,
+	status string,
+	price float64,
+	reason error,
+) (*entities.TradeProposal, error) {
+	message := ""
+	if reason != nil {
+		message = reason.Error()
+	}
+	proposal.Decide(status, price, message)
+	_, err := s.TradeProposalService.Update(ctx, proposal)
+	if err != nil {
+		return nil, err
+	}
+	return proposal, reason
+}
+
 func (s *DefaultTradingService) IsAttractiveSymbol(
 	ctx echo.Context,
 	tradingPosition *aggregate.TradingPositionAggregate,
@@ -190,8 +491,14 @@ func (s *DefaultTradingService) PullBackTradeSignal(
 	if err != nil {
 		return "", err
 	}
-	profit := (holdingTicker.Price - tradingPosition.Holding.EntryPrice) * tradingPosition.Holding.Quantity
-	profitPercentage := profit / (holdingTicker.Price * tradingPosition.Holding.Quantity) * 100
+	pnl, err := s.LedgerService.GetHoldingPnL(ctx, tradingPosition.Holding, holdingTicker.Price)
+	if err != nil {
+		return "", err
+	}
+	profitPercentage := 0.0
+	if pnl.CostBasis > 0 {
+		profitPercentage = pnl.UnrealisedPnL / pnl.CostBasis * 100
+	}
 	if holdingCurrentScore.Score >= tradingPosition.Holding.EntryScore {
 		return constants.PullBackTradeSignalHold, nil
 	}
@@ -211,56 +518,38 @@ func (s *DefaultTradingService) PullBackTradeSignal(
 	return constants.PullBackTradeSignalHold, nil
 }
 
+// GetOpenPositionsForSymbol returns the open positions every operating user
+// has in the symbol, in the book of their execution mode. It runs on behalf of
+// the system, with no caller to scope the positions to.
 func (s *DefaultTradingService) GetOpenPositionsForSymbol(
 	ctx echo.Context,
 	symbol string,
 ) (*aggregate.TradingPositionAggregates, error) {
-	if err := s.UacService.IsRegularUser(ctx); err != nil {
-		return nil, err
-	}
-	tradingPreferencesFilters := filtering.NewComplexFilter(
-		ctx,
-		map[string]interface{}{
-			"operate": true,
-		},
-		"created_at",
-		"desc",
-		1,
-		10000,
-	)
-	tradingPreferences, err := s.TradingPreferenceService.GetAll(ctx, tradingPreferencesFilters)
+	tradingPreferences, err := s.TradingPreferenceService.GetOperating(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if len(*tradingPreferences) == 0 {
+		return &aggregate.TradingPositionAggregates{}, nil
+	}
 	userIDs := make([]uuid.UUID, len(*tradingPreferences))
 	for i, tp := range *tradingPreferences {
 		userIDs[i] = tp.UserID
 	}
-	holdingFilters := filtering.NewComplexFilter(
-		ctx,
-		map[string]interface{}{
-			"symbol":      symbol,
-			"status":      constants.HoldingStatusOpen,
-			"user_id__in": userIDs,
-		},
-		"created_at",
-		"desc",
-		1,
-		10000,
-	)
-	holdings, err := s.HoldingService.GetAll(ctx, holdingFilters)
+	holdings, err := s.HoldingService.GetOpenBySymbol(ctx, symbol, userIDs)
 	if err != nil {
 		return nil, err
 	}
-	tradingPositionAggregates := make(aggregate.TradingPositionAggregates, len(*holdings))
+	tradingPositionAggregates := make(aggregate.TradingPositionAggregates, 0, len(*holdings))
 	// TODO: Optimize this
-	for i, holding := range *holdings {
+	for _, holding := range *holdings {
 		for _, tp := range *tradingPreferences {
-			if tp.UserID == holding.UserID {
-				tradingPositionAggregates[i] = aggregate.TradingPositionAggregate{
+			// Each user only trades the book of their execution mode
+			if tp.UserID == holding.UserID && tp.ExecutionMode == holding.Book {
+				tradingPositionAggregates = append(tradingPositionAggregates, aggregate.TradingPositionAggregate{
 					Holding:           &holding,
 					TradingPreference: &tp,
-				}
+				})
 			}
 		}
 	}
@@ -284,32 +573,63 @@ func (s *DefaultTradingService) ExecuteTrade(
 		logger.Info("Trading preference is not active")
 		return nil
 	}
-	balance, err := s.ExchangeService.GetBalance(ctx, holding.GetAsset())
+	frozen, err := s.EntriesFrozen(ctx, tradingPreference)
+	if err != nil {
+		return err
+	}
+	if frozen {
+		return errors.ErrMarketRegimeRiskOff
+	}
+	breach, err := s.EnforceRiskLimits(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if breach != nil {
+		return errors.ErrRiskLimitBreached
+	}
+	book := executionBook(tradingPreference, holding)
+	holding, parallel := holdingInBook(holding, book)
+	available, err := s.availableQuantity(ctx, holding)
 	if err != nil {
 		return err
 	}
 	newAssetSymbol := fmt.Sprintf("%s%s", toAsset, "USDT")
-	toTicker, err := s.ExchangeService.GetTicker(ctx, newAssetSymbol)
+	toTicker, err := s.getTicker(ctx, book, user.ID, newAssetSymbol)
 	if err != nil {
 		return err
 	}
-	fromTicker, err := s.ExchangeService.GetTicker(ctx, holding.GetAsset())
+	fromTicker, err := s.getAssetTicker(ctx, book, user.ID, holding.GetAsset())
+	if err != nil {
+		return err
+	}
+	amount, err := s.prepareOrderQuantity(
+		ctx,
+		book,
+		user.ID,
+		holding.Symbol,
+		available,
+		fromTicker.Price,
+	)
 	if err != nil {
 		return err
 	}
 	orderFactory := entities.OrderFactory{}
-	conversionQuote, err := s.ExchangeService.GetConversionQuote(
+	conversionQuote, err := s.getConversionQuote(
 		ctx,
+		book,
+		user.ID,
 		holding.GetAsset(),
 		toAsset,
-		balance.Free,
+		amount,
+		fromTicker.Price,
+		toTicker.Price,
 		walletType,
 	)
 	if err != nil {
 		return err
 	}
 	err = conversionQuote.ValidateConversionDrift(
-		fromTicker.Price*balance.Free,
+		fromTicker.Price*amount,
 		toTicker.Price,
 	)
 	if err != nil {
@@ -322,19 +642,28 @@ func (s *DefaultTradingService) ExecuteTrade(
 		conversionQuote.ToAmount*toTicker.Price,
 		constants.OrderTypeTakeProfit,
 	)
+	order.Book = book
 	_, err = s.OrderService.Create(ctx, order)
 	if err != nil {
 		return err
 	}
-	_, err = s.ExchangeService.AcceptConversionQuote(ctx, conversionQuote.ID)
+	converted, err := s.acceptConversionQuote(ctx, book, user.ID, conversionQuote)
 	if err != nil {
 		return err
 	}
-	holding.ExitPrice = fromTicker.Price
-	profit := (holding.ExitPrice - holding.EntryPrice) * holding.Quantity
-	holding.Status = constants.HoldingStatusClosed
-	holding.Profit = profit
-	s.HoldingService.Update(ctx, holding)
+	if converted != nil && converted.IsPartial() {
+		s.bookPartialConversion(
+			ctx,
+			holding,
+			parallel,
+			order,
+			converted.ExecutedQuote(conversionQuote),
+			fromTicker.Price,
+			book,
+			tradingPreference.CostBasisMethod,
+		)
+		return errors.ErrConversionIncomplete
+	}
 	holdingFactory := entities.HoldingFactory{}
 	newHolding := holdingFactory.NewHolding(
 		user.ID,
@@ -346,19 +675,38 @@ func (s *DefaultTradingService) ExecuteTrade(
 		*toMarketData.Score,
 		constants.HoldingStatusOpen,
 	)
+	newHolding.Book = book
+	ledgerEntryFactory := entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		user.ID,
+		holding.ID,
+		newHolding.ID,
+		order.ID,
+		conversionQuote,
+		fromTicker.Price,
+		toTicker.Price,
+	)
+	s.bookLedgerEntry(ctx, entry, holding, book, tradingPreference.CostBasisMethod)
+	holding.ExitPrice = entry.ExecutedPrice
+	holding.Status = constants.HoldingStatusClosed
+	holding.Profit = entry.RealisedPnL
+	s.saveHolding(ctx, holding, parallel)
+	newHolding.EntryPrice = entry.UnitCost()
 	s.HoldingService.Create(ctx, newHolding)
 	order.Status = constants.OrderStatusFilled
 	s.OrderService.Update(ctx, order)
-	profit = (holding.ExitPrice * holding.Quantity) - (holding.ExitPrice * holding.Quantity)
-	profitPercentage := profit / (holding.ExitPrice * holding.Quantity) * 100
+	if book != constants.ExecutionModeLive {
+		logger.Infof("Trade from %s to %s booked in the %s book", holding.Symbol, newAssetSymbol, book)
+		return nil
+	}
 	// Send notification
 	s.NotificationService.SendTradeNotification(
 		ctx,
 		holding.Symbol,
 		newAssetSymbol,
 		fromTicker.Price,
-		profit,
-		profitPercentage,
+		entry.RealisedPnL,
+		entry.RealisedPnLPercentage(),
 	)
 	return nil
 }
@@ -378,24 +726,41 @@ func (s *DefaultTradingService) ExecuteStopLoss(
 		logger.Info("Trading preference is not active")
 		return nil
 	}
-	balance, err := s.ExchangeService.GetBalance(ctx, holding.GetAsset())
+	book := executionBook(tradingPreference, holding)
+	holding, parallel := holdingInBook(holding, book)
+	available, err := s.availableQuantity(ctx, holding)
 	if err != nil {
 		return err
 	}
-	fromTicker, err := s.ExchangeService.GetTicker(ctx, holding.GetAsset())
+	fromTicker, err := s.getAssetTicker(ctx, book, user.ID, holding.GetAsset())
 	if err != nil {
 		return err
 	}
-	toTicker, err := s.ExchangeService.GetTicker(ctx, "USDT")
+	toTicker, err := s.getAssetTicker(ctx, book, user.ID, constants.LedgerQuoteAsset)
+	if err != nil {
+		return err
+	}
+	amount, err := s.prepareOrderQuantity(
+		ctx,
+		book,
+		user.ID,
+		holding.Symbol,
+		available,
+		fromTicker.Price,
+	)
 	if err != nil {
 		return err
 	}
 	orderFactory := entities.OrderFactory{}
-	conversionQuote, err := s.ExchangeService.GetConversionQuote(
+	conversionQuote, err := s.getConversionQuote(
 		ctx,
+		book,
+		user.ID,
 		holding.GetAsset(),
 		"USDT",
-		balance.Free,
+		amount,
+		fromTicker.Price,
+		toTicker.Price,
 		walletType,
 	)
 	if err != nil {
@@ -408,19 +773,15 @@ func (s *DefaultTradingService) ExecuteStopLoss(
 		conversionQuote.ToAmount*toTicker.Price,
 		constants.OrderTypeStopLoss,
 	)
+	order.Book = book
 	_, err = s.OrderService.Create(ctx, order)
 	if err != nil {
 		return err
 	}
-	_, err = s.ExchangeService.AcceptConversionQuote(ctx, conversionQuote.ID)
+	_, err = s.acceptConversionQuote(ctx, book, user.ID, conversionQuote)
 	if err != nil {
 		return err
 	}
-	holding.ExitPrice = fromTicker.Price
-	profit := (holding.ExitPrice * holding.Quantity) - (holding.EntryPrice * holding.Quantity)
-	holding.Status = constants.HoldingStatusClosed
-	holding.Profit = profit
-	s.HoldingService.Update(ctx, holding)
 	holdingFactory := entities.HoldingFactory{}
 	newHolding := holdingFactory.NewHolding(
 		user.ID,
@@ -432,89 +793,1225 @@ func (s *DefaultTradingService) ExecuteStopLoss(
 		holding.EntryScore,
 		constants.HoldingStatusOpen,
 	)
+	newHolding.Book = book
+	ledgerEntryFactory := entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		user.ID,
+		holding.ID,
+		newHolding.ID,
+		order.ID,
+		conversionQuote,
+		fromTicker.Price,
+		toTicker.Price,
+	)
+	s.bookLedgerEntry(ctx, entry, holding, book, tradingPreference.CostBasisMethod)
+	holding.ExitPrice = entry.ExecutedPrice
+	holding.Status = constants.HoldingStatusClosed
+	holding.Profit = entry.RealisedPnL
+	s.saveHolding(ctx, holding, parallel)
 	s.HoldingService.Create(ctx, newHolding)
 	order.Status = constants.OrderStatusFilled
 	s.OrderService.Update(ctx, order)
-	profit = (holding.ExitPrice * holding.Quantity) - (holding.ExitPrice * holding.Quantity)
-	profitPercentage := profit / (holding.ExitPrice * holding.Quantity) * 100
+	if book != constants.ExecutionModeLive {
+		logger.Infof("Stop loss of %s booked in the %s book", holding.Symbol, book)
+		return nil
+	}
 	// Send notification
 	s.NotificationService.SendStopLossNotification(
 		ctx,
 		holding.Symbol,
 		fromTicker.Price,
-		profit,
-		profitPercentage,
+		entry.RealisedPnL,
+		entry.RealisedPnLPercentage(),
 	)
 	return nil
 }
 
-// Trading Preference Service
-
-func (s *DefaultTradingPreferenceService) GetByUserID(
+// ProtectiveExitSignal raises the high-water mark of the holding with the price
+// and evaluates its protective exits, without executing them.
+func (s *DefaultTradingService) ProtectiveExitSignal(
 	ctx echo.Context,
-	id uuid.UUID,
-) (*entities.TradingPreference, error) {
-	tp, err := s.TradingPreferenceRepository.GetByUserID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	if err := s.UacService.IsResourceOwner(ctx, tp.UserID); err != nil {
-		return nil, err
+	tradingPosition *aggregate.TradingPositionAggregate,
+	price float64,
+	atr *float64,
+) (valueobjects.ProtectiveExitSignal, error) {
+	if tradingPosition.Holding.RecordPrice(price) {
+		_, err := s.HoldingService.Update(ctx, tradingPosition.Holding)
+		if err != nil {
+			return valueobjects.ProtectiveExitSignal{}, err
+		}
 	}
-	return tp, nil
+	return tradingPosition.ProtectiveExitSignal(price, atr), nil
 }
 
-func (s *DefaultTradingPreferenceService) GetByID(
+// EvaluateProtectiveExits runs on every price update of the holding symbol
+// and executes the stop or take profit that the price triggered.
+func (s *DefaultTradingService) EvaluateProtectiveExits(
 	ctx echo.Context,
-	id uuid.UUID,
-) (*entities.TradingPreference, error) {
-	tp, err := s.TradingPreferenceRepository.GetByID(ctx, id)
+	tradingPosition *aggregate.TradingPositionAggregate,
+	price float64,
+	atr *float64,
+) (valueobjects.ProtectiveExitSignal, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	signal, err := s.ProtectiveExitSignal(ctx, tradingPosition, price, atr)
 	if err != nil {
-		return nil, err
-	}
-	if err := s.UacService.IsResourceOwner(ctx, tp.UserID); err != nil {
-		return nil, err
-	}
-	return tp, nil
-}
-
-func (s *DefaultTradingPreferenceService) GetAll(
-	ctx echo.Context,
-	filters filtering.ComplexFilters,
-) (*entities.TradingPreferences, error) {
-	filters.SetMetaParameters()
-	filters.NarrowUserFilters("user_id")
-	return s.TradingPreferenceRepository.GetAll(ctx, filters)
+		return signal, err
+	}
+	switch signal.Action {
+	case constants.ProtectiveExitActionStopLoss, constants.ProtectiveExitActionTrailingStop:
+		logger.Infof(
+			"Protective %s triggered at %f for holding %s",
+			signal.Action,
+			signal.TriggerPrice,
+			tradingPosition.Holding.ID,
+		)
+		err = s.ExecuteStopLoss(
+			ctx,
+			tradingPosition.Holding,
+			"spot", // TODO: Get wallet type from trading preference
+		)
+	case constants.ProtectiveExitActionTakeProfit:
+		err = s.ExecuteTakeProfit(
+			ctx,
+			tradingPosition.Holding,
+			signal,
+			"spot", // TODO: Get wallet type from trading preference
+		)
+	}
+	return signal, err
 }
 
-func (s *DefaultTradingPreferenceService) Create(
+// ExecuteTakeProfit sells part of the holding into USDT and moves it along
+// its take profit ladder. The holding is closed once nothing is left.
+func (s *DefaultTradingService) ExecuteTakeProfit(
 	ctx echo.Context,
-	tp *entities.TradingPreference,
-) (*entities.TradingPreference, error) {
-	_, err := s.GetByUserID(ctx, tp.UserID)
-	if err == nil {
-		return nil, errors.ErrTradingPreferenceAlreadyExists
+	holding *entities.Holding,
+	signal valueobjects.ProtectiveExitSignal,
+	walletType string,
+) error {
+	logger := config.GetLoggerFromContext(ctx)
+	user := s.UacService.GetUser(ctx)
+	tradingPreference, err := s.TradingPreferenceService.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return err
 	}
-	if err := s.UacService.IsResourceOwner(ctx, tp.UserID); err != nil {
-		return nil, err
+	if !tradingPreference.Operate {
+		logger.Info("Trading preference is not active")
+		return nil
 	}
-	err = tp.Validate()
+	book := executionBook(tradingPreference, holding)
+	holding, parallel := holdingInBook(holding, book)
+	available, err := s.availableQuantity(ctx, holding)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return s.TradingPreferenceRepository.Create(ctx, tp)
-}
-
-func (s *DefaultTradingPreferenceService) Update(
-	ctx echo.Context,
-	entity *entities.TradingPreference,
-) (*entities.TradingPreference, error) {
-	_, err := s.GetByID(ctx, entity.ID)
+	fromTicker, err := s.getTicker(ctx, book, user.ID, holding.Symbol)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err := s.UacService.IsResourceOwner(ctx, entity.UserID); err != nil {
-		return nil, err
+	amount, err := s.prepareOrderQuantity(
+		ctx,
+		book,
+		user.ID,
+		holding.Symbol,
+		math.Min(signal.Quantity, available),
+		fromTicker.Price,
+	)
+	if err != nil {
+		return err
+	}
+	conversionQuote, err := s.getConversionQuote(
+		ctx,
+		book,
+		user.ID,
+		holding.GetAsset(),
+		constants.LedgerQuoteAsset,
+		amount,
+		fromTicker.Price,
+		1,
+		walletType,
+	)
+	if err != nil {
+		return err
+	}
+	orderFactory := entities.OrderFactory{}
+	order := orderFactory.NewOrder(
+		user.ID,
+		holding.Symbol,
+		amount,
+		fromTicker.Price,
+		constants.OrderTypeTakeProfit,
+	)
+	order.Book = book
+	_, err = s.OrderService.Create(ctx, order)
+	if err != nil {
+		return err
+	}
+	_, err = s.acceptConversionQuote(ctx, book, user.ID, conversionQuote)
+	if err != nil {
+		return err
+	}
+	ledgerEntryFactory := entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		user.ID,
+		holding.ID,
+		uuid.Nil,
+		order.ID,
+		conversionQuote,
+		fromTicker.Price,
+		1,
+	)
+	s.bookLedgerEntry(ctx, entry, holding, book, tradingPreference.CostBasisMethod)
+	holding.Quantity = math.Max(holding.Quantity-amount, 0)
+	holding.Profit += entry.RealisedPnL
+	holding.TakeProfitLevelsHit += signal.Levels
+	if holding.Quantity <= quantityDust {
+		holding.ExitPrice = entry.ExecutedPrice
+		holding.Status = constants.HoldingStatusClosed
+	}
+	s.saveHolding(ctx, holding, parallel)
+	order.Status = constants.OrderStatusFilled
+	s.OrderService.Update(ctx, order)
+	if book != constants.ExecutionModeLive {
+		logger.Infof("Take profit of %s booked in the %s book", holding.Symbol, book)
+		return nil
+	}
+	// Send notification
+	s.NotificationService.SendTakeProfitNotification(
+		ctx,
+		holding.Symbol,
+		signal.TriggerPrice,
+		amount,
+		entry.RealisedPnL,
+		entry.RealisedPnLPercentage(),
+	)
+	return nil
+}
+
+// recordLedgerEntry books an executed conversion and the tax lots it moves.
+// The conversion already happened, so a failure here is logged rather than
+// leaving the holdings out of sync with the exchange.
+func (s *DefaultTradingService) recordLedgerEntry(
+	ctx echo.Context,
+	entry *entities.LedgerEntry,
+	holding *entities.Holding,
+	costBasisMethod string,
+) {
+	logger := config.GetLoggerFromContext(ctx)
+	_, err := s.LedgerService.Record(ctx, entry, holding)
+	if err != nil {
+		logger.Errorf("Error recording ledger entry for quote %s: %s", entry.Reference, err)
+		return
+	}
+	_, err = s.TaxLotService.RecordEntry(ctx, entry, costBasisMethod)
+	if err != nil {
+		logger.Errorf("Error recording tax lots for quote %s: %s", entry.Reference, err)
+	}
+}
+
+// bookLedgerEntry records the entry of a live conversion. Simulated ones stay
+// out of the ledger and the tax lots, and are settled at the entry price of
+// the holding they dispose of.
+func (s *DefaultTradingService) bookLedgerEntry(
+	ctx echo.Context,
+	entry *entities.LedgerEntry,
+	holding *entities.Holding,
+	book string,
+	costBasisMethod string,
+) {
+	if book == constants.ExecutionModeLive {
+		s.recordLedgerEntry(ctx, entry, holding, costBasisMethod)
+		return
+	}
+	costBasis := -entry.FromDelta
+	if holding != nil {
+		costBasis *= holding.EntryPrice
+	}
+	entry.Settle(costBasis)
+}
+
+// executionBook returns the book the holding is traded in. The execution mode
+// of the preference decides, but a simulated holding never trades live.
+func executionBook(
+	tradingPreference *entities.TradingPreference,
+	holding *entities.Holding,
+) string {
+	if tradingPreference.ExecutionMode == constants.ExecutionModeLive && holding.IsSimulated() {
+		return holding.Book
+	}
+	return tradingPreference.ExecutionMode
+}
+
+// holdingInBook returns the holding to trade in the book. A holding of another
+// book is left untouched and traded through a parallel copy in the book.
+func holdingInBook(
+	holding *entities.Holding,
+	book string,
+) (*entities.Holding, bool) {
+	if holding.Book == book {
+		return holding, false
+	}
+	holdingFactory := entities.HoldingFactory{}
+	return holdingFactory.NewBookCopy(holding, book), true
+}
+
+// saveHolding stores the traded holding, creating it when it is a parallel
+// copy.
+func (s *DefaultTradingService) saveHolding(
+	ctx echo.Context,
+	holding *entities.Holding,
+	parallel bool,
+) {
+	logger := config.GetLoggerFromContext(ctx)
+	var err error
+	if parallel {
+		_, err = s.HoldingService.Create(ctx, holding)
+	} else {
+		_, err = s.HoldingService.Update(ctx, holding)
+	}
+	if err != nil {
+		logger.Errorf("Error storing holding %s: %s", holding.ID, err)
+	}
+}
+
+// availableQuantity returns how much of the holding can be sold. Live ones are
+// capped by the free balance on the exchange.
+func (s *DefaultTradingService) availableQuantity(
+	ctx echo.Context,
+	holding *entities.Holding,
+) (float64, error) {
+	if holding.IsSimulated() {
+		return holding.Quantity, nil
+	}
+	adapter, err := s.exchangeAdapter(ctx, holding.UserID)
+	if err != nil {
+		return 0, err
+	}
+	balance, err := adapter.GetBalance(ctx, holding.GetAsset())
+	if err != nil {
+		return 0, err
+	}
+	return math.Min(holding.Quantity, balance.Free), nil
+}
+
+// getConversionQuote asks the exchange for a conversion quote. The paper book
+// prices it at the tickers instead, and the shadow book falls back to them
+// when the exchange has no quote to give.
+func (s *DefaultTradingService) getConversionQuote(
+	ctx echo.Context,
+	book string,
+	userID uuid.UUID,
+	fromAsset string,
+	toAsset string,
+	amount float64,
+	fromPrice float64,
+	toPrice float64,
+	walletType string,
+) (*entities.ExchangeConversionQuote, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	factory := entities.ExchangeConversionQuoteFactory{}
+	if book == constants.ExecutionModePaper {
+		return factory.NewSimulatedConversionQuote(fromAsset, toAsset, amount, fromPrice, toPrice), nil
+	}
+	adapter, err := s.exchangeAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	conversionQuote, err := adapter.GetConversionQuote(ctx, fromAsset, toAsset, amount, walletType)
+	if err != nil && book == constants.ExecutionModeShadow {
+		logger.Infof("No conversion quote from %s to %s, pricing the shadow book at the tickers: %s", fromAsset, toAsset, err)
+		return factory.NewSimulatedConversionQuote(fromAsset, toAsset, amount, fromPrice, toPrice), nil
+	}
+	return conversionQuote, err
+}
+
+// acceptConversionQuote executes the quote on the exchange of the user for
+// the live book only, the other books get no conversion order back.
+func (s *DefaultTradingService) acceptConversionQuote(
+	ctx echo.Context,
+	book string,
+	userID uuid.UUID,
+	conversionQuote *entities.ExchangeConversionQuote,
+) (*entities.ExchangeConversionOrder, error) {
+	if book != constants.ExecutionModeLive {
+		return nil, nil
+	}
+	adapter, err := s.exchangeAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.AcceptConversionQuote(ctx, conversionQuote)
+}
+
+// bookPartialConversion books a conversion the exchange stopped at the
+// ledger quote asset as the sale it was. Whatever wasn't sold stays in the
+// holding and the order fails, so the trade can be tried again.
+func (s *DefaultTradingService) bookPartialConversion(
+	ctx echo.Context,
+	holding *entities.Holding,
+	parallel bool,
+	order *entities.Order,
+	executedQuote *entities.ExchangeConversionQuote,
+	fromPrice float64,
+	book string,
+	costBasisMethod string,
+) {
+	ledgerEntryFactory := entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		holding.UserID,
+		holding.ID,
+		uuid.Nil,
+		order.ID,
+		executedQuote,
+		fromPrice,
+		1,
+	)
+	s.bookLedgerEntry(ctx, entry, holding, book, costBasisMethod)
+	holding.Quantity = math.Max(holding.Quantity-executedQuote.FromAmount, 0)
+	holding.Profit += entry.RealisedPnL
+	if holding.Quantity <= quantityDust {
+		holding.ExitPrice = entry.ExecutedPrice
+		holding.Status = constants.HoldingStatusClosed
+	}
+	s.saveHolding(ctx, holding, parallel)
+	order.Status = constants.OrderStatusFailed
+	s.OrderService.Update(ctx, order)
+}
+
+// prepareOrderQuantity fits the quantity to the trading rules of the exchange
+// of the user. The paper book has no exchange of its own, so it follows the
+// rules of the market data exchange.
+func (s *DefaultTradingService) prepareOrderQuantity(
+	ctx echo.Context,
+	book string,
+	userID uuid.UUID,
+	symbol string,
+	quantity float64,
+	price float64,
+) (float64, error) {
+	if book == constants.ExecutionModePaper {
+		return s.ExchangeService.PrepareOrderQuantity(ctx, symbol, quantity, price)
+	}
+	adapter, err := s.exchangeAdapter(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return adapter.PrepareOrderQuantity(ctx, symbol, quantity, price)
+}
+
+// getTicker prices the symbol on the exchange of the user, the one its
+// orders go to. The paper book prices it on the market data exchange.
+func (s *DefaultTradingService) getTicker(
+	ctx echo.Context,
+	book string,
+	userID uuid.UUID,
+	symbol string,
+) (*valueobjects.ExchangeTicker, error) {
+	if book == constants.ExecutionModePaper {
+		return s.ExchangeService.GetTicker(ctx, symbol)
+	}
+	adapter, err := s.exchangeAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.GetTicker(ctx, symbol)
+}
+
+// getAssetTicker prices the asset against the ledger quote asset, which is
+// worth one of itself and has no ticker.
+func (s *DefaultTradingService) getAssetTicker(
+	ctx echo.Context,
+	book string,
+	userID uuid.UUID,
+	asset string,
+) (*valueobjects.ExchangeTicker, error) {
+	if asset == constants.LedgerQuoteAsset {
+		return &valueobjects.ExchangeTicker{Symbol: asset, Price: 1}, nil
+	}
+	return s.getTicker(ctx, book, userID, asset+constants.LedgerQuoteAsset)
+}
+
+// exchangeAdapter returns the adapter of the exchange the user keeps a key
+// for.
+func (s *DefaultTradingService) exchangeAdapter(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (exchanges.ExchangeAdapter, error) {
+	apiKey, err := s.CredentialResolver.ResolveExchangeKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ExchangeAdapterFactory.NewAdapter(apiKey)
+}
+
+func (s *DefaultTradingService) GetHoldingPnL(
+	ctx echo.Context,
+	holding *entities.Holding,
+) (*valueobjects.HoldingPnL, error) {
+	price := 0.0
+	if holding.Status == constants.HoldingStatusOpen {
+		ticker, err := s.ExchangeService.GetTicker(ctx, holding.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		price = ticker.Price
+	}
+	return s.LedgerService.GetHoldingPnL(ctx, holding, price)
+}
+
+func (s *DefaultTradingService) GetUserPnL(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*valueobjects.UserPnL, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id": userID,
+			"status":  constants.HoldingStatusOpen,
+			"book":    constants.ExecutionModeLive,
+		},
+		"created_at",
+		"desc",
+		1,
+		1000,
+	)
+	holdings, err := s.HoldingService.GetAll(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64, len(*holdings))
+	for _, holding := range *holdings {
+		if _, ok := prices[holding.Symbol]; ok {
+			continue
+		}
+		ticker, err := s.ExchangeService.GetTicker(ctx, holding.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		prices[holding.Symbol] = ticker.Price
+	}
+	return s.LedgerService.GetUserPnL(ctx, userID, holdings, prices)
+}
+
+// GetBookSummaries sums up the orders and holdings of the user since the given
+// time in every book, valuing open positions at current prices.
+func (s *DefaultTradingService) GetBookSummaries(
+	ctx echo.Context,
+	userID uuid.UUID,
+	since time.Time,
+) (valueobjects.BookSummaries, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	filters := map[string]interface{}{
+		"user_id":         userID,
+		"created_at__gte": since,
+	}
+	holdings, err := s.HoldingService.GetAll(
+		ctx,
+		filtering.NewComplexFilter(ctx, filters, "created_at", "desc", 1, 10000),
+	)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.OrderService.GetAll(
+		ctx,
+		filtering.NewComplexFilter(ctx, filters, "created_at", "desc", 1, 10000),
+	)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(valueobjects.BookSummaries, len(constants.ExecutionModes))
+	index := make(map[string]*valueobjects.BookSummary)
+	for i, book := range constants.ExecutionModes {
+		summaries[i].Book = book
+		index[book] = &summaries[i]
+	}
+	for _, order := range *orders {
+		if summary, ok := index[order.Book]; ok {
+			summary.Orders++
+		}
+	}
+	prices := make(map[string]float64)
+	for _, holding := range *holdings {
+		summary, ok := index[holding.Book]
+		if !ok || holding.Symbol == constants.LedgerQuoteAsset {
+			continue
+		}
+		summary.RealisedPnL += holding.Profit
+		if holding.Status != constants.HoldingStatusOpen {
+			summary.ClosedPositions++
+			continue
+		}
+		summary.OpenPositions++
+		price, ok := prices[holding.Symbol]
+		if !ok {
+			ticker, err := s.ExchangeService.GetTicker(ctx, holding.Symbol)
+			if err != nil {
+				return nil, err
+			}
+			price = ticker.Price
+			prices[holding.Symbol] = price
+		}
+		summary.UnrealisedPnL += (price - holding.EntryPrice) * holding.Quantity
+	}
+	return summaries, nil
+}
+
+// GetPortfolio returns the trading preference of the user with the holdings
+// it keeps open in the book of its execution mode.
+func (s *DefaultTradingService) GetPortfolio(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*aggregate.PortfolioAggregate, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	tradingPreference, err := s.TradingPreferenceService.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id": userID,
+			"status":  constants.HoldingStatusOpen,
+			"book":    tradingPreference.ExecutionMode,
+		},
+		"created_at",
+		"desc",
+		1,
+		1000,
+	)
+	holdings, err := s.HoldingService.GetAll(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregate.PortfolioAggregate{
+		TradingPreference: tradingPreference,
+		Holdings:          *holdings,
+	}, nil
+}
+
+// EnforceRiskLimits measures the portfolio of the user at current prices and
+// trips the kill switch when it breaches a risk limit.
+func (s *DefaultTradingService) EnforceRiskLimits(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*valueobjects.RiskBreach, error) {
+	portfolio, err := s.GetPortfolio(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cash, err := s.quoteCash(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.positionPrices(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := s.RiskService.GetSnapshot(ctx, portfolio, cash, prices)
+	if err != nil {
+		return nil, err
+	}
+	return s.RiskService.Evaluate(ctx, portfolio.TradingPreference, snapshot)
+}
+
+// EntriesFrozen tells whether the preference keeps new entries out of the
+// market because the latest market regime is risk-off.
+func (s *DefaultTradingService) EntriesFrozen(
+	ctx echo.Context,
+	tradingPreference *entities.TradingPreference,
+) (bool, error) {
+	if tradingPreference.RiskOffAction == constants.RiskOffActionNone {
+		return false, nil
+	}
+	regime, err := s.MarketRegimeService.GetLatest(ctx)
+	if err == errors.ErrMarketRegimeNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return regime.IsRiskOff(), nil
+}
+
+// ApplyMarketRegime moves every position of the user back to the ledger quote
+// asset when the market regime is risk-off and the preference asks to exit.
+// Positions that fail to exit don't block the rest.
+func (s *DefaultTradingService) ApplyMarketRegime(
+	ctx echo.Context,
+	userID uuid.UUID,
+	walletType string,
+) (*entities.Holdings, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	exited := make(entities.Holdings, 0)
+	portfolio, err := s.GetPortfolio(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tradingPreference := portfolio.TradingPreference
+	if !tradingPreference.Operate || tradingPreference.RiskOffAction != constants.RiskOffActionExitToQuote {
+		return &exited, nil
+	}
+	frozen, err := s.EntriesFrozen(ctx, tradingPreference)
+	if err != nil || !frozen {
+		return &exited, err
+	}
+	for _, holding := range portfolio.Positions() {
+		err := s.ExecuteStopLoss(ctx, &holding, walletType)
+		if err != nil {
+			logger.Errorf("Error exiting %s on a risk-off market: %s", holding.Symbol, err)
+			continue
+		}
+		exited = append(exited, holding)
+	}
+	return &exited, nil
+}
+
+// positionPrices returns the current price of every position, keyed by symbol.
+func (s *DefaultTradingService) positionPrices(
+	ctx echo.Context,
+	portfolio *aggregate.PortfolioAggregate,
+) (map[string]float64, error) {
+	prices := make(map[string]float64)
+	for _, holding := range portfolio.Positions() {
+		ticker, err := s.ExchangeService.GetTicker(ctx, holding.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		prices[holding.Symbol] = ticker.Price
+	}
+	return prices, nil
+}
+
+// quoteCash returns the free balance of the ledger quote asset in the
+// exchange account of the user, none if the user has no exchange key or the
+// account never held it.
+func (s *DefaultTradingService) quoteCash(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (float64, error) {
+	adapter, err := s.exchangeAdapter(ctx, userID)
+	if err == errors.ErrApiKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	balance, err := adapter.GetBalance(ctx, constants.LedgerQuoteAsset)
+	if err == errors.ErrBalanceNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return balance.Free, nil
+}
+
+// BuildDigest sums up the portfolio of the user between the given times: its
+// value at current prices, the open holdings with their unrealised PnL, the
+// trades and fees of the period, the watchlist ranking and the risk events.
+func (s *DefaultTradingService) BuildDigest(
+	ctx echo.Context,
+	userID uuid.UUID,
+	from time.Time,
+	to time.Time,
+) (*valueobjects.DigestNotification, error) {
+	portfolio, err := s.GetPortfolio(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tradingPreference := portfolio.TradingPreference
+	cash, err := s.quoteCash(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.positionPrices(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	digest := &valueobjects.DigestNotification{
+		From:           from,
+		To:             to,
+		PortfolioValue: portfolio.Equity(cash, prices),
+		Positions:      make(valueobjects.HoldingsPnL, 0),
+		RiskEvents:     make([]valueobjects.DigestRiskEvent, 0),
+	}
+	for _, holding := range portfolio.Positions() {
+		pnl, err := s.GetHoldingPnL(ctx, &holding)
+		if err != nil {
+			return nil, err
+		}
+		digest.Positions = append(digest.Positions, *pnl)
+		digest.UnrealisedPnL += pnl.UnrealisedPnL
+	}
+	orders, err := s.OrderService.GetAll(ctx, filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id":         userID,
+			"book":            tradingPreference.ExecutionMode,
+			"created_at__gte": from,
+			"created_at__lt":  to,
+		},
+		"created_at",
+		"desc",
+		1,
+		10000,
+	))
+	if err != nil {
+		return nil, err
+	}
+	digest.Trades = len(*orders)
+	entries, err := s.LedgerService.GetAll(ctx, filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id":         userID,
+			"created_at__gte": from,
+			"created_at__lt":  to,
+		},
+		"created_at",
+		"desc",
+		0,
+		10000,
+	))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range *entries {
+		digest.Fees += entry.FeeValue
+		digest.RealisedPnL += entry.RealisedPnL
+	}
+	// Stale market data leaves the ranking out rather than the whole digest
+	scores, err := s.MarketDataService.GetScores(ctx, tradingPreference.Watchlist)
+	if err != nil {
+		config.GetLoggerFromContext(ctx).Warnf("Error ranking the watchlist of user %s: %s", userID, err)
+	}
+	digest.Scores = scores
+	triggeredAt := tradingPreference.KillSwitchTriggeredAt
+	if triggeredAt != nil && !triggeredAt.Before(from) && triggeredAt.Before(to) {
+		digest.RiskEvents = append(digest.RiskEvents, valueobjects.DigestRiskEvent{
+			Kind:       constants.DigestRiskEventKillSwitch,
+			Limit:      tradingPreference.KillSwitchReason,
+			OccurredAt: *triggeredAt,
+		})
+	}
+	regimes, err := s.MarketRegimeService.GetHistory(ctx, filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"created_at__gte": from,
+			"created_at__lt":  to,
+		},
+		"created_at",
+		"asc",
+		0,
+		1000,
+	))
+	if err != nil {
+		return nil, err
+	}
+	history := *regimes
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].CreatedAt.Before(history[j].CreatedAt)
+	})
+	riskOff := false
+	for _, regime := range history {
+		if regime.IsRiskOff() && !riskOff {
+			digest.RiskEvents = append(digest.RiskEvents, valueobjects.DigestRiskEvent{
+				Kind:       constants.DigestRiskEventRiskOff,
+				OccurredAt: regime.CreatedAt,
+			})
+		}
+		riskOff = regime.IsRiskOff()
+	}
+	return digest, nil
+}
+
+// SendDueDigests sends the digest of every user whose schedule is due, on
+// behalf of each user. A failing digest doesn't block the rest, and is tried
+// again on the next run. Returns the number of digests sent.
+func (s *DefaultTradingService) SendDueDigests(
+	ctx echo.Context,
+	now time.Time,
+) (int, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	schedules, err := s.NotificationService.GetEnabledDigestSchedules(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := range *schedules {
+		schedule := &(*schedules)[i]
+		userCtx := uacs.NewUserContext(ctx, schedule.UserID)
+		preference, err := s.NotificationService.GetPreference(userCtx, schedule.UserID)
+		if err != nil {
+			logger.Errorf("Error getting notification preference of user %s: %s", schedule.UserID, err)
+			continue
+		}
+		if !schedule.IsDue(now, preference.Location()) {
+			continue
+		}
+		digest, err := s.BuildDigest(userCtx, schedule.UserID, schedule.PeriodStart(now), now)
+		if err != nil {
+			logger.Errorf("Error building the digest of user %s: %s", schedule.UserID, err)
+			continue
+		}
+		digest.Frequency = schedule.Frequency
+		if schedule.LastSentAt != nil {
+			digest.ValueChange = digest.PortfolioValue - schedule.LastValue
+			if schedule.LastValue > 0 {
+				digest.ValueChangePercentage = digest.ValueChange / schedule.LastValue * 100
+			}
+		}
+		err = s.NotificationService.SendDigestNotification(userCtx, schedule, digest)
+		if err != nil {
+			logger.Errorf("Error sending the digest of user %s: %s", schedule.UserID, err)
+		}
+		if schedule.LastSentAt != nil && schedule.LastSentAt.Equal(now) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// PlanAllocations sizes a new position for each candidate, in the given order,
+// until the portfolio runs out of slots or cash. Symbols already held and
+// candidates that cannot be sized are skipped.
+func (s *DefaultTradingService) PlanAllocations(
+	ctx echo.Context,
+	portfolio *aggregate.PortfolioAggregate,
+	cash float64,
+	prices map[string]float64,
+	candidates entities.MarketDatas,
+) valueobjects.PositionAllocations {
+	logger := config.GetLoggerFromContext(ctx)
+	allocations := make(valueobjects.PositionAllocations, 0)
+	slots := portfolio.OpenSlots()
+	equity := portfolio.Equity(cash, prices)
+	limits := riskLimits(portfolio.TradingPreference)
+	for _, candidate := range candidates {
+		if slots == 0 || cash <= 0 {
+			break
+		}
+		if portfolio.Holds(candidate.Symbol) {
+			continue
+		}
+		size, err := portfolio.TradingPreference.PositionSize(equity, candidate.Close, candidate.ATR)
+		if err != nil {
+			logger.Infof("Skipping %s, position cannot be sized: %s", candidate.Symbol, err)
+			continue
+		}
+		if limits.MaxSymbolExposurePercentage > 0 {
+			size = math.Min(size, equity*limits.MaxSymbolExposurePercentage/100)
+		}
+		allocation := valueobjects.PositionAllocation{
+			Symbol: candidate.Symbol,
+			Price:  candidate.Close,
+			Amount: math.Min(size, cash),
+		}
+		if candidate.Score != nil {
+			allocation.Score = *candidate.Score
+		}
+		allocations = append(allocations, allocation)
+		cash -= allocation.Amount
+		slots--
+	}
+	return allocations
+}
+
+// AllocateCapital opens positions with the free cash of the user in the best
+// scoring attractive symbols of the watchlist, up to the max concurrent
+// holdings of the preference. Positions that fail to open don't block the rest.
+func (s *DefaultTradingService) AllocateCapital(
+	ctx echo.Context,
+	userID uuid.UUID,
+	walletType string,
+) (*entities.Holdings, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	opened := make(entities.Holdings, 0)
+	portfolio, err := s.GetPortfolio(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !portfolio.TradingPreference.Operate {
+		logger.Info("Trading preference is not active")
+		return &opened, nil
+	}
+	if portfolio.OpenSlots() == 0 {
+		logger.Infof("User %s already holds the max concurrent holdings", userID)
+		return &opened, nil
+	}
+	frozen, err := s.EntriesFrozen(ctx, portfolio.TradingPreference)
+	if err != nil {
+		return nil, err
+	}
+	if frozen {
+		return nil, errors.ErrMarketRegimeRiskOff
+	}
+	breach, err := s.EnforceRiskLimits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if breach != nil {
+		return nil, errors.ErrRiskLimitBreached
+	}
+	adapter, err := s.exchangeAdapter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := adapter.GetBalance(ctx, constants.LedgerQuoteAsset)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.positionPrices(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.MarketDataService.GetScores(ctx, portfolio.TradingPreference.Watchlist)
+	if err != nil {
+		return nil, err
+	}
+	tradingPosition := &aggregate.TradingPositionAggregate{
+		TradingPreference: portfolio.TradingPreference,
+	}
+	candidates := make(entities.MarketDatas, 0)
+	for _, score := range scores {
+		if portfolio.Holds(score.Symbol) {
+			continue
+		}
+		marketData, err := s.MarketDataService.GetLatest(ctx, score.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		if marketData.Score == nil {
+			continue
+		}
+		attractive, err := s.IsAttractiveSymbol(ctx, tradingPosition, marketData)
+		if err != nil {
+			return nil, err
+		}
+		if attractive {
+			candidates = append(candidates, *marketData)
+		}
+	}
+	allocations := s.PlanAllocations(ctx, portfolio, balance.Free, prices, candidates)
+	for _, allocation := range allocations {
+		holding, err := s.OpenPosition(ctx, portfolio.TradingPreference, allocation, walletType)
+		if err != nil {
+			logger.Errorf("Error opening position in %s: %s", allocation.Symbol, err)
+			continue
+		}
+		opened = append(opened, *holding)
+	}
+	return &opened, nil
+}
+
+// OpenPosition converts the allocated amount of the ledger quote asset into
+// the symbol and opens a holding for it.
+func (s *DefaultTradingService) OpenPosition(
+	ctx echo.Context,
+	tradingPreference *entities.TradingPreference,
+	allocation valueobjects.PositionAllocation,
+	walletType string,
+) (*entities.Holding, error) {
+	logger := config.GetLoggerFromContext(ctx)
+	user := s.UacService.GetUser(ctx)
+	toTicker, err := s.ExchangeService.GetTicker(ctx, allocation.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	book := tradingPreference.ExecutionMode
+	quantity, err := s.prepareOrderQuantity(
+		ctx,
+		book,
+		user.ID,
+		allocation.Symbol,
+		allocation.Amount/toTicker.Price,
+		toTicker.Price,
+	)
+	if err != nil {
+		return nil, err
+	}
+	amount := quantity * toTicker.Price
+	conversionQuote, err := s.getConversionQuote(
+		ctx,
+		book,
+		user.ID,
+		constants.LedgerQuoteAsset,
+		strings.TrimSuffix(allocation.Symbol, constants.LedgerQuoteAsset),
+		amount,
+		1,
+		toTicker.Price,
+		walletType,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = conversionQuote.ValidateConversionDrift(amount, toTicker.Price)
+	if err != nil {
+		return nil, err
+	}
+	orderFactory := entities.OrderFactory{}
+	order := orderFactory.NewOrder(
+		user.ID,
+		allocation.Symbol,
+		conversionQuote.ToAmount,
+		toTicker.Price,
+		constants.OrderTypeEntry,
+	)
+	order.Book = book
+	_, err = s.OrderService.Create(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.acceptConversionQuote(ctx, book, user.ID, conversionQuote)
+	if err != nil {
+		return nil, err
+	}
+	holdingFactory := entities.HoldingFactory{}
+	holding := holdingFactory.NewHolding(
+		user.ID,
+		allocation.Symbol,
+		conversionQuote.ToAmount,
+		toTicker.Price,
+		0,
+		0,
+		allocation.Score,
+		constants.HoldingStatusOpen,
+	)
+	holding.Book = book
+	ledgerEntryFactory := entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		user.ID,
+		uuid.Nil,
+		holding.ID,
+		order.ID,
+		conversionQuote,
+		1,
+		toTicker.Price,
+	)
+	s.bookLedgerEntry(ctx, entry, nil, book, tradingPreference.CostBasisMethod)
+	holding.EntryPrice = entry.UnitCost()
+	s.HoldingService.Create(ctx, holding)
+	order.Status = constants.OrderStatusFilled
+	s.OrderService.Update(ctx, order)
+	if holding.IsSimulated() {
+		logger.Infof("Position in %s booked in the %s book", allocation.Symbol, book)
+		return holding, nil
+	}
+	// Send notification
+	s.NotificationService.SendPositionOpenedNotification(
+		ctx,
+		allocation.Symbol,
+		holding.EntryPrice,
+		holding.Quantity,
+		amount,
+	)
+	return holding, nil
+}
+
+// Trading Preference Service
+
+func (s *DefaultTradingPreferenceService) GetByUserID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.TradingPreference, error) {
+	tp, err := s.TradingPreferenceRepository.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, tp.UserID); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+func (s *DefaultTradingPreferenceService) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.TradingPreference, error) {
+	tp, err := s.TradingPreferenceRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, tp.UserID); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+func (s *DefaultTradingPreferenceService) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.TradingPreferences, error) {
+	filters.SetMetaParameters()
+	filters.NarrowUserFilters("user_id")
+	return s.TradingPreferenceRepository.GetAll(ctx, filters)
+}
+
+// GetOperating returns the preferences of every user that operates, for jobs
+// that run on behalf of the system.
+func (s *DefaultTradingPreferenceService) GetOperating(
+	ctx echo.Context,
+) (*entities.TradingPreferences, error) {
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"operate": true,
+		},
+		"created_at",
+		"desc",
+		1,
+		10000,
+	)
+	return s.TradingPreferenceRepository.GetAll(ctx, filters)
+}
+
+func (s *DefaultTradingPreferenceService) Create(
+	ctx echo.Context,
+	tp *entities.TradingPreference,
+) (*entities.TradingPreference, error) {
+	_, err := s.GetByUserID(ctx, tp.UserID)
+	if err == nil {
+		return nil, errors.ErrTradingPreferenceAlreadyExists
+	}
+	if err := s.UacService.IsResourceOwner(ctx, tp.UserID); err != nil {
+		return nil, err
+	}
+	if tp.Operate {
+		err = s.SecondFactorGuard.RequireSecondFactor(ctx, tp.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tp.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return s.TradingPreferenceRepository.Create(ctx, tp)
+}
+
+func (s *DefaultTradingPreferenceService) Update(
+	ctx echo.Context,
+	entity *entities.TradingPreference,
+) (*entities.TradingPreference, error) {
+	current, err := s.GetByID(ctx, entity.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, entity.UserID); err != nil {
+		return nil, err
+	}
+	// Enabling trading puts the funds at stake
+	if entity.Operate && !current.Operate {
+		err = s.SecondFactorGuard.RequireSecondFactor(ctx, entity.UserID)
+		if err != nil {
+			return nil, err
+		}
 	}
 	err = entity.Validate()
 	if err != nil {
@@ -559,6 +2056,28 @@ func (s *DefaultHoldingService) GetAll(
 	return s.HoldingRepository.GetAll(ctx, filters)
 }
 
+// GetOpenBySymbol returns the open holdings the users have in the symbol, for
+// jobs that run on behalf of the system.
+func (s *DefaultHoldingService) GetOpenBySymbol(
+	ctx echo.Context,
+	symbol string,
+	userIDs []uuid.UUID,
+) (*entities.Holdings, error) {
+	filters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"symbol":      symbol,
+			"status":      constants.HoldingStatusOpen,
+			"user_id__in": userIDs,
+		},
+		"created_at",
+		"desc",
+		1,
+		10000,
+	)
+	return s.HoldingRepository.GetAll(ctx, filters)
+}
+
 func (s *DefaultHoldingService) Create(
 	ctx echo.Context,
 	holding *entities.Holding,
@@ -715,3 +2234,593 @@ func (s *DefaultOrderService) Delete(
 	}
 	return s.OrderRepository.Delete(ctx, id)
 }
+
+// Ledger Service
+
+func (s *DefaultLedgerService) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.LedgerEntry, error) {
+	entry, err := s.LedgerEntryRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, entry.UserID); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *DefaultLedgerService) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.LedgerEntries, error) {
+	filters.SetMetaParameters()
+	filters.NarrowUserFilters("user_id")
+	return s.LedgerEntryRepository.GetAll(ctx, filters)
+}
+
+func (s *DefaultLedgerService) GetByHoldingID(
+	ctx echo.Context,
+	holding *entities.Holding,
+) (*entities.LedgerEntries, error) {
+	if err := s.UacService.IsResourceOwner(ctx, holding.UserID); err != nil {
+		return nil, err
+	}
+	return s.LedgerEntryRepository.GetByHoldingID(ctx, holding.ID)
+}
+
+// Record settles the entry against the cost basis of the holding it disposes
+// of and stores it. The entry is settled even when it cannot be stored.
+func (s *DefaultLedgerService) Record(
+	ctx echo.Context,
+	entry *entities.LedgerEntry,
+	holding *entities.Holding,
+) (*entities.LedgerEntry, error) {
+	if err := s.UacService.IsResourceOwner(ctx, entry.UserID); err != nil {
+		return nil, err
+	}
+	quantity := -entry.FromDelta
+	costBasis := quantity * entry.ExpectedPrice
+	if entry.FromAsset == constants.LedgerQuoteAsset {
+		costBasis = quantity
+	} else if holding != nil {
+		unitCost, err := s.GetUnitCost(ctx, holding)
+		if err != nil {
+			return nil, err
+		}
+		costBasis = quantity * unitCost
+	}
+	entry.Settle(costBasis)
+	err := entry.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return s.LedgerEntryRepository.Create(ctx, entry)
+}
+
+// GetUnitCost returns what each unit of the holding cost when it was opened.
+// Holdings opened before the ledger existed fall back to their entry price.
+func (s *DefaultLedgerService) GetUnitCost(
+	ctx echo.Context,
+	holding *entities.Holding,
+) (float64, error) {
+	entries, err := s.GetByHoldingID(ctx, holding)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range *entries {
+		if entry.ToHoldingID == holding.ID {
+			return entry.UnitCost(), nil
+		}
+	}
+	return holding.EntryPrice, nil
+}
+
+// GetHoldingPnL derives the PnL of a holding from its ledger entries. Open
+// holdings are valued at the given price, closed ones only realise PnL.
+func (s *DefaultLedgerService) GetHoldingPnL(
+	ctx echo.Context,
+	holding *entities.Holding,
+	price float64,
+) (*valueobjects.HoldingPnL, error) {
+	entries, err := s.GetByHoldingID(ctx, holding)
+	if err != nil {
+		return nil, err
+	}
+	pnl := valueobjects.HoldingPnL{
+		HoldingID: holding.ID,
+		Symbol:    holding.Symbol,
+		Status:    holding.Status,
+		Quantity:  holding.Quantity,
+	}
+	unitCost := holding.EntryPrice
+	disposedCostBasis := 0.0
+	for _, entry := range *entries {
+		if entry.ToHoldingID == holding.ID {
+			unitCost = entry.UnitCost()
+		}
+		if entry.FromHoldingID == holding.ID {
+			disposedCostBasis += entry.CostBasis
+			pnl.Fees += entry.FeeValue
+			pnl.RealisedPnL += entry.RealisedPnL
+		}
+	}
+	if holding.Status == constants.HoldingStatusOpen {
+		pnl.CostBasis = unitCost * holding.Quantity
+		pnl.MarketValue = price * holding.Quantity
+		pnl.UnrealisedPnL = pnl.MarketValue - pnl.CostBasis
+	} else {
+		pnl.CostBasis = disposedCostBasis
+	}
+	return &pnl, nil
+}
+
+// GetUserPnL realises PnL from every ledger entry of the user and values the
+// given open holdings at the given prices, keyed by symbol.
+func (s *DefaultLedgerService) GetUserPnL(
+	ctx echo.Context,
+	userID uuid.UUID,
+	holdings *entities.Holdings,
+	prices map[string]float64,
+) (*valueobjects.UserPnL, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	entries, err := s.LedgerEntryRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	pnl := valueobjects.UserPnL{
+		UserID:   userID,
+		Holdings: valueobjects.HoldingsPnL{},
+	}
+	for _, entry := range *entries {
+		pnl.Fees += entry.FeeValue
+		pnl.RealisedPnL += entry.RealisedPnL
+	}
+	for _, holding := range *holdings {
+		if holding.Status != constants.HoldingStatusOpen {
+			continue
+		}
+		holdingPnL, err := s.GetHoldingPnL(ctx, &holding, prices[holding.Symbol])
+		if err != nil {
+			return nil, err
+		}
+		pnl.UnrealisedPnL += holdingPnL.UnrealisedPnL
+		pnl.Holdings = append(pnl.Holdings, *holdingPnL)
+	}
+	return &pnl, nil
+}
+
+// Tax Lot Service
+
+func (s *DefaultTaxLotService) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.TaxLots, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.TaxLotRepository.GetByUserID(ctx, userID)
+}
+
+// RecordEntry disposes of the From leg of a settled ledger entry against the
+// open lots of the asset, in the order given by the cost basis method, and
+// opens a new lot for the To leg. Quote asset legs are not tracked.
+func (s *DefaultTaxLotService) RecordEntry(
+	ctx echo.Context,
+	entry *entities.LedgerEntry,
+	method string,
+) (*entities.TaxLotDisposals, error) {
+	if err := s.UacService.IsResourceOwner(ctx, entry.UserID); err != nil {
+		return nil, err
+	}
+	if !lib.SliceContains(constants.CostBasisMethods, method) {
+		return nil, errors.ErrInvalidCostBasisMethod
+	}
+	disposals := entities.TaxLotDisposals{}
+	if entry.FromAsset != constants.LedgerQuoteAsset {
+		lots, err := s.TaxLotRepository.GetOpenByAsset(ctx, entry.UserID, entry.FromAsset)
+		if err != nil {
+			return nil, err
+		}
+		sortTaxLots(*lots, method)
+		disposalFactory := entities.TaxLotDisposalFactory{}
+		remaining := -entry.FromDelta
+		for i := range *lots {
+			if remaining <= quantityDust {
+				break
+			}
+			lot := &(*lots)[i]
+			consumed := lot.Consume(remaining)
+			_, err = s.TaxLotRepository.Update(ctx, lot)
+			if err != nil {
+				return nil, err
+			}
+			disposal, err := s.createDisposal(ctx, disposalFactory.NewTaxLotDisposal(entry, lot, consumed, method))
+			if err != nil {
+				return nil, err
+			}
+			disposals = append(disposals, *disposal)
+			remaining -= consumed
+		}
+		// Quantities acquired before lots were tracked keep the ledger cost
+		if remaining > quantityDust {
+			disposal, err := s.createDisposal(ctx, disposalFactory.NewTaxLotDisposal(entry, nil, remaining, method))
+			if err != nil {
+				return nil, err
+			}
+			disposals = append(disposals, *disposal)
+		}
+	}
+	if entry.ToAsset != constants.LedgerQuoteAsset {
+		lotFactory := entities.TaxLotFactory{}
+		lot := lotFactory.NewTaxLot(
+			entry.UserID,
+			entry.ID,
+			entry.ToAsset,
+			entry.ToDelta,
+			entry.UnitCost(),
+			entry.CreatedAt,
+		)
+		err := lot.Validate()
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.TaxLotRepository.Create(ctx, lot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &disposals, nil
+}
+
+// GetDisposals returns the disposals of the user within a calendar year (UTC).
+func (s *DefaultTaxLotService) GetDisposals(
+	ctx echo.Context,
+	userID uuid.UUID,
+	year int,
+) (*entities.TaxLotDisposals, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	if year < 2009 || year > time.Now().UTC().Year() {
+		return nil, errors.ErrInvalidTaxYear
+	}
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return s.TaxLotDisposalRepository.GetByPeriod(ctx, userID, from, from.AddDate(1, 0, 0))
+}
+
+// ExportYearlyReport writes the disposals of a calendar year as CSV, one row
+// per lot matched, with amounts in the ledger quote asset.
+func (s *DefaultTaxLotService) ExportYearlyReport(
+	ctx echo.Context,
+	userID uuid.UUID,
+	year int,
+	w io.Writer,
+) error {
+	disposals, err := s.GetDisposals(ctx, userID, year)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	err = writer.Write(taxReportHeader)
+	if err != nil {
+		return err
+	}
+	for _, disposal := range *disposals {
+		acquiredAt := ""
+		if !disposal.AcquiredAt.IsZero() {
+			acquiredAt = disposal.AcquiredAt.UTC().Format(time.DateOnly)
+		}
+		lotID := ""
+		if disposal.LotID != uuid.Nil {
+			lotID = disposal.LotID.String()
+		}
+		err = writer.Write([]string{
+			disposal.Asset,
+			strconv.FormatFloat(disposal.Quantity, 'f', -1, 64),
+			acquiredAt,
+			disposal.DisposedAt.UTC().Format(time.DateOnly),
+			strconv.FormatFloat(disposal.Proceeds, 'f', 2, 64),
+			strconv.FormatFloat(disposal.CostBasis, 'f', 2, 64),
+			strconv.FormatFloat(disposal.Gain, 'f', 2, 64),
+			disposal.Term(),
+			disposal.Method,
+			lotID,
+			disposal.Reference,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *DefaultTaxLotService) createDisposal(
+	ctx echo.Context,
+	disposal *entities.TaxLotDisposal,
+) (*entities.TaxLotDisposal, error) {
+	err := disposal.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return s.TaxLotDisposalRepository.Create(ctx, disposal)
+}
+
+// Trade Proposal Service
+
+func (s *DefaultTradeProposalService) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.TradeProposal, error) {
+	proposal, err := s.TradeProposalRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, proposal.UserID); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (s *DefaultTradeProposalService) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.TradeProposals, error) {
+	filters.SetMetaParameters()
+	filters.NarrowUserFilters("user_id")
+	return s.TradeProposalRepository.GetAll(ctx, filters)
+}
+
+func (s *DefaultTradeProposalService) GetPending(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.TradeProposals, error) {
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.TradeProposalRepository.GetPending(ctx, userID)
+}
+
+func (s *DefaultTradeProposalService) Create(
+	ctx echo.Context,
+	proposal *entities.TradeProposal,
+) (*entities.TradeProposal, error) {
+	err := proposal.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UacService.IsResourceOwner(ctx, proposal.UserID); err != nil {
+		return nil, err
+	}
+	return s.TradeProposalRepository.Create(ctx, proposal)
+}
+
+func (s *DefaultTradeProposalService) Update(
+	ctx echo.Context,
+	proposal *entities.TradeProposal,
+) (*entities.TradeProposal, error) {
+	_, err := s.GetByID(ctx, proposal.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = proposal.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return s.TradeProposalRepository.Update(ctx, proposal)
+}
+
+// Claim takes the pending proposal for a decision. A single caller claims it,
+// the others get ErrTradeProposalDecided.
+func (s *DefaultTradeProposalService) Claim(
+	ctx echo.Context,
+	proposal *entities.TradeProposal,
+) error {
+	if err := s.UacService.IsResourceOwner(ctx, proposal.UserID); err != nil {
+		return err
+	}
+	claimed, err := s.TradeProposalRepository.ClaimPending(ctx, proposal.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.ErrTradeProposalDecided
+	}
+	proposal.Status = constants.TradeProposalStatusDeciding
+	return nil
+}
+
+// Risk Service
+
+// GetSnapshot measures the portfolio and the activity of its user today, with
+// days starting at midnight UTC. Positions are valued at the given prices,
+// keyed by symbol.
+func (s *DefaultRiskService) GetSnapshot(
+	ctx echo.Context,
+	portfolio *aggregate.PortfolioAggregate,
+	cash float64,
+	prices map[string]float64,
+) (*valueobjects.RiskSnapshot, error) {
+	userID := portfolio.TradingPreference.UserID
+	if err := s.UacService.IsResourceOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	entryFilters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id":         userID,
+			"created_at__gte": dayStart,
+		},
+		"created_at",
+		"desc",
+		0,
+		10000,
+	)
+	entries, err := s.LedgerService.GetAll(ctx, entryFilters)
+	if err != nil {
+		return nil, err
+	}
+	orderFilters := filtering.NewComplexFilter(
+		ctx,
+		map[string]interface{}{
+			"user_id":         userID,
+			"book":            portfolio.TradingPreference.ExecutionMode,
+			"created_at__gte": dayStart,
+		},
+		"created_at",
+		"desc",
+		1,
+		10000,
+	)
+	orders, err := s.OrderService.GetAll(ctx, orderFilters)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &valueobjects.RiskSnapshot{
+		Equity:      portfolio.Equity(cash, prices),
+		TradesToday: len(*orders),
+		Exposures:   portfolio.Exposures(prices),
+	}
+	snapshot.PeakEquity = math.Max(portfolio.TradingPreference.PeakEquity, snapshot.Equity)
+	for _, entry := range *entries {
+		snapshot.DailyRealisedPnL += entry.RealisedPnL
+	}
+	return snapshot, nil
+}
+
+// Evaluate checks the snapshot against the limits of the user merged with the
+// global ones, and trips the kill switch on a breach. The peak equity is kept
+// for the drawdown. Users already halted are not evaluated again.
+func (s *DefaultRiskService) Evaluate(
+	ctx echo.Context,
+	tradingPreference *entities.TradingPreference,
+	snapshot *valueobjects.RiskSnapshot,
+) (*valueobjects.RiskBreach, error) {
+	if err := s.UacService.IsResourceOwner(ctx, tradingPreference.UserID); err != nil {
+		return nil, err
+	}
+	if tradingPreference.KillSwitchEngaged() {
+		return nil, nil
+	}
+	peakRaised := snapshot.PeakEquity > tradingPreference.PeakEquity
+	tradingPreference.PeakEquity = math.Max(tradingPreference.PeakEquity, snapshot.PeakEquity)
+	breach := riskLimits(tradingPreference).Check(*snapshot)
+	if breach != nil {
+		return breach, s.TripKillSwitch(ctx, tradingPreference, *breach)
+	}
+	if peakRaised {
+		_, err := s.TradingPreferenceService.Update(ctx, tradingPreference)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// TripKillSwitch halts trading for the user until it is resumed, then
+// announces the breach. Failing to announce it doesn't undo the halt.
+func (s *DefaultRiskService) TripKillSwitch(
+	ctx echo.Context,
+	tradingPreference *entities.TradingPreference,
+	breach valueobjects.RiskBreach,
+) error {
+	logger := config.GetLoggerFromContext(ctx)
+	tradingPreference.TripKillSwitch(breach)
+	_, err := s.TradingPreferenceService.Update(ctx, tradingPreference)
+	if err != nil {
+		return err
+	}
+	logger.Warnf(
+		"Kill switch tripped for user %s: %s %s at %f, limit %f",
+		tradingPreference.UserID,
+		breach.Limit,
+		breach.Symbol,
+		breach.Value,
+		breach.Threshold,
+	)
+	// Events are only dispatched when a broker is configured
+	if s.EventsPubSub != nil {
+		tradingEventFactory := events.NewTradingEventFactory()
+		event := tradingEventFactory.NewRiskLimitBreachedEvent(tradingPreference.UserID, breach)
+		err = event.Dispatch(s.EventsPubSub)
+		if err != nil {
+			logger.Errorf("Error dispatching risk limit breached event: %s", err)
+		}
+	}
+	err = s.NotificationService.SendKillSwitchNotification(
+		ctx,
+		breach.Limit,
+		breach.Symbol,
+		breach.Value,
+		breach.Threshold,
+	)
+	if err != nil {
+		logger.Errorf("Error sending kill switch notification: %s", err)
+	}
+	return nil
+}
+
+// ResumeTrading releases the kill switch of the user and enables trading.
+func (s *DefaultRiskService) ResumeTrading(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.TradingPreference, error) {
+	tradingPreference, err := s.TradingPreferenceService.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tradingPreference.ResumeTrading()
+	return s.TradingPreferenceService.Update(ctx, tradingPreference)
+}
+
+// Helpers
+
+// quantityDust is the quantity below which a holding, lot or disposal is done.
+const quantityDust = 1e-12
+
+var taxReportHeader = []string{
+	"asset",
+	"quantity",
+	"date_acquired",
+	"date_disposed",
+	"proceeds_usdt",
+	"cost_basis_usdt",
+	"gain_usdt",
+	"term",
+	"method",
+	"lot_id",
+	"reference",
+}
+
+// riskLimits merges the limits of the preference with the global ones.
+func riskLimits(tradingPreference *entities.TradingPreference) valueobjects.RiskLimits {
+	conf := config.GetConfig()
+	return tradingPreference.RiskLimits().Merge(valueobjects.RiskLimits{
+		MaxDailyLossPercentage:      conf.Risk.MaxDailyLossPercentage,
+		MaxDrawdownPercentage:       conf.Risk.MaxDrawdownPercentage,
+		MaxTradesPerDay:             int(conf.Risk.MaxTradesPerDay),
+		MaxSymbolExposurePercentage: conf.Risk.MaxSymbolExposurePercentage,
+	})
+}
+
+// sortTaxLots orders lots in the order they are disposed of by the method.
+func sortTaxLots(lots entities.TaxLots, method string) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		switch method {
+		case constants.CostBasisMethodLIFO:
+			return lots[i].AcquiredAt.After(lots[j].AcquiredAt)
+		case constants.CostBasisMethodHIFO:
+			if lots[i].UnitCost != lots[j].UnitCost {
+				return lots[i].UnitCost > lots[j].UnitCost
+			}
+			return lots[i].AcquiredAt.Before(lots[j].AcquiredAt)
+		default:
+			return lots[i].AcquiredAt.Before(lots[j].AcquiredAt)
+		}
+	})
+}
@@ -1,12 +1,23 @@
 package trades
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/app/markets"
+	"github.com/sergiovirahonda/endurance-api/internal/app/notifications"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/aggregate"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 	"github.com/stretchr/testify/assert"
@@ -131,6 +142,36 @@ func TestUpdateTradingPreference(t *testing.T) {
 	assert.Equal(t, constants.TradingPreferenceRiskLevelHigh, updated.RiskLevel)
 }
 
+func TestUpdateTradingPreferenceRequiresSecondFactorToOperate(t *testing.T) {
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		false,
+		false,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	dto := dtos.TradingPreference{}
+	dto.FromEntity(tp)
+	database.Create(&dto)
+	ctx.Set("user", &entities.User{ID: userID})
+	secondFactorGuard.err = errors.ErrMFARequired
+	t.Cleanup(func() { secondFactorGuard.err = nil })
+	tp.RiskLevel = constants.TradingPreferenceRiskLevelHigh
+	_, err := tradingPreferenceService.Update(ctx, tp)
+	assert.NoError(t, err)
+	tp.Operate = true
+	_, err = tradingPreferenceService.Update(ctx, tp)
+	assert.Equal(t, errors.ErrMFARequired, err)
+	stored, err := tradePreferenceRepository.GetByID(ctx, tp.ID)
+	assert.NoError(t, err)
+	assert.False(t, stored.Operate)
+}
+
 func TestDeleteTradingPreference(t *testing.T) {
 	ctx := echo.New().NewContext(nil, nil)
 	userID := uuid.New()
@@ -373,3 +414,2036 @@ func TestDeleteOrder(t *testing.T) {
 	_, err = orderService.GetByID(ctx, o.ID)
 	assert.Equal(t, gorm.ErrRecordNotFound, err)
 }
+
+// --- LedgerService Tests ---
+
+func newLedgerTestHolding(userID uuid.UUID, symbol string, quantity float64, entryPrice float64) *entities.Holding {
+	holdingFactory := &entities.HoldingFactory{}
+	return holdingFactory.NewHolding(
+		userID,
+		symbol,
+		quantity,
+		entryPrice,
+		0,
+		0,
+		0.5,
+		constants.HoldingStatusOpen,
+	)
+}
+
+func TestRecordLedgerEntryUsesCostBasisOfOpeningEntry(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	// Ticker says 55000 but the holding was actually bought at 60000
+	btcHolding := newLedgerTestHolding(userID, "BTCUSDT", 0.5, 55000)
+	opening := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		uuid.Nil,
+		btcHolding.ID,
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-1", FromAsset: "USDT", ToAsset: "BTC", FromAmount: 30000, ToAmount: 0.5},
+		1,
+		60000,
+	)
+	_, err := ledgerService.Record(ctx, opening, nil)
+	assert.NoError(t, err)
+	closing := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		btcHolding.ID,
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-2", FromAsset: "BTC", ToAsset: "ETH", FromAmount: 0.5, ToAmount: 10},
+		66000,
+		3300,
+	)
+
+	// Act
+	recorded, err := ledgerService.Record(ctx, closing, btcHolding)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, opening.RealisedPnL)
+	assert.Equal(t, 30000.0, recorded.CostBasis)
+	assert.Equal(t, 33000.0, recorded.Proceeds)
+	assert.Equal(t, 3000.0, recorded.RealisedPnL)
+	assert.Equal(t, 66000.0, recorded.ExecutedPrice)
+	assert.Equal(t, 0.0, recorded.Slippage)
+	assert.InDelta(t, 10.0, recorded.RealisedPnLPercentage(), 0.0001)
+}
+
+func TestRecordLedgerEntryDeductsExternalFeesAndTracksSlippage(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	holding := newLedgerTestHolding(userID, "BTCUSDT", 1, 50000)
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		holding.ID,
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{
+			ID:         "q-3",
+			FromAsset:  "BTC",
+			ToAsset:    "ETH",
+			FromAmount: 1,
+			ToAmount:   19.8,
+			Fee:        12,
+			FeeAsset:   "USDT",
+		},
+		60000,
+		3000,
+	)
+
+	// Act
+	recorded, err := ledgerService.Record(ctx, entry, holding)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 50000.0, recorded.CostBasis)
+	assert.Equal(t, 59400.0, recorded.Proceeds)
+	assert.Equal(t, 12.0, recorded.FeeValue)
+	assert.Equal(t, 59400.0-50000.0-12.0, recorded.RealisedPnL)
+	assert.InDelta(t, 0.01, recorded.Slippage, 0.0000001)
+}
+
+func TestRecordLedgerEntryReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New()})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		uuid.New(),
+		uuid.New(),
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-4", FromAsset: "BTC", ToAsset: "USDT", FromAmount: 1, ToAmount: 60000},
+		60000,
+		1,
+	)
+
+	// Act
+	_, err := ledgerService.Record(ctx, entry, nil)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+func TestRecordLedgerEntryReturnsErrorIfInvalid(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		uuid.New(),
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-5", FromAsset: "BTC", ToAsset: "BTC", FromAmount: 1, ToAmount: 1},
+		60000,
+		60000,
+	)
+
+	// Act
+	_, err := ledgerService.Record(ctx, entry, nil)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidLedgerEntryAsset, err)
+}
+
+func TestGetHoldingPnLFallsBackToEntryPriceWithoutLedger(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	holding := newLedgerTestHolding(userID, "ETHUSDT", 2, 3000)
+
+	// Act
+	pnl, err := ledgerService.GetHoldingPnL(ctx, holding, 3300)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 6000.0, pnl.CostBasis)
+	assert.Equal(t, 6600.0, pnl.MarketValue)
+	assert.Equal(t, 600.0, pnl.UnrealisedPnL)
+	assert.Equal(t, 0.0, pnl.RealisedPnL)
+}
+
+func TestGetUserPnLAggregatesRealisedAndUnrealised(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	btcHolding := newLedgerTestHolding(userID, "BTCUSDT", 1, 50000)
+	ethHolding := newLedgerTestHolding(userID, "ETHUSDT", 20, 3000)
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		btcHolding.ID,
+		ethHolding.ID,
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-6", FromAsset: "BTC", ToAsset: "ETH", FromAmount: 1, ToAmount: 20},
+		60000,
+		3000,
+	)
+	_, err := ledgerService.Record(ctx, entry, btcHolding)
+	assert.NoError(t, err)
+	btcHolding.Status = constants.HoldingStatusClosed
+	holdings := entities.Holdings{*btcHolding, *ethHolding}
+
+	// Act
+	pnl, err := ledgerService.GetUserPnL(ctx, userID, &holdings, map[string]float64{
+		"ETHUSDT": 3100,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 10000.0, pnl.RealisedPnL)
+	assert.Equal(t, 2000.0, pnl.UnrealisedPnL)
+	assert.Len(t, pnl.Holdings, 1)
+	assert.Equal(t, ethHolding.ID, pnl.Holdings[0].HoldingID)
+	assert.Equal(t, 60000.0, pnl.Holdings[0].CostBasis)
+}
+
+// --- TaxLotService Tests ---
+
+// disposeTestLots seeds three BTC lots and sells 1.5 BTC for 90000 USDT.
+func disposeTestLots(t *testing.T, method string) (*entities.TaxLotDisposals, *entities.TaxLots) {
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	lotFactory := &entities.TaxLotFactory{}
+	lots := []*entities.TaxLot{
+		lotFactory.NewTaxLot(userID, uuid.New(), "BTC", 1, 30000, time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		lotFactory.NewTaxLot(userID, uuid.New(), "BTC", 1, 50000, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		lotFactory.NewTaxLot(userID, uuid.New(), "BTC", 1, 40000, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	for _, lot := range lots {
+		_, err := taxLotRepository.Create(ctx, lot)
+		assert.NoError(t, err)
+	}
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		uuid.New(),
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-tax", FromAsset: "BTC", ToAsset: "USDT", FromAmount: 1.5, ToAmount: 90000},
+		60000,
+		1,
+	)
+	entry.CreatedAt = time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC)
+	entry.Settle(60000)
+	disposals, err := taxLotService.RecordEntry(ctx, entry, method)
+	assert.NoError(t, err)
+	remaining, err := taxLotService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	return disposals, remaining
+}
+
+func TestRecordTaxLotEntryDisposesFIFO(t *testing.T) {
+	// Act
+	disposals, lots := disposeTestLots(t, constants.CostBasisMethodFIFO)
+
+	// Assert
+	assert.Equal(t, 2, len(*disposals))
+	assert.Equal(t, 30000.0, (*disposals)[0].CostBasis)
+	assert.Equal(t, 60000.0, (*disposals)[0].Proceeds)
+	assert.Equal(t, 30000.0, (*disposals)[0].Gain)
+	assert.Equal(t, constants.TaxTermLong, (*disposals)[0].Term())
+	assert.Equal(t, 25000.0, (*disposals)[1].CostBasis)
+	assert.Equal(t, 5000.0, (*disposals)[1].Gain)
+	assert.Equal(t, constants.TaxTermShort, (*disposals)[1].Term())
+	assert.Equal(t, 0.0, (*lots)[0].RemainingQuantity)
+	assert.Equal(t, 0.5, (*lots)[1].RemainingQuantity)
+	assert.Equal(t, 1.0, (*lots)[2].RemainingQuantity)
+}
+
+func TestRecordTaxLotEntryDisposesLIFO(t *testing.T) {
+	// Act
+	disposals, lots := disposeTestLots(t, constants.CostBasisMethodLIFO)
+
+	// Assert
+	assert.Equal(t, 2, len(*disposals))
+	assert.Equal(t, 40000.0, (*disposals)[0].CostBasis)
+	assert.Equal(t, 25000.0, (*disposals)[1].CostBasis)
+	assert.Equal(t, 1.0, (*lots)[0].RemainingQuantity)
+	assert.Equal(t, 0.5, (*lots)[1].RemainingQuantity)
+	assert.Equal(t, 0.0, (*lots)[2].RemainingQuantity)
+}
+
+func TestRecordTaxLotEntryDisposesHIFO(t *testing.T) {
+	// Act
+	disposals, lots := disposeTestLots(t, constants.CostBasisMethodHIFO)
+
+	// Assert
+	assert.Equal(t, 2, len(*disposals))
+	assert.Equal(t, 50000.0, (*disposals)[0].CostBasis)
+	assert.Equal(t, 10000.0, (*disposals)[0].Gain)
+	assert.Equal(t, 20000.0, (*disposals)[1].CostBasis)
+	assert.Equal(t, 1.0, (*lots)[0].RemainingQuantity)
+	assert.Equal(t, 0.0, (*lots)[1].RemainingQuantity)
+	assert.Equal(t, 0.5, (*lots)[2].RemainingQuantity)
+}
+
+func TestRecordTaxLotEntryFallsBackToLedgerCostAndOpensLot(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		uuid.New(),
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-untracked", FromAsset: "BTC", ToAsset: "ETH", FromAmount: 1, ToAmount: 20},
+		60000,
+		3000,
+	)
+	entry.Settle(45000)
+
+	// Act
+	disposals, err := taxLotService.RecordEntry(ctx, entry, constants.CostBasisMethodFIFO)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*disposals))
+	assert.Equal(t, uuid.Nil, (*disposals)[0].LotID)
+	assert.Equal(t, 45000.0, (*disposals)[0].CostBasis)
+	assert.Equal(t, 15000.0, (*disposals)[0].Gain)
+	assert.Equal(t, constants.TaxTermUnknown, (*disposals)[0].Term())
+	lots, err := taxLotService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*lots))
+	assert.Equal(t, "ETH", (*lots)[0].Asset)
+	assert.Equal(t, 20.0, (*lots)[0].RemainingQuantity)
+	assert.Equal(t, 3000.0, (*lots)[0].UnitCost)
+}
+
+func TestRecordTaxLotEntryReturnsErrorIfInvalidMethod(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+
+	// Act
+	_, err := taxLotService.RecordEntry(ctx, &entities.LedgerEntry{UserID: userID}, "average")
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidCostBasisMethod, err)
+}
+
+func TestExportYearlyTaxReportWritesDisposalsOfTheYear(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	lotFactory := &entities.TaxLotFactory{}
+	lot := lotFactory.NewTaxLot(userID, uuid.New(), "BTC", 1, 30000, time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC))
+	_, err := taxLotRepository.Create(ctx, lot)
+	assert.NoError(t, err)
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		uuid.New(),
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "q-report", FromAsset: "BTC", ToAsset: "USDT", FromAmount: 1, ToAmount: 42000.5},
+		42000,
+		1,
+	)
+	entry.CreatedAt = time.Date(2024, time.May, 2, 10, 0, 0, 0, time.UTC)
+	entry.Settle(30000)
+	_, err = taxLotService.RecordEntry(ctx, entry, constants.CostBasisMethodFIFO)
+	assert.NoError(t, err)
+	report := bytes.Buffer{}
+
+	// Act
+	err = taxLotService.ExportYearlyReport(ctx, userID, 2024, &report)
+
+	// Assert
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "asset,quantity,date_acquired,date_disposed,proceeds_usdt,cost_basis_usdt,gain_usdt,term,method,lot_id,reference", lines[0])
+	assert.Equal(t, "BTC,1,2023-03-01,2024-05-02,42000.50,30000.00,12000.50,long,fifo,"+lot.ID.String()+",q-report", lines[1])
+	emptyReport := bytes.Buffer{}
+	err = taxLotService.ExportYearlyReport(ctx, userID, 2023, &emptyReport)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(strings.Split(strings.TrimSpace(emptyReport.String()), "\n")))
+}
+
+func TestExportYearlyTaxReportReturnsErrorIfInvalidYear(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+
+	// Act
+	err := taxLotService.ExportYearlyReport(ctx, userID, time.Now().Year()+1, &bytes.Buffer{})
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidTaxYear, err)
+}
+
+// --- Protective exits Tests ---
+
+func newProtectiveExitPosition(
+	t *testing.T,
+	ctx echo.Context,
+	userID uuid.UUID,
+	preference *entities.TradingPreference,
+) *aggregate.TradingPositionAggregate {
+	holding := newLedgerTestHolding(userID, "SOLUSDT", 4, 100)
+	_, err := holdingService.Create(ctx, holding)
+	assert.NoError(t, err)
+	return &aggregate.TradingPositionAggregate{
+		Holding:           holding,
+		TradingPreference: preference,
+	}
+}
+
+func newProtectiveExitTradingService() *DefaultTradingService {
+	return &DefaultTradingService{
+		HoldingService: holdingService.(*DefaultHoldingService),
+		UacService:     uacService,
+	}
+}
+
+func TestProtectiveExitSignalTriggersFixedStopLoss(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	position := newProtectiveExitPosition(t, ctx, userID, &entities.TradingPreference{
+		StopLossPercentage: 5,
+	})
+	tradingService := newProtectiveExitTradingService()
+
+	// Act
+	signal, err := tradingService.ProtectiveExitSignal(ctx, position, 94, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionStopLoss, signal.Action)
+	assert.Equal(t, 95.0, signal.TriggerPrice)
+	assert.Equal(t, 4.0, signal.Quantity)
+}
+
+func TestProtectiveExitSignalTrailsHighWaterMark(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	position := newProtectiveExitPosition(t, ctx, userID, &entities.TradingPreference{
+		TrailingStopATRMultiple: 2,
+	})
+	tradingService := newProtectiveExitTradingService()
+	atr := 5.0
+	signal, err := tradingService.ProtectiveExitSignal(ctx, position, 130, &atr)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionHold, signal.Action)
+
+	// Act
+	signal, err = tradingService.ProtectiveExitSignal(ctx, position, 119, &atr)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionTrailingStop, signal.Action)
+	assert.Equal(t, 120.0, signal.TriggerPrice)
+	stored, err := holdingService.GetByID(ctx, position.Holding.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 130.0, stored.HighWaterMark)
+}
+
+func TestProtectiveExitSignalSkipsTrailingStopWithoutATR(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	position := newProtectiveExitPosition(t, ctx, userID, &entities.TradingPreference{
+		TrailingStopATRMultiple: 2,
+	})
+	position.Holding.HighWaterMark = 200
+
+	// Act
+	signal, err := newProtectiveExitTradingService().ProtectiveExitSignal(ctx, position, 101, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionHold, signal.Action)
+}
+
+func TestProtectiveExitSignalClimbsTakeProfitLadder(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	position := newProtectiveExitPosition(t, ctx, userID, &entities.TradingPreference{
+		TakeProfitLadder: valueobjects.TakeProfitLadder{
+			{TargetPercentage: 10, SellFraction: 0.25},
+			{TargetPercentage: 20, SellFraction: 0.25},
+			{TargetPercentage: 30, SellFraction: 0.5},
+		},
+	})
+	tradingService := newProtectiveExitTradingService()
+	signal, err := tradingService.ProtectiveExitSignal(ctx, position, 105, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionHold, signal.Action)
+
+	// Act
+	signal, err = tradingService.ProtectiveExitSignal(ctx, position, 121, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionTakeProfit, signal.Action)
+	assert.Equal(t, 2, signal.Levels)
+	assert.InDelta(t, 120.0, signal.TriggerPrice, 0.0000001)
+	assert.Equal(t, 2.0, signal.Quantity)
+
+	// Act - the last level sells whatever is left
+	position.Holding.TakeProfitLevelsHit += signal.Levels
+	position.Holding.Quantity = 1.99
+	signal, err = tradingService.ProtectiveExitSignal(ctx, position, 131, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.ProtectiveExitActionTakeProfit, signal.Action)
+	assert.Equal(t, 1, signal.Levels)
+	assert.Equal(t, 1.99, signal.Quantity)
+}
+
+func TestCreateTradingPreferenceReturnsErrorIfInvalidTakeProfitLadder(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.TakeProfitLadder = valueobjects.TakeProfitLadder{
+		{TargetPercentage: 20, SellFraction: 0.5},
+		{TargetPercentage: 10, SellFraction: 0.5},
+	}
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidTakeProfitLadder, err)
+}
+
+func TestCreateTradingPreferenceStoresProtectiveExits(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.StopLossPercentage = 7.5
+	tp.TrailingStopATRMultiple = 3
+	tp.TakeProfitLadder = valueobjects.TakeProfitLadder{
+		{TargetPercentage: 15, SellFraction: 0.5},
+		{TargetPercentage: 40, SellFraction: 0.5},
+	}
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.NoError(t, err)
+	stored, err := tradingPreferenceService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 7.5, stored.StopLossPercentage)
+	assert.Equal(t, 3.0, stored.TrailingStopATRMultiple)
+	assert.Equal(t, tp.TakeProfitLadder, stored.TakeProfitLadder)
+}
+
+// --- Position sizing Tests ---
+
+func newPositionSizingPreference(
+	t *testing.T,
+	ctx echo.Context,
+	userID uuid.UUID,
+	method string,
+	positionFraction float64,
+	maxConcurrentHoldings int,
+) *entities.TradingPreference {
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "ADAUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.PositionSizingMethod = method
+	tp.PositionFraction = positionFraction
+	tp.MaxConcurrentHoldings = maxConcurrentHoldings
+	_, err := tradingPreferenceService.Create(ctx, tp)
+	assert.NoError(t, err)
+	return tp
+}
+
+func newPositionSizingCandidate(symbol string, price float64, atr *float64) entities.MarketData {
+	score := 0.9
+	return entities.MarketData{
+		Symbol: symbol,
+		Close:  price,
+		ATR:    atr,
+		Score:  &score,
+	}
+}
+
+func newPositionSizingTradingService() *DefaultTradingService {
+	return &DefaultTradingService{
+		TradingPreferenceService: tradingPreferenceService.(*DefaultTradingPreferenceService),
+		HoldingService:           holdingService.(*DefaultHoldingService),
+		UacService:               uacService,
+	}
+}
+
+func TestGetPortfolioOnlyCountsOpenPositions(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	btc := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+	cash := newLedgerTestHolding(userID, "USDT", 500, 1)
+	eth := newLedgerTestHolding(userID, "ETHUSDT", 1, 3000)
+	eth.Status = constants.HoldingStatusClosed
+	for _, holding := range []*entities.Holding{btc, cash, eth} {
+		_, err := holdingService.Create(ctx, holding)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	portfolio, err := newPositionSizingTradingService().GetPortfolio(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, portfolio.Holdings, 2)
+	assert.Len(t, portfolio.Positions(), 1)
+	assert.True(t, portfolio.Holds("BTCUSDT"))
+	assert.False(t, portfolio.Holds("ETHUSDT"))
+	assert.Equal(t, 1, portfolio.OpenSlots())
+	assert.Equal(t, 1000.0+6500.0, portfolio.Equity(1000, map[string]float64{"BTCUSDT": 65000}))
+}
+
+func TestPlanAllocationsSpreadsFixedFractionAcrossSlots(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 25, 3)
+	_, err := holdingService.Create(ctx, newLedgerTestHolding(userID, "BTCUSDT", 1, 100))
+	assert.NoError(t, err)
+	tradingService := newPositionSizingTradingService()
+	portfolio, err := tradingService.GetPortfolio(ctx, userID)
+	assert.NoError(t, err)
+	candidates := entities.MarketDatas{
+		newPositionSizingCandidate("BTCUSDT", 100, nil),
+		newPositionSizingCandidate("ETHUSDT", 3000, nil),
+		newPositionSizingCandidate("SOLUSDT", 150, nil),
+		newPositionSizingCandidate("ADAUSDT", 0.5, nil),
+	}
+
+	// Act
+	allocations := tradingService.PlanAllocations(
+		ctx,
+		portfolio,
+		300,
+		map[string]float64{"BTCUSDT": 100},
+		candidates,
+	)
+
+	// Assert
+	assert.Len(t, allocations, 2)
+	assert.Equal(t, "ETHUSDT", allocations[0].Symbol)
+	assert.Equal(t, 100.0, allocations[0].Amount)
+	assert.Equal(t, 0.9, allocations[0].Score)
+	assert.Equal(t, "SOLUSDT", allocations[1].Symbol)
+	assert.Equal(t, 100.0, allocations[1].Amount)
+}
+
+func TestPlanAllocationsSizesByVolatilityParity(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodVolatilityParity, 100, 3)
+	tradingService := newPositionSizingTradingService()
+	portfolio, err := tradingService.GetPortfolio(ctx, userID)
+	assert.NoError(t, err)
+	ethATR := 150.0
+	solATR := 3.0
+	candidates := entities.MarketDatas{
+		newPositionSizingCandidate("BTCUSDT", 60000, nil),
+		newPositionSizingCandidate("ETHUSDT", 3000, &ethATR),
+		newPositionSizingCandidate("SOLUSDT", 150, &solATR),
+	}
+
+	// Act
+	allocations := tradingService.PlanAllocations(ctx, portfolio, 10000, nil, candidates)
+
+	// Assert
+	// A one ATR move costs 1% of the equity: 100 * 3000 / 150 and 100 * 150 / 3
+	assert.Len(t, allocations, 2)
+	assert.Equal(t, "ETHUSDT", allocations[0].Symbol)
+	assert.InDelta(t, 2000.0, allocations[0].Amount, 0.0000001)
+	assert.Equal(t, "SOLUSDT", allocations[1].Symbol)
+	assert.InDelta(t, 5000.0, allocations[1].Amount, 0.0000001)
+}
+
+func TestPlanAllocationsSizesByRiskPerTradeWithinCash(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodRiskPerTrade, 40, 4)
+	tp.StopLossPercentage = 5
+	tp.TrailingStopATRMultiple = 2
+	tradingService := newPositionSizingTradingService()
+	portfolio, err := tradingService.GetPortfolio(ctx, userID)
+	assert.NoError(t, err)
+	portfolio.TradingPreference = tp
+	btcATR := 600.0
+	candidates := entities.MarketDatas{
+		newPositionSizingCandidate("BTCUSDT", 60000, &btcATR),
+		newPositionSizingCandidate("ETHUSDT", 3000, nil),
+		newPositionSizingCandidate("SOLUSDT", 150, nil),
+		newPositionSizingCandidate("ADAUSDT", 0.5, nil),
+	}
+
+	// Act
+	allocations := tradingService.PlanAllocations(ctx, portfolio, 10000, nil, candidates)
+
+	// Assert
+	// The trailing stop of BTC is 2% away, so 100 / 0.02 is capped at 40%
+	// of the equity. The others use the 5% stop, 100 / 0.05.
+	assert.Len(t, allocations, 4)
+	assert.InDelta(t, 4000.0, allocations[0].Amount, 0.0000001)
+	assert.InDelta(t, 2000.0, allocations[1].Amount, 0.0000001)
+	assert.InDelta(t, 2000.0, allocations[2].Amount, 0.0000001)
+	assert.InDelta(t, 2000.0, allocations[3].Amount, 0.0000001)
+
+	// Act - without any stop the risk cannot be sized
+	portfolio.TradingPreference.StopLossPercentage = 0
+	allocations = tradingService.PlanAllocations(ctx, portfolio, 10000, nil, candidates)
+
+	// Assert
+	assert.Len(t, allocations, 1)
+	assert.Equal(t, "BTCUSDT", allocations[0].Symbol)
+}
+
+func TestPlanAllocationsReturnsNothingWhenSlotsAreTaken(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 100, 1)
+	_, err := holdingService.Create(ctx, newLedgerTestHolding(userID, "BTCUSDT", 1, 100))
+	assert.NoError(t, err)
+	tradingService := newPositionSizingTradingService()
+	portfolio, err := tradingService.GetPortfolio(ctx, userID)
+	assert.NoError(t, err)
+
+	// Act
+	allocations := tradingService.PlanAllocations(
+		ctx,
+		portfolio,
+		1000,
+		nil,
+		entities.MarketDatas{newPositionSizingCandidate("ETHUSDT", 3000, nil)},
+	)
+
+	// Assert
+	assert.Empty(t, allocations)
+}
+
+func TestCreateTradingPreferenceReturnsErrorIfInvalidMaxConcurrentHoldings(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.MaxConcurrentHoldings = 0
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidMaxHoldings, err)
+}
+
+// --- Risk limits Tests ---
+
+func newRiskTestPreference(
+	t *testing.T,
+	ctx echo.Context,
+	userID uuid.UUID,
+	configure func(tp *entities.TradingPreference),
+) *entities.TradingPreference {
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT", "ETHUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	configure(tp)
+	_, err := tradingPreferenceService.Create(ctx, tp)
+	assert.NoError(t, err)
+	return tp
+}
+
+func TestGetRiskSnapshotMeasuresTodaysActivity(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.PeakEquity = 7000
+	})
+	_, err := holdingService.Create(ctx, newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000))
+	assert.NoError(t, err)
+	_, err = holdingService.Create(ctx, newLedgerTestHolding(userID, "ETHUSDT", 1, 3000))
+	assert.NoError(t, err)
+	for _, realisedPnL := range []float64{-80, 30} {
+		_, err = ledgerEntryRepository.Create(ctx, &entities.LedgerEntry{
+			ID:          uuid.New(),
+			UserID:      userID,
+			EntryType:   constants.LedgerEntryTypeConversion,
+			FromAsset:   "SOL",
+			FromDelta:   -1,
+			ToAsset:     "USDT",
+			ToDelta:     100,
+			RealisedPnL: realisedPnL,
+			CreatedAt:   time.Now().UTC(),
+		})
+		assert.NoError(t, err)
+	}
+	orderFactory := &entities.OrderFactory{}
+	for i := 0; i < 3; i++ {
+		_, err = orderService.Create(ctx, orderFactory.NewOrder(
+			userID,
+			"BTCUSDT",
+			0.1,
+			60000,
+			constants.OrderTypeEntry,
+		))
+		assert.NoError(t, err)
+	}
+	tradingService := newPositionSizingTradingService()
+	portfolio, err := tradingService.GetPortfolio(ctx, userID)
+	assert.NoError(t, err)
+
+	// Act
+	snapshot, err := riskService.GetSnapshot(
+		ctx,
+		portfolio,
+		1000,
+		map[string]float64{"BTCUSDT": 50000},
+	)
+
+	// Assert
+	// ETH has no price, so it is valued at its entry price
+	assert.NoError(t, err)
+	assert.Equal(t, 9000.0, snapshot.Equity)
+	assert.Equal(t, 9000.0, snapshot.PeakEquity)
+	assert.Equal(t, -50.0, snapshot.DailyRealisedPnL)
+	assert.Equal(t, 3, snapshot.TradesToday)
+	assert.Equal(t, map[string]float64{"BTCUSDT": 5000, "ETHUSDT": 3000}, snapshot.Exposures)
+}
+
+func TestEvaluateRiskLimitsKeepsPeakEquity(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.MaxDrawdownPercentage = 10
+		tp.PeakEquity = 10000
+	})
+
+	// Act
+	breach, err := riskService.Evaluate(ctx, tp, &valueobjects.RiskSnapshot{
+		Equity:     12000,
+		PeakEquity: 12000,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, breach)
+	stored, err := tradingPreferenceService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.True(t, stored.Operate)
+	assert.Equal(t, 12000.0, stored.PeakEquity)
+}
+
+func TestEvaluateRiskLimitsTripsKillSwitchOnDrawdown(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.MaxDrawdownPercentage = 10
+		tp.PeakEquity = 10000
+	})
+
+	// Act
+	breach, err := riskService.Evaluate(ctx, tp, &valueobjects.RiskSnapshot{
+		Equity:     8500,
+		PeakEquity: 10000,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.RiskLimitDrawdown, breach.Limit)
+	assert.Equal(t, 15.0, breach.Value)
+	assert.Equal(t, 10.0, breach.Threshold)
+	stored, err := tradingPreferenceService.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.False(t, stored.Operate)
+	assert.Equal(t, constants.RiskLimitDrawdown, stored.KillSwitchReason)
+	assert.NotNil(t, stored.KillSwitchTriggeredAt)
+}
+
+func TestEvaluateRiskLimitsTripsKillSwitchOnDailyLoss(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.MaxDailyLossPercentage = 5
+	})
+
+	// Act
+	breach, err := riskService.Evaluate(ctx, tp, &valueobjects.RiskSnapshot{
+		Equity:           9400,
+		PeakEquity:       9400,
+		DailyRealisedPnL: -600,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.RiskLimitDailyLoss, breach.Limit)
+	assert.InDelta(t, 6.0, breach.Value, 0.0000001)
+	assert.False(t, tp.Operate)
+}
+
+func TestEvaluateRiskLimitsTripsKillSwitchOnTradesPerDay(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.MaxTradesPerDay = 3
+	})
+
+	// Act
+	breach, err := riskService.Evaluate(ctx, tp, &valueobjects.RiskSnapshot{
+		Equity:      1000,
+		PeakEquity:  1000,
+		TradesToday: 3,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.RiskLimitTradesPerDay, breach.Limit)
+	assert.False(t, tp.Operate)
+}
+
+func TestEvaluateRiskLimitsTripsKillSwitchOnSymbolExposure(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.MaxSymbolExposurePercentage = 50
+	})
+
+	// Act
+	breach, err := riskService.Evaluate(ctx, tp, &valueobjects.RiskSnapshot{
+		Equity:     1000,
+		PeakEquity: 1000,
+		Exposures:  map[string]float64{"BTCUSDT": 500, "ETHUSDT": 450, "SOLUSDT": 600},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.RiskLimitSymbolExposure, breach.Limit)
+	assert.Equal(t, "SOLUSDT", breach.Symbol)
+	assert.Equal(t, 60.0, breach.Value)
+}
+
+func TestKillSwitchRequiresExplicitResume(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.PeakEquity = 10000
+	})
+	err := riskService.TripKillSwitch(ctx, tp, valueobjects.RiskBreach{
+		Limit:     constants.RiskLimitDrawdown,
+		Value:     25,
+		Threshold: 20,
+	})
+	assert.NoError(t, err)
+	tp.Operate = true
+
+	// Act
+	_, err = tradingPreferenceService.Update(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrKillSwitchEngaged, err)
+
+	// Act
+	resumed, err := riskService.ResumeTrading(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, resumed.Operate)
+	assert.False(t, resumed.KillSwitchEngaged())
+	assert.Nil(t, resumed.KillSwitchTriggeredAt)
+	assert.Equal(t, 0.0, resumed.PeakEquity)
+}
+
+func TestPlanAllocationsCapsSymbolExposure(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.MaxConcurrentHoldings = 2
+		tp.MaxSymbolExposurePercentage = 30
+	})
+	tradingService := newPositionSizingTradingService()
+	portfolio, err := tradingService.GetPortfolio(ctx, userID)
+	assert.NoError(t, err)
+
+	// Act
+	allocations := tradingService.PlanAllocations(
+		ctx,
+		portfolio,
+		1000,
+		nil,
+		entities.MarketDatas{
+			newPositionSizingCandidate("BTCUSDT", 60000, nil),
+			newPositionSizingCandidate("ETHUSDT", 3000, nil),
+		},
+	)
+
+	// Assert
+	assert.Len(t, allocations, 2)
+	assert.Equal(t, 300.0, allocations[0].Amount)
+	assert.Equal(t, 300.0, allocations[1].Amount)
+}
+
+func TestCreateTradingPreferenceReturnsErrorIfInvalidRiskLimit(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.MaxDrawdownPercentage = 120
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidRiskLimit, err)
+}
+
+// --- Market regime Tests ---
+
+// newMarketRegimeTradingService records the regime as the latest one and
+// returns a trading service reading it.
+func newMarketRegimeTradingService(t *testing.T, ctx echo.Context, regime string) *DefaultTradingService {
+	regimeService := marketRegimeService.(*markets.DefaultMarketRegimeService)
+	factory := entities.MarketRegimeFactory{}
+	_, err := regimeService.MarketRegimeRepository.Create(ctx, factory.NewMarketRegime(regime, 50, 1, 5, 10))
+	assert.NoError(t, err)
+	tradingService := newPositionSizingTradingService()
+	tradingService.MarketRegimeService = regimeService
+	return tradingService
+}
+
+func TestEntriesFrozenWhenMarketIsRiskOff(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tradingService := newMarketRegimeTradingService(t, ctx, constants.MarketRegimeRiskOff)
+
+	// Act
+	frozen, err := tradingService.EntriesFrozen(ctx, tp)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, frozen)
+}
+
+func TestEntriesNotFrozenWhenMarketIsNeutral(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tradingService := newMarketRegimeTradingService(t, ctx, constants.MarketRegimeNeutral)
+
+	// Act
+	frozen, err := tradingService.EntriesFrozen(ctx, tp)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, frozen)
+}
+
+func TestEntriesNotFrozenWithoutRiskOffAction(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tp.RiskOffAction = constants.RiskOffActionNone
+	tradingService := newMarketRegimeTradingService(t, ctx, constants.MarketRegimeRiskOff)
+
+	// Act
+	frozen, err := tradingService.EntriesFrozen(ctx, tp)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, frozen)
+}
+
+func TestAllocateCapitalIsFrozenOnRiskOffMarket(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tradingService := newMarketRegimeTradingService(t, ctx, constants.MarketRegimeRiskOff)
+
+	// Act
+	opened, err := tradingService.AllocateCapital(ctx, userID, "spot")
+
+	// Assert
+	assert.Nil(t, opened)
+	assert.Equal(t, errors.ErrMarketRegimeRiskOff, err)
+}
+
+func TestApplyMarketRegimeKeepsPositionsWhenFreezingEntries(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	btc := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+	_, err := holdingService.Create(ctx, btc)
+	assert.NoError(t, err)
+	tradingService := newMarketRegimeTradingService(t, ctx, constants.MarketRegimeRiskOff)
+
+	// Act
+	exited, err := tradingService.ApplyMarketRegime(ctx, userID, "spot")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, *exited)
+	stored, err := holdingService.GetByID(ctx, btc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.HoldingStatusOpen, stored.Status)
+}
+
+func TestCreateTradingPreferenceReturnsErrorIfInvalidRiskOffAction(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.RiskOffAction = "panic"
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidRiskOffAction, err)
+}
+
+// --- Execution mode Tests ---
+
+func newExecutionModeTradingService() *DefaultTradingService {
+	tradingService := newPositionSizingTradingService()
+	tradingService.OrderService = orderService.(*DefaultOrderService)
+	tradingService.LedgerService = ledgerService.(*DefaultLedgerService)
+	return tradingService
+}
+
+func TestGetConversionQuotePricesPaperBookAtTickers(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	tradingService := newExecutionModeTradingService()
+
+	// Act
+	quote, err := tradingService.getConversionQuote(
+		ctx,
+		constants.ExecutionModePaper,
+		uuid.New(),
+		"USDT",
+		"BTC",
+		1000,
+		1,
+		50000,
+		"spot",
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0.02, quote.ToAmount)
+	assert.InDelta(t, 50000.0, quote.InverseRatio, 0.0001)
+	converted, err := tradingService.acceptConversionQuote(ctx, constants.ExecutionModePaper, uuid.New(), quote)
+	assert.NoError(t, err)
+	assert.Nil(t, converted)
+}
+
+func TestAvailableQuantityReadsTheExchangeOfTheUserKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	krakenKey := newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeKraken)
+	factory := &stubExchangeAdapterFactory{
+		adapter: &stubExchangeAdapter{balances: map[string]float64{"BTC": 0.05}},
+	}
+	tradingService := newExecutionModeTradingService()
+	tradingService.ExchangeAdapterFactory = factory
+	tradingService.CredentialResolver = credentialResolver
+	holding := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+
+	// Act
+	available, err := tradingService.availableQuantity(ctx, holding)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0.05, available)
+	assert.Len(t, factory.keys, 1)
+	assert.Equal(t, krakenKey.ID, factory.keys[0].ID)
+}
+
+func TestAvailableQuantityReturnsErrorWithoutExchangeKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tradingService := newExecutionModeTradingService()
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: &stubExchangeAdapter{}}
+	tradingService.CredentialResolver = credentialResolver
+
+	// Act
+	_, err := tradingService.availableQuantity(ctx, newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000))
+
+	// Assert
+	assert.Equal(t, errors.ErrApiKeyNotFound, err)
+}
+
+func TestExecuteTakeProfitSellsNoMoreThanTheFreeBalance(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeBinance)
+	holding := newLedgerTestHolding(userID, "SOLUSDT", 4, 100)
+	_, err := holdingService.Create(ctx, holding)
+	assert.NoError(t, err)
+	adapter := &stubExchangeAdapter{
+		balances: map[string]float64{"SOL": 1},
+		prices:   map[string]float64{"SOLUSDT": 120},
+	}
+	tradingService := newApprovalTradingService(t, map[string]float64{"SOLUSDT": 120}, newTelegramStandIn(t))
+	tradingService.TaxLotService = taxLotService.(*DefaultTaxLotService)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: adapter}
+	tradingService.CredentialResolver = credentialResolver
+	signal := valueobjects.ProtectiveExitSignal{
+		Action:       constants.ProtectiveExitActionTakeProfit,
+		TriggerPrice: 120,
+		Quantity:     2,
+		Levels:       1,
+	}
+
+	// Act
+	err = tradingService.ExecuteTakeProfit(ctx, holding, signal, "spot")
+
+	// Assert
+	assert.NoError(t, err)
+	accepted := adapter.Accepted()
+	assert.Len(t, accepted, 1)
+	assert.Equal(t, 1.0, accepted[0].FromAmount)
+	assert.Equal(t, 3.0, holding.Quantity)
+}
+
+func TestSimulatedExecutionModesNeverAcceptQuotes(t *testing.T) {
+	for _, mode := range []string{constants.ExecutionModePaper, constants.ExecutionModeShadow} {
+		for _, book := range constants.ExecutionModes {
+			// Arrange
+			userID := uuid.New()
+			ctx := newApprovalTestContext(userID)
+			tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+			tp.ExecutionMode = mode
+			_, err := tradingPreferenceService.Update(ctx, tp)
+			assert.NoError(t, err)
+			newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeBinance)
+			holding := newLedgerTestHolding(userID, "SOLUSDT", 4, 100)
+			holding.Book = book
+			_, err = holdingService.Create(ctx, holding)
+			assert.NoError(t, err)
+			adapter := &stubExchangeAdapter{
+				balances: map[string]float64{"SOL": 4},
+				prices:   map[string]float64{"SOLUSDT": 90},
+			}
+			tradingService := newApprovalTradingService(
+				t,
+				map[string]float64{"SOL": 90, "SOLUSDT": 90, constants.LedgerQuoteAsset: 1},
+				newTelegramStandIn(t),
+			)
+			tradingService.TaxLotService = taxLotService.(*DefaultTaxLotService)
+			tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: adapter}
+			tradingService.CredentialResolver = credentialResolver
+
+			// Act
+			err = tradingService.ExecuteStopLoss(ctx, holding, "spot")
+
+			// Assert
+			assert.NoError(t, err, "%s mode, %s holding", mode, book)
+			assert.Empty(t, adapter.Accepted(), "%s mode, %s holding", mode, book)
+			stored, err := holdingService.GetByID(ctx, holding.ID)
+			assert.NoError(t, err)
+			if book == mode {
+				assert.Equal(t, constants.HoldingStatusClosed, stored.Status)
+				continue
+			}
+			// Holdings of another book stay as they are, their exit is booked
+			// through a parallel copy
+			assert.Equal(t, constants.HoldingStatusOpen, stored.Status, "%s mode, %s holding", mode, book)
+			filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+				"symbol": "SOLUSDT",
+				"status": constants.HoldingStatusClosed,
+				"book":   mode,
+			}, "created_at", "desc", 0, 10)
+			closed, err := holdingService.GetAll(ctx, filters)
+			assert.NoError(t, err)
+			assert.Len(t, *closed, 1, "%s mode, %s holding", mode, book)
+		}
+	}
+}
+
+func TestExecuteStopLossPricesLiveBookOnTheExchangeOfTheUser(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeKraken)
+	holding := newLedgerTestHolding(userID, "SOLUSDT", 4, 100)
+	_, err := holdingService.Create(ctx, holding)
+	assert.NoError(t, err)
+	adapter := &stubExchangeAdapter{
+		balances: map[string]float64{"SOL": 4},
+		prices:   map[string]float64{"SOLUSDT": 90},
+	}
+	// The market data exchange has no price to give
+	tradingService := newApprovalTradingService(t, map[string]float64{}, newTelegramStandIn(t))
+	tradingService.TaxLotService = taxLotService.(*DefaultTaxLotService)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: adapter}
+	tradingService.CredentialResolver = credentialResolver
+
+	// Act
+	err = tradingService.ExecuteStopLoss(ctx, holding, "spot")
+
+	// Assert
+	assert.NoError(t, err)
+	accepted := adapter.Accepted()
+	assert.Len(t, accepted, 1)
+	assert.Equal(t, 360.0, accepted[0].ToAmount)
+	assert.Equal(t, 90.0, holding.ExitPrice)
+}
+
+func TestExecuteTradeBooksPartialConversionAsASale(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.Watchlist = []string{"PARTAUSDT", "PARTBUSDT"}
+		tp.RiskOffAction = constants.RiskOffActionNone
+	})
+	newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeKraken)
+	holding := newLedgerTestHolding(userID, "PARTAUSDT", 4, 100)
+	_, err := holdingService.Create(ctx, holding)
+	assert.NoError(t, err)
+	marketDataFactory := &entities.MarketDataFactory{}
+	marketData := marketDataFactory.NewMarketDataFromEvent(uuid.New(), "PARTBUSDT", time.Now().UTC(), 10, 10, 10, 10, 1)
+	score := 0.9
+	marketData.Score = &score
+	adapter := &stubExchangeAdapter{
+		balances: map[string]float64{"PARTA": 4, constants.LedgerQuoteAsset: 0},
+		prices:   map[string]float64{"PARTAUSDT": 90, "PARTBUSDT": 10},
+		partial:  true,
+	}
+	tradingService := newDigestTradingService(
+		t,
+		map[string]float64{"PARTAUSDT": 90, "PARTBUSDT": 10, constants.LedgerQuoteAsset: 0},
+		newTelegramStandIn(t),
+	)
+	tradingService.RiskService = riskService.(*DefaultRiskService)
+	tradingService.TaxLotService = taxLotService.(*DefaultTaxLotService)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: adapter}
+	tradingService.CredentialResolver = credentialResolver
+
+	// Act
+	err = tradingService.ExecuteTrade(ctx, holding, "PARTB", marketData, "spot")
+
+	// Assert
+	assert.Equal(t, errors.ErrConversionIncomplete, err)
+	stored, err := holdingService.GetByID(ctx, holding.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.HoldingStatusClosed, stored.Status)
+	assert.Equal(t, 90.0, stored.ExitPrice)
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"from_holding_id": holding.ID,
+	}, "created_at", "desc", 0, 10)
+	entries, err := ledgerService.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, *entries, 1)
+	assert.Equal(t, constants.LedgerQuoteAsset, (*entries)[0].ToAsset)
+	assert.Equal(t, 360.0, (*entries)[0].ToDelta)
+	filters = filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"symbol": "PARTBUSDT",
+	}, "created_at", "desc", 0, 10)
+	orders, err := orderService.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Len(t, *orders, 1)
+	assert.Equal(t, constants.OrderStatusFailed, (*orders)[0].Status)
+	holdings, err := holdingService.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Empty(t, *holdings)
+}
+
+func TestBookLedgerEntryKeepsSimulatedEntriesOutOfTheLedger(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	holding := newLedgerTestHolding(userID, "BTCUSDT", 0.5, 60000)
+	holding.Book = constants.ExecutionModeShadow
+	entry := ledgerEntryFactory.NewConversionEntry(
+		userID,
+		holding.ID,
+		uuid.New(),
+		uuid.New(),
+		&entities.ExchangeConversionQuote{ID: "simulated-1", FromAsset: "BTC", ToAsset: "USDT", FromAmount: 0.5, ToAmount: 33000},
+		66000,
+		1,
+	)
+
+	// Act
+	newExecutionModeTradingService().bookLedgerEntry(
+		ctx,
+		entry,
+		holding,
+		holding.Book,
+		constants.CostBasisMethodFIFO,
+	)
+
+	// Assert
+	assert.Equal(t, 30000.0, entry.CostBasis)
+	assert.Equal(t, 3000.0, entry.RealisedPnL)
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{"user_id": userID}, "created_at", "desc", 1, 10)
+	entries, err := ledgerService.GetAll(ctx, filters)
+	assert.NoError(t, err)
+	assert.Empty(t, *entries)
+}
+
+func TestGetPortfolioOnlyHoldsTheExecutionModeBook(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tp := newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	tp.ExecutionMode = constants.ExecutionModeShadow
+	_, err := tradingPreferenceService.Update(ctx, tp)
+	assert.NoError(t, err)
+	live := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+	shadow := newLedgerTestHolding(userID, "ETHUSDT", 1, 3000)
+	shadow.Book = constants.ExecutionModeShadow
+	for _, holding := range []*entities.Holding{live, shadow} {
+		_, err := holdingService.Create(ctx, holding)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	portfolio, err := newExecutionModeTradingService().GetPortfolio(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, portfolio.Holdings, 1)
+	assert.True(t, portfolio.Holds("ETHUSDT"))
+	assert.False(t, portfolio.Holds("BTCUSDT"))
+}
+
+func TestGetBookSummariesComparesBooksSideBySide(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	orderFactory := &entities.OrderFactory{}
+	holdings := map[string]float64{
+		constants.ExecutionModeLive:   120,
+		constants.ExecutionModeShadow: 150,
+	}
+	for book, profit := range holdings {
+		holding := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+		holding.Status = constants.HoldingStatusClosed
+		holding.Profit = profit
+		holding.Book = book
+		_, err := holdingService.Create(ctx, holding)
+		assert.NoError(t, err)
+		for _, tradeType := range []string{constants.OrderTypeEntry, constants.OrderTypeTakeProfit} {
+			order := orderFactory.NewOrder(userID, "BTCUSDT", 0.1, 60000, tradeType)
+			order.Book = book
+			_, err := orderService.Create(ctx, order)
+			assert.NoError(t, err)
+		}
+	}
+	cash := newLedgerTestHolding(userID, constants.LedgerQuoteAsset, 500, 1)
+	cash.Book = constants.ExecutionModeShadow
+	_, err := holdingService.Create(ctx, cash)
+	assert.NoError(t, err)
+
+	// Act
+	summaries, err := newExecutionModeTradingService().GetBookSummaries(ctx, userID, time.Now().Add(-time.Hour))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, valueobjects.BookSummaries{
+		{Book: constants.ExecutionModeLive, Orders: 2, ClosedPositions: 1, RealisedPnL: 120},
+		{Book: constants.ExecutionModePaper},
+		{Book: constants.ExecutionModeShadow, Orders: 2, ClosedPositions: 1, RealisedPnL: 150},
+	}, summaries)
+}
+
+func TestCreateTradingPreferenceReturnsErrorIfInvalidExecutionMode(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.ExecutionMode = "dry"
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidExecutionMode, err)
+}
+
+// --- Trade approval Tests ---
+
+const approvalTestChatID = 424242
+
+// newApprovalTradingService quotes the given prices and sends notifications
+// to the telegram stand-in.
+func newApprovalTradingService(
+	t *testing.T,
+	prices map[string]float64,
+	standIn *telegramStandIn,
+) *DefaultTradingService {
+	tradingService := newExecutionModeTradingService()
+	tradingService.TradeProposalService = tradeProposalService.(*DefaultTradeProposalService)
+	tradingService.ExchangeService = newTickerStandIn(t, prices)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{
+		adapter: &stubExchangeAdapter{prices: prices},
+	}
+	tradingService.CredentialResolver = &exchangeCredentialResolver{}
+	telegramClient := standIn.Client()
+	tradingService.NotificationService = notifications.NewDefaultNotificationService(
+		&telegramCredentialResolver{chatID: approvalTestChatID},
+		telegramChatRepository,
+		pairingCodeRepository,
+		channelRepository,
+		deliveryRepository,
+		notificationPreferenceRepository,
+		digestRepository,
+		uacService,
+		telegramClient,
+		map[string]notifications.Notifier{constants.NotificationChannelTelegram: telegramClient},
+	)
+	return tradingService
+}
+
+func newApprovalTestContext(userID uuid.UUID) echo.Context {
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx := echo.New().NewContext(request, httptest.NewRecorder())
+	ctx.Set("user", &entities.User{ID: userID})
+	return ctx
+}
+
+func newTestTradeProposal(
+	t *testing.T,
+	ctx echo.Context,
+	userID uuid.UUID,
+	timeout time.Duration,
+) *entities.TradeProposal {
+	holding := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+	_, err := holdingService.Create(ctx, holding)
+	assert.NoError(t, err)
+	proposalFactory := &entities.TradeProposalFactory{}
+	proposal := proposalFactory.NewTradeProposal(userID, holding.ID, "BTCUSDT", "ETHUSDT", 3000, "spot", timeout)
+	_, err = tradeProposalService.Create(ctx, proposal)
+	assert.NoError(t, err)
+	return proposal
+}
+
+func TestProposeTradeAsksForApprovalWithInlineButtons(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	holding := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+	standIn := newTelegramStandIn(t)
+	tradingService := newApprovalTradingService(t, map[string]float64{"ETHUSDT": 3000}, standIn)
+
+	// Act
+	proposal, err := tradingService.ProposeTrade(ctx, holding, "ETHUSDT", "spot")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusPending, proposal.Status)
+	assert.Equal(t, holding.ID, proposal.HoldingID)
+	assert.Equal(t, 3000.0, proposal.ProposedPrice)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), proposal.ExpiresAt, time.Minute)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusPending, stored.Status)
+	messages := standIn.Requests("sendMessage")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, fmt.Sprint(approvalTestChatID), messages[0].Params["chat_id"])
+	markup := fmt.Sprint(messages[0].Params["reply_markup"])
+	assert.Contains(t, markup, "approve_trade|"+proposal.ID.String())
+	assert.Contains(t, markup, "reject_trade|"+proposal.ID.String())
+}
+
+func TestProposeTradeKeepsProposalAwaitingApproval(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	holding := newLedgerTestHolding(userID, "BTCUSDT", 0.1, 60000)
+	standIn := newTelegramStandIn(t)
+	tradingService := newApprovalTradingService(t, map[string]float64{"ETHUSDT": 3000}, standIn)
+	first, err := tradingService.ProposeTrade(ctx, holding, "ETHUSDT", "spot")
+	assert.NoError(t, err)
+
+	// Act
+	second, err := tradingService.ProposeTrade(ctx, holding, "ETHUSDT", "spot")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Len(t, standIn.Requests("sendMessage"), 1)
+}
+
+func TestDecideTradeProposalStoresRejection(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	tradingService := newApprovalTradingService(t, map[string]float64{}, newTelegramStandIn(t))
+
+	// Act
+	decided, err := tradingService.DecideTradeProposal(ctx, proposal.ID, false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusRejected, decided.Status)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusRejected, stored.Status)
+	assert.NotNil(t, stored.DecidedAt)
+}
+
+func TestDecideTradeProposalReturnsErrorIfExpired(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	proposal := newTestTradeProposal(t, ctx, userID, -time.Minute)
+	tradingService := newApprovalTradingService(t, map[string]float64{"ETHUSDT": 3000}, newTelegramStandIn(t))
+
+	// Act
+	decided, err := tradingService.DecideTradeProposal(ctx, proposal.ID, true)
+
+	// Assert
+	assert.Equal(t, errors.ErrTradeProposalExpired, err)
+	assert.Equal(t, constants.TradeProposalStatusExpired, decided.Status)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusExpired, stored.Status)
+}
+
+func TestDecideTradeProposalLosesTheClaimToAnotherDecision(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	// Both decisions read the proposal while it was still pending
+	stale := *proposal
+	assert.NoError(t, tradeProposalService.Claim(ctx, proposal))
+
+	// Act
+	err := tradeProposalService.Claim(ctx, &stale)
+
+	// Assert
+	assert.Equal(t, errors.ErrTradeProposalDecided, err)
+	assert.Equal(t, constants.TradeProposalStatusPending, stale.Status)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusDeciding, stored.Status)
+}
+
+func TestDecideTradeProposalFailsWhenPriceDrifted(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	tradingService := newApprovalTradingService(t, map[string]float64{"ETHUSDT": 3100}, newTelegramStandIn(t))
+
+	// Act
+	decided, err := tradingService.DecideTradeProposal(ctx, proposal.ID, true)
+
+	// Assert
+	assert.Equal(t, errors.ErrTradeProposalPriceDrift, err)
+	assert.Equal(t, constants.TradeProposalStatusFailed, decided.Status)
+	stored, err := tradeProposalService.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusFailed, stored.Status)
+	assert.Equal(t, 3100.0, stored.DecisionPrice)
+	assert.Equal(t, errors.ErrTradeProposalPriceDrift.Error(), stored.Reason)
+}
+
+func TestDecideTradeProposalPricesLiveBookOnTheExchangeOfTheUser(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	// The market data exchange still quotes the proposed price
+	tradingService := newApprovalTradingService(t, map[string]float64{"ETHUSDT": 3000}, newTelegramStandIn(t))
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{
+		adapter: &stubExchangeAdapter{prices: map[string]float64{"ETHUSDT": 3100}},
+	}
+
+	// Act
+	decided, err := tradingService.DecideTradeProposal(ctx, proposal.ID, true)
+
+	// Assert
+	assert.Equal(t, errors.ErrTradeProposalPriceDrift, err)
+	assert.Equal(t, 3100.0, decided.DecisionPrice)
+}
+
+func TestDecideTradeProposalReturnsErrorIfAlreadyDecided(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	newPositionSizingPreference(t, ctx, userID, constants.PositionSizingMethodFixedFraction, 50, 2)
+	proposal := newTestTradeProposal(t, ctx, userID, time.Hour)
+	tradingService := newApprovalTradingService(t, map[string]float64{}, newTelegramStandIn(t))
+	_, err := tradingService.DecideTradeProposal(ctx, proposal.ID, false)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = tradingService.DecideTradeProposal(ctx, proposal.ID, true)
+
+	// Assert
+	assert.Equal(t, errors.ErrTradeProposalDecided, err)
+}
+
+func TestExpireTradeProposalsClosesOverdueProposals(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	overdue := newTestTradeProposal(t, ctx, userID, -time.Minute)
+	pending := newTestTradeProposal(t, ctx, userID, time.Hour)
+	tradingService := newApprovalTradingService(t, map[string]float64{}, newTelegramStandIn(t))
+
+	// Act
+	expired, err := tradingService.ExpireTradeProposals(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *expired, 1)
+	assert.Equal(t, overdue.ID, (*expired)[0].ID)
+	stored, err := tradeProposalService.GetByID(ctx, pending.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusPending, stored.Status)
+}
+
+func TestCreateTradingPreferenceReturnsErrorIfInvalidApprovalTimeout(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	ctx.Set("user", &entities.User{ID: userID})
+	tpFactory := &entities.TradingPreferenceFactory{}
+	tp := tpFactory.NewTradingPreference(
+		userID,
+		constants.TradingAlgorithmSwingTrading,
+		[]string{"BTCUSDT"},
+		true,
+		true,
+		false,
+		constants.TradingPreferenceRiskLevelLow,
+	)
+	tp.RequireApproval = true
+	tp.ApprovalTimeoutMinutes = 0
+
+	// Act
+	_, err := tradingPreferenceService.Create(ctx, tp)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidApprovalTimeout, err)
+}
+
+// --- Digest Tests ---
+
+// newDigestTradingService quotes the given prices, ranks the watchlist from
+// the stored market data and sends digests to the telegram stand-in.
+func newDigestTradingService(
+	t *testing.T,
+	prices map[string]float64,
+	standIn *telegramStandIn,
+) *DefaultTradingService {
+	tradingService := newApprovalTradingService(t, prices, standIn)
+	regimeService := marketRegimeService.(*markets.DefaultMarketRegimeService)
+	tradingService.MarketRegimeService = regimeService
+	tradingService.MarketDataService = markets.NewDefaultMarketDataService(
+		regimeService.MarketDataRepository,
+		uacService,
+	)
+	return tradingService
+}
+
+func TestBuildDigestSumsUpThePeriod(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	now := time.Now().UTC()
+	newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		triggeredAt := now.Add(-time.Hour)
+		tp.Watchlist = []string{"DIGESTAUSDT"}
+		tp.Operate = false
+		tp.KillSwitchReason = constants.RiskLimitDailyLoss
+		tp.KillSwitchTriggeredAt = &triggeredAt
+	})
+	_, err := holdingService.Create(ctx, newLedgerTestHolding(userID, "DIGESTAUSDT", 2, 100))
+	assert.NoError(t, err)
+	orderFactory := &entities.OrderFactory{}
+	for _, createdAt := range []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Hour), now.AddDate(0, 0, -3)} {
+		order := orderFactory.NewOrder(userID, "DIGESTAUSDT", 2, 100, constants.OrderTypeEntry)
+		order.CreatedAt = createdAt
+		_, err = orderService.Create(ctx, order)
+		assert.NoError(t, err)
+	}
+	for _, createdAt := range []time.Time{now.Add(-time.Hour), now.AddDate(0, 0, -3)} {
+		_, err = ledgerEntryRepository.Create(ctx, &entities.LedgerEntry{
+			ID:          uuid.New(),
+			UserID:      userID,
+			EntryType:   constants.LedgerEntryTypeConversion,
+			FromAsset:   "DIGESTB",
+			FromDelta:   -1,
+			ToAsset:     "USDT",
+			ToDelta:     100,
+			FeeValue:    1.5,
+			RealisedPnL: 20,
+			CreatedAt:   createdAt,
+		})
+		assert.NoError(t, err)
+	}
+	newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeBinance)
+	// The process account holds more cash than the user's own
+	tradingService := newDigestTradingService(
+		t,
+		map[string]float64{"DIGESTAUSDT": 150, constants.LedgerQuoteAsset: 1000},
+		newTelegramStandIn(t),
+	)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: &stubExchangeAdapter{
+		balances: map[string]float64{constants.LedgerQuoteAsset: 400},
+	}}
+	tradingService.CredentialResolver = credentialResolver
+
+	// Act
+	digest, err := tradingService.BuildDigest(ctx, userID, now.AddDate(0, 0, -1), now)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 700.0, digest.PortfolioValue)
+	assert.Equal(t, 2, digest.Trades)
+	assert.Equal(t, 1.5, digest.Fees)
+	assert.Equal(t, 20.0, digest.RealisedPnL)
+	assert.Len(t, digest.Positions, 1)
+	assert.Equal(t, "DIGESTAUSDT", digest.Positions[0].Symbol)
+	assert.Equal(t, 100.0, digest.UnrealisedPnL)
+	// Without market data the watchlist isn't ranked
+	assert.Empty(t, digest.Scores)
+	assert.Contains(t, digest.RiskEvents, valueobjects.DigestRiskEvent{
+		Kind:       constants.DigestRiskEventKillSwitch,
+		Limit:      constants.RiskLimitDailyLoss,
+		OccurredAt: now.Add(-time.Hour),
+	})
+}
+
+func TestBuildDigestCountsNoCashWithoutExchangeKey(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	ctx := newApprovalTestContext(userID)
+	now := time.Now().UTC()
+	newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {
+		tp.Watchlist = []string{"DIGESTCUSDT"}
+	})
+	_, err := holdingService.Create(ctx, newLedgerTestHolding(userID, "DIGESTCUSDT", 2, 100))
+	assert.NoError(t, err)
+	tradingService := newDigestTradingService(
+		t,
+		map[string]float64{"DIGESTCUSDT": 150, constants.LedgerQuoteAsset: 1000},
+		newTelegramStandIn(t),
+	)
+	tradingService.CredentialResolver = credentialResolver
+
+	// Act
+	digest, err := tradingService.BuildDigest(ctx, userID, now.AddDate(0, 0, -1), now)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 300.0, digest.PortfolioValue)
+}
+
+func TestSendDueDigestsOnlySendsDueSchedules(t *testing.T) {
+	// Arrange
+	now := time.Now().UTC().Add(25 * time.Hour)
+	scheduleFactory := &entities.DigestScheduleFactory{}
+	standIn := newTelegramStandIn(t)
+	tradingService := newDigestTradingService(t, map[string]float64{}, standIn)
+	tradingService.ExchangeAdapterFactory = &stubExchangeAdapterFactory{adapter: &stubExchangeAdapter{
+		balances: map[string]float64{constants.LedgerQuoteAsset: 500},
+	}}
+	tradingService.CredentialResolver = credentialResolver
+	schedules := map[uuid.UUID]*entities.DigestSchedule{}
+	for _, frequency := range []string{constants.DigestFrequencyDaily, constants.DigestFrequencyWeekly} {
+		userID := uuid.New()
+		ctx := newApprovalTestContext(userID)
+		newRiskTestPreference(t, ctx, userID, func(tp *entities.TradingPreference) {})
+		newExchangeKey(t, ctx, userID, constants.ApiKeyServiceTypeBinance)
+		// The weekly digest is next due in a couple of days
+		schedule, err := tradingService.NotificationService.UpdateDigestSchedule(
+			ctx,
+			scheduleFactory.NewDigestSchedule(userID, frequency, "00:00", now.AddDate(0, 0, 2).Weekday()),
+		)
+		assert.NoError(t, err)
+		schedules[userID] = schedule
+	}
+	ctx, err := newBotContext()
+	assert.NoError(t, err)
+
+	// Act
+	sent, err := tradingService.SendDueDigests(ctx, now)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	messages := standIn.Requests("sendMessage")
+	assert.Len(t, messages, 1)
+	assert.Contains(t, fmt.Sprint(messages[0].Params["text"]), "Daily digest")
+	for userID, schedule := range schedules {
+		stored, err := tradingService.NotificationService.GetDigestSchedule(newApprovalTestContext(userID), userID)
+		assert.NoError(t, err)
+		if schedule.Frequency == constants.DigestFrequencyDaily {
+			assert.True(t, stored.LastSentAt.Equal(now))
+			assert.Equal(t, 500.0, stored.LastValue)
+		} else {
+			assert.Nil(t, stored.LastSentAt)
+		}
+	}
+
+	// Act
+	sent, err = tradingService.SendDueDigests(ctx, now)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Len(t, standIn.Requests("sendMessage"), 1)
+}
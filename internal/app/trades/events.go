@@ -0,0 +1,39 @@
+package trades
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
+	"gopkg.in/telebot.v3"
+)
+
+type TradingEventHandler interface {
+	HandleMarketDataPushed(ctx echo.Context, event events.MarketDataEvent) error
+}
+
+type TradingEventRegistry interface {
+	HandleEvent(ctx echo.Context, msg []byte) error
+}
+
+type TradeApprovalHandler interface {
+	Register()
+	HandleApprove(c telebot.Context) error
+	HandleReject(c telebot.Context) error
+}
+
+type DigestHandler interface {
+	Loop()
+	HandleTick(ctx echo.Context, now time.Time) error
+}
+
+type TelegramCommandHandler interface {
+	Register()
+	HandlePair(c telebot.Context) error
+	HandleStatus(c telebot.Context) error
+	HandleScores(c telebot.Context) error
+	HandlePause(c telebot.Context) error
+	HandleResume(c telebot.Context) error
+	HandleHistory(c telebot.Context) error
+	HandleStopLoss(c telebot.Context) error
+}
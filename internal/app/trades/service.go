@@ -1,21 +1,45 @@
 package trades
 
 import (
+	"io"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/aggregate"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 )
 
 type TradingService interface {
 	ExecuteTrade(ctx echo.Context, holding *entities.Holding, toAsset string, toMarketData *entities.MarketData, walletType string) error
 	ExecuteStopLoss(ctx echo.Context, holding *entities.Holding, walletType string) error
+	ExecuteTakeProfit(ctx echo.Context, holding *entities.Holding, signal valueobjects.ProtectiveExitSignal, walletType string) error
+	ProtectiveExitSignal(ctx echo.Context, tradingPosition *aggregate.TradingPositionAggregate, price float64, atr *float64) (valueobjects.ProtectiveExitSignal, error)
+	EvaluateProtectiveExits(ctx echo.Context, tradingPosition *aggregate.TradingPositionAggregate, price float64, atr *float64) (valueobjects.ProtectiveExitSignal, error)
+	GetPortfolio(ctx echo.Context, userID uuid.UUID) (*aggregate.PortfolioAggregate, error)
+	EnforceRiskLimits(ctx echo.Context, userID uuid.UUID) (*valueobjects.RiskBreach, error)
+	BuildDigest(ctx echo.Context, userID uuid.UUID, from time.Time, to time.Time) (*valueobjects.DigestNotification, error)
+	SendDueDigests(ctx echo.Context, now time.Time) (int, error)
+	EntriesFrozen(ctx echo.Context, tradingPreference *entities.TradingPreference) (bool, error)
+	ApplyMarketRegime(ctx echo.Context, userID uuid.UUID, walletType string) (*entities.Holdings, error)
+	PlanAllocations(ctx echo.Context, portfolio *aggregate.PortfolioAggregate, cash float64, prices map[string]float64, candidates entities.MarketDatas) valueobjects.PositionAllocations
+	AllocateCapital(ctx echo.Context, userID uuid.UUID, walletType string) (*entities.Holdings, error)
+	OpenPosition(ctx echo.Context, tradingPreference *entities.TradingPreference, allocation valueobjects.PositionAllocation, walletType string) (*entities.Holding, error)
+	GetHoldingPnL(ctx echo.Context, holding *entities.Holding) (*valueobjects.HoldingPnL, error)
+	GetUserPnL(ctx echo.Context, userID uuid.UUID) (*valueobjects.UserPnL, error)
+	GetBookSummaries(ctx echo.Context, userID uuid.UUID, since time.Time) (valueobjects.BookSummaries, error)
+	ProposeTrade(ctx echo.Context, holding *entities.Holding, toSymbol string, walletType string) (*entities.TradeProposal, error)
+	DecideTradeProposal(ctx echo.Context, proposalID uuid.UUID, approve bool) (*entities.TradeProposal, error)
+	ExpireTradeProposals(ctx echo.Context, userID uuid.UUID) (*entities.TradeProposals, error)
 }
 
 type TradingPreferenceService interface {
 	GetByUserID(ctx echo.Context, id uuid.UUID) (*entities.TradingPreference, error)
 	GetByID(ctx echo.Context, id uuid.UUID) (*entities.TradingPreference, error)
 	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.TradingPreferences, error)
+	GetOperating(ctx echo.Context) (*entities.TradingPreferences, error)
 	Create(ctx echo.Context, tp *entities.TradingPreference) (*entities.TradingPreference, error)
 	Update(ctx echo.Context, entity *entities.TradingPreference) (*entities.TradingPreference, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
@@ -24,6 +48,7 @@ type TradingPreferenceService interface {
 type HoldingService interface {
 	GetByID(ctx echo.Context, id uuid.UUID) (*entities.Holding, error)
 	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.Holdings, error)
+	GetOpenBySymbol(ctx echo.Context, symbol string, userIDs []uuid.UUID) (*entities.Holdings, error)
 	Create(ctx echo.Context, holding *entities.Holding) (*entities.Holding, error)
 	Update(ctx echo.Context, entity *entities.Holding) (*entities.Holding, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
@@ -36,3 +61,36 @@ type OrderService interface {
 	Update(ctx echo.Context, entity *entities.Order) (*entities.Order, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
 }
+
+type LedgerService interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.LedgerEntry, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.LedgerEntries, error)
+	GetByHoldingID(ctx echo.Context, holding *entities.Holding) (*entities.LedgerEntries, error)
+	Record(ctx echo.Context, entry *entities.LedgerEntry, holding *entities.Holding) (*entities.LedgerEntry, error)
+	GetUnitCost(ctx echo.Context, holding *entities.Holding) (float64, error)
+	GetHoldingPnL(ctx echo.Context, holding *entities.Holding, price float64) (*valueobjects.HoldingPnL, error)
+	GetUserPnL(ctx echo.Context, userID uuid.UUID, holdings *entities.Holdings, prices map[string]float64) (*valueobjects.UserPnL, error)
+}
+
+type TaxLotService interface {
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.TaxLots, error)
+	RecordEntry(ctx echo.Context, entry *entities.LedgerEntry, method string) (*entities.TaxLotDisposals, error)
+	GetDisposals(ctx echo.Context, userID uuid.UUID, year int) (*entities.TaxLotDisposals, error)
+	ExportYearlyReport(ctx echo.Context, userID uuid.UUID, year int, w io.Writer) error
+}
+
+type TradeProposalService interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.TradeProposal, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.TradeProposals, error)
+	GetPending(ctx echo.Context, userID uuid.UUID) (*entities.TradeProposals, error)
+	Create(ctx echo.Context, proposal *entities.TradeProposal) (*entities.TradeProposal, error)
+	Update(ctx echo.Context, proposal *entities.TradeProposal) (*entities.TradeProposal, error)
+	Claim(ctx echo.Context, proposal *entities.TradeProposal) error
+}
+
+type RiskService interface {
+	GetSnapshot(ctx echo.Context, portfolio *aggregate.PortfolioAggregate, cash float64, prices map[string]float64) (*valueobjects.RiskSnapshot, error)
+	Evaluate(ctx echo.Context, tradingPreference *entities.TradingPreference, snapshot *valueobjects.RiskSnapshot) (*valueobjects.RiskBreach, error)
+	TripKillSwitch(ctx echo.Context, tradingPreference *entities.TradingPreference, breach valueobjects.RiskBreach) error
+	ResumeTrading(ctx echo.Context, userID uuid.UUID) (*entities.TradingPreference, error)
+}
@@ -1,26 +1,68 @@
 package trades
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
+	binance "github.com/adshao/go-binance/v2"
+	binanceSapiConnector "github.com/binance/binance-connector-go"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	exchanges "github.com/sergiovirahonda/endurance-api/internal/app/exchange"
+
+	keys "github.com/sergiovirahonda/endurance-api/internal/app/key"
+	"github.com/sergiovirahonda/endurance-api/internal/app/markets"
+	"github.com/sergiovirahonda/endurance-api/internal/app/notifications"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/key"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/market"
+	notificationRepository "github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/notification"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/trade"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
 
 var (
-	database                  *gorm.DB
-	tradePreferenceRepository trade.TradingPreferenceRepository
-	holdingRepository         trade.HoldingRepository
-	orderRepository           trade.OrderRepository
-	tradingPreferenceService  TradingPreferenceService
-	holdingService            HoldingService
-	orderService              OrderService
-	uacService                uacs.UacService
+	database                         *gorm.DB
+	tradePreferenceRepository        trade.TradingPreferenceRepository
+	holdingRepository                trade.HoldingRepository
+	orderRepository                  trade.OrderRepository
+	ledgerEntryRepository            trade.LedgerEntryRepository
+	taxLotRepository                 trade.TaxLotRepository
+	taxLotDisposalRepository         trade.TaxLotDisposalRepository
+	tradingPreferenceService         TradingPreferenceService
+	holdingService                   HoldingService
+	orderService                     OrderService
+	ledgerService                    LedgerService
+	taxLotService                    TaxLotService
+	riskService                      RiskService
+	tradeProposalService             TradeProposalService
+	telegramChatRepository           notificationRepository.TelegramChatRepository
+	pairingCodeRepository            notificationRepository.PairingCodeRepository
+	channelRepository                notificationRepository.NotificationChannelRepository
+	deliveryRepository               notificationRepository.NotificationDeliveryRepository
+	notificationPreferenceRepository notificationRepository.NotificationPreferenceRepository
+	digestRepository                 notificationRepository.DigestScheduleRepository
+	marketRegimeService              markets.MarketRegimeService
+	uacService                       uacs.UacService
+	secondFactorGuard                *stubSecondFactorGuard
+	keyRepository                    key.KeyRepository
+	credentialResolver               keys.CredentialResolver
 )
 
 func TestMain(m *testing.M) {
@@ -33,6 +75,20 @@ func TestMain(m *testing.M) {
 		&dtos.TradingPreference{},
 		&dtos.Holding{},
 		&dtos.Order{},
+		&dtos.LedgerEntry{},
+		&dtos.TaxLot{},
+		&dtos.TaxLotDisposal{},
+		&dtos.ApiKey{},
+		&dtos.Market{},
+		&dtos.MarketData{},
+		&dtos.MarketRegime{},
+		&dtos.TradeProposal{},
+		&dtos.TelegramChat{},
+		&dtos.PairingCode{},
+		&dtos.NotificationChannel{},
+		&dtos.NotificationDelivery{},
+		&dtos.NotificationPreference{},
+		&dtos.DigestSchedule{},
 	}
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
@@ -40,10 +96,300 @@ func TestMain(m *testing.M) {
 	tradePreferenceRepository = trade.NewDefaultTradingPreferenceRepository(database)
 	holdingRepository = trade.NewDefaultHoldingRepository(database)
 	orderRepository = trade.NewDefaultOrderRepository(database)
+	ledgerEntryRepository = trade.NewDefaultLedgerEntryRepository(database)
+	taxLotRepository = trade.NewDefaultTaxLotRepository(database)
+	taxLotDisposalRepository = trade.NewDefaultTaxLotDisposalRepository(database)
 	uacService = uacs.NewDefaultUacService()
-	tradingPreferenceService = NewDefaultTradingPreferenceService(tradePreferenceRepository, uacService)
+	secondFactorGuard = &stubSecondFactorGuard{}
+	tradingPreferenceService = NewDefaultTradingPreferenceService(
+		tradePreferenceRepository,
+		secondFactorGuard,
+		uacService,
+	)
 	holdingService = NewDefaultHoldingService(holdingRepository, uacService)
 	orderService = NewDefaultOrderService(orderRepository, uacService)
+	ledgerService = NewDefaultLedgerService(ledgerEntryRepository, uacService)
+	taxLotService = NewDefaultTaxLotService(taxLotRepository, taxLotDisposalRepository, uacService)
+	tradeProposalService = NewDefaultTradeProposalService(trade.NewDefaultTradeProposalRepository(database), uacService)
+	// Without telegram keys notifications fail before reaching telegram
+	keyring, err := lib.NewKeyring("test", map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		logger.Fatalf("Error building test keyring: %s", err)
+	}
+	keyRepository = key.NewDefaultKeyRepository(database, keyring)
+	credentialResolver = keys.NewDefaultCredentialResolver(keyRepository)
+	telegramChatRepository = notificationRepository.NewDefaultTelegramChatRepository(database)
+	pairingCodeRepository = notificationRepository.NewDefaultPairingCodeRepository(database)
+	channelRepository = notificationRepository.NewDefaultNotificationChannelRepository(database)
+	deliveryRepository = notificationRepository.NewDefaultNotificationDeliveryRepository(database)
+	notificationPreferenceRepository = notificationRepository.NewDefaultNotificationPreferenceRepository(database)
+	digestRepository = notificationRepository.NewDefaultDigestScheduleRepository(database)
+	notificationService := notifications.NewDefaultNotificationService(
+		credentialResolver,
+		telegramChatRepository,
+		pairingCodeRepository,
+		channelRepository,
+		deliveryRepository,
+		notificationPreferenceRepository,
+		digestRepository,
+		uacService,
+		nil,
+		map[string]notifications.Notifier{},
+	)
+	riskService = NewDefaultRiskService(
+		tradingPreferenceService.(*DefaultTradingPreferenceService),
+		orderService.(*DefaultOrderService),
+		ledgerService.(*DefaultLedgerService),
+		notificationService,
+		nil,
+		uacService,
+	)
+	marketRegimeService = markets.NewDefaultMarketRegimeService(
+		market.NewDefaultMarketRepository(database),
+		market.NewDefaultMarketDataRepository(database),
+		market.NewDefaultMarketRegimeRepository(database),
+		config.GetConfig(),
+	)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
+
+// telegramStandIn answers the Telegram bot API methods the service calls and
+// keeps the requests it received.
+type telegramStandIn struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []telegramRequest
+}
+
+type telegramRequest struct {
+	Method string
+	Params map[string]interface{}
+}
+
+func newTelegramStandIn(t *testing.T) *telegramStandIn {
+	standIn := &telegramStandIn{}
+	standIn.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		params := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&params)
+		standIn.mutex.Lock()
+		standIn.requests = append(standIn.requests, telegramRequest{Method: method, Params: params})
+		standIn.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch method {
+		case "getMe":
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"endurance","username":"endurance_bot"}}`)
+		case "sendMessage", "editMessageText":
+			fmt.Fprintf(w, `{"ok":true,"result":{"message_id":10,"date":0,"chat":{"id":%v,"type":"private"},"text":"ok"}}`, params["chat_id"])
+		default:
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+		}
+	}))
+	t.Cleanup(standIn.Close)
+	return standIn
+}
+
+func (s *telegramStandIn) Requests(method string) []telegramRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := make([]telegramRequest, 0)
+	for _, request := range s.requests {
+		if request.Method == method {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func (s *telegramStandIn) Client() *notification.TelegramClient {
+	cfg := *config.GetConfig()
+	cfg.Telegram.APIURL = s.URL
+	return notification.NewTelegramClient(&cfg, "test-token", 0)
+}
+
+// telegramCredentialResolver hands out telegram credentials for any user, so
+// notifications reach the stand-in.
+type telegramCredentialResolver struct {
+	*keys.DefaultCredentialResolver
+	chatID int64
+}
+
+func (r *telegramCredentialResolver) ResolveTelegramCredentials(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*valueobjects.TelegramCredentials, error) {
+	return &valueobjects.TelegramCredentials{BotToken: "test-token", ChatID: r.chatID}, nil
+}
+
+// exchangeCredentialResolver hands out an exchange key for any user, so the
+// trading service resolves an adapter for them.
+type exchangeCredentialResolver struct {
+	*keys.DefaultCredentialResolver
+}
+
+func (r *exchangeCredentialResolver) ResolveExchangeKey(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.ApiKey, error) {
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	return apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeBinance, "test-api-key", "test-api-secret"), nil
+}
+
+// newTickerStandIn points an exchange service at a server quoting the given
+// prices, keyed by symbol. The price of the ledger quote asset, if any, is the
+// free balance of the account. Quoted symbols trade in steps of 0.0001.
+func newTickerStandIn(t *testing.T, prices map[string]float64) *exchanges.DefaultExchangeService {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cash, ok := prices[constants.LedgerQuoteAsset]
+		if r.URL.Path == "/api/v3/account" && ok {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"balances":[{"asset":"%s","free":"%f","locked":"0"}]}`, constants.LedgerQuoteAsset, cash)
+			return
+		}
+		symbol := r.URL.Query().Get("symbol")
+		price, ok := prices[symbol]
+		if r.URL.Path == "/api/v3/exchangeInfo" && ok {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"symbols":[{"symbol":"%s","baseAssetPrecision":8,"quotePrecision":8,"filters":[{"filterType":"LOT_SIZE","minQty":"0.0001","maxQty":"100000","stepSize":"0.0001"}]}]}`, symbol)
+			return
+		}
+		if r.URL.Path != "/api/v3/ticker/24hr" || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"symbol":"%s","lastPrice":"%f","volume":"1","priceChangePercent":"0"}`, symbol, price)
+	}))
+	t.Cleanup(server.Close)
+	sapiClient := binanceSapiConnector.NewClient("key", "secret", server.URL)
+	sapiClient.HTTPClient = server.Client()
+	generalClient := binance.NewClient("key", "secret")
+	generalClient.BaseURL = server.URL
+	generalClient.HTTPClient = server.Client()
+	return exchanges.NewDefaultExchangeService(sapiClient, generalClient)
+}
+
+// stubSecondFactorGuard lets every action through, unless told otherwise,
+// and keeps the last code it was given.
+type stubSecondFactorGuard struct {
+	err  error
+	code string
+}
+
+func (g *stubSecondFactorGuard) RequireSecondFactor(ctx echo.Context, userID uuid.UUID) error {
+	g.code, _ = ctx.Get("mfa_code").(string)
+	return g.err
+}
+
+// stubExchangeAdapter holds the free balances of an account, quotes
+// conversions at the given prices, keyed by symbol, and keeps the quotes it
+// accepted. Conversions stop at the ledger quote asset when partial is set.
+type stubExchangeAdapter struct {
+	exchanges.ExchangeAdapter
+	balances map[string]float64
+	prices   map[string]float64
+	partial  bool
+	mutex    sync.Mutex
+	accepted []entities.ExchangeConversionQuote
+}
+
+func (a *stubExchangeAdapter) GetBalance(
+	ctx echo.Context,
+	asset string,
+) (*valueobjects.ExchangeBalance, error) {
+	free, ok := a.balances[asset]
+	if !ok {
+		return nil, errors.ErrBalanceNotFound
+	}
+	return &valueobjects.ExchangeBalance{Asset: asset, Free: free}, nil
+}
+
+func (a *stubExchangeAdapter) GetTicker(
+	ctx echo.Context,
+	symbol string,
+) (*valueobjects.ExchangeTicker, error) {
+	price, ok := a.prices[symbol]
+	if !ok {
+		return nil, errors.ErrTickerNotAvailable
+	}
+	return &valueobjects.ExchangeTicker{Symbol: symbol, Price: price}, nil
+}
+
+func (a *stubExchangeAdapter) PrepareOrderQuantity(
+	ctx echo.Context,
+	symbol string,
+	quantity float64,
+	price float64,
+) (float64, error) {
+	return quantity, nil
+}
+
+func (a *stubExchangeAdapter) GetConversionQuote(
+	ctx echo.Context,
+	fromAsset string,
+	toAsset string,
+	fromAmount float64,
+	walletType string,
+) (*entities.ExchangeConversionQuote, error) {
+	factory := entities.ExchangeConversionQuoteFactory{}
+	quote := factory.NewSimulatedConversionQuote(fromAsset, toAsset, fromAmount, a.price(fromAsset), a.price(toAsset))
+	quote.ID = "quote-" + uuid.NewString()
+	return quote, nil
+}
+
+func (a *stubExchangeAdapter) AcceptConversionQuote(
+	ctx echo.Context,
+	quote *entities.ExchangeConversionQuote,
+) (*entities.ExchangeConversionOrder, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.accepted = append(a.accepted, *quote)
+	if a.partial {
+		return &entities.ExchangeConversionOrder{
+			ID:         quote.ID,
+			Status:     constants.ExchangeConversionStatusPartial,
+			FromAmount: quote.FromAmount,
+			ToAsset:    constants.LedgerQuoteAsset,
+			ToAmount:   quote.FromAmount * a.price(quote.FromAsset),
+			FeeAsset:   constants.LedgerQuoteAsset,
+		}, nil
+	}
+	return &entities.ExchangeConversionOrder{ID: quote.ID, Status: "SUCCESS"}, nil
+}
+
+func (a *stubExchangeAdapter) Accepted() []entities.ExchangeConversionQuote {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]entities.ExchangeConversionQuote{}, a.accepted...)
+}
+
+func (a *stubExchangeAdapter) price(asset string) float64 {
+	if asset == constants.LedgerQuoteAsset {
+		return 1
+	}
+	return a.prices[asset+constants.LedgerQuoteAsset]
+}
+
+// stubExchangeAdapterFactory hands out the same adapter for every key and
+// keeps the keys it was given.
+type stubExchangeAdapterFactory struct {
+	adapter *stubExchangeAdapter
+	keys    []entities.ApiKey
+}
+
+func (f *stubExchangeAdapterFactory) NewAdapter(
+	apiKey *entities.ApiKey,
+) (exchanges.ExchangeAdapter, error) {
+	f.keys = append(f.keys, *apiKey)
+	return f.adapter, nil
+}
+
+// newExchangeKey stores an exchange key of the user, so the trading service
+// resolves an adapter for it.
+func newExchangeKey(t *testing.T, ctx echo.Context, userID uuid.UUID, service string) *entities.ApiKey {
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey, err := keyRepository.Create(ctx, apiKeyFactory.NewApiKey(userID, service, "test-api-key", "test-api-secret"))
+	assert.NoError(t, err)
+	return apiKey
+}
@@ -14,6 +14,15 @@ func NewDefaultUacService() *DefaultUacService {
 	return &DefaultUacService{}
 }
 
+// NewUserContext returns a context acting on behalf of the user, for jobs
+// that run on behalf of the system through the resources of every user.
+func NewUserContext(ctx echo.Context, userID uuid.UUID) echo.Context {
+	userCtx := echo.New().NewContext(ctx.Request(), nil)
+	userCtx.Set("logger", ctx.Get("logger"))
+	userCtx.Set("user", &entities.User{ID: userID})
+	return userCtx
+}
+
 func (s DefaultUacService) GetUser(ctx echo.Context) *entities.User {
 	return ctx.Get("user").(*entities.User)
 }
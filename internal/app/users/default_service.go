@@ -1,6 +1,12 @@
 package users
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -8,26 +14,56 @@ import (
 	"github.com/labstack/echo/v4"
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/captcha"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/user"
 	"github.com/sergiovirahonda/endurance-api/internal/lib"
+	"gorm.io/gorm"
+)
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
 )
 
 type DefaultUserService struct {
-	UserRepository user.UserRepository
-	UacService     uacs.UacService
+	UserRepository          user.UserRepository
+	SessionRepository       user.SessionRepository
+	SigningKeyRepository    user.SigningKeyRepository
+	MFAFactorRepository     user.MFAFactorRepository
+	UserTokenRepository     user.UserTokenRepository
+	LoginThrottleRepository user.LoginThrottleRepository
+	Mailer                  notification.Mailer
+	CaptchaVerifier         captcha.Verifier
+	UacService              uacs.UacService
 }
 
 func NewDefaultUserService(
 	userRepository user.UserRepository,
+	sessionRepository user.SessionRepository,
+	signingKeyRepository user.SigningKeyRepository,
+	mfaFactorRepository user.MFAFactorRepository,
+	userTokenRepository user.UserTokenRepository,
+	loginThrottleRepository user.LoginThrottleRepository,
+	mailer notification.Mailer,
+	captchaVerifier captcha.Verifier,
 	uacService uacs.UacService,
 ) *DefaultUserService {
 	return &DefaultUserService{
-		UserRepository: userRepository,
-		UacService:     uacService,
+		UserRepository:          userRepository,
+		SessionRepository:       sessionRepository,
+		SigningKeyRepository:    signingKeyRepository,
+		MFAFactorRepository:     mfaFactorRepository,
+		UserTokenRepository:     userTokenRepository,
+		LoginThrottleRepository: loginThrottleRepository,
+		Mailer:                  mailer,
+		CaptchaVerifier:         captchaVerifier,
+		UacService:              uacService,
 	}
 }
 
@@ -118,6 +154,20 @@ func (s *DefaultUserService) UpdateUser(
 		}
 		user.Password = hash
 	}
+	user.DisabledAt = u.DisabledAt
+	if user.Enabled {
+		user.DisabledAt = nil
+	} else if u.Enabled {
+		// Disabling an enabled account is on purpose, and its pending
+		// verification links must not enable it again. Accounts still
+		// pending verification keep theirs.
+		now := time.Now().UTC()
+		user.Disable(now)
+		err = s.UserTokenRepository.RedeemByUserID(ctx, user.ID, constants.UserTokenPurposeEmailVerification, now)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return s.UserRepository.Update(ctx, user)
 }
 
@@ -137,6 +187,9 @@ func (s *DefaultUserService) DeleteUser(
 	return s.UserRepository.Delete(ctx, u.ID)
 }
 
+// RegisterUser creates a disabled account and emails the link to verify its
+// address, which enables it. Failing to send the email doesn't fail the
+// registration, the user can ask for it again.
 func (s *DefaultUserService) RegisterUser(
 	ctx echo.Context,
 	email string,
@@ -154,9 +207,164 @@ func (s *DefaultUserService) RegisterUser(
 		role,
 		time.Time{},
 		false,
-		true,
+		false,
+	)
+	user, err := s.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	err = s.sendEmailVerification(ctx, user)
+	if err != nil {
+		config.GetLogger().Errorf("Error sending email verification to user %s: %s", user.ID, err)
+	}
+	return user, nil
+}
+
+// RequestEmailVerification emails a new verification link to an account
+// still pending it, invalidating the previous ones. It says nothing of
+// whether the address is registered.
+func (s *DefaultUserService) RequestEmailVerification(
+	ctx echo.Context,
+	email string,
+) error {
+	user, err := s.UserRepository.GetByEmail(ctx, email)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Enabled || user.IsEmailVerified() || user.IsDisabledOnPurpose() {
+		return nil
+	}
+	// Only accounts that registered on their own are pending verification,
+	// verifying an address must not enable an account an admin disabled
+	tokens, err := s.UserTokenRepository.GetByUserID(
+		ctx,
+		user.ID,
+		constants.UserTokenPurposeEmailVerification,
+	)
+	if err != nil {
+		return err
+	}
+	if len(*tokens) == 0 {
+		return nil
+	}
+	return s.sendEmailVerification(ctx, user)
+}
+
+// VerifyEmail redeems a verification token, enabling the account it was
+// issued for unless it was disabled on purpose.
+func (s *DefaultUserService) VerifyEmail(
+	ctx echo.Context,
+	token string,
+) error {
+	userToken, err := s.redeemableUserToken(ctx, token, constants.UserTokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	user, err := s.UserRepository.GetByID(ctx, userToken.UserID)
+	if err != nil {
+		return errors.ErrInvalidUserToken
+	}
+	if user.IsDisabledOnPurpose() {
+		return errors.ErrUserNotEnabled
+	}
+	err = s.redeemUserToken(ctx, userToken)
+	if err != nil {
+		return err
+	}
+	user.VerifyEmail(time.Now().UTC())
+	_, err = s.UserRepository.Update(ctx, user)
+	return err
+}
+
+// RequestPasswordReset emails a password reset link to the user, if the
+// address belongs to an enabled account. It says nothing of whether it does.
+func (s *DefaultUserService) RequestPasswordReset(
+	ctx echo.Context,
+	email string,
+) error {
+	user, err := s.UserRepository.GetByEmail(ctx, email)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.Enabled {
+		return nil
+	}
+	conf := config.GetConfig().Accounts
+	token, err := s.issueUserToken(
+		ctx,
+		user,
+		constants.UserTokenPurposePasswordReset,
+		conf.PasswordResetTTL,
 	)
-	return s.CreateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	return s.Mailer.Send(requestContext(ctx), &valueobjects.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. "+
+				"If it was you, choose a new one here:\n%s\n\n"+
+				"The link expires in %s. If it wasn't you, ignore this email.",
+			user.FirstName,
+			userTokenLink(conf.BaseURL, "reset-password", token),
+			conf.PasswordResetTTL,
+		),
+	})
+}
+
+// ResetPasswordWithToken sets the password of the user the reset token was
+// issued for and signs them out everywhere.
+func (s *DefaultUserService) ResetPasswordWithToken(
+	ctx echo.Context,
+	token string,
+	password string,
+) error {
+	userToken, err := s.redeemableUserToken(ctx, token, constants.UserTokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.UserRepository.GetByID(ctx, userToken.UserID)
+	if err != nil {
+		return errors.ErrInvalidUserToken
+	}
+	if !user.Enabled {
+		return errors.ErrUserNotEnabled
+	}
+	err = s.redeemUserToken(ctx, userToken)
+	if err != nil {
+		return err
+	}
+	hasher := lib.NewHasher()
+	hash, err := hasher.HashString(password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	user.LoggedIn = false
+	_, err = s.UserRepository.Update(ctx, user)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.SessionRepository.GetActiveByUserID(ctx, user.ID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for i := range *sessions {
+		session := &(*sessions)[i]
+		session.Revoke(constants.SessionRevokedPasswordReset)
+		_, err = s.SessionRepository.Update(ctx, session)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *DefaultUserService) PasswordReset(
@@ -194,39 +402,70 @@ func (s DefaultUserService) CheckUserPassword(
 	return nil
 }
 
+// UnlockAccount lifts the lockout of an account and forgets its failed
+// logins. Only admins can unlock accounts.
+func (s DefaultUserService) UnlockAccount(ctx echo.Context, userID uuid.UUID) error {
+	err := s.UacService.IsAdminUser(ctx)
+	if err != nil {
+		return err
+	}
+	user, err := s.UserRepository.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.clearAccountThrottle(ctx, user.Email)
+}
+
 // User authentication services
 
+// Authenticate checks the password of the user and marks them logged in. It
+// knows nothing of second factors, Login is the way in for users.
 func (s DefaultUserService) Authenticate(
 	ctx echo.Context,
 	credentials valueobjects.LoginCredentials,
 ) (*entities.User, error) {
-	hasher := lib.NewHasher()
-	user, err := s.UserRepository.GetByEmail(ctx, credentials.Email)
+	user, err := s.checkCredentials(ctx, credentials)
 	if err != nil {
-		return nil, errors.ErrInvalidCredentials
-	}
-	valid := hasher.CheckStringHash(credentials.Password, user.Password)
-	if !valid {
-		return nil, errors.ErrInvalidCredentials
+		return nil, err
 	}
-	if !user.Enabled {
-		return nil, errors.ErrUserNotEnabled
+	user, err = s.logIn(ctx, user)
+	if err != nil {
+		return nil, err
 	}
-	user.LastLogin = time.Now().UTC()
-	user.LoggedIn = true
-	_, err = s.UserRepository.Update(ctx, user)
+	err = s.clearAccountThrottle(ctx, user.Email)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+// Logout revokes the session of the access token in context. The user is
+// only logged out once no other session is left.
 func (s DefaultUserService) Logout(ctx echo.Context) error {
 	user := s.UacService.GetUser(ctx)
 	user, err := s.UserRepository.GetByID(ctx, user.ID)
 	if err != nil {
 		return err
 	}
+	sessionID, ok := ctx.Get("session_id").(uuid.UUID)
+	if ok {
+		session, err := s.SessionRepository.GetByID(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		session.Revoke(constants.SessionRevokedLogout)
+		_, err = s.SessionRepository.Update(ctx, session)
+		if err != nil {
+			return err
+		}
+	}
+	sessions, err := s.SessionRepository.GetActiveByUserID(ctx, user.ID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if len(*sessions) > 0 {
+		return nil
+	}
 	user.LoggedIn = false
 	_, err = s.UserRepository.Update(ctx, user)
 	if err != nil {
@@ -235,18 +474,28 @@ func (s DefaultUserService) Logout(ctx echo.Context) error {
 	return nil
 }
 
+// GetClaimsFromToken verifies the token with the signing key named by its
+// kid header, so tokens signed before a rotation keep working until they
+// expire.
 func (s DefaultUserService) GetClaimsFromToken(
 	ctx echo.Context,
 	tokenString string,
 ) (*entities.JWTClaim, error) {
 	logger := config.GetLogger()
-	signingKey := []byte(config.GetConfig().JWT.SigningKey)
 	token, err := jwt.ParseWithClaims(tokenString, &entities.JWTClaim{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
 			logger.Error("unexpected signing method", "method", token.Header["alg"])
 			return nil, errors.ErrUnexpectedSigningMethod
 		}
-		return signingKey, nil
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.ErrUnknownSigningKey
+		}
+		signingKey, err := s.SigningKeyRepository.GetByID(ctx, kid)
+		if err != nil {
+			return nil, errors.ErrUnknownSigningKey
+		}
+		return signingKey.PublicKey, nil
 	})
 	if err != nil {
 		return nil, err
@@ -271,7 +520,9 @@ func (s DefaultUserService) GetClaimsFromToken(
 		FirstName: claims.FirstName,
 		LastName:  claims.LastName,
 		Role:      claims.Role,
+		SessionID: claims.SessionID,
 		StandardClaims: jwt.StandardClaims{
+			Id:        claims.Id,
 			ExpiresAt: claims.ExpiresAt,
 			Audience:  claims.Audience,
 		},
@@ -282,6 +533,7 @@ func (s DefaultUserService) GetClaimsFromToken(
 func (s DefaultUserService) GenerateAccessToken(
 	ctx echo.Context,
 	user *entities.User,
+	session *entities.Session,
 ) (string, error) {
 	accessTokenClaim := &entities.JWTClaim{
 		ID:        user.ID.String(),
@@ -289,22 +541,21 @@ func (s DefaultUserService) GenerateAccessToken(
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Role:      user.Role,
+		SessionID: session.ID.String(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Minute * 5).Unix(),
-			Audience:  "access",
+			ExpiresAt: time.Now().Add(config.GetConfig().JWT.AccessTokenTTL).Unix(),
+			Audience:  constants.TokenAudienceAccess,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaim)
-	jwtToken, err := token.SignedString([]byte(config.GetConfig().JWT.SigningKey))
-	if err != nil {
-		return "", err
-	}
-	return jwtToken, nil
+	return s.signToken(ctx, accessTokenClaim)
 }
 
+// GenerateRefreshToken issues the current refresh token of the session, the
+// only one of its family that can be exchanged.
 func (s DefaultUserService) GenerateRefreshToken(
 	ctx echo.Context,
 	user *entities.User,
+	session *entities.Session,
 ) (string, error) {
 
 	accessTokenClaim := &entities.JWTClaim{
@@ -313,43 +564,65 @@ func (s DefaultUserService) GenerateRefreshToken(
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Role:      user.Role,
+		SessionID: session.ID.String(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
-			Audience:  "refresh",
+			Id:        session.RefreshTokenID.String(),
+			ExpiresAt: session.ExpiresAt.Unix(),
+			Audience:  constants.TokenAudienceRefresh,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaim)
-	jwtToken, err := token.SignedString([]byte(config.GetConfig().JWT.SigningKey))
-	if err != nil {
-		return "", err
-	}
-	return jwtToken, nil
+	return s.signToken(ctx, accessTokenClaim)
 }
 
+// GetTokens opens a new session for the user and issues its tokens.
 func (s DefaultUserService) GetTokens(
 	ctx echo.Context,
 	user *entities.User,
 ) (*valueobjects.Tokens, error) {
-	tokens := valueobjects.Tokens{}
-	accessToken, err := s.GenerateAccessToken(ctx, user)
-	if err != nil {
-		return &valueobjects.Tokens{}, err
+	userAgent, ipAddress := "", ""
+	if ctx.Request() != nil {
+		userAgent = ctx.Request().UserAgent()
+		ipAddress = ctx.RealIP()
 	}
-	refreshToken, err := s.GenerateRefreshToken(ctx, user)
+	factory := entities.SessionFactory{}
+	session := factory.NewSession(
+		user.ID,
+		userAgent,
+		ipAddress,
+		config.GetConfig().JWT.RefreshTokenTTL,
+	)
+	session, err := s.SessionRepository.Create(ctx, session)
 	if err != nil {
 		return &valueobjects.Tokens{}, err
 	}
-	tokens.AccessToken = accessToken
-	tokens.RefreshToken = refreshToken
-
-	return &tokens, nil
+	return s.issueTokens(ctx, user, session)
 }
 
+// Login opens a session for the user. Users with two-factor authentication
+// get an MFA token instead, to complete the login with through LoginWithMFA.
 func (s DefaultUserService) Login(
 	ctx echo.Context,
 	credentials valueobjects.LoginCredentials,
 ) (*valueobjects.Tokens, error) {
-	user, err := s.Authenticate(ctx, credentials)
+	user, err := s.checkCredentials(ctx, credentials)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	factor, err := s.mfaFactor(ctx, user.ID)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	if factor != nil && factor.IsEnabled() {
+		mfaToken, err := s.GenerateMFAToken(ctx, user)
+		if err != nil {
+			return &valueobjects.Tokens{}, err
+		}
+		return &valueobjects.Tokens{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+	user, err = s.logIn(ctx, user)
 	if err != nil {
 		return &valueobjects.Tokens{}, err
 	}
@@ -357,6 +630,10 @@ func (s DefaultUserService) Login(
 	if err != nil {
 		return tokens, err
 	}
+	err = s.clearAccountThrottle(ctx, user.Email)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
 	return tokens, nil
 }
 
@@ -368,7 +645,7 @@ func (s DefaultUserService) RefreshToken(
 	if err != nil {
 		return &valueobjects.Tokens{}, err
 	}
-	if claims.StandardClaims.Audience != "refresh" {
+	if claims.StandardClaims.Audience != constants.TokenAudienceRefresh {
 		return &valueobjects.Tokens{}, errors.ErrInvalidToken
 	}
 	if claims.ExpiresAt < time.Now().Unix() {
@@ -379,6 +656,15 @@ func (s DefaultUserService) RefreshToken(
 	if err != nil {
 		return &valueobjects.Tokens{}, errors.ErrInvalidToken
 	}
+	session, err := s.activeSession(ctx, claims)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	if session.RefreshTokenID.String() != claims.Id {
+		// An already rotated token: whoever holds the family can't be told
+		// apart from the user, so nobody keeps it
+		return &valueobjects.Tokens{}, s.revokeReusedSession(ctx, session.ID)
+	}
 	user := &entities.User{
 		ID:        id,
 		Email:     claims.Email,
@@ -397,15 +683,18 @@ func (s DefaultUserService) RefreshToken(
 	if !instance.LoggedIn {
 		return &valueobjects.Tokens{}, errors.ErrUserLoggedOut
 	}
-	accessToken, err := s.GenerateAccessToken(ctx, instance)
+	previousID := session.RefreshTokenID
+	session.Rotate(config.GetConfig().JWT.RefreshTokenTTL)
+	swapped, err := s.SessionRepository.Rotate(ctx, session, previousID)
 	if err != nil {
 		return &valueobjects.Tokens{}, err
 	}
-	tokens := valueobjects.Tokens{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+	if !swapped {
+		// The same token was presented twice at once, and the other refresh
+		// rotated it first
+		return &valueobjects.Tokens{}, s.revokeReusedSession(ctx, session.ID)
 	}
-	return &tokens, nil
+	return s.issueTokens(ctx, instance, session)
 }
 
 func (s DefaultUserService) ValidateAccessToken(
@@ -416,7 +705,7 @@ func (s DefaultUserService) ValidateAccessToken(
 	if err != nil {
 		return &entities.JWTClaim{}, err
 	}
-	if claims.Audience != "access" {
+	if claims.Audience != constants.TokenAudienceAccess {
 		return &entities.JWTClaim{}, errors.ErrInvalidToken
 	}
 	if claims.ExpiresAt < time.Now().Unix() {
@@ -434,10 +723,834 @@ func (s DefaultUserService) ValidateAccessToken(
 		LastName:  claims.LastName,
 		Role:      claims.Role,
 	}
+	session, err := s.activeSession(ctx, claims)
+	if err != nil {
+		return &entities.JWTClaim{}, err
+	}
 	ctx.Set("user", user)
+	ctx.Set("session_id", session.ID)
 	_, err = s.GetUserByID(ctx, id)
 	if err != nil {
 		return &entities.JWTClaim{}, err
 	}
 	return claims, nil
 }
+
+// Session services
+
+// ListSessions returns the active sessions of the user in context.
+func (s DefaultUserService) ListSessions(ctx echo.Context) (*entities.Sessions, error) {
+	user := s.UacService.GetUser(ctx)
+	return s.SessionRepository.GetActiveByUserID(ctx, user.ID, time.Now().UTC())
+}
+
+// RevokeSession ends a session of the user in context, signing out whoever
+// holds its tokens.
+func (s DefaultUserService) RevokeSession(ctx echo.Context, id uuid.UUID) error {
+	session, err := s.SessionRepository.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = s.UacService.IsResourceOwner(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	session.Revoke(constants.SessionRevokedByUser)
+	_, err = s.SessionRepository.Update(ctx, session)
+	return err
+}
+
+// Two-factor authentication services
+
+// LoginWithMFA completes the login the MFA token was issued for, with either
+// a TOTP code or an unused recovery code.
+func (s DefaultUserService) LoginWithMFA(
+	ctx echo.Context,
+	credentials valueobjects.MFACredentials,
+) (*valueobjects.Tokens, error) {
+	claims, err := s.GetClaimsFromToken(ctx, credentials.MFAToken)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	if claims.Audience != constants.TokenAudienceMFA {
+		return &valueobjects.Tokens{}, errors.ErrInvalidToken
+	}
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return &valueobjects.Tokens{}, errors.ErrInvalidToken
+	}
+	user, err := s.UserRepository.GetByID(ctx, id)
+	if err != nil {
+		return &valueobjects.Tokens{}, errors.ErrInvalidToken
+	}
+	if !user.Enabled {
+		return &valueobjects.Tokens{}, errors.ErrUserNotEnabled
+	}
+	now := time.Now().UTC()
+	throttles, err := s.loginThrottles(ctx, user.Email)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	err = checkLoginLockout(throttles, now)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	factor, err := s.mfaFactor(ctx, user.ID)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	if factor == nil || !factor.IsEnabled() {
+		return &valueobjects.Tokens{}, errors.ErrMFANotEnrolled
+	}
+	conf := config.GetConfig().MFA
+	if !factor.VerifyCode(credentials.Code, now, conf.Skew) &&
+		!factor.UseRecoveryCode(credentials.Code) {
+		err = s.failLogin(ctx, user, throttles, now)
+		if err != nil {
+			return &valueobjects.Tokens{}, err
+		}
+		return &valueobjects.Tokens{}, errors.ErrInvalidMFACode
+	}
+	_, err = s.MFAFactorRepository.Update(ctx, factor)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	user, err = s.logIn(ctx, user)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	tokens, err := s.GetTokens(ctx, user)
+	if err != nil {
+		return tokens, err
+	}
+	err = s.clearAccountThrottle(ctx, user.Email)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	return tokens, nil
+}
+
+// GenerateMFAToken issues the token standing for a login whose password was
+// checked but whose second factor is still pending. It opens no session.
+func (s DefaultUserService) GenerateMFAToken(
+	ctx echo.Context,
+	user *entities.User,
+) (string, error) {
+	mfaTokenClaim := &entities.JWTClaim{
+		ID:    user.ID.String(),
+		Email: user.Email,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(config.GetConfig().MFA.TokenTTL).Unix(),
+			Audience:  constants.TokenAudienceMFA,
+		},
+	}
+	return s.signToken(ctx, mfaTokenClaim)
+}
+
+// EnrollMFA starts the enrolment of a new TOTP secret for the user in
+// context, replacing any pending one. It only takes effect once confirmed.
+func (s DefaultUserService) EnrollMFA(ctx echo.Context) (*valueobjects.MFAEnrollment, error) {
+	contextUser := s.UacService.GetUser(ctx)
+	user, err := s.UserRepository.GetByID(ctx, contextUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := s.mfaFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if factor != nil && factor.IsEnabled() {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+	if factor != nil {
+		err = s.MFAFactorRepository.Delete(ctx, factor.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	factory := entities.MFAFactorFactory{}
+	factor, err = factory.NewMFAFactor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	factor, err = s.MFAFactorRepository.Create(ctx, factor)
+	if err != nil {
+		return nil, err
+	}
+	return &valueobjects.MFAEnrollment{
+		Secret: factor.Secret,
+		ProvisioningURI: lib.TOTPProvisioningURI(
+			config.GetConfig().MFA.Issuer,
+			user.Email,
+			factor.Secret,
+		),
+	}, nil
+}
+
+// ConfirmMFA enables the pending factor of the user in context once the code
+// proves their app holds the secret, and issues the recovery codes.
+func (s DefaultUserService) ConfirmMFA(
+	ctx echo.Context,
+	code string,
+) (*valueobjects.MFARecoveryCodes, error) {
+	user := s.UacService.GetUser(ctx)
+	factor, err := s.mfaFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil {
+		return nil, errors.ErrMFANotEnrolled
+	}
+	if factor.IsEnabled() {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+	now := time.Now().UTC()
+	if !factor.VerifyCode(code, now, config.GetConfig().MFA.Skew) {
+		return nil, errors.ErrInvalidMFACode
+	}
+	factor.Enable(now)
+	return s.issueRecoveryCodes(ctx, factor)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user in
+// context, invalidating the previous ones.
+func (s DefaultUserService) RegenerateRecoveryCodes(
+	ctx echo.Context,
+	code string,
+) (*valueobjects.MFARecoveryCodes, error) {
+	user := s.UacService.GetUser(ctx)
+	factor, err := s.enabledMFAFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !factor.VerifyCode(code, time.Now().UTC(), config.GetConfig().MFA.Skew) {
+		return nil, errors.ErrInvalidMFACode
+	}
+	return s.issueRecoveryCodes(ctx, factor)
+}
+
+// SetMFARequiredForSensitiveActions sets whether the user in context has to
+// confirm sensitive actions with a code. Either way, the change itself takes
+// one.
+func (s DefaultUserService) SetMFARequiredForSensitiveActions(
+	ctx echo.Context,
+	required bool,
+) error {
+	user := s.UacService.GetUser(ctx)
+	factor, err := s.enabledMFAFactor(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = s.verifyContextCode(ctx, factor)
+	if err != nil {
+		return err
+	}
+	factor.RequiredForSensitiveActions = required
+	_, err = s.MFAFactorRepository.Update(ctx, factor)
+	return err
+}
+
+// ResetMFA removes the second factor of a user who lost it, along with their
+// sessions. Only admins can reset it.
+func (s DefaultUserService) ResetMFA(ctx echo.Context, userID uuid.UUID) error {
+	err := s.UacService.IsAdminUser(ctx)
+	if err != nil {
+		return err
+	}
+	factor, err := s.mfaFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil {
+		return errors.ErrMFANotEnrolled
+	}
+	err = s.MFAFactorRepository.Delete(ctx, factor.ID)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.SessionRepository.GetActiveByUserID(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for i := range *sessions {
+		session := &(*sessions)[i]
+		session.Revoke(constants.SessionRevokedMFAReset)
+		_, err = s.SessionRepository.Update(ctx, session)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequireSecondFactor passes when the user doesn't guard sensitive actions
+// with their second factor, or the context carries a valid code. System jobs
+// act on behalf of users and are never asked for one.
+func (s DefaultUserService) RequireSecondFactor(ctx echo.Context, userID uuid.UUID) error {
+	if s.UacService.IsFunctionalUser(ctx) == nil {
+		return nil
+	}
+	factor, err := s.mfaFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil || !factor.IsEnabled() || !factor.RequiredForSensitiveActions {
+		return nil
+	}
+	return s.verifyContextCode(ctx, factor)
+}
+
+// Signing key services
+
+// RotateSigningKeys starts signing with a new key once the current one is
+// due, retiring the rest, and drops retired keys no live token was signed
+// with. Keys still sealed with a previous master key are resealed with the
+// current one. It reports whether a new key was created.
+func (s DefaultUserService) RotateSigningKeys(ctx echo.Context, now time.Time) (bool, error) {
+	conf := config.GetConfig().JWT
+	signingKeys, err := s.SigningKeyRepository.GetAll(ctx)
+	if err != nil {
+		return false, err
+	}
+	rotated := false
+	current := currentSigningKey(signingKeys)
+	if current == nil || current.IsDue(conf.KeyRotationPeriod, now) {
+		current, err = s.createSigningKey(ctx)
+		if err != nil {
+			return false, err
+		}
+		rotated = true
+	}
+	tokenTTL := conf.RefreshTokenTTL
+	if conf.AccessTokenTTL > tokenTTL {
+		tokenTTL = conf.AccessTokenTTL
+	}
+	for i := range *signingKeys {
+		signingKey := &(*signingKeys)[i]
+		if signingKey.ID == current.ID {
+			continue
+		}
+		if signingKey.IsExpired(tokenTTL, now) {
+			err = s.SigningKeyRepository.Delete(ctx, signingKey.ID)
+			if err != nil {
+				return rotated, err
+			}
+			continue
+		}
+		if !signingKey.IsRetired() {
+			signingKey.Retire(now)
+			_, err = s.SigningKeyRepository.Update(ctx, signingKey)
+			if err != nil {
+				return rotated, err
+			}
+		}
+	}
+	stale, err := s.SigningKeyRepository.GetStale(ctx)
+	if err != nil {
+		return rotated, err
+	}
+	for i := range *stale {
+		_, err = s.SigningKeyRepository.Update(ctx, &(*stale)[i])
+		if err != nil {
+			return rotated, err
+		}
+	}
+	return rotated, nil
+}
+
+// GetJWKS returns the public halves of every key tokens may still be signed
+// with, for third parties to verify them.
+func (s DefaultUserService) GetJWKS(ctx echo.Context) (*valueobjects.JWKS, error) {
+	signingKeys, err := s.SigningKeyRepository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jwks := &valueobjects.JWKS{Keys: []valueobjects.JWK{}}
+	for _, signingKey := range *signingKeys {
+		jwks.Keys = append(jwks.Keys, valueobjects.JWK{
+			KeyType:   constants.JWKKeyTypeOKP,
+			Curve:     constants.JWKCurveEd25519,
+			KeyID:     signingKey.ID,
+			Algorithm: signingKey.Algorithm,
+			Use:       constants.JWKUseSignature,
+			X:         base64.RawURLEncoding.EncodeToString(signingKey.PublicKey),
+		})
+	}
+	return jwks, nil
+}
+
+// Helpers
+
+// checkCredentials checks the password of the user. Locked accounts and IPs
+// are refused before the password is hashed, so hammering them costs
+// nothing, and every failure counts against both.
+func (s DefaultUserService) checkCredentials(
+	ctx echo.Context,
+	credentials valueobjects.LoginCredentials,
+) (*entities.User, error) {
+	now := time.Now().UTC()
+	throttles, err := s.loginThrottles(ctx, credentials.Email)
+	if err != nil {
+		return nil, err
+	}
+	err = checkLoginLockout(throttles, now)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkCaptcha(ctx, throttles, credentials.CaptchaToken, now)
+	if err != nil {
+		return nil, err
+	}
+	hasher := lib.NewHasher()
+	valid := false
+	user, err := s.UserRepository.GetByEmail(ctx, credentials.Email)
+	if err != nil {
+		// Unknown emails still cost a hash comparison, so response times
+		// don't tell which accounts exist
+		user = nil
+		hasher.CheckStringHash(credentials.Password, getDummyPasswordHash())
+	} else {
+		valid = hasher.CheckStringHash(credentials.Password, user.Password)
+	}
+	if !valid {
+		err = s.failLogin(ctx, user, throttles, now)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrInvalidCredentials
+	}
+	if !user.Enabled && !user.IsEmailVerified() && !user.IsDisabledOnPurpose() {
+		return nil, errors.ErrEmailNotVerified
+	}
+	if !user.Enabled {
+		return nil, errors.ErrUserNotEnabled
+	}
+	return user, nil
+}
+
+// loginThrottles returns the failed login counts of the account and, for
+// requests, of the client IP. Counts not stored yet start at zero.
+func (s DefaultUserService) loginThrottles(
+	ctx echo.Context,
+	email string,
+) ([]*entities.LoginThrottle, error) {
+	account, err := s.loginThrottle(ctx, constants.LoginThrottleScopeAccount, loginThrottleKey(email))
+	if err != nil {
+		return nil, err
+	}
+	throttles := []*entities.LoginThrottle{account}
+	if ctx.Request() == nil || ctx.RealIP() == "" {
+		return throttles, nil
+	}
+	ip, err := s.loginThrottle(ctx, constants.LoginThrottleScopeIP, ctx.RealIP())
+	if err != nil {
+		return nil, err
+	}
+	return append(throttles, ip), nil
+}
+
+func (s DefaultUserService) loginThrottle(
+	ctx echo.Context,
+	scope string,
+	key string,
+) (*entities.LoginThrottle, error) {
+	throttle, err := s.LoginThrottleRepository.GetByScopeAndKey(ctx, scope, key)
+	if err == gorm.ErrRecordNotFound {
+		factory := entities.LoginThrottleFactory{}
+		return factory.NewLoginThrottle(scope, key), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return throttle, nil
+}
+
+func checkLoginLockout(throttles []*entities.LoginThrottle, now time.Time) error {
+	for _, throttle := range throttles {
+		if !throttle.IsLocked(now) {
+			continue
+		}
+		if throttle.Scope == constants.LoginThrottleScopeAccount {
+			return errors.ErrAccountLocked
+		}
+		return errors.ErrTooManyLoginAttempts
+	}
+	return nil
+}
+
+// checkCaptcha asks for a solved CAPTCHA once the account or IP failed
+// enough logins, if the lockout is configured to.
+func (s DefaultUserService) checkCaptcha(
+	ctx echo.Context,
+	throttles []*entities.LoginThrottle,
+	token string,
+	now time.Time,
+) error {
+	conf := config.GetConfig().Lockout
+	required := false
+	for _, throttle := range throttles {
+		if throttle.RequiresCaptcha(conf.CaptchaThreshold, now, conf.Window) {
+			required = true
+		}
+	}
+	if !required {
+		return nil
+	}
+	if token == "" {
+		return errors.ErrCaptchaRequired
+	}
+	remoteIP := ""
+	if ctx.Request() != nil {
+		remoteIP = ctx.RealIP()
+	}
+	return s.CaptchaVerifier.Verify(requestContext(ctx), token, remoteIP)
+}
+
+// getDummyPasswordHash returns a hash made like the stored ones, compared
+// against when the email matches no account.
+func getDummyPasswordHash() string {
+	dummyPasswordHashOnce.Do(func() {
+		hash, err := lib.NewHasher().HashString(uuid.NewString())
+		if err != nil {
+			panic(err)
+		}
+		dummyPasswordHash = hash
+	})
+	return dummyPasswordHash
+}
+
+// failLogin counts a failed login against every throttle and locks those
+// it took to the threshold, telling the user when it locked their account.
+// The lockout follows the count as stored, failures of other instances
+// included.
+func (s DefaultUserService) failLogin(
+	ctx echo.Context,
+	user *entities.User,
+	throttles []*entities.LoginThrottle,
+	now time.Time,
+) error {
+	conf := config.GetConfig().Lockout
+	for _, throttle := range throttles {
+		threshold := conf.IPThreshold
+		if throttle.Scope == constants.LoginThrottleScopeAccount {
+			threshold = conf.AccountThreshold
+		}
+		stored, err := s.LoginThrottleRepository.Fail(ctx, throttle, now, conf.Window)
+		if err != nil {
+			return err
+		}
+		if !stored.Lock(now, threshold, conf.LockoutBase, conf.LockoutMax) {
+			continue
+		}
+		err = s.LoginThrottleRepository.Lock(ctx, stored)
+		if err != nil {
+			return err
+		}
+		if user != nil && stored.Scope == constants.LoginThrottleScopeAccount {
+			s.sendLockoutNotice(ctx, user, stored)
+		}
+	}
+	return nil
+}
+
+// clearAccountThrottle forgets the failed logins of the account once a login
+// went all the way through, second factor included. Those of the IP are
+// kept, or logging into an account of their own would let a client reset
+// them.
+func (s DefaultUserService) clearAccountThrottle(ctx echo.Context, email string) error {
+	return s.LoginThrottleRepository.Delete(
+		ctx,
+		constants.LoginThrottleScopeAccount,
+		loginThrottleKey(email),
+	)
+}
+
+func (s DefaultUserService) sendLockoutNotice(
+	ctx echo.Context,
+	user *entities.User,
+	throttle *entities.LoginThrottle,
+) {
+	err := s.Mailer.Send(requestContext(ctx), &valueobjects.Mail{
+		To:      user.Email,
+		Subject: "Your account was locked",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nYour account was locked until %s after %d failed logins. "+
+				"If they weren't yours, reset your password once it is unlocked.",
+			user.FirstName,
+			throttle.LockedUntil.Format(time.RFC1123),
+			throttle.FailedAttempts,
+		),
+	})
+	if err != nil {
+		config.GetLogger().Errorf("Error sending lockout notice to user %s: %s", user.ID, err)
+	}
+}
+
+func loginThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (s DefaultUserService) logIn(
+	ctx echo.Context,
+	user *entities.User,
+) (*entities.User, error) {
+	user.LastLogin = time.Now().UTC()
+	user.LoggedIn = true
+	_, err := s.UserRepository.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// issueUserToken creates a token for the purpose, invalidating the ones
+// issued before, and returns it in clear for the email.
+func (s DefaultUserService) issueUserToken(
+	ctx echo.Context,
+	user *entities.User,
+	purpose string,
+	ttl time.Duration,
+) (string, error) {
+	token, err := lib.RandomCode(constants.UserTokenLength, lib.CodeAlphabet)
+	if err != nil {
+		return "", err
+	}
+	err = s.UserTokenRepository.RedeemByUserID(ctx, user.ID, purpose, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	factory := entities.UserTokenFactory{}
+	userToken := factory.NewUserToken(user.ID, purpose, lib.Hasher{}.DigestString(token), ttl)
+	_, err = s.UserTokenRepository.Create(ctx, userToken)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemableUserToken looks the token up by its digest. Unknown, used,
+// expired and other purpose tokens all fail the same way.
+func (s DefaultUserService) redeemableUserToken(
+	ctx echo.Context,
+	token string,
+	purpose string,
+) (*entities.UserToken, error) {
+	userToken, err := s.UserTokenRepository.GetByTokenDigest(ctx, lib.Hasher{}.DigestString(token))
+	if err != nil {
+		return nil, errors.ErrInvalidUserToken
+	}
+	if userToken.Purpose != purpose || !userToken.IsRedeemable(time.Now().UTC()) {
+		return nil, errors.ErrInvalidUserToken
+	}
+	return userToken, nil
+}
+
+// redeemUserToken spends the token before what it was issued for is done,
+// so of two requests racing with the same token only one goes on.
+func (s DefaultUserService) redeemUserToken(
+	ctx echo.Context,
+	userToken *entities.UserToken,
+) error {
+	redeemed, err := s.UserTokenRepository.Redeem(ctx, userToken.ID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return errors.ErrInvalidUserToken
+	}
+	return nil
+}
+
+func (s DefaultUserService) sendEmailVerification(
+	ctx echo.Context,
+	user *entities.User,
+) error {
+	conf := config.GetConfig().Accounts
+	token, err := s.issueUserToken(
+		ctx,
+		user,
+		constants.UserTokenPurposeEmailVerification,
+		conf.EmailVerificationTTL,
+	)
+	if err != nil {
+		return err
+	}
+	return s.Mailer.Send(requestContext(ctx), &valueobjects.Mail{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address to activate your account:\n%s\n\n"+
+				"The link expires in %s.",
+			user.FirstName,
+			userTokenLink(conf.BaseURL, "verify-email", token),
+			conf.EmailVerificationTTL,
+		),
+	})
+}
+
+func userTokenLink(baseURL string, path string, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", baseURL, path, url.QueryEscape(token))
+}
+
+func requestContext(ctx echo.Context) context.Context {
+	if ctx.Request() == nil {
+		return context.Background()
+	}
+	return ctx.Request().Context()
+}
+
+// mfaFactor returns the factor of the user, or nil when they have none.
+func (s DefaultUserService) mfaFactor(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.MFAFactor, error) {
+	factor, err := s.MFAFactorRepository.GetByUserID(ctx, userID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return factor, nil
+}
+
+func (s DefaultUserService) enabledMFAFactor(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.MFAFactor, error) {
+	factor, err := s.mfaFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil || !factor.IsEnabled() {
+		return nil, errors.ErrMFANotEnrolled
+	}
+	return factor, nil
+}
+
+// verifyContextCode checks the TOTP code in context against the factor,
+// saving the step it used so it can't be replayed.
+func (s DefaultUserService) verifyContextCode(
+	ctx echo.Context,
+	factor *entities.MFAFactor,
+) error {
+	code, ok := ctx.Get("mfa_code").(string)
+	if !ok || code == "" {
+		return errors.ErrMFARequired
+	}
+	if !factor.VerifyCode(code, time.Now().UTC(), config.GetConfig().MFA.Skew) {
+		return errors.ErrInvalidMFACode
+	}
+	_, err := s.MFAFactorRepository.Update(ctx, factor)
+	return err
+}
+
+func (s DefaultUserService) issueRecoveryCodes(
+	ctx echo.Context,
+	factor *entities.MFAFactor,
+) (*valueobjects.MFARecoveryCodes, error) {
+	codes, err := factor.IssueRecoveryCodes(config.GetConfig().MFA.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.MFAFactorRepository.Update(ctx, factor)
+	if err != nil {
+		return nil, err
+	}
+	return &valueobjects.MFARecoveryCodes{Codes: codes}, nil
+}
+
+// signToken signs the claims with the current signing key, creating the
+// first one when none exists yet.
+func (s DefaultUserService) signToken(
+	ctx echo.Context,
+	claims *entities.JWTClaim,
+) (string, error) {
+	signingKeys, err := s.SigningKeyRepository.GetAll(ctx)
+	if err != nil {
+		return "", err
+	}
+	signingKey := currentSigningKey(signingKeys)
+	if signingKey == nil {
+		signingKey, err = s.createSigningKey(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = signingKey.ID
+	return token.SignedString(signingKey.PrivateKey)
+}
+
+func (s DefaultUserService) createSigningKey(ctx echo.Context) (*entities.SigningKey, error) {
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	if err != nil {
+		return nil, err
+	}
+	return s.SigningKeyRepository.Create(ctx, signingKey)
+}
+
+// currentSigningKey returns the newest key not yet retired, if any.
+func currentSigningKey(signingKeys *entities.SigningKeys) *entities.SigningKey {
+	for i := range *signingKeys {
+		if !(*signingKeys)[i].IsRetired() {
+			return &(*signingKeys)[i]
+		}
+	}
+	return nil
+}
+
+func (s DefaultUserService) issueTokens(
+	ctx echo.Context,
+	user *entities.User,
+	session *entities.Session,
+) (*valueobjects.Tokens, error) {
+	accessToken, err := s.GenerateAccessToken(ctx, user, session)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	refreshToken, err := s.GenerateRefreshToken(ctx, user, session)
+	if err != nil {
+		return &valueobjects.Tokens{}, err
+	}
+	return &valueobjects.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// revokeReusedSession revokes the session a rotated refresh token came back
+// for, reading it again so the rotation that won isn't overwritten.
+func (s DefaultUserService) revokeReusedSession(ctx echo.Context, id uuid.UUID) error {
+	session, err := s.SessionRepository.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	session.Revoke(constants.SessionRevokedReuse)
+	_, err = s.SessionRepository.Update(ctx, session)
+	if err != nil {
+		return err
+	}
+	return errors.ErrRefreshTokenReused
+}
+
+// activeSession returns the session the token was issued for, failing when
+// it was revoked or has expired.
+func (s DefaultUserService) activeSession(
+	ctx echo.Context,
+	claims *entities.JWTClaim,
+) (*entities.Session, error) {
+	id, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, errors.ErrInvalidToken
+	}
+	session, err := s.SessionRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.ErrInvalidToken
+	}
+	if !session.IsActive(time.Now().UTC()) {
+		return nil, errors.ErrSessionRevoked
+	}
+	return session, nil
+}
@@ -1,18 +1,25 @@
 package users
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/user"
 	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
@@ -541,12 +548,59 @@ func TestDeleteUserDeletesUser(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// racingSessionRepository lets a concurrent refresh of the same token rotate
+// the session right before every rotation.
+type racingSessionRepository struct {
+	user.SessionRepository
+}
+
+func (r *racingSessionRepository) Rotate(
+	ctx echo.Context,
+	session *entities.Session,
+	previousID uuid.UUID,
+) (bool, error) {
+	concurrent, err := r.SessionRepository.GetByID(ctx, session.ID)
+	if err != nil {
+		return false, err
+	}
+	concurrent.Rotate(time.Hour)
+	_, err = r.SessionRepository.Rotate(ctx, concurrent, previousID)
+	if err != nil {
+		return false, err
+	}
+	return r.SessionRepository.Rotate(ctx, session, previousID)
+}
+
+func TestRefreshTokenLosingTheRotationRevokesSession(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("rotation-race@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	racingService := *userService.(*DefaultUserService)
+	racingService.SessionRepository = &racingSessionRepository{SessionRepository: sessionRepository}
+
+	// Act
+	_, err = racingService.RefreshToken(ctx, tokens.RefreshToken)
+
+	// Assert
+	assert.Equal(t, errors.ErrRefreshTokenReused, err)
+	claims, err := userService.GetClaimsFromToken(ctx, tokens.RefreshToken)
+	assert.NoError(t, err)
+	session, err := sessionRepository.GetByID(ctx, uuid.MustParse(claims.SessionID))
+	assert.NoError(t, err)
+	assert.Equal(t, constants.SessionRevokedReuse, session.RevokedReason)
+}
+
 // Authentication tests
 
 func TestGenerateAccessTokenGeneratesExpectedToken(t *testing.T) {
 	ctx := echo.New().NewContext(nil, nil)
 	ctx.Set("user", regularUser)
-	token, err := userService.GenerateAccessToken(ctx, regularUser)
+	sessionFactory := entities.SessionFactory{}
+	session := sessionFactory.NewSession(regularUser.ID, "", "", time.Hour)
+	token, err := userService.GenerateAccessToken(ctx, regularUser, session)
 	assert.Equal(t, err, nil)
 	assert.NotEqual(t, token, "")
 }
@@ -554,7 +608,9 @@ func TestGenerateAccessTokenGeneratesExpectedToken(t *testing.T) {
 func TestGenerateRefreshTokenGeneratesExpectedToken(t *testing.T) {
 	ctx := echo.New().NewContext(nil, nil)
 	ctx.Set("user", regularUser)
-	token, err := userService.GenerateRefreshToken(ctx, regularUser)
+	sessionFactory := entities.SessionFactory{}
+	session := sessionFactory.NewSession(regularUser.ID, "", "", time.Hour)
+	token, err := userService.GenerateRefreshToken(ctx, regularUser, session)
 	assert.Equal(t, err, nil)
 	assert.NotEqual(t, token, "")
 	splitted := strings.Split(token, ".")
@@ -890,3 +946,1076 @@ func TestRegistrationServiceSucceedsIfEmailDoesNotExist(t *testing.T) {
 	assert.Equal(t, err, nil)
 	assert.Equal(t, instance.Email, instance.Email)
 }
+
+// Session tests
+
+// newLoggedInUser stores an enabled, logged in user.
+func newLoggedInUser(email string) *entities.User {
+	factory := entities.UserFactory{}
+	user := factory.NewUser(
+		email,
+		"test",
+		"test",
+		"test",
+		constants.RoleUser,
+		time.Now().UTC(),
+		true,
+		true,
+	)
+	dto := dtos.User{}
+	dto.FromEntity(user)
+	database.Create(&dto)
+	return user
+}
+
+func TestRefreshTokenRotatesRefreshToken(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("rotation@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+
+	// Act
+	refreshedTokens, err := userService.RefreshToken(ctx, tokens.RefreshToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshedTokens.RefreshToken)
+	claims, err := userService.GetClaimsFromToken(ctx, tokens.RefreshToken)
+	assert.NoError(t, err)
+	refreshedClaims, err := userService.GetClaimsFromToken(ctx, refreshedTokens.RefreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.SessionID, refreshedClaims.SessionID)
+	assert.NotEqual(t, claims.Id, refreshedClaims.Id)
+	_, err = userService.ValidateAccessToken(ctx, refreshedTokens.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("reuse@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	refreshedTokens, err := userService.RefreshToken(ctx, tokens.RefreshToken)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.RefreshToken(ctx, tokens.RefreshToken)
+
+	// Assert
+	assert.Equal(t, errors.ErrRefreshTokenReused, err)
+	_, err = userService.RefreshToken(ctx, refreshedTokens.RefreshToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+	_, err = userService.ValidateAccessToken(ctx, refreshedTokens.AccessToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+	claims, err := userService.GetClaimsFromToken(ctx, tokens.RefreshToken)
+	assert.NoError(t, err)
+	session, err := sessionRepository.GetByID(ctx, uuid.MustParse(claims.SessionID))
+	assert.NoError(t, err)
+	assert.Equal(t, constants.SessionRevokedReuse, session.RevokedReason)
+}
+
+func TestListSessionsReturnsActiveSessions(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("sessions@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	_, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	claims, err := userService.GetClaimsFromToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+	err = userService.RevokeSession(ctx, uuid.MustParse(claims.SessionID))
+	assert.NoError(t, err)
+
+	// Act
+	sessions, err := userService.ListSessions(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *sessions, 1)
+	assert.NotEqual(t, claims.SessionID, (*sessions)[0].ID.String())
+}
+
+func TestRevokeSessionRevokesAccessTokens(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("revoke@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	claims, err := userService.ValidateAccessToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+
+	// Act
+	err = userService.RevokeSession(ctx, uuid.MustParse(claims.SessionID))
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = userService.ValidateAccessToken(ctx, tokens.AccessToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+	_, err = userService.RefreshToken(ctx, tokens.RefreshToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+}
+
+func TestRevokeSessionReturnsErrorIfNotOwner(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("revoke-owner@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	claims, err := userService.GetClaimsFromToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+	otherCtx := echo.New().NewContext(nil, nil)
+	otherCtx.Set("user", &entities.User{ID: uuid.New()})
+
+	// Act
+	err = userService.RevokeSession(otherCtx, uuid.MustParse(claims.SessionID))
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+func TestLogoutRevokesCurrentSessionOnly(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("logout-session@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	otherTokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	_, err = userService.ValidateAccessToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+
+	// Act
+	err = userService.Logout(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = userService.ValidateAccessToken(ctx, tokens.AccessToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+	_, err = userService.RefreshToken(ctx, otherTokens.RefreshToken)
+	assert.NoError(t, err)
+}
+
+// Signing key tests
+
+func TestGetClaimsFromTokenFailsIfSigningKeyUnknown(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &entities.JWTClaim{
+		ID: regularUser.ID.String(),
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Audience:  "access",
+		},
+	})
+	token.Header["kid"] = signingKey.ID
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.GetClaimsFromToken(ctx, tokenString)
+
+	// Assert
+	assert.Equal(t, errors.ErrUnknownSigningKey, validationErrorCause(t, err))
+}
+
+func TestGetClaimsFromTokenFailsIfSignedWithHMAC(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &entities.JWTClaim{
+		ID: regularUser.ID.String(),
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Audience:  "access",
+		},
+	})
+	tokenString, err := token.SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.GetClaimsFromToken(ctx, tokenString)
+
+	// Assert
+	assert.Equal(t, errors.ErrUnexpectedSigningMethod, validationErrorCause(t, err))
+}
+
+func TestRotateSigningKeysSkipsKeyNotDue(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", regularUser)
+	_, err := userService.GetTokens(ctx, regularUser)
+	assert.NoError(t, err)
+
+	// Act
+	rotated, err := userService.RotateSigningKeys(ctx, time.Now().UTC())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, rotated)
+}
+
+func TestRotateSigningKeysKeepsRetiredKeyVerifying(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", regularUser)
+	tokens, err := userService.GetTokens(ctx, regularUser)
+	assert.NoError(t, err)
+	now := time.Now().UTC().Add(config.GetConfig().JWT.KeyRotationPeriod)
+
+	// Act
+	rotated, err := userService.RotateSigningKeys(ctx, now)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+	_, err = userService.GetClaimsFromToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+	newTokens, err := userService.GetTokens(ctx, regularUser)
+	assert.NoError(t, err)
+	assert.NotEqual(t, tokenKeyID(t, tokens.AccessToken), tokenKeyID(t, newTokens.AccessToken))
+}
+
+func TestRotateSigningKeysDropsExpiredKeys(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", regularUser)
+	tokens, err := userService.GetTokens(ctx, regularUser)
+	assert.NoError(t, err)
+	conf := config.GetConfig().JWT
+	now := time.Now().UTC().Add(conf.KeyRotationPeriod)
+	_, err = userService.RotateSigningKeys(ctx, now)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.RotateSigningKeys(ctx, now.Add(conf.RefreshTokenTTL+time.Minute))
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = signingKeyRepository.GetByID(ctx, tokenKeyID(t, tokens.AccessToken))
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	_, err = userService.GetClaimsFromToken(ctx, tokens.AccessToken)
+	assert.Equal(t, errors.ErrUnknownSigningKey, validationErrorCause(t, err))
+}
+
+func TestGetJWKSReturnsSigningKeys(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", regularUser)
+	tokens, err := userService.GetTokens(ctx, regularUser)
+	assert.NoError(t, err)
+	kid := tokenKeyID(t, tokens.AccessToken)
+	signingKey, err := signingKeyRepository.GetByID(ctx, kid)
+	assert.NoError(t, err)
+
+	// Act
+	jwks, err := userService.GetJWKS(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	var found *valueobjects.JWK
+	for i := range jwks.Keys {
+		if jwks.Keys[i].KeyID == kid {
+			found = &jwks.Keys[i]
+		}
+	}
+	assert.NotNil(t, found)
+	assert.Equal(t, constants.JWKKeyTypeOKP, found.KeyType)
+	assert.Equal(t, constants.JWKCurveEd25519, found.Curve)
+	assert.Equal(t, constants.SigningAlgorithmEdDSA, found.Algorithm)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(signingKey.PublicKey), found.X)
+}
+
+// Helpers
+
+func tokenKeyID(t *testing.T, tokenString string) string {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &entities.JWTClaim{})
+	assert.NoError(t, err)
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func validationErrorCause(t *testing.T, err error) error {
+	validationErr, ok := err.(*jwt.ValidationError)
+	assert.True(t, ok)
+	if !ok {
+		return err
+	}
+	return validationErr.Inner
+}
+
+// Two-factor authentication tests
+
+// newMFAUser stores a user with password "test" and an enabled second factor,
+// required for sensitive actions when told so.
+func newMFAUser(t *testing.T, email string, requiredForSensitiveActions bool) (*entities.User, *entities.MFAFactor) {
+	user := newLoggedInUser(email)
+	hash, err := lib.NewHasher().HashString("test")
+	assert.NoError(t, err)
+	user.Password = hash
+	_, err = repository.Update(echo.New().NewContext(nil, nil), user)
+	assert.NoError(t, err)
+	factory := entities.MFAFactorFactory{}
+	factor, err := factory.NewMFAFactor(user.ID)
+	assert.NoError(t, err)
+	factor.Enable(time.Now().UTC())
+	factor.RequiredForSensitiveActions = requiredForSensitiveActions
+	_, err = mfaFactorRepository.Create(echo.New().NewContext(nil, nil), factor)
+	assert.NoError(t, err)
+	return user, factor
+}
+
+// totpCode returns the code of the secret at the given offset in steps.
+func totpCode(t *testing.T, secret string, offset int64) string {
+	code, err := lib.TOTPCode(secret, lib.TOTPStep(time.Now())+offset)
+	assert.NoError(t, err)
+	return code
+}
+
+func TestLoginReturnsMFATokenIfMFAEnabled(t *testing.T) {
+	// Arrange
+	user, _ := newMFAUser(t, "mfa-login@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	tokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, tokens.MFARequired)
+	assert.NotEmpty(t, tokens.MFAToken)
+	assert.Empty(t, tokens.AccessToken)
+	assert.Empty(t, tokens.RefreshToken)
+	_, err = userService.ValidateAccessToken(ctx, tokens.MFAToken)
+	assert.Equal(t, errors.ErrInvalidToken, err)
+}
+
+func TestLoginWithMFASucceedsWithCode(t *testing.T) {
+	// Arrange
+	user, factor := newMFAUser(t, "mfa-code@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	tokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+
+	// Act
+	sessionTokens, err := userService.LoginWithMFA(ctx, valueobjects.MFACredentials{
+		MFAToken: tokens.MFAToken,
+		Code:     totpCode(t, factor.Secret, 0),
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = userService.ValidateAccessToken(ctx, sessionTokens.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestLoginWithMFAFailsIfCodeReplayed(t *testing.T) {
+	// Arrange
+	user, factor := newMFAUser(t, "mfa-replay@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	tokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+	credentials := valueobjects.MFACredentials{
+		MFAToken: tokens.MFAToken,
+		Code:     totpCode(t, factor.Secret, 0),
+	}
+	_, err = userService.LoginWithMFA(ctx, credentials)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.LoginWithMFA(ctx, credentials)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidMFACode, err)
+}
+
+func TestLoginWithMFAFailsIfPasswordTokenPassed(t *testing.T) {
+	// Arrange
+	user, factor := newMFAUser(t, "mfa-audience@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.LoginWithMFA(ctx, valueobjects.MFACredentials{
+		MFAToken: tokens.AccessToken,
+		Code:     totpCode(t, factor.Secret, 0),
+	})
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidToken, err)
+}
+
+func TestLoginWithMFAAcceptsRecoveryCodeOnce(t *testing.T) {
+	// Arrange
+	user, factor := newMFAUser(t, "mfa-recovery@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	recoveryCodes, err := userService.RegenerateRecoveryCodes(ctx, totpCode(t, factor.Secret, 0))
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes.Codes, config.GetConfig().MFA.RecoveryCodeCount)
+	tokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+	credentials := valueobjects.MFACredentials{
+		MFAToken: tokens.MFAToken,
+		Code:     strings.ToLower(recoveryCodes.Codes[0]),
+	}
+
+	// Act
+	_, err = userService.LoginWithMFA(ctx, credentials)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = userService.LoginWithMFA(ctx, credentials)
+	assert.Equal(t, errors.ErrInvalidMFACode, err)
+}
+
+func TestEnrollAndConfirmMFA(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("mfa-enroll@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+
+	// Act
+	enrollment, err := userService.EnrollMFA(ctx)
+	assert.NoError(t, err)
+	recoveryCodes, err := userService.ConfirmMFA(ctx, totpCode(t, enrollment.Secret, 0))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/"))
+	assert.Contains(t, enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+	assert.Len(t, recoveryCodes.Codes, config.GetConfig().MFA.RecoveryCodeCount)
+	factor, err := mfaFactorRepository.GetByUserID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.True(t, factor.IsEnabled())
+	assert.Len(t, factor.RecoveryCodes, len(recoveryCodes.Codes))
+	assert.NotContains(t, factor.RecoveryCodes, recoveryCodes.Codes[0])
+	_, err = userService.EnrollMFA(ctx)
+	assert.Equal(t, errors.ErrMFAAlreadyEnabled, err)
+}
+
+func TestConfirmMFAFailsIfCodeInvalid(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("mfa-confirm@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	_, err := userService.EnrollMFA(ctx)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.ConfirmMFA(ctx, "000000x")
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidMFACode, err)
+	factor, err := mfaFactorRepository.GetByUserID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.False(t, factor.IsEnabled())
+}
+
+func TestRequireSecondFactorPassesIfNotRequired(t *testing.T) {
+	// Arrange
+	user, _ := newMFAUser(t, "mfa-not-required@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+
+	// Act
+	err := userService.RequireSecondFactor(ctx, user.ID)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestRequireSecondFactorFailsWithoutCode(t *testing.T) {
+	// Arrange
+	user, _ := newMFAUser(t, "mfa-required@test.com", true)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+
+	// Act
+	err := userService.RequireSecondFactor(ctx, user.ID)
+
+	// Assert
+	assert.Equal(t, errors.ErrMFARequired, err)
+}
+
+func TestRequireSecondFactorPassesWithCode(t *testing.T) {
+	// Arrange
+	user, factor := newMFAUser(t, "mfa-required-code@test.com", true)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	ctx.Set("mfa_code", totpCode(t, factor.Secret, 0))
+
+	// Act
+	err := userService.RequireSecondFactor(ctx, user.ID)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestRequireSecondFactorSkipsFunctionalUsers(t *testing.T) {
+	// Arrange
+	user, _ := newMFAUser(t, "mfa-required-functional@test.com", true)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New(), Role: constants.RoleFunctional})
+
+	// Act
+	err := userService.RequireSecondFactor(ctx, user.ID)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestResetMFAFailsIfNotAdmin(t *testing.T) {
+	// Arrange
+	user, _ := newMFAUser(t, "mfa-reset-forbidden@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+
+	// Act
+	err := userService.ResetMFA(ctx, user.ID)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+func TestResetMFARemovesFactorAndSessions(t *testing.T) {
+	// Arrange
+	user, _ := newMFAUser(t, "mfa-reset@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	ctx.Set("user", &entities.User{ID: uuid.New(), Role: constants.RoleAdmin})
+
+	// Act
+	err = userService.ResetMFA(ctx, user.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = mfaFactorRepository.GetByUserID(ctx, user.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	_, err = userService.ValidateAccessToken(ctx, tokens.AccessToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+	loginTokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+	assert.False(t, loginTokens.MFARequired)
+}
+
+// Account email tests
+
+// mailedToken returns the token of the latest link emailed to the address.
+func mailedToken(t *testing.T, to string) string {
+	mail, ok := mailer.Last(to)
+	assert.True(t, ok)
+	if !ok {
+		return ""
+	}
+	_, link, found := strings.Cut(mail.Text, "?token=")
+	assert.True(t, found)
+	token, _, _ := strings.Cut(link, "\n")
+	return token
+}
+
+// registerUser registers a user with password "test" through the service.
+func registerUser(t *testing.T, email string) *entities.User {
+	ctx := echo.New().NewContext(nil, nil)
+	user, err := userService.RegisterUser(ctx, email, "test", "test", "test", constants.RoleUser)
+	assert.NoError(t, err)
+	return user
+}
+
+func TestRegisterUserCreatesDisabledUserAndSendsVerification(t *testing.T) {
+	// Arrange
+	email := "verify-register@test.com"
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	registerUser(t, email)
+
+	// Assert
+	instance, err := repository.GetByEmail(ctx, email)
+	assert.NoError(t, err)
+	assert.False(t, instance.Enabled)
+	assert.False(t, instance.IsEmailVerified())
+	mail, ok := mailer.Last(email)
+	assert.True(t, ok)
+	assert.Contains(t, mail.Text, config.GetConfig().Accounts.BaseURL+"/verify-email?token=")
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: email, Password: "test"})
+	assert.Equal(t, errors.ErrEmailNotVerified, err)
+}
+
+func TestVerifyEmailEnablesUser(t *testing.T) {
+	// Arrange
+	email := "verify-enable@test.com"
+	registerUser(t, email)
+	token := mailedToken(t, email)
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	err := userService.VerifyEmail(ctx, token)
+
+	// Assert
+	assert.NoError(t, err)
+	instance, err := repository.GetByEmail(ctx, email)
+	assert.NoError(t, err)
+	assert.True(t, instance.Enabled)
+	assert.True(t, instance.IsEmailVerified())
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: email, Password: "test"})
+	assert.NoError(t, err)
+	err = userService.VerifyEmail(ctx, token)
+	assert.Equal(t, errors.ErrInvalidUserToken, err)
+}
+
+func TestVerifyEmailFailsIfTokenReplaced(t *testing.T) {
+	// Arrange
+	email := "verify-replaced@test.com"
+	registerUser(t, email)
+	token := mailedToken(t, email)
+	ctx := echo.New().NewContext(nil, nil)
+	err := userService.RequestEmailVerification(ctx, email)
+	assert.NoError(t, err)
+
+	// Act
+	err = userService.VerifyEmail(ctx, token)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidUserToken, err)
+	assert.NoError(t, userService.VerifyEmail(ctx, mailedToken(t, email)))
+}
+
+func TestVerifyEmailFailsIfTokenExpired(t *testing.T) {
+	// Arrange
+	user := registerUser(t, "verify-expired@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.UserTokenFactory{}
+	userToken := factory.NewUserToken(
+		user.ID,
+		constants.UserTokenPurposeEmailVerification,
+		lib.Hasher{}.DigestString("expired-token"),
+		-time.Minute,
+	)
+	_, err := userTokenRepository.Create(ctx, userToken)
+	assert.NoError(t, err)
+
+	// Act
+	err = userService.VerifyEmail(ctx, "expired-token")
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidUserToken, err)
+}
+
+func TestVerifyEmailDoesNotEnableAccountsDisabledByAdmin(t *testing.T) {
+	// Arrange
+	email := "verify-disabled@test.com"
+	user := registerUser(t, email)
+	token := mailedToken(t, email)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New(), Role: constants.RoleAdmin})
+	user.Enabled = true
+	user, err := userService.UpdateUser(ctx, user)
+	assert.NoError(t, err)
+	user.Enabled = false
+	_, err = userService.UpdateUser(ctx, user)
+	assert.NoError(t, err)
+	factory := entities.UserTokenFactory{}
+	_, err = userTokenRepository.Create(ctx, factory.NewUserToken(
+		user.ID,
+		constants.UserTokenPurposeEmailVerification,
+		lib.Hasher{}.DigestString("verify-disabled-token"),
+		time.Hour,
+	))
+	assert.NoError(t, err)
+
+	// Act
+	pendingErr := userService.VerifyEmail(ctx, token)
+	laterErr := userService.VerifyEmail(ctx, "verify-disabled-token")
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidUserToken, pendingErr)
+	assert.Equal(t, errors.ErrUserNotEnabled, laterErr)
+	instance, err := repository.GetByEmail(ctx, email)
+	assert.NoError(t, err)
+	assert.False(t, instance.Enabled)
+	assert.True(t, instance.IsDisabledOnPurpose())
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: email, Password: "test"})
+	assert.Equal(t, errors.ErrUserNotEnabled, err)
+	sent := len(mailer.Mails())
+	assert.NoError(t, userService.RequestEmailVerification(ctx, email))
+	assert.Len(t, mailer.Mails(), sent)
+}
+
+func TestUpdateUserEnablingClearsDisabledOnPurpose(t *testing.T) {
+	// Arrange
+	user := registerUser(t, "verify-reenabled@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New(), Role: constants.RoleAdmin})
+	user.Enabled = true
+	user, err := userService.UpdateUser(ctx, user)
+	assert.NoError(t, err)
+	user.Enabled = false
+	user, err = userService.UpdateUser(ctx, user)
+	assert.NoError(t, err)
+	assert.True(t, user.IsDisabledOnPurpose())
+	user.Enabled = true
+
+	// Act
+	user, err = userService.UpdateUser(ctx, user)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, user.Enabled)
+	assert.False(t, user.IsDisabledOnPurpose())
+}
+
+func TestUpdateUserKeepsVerificationOfPendingUsers(t *testing.T) {
+	// Arrange
+	email := "verify-pending-edit@test.com"
+	user := registerUser(t, email)
+	token := mailedToken(t, email)
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", &entities.User{ID: uuid.New(), Role: constants.RoleAdmin})
+	user.FirstName = "Edited"
+
+	// Act
+	user, err := userService.UpdateUser(ctx, user)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, user.IsDisabledOnPurpose())
+	assert.NoError(t, userService.VerifyEmail(ctx, token))
+	instance, err := repository.GetByEmail(ctx, email)
+	assert.NoError(t, err)
+	assert.True(t, instance.Enabled)
+	assert.Equal(t, "Edited", instance.FirstName)
+}
+
+func TestRequestEmailVerificationIgnoresUsersNotPending(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	user := newLoggedInUser("verify-not-pending@test.com")
+	user.Enabled = false
+	_, err := repository.Update(ctx, user)
+	assert.NoError(t, err)
+
+	// Act
+	err = userService.RequestEmailVerification(ctx, user.Email)
+	unknownErr := userService.RequestEmailVerification(ctx, "verify-unknown@test.com")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, unknownErr)
+	_, ok := mailer.Last(user.Email)
+	assert.False(t, ok)
+}
+
+func TestResetPasswordWithTokenSetsPasswordAndRevokesSessions(t *testing.T) {
+	// Arrange
+	user := newLoggedInUser("reset-password@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+	tokens, err := userService.GetTokens(ctx, user)
+	assert.NoError(t, err)
+	err = userService.RequestPasswordReset(ctx, user.Email)
+	assert.NoError(t, err)
+	token := mailedToken(t, user.Email)
+
+	// Act
+	err = userService.ResetPasswordWithToken(ctx, token, "new password")
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = userService.ValidateAccessToken(ctx, tokens.AccessToken)
+	assert.Equal(t, errors.ErrSessionRevoked, err)
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "new password"})
+	assert.NoError(t, err)
+	err = userService.ResetPasswordWithToken(ctx, token, "another password")
+	assert.Equal(t, errors.ErrInvalidUserToken, err)
+}
+
+func TestResetPasswordWithTokenFailsIfVerificationTokenPassed(t *testing.T) {
+	// Arrange
+	email := "reset-purpose@test.com"
+	registerUser(t, email)
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	err := userService.ResetPasswordWithToken(ctx, mailedToken(t, email), "new password")
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidUserToken, err)
+}
+
+func TestRequestPasswordResetIgnoresUnknownEmail(t *testing.T) {
+	// Arrange
+	email := "reset-unknown@test.com"
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	err := userService.RequestPasswordReset(ctx, email)
+
+	// Assert
+	assert.NoError(t, err)
+	_, ok := mailer.Last(email)
+	assert.False(t, ok)
+}
+
+// Login throttling tests
+
+// newPasswordUser stores an enabled user with password "test".
+func newPasswordUser(t *testing.T, email string) *entities.User {
+	user := newLoggedInUser(email)
+	hash, err := lib.NewHasher().HashString("test")
+	assert.NoError(t, err)
+	user.Password = hash
+	_, err = repository.Update(echo.New().NewContext(nil, nil), user)
+	assert.NoError(t, err)
+	return user
+}
+
+// setLockout overrides the lockout configuration for the test.
+func setLockout(t *testing.T, accountThreshold int, ipThreshold int, captchaThreshold int) {
+	conf := &config.GetConfig().Lockout
+	previous := *conf
+	conf.AccountThreshold = accountThreshold
+	conf.IPThreshold = ipThreshold
+	conf.CaptchaThreshold = captchaThreshold
+	t.Cleanup(func() { *conf = previous })
+}
+
+// ipContext returns the context of a request from the IP address.
+func ipContext(ipAddress string) echo.Context {
+	request := httptest.NewRequest(http.MethodPost, "/login", nil)
+	request.Header.Set(echo.HeaderXRealIP, ipAddress)
+	return echo.New().NewContext(request, httptest.NewRecorder())
+}
+
+func TestLoginLocksAccountAfterFailedAttempts(t *testing.T) {
+	// Arrange
+	setLockout(t, 2, 100, 0)
+	user := newPasswordUser(t, "lockout-account@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	wrong := valueobjects.LoginCredentials{Email: user.Email, Password: "wrong"}
+	_, err := userService.Login(ctx, wrong)
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+
+	// Act
+	_, err = userService.Login(ctx, wrong)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.Equal(t, errors.ErrAccountLocked, err)
+	throttle, err := loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeAccount, user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, throttle.FailedAttempts)
+	assert.True(t, throttle.IsLocked(time.Now().UTC()))
+	mail, ok := mailer.Last(user.Email)
+	assert.True(t, ok)
+	assert.Equal(t, "Your account was locked", mail.Subject)
+}
+
+func TestLoginDoublesLockoutAfterEachFailureOverThreshold(t *testing.T) {
+	// Arrange
+	setLockout(t, 2, 100, 0)
+	user := newPasswordUser(t, "lockout-doubling@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	now := time.Now().UTC()
+	lockedUntil := now.Add(-time.Second)
+	factory := entities.LoginThrottleFactory{}
+	throttle := factory.NewLoginThrottle(constants.LoginThrottleScopeAccount, user.Email)
+	for i := 0; i < 3; i++ {
+		_, err := loginThrottleRepository.Fail(ctx, throttle, now.Add(-time.Minute), time.Hour)
+		assert.NoError(t, err)
+	}
+	throttle.LockedUntil = &lockedUntil
+	err := loginThrottleRepository.Lock(ctx, throttle)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "wrong"})
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+	throttle, err = loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeAccount, user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, throttle.FailedAttempts)
+	base := config.GetConfig().Lockout.LockoutBase
+	assert.WithinDuration(t, time.Now().UTC().Add(4*base), *throttle.LockedUntil, 5*time.Second)
+}
+
+func TestLoginLocksIPAfterFailedAttempts(t *testing.T) {
+	// Arrange
+	setLockout(t, 100, 2, 0)
+	ctx := ipContext("203.0.113.10")
+	for _, email := range []string{"lockout-ip-1@test.com", "lockout-ip-2@test.com"} {
+		_, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: email, Password: "wrong"})
+		assert.Equal(t, errors.ErrInvalidCredentials, err)
+	}
+	user := newPasswordUser(t, "lockout-ip-3@test.com")
+
+	// Act
+	_, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+
+	// Assert
+	assert.Equal(t, errors.ErrTooManyLoginAttempts, err)
+	_, err = userService.Login(
+		ipContext("203.0.113.11"),
+		valueobjects.LoginCredentials{Email: user.Email, Password: "test"},
+	)
+	assert.NoError(t, err)
+}
+
+func TestLoginLocksIPIgnoringForwardedAddressesOfUntrustedClients(t *testing.T) {
+	// Arrange
+	setLockout(t, 100, 2, 0)
+	extractor, err := lib.NewIPExtractor()
+	assert.NoError(t, err)
+	server := echo.New()
+	server.IPExtractor = extractor
+	spoofedContext := func(forwardedFor string) echo.Context {
+		request := httptest.NewRequest(http.MethodPost, "/login", nil)
+		request.RemoteAddr = "203.0.113.20:4000"
+		request.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		request.Header.Set(echo.HeaderXRealIP, forwardedFor)
+		return server.NewContext(request, httptest.NewRecorder())
+	}
+	for i, email := range []string{"lockout-spoof-1@test.com", "lockout-spoof-2@test.com"} {
+		ctx := spoofedContext(fmt.Sprintf("198.51.100.%d", i+1))
+		_, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: email, Password: "wrong"})
+		assert.Equal(t, errors.ErrInvalidCredentials, err)
+	}
+	user := newPasswordUser(t, "lockout-spoof-3@test.com")
+
+	// Act
+	_, err = userService.Login(
+		spoofedContext("198.51.100.3"),
+		valueobjects.LoginCredentials{Email: user.Email, Password: "test"},
+	)
+
+	// Assert
+	assert.Equal(t, errors.ErrTooManyLoginAttempts, err)
+}
+
+func TestLoginComparesAPasswordHashForUnknownEmails(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	credentials := valueobjects.LoginCredentials{Email: "unknown-login@test.com", Password: "test"}
+
+	// Act
+	_, err := userService.Login(ctx, credentials)
+
+	// Assert
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+	assert.NotEmpty(t, dummyPasswordHash)
+	assert.False(t, lib.NewHasher().CheckStringHash(credentials.Password, dummyPasswordHash))
+}
+
+func TestLoginRequiresCaptchaAfterFailedAttempts(t *testing.T) {
+	// Arrange
+	setLockout(t, 100, 100, 1)
+	user := newPasswordUser(t, "lockout-captcha@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	_, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "wrong"})
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+	credentials := valueobjects.LoginCredentials{Email: user.Email, Password: "test"}
+
+	// Act
+	_, err = userService.Login(ctx, credentials)
+
+	// Assert
+	assert.Equal(t, errors.ErrCaptchaRequired, err)
+	credentials.CaptchaToken = "unsolved"
+	_, err = userService.Login(ctx, credentials)
+	assert.Equal(t, errors.ErrInvalidCaptcha, err)
+	credentials.CaptchaToken = "solved"
+	_, err = userService.Login(ctx, credentials)
+	assert.NoError(t, err)
+	_, err = loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeAccount, user.Email)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestLoginWithMFACountsInvalidCodes(t *testing.T) {
+	// Arrange
+	setLockout(t, 1, 100, 0)
+	user, _ := newMFAUser(t, "lockout-mfa@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	tokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+	credentials := valueobjects.MFACredentials{MFAToken: tokens.MFAToken, Code: "000000"}
+	_, err = userService.LoginWithMFA(ctx, credentials)
+	assert.Equal(t, errors.ErrInvalidMFACode, err)
+
+	// Act
+	_, err = userService.LoginWithMFA(ctx, credentials)
+
+	// Assert
+	assert.Equal(t, errors.ErrAccountLocked, err)
+}
+
+func TestLoginKeepsFailuresUntilTheSecondFactorPasses(t *testing.T) {
+	// Arrange
+	setLockout(t, 3, 100, 0)
+	user, factor := newMFAUser(t, "lockout-mfa-pending@test.com", false)
+	ctx := echo.New().NewContext(nil, nil)
+	_, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "wrong"})
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+	tokens, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+	throttle, err := loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeAccount, user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, throttle.FailedAttempts)
+
+	// Act
+	_, err = userService.LoginWithMFA(ctx, valueobjects.MFACredentials{
+		MFAToken: tokens.MFAToken,
+		Code:     totpCode(t, factor.Secret, 0),
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeAccount, user.Email)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestUnlockAccountFailsIfNotAdmin(t *testing.T) {
+	// Arrange
+	user := newPasswordUser(t, "unlock-forbidden@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("user", user)
+
+	// Act
+	err := userService.UnlockAccount(ctx, user.ID)
+
+	// Assert
+	assert.Equal(t, errors.ErrForbidden, err)
+}
+
+func TestUnlockAccountLiftsLockout(t *testing.T) {
+	// Arrange
+	setLockout(t, 1, 100, 0)
+	user := newPasswordUser(t, "unlock-account@test.com")
+	ctx := echo.New().NewContext(nil, nil)
+	_, err := userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "wrong"})
+	assert.Equal(t, errors.ErrInvalidCredentials, err)
+	ctx.Set("user", &entities.User{ID: uuid.New(), Role: constants.RoleAdmin})
+
+	// Act
+	err = userService.UnlockAccount(ctx, user.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = userService.Login(ctx, valueobjects.LoginCredentials{Email: user.Email, Password: "test"})
+	assert.NoError(t, err)
+}
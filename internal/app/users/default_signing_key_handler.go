@@ -0,0 +1,84 @@
+package users
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+)
+
+// Structs
+
+type DefaultSigningKeyHandler struct {
+	userService *DefaultUserService
+	period      time.Duration
+}
+
+// Factories
+
+func NewDefaultSigningKeyHandler(userService *DefaultUserService) *DefaultSigningKeyHandler {
+	return &DefaultSigningKeyHandler{
+		userService: userService,
+		period:      config.GetConfig().JWT.KeyRotationInterval,
+	}
+}
+
+// Receivers
+
+// Loop checks the signing keys right away and then every period until the
+// process is told to stop. The first pass creates the initial key, and later
+// ones rotate it once due and drop the retired keys that expired.
+func (h *DefaultSigningKeyHandler) Loop() {
+	logger := config.GetLogger()
+	logger.Info("Starting signing key rotation loop...")
+	ticker := time.NewTicker(h.period)
+	defer ticker.Stop()
+	// Graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	h.tick(time.Now().UTC())
+	for {
+		select {
+		case now := <-ticker.C:
+			h.tick(now.UTC())
+		case <-sigChan:
+			logger.Info("Signing key rotation loop shut down.")
+			return
+		}
+	}
+}
+
+// HandleTick rotates the signing keys if the current one is due.
+func (h *DefaultSigningKeyHandler) HandleTick(ctx echo.Context, now time.Time) error {
+	rotated, err := h.userService.RotateSigningKeys(ctx, now)
+	if rotated {
+		config.GetLoggerFromContext(ctx).Info("Rotated the token signing key.")
+	}
+	return err
+}
+
+// ServeJWKS writes the public signing keys, to be routed at
+// constants.JWKSPath.
+func (h *DefaultSigningKeyHandler) ServeJWKS(ctx echo.Context) error {
+	jwks, err := h.userService.GetJWKS(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, jwks)
+}
+
+// Helpers
+
+func (h *DefaultSigningKeyHandler) tick(now time.Time) {
+	logger := config.GetLogger()
+	ctx := echo.New().NewContext(nil, nil)
+	ctx.Set("logger", logger)
+	err := h.HandleTick(ctx, now)
+	if err != nil {
+		logger.Errorf("Error rotating signing keys: %s", err)
+	}
+}
@@ -1,6 +1,8 @@
 package users
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
@@ -19,14 +21,42 @@ type UserService interface {
 	RegisterUser(ctx echo.Context, email, password, firstName, lastName, role string) (*entities.User, error)
 	PasswordReset(ctx echo.Context, password string) error
 	CheckUserPassword(ctx echo.Context, user *entities.User, password string) error
+	UnlockAccount(ctx echo.Context, userID uuid.UUID) error
+	// Account email services
+	RequestEmailVerification(ctx echo.Context, email string) error
+	VerifyEmail(ctx echo.Context, token string) error
+	RequestPasswordReset(ctx echo.Context, email string) error
+	ResetPasswordWithToken(ctx echo.Context, token string, password string) error
 	// Authentication services
 	Authenticate(ctx echo.Context, credentials valueobjects.LoginCredentials) (*entities.User, error)
 	Logout(ctx echo.Context) error
 	GetClaimsFromToken(ctx echo.Context, tokenString string) (*entities.JWTClaim, error)
-	GenerateAccessToken(ctx echo.Context, user *entities.User) (string, error)
-	GenerateRefreshToken(ctx echo.Context, user *entities.User) (string, error)
+	GenerateAccessToken(ctx echo.Context, user *entities.User, session *entities.Session) (string, error)
+	GenerateRefreshToken(ctx echo.Context, user *entities.User, session *entities.Session) (string, error)
 	GetTokens(ctx echo.Context, user *entities.User) (*valueobjects.Tokens, error)
 	Login(ctx echo.Context, credentials valueobjects.LoginCredentials) (*valueobjects.Tokens, error)
 	RefreshToken(ctx echo.Context, refreshToken string) (*valueobjects.Tokens, error)
 	ValidateAccessToken(ctx echo.Context, tokenString string) (*entities.JWTClaim, error)
+	// Session services
+	ListSessions(ctx echo.Context) (*entities.Sessions, error)
+	RevokeSession(ctx echo.Context, id uuid.UUID) error
+	// Signing key services
+	RotateSigningKeys(ctx echo.Context, now time.Time) (bool, error)
+	GetJWKS(ctx echo.Context) (*valueobjects.JWKS, error)
+	// Two-factor authentication services
+	LoginWithMFA(ctx echo.Context, credentials valueobjects.MFACredentials) (*valueobjects.Tokens, error)
+	GenerateMFAToken(ctx echo.Context, user *entities.User) (string, error)
+	EnrollMFA(ctx echo.Context) (*valueobjects.MFAEnrollment, error)
+	ConfirmMFA(ctx echo.Context, code string) (*valueobjects.MFARecoveryCodes, error)
+	RegenerateRecoveryCodes(ctx echo.Context, code string) (*valueobjects.MFARecoveryCodes, error)
+	SetMFARequiredForSensitiveActions(ctx echo.Context, required bool) error
+	ResetMFA(ctx echo.Context, userID uuid.UUID) error
+	SecondFactorGuard
+}
+
+// SecondFactorGuard makes users who asked for it confirm sensitive actions,
+// such as adding exchange keys or enabling trading, with a TOTP code. The
+// code travels in the "mfa_code" context value.
+type SecondFactorGuard interface {
+	RequireSecondFactor(ctx echo.Context, userID uuid.UUID) error
 }
@@ -1,22 +1,38 @@
 package users
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	uacs "github.com/sergiovirahonda/endurance-api/internal/app/uac"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/notification"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/repositories/user"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gorm.io/gorm"
 )
 
 var (
-	database    *gorm.DB
-	repository  user.UserRepository
-	userService UserService
-	uacService  uacs.UacService
+	database   *gorm.DB
+	repository user.UserRepository
+	// Sessions
+	sessionRepository user.SessionRepository
+	// Signing keys
+	signingKeyRepository user.SigningKeyRepository
+	// Two-factor authentication
+	mfaFactorRepository user.MFAFactorRepository
+	// Account emails
+	userTokenRepository user.UserTokenRepository
+	mailer              *notification.CaptureMailer
+	// Login throttling
+	loginThrottleRepository user.LoginThrottleRepository
+	captchaVerifier         *stubCaptchaVerifier
+	userService             UserService
+	uacService              uacs.UacService
 )
 
 func TestMain(m *testing.M) {
@@ -27,13 +43,49 @@ func TestMain(m *testing.M) {
 	logger.Info("Test DB connection established.")
 	models := []interface{}{
 		&dtos.User{},
+		&dtos.Session{},
+		&dtos.SigningKey{},
+		&dtos.MFAFactor{},
+		&dtos.UserToken{},
+		&dtos.LoginThrottle{},
 	}
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
 	logger.Info("Migrations completed. Running tests...")
+	keyring, err := lib.NewKeyring("test", map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		logger.Fatalf("Error building test keyring: %s", err)
+	}
+	mfaFactorRepository = user.NewDefaultMFAFactorRepository(database, keyring)
 	repository = user.NewDefaultUserRepository(database)
 	uacService = uacs.NewDefaultUacService()
-	userService = NewDefaultUserService(repository, uacService)
+	sessionRepository = user.NewDefaultSessionRepository(database)
+	signingKeyRepository = user.NewDefaultSigningKeyRepository(database, keyring)
+	userTokenRepository = user.NewDefaultUserTokenRepository(database)
+	mailer = notification.NewCaptureMailer()
+	loginThrottleRepository = user.NewDefaultLoginThrottleRepository(database)
+	captchaVerifier = &stubCaptchaVerifier{}
+	userService = NewDefaultUserService(
+		repository,
+		sessionRepository,
+		signingKeyRepository,
+		mfaFactorRepository,
+		userTokenRepository,
+		loginThrottleRepository,
+		mailer,
+		captchaVerifier,
+		uacService,
+	)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
+
+// stubCaptchaVerifier accepts the "solved" token only.
+type stubCaptchaVerifier struct{}
+
+func (v *stubCaptchaVerifier) Verify(ctx context.Context, token string, remoteIP string) error {
+	if token != "solved" {
+		return errors.ErrInvalidCaptcha
+	}
+	return nil
+}
@@ -1,11 +1,21 @@
 package config
 
 import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
 	"github.com/joeshaw/envdecode"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 )
 
 var config *Config
 
+// EnvLocal is the environment of development machines, the only one allowed
+// to run without an encryption master key.
+const EnvLocal = "local"
+
 type (
 	Config struct {
 		Server
@@ -13,12 +23,27 @@ type (
 		NATS
 		Logger
 		JWT
+		MFA
+		Accounts
+		Lockout
+		Captcha
 		Binance
+		Kraken
+		Risk
+		Regime
+		Telegram
+		Notifications
+		Encryption
 	}
 	// Server configurations
 	Server struct {
 		Port string `env:"SERVER_PORT,default=8080"`
 		Env  string `env:"SERVER_ENV,default=local"`
+		// Comma separated CIDR ranges of the reverse proxies in front of
+		// the API. Only they are trusted to report the client address in
+		// X-Forwarded-For; without any, the address of the connection is
+		// used and forwarding headers are ignored.
+		TrustedProxies string `env:"SERVER_TRUSTED_PROXIES"`
 	}
 	// Database configurations
 	Database struct {
@@ -37,18 +62,129 @@ type (
 		// Domain events
 		NatsDomainEventsPattern           string `env:"NATS_DOMAIN_EVENTS_PATTERN,default=endurance.events.*"`
 		NatsMarketDataDomainEventsSubject string `env:"NATS_MARKET_DATA_DOMAIN_EVENTS_SUBJECT,default=endurance.events.market_data"`
+		NatsTradingDomainEventsSubject    string `env:"NATS_TRADING_DOMAIN_EVENTS_SUBJECT,default=endurance.events.trading"`
 	}
 	Logger struct {
 		Level          int64 `env:"LOG_LEVEL,default=4"`
 		LogTestQueries bool  `env:"LOG_TEST_QUERIES"`
 	}
+	// Tokens are signed with rotating Ed25519 keys stored in the database,
+	// their private halves sealed under the encryption master key.
 	JWT struct {
-		SigningKey string `env:"JWT_SIGNING_KEY,default=secret"`
+		AccessTokenTTL      time.Duration `env:"JWT_ACCESS_TOKEN_TTL,default=5m"`
+		RefreshTokenTTL     time.Duration `env:"JWT_REFRESH_TOKEN_TTL,default=24h"`
+		KeyRotationPeriod   time.Duration `env:"JWT_KEY_ROTATION_PERIOD,default=720h"`
+		KeyRotationInterval time.Duration `env:"JWT_KEY_ROTATION_INTERVAL,default=1h"`
+	}
+	MFA struct {
+		Issuer            string        `env:"MFA_ISSUER,default=Endurance"`
+		TokenTTL          time.Duration `env:"MFA_TOKEN_TTL,default=5m"`
+		RecoveryCodeCount int           `env:"MFA_RECOVERY_CODE_COUNT,default=10"`
+		// Time steps accepted either side of the current one, for clock drift
+		Skew int64 `env:"MFA_SKEW,default=1"`
+	}
+	// Email verification and password reset. Links in the emails point to
+	// BaseURL, the frontend handling them.
+	Accounts struct {
+		BaseURL              string        `env:"ACCOUNTS_BASE_URL,default=http://localhost:3000"`
+		EmailVerificationTTL time.Duration `env:"ACCOUNTS_EMAIL_VERIFICATION_TTL,default=48h"`
+		PasswordResetTTL     time.Duration `env:"ACCOUNTS_PASSWORD_RESET_TTL,default=1h"`
+	}
+	// Login throttling. Failed logins are counted per account and per IP,
+	// locking either once over its threshold for LockoutBase, doubled with
+	// every further failure up to LockoutMax. Counts start over after Window
+	// without failures. From CaptchaThreshold failures a CAPTCHA is asked
+	// for too, zero disables it.
+	Lockout struct {
+		AccountThreshold int           `env:"LOCKOUT_ACCOUNT_THRESHOLD,default=5"`
+		IPThreshold      int           `env:"LOCKOUT_IP_THRESHOLD,default=20"`
+		CaptchaThreshold int           `env:"LOCKOUT_CAPTCHA_THRESHOLD,default=0"`
+		LockoutBase      time.Duration `env:"LOCKOUT_BASE,default=1m"`
+		LockoutMax       time.Duration `env:"LOCKOUT_MAX,default=1h"`
+		Window           time.Duration `env:"LOCKOUT_WINDOW,default=15m"`
+	}
+	// CAPTCHA verification through a siteverify endpoint, as offered by
+	// hCaptcha, reCAPTCHA and Turnstile alike
+	Captcha struct {
+		VerifyURL   string        `env:"CAPTCHA_VERIFY_URL,default=https://api.hcaptcha.com/siteverify"`
+		Secret      string        `env:"CAPTCHA_SECRET"`
+		HTTPTimeout time.Duration `env:"CAPTCHA_HTTP_TIMEOUT,default=10s"`
 	}
 	Binance struct {
 		BaseURL   string `env:"BINANCE_BASE_URL,default=https://api.binance.com"`
 		APIKey    string `env:"BINANCE_API_KEY"`
 		APISecret string `env:"BINANCE_API_SECRET"`
+		// WebSocket ingestion
+		WebSocketMinBackoff       time.Duration `env:"BINANCE_WS_MIN_BACKOFF,default=1s"`
+		WebSocketMaxBackoff       time.Duration `env:"BINANCE_WS_MAX_BACKOFF,default=1m"`
+		WebSocketMaxReconnects    int64         `env:"BINANCE_WS_MAX_RECONNECTS,default=10"`
+		WebSocketHeartbeatTimeout time.Duration `env:"BINANCE_WS_HEARTBEAT_TIMEOUT,default=1m"`
+		WebSocketBufferSize       int64         `env:"BINANCE_WS_BUFFER_SIZE,default=1000"`
+		// Trading rules
+		SymbolFiltersTTL time.Duration `env:"BINANCE_SYMBOL_FILTERS_TTL,default=1h"`
+		// Rate limits
+		RequestWeightLimit    int64         `env:"BINANCE_REQUEST_WEIGHT_LIMIT,default=6000"`
+		SapiIPWeightLimit     int64         `env:"BINANCE_SAPI_IP_WEIGHT_LIMIT,default=12000"`
+		SapiUIDWeightLimit    int64         `env:"BINANCE_SAPI_UID_WEIGHT_LIMIT,default=180000"`
+		OrderCountLimit       int64         `env:"BINANCE_ORDER_COUNT_LIMIT,default=50"`
+		RateLimitSafetyMargin float64       `env:"BINANCE_RATE_LIMIT_SAFETY_MARGIN,default=0.9"`
+		RateLimitMaxWait      time.Duration `env:"BINANCE_RATE_LIMIT_MAX_WAIT,default=5s"`
+		RateLimitMinBackoff   time.Duration `env:"BINANCE_RATE_LIMIT_MIN_BACKOFF,default=1s"`
+		RateLimitMaxBackoff   time.Duration `env:"BINANCE_RATE_LIMIT_MAX_BACKOFF,default=5m"`
+	}
+	Kraken struct {
+		BaseURL      string        `env:"KRAKEN_BASE_URL,default=https://api.kraken.com"`
+		PollInterval time.Duration `env:"KRAKEN_POLL_INTERVAL,default=30s"`
+	}
+	// Global risk limits, applied to every user on top of their own. Zero
+	// disables a limit.
+	Risk struct {
+		MaxDailyLossPercentage      float64 `env:"RISK_MAX_DAILY_LOSS_PERCENTAGE,default=0"`
+		MaxDrawdownPercentage       float64 `env:"RISK_MAX_DRAWDOWN_PERCENTAGE,default=0"`
+		MaxTradesPerDay             int64   `env:"RISK_MAX_TRADES_PER_DAY,default=0"`
+		MaxSymbolExposurePercentage float64 `env:"RISK_MAX_SYMBOL_EXPOSURE_PERCENTAGE,default=0"`
+	}
+	// Market regime monitor. Breadth is the percentage of enabled markets
+	// closing below their SMA50 and the ATR spike how many times the latest
+	// ATR is over its average in the lookback window. The BTC drawdown is
+	// measured on the benchmark symbol.
+	Regime struct {
+		BenchmarkSymbol    string        `env:"REGIME_BENCHMARK_SYMBOL,default=BTCUSDT"`
+		Lookback           time.Duration `env:"REGIME_LOOKBACK,default=24h"`
+		EvaluationInterval time.Duration `env:"REGIME_EVALUATION_INTERVAL,default=5m"`
+		RiskOffBreadth     float64       `env:"REGIME_RISK_OFF_BREADTH,default=70"`
+		RiskOnBreadth      float64       `env:"REGIME_RISK_ON_BREADTH,default=30"`
+		RiskOffATRSpike    float64       `env:"REGIME_RISK_OFF_ATR_SPIKE,default=2"`
+		RiskOffBTCDrawdown float64       `env:"REGIME_RISK_OFF_BTC_DRAWDOWN,default=15"`
+		RiskOnBTCDrawdown  float64       `env:"REGIME_RISK_ON_BTC_DRAWDOWN,default=5"`
+	}
+	Telegram struct {
+		APIURL         string        `env:"TELEGRAM_API_URL,default=https://api.telegram.org"`
+		PairingCodeTTL time.Duration `env:"TELEGRAM_PAIRING_CODE_TTL,default=10m"`
+	}
+	Notifications struct {
+		MaxAttempts  int           `env:"NOTIFICATIONS_MAX_ATTEMPTS,default=3"`
+		RetryBackoff time.Duration `env:"NOTIFICATIONS_RETRY_BACKOFF,default=2s"`
+		HTTPTimeout  time.Duration `env:"NOTIFICATIONS_HTTP_TIMEOUT,default=10s"`
+		SMTPHost     string        `env:"NOTIFICATIONS_SMTP_HOST,default=localhost"`
+		SMTPPort     string        `env:"NOTIFICATIONS_SMTP_PORT,default=587"`
+		SMTPUsername string        `env:"NOTIFICATIONS_SMTP_USERNAME"`
+		SMTPPassword string        `env:"NOTIFICATIONS_SMTP_PASSWORD"`
+		SMTPFrom     string        `env:"NOTIFICATIONS_SMTP_FROM,default=notifications@endurance.local"`
+		// Mailer of account emails, smtp or capture to keep them in memory
+		Mailer       string        `env:"NOTIFICATIONS_MAILER,default=smtp"`
+		DigestPeriod time.Duration `env:"NOTIFICATIONS_DIGEST_PERIOD,default=5m"`
+	}
+	// Envelope encryption of secrets at rest. Rotating the master key means
+	// giving the new one a new ID and moving the old one to the previous
+	// keys until the re-encryption loop has rewritten every record.
+	Encryption struct {
+		MasterKeyID        string        `env:"ENCRYPTION_MASTER_KEY_ID,default=default"`
+		MasterKey          string        `env:"ENCRYPTION_MASTER_KEY"`
+		MasterKeyFile      string        `env:"ENCRYPTION_MASTER_KEY_FILE"`
+		PreviousMasterKeys string        `env:"ENCRYPTION_PREVIOUS_MASTER_KEYS"`
+		ReencryptPeriod    time.Duration `env:"ENCRYPTION_REENCRYPT_PERIOD,default=1h"`
+		ReencryptBatchSize int           `env:"ENCRYPTION_REENCRYPT_BATCH_SIZE,default=100"`
 	}
 )
 
@@ -56,10 +192,57 @@ func initCfg() {
 	if config != nil {
 		return
 	}
-	config = &Config{}
-	if err := envdecode.Decode(config); err != nil {
+	cfg, err := LoadConfig()
+	if err != nil {
 		panic(err)
 	}
+	config = cfg
+}
+
+// LoadConfig reads the configuration from the environment and validates
+// it, so startup can report what is wrong with it.
+func LoadConfig() (*Config, error) {
+	cfg := &Config{}
+	if err := envdecode.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate refuses settings that are only safe for local development, or
+// that can't work together.
+func (c *Config) Validate() error {
+	// The master key seals the token signing keys along with every other
+	// secret at rest
+	if c.Server.Env != EnvLocal && c.Encryption.MasterKey == "" && c.Encryption.MasterKeyFile == "" {
+		return errors.ErrEncryptionKeyMissing
+	}
+	if c.Lockout.CaptchaThreshold > 0 && c.Captcha.Secret == "" {
+		return errors.ErrCaptchaSecretMissing
+	}
+	if _, err := c.TrustedProxyRanges(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TrustedProxyRanges parses the ranges of the trusted reverse proxies.
+func (c *Config) TrustedProxyRanges() ([]*net.IPNet, error) {
+	ranges := []*net.IPNet{}
+	for _, value := range strings.Split(c.Server.TrustedProxies, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errors.ErrTrustedProxyInvalid
+		}
+		ranges = append(ranges, ipRange)
+	}
+	return ranges, nil
 }
 
 func GetConfig() *Config {
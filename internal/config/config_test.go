@@ -0,0 +1,100 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRefusesMissingMasterKeyOutsideLocal(t *testing.T) {
+	// Arrange
+	cfg := Config{}
+	cfg.Server.Env = "production"
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.Equal(t, errors.ErrEncryptionKeyMissing, err)
+}
+
+func TestValidateAcceptsMasterKeyOutsideLocal(t *testing.T) {
+	// Arrange
+	cfg := Config{}
+	cfg.Server.Env = "production"
+	cfg.Encryption.MasterKeyFile = "/run/secrets/master_key"
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestValidateAllowsMissingMasterKeyInLocal(t *testing.T) {
+	// Arrange
+	cfg := Config{}
+	cfg.Server.Env = EnvLocal
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestValidateRefusesCaptchaWithoutSecret(t *testing.T) {
+	// Arrange
+	cfg := Config{}
+	cfg.Server.Env = EnvLocal
+	cfg.Lockout.CaptchaThreshold = 3
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.Equal(t, errors.ErrCaptchaSecretMissing, err)
+}
+
+func TestLoadConfigWrapsValidationErrors(t *testing.T) {
+	// Arrange
+	t.Setenv("SERVER_ENV", EnvLocal)
+	t.Setenv("LOCKOUT_CAPTCHA_THRESHOLD", "3")
+	t.Setenv("CAPTCHA_SECRET", "")
+
+	// Act
+	cfg, err := LoadConfig()
+
+	// Assert
+	assert.Nil(t, cfg)
+	assert.ErrorIs(t, err, errors.ErrCaptchaSecretMissing)
+}
+
+func TestValidateRefusesInvalidTrustedProxies(t *testing.T) {
+	// Arrange
+	cfg := Config{}
+	cfg.Server.Env = EnvLocal
+	cfg.Server.TrustedProxies = "10.0.0.0/8,proxy.internal"
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.Equal(t, errors.ErrTrustedProxyInvalid, err)
+}
+
+func TestTrustedProxyRangesParsesCommaSeparatedRanges(t *testing.T) {
+	// Arrange
+	cfg := Config{}
+	cfg.Server.TrustedProxies = "10.0.0.0/8, 2001:db8::/32,"
+
+	// Act
+	ranges, err := cfg.TrustedProxyRanges()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, ranges, 2)
+	assert.Equal(t, "10.0.0.0/8", ranges[0].String())
+	assert.Equal(t, "2001:db8::/32", ranges[1].String())
+}
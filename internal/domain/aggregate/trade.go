@@ -1,6 +1,12 @@
 package aggregate
 
-import "github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+import (
+	"math"
+
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
 
 type TradingPositionAggregate struct {
 	Holding           *entities.Holding
@@ -8,3 +14,130 @@ type TradingPositionAggregate struct {
 }
 
 type TradingPositionAggregates []TradingPositionAggregate
+
+// ProtectiveExitSignal evaluates the protective exits of the preference
+// against the price. Stops win over take profits, and every ladder level
+// reached since the last evaluation is sold at once. The high-water mark of
+// the holding must already include the price.
+func (a *TradingPositionAggregate) ProtectiveExitSignal(
+	price float64,
+	atr *float64,
+) valueobjects.ProtectiveExitSignal {
+	holding := a.Holding
+	preference := a.TradingPreference
+	if preference.StopLossPercentage > 0 {
+		stopPrice := holding.EntryPrice * (1 - preference.StopLossPercentage/100)
+		if price <= stopPrice {
+			return valueobjects.ProtectiveExitSignal{
+				Action:       constants.ProtectiveExitActionStopLoss,
+				TriggerPrice: stopPrice,
+				Quantity:     holding.Quantity,
+			}
+		}
+	}
+	if preference.TrailingStopATRMultiple > 0 && atr != nil && *atr > 0 {
+		stopPrice := holding.HighWaterMark - preference.TrailingStopATRMultiple*(*atr)
+		if price <= stopPrice {
+			return valueobjects.ProtectiveExitSignal{
+				Action:       constants.ProtectiveExitActionTrailingStop,
+				TriggerPrice: stopPrice,
+				Quantity:     holding.Quantity,
+			}
+		}
+	}
+	signal := valueobjects.ProtectiveExitSignal{
+		Action: constants.ProtectiveExitActionHold,
+	}
+	ladder := preference.TakeProfitLadder
+	fraction := 0.0
+	for i := holding.TakeProfitLevelsHit; i < len(ladder); i++ {
+		targetPrice := holding.EntryPrice * (1 + ladder[i].TargetPercentage/100)
+		if price < targetPrice {
+			break
+		}
+		fraction += ladder[i].SellFraction
+		signal.TriggerPrice = targetPrice
+		signal.Levels++
+	}
+	if signal.Levels == 0 {
+		return signal
+	}
+	signal.Action = constants.ProtectiveExitActionTakeProfit
+	signal.Quantity = math.Min(fraction*holding.GetInitialQuantity(), holding.Quantity)
+	if holding.TakeProfitLevelsHit+signal.Levels == len(ladder) && fraction > 0 {
+		// The last level closes whatever the ladder left
+		ladderFraction := 0.0
+		for _, level := range ladder {
+			ladderFraction += level.SellFraction
+		}
+		if ladderFraction >= 1-1e-9 {
+			signal.Quantity = holding.Quantity
+		}
+	}
+	return signal
+}
+
+// PortfolioAggregate groups the open holdings of a user with the preference
+// that allocates capital across them.
+type PortfolioAggregate struct {
+	TradingPreference *entities.TradingPreference
+	Holdings          entities.Holdings
+}
+
+// Positions returns the open holdings in assets other than the ledger quote
+// asset, which is the capital waiting to be allocated.
+func (a *PortfolioAggregate) Positions() entities.Holdings {
+	positions := make(entities.Holdings, 0, len(a.Holdings))
+	for _, holding := range a.Holdings {
+		if holding.Status != constants.HoldingStatusOpen || holding.Symbol == constants.LedgerQuoteAsset {
+			continue
+		}
+		positions = append(positions, holding)
+	}
+	return positions
+}
+
+// Holds tells whether the portfolio has an open position in the symbol.
+func (a *PortfolioAggregate) Holds(symbol string) bool {
+	for _, holding := range a.Positions() {
+		if holding.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenSlots returns how many positions can still be opened.
+func (a *PortfolioAggregate) OpenSlots() int {
+	slots := a.TradingPreference.MaxConcurrentHoldings - len(a.Positions())
+	if slots < 0 {
+		return 0
+	}
+	return slots
+}
+
+// Exposures values the positions at the given prices, keyed by symbol.
+// Positions without a price are valued at their entry price.
+func (a *PortfolioAggregate) Exposures(prices map[string]float64) map[string]float64 {
+	exposures := make(map[string]float64)
+	for _, holding := range a.Positions() {
+		price, ok := prices[holding.Symbol]
+		if !ok {
+			price = holding.EntryPrice
+		}
+		exposures[holding.Symbol] += holding.Quantity * price
+	}
+	return exposures
+}
+
+// Equity is the value of the positions at the given prices plus the cash.
+func (a *PortfolioAggregate) Equity(
+	cash float64,
+	prices map[string]float64,
+) float64 {
+	equity := cash
+	for _, exposure := range a.Exposures(prices) {
+		equity += exposure
+	}
+	return equity
+}
@@ -0,0 +1,30 @@
+package constants
+
+const (
+	// Order sides
+	ExchangeOrderSideBuy  = "buy"
+	ExchangeOrderSideSell = "sell"
+
+	// Order types
+	ExchangeOrderTypeMarket = "market"
+
+	// Conversion statuses
+	ExchangeConversionStatusPartial = "PARTIAL"
+)
+
+var (
+	ExchangeOrderSides = []string{
+		ExchangeOrderSideBuy,
+		ExchangeOrderSideSell,
+	}
+	// Quote assets recognised when splitting a canonical symbol, longest first
+	ExchangeQuoteAssets = []string{
+		"FDUSD",
+		"USDT",
+		"USDC",
+		"EUR",
+		"USD",
+		"BTC",
+		"ETH",
+	}
+)
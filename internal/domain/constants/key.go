@@ -1,13 +1,20 @@
 package constants
 
 const (
-	ApiKeyServiceTypeBinance = "binance"
+	ApiKeyServiceTypeBinance  = "binance"
+	ApiKeyServiceTypeKraken   = "kraken"
 	ApiKeyServiceTypeTelegram = "telegram"
 )
 
 var (
 	ApiKeyServiceTypes = []string{
 		ApiKeyServiceTypeBinance,
+		ApiKeyServiceTypeKraken,
 		ApiKeyServiceTypeTelegram,
 	}
-)
\ No newline at end of file
+	// Services backed by an exchange adapter
+	ExchangeServiceTypes = []string{
+		ApiKeyServiceTypeBinance,
+		ApiKeyServiceTypeKraken,
+	}
+)
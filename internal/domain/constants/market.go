@@ -7,4 +7,71 @@ const (
 	PartialMarketDataEvent  = "partial_market_data"
 	// Domain event tpes
 	MarketDataPushedEvent = "market_data_pushed"
+
+	// Market regimes
+	MarketRegimeRiskOn  = "risk_on"
+	MarketRegimeNeutral = "neutral"
+	MarketRegimeRiskOff = "risk_off"
+
+	// Alert rule conditions, met once the field crosses the threshold
+	AlertConditionAbove = "above"
+	AlertConditionBelow = "below"
+
+	// Market data fields alert rules can watch, named after their JSON keys
+	AlertFieldOpen                = "open"
+	AlertFieldHigh                = "high"
+	AlertFieldLow                 = "low"
+	AlertFieldClose               = "close"
+	AlertFieldVolume              = "volume"
+	AlertFieldMACD                = "macd"
+	AlertFieldMACDSignal          = "macd_signal"
+	AlertFieldMACDHist            = "macd_hist"
+	AlertFieldRSI6                = "rsi6"
+	AlertFieldRSI12               = "rsi12"
+	AlertFieldRSI24               = "rsi24"
+	AlertFieldSMA20               = "sma20"
+	AlertFieldSMA50               = "sma50"
+	AlertFieldSMA200              = "sma200"
+	AlertFieldATR                 = "atr"
+	AlertFieldBollingerBandsWidth = "bollinger_bands_width"
+	AlertFieldBollingerBandsUpper = "bollinger_bands_upper"
+	AlertFieldBollingerBandsLower = "bollinger_bands_lower"
+	AlertFieldOBV                 = "obv"
+	AlertFieldADX                 = "adx"
+	AlertFieldScore               = "score"
+)
+
+var (
+	MarketRegimes = []string{
+		MarketRegimeRiskOn,
+		MarketRegimeNeutral,
+		MarketRegimeRiskOff,
+	}
+	AlertConditions = []string{
+		AlertConditionAbove,
+		AlertConditionBelow,
+	}
+	AlertFields = []string{
+		AlertFieldOpen,
+		AlertFieldHigh,
+		AlertFieldLow,
+		AlertFieldClose,
+		AlertFieldVolume,
+		AlertFieldMACD,
+		AlertFieldMACDSignal,
+		AlertFieldMACDHist,
+		AlertFieldRSI6,
+		AlertFieldRSI12,
+		AlertFieldRSI24,
+		AlertFieldSMA20,
+		AlertFieldSMA50,
+		AlertFieldSMA200,
+		AlertFieldATR,
+		AlertFieldBollingerBandsWidth,
+		AlertFieldBollingerBandsUpper,
+		AlertFieldBollingerBandsLower,
+		AlertFieldOBV,
+		AlertFieldADX,
+		AlertFieldScore,
+	}
 )
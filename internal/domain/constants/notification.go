@@ -0,0 +1,114 @@
+package constants
+
+const (
+	// Telegram chat pairing
+	TelegramPairingCodeLength = 8
+
+	// Telegram bot commands. Pairing is accepted on /start too, which is
+	// what deep links to the bot send.
+	TelegramCommandStart    = "/start"
+	TelegramCommandPair     = "/pair"
+	TelegramCommandStatus   = "/status"
+	TelegramCommandScores   = "/scores"
+	TelegramCommandPause    = "/pause"
+	TelegramCommandResume   = "/resume"
+	TelegramCommandHistory  = "/history"
+	TelegramCommandStopLoss = "/stoploss"
+)
+
+const (
+	// Notification channels
+	NotificationChannelTelegram = "telegram"
+	NotificationChannelEmail    = "email"
+	NotificationChannelSlack    = "slack"
+	NotificationChannelWebhook  = "webhook"
+
+	// Notification kinds
+	NotificationKindTrade          = "trade"
+	NotificationKindStopLoss       = "stop_loss"
+	NotificationKindTakeProfit     = "take_profit"
+	NotificationKindPositionOpened = "position_opened"
+	NotificationKindKillSwitch     = "kill_switch"
+	NotificationKindTradeProposal  = "trade_proposal"
+	NotificationKindDailyDigest    = "daily_digest"
+	NotificationKindWeeklyDigest   = "weekly_digest"
+	NotificationKindAlert          = "alert"
+
+	// Notification delivery statuses
+	NotificationDeliveryStatusDelivered = "delivered"
+	NotificationDeliveryStatusFailed    = "failed"
+	// Failed so far, with attempts left to go in the background
+	NotificationDeliveryStatusRetrying = "retrying"
+	// Held back by the quiet hours of the user
+	NotificationDeliveryStatusSuppressed = "suppressed"
+
+	// Notification formats
+	NotificationFormatText     = "text"
+	NotificationFormatMarkdown = "markdown"
+	NotificationFormatHTML     = "html"
+
+	// Notification locales
+	NotificationLocaleEnglish = "en"
+	NotificationLocaleSpanish = "es"
+
+	// Digest frequencies
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+
+	// Risk events reported in digests
+	DigestRiskEventKillSwitch = "kill_switch"
+	DigestRiskEventRiskOff    = "risk_off"
+
+	// Headers of generic webhook deliveries
+	WebhookSignatureHeader = "X-Endurance-Signature"
+	WebhookTimestampHeader = "X-Endurance-Timestamp"
+)
+
+var NotificationChannels = []string{
+	NotificationChannelTelegram,
+	NotificationChannelEmail,
+	NotificationChannelSlack,
+	NotificationChannelWebhook,
+}
+
+var NotificationKinds = []string{
+	NotificationKindTrade,
+	NotificationKindStopLoss,
+	NotificationKindTakeProfit,
+	NotificationKindPositionOpened,
+	NotificationKindKillSwitch,
+	NotificationKindTradeProposal,
+	NotificationKindDailyDigest,
+	NotificationKindWeeklyDigest,
+	NotificationKindAlert,
+}
+
+// Notification kinds delivered during quiet hours too, as they need the user
+// attention right away.
+var QuietHoursBypassKinds = []string{
+	NotificationKindKillSwitch,
+	NotificationKindTradeProposal,
+}
+
+var NotificationLocales = []string{
+	NotificationLocaleEnglish,
+	NotificationLocaleSpanish,
+}
+
+var DigestFrequencies = []string{
+	DigestFrequencyDaily,
+	DigestFrequencyWeekly,
+}
+
+var NotificationDeliveryStatuses = []string{
+	NotificationDeliveryStatusDelivered,
+	NotificationDeliveryStatusFailed,
+	NotificationDeliveryStatusRetrying,
+	NotificationDeliveryStatusSuppressed,
+}
+
+// Mailers of account emails
+const (
+	MailerSMTP    = "smtp"
+	MailerCapture = "capture"
+)
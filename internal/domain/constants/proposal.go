@@ -0,0 +1,24 @@
+package constants
+
+const (
+	// Trade proposal statuses. Pending proposals wait for the user decision,
+	// deciding ones were claimed by a decision in progress and failed ones
+	// were approved but could not be executed.
+	TradeProposalStatusPending  = "pending"
+	TradeProposalStatusDeciding = "deciding"
+	TradeProposalStatusApproved = "approved"
+	TradeProposalStatusRejected = "rejected"
+	TradeProposalStatusExpired  = "expired"
+	TradeProposalStatusFailed   = "failed"
+)
+
+var (
+	TradeProposalStatuses = []string{
+		TradeProposalStatusPending,
+		TradeProposalStatusDeciding,
+		TradeProposalStatusApproved,
+		TradeProposalStatusRejected,
+		TradeProposalStatusExpired,
+		TradeProposalStatusFailed,
+	}
+)
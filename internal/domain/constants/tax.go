@@ -0,0 +1,21 @@
+package constants
+
+const (
+	// Cost basis methods, the order in which tax lots are disposed of
+	CostBasisMethodFIFO = "fifo"
+	CostBasisMethodLIFO = "lifo"
+	CostBasisMethodHIFO = "hifo"
+
+	// Holding periods of a disposal
+	TaxTermShort   = "short"
+	TaxTermLong    = "long"
+	TaxTermUnknown = "unknown"
+)
+
+var (
+	CostBasisMethods = []string{
+		CostBasisMethodFIFO,
+		CostBasisMethodLIFO,
+		CostBasisMethodHIFO,
+	}
+)
@@ -1,6 +1,10 @@
 package constants
 
 const (
+	// Events
+	TradingEventDomain     = "trading"
+	RiskLimitBreachedEvent = "risk_limit_breached"
+
 	TradingPreferenceRiskLevelLow    = "low"
 	TradingPreferenceRiskLevelMedium = "medium"
 	TradingPreferenceRiskLevelHigh   = "high"
@@ -14,10 +18,12 @@ const (
 	OrderStatusOpen    = "open"
 	OrderStatusFilled  = "filled"
 	OrderStatusPending = "pending"
+	OrderStatusFailed  = "failed"
 
 	// Order types
 	OrderTypeStopLoss   = "stop_loss"
 	OrderTypeTakeProfit = "take_profit"
+	OrderTypeEntry      = "entry"
 
 	// Holding statuses
 	HoldingStatusOpen   = "open"
@@ -26,6 +32,41 @@ const (
 	// Pull back trade signals
 	PullBackTradeSignalHold = "hold"
 	PullBackTradeSignalSell = "sell"
+
+	// Protective exit actions
+	ProtectiveExitActionHold         = "hold"
+	ProtectiveExitActionStopLoss     = "stop_loss"
+	ProtectiveExitActionTrailingStop = "trailing_stop"
+	ProtectiveExitActionTakeProfit   = "take_profit"
+
+	// Position sizing methods
+	PositionSizingMethodFixedFraction    = "fixed_fraction"
+	PositionSizingMethodVolatilityParity = "volatility_parity"
+	PositionSizingMethodRiskPerTrade     = "risk_per_trade"
+
+	// Risk limits
+	RiskLimitDailyLoss      = "daily_loss"
+	RiskLimitDrawdown       = "drawdown"
+	RiskLimitTradesPerDay   = "trades_per_day"
+	RiskLimitSymbolExposure = "symbol_exposure"
+
+	// Execution modes. Orders and holdings are booked under the mode that
+	// produced them, paper and shadow ones never reach the exchange.
+	ExecutionModeLive   = "live"
+	ExecutionModePaper  = "paper"
+	ExecutionModeShadow = "shadow"
+
+	// Risk-off actions
+	RiskOffActionNone          = "none"
+	RiskOffActionFreezeEntries = "freeze_entries"
+	RiskOffActionExitToQuote   = "exit_to_usdt"
+
+	// Ledger entry types
+	LedgerEntryTypeConversion  = "conversion"
+	LedgerEntryTypeMarketOrder = "market_order"
+
+	// Ledger quote asset, in which costs, proceeds and PnL are expressed
+	LedgerQuoteAsset = "USDT"
 )
 
 var (
@@ -43,13 +84,34 @@ var (
 		OrderStatusOpen,
 		OrderStatusFilled,
 		OrderStatusPending,
+		OrderStatusFailed,
 	}
 	OrderTypes = []string{
 		OrderTypeStopLoss,
 		OrderTypeTakeProfit,
+		OrderTypeEntry,
 	}
 	HoldingStatuses = []string{
 		HoldingStatusOpen,
 		HoldingStatusClosed,
 	}
+	PositionSizingMethods = []string{
+		PositionSizingMethodFixedFraction,
+		PositionSizingMethodVolatilityParity,
+		PositionSizingMethodRiskPerTrade,
+	}
+	ExecutionModes = []string{
+		ExecutionModeLive,
+		ExecutionModePaper,
+		ExecutionModeShadow,
+	}
+	RiskOffActions = []string{
+		RiskOffActionNone,
+		RiskOffActionFreezeEntries,
+		RiskOffActionExitToQuote,
+	}
+	LedgerEntryTypes = []string{
+		LedgerEntryTypeConversion,
+		LedgerEntryTypeMarketOrder,
+	}
 )
@@ -11,3 +11,46 @@ var Roles = []string{
 	RoleAdmin,
 	RoleUser,
 }
+
+// Why a session was revoked
+const (
+	SessionRevokedLogout = "logout"
+	SessionRevokedByUser = "revoked"
+	SessionRevokedReuse  = "refresh_token_reuse"
+	// The second factor of the user was reset by an admin
+	SessionRevokedMFAReset = "mfa_reset"
+	// The password was reset through a reset token
+	SessionRevokedPasswordReset = "password_reset"
+)
+
+// Token signing
+const (
+	SigningAlgorithmEdDSA = "EdDSA"
+	JWKKeyTypeOKP         = "OKP"
+	JWKCurveEd25519       = "Ed25519"
+	JWKUseSignature       = "sig"
+	// JWKSPath is where the public signing keys are served from
+	JWKSPath = "/.well-known/jwks.json"
+)
+
+// Audiences of the tokens issued
+const (
+	TokenAudienceAccess  = "access"
+	TokenAudienceRefresh = "refresh"
+	// Short-lived token between the password and the second factor of a login
+	TokenAudienceMFA = "mfa"
+)
+
+// What failed logins are counted against
+const (
+	LoginThrottleScopeAccount = "account"
+	LoginThrottleScopeIP      = "ip"
+)
+
+// What a user token is for
+const (
+	UserTokenPurposeEmailVerification = "email_verification"
+	UserTokenPurposePasswordReset     = "password_reset"
+	// Length of the tokens sent by email
+	UserTokenLength = 32
+)
@@ -0,0 +1,109 @@
+package entities
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// AlertRule tells the user when a field of the market data of a symbol
+// crosses the threshold in the direction of the condition. Crossings are
+// found against the last value the rule saw, and once fired the rule keeps
+// quiet for the cooldown.
+type AlertRule struct {
+	ID              uuid.UUID  `json:"id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	Symbol          string     `json:"symbol"`
+	Field           string     `json:"field"`
+	Condition       string     `json:"condition"`
+	Threshold       float64    `json:"threshold"`
+	CooldownMinutes int        `json:"cooldown_minutes"`
+	Enabled         bool       `json:"enabled"`
+	LastValue       *float64   `json:"last_value"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type AlertRules []AlertRule
+
+// Validations
+
+func (r *AlertRule) Validate() error {
+	if !strings.HasSuffix(r.Symbol, "USDT") {
+		return errors.ErrInvalidAlertSymbol
+	}
+	if !lib.SliceContains(constants.AlertFields, r.Field) {
+		return errors.ErrInvalidAlertField
+	}
+	if !lib.SliceContains(constants.AlertConditions, r.Condition) {
+		return errors.ErrInvalidAlertCondition
+	}
+	if r.CooldownMinutes < 0 {
+		return errors.ErrInvalidAlertCooldown
+	}
+	return nil
+}
+
+// Receivers
+
+// Met tells whether the value is on the side of the threshold the condition
+// asks for.
+func (r *AlertRule) Met(value float64) bool {
+	if r.Condition == constants.AlertConditionBelow {
+		return value < r.Threshold
+	}
+	return value > r.Threshold
+}
+
+func (r *AlertRule) InCooldown(now time.Time) bool {
+	if r.LastTriggeredAt == nil {
+		return false
+	}
+	return now.Before(r.LastTriggeredAt.Add(time.Duration(r.CooldownMinutes) * time.Minute))
+}
+
+// Observe records the value and tells whether the rule fires on it: the
+// condition must be met now and not on the last value seen, so the first
+// value seen never fires. Crossings during the cooldown are let go.
+func (r *AlertRule) Observe(value float64, now time.Time) bool {
+	crossed := r.LastValue != nil && !r.Met(*r.LastValue) && r.Met(value)
+	r.LastValue = &value
+	r.UpdatedAt = now
+	if !crossed || r.InCooldown(now) {
+		return false
+	}
+	r.LastTriggeredAt = &now
+	return true
+}
+
+// Factories
+
+type AlertRuleFactory struct{}
+
+func (f *AlertRuleFactory) NewAlertRule(
+	userID uuid.UUID,
+	symbol string,
+	field string,
+	condition string,
+	threshold float64,
+	cooldownMinutes int,
+) *AlertRule {
+	now := time.Now().UTC()
+	return &AlertRule{
+		ID:              uuid.New(),
+		UserID:          userID,
+		Symbol:          symbol,
+		Field:           field,
+		Condition:       condition,
+		Threshold:       threshold,
+		CooldownMinutes: cooldownMinutes,
+		Enabled:         true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
@@ -1,9 +1,13 @@
 package entities
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 )
 
 type ExchangeConversionQuote struct {
@@ -23,6 +27,26 @@ type ExchangeConversionOrder struct {
 	ID        string    `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	Status    string    `json:"status"`
+	// What was actually converted, when the exchange reports it. A partial
+	// conversion stops at the ledger quote asset.
+	FromAmount float64 `json:"from_amount"`
+	ToAsset    string  `json:"to_asset"`
+	ToAmount   float64 `json:"to_amount"`
+	Fee        float64 `json:"fee"`
+	FeeAsset   string  `json:"fee_asset"`
+}
+
+type ExchangeOrder struct {
+	ID               string    `json:"id"`
+	Symbol           string    `json:"symbol"`
+	Side             string    `json:"side"`
+	Type             string    `json:"type"`
+	Quantity         float64   `json:"quantity"`
+	ExecutedQuantity float64   `json:"executed_quantity"`
+	Price            float64   `json:"price"`
+	Fee              float64   `json:"fee"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 // Validations
@@ -66,3 +90,74 @@ func (e *ExchangeConversionQuote) ValidateConversionDrift(
 	}
 	return nil
 }
+
+func (e *ExchangeOrder) Validate() error {
+	if !lib.SliceContains(constants.ExchangeOrderSides, e.Side) {
+		return errors.ErrInvalidOrderSide
+	}
+	if e.Quantity <= 0 {
+		return errors.ErrInvalidOrderAmount
+	}
+	if e.ExecutedQuantity < 0 {
+		return errors.ErrInvalidOrderAmount
+	}
+	if e.Price < 0 {
+		return errors.ErrInvalidPrice
+	}
+	return nil
+}
+
+// Receivers
+
+func (e *ExchangeConversionOrder) IsPartial() bool {
+	return e.Status == constants.ExchangeConversionStatusPartial
+}
+
+// ExecutedQuote returns the quote as the exchange executed it, so what a
+// partial conversion did convert can be booked.
+func (e *ExchangeConversionOrder) ExecutedQuote(
+	quote *ExchangeConversionQuote,
+) *ExchangeConversionQuote {
+	executed := *quote
+	executed.ToAsset = e.ToAsset
+	executed.FromAmount = e.FromAmount
+	executed.ToAmount = e.ToAmount
+	executed.Fee = e.Fee
+	executed.FeeAsset = e.FeeAsset
+	executed.Ratio = 0
+	executed.InverseRatio = 0
+	if e.FromAmount > 0 && e.ToAmount > 0 {
+		executed.Ratio = e.ToAmount / e.FromAmount
+		executed.InverseRatio = e.FromAmount / e.ToAmount
+	}
+	return &executed
+}
+
+// Factories
+
+type ExchangeConversionQuoteFactory struct{}
+
+// NewSimulatedConversionQuote prices a conversion at the unit prices of both
+// assets, without fees, for books that never reach the exchange.
+func (f *ExchangeConversionQuoteFactory) NewSimulatedConversionQuote(
+	fromAsset string,
+	toAsset string,
+	fromAmount float64,
+	fromPrice float64,
+	toPrice float64,
+) *ExchangeConversionQuote {
+	quote := &ExchangeConversionQuote{
+		ID:         fmt.Sprintf("simulated-%s", uuid.New()),
+		FromAsset:  fromAsset,
+		ToAsset:    toAsset,
+		FromAmount: fromAmount,
+	}
+	if toPrice > 0 {
+		quote.Ratio = fromPrice / toPrice
+		quote.ToAmount = fromAmount * quote.Ratio
+	}
+	if quote.Ratio > 0 {
+		quote.InverseRatio = 1 / quote.Ratio
+	}
+	return quote
+}
@@ -1,22 +1,31 @@
 package entities
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/lib"
 )
 
+// ApiKey permissions are the ones the exchange reported when the key was
+// last verified. VerifiedAt is nil for services that can't be verified.
 type ApiKey struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Service   string    `json:"service"`
-	Key       string    `json:"key"`
-	Secret    string    `json:"secret"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uuid.UUID  `json:"id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	Service      string     `json:"service"`
+	Key          string     `json:"key"`
+	Secret       string     `json:"secret"`
+	CanTrade     bool       `json:"can_trade"`
+	CanWithdraw  bool       `json:"can_withdraw"`
+	IPRestricted bool       `json:"ip_restricted"`
+	VerifiedAt   *time.Time `json:"verified_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 type ApiKeys []ApiKey
@@ -42,6 +51,39 @@ func (a *ApiKey) Validate() error {
 	return nil
 }
 
+// Receivers
+
+// Verify records the permissions the exchange reported for the key, and
+// rejects keys that can't trade or that could withdraw funds if leaked.
+func (a *ApiKey) Verify(
+	permissions *valueobjects.ExchangeApiKeyPermissions,
+	now time.Time,
+) error {
+	if permissions.CanWithdraw {
+		return errors.ErrApiKeyWithdrawEnabled
+	}
+	if !permissions.CanTrade {
+		return errors.ErrApiKeyTradingDisabled
+	}
+	a.CanTrade = permissions.CanTrade
+	a.CanWithdraw = permissions.CanWithdraw
+	a.IPRestricted = permissions.IPRestricted
+	a.VerifiedAt = &now
+	return nil
+}
+
+// MarshalJSON masks the secret, so keys can be shown without leaking it.
+// Telegram keys hold the bot token itself in Key, so it is masked too.
+func (a ApiKey) MarshalJSON() ([]byte, error) {
+	type apiKey ApiKey
+	masked := apiKey(a)
+	masked.Secret = maskSecret(a.Secret)
+	if a.Service == constants.ApiKeyServiceTypeTelegram {
+		masked.Key = maskSecret(a.Key)
+	}
+	return json.Marshal(masked)
+}
+
 // Factories
 
 type ApiKeyFactory struct{}
@@ -79,3 +121,13 @@ func (f *ApiKeyFactory) Clone(
 		UpdatedAt: apiKey.UpdatedAt,
 	}
 }
+
+// Helpers
+
+// maskSecret keeps the last four characters of long enough secrets.
+func maskSecret(secret string) string {
+	if len(secret) < 12 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
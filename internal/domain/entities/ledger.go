@@ -0,0 +1,207 @@
+package entities
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// LedgerEntry records a single exchange of one asset for another. The From
+// leg is what left the account and the To leg what arrived, so both deltas
+// are the actual balance changes. Values such as proceeds, cost basis and PnL
+// are expressed in the ledger quote asset.
+type LedgerEntry struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	FromHoldingID uuid.UUID `json:"from_holding_id"`
+	ToHoldingID   uuid.UUID `json:"to_holding_id"`
+	OrderID       uuid.UUID `json:"order_id"`
+	Reference     string    `json:"reference"`
+	EntryType     string    `json:"entry_type"`
+	FromAsset     string    `json:"from_asset"`
+	FromDelta     float64   `json:"from_delta"`
+	ToAsset       string    `json:"to_asset"`
+	ToDelta       float64   `json:"to_delta"`
+	Fee           float64   `json:"fee"`
+	FeeAsset      string    `json:"fee_asset"`
+	FeeValue      float64   `json:"fee_value"`
+	ExpectedPrice float64   `json:"expected_price"`
+	ExecutedPrice float64   `json:"executed_price"`
+	Slippage      float64   `json:"slippage"`
+	Proceeds      float64   `json:"proceeds"`
+	CostBasis     float64   `json:"cost_basis"`
+	RealisedPnL   float64   `json:"realised_pnl"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type LedgerEntries []LedgerEntry
+
+// Validations
+
+func (l *LedgerEntry) Validate() error {
+	if !lib.SliceContains(constants.LedgerEntryTypes, l.EntryType) {
+		return errors.ErrInvalidLedgerEntryType
+	}
+	if l.FromAsset == "" || l.ToAsset == "" || l.FromAsset == l.ToAsset {
+		return errors.ErrInvalidLedgerEntryAsset
+	}
+	if l.FromDelta >= 0 || l.ToDelta <= 0 {
+		return errors.ErrInvalidLedgerEntryDelta
+	}
+	if l.Fee < 0 || l.FeeValue < 0 {
+		return errors.ErrInvalidLedgerEntryFee
+	}
+	if l.ExpectedPrice < 0 || l.ExecutedPrice < 0 {
+		return errors.ErrInvalidLedgerEntryPrice
+	}
+	if l.Proceeds < 0 || l.CostBasis < 0 {
+		return errors.ErrInvalidLedgerEntryAmount
+	}
+	return nil
+}
+
+// Receivers
+
+// Settle books the cost basis of the From leg and derives the realised PnL.
+// Fees charged in either leg asset are already part of the deltas, so only
+// fees paid in a third asset are deducted here.
+func (l *LedgerEntry) Settle(costBasis float64) {
+	l.CostBasis = costBasis
+	l.RealisedPnL = l.Proceeds - costBasis - l.ExternalFeeValue()
+}
+
+// ExternalFeeValue returns the value of the fee when it was not taken from
+// any of the legs.
+func (l *LedgerEntry) ExternalFeeValue() float64 {
+	if l.FeeAsset == "" || l.FeeAsset == l.FromAsset || l.FeeAsset == l.ToAsset {
+		return 0
+	}
+	return l.FeeValue
+}
+
+// UnitCost returns the acquisition cost of each unit of the To asset.
+func (l *LedgerEntry) UnitCost() float64 {
+	if l.ToDelta <= 0 {
+		return 0
+	}
+	return (l.Proceeds + l.ExternalFeeValue()) / l.ToDelta
+}
+
+// RealisedPnLPercentage returns the realised PnL relative to the cost basis.
+func (l *LedgerEntry) RealisedPnLPercentage() float64 {
+	if l.CostBasis <= 0 {
+		return 0
+	}
+	return l.RealisedPnL / l.CostBasis * 100
+}
+
+// Factories
+
+type LedgerEntryFactory struct{}
+
+// NewConversionEntry records a conversion quote once it has been accepted.
+// Prices are the value of one unit of each asset before the conversion, and
+// the executed price is the value received per unit of the From asset.
+func (f *LedgerEntryFactory) NewConversionEntry(
+	userID uuid.UUID,
+	fromHoldingID uuid.UUID,
+	toHoldingID uuid.UUID,
+	orderID uuid.UUID,
+	quote *ExchangeConversionQuote,
+	fromPrice float64,
+	toPrice float64,
+) *LedgerEntry {
+	entry := &LedgerEntry{
+		ID:            uuid.New(),
+		UserID:        userID,
+		FromHoldingID: fromHoldingID,
+		ToHoldingID:   toHoldingID,
+		OrderID:       orderID,
+		Reference:     quote.ID,
+		EntryType:     constants.LedgerEntryTypeConversion,
+		FromAsset:     quote.FromAsset,
+		FromDelta:     -quote.FromAmount,
+		ToAsset:       quote.ToAsset,
+		ToDelta:       quote.ToAmount,
+		Fee:           quote.Fee,
+		FeeAsset:      quote.FeeAsset,
+		ExpectedPrice: quoteValue(quote.FromAsset, 1, fromPrice),
+		Proceeds:      quoteValue(quote.ToAsset, quote.ToAmount, toPrice),
+		CreatedAt:     time.Now().UTC(),
+	}
+	switch quote.FeeAsset {
+	case quote.FromAsset:
+		entry.FeeValue = quoteValue(quote.FromAsset, quote.Fee, fromPrice)
+	case quote.ToAsset:
+		entry.FeeValue = quoteValue(quote.ToAsset, quote.Fee, toPrice)
+	case constants.LedgerQuoteAsset:
+		entry.FeeValue = quote.Fee
+	}
+	if quote.FromAmount > 0 {
+		entry.ExecutedPrice = entry.Proceeds / quote.FromAmount
+	}
+	entry.Slippage = slippage(entry.ExpectedPrice, entry.ExecutedPrice, false)
+	return entry
+}
+
+// NewMarketOrderEntry records a filled market order against the quote asset.
+// Prices are the value of one unit of the base asset.
+func (f *LedgerEntryFactory) NewMarketOrderEntry(
+	userID uuid.UUID,
+	fromHoldingID uuid.UUID,
+	toHoldingID uuid.UUID,
+	orderID uuid.UUID,
+	order *ExchangeOrder,
+	expectedPrice float64,
+) *LedgerEntry {
+	baseAsset := strings.TrimSuffix(order.Symbol, constants.LedgerQuoteAsset)
+	quoteAmount := order.ExecutedQuantity * order.Price
+	entry := &LedgerEntry{
+		ID:            uuid.New(),
+		UserID:        userID,
+		FromHoldingID: fromHoldingID,
+		ToHoldingID:   toHoldingID,
+		OrderID:       orderID,
+		Reference:     order.ID,
+		EntryType:     constants.LedgerEntryTypeMarketOrder,
+		ExpectedPrice: expectedPrice,
+		ExecutedPrice: order.Price,
+		Proceeds:      quoteAmount,
+		CreatedAt:     time.Now().UTC(),
+	}
+	buy := order.Side == constants.ExchangeOrderSideBuy
+	if buy {
+		entry.FromAsset, entry.FromDelta = constants.LedgerQuoteAsset, -quoteAmount
+		entry.ToAsset, entry.ToDelta = baseAsset, order.ExecutedQuantity
+	} else {
+		entry.FromAsset, entry.FromDelta = baseAsset, -order.ExecutedQuantity
+		entry.ToAsset, entry.ToDelta = constants.LedgerQuoteAsset, quoteAmount
+	}
+	entry.Slippage = slippage(expectedPrice, order.Price, buy)
+	return entry
+}
+
+// Helpers
+
+func quoteValue(asset string, amount float64, price float64) float64 {
+	if asset == constants.LedgerQuoteAsset {
+		return amount
+	}
+	return amount * price
+}
+
+// slippage returns how much worse the executed price was than expected, as a
+// fraction of the expected price. Negative values mean a better fill.
+func slippage(expected float64, executed float64, buy bool) float64 {
+	if expected <= 0 || executed <= 0 {
+		return 0
+	}
+	if buy {
+		return (executed - expected) / expected
+	}
+	return (expected - executed) / expected
+}
@@ -0,0 +1,86 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginThrottle counts the failed logins against an account, keyed by the
+// email tried, or an IP address. It is stored so every API instance sees
+// the same count.
+type LoginThrottle struct {
+	ID             uuid.UUID  `json:"id"`
+	Scope          string     `json:"scope"`
+	Key            string     `json:"key"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LastFailedAt   time.Time  `json:"last_failed_at"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Receivers
+
+func (t *LoginThrottle) IsLocked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+// RequiresCaptcha tells whether enough logins failed to ask for a CAPTCHA.
+// A zero threshold never does.
+func (t *LoginThrottle) RequiresCaptcha(threshold int, now time.Time, window time.Duration) bool {
+	if threshold <= 0 || t.isStale(now, window) {
+		return false
+	}
+	return t.FailedAttempts >= threshold
+}
+
+// Lock locks the throttle once its failures reach the threshold, for the
+// base lockout doubled once per failure over the threshold, up to the
+// maximum. It reports whether it locked.
+func (t *LoginThrottle) Lock(
+	now time.Time,
+	threshold int,
+	base time.Duration,
+	max time.Duration,
+) bool {
+	if threshold <= 0 || t.FailedAttempts < threshold {
+		return false
+	}
+	lockout := base
+	for i := threshold; i < t.FailedAttempts && lockout < max; i++ {
+		lockout *= 2
+	}
+	if lockout > max {
+		lockout = max
+	}
+	lockedUntil := now.Add(lockout)
+	t.LockedUntil = &lockedUntil
+	t.UpdatedAt = now
+	return true
+}
+
+// isStale tells whether the window passed since the last failure, or since
+// the lockout it caused ended.
+func (t *LoginThrottle) isStale(now time.Time, window time.Duration) bool {
+	last := t.LastFailedAt
+	if t.LockedUntil != nil && t.LockedUntil.After(last) {
+		last = *t.LockedUntil
+	}
+	return now.Sub(last) > window
+}
+
+// Factories
+
+type LoginThrottleFactory struct{}
+
+func (f *LoginThrottleFactory) NewLoginThrottle(scope string, key string) *LoginThrottle {
+	now := time.Now().UTC()
+	return &LoginThrottle{
+		ID:        uuid.New(),
+		Scope:     scope,
+		Key:       key,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
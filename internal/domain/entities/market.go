@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/events"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 )
 
 type Market struct {
@@ -64,6 +66,22 @@ type MarketData struct {
 
 type MarketDatas []MarketData
 
+// MarketRegime is a snapshot of the stress of the whole market. Breadth is the
+// percentage of enabled markets closing below their SMA50, ATR spike the
+// average ratio of the latest ATR to its mean over the lookback window, and
+// BTC drawdown the percentage the benchmark closes under its high in it.
+type MarketRegime struct {
+	ID              uuid.UUID `json:"id"`
+	Regime          string    `json:"regime"`
+	Breadth         float64   `json:"breadth"`
+	ATRSpike        float64   `json:"atr_spike"`
+	BTCDrawdown     float64   `json:"btc_drawdown"`
+	MarketsMeasured int       `json:"markets_measured"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type MarketRegimes []MarketRegime
+
 // Validations
 
 func (m *MarketData) Validate() error {
@@ -91,6 +109,22 @@ func (m *MarketData) Validate() error {
 	return nil
 }
 
+func (r *MarketRegime) Validate() error {
+	if !lib.SliceContains(constants.MarketRegimes, r.Regime) {
+		return errors.ErrInvalidMarketRegime
+	}
+	if r.Breadth < 0 || r.Breadth > 100 || r.ATRSpike < 0 || r.BTCDrawdown < 0 || r.MarketsMeasured < 0 {
+		return errors.ErrInvalidRegimeMeasure
+	}
+	return nil
+}
+
+// Receivers
+
+func (r *MarketRegime) IsRiskOff() bool {
+	return r.Regime == constants.MarketRegimeRiskOff
+}
+
 func (m *MarketData) FromEvent(event *events.MarketDataEvent) {
 	factory := MarketDataFactory{}
 	marketData := factory.NewRawMarketData(
@@ -106,6 +140,61 @@ func (m *MarketData) FromEvent(event *events.MarketDataEvent) {
 	*m = *marketData
 }
 
+// Field returns the value of the field alert rules refer to by name, and
+// whether the datapoint has it. Indicators are missing until there is enough
+// history to calculate them.
+func (m *MarketData) Field(name string) (float64, bool) {
+	var value *float64
+	switch name {
+	case constants.AlertFieldOpen:
+		return m.Open, true
+	case constants.AlertFieldHigh:
+		return m.High, true
+	case constants.AlertFieldLow:
+		return m.Low, true
+	case constants.AlertFieldClose:
+		return m.Close, true
+	case constants.AlertFieldVolume:
+		return m.Volume, true
+	case constants.AlertFieldMACD:
+		value = m.MACD
+	case constants.AlertFieldMACDSignal:
+		value = m.MACDSignal
+	case constants.AlertFieldMACDHist:
+		value = m.MACDHist
+	case constants.AlertFieldRSI6:
+		value = m.RSI6
+	case constants.AlertFieldRSI12:
+		value = m.RSI12
+	case constants.AlertFieldRSI24:
+		value = m.RSI24
+	case constants.AlertFieldSMA20:
+		value = m.SMA20
+	case constants.AlertFieldSMA50:
+		value = m.SMA50
+	case constants.AlertFieldSMA200:
+		value = m.SMA200
+	case constants.AlertFieldATR:
+		value = m.ATR
+	case constants.AlertFieldBollingerBandsWidth:
+		value = m.BollingerBandsWidth
+	case constants.AlertFieldBollingerBandsUpper:
+		value = m.BollingerBandsUpper
+	case constants.AlertFieldBollingerBandsLower:
+		value = m.BollingerBandsLower
+	case constants.AlertFieldOBV:
+		value = m.OBV
+	case constants.AlertFieldADX:
+		value = m.ADX
+	case constants.AlertFieldScore:
+		value = m.Score
+	}
+	if value == nil {
+		return 0, false
+	}
+	return *value, true
+}
+
 // Factories
 
 type MarketDataFactory struct{}
@@ -346,6 +435,26 @@ func (f *MarketDataFactory) Clone(
 	}
 }
 
+type MarketRegimeFactory struct{}
+
+func (f *MarketRegimeFactory) NewMarketRegime(
+	regime string,
+	breadth float64,
+	atrSpike float64,
+	btcDrawdown float64,
+	marketsMeasured int,
+) *MarketRegime {
+	return &MarketRegime{
+		ID:              uuid.New(),
+		Regime:          regime,
+		Breadth:         breadth,
+		ATRSpike:        atrSpike,
+		BTCDrawdown:     btcDrawdown,
+		MarketsMeasured: marketsMeasured,
+		CreatedAt:       time.Now().UTC(),
+	}
+}
+
 func (f *MarketDataFactory) NewMarketDataFromEvent(
 	correlationID uuid.UUID,
 	symbol string,
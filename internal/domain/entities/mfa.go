@@ -0,0 +1,117 @@
+package entities
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// MFAFactor is the TOTP second factor of a user. It is pending from
+// enrolment until a first code confirms the user's app holds the secret.
+// RecoveryCodes keeps the digests of the unused recovery codes.
+type MFAFactor struct {
+	ID                          uuid.UUID  `json:"id"`
+	UserID                      uuid.UUID  `json:"user_id"`
+	Secret                      string     `json:"-"`
+	RecoveryCodes               []string   `json:"-"`
+	RequiredForSensitiveActions bool       `json:"required_for_sensitive_actions"`
+	LastUsedStep                int64      `json:"-"`
+	EnabledAt                   *time.Time `json:"enabled_at"`
+	CreatedAt                   time.Time  `json:"created_at"`
+	UpdatedAt                   time.Time  `json:"updated_at"`
+}
+
+// Receivers
+
+func (f *MFAFactor) IsEnabled() bool {
+	return f.EnabledAt != nil
+}
+
+func (f *MFAFactor) Enable(now time.Time) {
+	f.EnabledAt = &now
+	f.UpdatedAt = now
+}
+
+// VerifyCode checks a TOTP code within skew steps of now. A code is only
+// accepted once, so a step at or before the last one used is rejected.
+func (f *MFAFactor) VerifyCode(code string, now time.Time, skew int64) bool {
+	code = strings.TrimSpace(code)
+	current := lib.TOTPStep(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= f.LastUsedStep {
+			continue
+		}
+		expected, err := lib.TOTPCode(f.Secret, step)
+		if err != nil {
+			return false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			f.LastUsedStep = step
+			f.UpdatedAt = now.UTC()
+			return true
+		}
+	}
+	return false
+}
+
+// UseRecoveryCode consumes the recovery code if it is one of the unused ones.
+func (f *MFAFactor) UseRecoveryCode(code string) bool {
+	digest := lib.NewHasher().DigestString(normalizeRecoveryCode(code))
+	for i, recoveryCode := range f.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(recoveryCode), []byte(digest)) == 1 {
+			f.RecoveryCodes = append(f.RecoveryCodes[:i], f.RecoveryCodes[i+1:]...)
+			f.UpdatedAt = time.Now().UTC()
+			return true
+		}
+	}
+	return false
+}
+
+// IssueRecoveryCodes replaces the recovery codes with count new ones,
+// returning them in plain text for the only time.
+func (f *MFAFactor) IssueRecoveryCodes(count int) ([]string, error) {
+	hasher := lib.NewHasher()
+	codes := make([]string, count)
+	digests := make([]string, count)
+	for i := range codes {
+		code, err := lib.RandomCode(10, lib.CodeAlphabet)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+		digests[i] = hasher.DigestString(normalizeRecoveryCode(codes[i]))
+	}
+	f.RecoveryCodes = digests
+	f.UpdatedAt = time.Now().UTC()
+	return codes, nil
+}
+
+// Factories
+
+type MFAFactorFactory struct{}
+
+func (f *MFAFactorFactory) NewMFAFactor(userID uuid.UUID) (*MFAFactor, error) {
+	secret, err := lib.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &MFAFactor{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Secret:        secret,
+		RecoveryCodes: []string{},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}, nil
+}
+
+// Helpers
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
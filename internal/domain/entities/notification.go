@@ -0,0 +1,460 @@
+package entities
+
+import (
+	"net/mail"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// TelegramChat links a Telegram chat to the user that paired it. Commands are
+// only taken from paired chats.
+type TelegramChat struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	ChatID    int64     `json:"chat_id"`
+	PairedAt  time.Time `json:"paired_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PairingCode is a one-time code the user sends to the bot to pair a chat.
+// Only its digest is kept.
+type PairingCode struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	CodeDigest string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NotificationChannel is a destination the user wants notifications delivered
+// to. Target is the email address, or the Slack or webhook URL; Telegram
+// channels are delivered to the paired chat instead. Kinds narrows the
+// notifications the channel receives, all of them when empty.
+type NotificationChannel struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Type      string    `json:"type"`
+	Target    string    `json:"target"`
+	Secret    string    `json:"-"`
+	Kinds     []string  `json:"kinds"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NotificationChannels []NotificationChannel
+
+// NotificationDelivery records the outcome of delivering a notification
+// through a channel, after all its attempts.
+type NotificationDelivery struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	ChannelID   uuid.UUID  `json:"channel_id"`
+	ChannelType string     `json:"channel_type"`
+	Kind        string     `json:"kind"`
+	Subject     string     `json:"subject"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	Error       string     `json:"error"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type NotificationDeliveries []NotificationDelivery
+
+// NotificationPreference is how the user wants notifications: in which
+// language, which events at all, and when not to be disturbed. Quiet hours
+// are HH:MM bounds in the user timezone and may wrap past midnight.
+type NotificationPreference struct {
+	ID              uuid.UUID `json:"id"`
+	UserID          uuid.UUID `json:"user_id"`
+	Locale          string    `json:"locale"`
+	Timezone        string    `json:"timezone"`
+	Events          []string  `json:"events"`
+	QuietHoursStart string    `json:"quiet_hours_start"`
+	QuietHoursEnd   string    `json:"quiet_hours_end"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// DigestSchedule is when the user gets the portfolio digest: every day, or
+// every week on Weekday, at Time (HH:MM) in the timezone of the notification
+// preference. The portfolio value of the last digest is kept to report its
+// change.
+type DigestSchedule struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Frequency  string       `json:"frequency"`
+	Time       string       `json:"time"`
+	Weekday    time.Weekday `json:"weekday"`
+	Enabled    bool         `json:"enabled"`
+	LastSentAt *time.Time   `json:"last_sent_at"`
+	LastValue  float64      `json:"last_value"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+type DigestSchedules []DigestSchedule
+
+var clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// Validations
+
+func (c *TelegramChat) Validate() error {
+	if c.UserID == uuid.Nil || c.ChatID == 0 {
+		return errors.ErrInvalidTelegramChat
+	}
+	return nil
+}
+
+func (c *NotificationChannel) Validate() error {
+	if !lib.SliceContains(constants.NotificationChannels, c.Type) {
+		return errors.ErrInvalidNotificationChannel
+	}
+	for _, kind := range c.Kinds {
+		if !lib.SliceContains(constants.NotificationKinds, kind) {
+			return errors.ErrInvalidNotificationKind
+		}
+	}
+	switch c.Type {
+	case constants.NotificationChannelEmail:
+		address, err := mail.ParseAddress(c.Target)
+		if err != nil || address.Address != c.Target {
+			return errors.ErrInvalidNotificationTarget
+		}
+	case constants.NotificationChannelSlack, constants.NotificationChannelWebhook:
+		target, err := url.Parse(c.Target)
+		if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+			return errors.ErrInvalidNotificationTarget
+		}
+	}
+	if c.Type == constants.NotificationChannelWebhook && c.Secret == "" {
+		return errors.ErrNotificationWebhookSecretMissing
+	}
+	return nil
+}
+
+func (p *NotificationPreference) Validate() error {
+	if !lib.SliceContains(constants.NotificationLocales, p.Locale) {
+		return errors.ErrInvalidNotificationLocale
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "" {
+		return errors.ErrInvalidNotificationTimezone
+	}
+	for _, event := range p.Events {
+		if !lib.SliceContains(constants.NotificationKinds, event) {
+			return errors.ErrInvalidNotificationKind
+		}
+	}
+	if p.QuietHoursStart == "" && p.QuietHoursEnd == "" {
+		return nil
+	}
+	if !clockPattern.MatchString(p.QuietHoursStart) ||
+		!clockPattern.MatchString(p.QuietHoursEnd) ||
+		p.QuietHoursStart == p.QuietHoursEnd {
+		return errors.ErrInvalidQuietHours
+	}
+	return nil
+}
+
+func (d *DigestSchedule) Validate() error {
+	if !lib.SliceContains(constants.DigestFrequencies, d.Frequency) {
+		return errors.ErrInvalidDigestFrequency
+	}
+	if !clockPattern.MatchString(d.Time) {
+		return errors.ErrInvalidDigestTime
+	}
+	if d.Weekday < time.Sunday || d.Weekday > time.Saturday {
+		return errors.ErrInvalidDigestWeekday
+	}
+	return nil
+}
+
+// Receivers
+
+// Location returns the timezone of the user, UTC if it can't be loaded.
+func (p *NotificationPreference) Location() *time.Location {
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Receives tells whether the user wants notifications of the kind.
+func (p *NotificationPreference) Receives(kind string) bool {
+	return len(p.Events) == 0 || lib.SliceContains(p.Events, kind)
+}
+
+// InQuietHours tells whether the time falls within the quiet hours of the
+// user, start included and end excluded.
+func (p *NotificationPreference) InQuietHours(now time.Time) bool {
+	if p.QuietHoursStart == "" || p.QuietHoursEnd == "" {
+		return false
+	}
+	clock := now.In(p.Location()).Format("15:04")
+	if p.QuietHoursStart < p.QuietHoursEnd {
+		return clock >= p.QuietHoursStart && clock < p.QuietHoursEnd
+	}
+	return clock >= p.QuietHoursStart || clock < p.QuietHoursEnd
+}
+
+// Kind returns the notification kind of the digest.
+func (d *DigestSchedule) Kind() string {
+	if d.Frequency == constants.DigestFrequencyWeekly {
+		return constants.NotificationKindWeeklyDigest
+	}
+	return constants.NotificationKindDailyDigest
+}
+
+// LastOccurrence returns the latest time the digest was scheduled for, up to
+// now, in the given timezone.
+func (d *DigestSchedule) LastOccurrence(now time.Time, location *time.Location) time.Time {
+	local := now.In(location)
+	clock, err := time.Parse("15:04", d.Time)
+	if err != nil {
+		clock = time.Time{}
+	}
+	occurrence := time.Date(
+		local.Year(), local.Month(), local.Day(),
+		clock.Hour(), clock.Minute(), 0, 0,
+		location,
+	)
+	step := 1
+	if d.Frequency == constants.DigestFrequencyWeekly {
+		step = 7
+		occurrence = occurrence.AddDate(0, 0, -((int(local.Weekday()) - int(d.Weekday) + 7) % 7))
+	}
+	if occurrence.After(local) {
+		occurrence = occurrence.AddDate(0, 0, -step)
+	}
+	return occurrence
+}
+
+// IsDue tells whether an occurrence of the digest passed since the last one
+// was sent, or since the schedule was set for the first digest.
+func (d *DigestSchedule) IsDue(now time.Time, location *time.Location) bool {
+	if !d.Enabled {
+		return false
+	}
+	since := d.CreatedAt
+	if d.LastSentAt != nil {
+		since = *d.LastSentAt
+	}
+	return d.LastOccurrence(now, location).After(since)
+}
+
+// PeriodStart returns where the period the next digest covers begins: where
+// the last one ended, or a whole period back for the first one.
+func (d *DigestSchedule) PeriodStart(now time.Time) time.Time {
+	if d.LastSentAt != nil {
+		return *d.LastSentAt
+	}
+	if d.Frequency == constants.DigestFrequencyWeekly {
+		return now.AddDate(0, 0, -7)
+	}
+	return now.AddDate(0, 0, -1)
+}
+
+// MarkSent records the digest sent at the time, with the portfolio value it
+// reported.
+func (d *DigestSchedule) MarkSent(now time.Time, value float64) {
+	d.LastSentAt = &now
+	d.LastValue = value
+	d.UpdatedAt = now
+}
+
+// Receives tells whether notifications of the kind go through the channel.
+func (c *NotificationChannel) Receives(kind string) bool {
+	return c.Enabled && (len(c.Kinds) == 0 || lib.SliceContains(c.Kinds, kind))
+}
+
+func (d *NotificationDelivery) IsDelivered() bool {
+	return d.Status == constants.NotificationDeliveryStatusDelivered
+}
+
+func (d *NotificationDelivery) IsFailed() bool {
+	return d.Status == constants.NotificationDeliveryStatusFailed
+}
+
+// Retry marks the failed delivery as having attempts left.
+func (d *NotificationDelivery) Retry() {
+	d.Status = constants.NotificationDeliveryStatusRetrying
+}
+
+// RecordAttempt records the outcome of one more attempt, failed when it
+// returned an error.
+func (d *NotificationDelivery) RecordAttempt(err error) {
+	d.Attempts++
+	if err != nil {
+		d.Status = constants.NotificationDeliveryStatusFailed
+		d.Error = err.Error()
+		d.DeliveredAt = nil
+		return
+	}
+	now := time.Now().UTC()
+	d.Status = constants.NotificationDeliveryStatusDelivered
+	d.Error = ""
+	d.DeliveredAt = &now
+}
+
+// IsRedeemable tells whether the code can still pair a chat.
+func (p *PairingCode) IsRedeemable(now time.Time) bool {
+	return p.UsedAt == nil && now.Before(p.ExpiresAt)
+}
+
+func (p *PairingCode) Redeem() {
+	now := time.Now().UTC()
+	p.UsedAt = &now
+}
+
+// Factories
+
+type TelegramChatFactory struct{}
+
+func (f *TelegramChatFactory) NewTelegramChat(
+	userID uuid.UUID,
+	chatID int64,
+) *TelegramChat {
+	now := time.Now().UTC()
+	return &TelegramChat{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ChatID:    chatID,
+		PairedAt:  now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+type PairingCodeFactory struct{}
+
+func (f *PairingCodeFactory) NewPairingCode(
+	userID uuid.UUID,
+	codeDigest string,
+	ttl time.Duration,
+) *PairingCode {
+	now := time.Now().UTC()
+	return &PairingCode{
+		ID:         uuid.New(),
+		UserID:     userID,
+		CodeDigest: codeDigest,
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+	}
+}
+
+type NotificationChannelFactory struct{}
+
+func (f *NotificationChannelFactory) NewNotificationChannel(
+	userID uuid.UUID,
+	channelType string,
+	target string,
+	secret string,
+	kinds []string,
+) *NotificationChannel {
+	now := time.Now().UTC()
+	return &NotificationChannel{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      channelType,
+		Target:    target,
+		Secret:    secret,
+		Kinds:     kinds,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+type NotificationPreferenceFactory struct{}
+
+// NewNotificationPreference returns the preference users have until they set
+// one: every event in English, at any time.
+func (f *NotificationPreferenceFactory) NewNotificationPreference(
+	userID uuid.UUID,
+) *NotificationPreference {
+	now := time.Now().UTC()
+	return &NotificationPreference{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Locale:    constants.NotificationLocaleEnglish,
+		Timezone:  "UTC",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+type DigestScheduleFactory struct{}
+
+func (f *DigestScheduleFactory) NewDigestSchedule(
+	userID uuid.UUID,
+	frequency string,
+	clock string,
+	weekday time.Weekday,
+) *DigestSchedule {
+	now := time.Now().UTC()
+	return &DigestSchedule{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Frequency: frequency,
+		Time:      clock,
+		Weekday:   weekday,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+type NotificationDeliveryFactory struct{}
+
+// NewNotificationDelivery records the outcome of the attempts, failed when
+// the last one returned an error.
+func (f *NotificationDeliveryFactory) NewNotificationDelivery(
+	channel *NotificationChannel,
+	kind string,
+	subject string,
+	attempts int,
+	err error,
+) *NotificationDelivery {
+	now := time.Now().UTC()
+	delivery := &NotificationDelivery{
+		ID:          uuid.New(),
+		UserID:      channel.UserID,
+		ChannelID:   channel.ID,
+		ChannelType: channel.Type,
+		Kind:        kind,
+		Subject:     subject,
+		Status:      constants.NotificationDeliveryStatusDelivered,
+		Attempts:    attempts,
+		DeliveredAt: &now,
+		CreatedAt:   now,
+	}
+	if err != nil {
+		delivery.Status = constants.NotificationDeliveryStatusFailed
+		delivery.Error = err.Error()
+		delivery.DeliveredAt = nil
+	}
+	return delivery
+}
+
+// NewSuppressedNotificationDelivery records a notification held back by the
+// quiet hours of the user.
+func (f *NotificationDeliveryFactory) NewSuppressedNotificationDelivery(
+	channel *NotificationChannel,
+	kind string,
+	subject string,
+) *NotificationDelivery {
+	delivery := f.NewNotificationDelivery(channel, kind, subject, 0, nil)
+	delivery.Status = constants.NotificationDeliveryStatusSuppressed
+	delivery.DeliveredAt = nil
+	return delivery
+}
@@ -0,0 +1,102 @@
+package entities
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// TradeProposal is a rotation out of a holding waiting for the user approval.
+// It keeps the decision taken on it, and the price it was taken at.
+type TradeProposal struct {
+	ID            uuid.UUID  `json:"id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	HoldingID     uuid.UUID  `json:"holding_id"`
+	FromSymbol    string     `json:"from_symbol"`
+	ToSymbol      string     `json:"to_symbol"`
+	ProposedPrice float64    `json:"proposed_price"`
+	WalletType    string     `json:"wallet_type"`
+	Status        string     `json:"status"`
+	DecisionPrice float64    `json:"decision_price"`
+	Reason        string     `json:"reason"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	DecidedAt     *time.Time `json:"decided_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type TradeProposals []TradeProposal
+
+// Validations
+
+func (p *TradeProposal) Validate() error {
+	if !lib.SliceContains(constants.TradeProposalStatuses, p.Status) {
+		return errors.ErrInvalidTradeProposalStatus
+	}
+	if p.FromSymbol == "" || p.ToSymbol == "" || p.FromSymbol == p.ToSymbol {
+		return errors.ErrInvalidTradeProposalSymbol
+	}
+	if p.ProposedPrice <= 0 || p.DecisionPrice < 0 {
+		return errors.ErrInvalidTradeProposalPrice
+	}
+	return nil
+}
+
+// Receivers
+
+func (p *TradeProposal) IsPending() bool {
+	return p.Status == constants.TradeProposalStatusPending
+}
+
+func (p *TradeProposal) IsExpired(now time.Time) bool {
+	return !now.Before(p.ExpiresAt)
+}
+
+// PriceDrift returns how far the price moved away from the proposed one, as
+// an absolute percentage.
+func (p *TradeProposal) PriceDrift(price float64) float64 {
+	return math.Abs(price-p.ProposedPrice) / p.ProposedPrice * 100
+}
+
+// Decide closes the proposal with the given status.
+func (p *TradeProposal) Decide(status string, price float64, reason string) {
+	now := time.Now().UTC()
+	p.Status = status
+	p.DecisionPrice = price
+	p.Reason = reason
+	p.DecidedAt = &now
+	p.UpdatedAt = now
+}
+
+// Factories
+
+type TradeProposalFactory struct{}
+
+func (f *TradeProposalFactory) NewTradeProposal(
+	userID uuid.UUID,
+	holdingID uuid.UUID,
+	fromSymbol string,
+	toSymbol string,
+	proposedPrice float64,
+	walletType string,
+	timeout time.Duration,
+) *TradeProposal {
+	now := time.Now().UTC()
+	return &TradeProposal{
+		ID:            uuid.New(),
+		UserID:        userID,
+		HoldingID:     holdingID,
+		FromSymbol:    fromSymbol,
+		ToSymbol:      toSymbol,
+		ProposedPrice: proposedPrice,
+		WalletType:    walletType,
+		Status:        constants.TradeProposalStatusPending,
+		ExpiresAt:     now.Add(timeout),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
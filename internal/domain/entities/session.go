@@ -0,0 +1,84 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of a user, and the family of the refresh tokens issued
+// for it. Refresh tokens are single use: each refresh rotates RefreshTokenID,
+// and an older token of the family coming back means it was stolen, so the
+// whole session is revoked. Access tokens carry the session ID too, so they
+// stop working along with it.
+type Session struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	RefreshTokenID uuid.UUID  `json:"-"`
+	UserAgent      string     `json:"user_agent"`
+	IPAddress      string     `json:"ip_address"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	LastUsedAt     time.Time  `json:"last_used_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	RevokedReason  string     `json:"revoked_reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type Sessions []Session
+
+// Receivers
+
+func (s *Session) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return !s.IsRevoked() && now.Before(s.ExpiresAt)
+}
+
+// Rotate issues the ID of the next refresh token of the family and extends
+// the session by the refresh token lifetime.
+func (s *Session) Rotate(ttl time.Duration) uuid.UUID {
+	now := time.Now().UTC()
+	s.RefreshTokenID = uuid.New()
+	s.LastUsedAt = now
+	s.ExpiresAt = now.Add(ttl)
+	s.UpdatedAt = now
+	return s.RefreshTokenID
+}
+
+// Revoke ends the session, keeping the first reason it was revoked for.
+func (s *Session) Revoke(reason string) {
+	if s.IsRevoked() {
+		return
+	}
+	now := time.Now().UTC()
+	s.RevokedAt = &now
+	s.RevokedReason = reason
+	s.UpdatedAt = now
+}
+
+// Factories
+
+type SessionFactory struct{}
+
+func (f *SessionFactory) NewSession(
+	userID uuid.UUID,
+	userAgent string,
+	ipAddress string,
+	ttl time.Duration,
+) *Session {
+	now := time.Now().UTC()
+	return &Session{
+		ID:             uuid.New(),
+		UserID:         userID,
+		RefreshTokenID: uuid.New(),
+		UserAgent:      userAgent,
+		IPAddress:      ipAddress,
+		ExpiresAt:      now.Add(ttl),
+		LastUsedAt:     now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
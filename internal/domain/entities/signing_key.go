@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SigningKey is an Ed25519 key pair tokens are signed with, identified in
+// their header by its ID. Only the newest key signs; rotated ones are
+// retired but kept for verification until every token they signed has
+// expired.
+type SigningKey struct {
+	ID         string             `json:"kid"`
+	Algorithm  string             `json:"alg"`
+	PublicKey  ed25519.PublicKey  `json:"-"`
+	PrivateKey ed25519.PrivateKey `json:"-"`
+	CreatedAt  time.Time          `json:"created_at"`
+	RetiredAt  *time.Time         `json:"retired_at"`
+}
+
+type SigningKeys []SigningKey
+
+// Receivers
+
+func (k *SigningKey) IsRetired() bool {
+	return k.RetiredAt != nil
+}
+
+// IsDue tells whether the key has signed for the whole rotation period.
+func (k *SigningKey) IsDue(period time.Duration, now time.Time) bool {
+	return !now.Before(k.CreatedAt.Add(period))
+}
+
+// IsExpired tells whether every token signed by the retired key has expired,
+// given the longest lifetime of a token.
+func (k *SigningKey) IsExpired(tokenTTL time.Duration, now time.Time) bool {
+	return k.IsRetired() && now.After(k.RetiredAt.Add(tokenTTL))
+}
+
+func (k *SigningKey) Retire(now time.Time) {
+	if k.IsRetired() {
+		return
+	}
+	k.RetiredAt = &now
+}
+
+// Factories
+
+type SigningKeyFactory struct{}
+
+func (f *SigningKeyFactory) NewSigningKey(algorithm string) (*SigningKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  algorithm,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,156 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
+)
+
+// TaxLot is a quantity of an asset acquired in a single ledger entry, valued
+// at what it cost in the ledger quote asset.
+type TaxLot struct {
+	ID                uuid.UUID `json:"id"`
+	UserID            uuid.UUID `json:"user_id"`
+	LedgerEntryID     uuid.UUID `json:"ledger_entry_id"`
+	Asset             string    `json:"asset"`
+	Quantity          float64   `json:"quantity"`
+	RemainingQuantity float64   `json:"remaining_quantity"`
+	UnitCost          float64   `json:"unit_cost"`
+	AcquiredAt        time.Time `json:"acquired_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type TaxLots []TaxLot
+
+// TaxLotDisposal is the part of a disposal matched against one tax lot.
+// Quantities disposed of without any lot left to match have no LotID.
+type TaxLotDisposal struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	LotID         uuid.UUID `json:"lot_id"`
+	LedgerEntryID uuid.UUID `json:"ledger_entry_id"`
+	Reference     string    `json:"reference"`
+	Asset         string    `json:"asset"`
+	Method        string    `json:"method"`
+	Quantity      float64   `json:"quantity"`
+	Proceeds      float64   `json:"proceeds"`
+	CostBasis     float64   `json:"cost_basis"`
+	Gain          float64   `json:"gain"`
+	AcquiredAt    time.Time `json:"acquired_at"`
+	DisposedAt    time.Time `json:"disposed_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type TaxLotDisposals []TaxLotDisposal
+
+// Validations
+
+func (l *TaxLot) Validate() error {
+	if l.Asset == "" || l.Asset == constants.LedgerQuoteAsset {
+		return errors.ErrInvalidTaxLotAsset
+	}
+	if l.Quantity <= 0 || l.RemainingQuantity < 0 || l.RemainingQuantity > l.Quantity {
+		return errors.ErrInvalidTaxLotQuantity
+	}
+	if l.UnitCost < 0 {
+		return errors.ErrInvalidTaxLotUnitCost
+	}
+	return nil
+}
+
+func (d *TaxLotDisposal) Validate() error {
+	if !lib.SliceContains(constants.CostBasisMethods, d.Method) {
+		return errors.ErrInvalidCostBasisMethod
+	}
+	if d.Asset == "" || d.Quantity <= 0 || d.Proceeds < 0 || d.CostBasis < 0 {
+		return errors.ErrInvalidTaxDisposal
+	}
+	return nil
+}
+
+// Receivers
+
+// Consume takes up to quantity out of the lot and returns what was taken.
+func (l *TaxLot) Consume(quantity float64) float64 {
+	consumed := quantity
+	if consumed > l.RemainingQuantity {
+		consumed = l.RemainingQuantity
+	}
+	l.RemainingQuantity -= consumed
+	l.UpdatedAt = time.Now().UTC()
+	return consumed
+}
+
+// Term tells whether the lot was held for more than a year when disposed of.
+func (d *TaxLotDisposal) Term() string {
+	if d.AcquiredAt.IsZero() {
+		return constants.TaxTermUnknown
+	}
+	if d.DisposedAt.After(d.AcquiredAt.AddDate(1, 0, 0)) {
+		return constants.TaxTermLong
+	}
+	return constants.TaxTermShort
+}
+
+// Factories
+
+type TaxLotFactory struct{}
+
+func (f *TaxLotFactory) NewTaxLot(
+	userID uuid.UUID,
+	ledgerEntryID uuid.UUID,
+	asset string,
+	quantity float64,
+	unitCost float64,
+	acquiredAt time.Time,
+) *TaxLot {
+	return &TaxLot{
+		ID:                uuid.New(),
+		UserID:            userID,
+		LedgerEntryID:     ledgerEntryID,
+		Asset:             asset,
+		Quantity:          quantity,
+		RemainingQuantity: quantity,
+		UnitCost:          unitCost,
+		AcquiredAt:        acquiredAt,
+		CreatedAt:         time.Now().UTC(),
+		UpdatedAt:         time.Now().UTC(),
+	}
+}
+
+type TaxLotDisposalFactory struct{}
+
+// NewTaxLotDisposal matches quantity of the entry From leg against the lot.
+// A nil lot books the quantity at the cost basis the ledger settled.
+func (f *TaxLotDisposalFactory) NewTaxLotDisposal(
+	entry *LedgerEntry,
+	lot *TaxLot,
+	quantity float64,
+	method string,
+) *TaxLotDisposal {
+	disposed := -entry.FromDelta
+	disposal := &TaxLotDisposal{
+		ID:            uuid.New(),
+		UserID:        entry.UserID,
+		LedgerEntryID: entry.ID,
+		Reference:     entry.Reference,
+		Asset:         entry.FromAsset,
+		Method:        method,
+		Quantity:      quantity,
+		Proceeds:      (entry.Proceeds - entry.ExternalFeeValue()) * quantity / disposed,
+		CostBasis:     entry.CostBasis * quantity / disposed,
+		DisposedAt:    entry.CreatedAt,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if lot != nil {
+		disposal.LotID = lot.ID
+		disposal.CostBasis = lot.UnitCost * quantity
+		disposal.AcquiredAt = lot.AcquiredAt
+	}
+	disposal.Gain = disposal.Proceeds - disposal.CostBasis
+	return disposal
+}
@@ -1,12 +1,14 @@
 package entities
 
 import (
+	"math"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/sergiovirahonda/endurance-api/internal/lib"
 )
 
@@ -19,8 +21,35 @@ type TradingPreference struct {
 	StopLossEnabled     bool      `json:"stop_loss_enabled"`
 	StopLossExitEnabled bool      `json:"stop_loss_exit"`
 	RiskLevel           string    `json:"risk_level"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	CostBasisMethod     string    `json:"cost_basis_method"`
+	ExecutionMode       string    `json:"execution_mode"`
+	// Protective exits, disabled when zero or empty
+	StopLossPercentage      float64                       `json:"stop_loss_percentage"`
+	TrailingStopATRMultiple float64                       `json:"trailing_stop_atr_multiple"`
+	TakeProfitLadder        valueobjects.TakeProfitLadder `json:"take_profit_ladder"`
+	// Position sizing, percentages are of the equity
+	PositionSizingMethod   string  `json:"position_sizing_method"`
+	PositionFraction       float64 `json:"position_fraction"`
+	RiskPerTradePercentage float64 `json:"risk_per_trade_percentage"`
+	MaxConcurrentHoldings  int     `json:"max_concurrent_holdings"`
+	// Risk limits, percentages are of the equity and zero disables a limit
+	MaxDailyLossPercentage      float64 `json:"max_daily_loss_percentage"`
+	MaxDrawdownPercentage       float64 `json:"max_drawdown_percentage"`
+	MaxTradesPerDay             int     `json:"max_trades_per_day"`
+	MaxSymbolExposurePercentage float64 `json:"max_symbol_exposure_percentage"`
+	// What to do while the market regime is risk-off
+	RiskOffAction string `json:"risk_off_action"`
+	// Rotations wait for the user approval when required, and are dropped
+	// once the timeout passes or the price drifts further than allowed
+	RequireApproval        bool    `json:"require_approval"`
+	ApprovalTimeoutMinutes int     `json:"approval_timeout_minutes"`
+	MaxApprovalPriceDrift  float64 `json:"max_approval_price_drift"`
+	// Kill switch state, trading stays halted until explicitly resumed
+	PeakEquity            float64    `json:"peak_equity"`
+	KillSwitchReason      string     `json:"kill_switch_reason"`
+	KillSwitchTriggeredAt *time.Time `json:"kill_switch_triggered_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
 }
 
 type TradingPreferences []TradingPreference
@@ -35,8 +64,13 @@ type Holding struct {
 	Profit     float64   `json:"profit"`
 	EntryScore float64   `json:"entry_score"`
 	Status     string    `json:"status"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	Book       string    `json:"book"`
+	// Protective exits state
+	InitialQuantity     float64   `json:"initial_quantity"`
+	HighWaterMark       float64   `json:"high_water_mark"`
+	TakeProfitLevelsHit int       `json:"take_profit_levels_hit"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
 
 type Holdings []Holding
@@ -49,6 +83,7 @@ type Order struct {
 	Price     float64   `json:"price"`
 	Status    string    `json:"status"`
 	TradeType string    `json:"trade_type"`
+	Book      string    `json:"book"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -64,6 +99,57 @@ func (tp *TradingPreference) Validate() error {
 	if !lib.SliceContains(constants.TradingPreferenceRiskLevels, tp.RiskLevel) {
 		return errors.ErrInvalidRiskLevel
 	}
+	if !lib.SliceContains(constants.CostBasisMethods, tp.CostBasisMethod) {
+		return errors.ErrInvalidCostBasisMethod
+	}
+	if !lib.SliceContains(constants.ExecutionModes, tp.ExecutionMode) {
+		return errors.ErrInvalidExecutionMode
+	}
+	if tp.StopLossPercentage < 0 || tp.StopLossPercentage >= 100 {
+		return errors.ErrInvalidStopLossPercentage
+	}
+	if tp.TrailingStopATRMultiple < 0 {
+		return errors.ErrInvalidTrailingStopMultiple
+	}
+	if err := tp.TakeProfitLadder.Validate(); err != nil {
+		return err
+	}
+	if !lib.SliceContains(constants.PositionSizingMethods, tp.PositionSizingMethod) {
+		return errors.ErrInvalidPositionSizingMethod
+	}
+	if tp.PositionFraction <= 0 || tp.PositionFraction > 100 {
+		return errors.ErrInvalidPositionFraction
+	}
+	if tp.RiskPerTradePercentage <= 0 || tp.RiskPerTradePercentage > 100 {
+		return errors.ErrInvalidRiskPerTrade
+	}
+	if tp.MaxConcurrentHoldings < 1 {
+		return errors.ErrInvalidMaxHoldings
+	}
+	for _, percentage := range []float64{
+		tp.MaxDailyLossPercentage,
+		tp.MaxDrawdownPercentage,
+		tp.MaxSymbolExposurePercentage,
+	} {
+		if percentage < 0 || percentage > 100 {
+			return errors.ErrInvalidRiskLimit
+		}
+	}
+	if tp.MaxTradesPerDay < 0 || tp.PeakEquity < 0 {
+		return errors.ErrInvalidRiskLimit
+	}
+	if !lib.SliceContains(constants.RiskOffActions, tp.RiskOffAction) {
+		return errors.ErrInvalidRiskOffAction
+	}
+	if tp.ApprovalTimeoutMinutes < 1 {
+		return errors.ErrInvalidApprovalTimeout
+	}
+	if tp.MaxApprovalPriceDrift <= 0 || tp.MaxApprovalPriceDrift > 100 {
+		return errors.ErrInvalidApprovalPriceDrift
+	}
+	if tp.Operate && tp.KillSwitchEngaged() {
+		return errors.ErrKillSwitchEngaged
+	}
 	for _, symbol := range tp.Watchlist {
 		if !strings.HasSuffix(symbol, "USDT") {
 			return errors.ErrInvalidWatchlistElement
@@ -72,6 +158,73 @@ func (tp *TradingPreference) Validate() error {
 	return nil
 }
 
+// PositionSize returns how much of the equity, in the ledger quote asset, a new
+// position opened at price should take. Volatility parity loses the risk per
+// trade on a move of one ATR, risk per trade loses it when the nearest stop is
+// hit. Every method is capped by the position fraction.
+func (tp *TradingPreference) PositionSize(
+	equity float64,
+	price float64,
+	atr *float64,
+) (float64, error) {
+	if equity <= 0 || price <= 0 {
+		return 0, errors.ErrPositionSizeNotAvailable
+	}
+	maxSize := equity * tp.PositionFraction / 100
+	risk := equity * tp.RiskPerTradePercentage / 100
+	size := maxSize
+	switch tp.PositionSizingMethod {
+	case constants.PositionSizingMethodVolatilityParity:
+		if atr == nil || *atr <= 0 {
+			return 0, errors.ErrPositionSizeNotAvailable
+		}
+		size = risk * price / *atr
+	case constants.PositionSizingMethodRiskPerTrade:
+		stopDistance := tp.StopLossPercentage / 100
+		if tp.TrailingStopATRMultiple > 0 && atr != nil && *atr > 0 {
+			trailingDistance := tp.TrailingStopATRMultiple * *atr / price
+			if stopDistance == 0 || trailingDistance < stopDistance {
+				stopDistance = trailingDistance
+			}
+		}
+		if stopDistance <= 0 {
+			return 0, errors.ErrPositionSizeNotAvailable
+		}
+		size = risk / stopDistance
+	}
+	return math.Min(size, maxSize), nil
+}
+
+func (tp *TradingPreference) RiskLimits() valueobjects.RiskLimits {
+	return valueobjects.RiskLimits{
+		MaxDailyLossPercentage:      tp.MaxDailyLossPercentage,
+		MaxDrawdownPercentage:       tp.MaxDrawdownPercentage,
+		MaxTradesPerDay:             tp.MaxTradesPerDay,
+		MaxSymbolExposurePercentage: tp.MaxSymbolExposurePercentage,
+	}
+}
+
+func (tp *TradingPreference) KillSwitchEngaged() bool {
+	return tp.KillSwitchReason != ""
+}
+
+// TripKillSwitch halts trading because of the breach.
+func (tp *TradingPreference) TripKillSwitch(breach valueobjects.RiskBreach) {
+	now := time.Now().UTC()
+	tp.Operate = false
+	tp.KillSwitchReason = breach.Limit
+	tp.KillSwitchTriggeredAt = &now
+}
+
+// ResumeTrading releases the kill switch and starts tracking the drawdown
+// again from the equity the user resumes with.
+func (tp *TradingPreference) ResumeTrading() {
+	tp.Operate = true
+	tp.KillSwitchReason = ""
+	tp.KillSwitchTriggeredAt = nil
+	tp.PeakEquity = 0
+}
+
 func (h *Holding) Validate() error {
 	if !lib.SliceContains(constants.HoldingStatuses, h.Status) {
 		return errors.ErrInvalidHoldingStatus
@@ -79,6 +232,9 @@ func (h *Holding) Validate() error {
 	if !strings.HasSuffix(h.Symbol, "USDT") {
 		return errors.ErrInvalidHoldingSymbol
 	}
+	if !lib.SliceContains(constants.ExecutionModes, h.Book) {
+		return errors.ErrInvalidHoldingBook
+	}
 	if h.Quantity < 0 {
 		return errors.ErrInvalidHoldingQuantity
 	}
@@ -91,13 +247,39 @@ func (h *Holding) Validate() error {
 	if h.EntryScore < 0 {
 		return errors.ErrInvalidHoldingEntryScore
 	}
+	if h.InitialQuantity < 0 || h.HighWaterMark < 0 || h.TakeProfitLevelsHit < 0 {
+		return errors.ErrInvalidHoldingQuantity
+	}
 	return nil
 }
 
+// IsSimulated tells whether the holding lives in a paper or shadow book.
+func (h *Holding) IsSimulated() bool {
+	return h.Book != constants.ExecutionModeLive
+}
+
 func (h *Holding) GetAsset() string {
 	return strings.Split(h.Symbol, "USDT")[0]
 }
 
+// GetInitialQuantity returns the quantity the holding was opened with, which
+// take profit fractions refer to. Older holdings fall back to the current one.
+func (h *Holding) GetInitialQuantity() float64 {
+	if h.InitialQuantity > 0 {
+		return h.InitialQuantity
+	}
+	return h.Quantity
+}
+
+// RecordPrice raises the high-water mark and tells whether it moved.
+func (h *Holding) RecordPrice(price float64) bool {
+	if price <= h.HighWaterMark {
+		return false
+	}
+	h.HighWaterMark = price
+	return true
+}
+
 func (o *Order) Validate() error {
 	if !lib.SliceContains(constants.OrderStatuses, o.Status) {
 		return errors.ErrInvalidOrderStatus
@@ -114,6 +296,9 @@ func (o *Order) Validate() error {
 	if !strings.HasSuffix(o.Symbol, "USDT") {
 		return errors.ErrInvalidOrderSymbol
 	}
+	if !lib.SliceContains(constants.ExecutionModes, o.Book) {
+		return errors.ErrInvalidOrderBook
+	}
 	return nil
 }
 
@@ -132,17 +317,20 @@ func (f *HoldingFactory) NewHolding(
 	status string,
 ) *Holding {
 	return &Holding{
-		ID:         uuid.New(),
-		UserID:     userID,
-		Symbol:     symbol,
-		Quantity:   quantity,
-		EntryPrice: entryPrice,
-		ExitPrice:  exitPrice,
-		Profit:     profit,
-		EntryScore: entryScore,
-		Status:     status,
-		CreatedAt:  time.Now().UTC(),
-		UpdatedAt:  time.Now().UTC(),
+		ID:              uuid.New(),
+		UserID:          userID,
+		Symbol:          symbol,
+		Quantity:        quantity,
+		EntryPrice:      entryPrice,
+		ExitPrice:       exitPrice,
+		Profit:          profit,
+		EntryScore:      entryScore,
+		Status:          status,
+		Book:            constants.ExecutionModeLive,
+		InitialQuantity: quantity,
+		HighWaterMark:   entryPrice,
+		CreatedAt:       time.Now().UTC(),
+		UpdatedAt:       time.Now().UTC(),
 	}
 }
 
@@ -157,20 +345,35 @@ func (f *HoldingFactory) Clone(
 	status string,
 ) *Holding {
 	return &Holding{
-		ID:         holding.ID,
-		UserID:     holding.UserID,
-		Symbol:     symbol,
-		Quantity:   quantity,
-		EntryPrice: entryPrice,
-		ExitPrice:  exitPrice,
-		Profit:     profit,
-		EntryScore: entryScore,
-		Status:     status,
-		CreatedAt:  holding.CreatedAt,
-		UpdatedAt:  holding.UpdatedAt,
+		ID:                  holding.ID,
+		UserID:              holding.UserID,
+		Symbol:              symbol,
+		Quantity:            quantity,
+		EntryPrice:          entryPrice,
+		ExitPrice:           exitPrice,
+		Profit:              profit,
+		EntryScore:          entryScore,
+		Status:              status,
+		Book:                holding.Book,
+		InitialQuantity:     holding.InitialQuantity,
+		HighWaterMark:       holding.HighWaterMark,
+		TakeProfitLevelsHit: holding.TakeProfitLevelsHit,
+		CreatedAt:           holding.CreatedAt,
+		UpdatedAt:           holding.UpdatedAt,
 	}
 }
 
+// NewBookCopy copies the holding into another book, so it can be traded
+// there while the original stays as it is.
+func (f *HoldingFactory) NewBookCopy(holding *Holding, book string) *Holding {
+	copied := *holding
+	copied.ID = uuid.New()
+	copied.Book = book
+	copied.CreatedAt = time.Now().UTC()
+	copied.UpdatedAt = copied.CreatedAt
+	return &copied
+}
+
 type TradingPreferenceFactory struct{}
 
 func (f *TradingPreferenceFactory) NewTradingPreference(
@@ -183,16 +386,25 @@ func (f *TradingPreferenceFactory) NewTradingPreference(
 	riskLevel string,
 ) *TradingPreference {
 	return &TradingPreference{
-		ID:                  uuid.New(),
-		UserID:              userID,
-		Algorithm:           algorithm,
-		Watchlist:           watchlist,
-		Operate:             operate,
-		StopLossEnabled:     stopLossEnabled,
-		StopLossExitEnabled: StopLossExitEnabled,
-		RiskLevel:           riskLevel,
-		CreatedAt:           time.Now().UTC(),
-		UpdatedAt:           time.Now().UTC(),
+		ID:                     uuid.New(),
+		UserID:                 userID,
+		Algorithm:              algorithm,
+		Watchlist:              watchlist,
+		Operate:                operate,
+		StopLossEnabled:        stopLossEnabled,
+		StopLossExitEnabled:    StopLossExitEnabled,
+		RiskLevel:              riskLevel,
+		CostBasisMethod:        constants.CostBasisMethodFIFO,
+		ExecutionMode:          constants.ExecutionModeLive,
+		PositionSizingMethod:   constants.PositionSizingMethodFixedFraction,
+		PositionFraction:       100,
+		RiskPerTradePercentage: 1,
+		MaxConcurrentHoldings:  1,
+		RiskOffAction:          constants.RiskOffActionFreezeEntries,
+		ApprovalTimeoutMinutes: 15,
+		MaxApprovalPriceDrift:  1,
+		CreatedAt:              time.Now().UTC(),
+		UpdatedAt:              time.Now().UTC(),
 	}
 }
 
@@ -206,16 +418,36 @@ func (f *TradingPreferenceFactory) Clone(
 	riskLevel string,
 ) *TradingPreference {
 	return &TradingPreference{
-		ID:                  tradingPreference.ID,
-		UserID:              tradingPreference.UserID,
-		Algorithm:           algorithm,
-		Watchlist:           watchlist,
-		Operate:             operate,
-		StopLossEnabled:     stopLossEnabled,
-		StopLossExitEnabled: StopLossExitEnabled,
-		RiskLevel:           riskLevel,
-		CreatedAt:           tradingPreference.CreatedAt,
-		UpdatedAt:           tradingPreference.UpdatedAt,
+		ID:                          tradingPreference.ID,
+		UserID:                      tradingPreference.UserID,
+		Algorithm:                   algorithm,
+		Watchlist:                   watchlist,
+		Operate:                     operate,
+		StopLossEnabled:             stopLossEnabled,
+		StopLossExitEnabled:         StopLossExitEnabled,
+		RiskLevel:                   riskLevel,
+		CostBasisMethod:             tradingPreference.CostBasisMethod,
+		ExecutionMode:               tradingPreference.ExecutionMode,
+		StopLossPercentage:          tradingPreference.StopLossPercentage,
+		TrailingStopATRMultiple:     tradingPreference.TrailingStopATRMultiple,
+		TakeProfitLadder:            tradingPreference.TakeProfitLadder,
+		PositionSizingMethod:        tradingPreference.PositionSizingMethod,
+		PositionFraction:            tradingPreference.PositionFraction,
+		RiskPerTradePercentage:      tradingPreference.RiskPerTradePercentage,
+		MaxConcurrentHoldings:       tradingPreference.MaxConcurrentHoldings,
+		MaxDailyLossPercentage:      tradingPreference.MaxDailyLossPercentage,
+		MaxDrawdownPercentage:       tradingPreference.MaxDrawdownPercentage,
+		MaxTradesPerDay:             tradingPreference.MaxTradesPerDay,
+		MaxSymbolExposurePercentage: tradingPreference.MaxSymbolExposurePercentage,
+		RiskOffAction:               tradingPreference.RiskOffAction,
+		RequireApproval:             tradingPreference.RequireApproval,
+		ApprovalTimeoutMinutes:      tradingPreference.ApprovalTimeoutMinutes,
+		MaxApprovalPriceDrift:       tradingPreference.MaxApprovalPriceDrift,
+		PeakEquity:                  tradingPreference.PeakEquity,
+		KillSwitchReason:            tradingPreference.KillSwitchReason,
+		KillSwitchTriggeredAt:       tradingPreference.KillSwitchTriggeredAt,
+		CreatedAt:                   tradingPreference.CreatedAt,
+		UpdatedAt:                   tradingPreference.UpdatedAt,
 	}
 }
 
@@ -236,6 +468,7 @@ func (f *OrderFactory) NewOrder(
 		Price:     price,
 		Status:    constants.OrderStatusOpen,
 		TradeType: tradeType,
+		Book:      constants.ExecutionModeLive,
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
 	}
@@ -256,6 +489,7 @@ func (f *OrderFactory) Clone(
 		Price:     price,
 		Status:    order.Status,
 		TradeType: tradeType,
+		Book:      order.Book,
 		CreatedAt: order.CreatedAt,
 		UpdatedAt: order.UpdatedAt,
 	}
@@ -7,18 +7,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// User accounts registered on their own stay disabled until their address
+// is verified. Accounts created otherwise, or before verification existed,
+// are enabled with EmailVerifiedAt unset. DisabledAt is set when an account
+// is disabled on purpose, which verifying the address never undoes.
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Role      string    `json:"role"`
-	LastLogin time.Time `json:"last_login"`
-	LoggedIn  bool      `json:"logged_in"`
-	Enabled   bool      `json:"enabled"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	Password        string     `json:"password"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	Role            string     `json:"role"`
+	LastLogin       time.Time  `json:"last_login"`
+	LoggedIn        bool       `json:"logged_in"`
+	Enabled         bool       `json:"enabled"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	DisabledAt      *time.Time `json:"disabled_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 type Users []User
@@ -32,9 +38,38 @@ type JWTClaim struct {
 	FirstName string `json:"first_name,omitempty"`
 	LastName  string `json:"last_name,omitempty"`
 	Role      string `json:"role,omitempty"`
+	// Session the token was issued for
+	SessionID string `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
+// Receivers
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsDisabledOnPurpose() bool {
+	return u.DisabledAt != nil
+}
+
+// Disable records the account as disabled on purpose, keeping the first
+// time it was.
+func (u *User) Disable(now time.Time) {
+	u.Enabled = false
+	if u.DisabledAt == nil {
+		u.DisabledAt = &now
+	}
+	u.UpdatedAt = now
+}
+
+// VerifyEmail marks the address as verified, enabling the account.
+func (u *User) VerifyEmail(now time.Time) {
+	u.EmailVerifiedAt = &now
+	u.Enabled = true
+	u.UpdatedAt = now
+}
+
 // Factories
 
 type UserFactory struct{}
@@ -76,16 +111,18 @@ func (f *UserFactory) Clone(
 	enabled bool,
 ) *User {
 	return &User{
-		ID:        user.ID,
-		Email:     email,
-		Password:  password,
-		FirstName: firstName,
-		LastName:  lastName,
-		Role:      role,
-		LastLogin: lastLogin,
-		LoggedIn:  loggedIn,
-		Enabled:   enabled,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:              user.ID,
+		Email:           email,
+		Password:        password,
+		FirstName:       firstName,
+		LastName:        lastName,
+		Role:            role,
+		LastLogin:       lastLogin,
+		LoggedIn:        loggedIn,
+		Enabled:         enabled,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		DisabledAt:      user.DisabledAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
 	}
 }
@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserToken is a single-use token emailed to a user to prove they own the
+// address, to verify it or to reset the password. Only its digest is kept.
+type UserToken struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Purpose     string     `json:"purpose"`
+	TokenDigest string     `json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type UserTokens []UserToken
+
+// Receivers
+
+func (t *UserToken) IsRedeemable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
+
+func (t *UserToken) Redeem() {
+	now := time.Now().UTC()
+	t.UsedAt = &now
+}
+
+// Factories
+
+type UserTokenFactory struct{}
+
+func (f *UserTokenFactory) NewUserToken(
+	userID uuid.UUID,
+	purpose string,
+	tokenDigest string,
+	ttl time.Duration,
+) *UserToken {
+	now := time.Now().UTC()
+	return &UserToken{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Purpose:     purpose,
+		TokenDigest: tokenDigest,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+}
@@ -3,23 +3,24 @@ package errors
 import "errors"
 
 var (
-	ErrNoBalance                    = errors.New("no balances available")
-	ErrAccountNotAvailable          = errors.New("account not available")
-	ErrInvalidBalance               = errors.New("invalid balance")
-	ErrInvalidPrice                 = errors.New("invalid price")
-	ErrInvalidVolume                = errors.New("invalid volume")
-	ErrInvalidPricePercentageChange = errors.New("invalid price percentage change")
-	ErrTickerNotAvailable           = errors.New("ticker not available")
-	ErrExchangeInfoNotAvailable     = errors.New("exchange info not available")
-	ErrConversionQuoteNotAvailable  = errors.New("conversion quote not available")
-	ErrInvalidRatio                 = errors.New("invalid ratio")
-	ErrInvalidInverseRatio          = errors.New("invalid inverse ratio")
-	ErrInvalidToAmount              = errors.New("invalid to amount")
-	ErrInsufficientBalance          = errors.New("insufficient balance")
-	ErrInvalidSymbol                = errors.New("invalid symbol")
-	ErrQuoteExpired                 = errors.New("quote expired")
-	ErrInvalidQuoteAmount           = errors.New("invalid quote amount")
-	ErrBalanceNotFound              = errors.New("balance not found")
+	ErrNoBalance                     = errors.New("no balances available")
+	ErrAccountNotAvailable           = errors.New("account not available")
+	ErrInvalidBalance                = errors.New("invalid balance")
+	ErrInvalidPrice                  = errors.New("invalid price")
+	ErrInvalidVolume                 = errors.New("invalid volume")
+	ErrInvalidPricePercentageChange  = errors.New("invalid price percentage change")
+	ErrTickerNotAvailable            = errors.New("ticker not available")
+	ErrExchangeInfoNotAvailable      = errors.New("exchange info not available")
+	ErrConversionQuoteNotAvailable   = errors.New("conversion quote not available")
+	ErrInvalidRatio                  = errors.New("invalid ratio")
+	ErrInvalidInverseRatio           = errors.New("invalid inverse ratio")
+	ErrInvalidToAmount               = errors.New("invalid to amount")
+	ErrInsufficientBalance           = errors.New("insufficient balance")
+	ErrInvalidSymbol                 = errors.New("invalid symbol")
+	ErrQuoteExpired                  = errors.New("quote expired")
+	ErrInvalidQuoteAmount            = errors.New("invalid quote amount")
+	ErrBalanceNotFound               = errors.New("balance not found")
+	ErrApiKeyPermissionsNotAvailable = errors.New("api key permissions not available")
 	// Validation errors - Exchange Conversion Quote
 	ErrInvalidFromAmount      = errors.New("invalid from amount")
 	ErrInvalidValidTime       = errors.New("invalid valid time")
@@ -32,6 +33,24 @@ var (
 	ErrInvalidLow         = errors.New("invalid low")
 	ErrInvalidClose       = errors.New("invalid close")
 	ErrInvalidTrades      = errors.New("invalid trades")
+	// Adapters
+	ErrExchangeNotSupported  = errors.New("exchange not supported")
+	ErrInvalidOrderSide      = errors.New("invalid order side")
+	ErrInvalidOrderAmount    = errors.New("invalid order amount")
+	ErrOrderNotPlaced        = errors.New("order not placed")
+	ErrOrderNotFilled        = errors.New("order not filled")
+	ErrOrderFillNotAvailable = errors.New("order fill not available")
+	ErrInvalidKlineInterval  = errors.New("invalid kline interval")
+	ErrExchangeRequestFailed = errors.New("exchange request failed")
+	// Symbol filters
+	ErrSymbolFiltersNotAvailable = errors.New("symbol filters not available")
+	ErrOrderQuantityBelowMinimum = errors.New("order quantity below symbol minimum")
+	ErrOrderQuantityAboveMaximum = errors.New("order quantity above symbol maximum")
+	ErrOrderNotionalBelowMinimum = errors.New("order value below symbol minimum notional")
+	ErrOrderNotionalAboveMaximum = errors.New("order value above symbol maximum notional")
+	// Rate limits
+	ErrRateLimitExceeded = errors.New("exchange rate limit exceeded")
+	ErrRateLimitBanned   = errors.New("exchange rate limit ban in effect")
 	// WebSocket
 	ErrWebSocketConnectionFailed   = errors.New("websocket connection failed")
 	ErrWebSocketSubscriptionFailed = errors.New("websocket subscription failed")
@@ -12,4 +12,11 @@ var (
 	ErrApiKeySecretRequired   = errors.New("secret is required")
 	ErrApiKeyServiceInvalid   = errors.New("service is invalid")
 	ErrApiKeyTelegramNotFound = errors.New("telegram key not found")
+	ErrApiKeyRejected         = errors.New("api key rejected by the exchange")
+	ErrApiKeyTradingDisabled  = errors.New("api key must have spot trading enabled")
+	ErrApiKeyWithdrawEnabled  = errors.New("api key must have withdrawals disabled")
+	ErrEncryptionKeyMissing   = errors.New("encryption master key is missing")
+	ErrEncryptionKeyInvalid   = errors.New("encryption key must be 32 bytes, base64 encoded")
+	ErrEncryptionKeyUnknown   = errors.New("data encrypted with an unknown master key")
+	ErrDecryptionFailed       = errors.New("data could not be decrypted")
 )
@@ -21,4 +21,15 @@ var (
 	ErrInsufficientDataForBollingerBands  = errors.New("insufficient data for Bollinger Bands calculation")
 	ErrInsufficientDataForOBV             = errors.New("insufficient data for OBV calculation")
 	ErrInsufficientDataForADX             = errors.New("insufficient data for ADX calculation")
+	// Validation errors - Market regime
+	ErrInvalidMarketRegime  = errors.New("invalid market regime")
+	ErrInvalidRegimeMeasure = errors.New("invalid market regime measure")
+	ErrMarketRegimeNotFound = errors.New("market regime not found")
+	ErrMarketRegimeRiskOff  = errors.New("market regime is risk-off, new entries are frozen")
+	// Validation errors - Alert rules
+	ErrInvalidAlertSymbol    = errors.New("invalid alert rule symbol")
+	ErrInvalidAlertField     = errors.New("invalid alert rule field")
+	ErrInvalidAlertCondition = errors.New("invalid alert rule condition")
+	ErrInvalidAlertCooldown  = errors.New("invalid alert rule cooldown")
+	ErrAlertRuleNotFound     = errors.New("alert rule not found")
 )
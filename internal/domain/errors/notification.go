@@ -0,0 +1,32 @@
+package errors
+
+import "errors"
+
+var (
+	// Telegram pairing errors
+	ErrInvalidPairingCode    = errors.New("invalid or expired pairing code")
+	ErrInvalidTelegramChat   = errors.New("invalid telegram chat")
+	ErrTelegramChatNotPaired = errors.New("telegram chat not paired, send /pair with a pairing code")
+
+	// Notification channel errors
+	ErrInvalidNotificationChannel       = errors.New("invalid notification channel")
+	ErrInvalidNotificationKind          = errors.New("invalid notification kind")
+	ErrInvalidNotificationTarget        = errors.New("invalid notification target")
+	ErrNotificationWebhookSecretMissing = errors.New("webhook notification channels require a signing secret")
+	ErrNotificationChannelNotFound      = errors.New("notification channel not found")
+	ErrNotifierNotConfigured            = errors.New("no notifier configured for the channel")
+	ErrNotificationNotDelivered         = errors.New("notification not delivered")
+	ErrNotificationRejected             = errors.New("notification rejected by the receiving end")
+
+	// Notification preference errors
+	ErrInvalidNotificationLocale   = errors.New("invalid notification locale")
+	ErrInvalidNotificationTimezone = errors.New("invalid notification timezone")
+	ErrInvalidQuietHours           = errors.New("invalid quiet hours, both bounds must be HH:MM")
+	ErrNotificationTemplateMissing = errors.New("notification template not found")
+
+	// Digest schedule errors
+	ErrInvalidDigestFrequency = errors.New("invalid digest frequency")
+	ErrInvalidDigestTime      = errors.New("invalid digest time, it must be HH:MM")
+	ErrInvalidDigestWeekday   = errors.New("invalid digest weekday, it must be between 0 (Sunday) and 6")
+	ErrDigestScheduleNotFound = errors.New("digest schedule not found")
+)
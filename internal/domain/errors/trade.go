@@ -5,9 +5,37 @@ import "errors"
 var (
 	ErrTradingPreferenceAlreadyExists = errors.New("trading preference already exists")
 	// Validation errors - Trading Preference
-	ErrInvalidAlgorithm        = errors.New("invalid algorithm")
-	ErrInvalidRiskLevel        = errors.New("invalid risk level")
-	ErrInvalidWatchlistElement = errors.New("invalid watchlist element")
+	ErrInvalidAlgorithm            = errors.New("invalid algorithm")
+	ErrInvalidRiskLevel            = errors.New("invalid risk level")
+	ErrInvalidWatchlistElement     = errors.New("invalid watchlist element")
+	ErrInvalidStopLossPercentage   = errors.New("invalid stop loss percentage")
+	ErrInvalidTrailingStopMultiple = errors.New("invalid trailing stop ATR multiple")
+	ErrInvalidTakeProfitLadder     = errors.New("invalid take profit ladder")
+	ErrInvalidPositionSizingMethod = errors.New("invalid position sizing method")
+	ErrInvalidPositionFraction     = errors.New("invalid position fraction")
+	ErrInvalidRiskPerTrade         = errors.New("invalid risk per trade percentage")
+	ErrInvalidMaxHoldings          = errors.New("invalid max concurrent holdings")
+	ErrInvalidRiskLimit            = errors.New("invalid risk limit")
+	ErrInvalidRiskOffAction        = errors.New("invalid risk-off action")
+	ErrInvalidExecutionMode        = errors.New("invalid execution mode")
+	ErrInvalidApprovalTimeout      = errors.New("invalid approval timeout")
+	ErrInvalidApprovalPriceDrift   = errors.New("invalid approval price drift")
+	// Position sizing errors
+	ErrPositionSizeNotAvailable = errors.New("position size not available")
+	// Risk errors
+	ErrRiskLimitBreached = errors.New("risk limit breached")
+	ErrKillSwitchEngaged = errors.New("trading halted by the kill switch, it must be resumed explicitly")
+	// Execution errors
+	ErrConversionIncomplete = errors.New("conversion stopped at the ledger quote asset")
+	// Validation errors - Trade proposal
+	ErrInvalidTradeProposalStatus = errors.New("invalid trade proposal status")
+	ErrInvalidTradeProposalSymbol = errors.New("invalid trade proposal symbol")
+	ErrInvalidTradeProposalPrice  = errors.New("invalid trade proposal price")
+	ErrTradeProposalNotFound      = errors.New("trade proposal not found")
+	ErrTradeProposalDecided       = errors.New("trade proposal already decided")
+	ErrTradeProposalExpired       = errors.New("trade proposal expired")
+	ErrTradeProposalPriceDrift    = errors.New("price drifted too far since the trade was proposed")
+	ErrTradingNotActive           = errors.New("trading preference is not active")
 	// Validation errors - Holding
 	ErrInvalidHoldingStatus     = errors.New("invalid holding status")
 	ErrInvalidHoldingSymbol     = errors.New("invalid holding symbol")
@@ -15,6 +43,7 @@ var (
 	ErrInvalidHoldingEntryPrice = errors.New("invalid holding entry price")
 	ErrInvalidHoldingExitPrice  = errors.New("invalid holding exit price")
 	ErrInvalidHoldingEntryScore = errors.New("invalid holding entry score")
+	ErrInvalidHoldingBook       = errors.New("invalid holding book")
 	ErrHoldingNotFound          = errors.New("holding not found")
 	// Validation errors - Order
 	ErrInvalidOrderStatus   = errors.New("invalid order status")
@@ -22,4 +51,19 @@ var (
 	ErrInvalidOrderPrice    = errors.New("invalid order price")
 	ErrInvalidOrderQuantity = errors.New("invalid order quantity")
 	ErrInvalidOrderSymbol   = errors.New("invalid order symbol")
+	ErrInvalidOrderBook     = errors.New("invalid order book")
+	// Validation errors - Ledger entry
+	ErrInvalidLedgerEntryType   = errors.New("invalid ledger entry type")
+	ErrInvalidLedgerEntryAsset  = errors.New("invalid ledger entry asset")
+	ErrInvalidLedgerEntryDelta  = errors.New("invalid ledger entry delta")
+	ErrInvalidLedgerEntryFee    = errors.New("invalid ledger entry fee")
+	ErrInvalidLedgerEntryPrice  = errors.New("invalid ledger entry price")
+	ErrInvalidLedgerEntryAmount = errors.New("invalid ledger entry amount")
+	// Validation errors - Tax lots
+	ErrInvalidCostBasisMethod = errors.New("invalid cost basis method")
+	ErrInvalidTaxLotAsset     = errors.New("invalid tax lot asset")
+	ErrInvalidTaxLotQuantity  = errors.New("invalid tax lot quantity")
+	ErrInvalidTaxLotUnitCost  = errors.New("invalid tax lot unit cost")
+	ErrInvalidTaxDisposal     = errors.New("invalid tax lot disposal")
+	ErrInvalidTaxYear         = errors.New("invalid tax year")
 )
@@ -8,6 +8,16 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotEnabled     = errors.New("user not enabled")
 	ErrEmailAddressInUse  = errors.New("email address already in use")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrInvalidUserToken   = errors.New("invalid or expired token")
+
+	// Login throttling errors
+	ErrAccountLocked        = errors.New("account temporarily locked after too many failed logins")
+	ErrTooManyLoginAttempts = errors.New("too many failed logins, try again later")
+	ErrCaptchaRequired      = errors.New("captcha required")
+	ErrInvalidCaptcha       = errors.New("invalid captcha")
+	ErrCaptchaSecretMissing = errors.New("a captcha secret is required when the lockout asks for a captcha")
+	ErrTrustedProxyInvalid  = errors.New("trusted proxies must be comma separated CIDR ranges")
 
 	// Authentication errors
 	ErrInvalidToken            = errors.New("invalid token")
@@ -16,4 +26,14 @@ var (
 	ErrTokenExpired            = errors.New("token expired")
 	ErrUserLoggedOut           = errors.New("user logged out")
 	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+	ErrSessionRevoked          = errors.New("session revoked")
+	ErrRefreshTokenReused      = errors.New("refresh token already used, session revoked")
+	ErrSigningKeyInvalid       = errors.New("signing key invalid")
+	ErrUnknownSigningKey       = errors.New("unknown signing key")
+
+	// Two-factor authentication errors
+	ErrMFARequired       = errors.New("second factor required")
+	ErrInvalidMFACode    = errors.New("invalid second factor code")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication not enrolled")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
 )
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/pubsub"
+)
+
+// Event structures
+
+type RiskLimitBreachedEvent struct {
+	BaseEvent
+	UserID    uuid.UUID `json:"user_id"`
+	Limit     string    `json:"limit"`
+	Symbol    string    `json:"symbol"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+}
+
+// Factories
+
+type TradingEventFactory struct{}
+
+func NewTradingEventFactory() *TradingEventFactory {
+	return &TradingEventFactory{}
+}
+
+// Factory receivers
+
+func (f *TradingEventFactory) NewRiskLimitBreachedEvent(
+	userID uuid.UUID,
+	breach valueobjects.RiskBreach,
+) *RiskLimitBreachedEvent {
+	return &RiskLimitBreachedEvent{
+		BaseEvent: BaseEvent{
+			ID:        uuid.New(),
+			Domain:    constants.TradingEventDomain,
+			Type:      constants.RiskLimitBreachedEvent,
+			Timestamp: time.Now().UTC(),
+		},
+		UserID:    userID,
+		Limit:     breach.Limit,
+		Symbol:    breach.Symbol,
+		Value:     breach.Value,
+		Threshold: breach.Threshold,
+	}
+}
+
+// Domain events receivers
+
+func (e RiskLimitBreachedEvent) Dispatch(ps *pubsub.EventsPubSub) error {
+	conf := config.GetConfig()
+	err := ps.Publish(conf.NATS.NatsTradingDomainEventsSubject, e)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,12 +1,28 @@
 package valueobjects
 
-import "time"
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+)
 
 type ExchangeCredentials struct {
 	APIKey    string `json:"api_key"`
 	APISecret string `json:"api_secret"`
 }
 
+// ExchangeApiKeyPermissions are what an API key is allowed to do on the
+// exchange, as reported by the exchange itself.
+type ExchangeApiKeyPermissions struct {
+	CanRead      bool `json:"can_read"`
+	CanTrade     bool `json:"can_trade"`
+	CanWithdraw  bool `json:"can_withdraw"`
+	IPRestricted bool `json:"ip_restricted"`
+}
+
 type ExchangeKline struct {
 	OpenTime time.Time `json:"open_time"`
 	Open     float64   `json:"open"`
@@ -45,14 +61,117 @@ type ExchangeTicker struct {
 }
 
 type ExchangeAvailableSymbol struct {
-	Symbol         string `json:"symbol"`
-	BaseAsset      string `json:"base_asset"`
-	QuoteAsset     string `json:"quote_asset"`
-	Status         string `json:"status"`
-	QuotePrecision int64  `json:"quote_precision"`
+	Symbol             string                `json:"symbol"`
+	BaseAsset          string                `json:"base_asset"`
+	QuoteAsset         string                `json:"quote_asset"`
+	Status             string                `json:"status"`
+	BaseAssetPrecision int64                 `json:"base_asset_precision"`
+	QuotePrecision     int64                 `json:"quote_precision"`
+	Filters            ExchangeSymbolFilters `json:"filters"`
+}
+
+// ExchangeSymbolFilters are the trading rules of a symbol. Zero values mean
+// the exchange does not enforce that rule.
+type ExchangeSymbolFilters struct {
+	Symbol            string  `json:"symbol"`
+	MinQuantity       float64 `json:"min_quantity"`
+	MaxQuantity       float64 `json:"max_quantity"`
+	StepSize          float64 `json:"step_size"`
+	MinNotional       float64 `json:"min_notional"`
+	MaxNotional       float64 `json:"max_notional"`
+	QuantityPrecision int64   `json:"quantity_precision"`
+	PricePrecision    int64   `json:"price_precision"`
 }
 
 type WebSocketSubscription struct {
 	Symbols   []string `json:"symbols"`
 	Intervals []string `json:"intervals"`
 }
+
+type ExchangeRateLimitBudget struct {
+	Bucket    string    `json:"bucket"`
+	Scope     string    `json:"scope"`
+	Used      int64     `json:"used"`
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type ExchangeRateLimitMetrics struct {
+	Budgets     []ExchangeRateLimitBudget `json:"budgets"`
+	BannedUntil time.Time                 `json:"banned_until"`
+	Throttled   int64                     `json:"throttled"`
+	Rejected    int64                     `json:"rejected"`
+}
+
+// RoundQuantity rounds a quantity down to the symbol step size, so what is
+// submitted never exceeds what is available.
+func (f ExchangeSymbolFilters) RoundQuantity(quantity float64) float64 {
+	return roundDown(quantity, f.StepSize, f.QuantityPrecision)
+}
+
+// ValidateQuantity checks a quantity against the lot size and, when a
+// reference price is given, against the notional limits.
+func (f ExchangeSymbolFilters) ValidateQuantity(quantity float64, price float64) error {
+	if quantity <= 0 || quantity < f.MinQuantity {
+		return errors.ErrOrderQuantityBelowMinimum
+	}
+	if f.MaxQuantity > 0 && quantity > f.MaxQuantity {
+		return errors.ErrOrderQuantityAboveMaximum
+	}
+	if price <= 0 {
+		return nil
+	}
+	notional := quantity * price
+	if notional < f.MinNotional {
+		return errors.ErrOrderNotionalBelowMinimum
+	}
+	if f.MaxNotional > 0 && notional > f.MaxNotional {
+		return errors.ErrOrderNotionalAboveMaximum
+	}
+	return nil
+}
+
+func roundDown(value float64, step float64, precision int64) float64 {
+	if step <= 0 {
+		return value
+	}
+	scale := math.Pow10(int(precision))
+	// Float noise such as 0.3/0.1 = 2.9999999999999996 leaves values that are
+	// on a step just below it, so the nearest step is kept unless it exceeds
+	// the value.
+	steps := math.Round(value / step)
+	if math.Round(steps*step*scale)/scale > value {
+		steps = math.Floor(value / step)
+	}
+	return math.Round(steps*step*scale) / scale
+}
+
+// MarketSymbol is the exchange-agnostic form of a symbol. Its canonical
+// string is the base asset followed by the quote asset, e.g. BTCUSDT.
+type MarketSymbol struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+}
+
+func NewMarketSymbol(base string, quote string) MarketSymbol {
+	return MarketSymbol{
+		Base:  strings.ToUpper(base),
+		Quote: strings.ToUpper(quote),
+	}
+}
+
+func ParseMarketSymbol(symbol string) (MarketSymbol, error) {
+	symbol = strings.ToUpper(symbol)
+	for _, quote := range constants.ExchangeQuoteAssets {
+		base, found := strings.CutSuffix(symbol, quote)
+		if found && base != "" {
+			return NewMarketSymbol(base, quote), nil
+		}
+	}
+	return MarketSymbol{}, errors.ErrInvalidSymbol
+}
+
+func (m MarketSymbol) String() string {
+	return m.Base + m.Quote
+}
@@ -0,0 +1,34 @@
+package valueobjects
+
+import "github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+
+// RegimeThresholds classify the market regime out of its stress measures.
+// Breadth and drawdowns are percentages and zero disables a risk-off trigger.
+type RegimeThresholds struct {
+	RiskOffBreadth     float64 `json:"risk_off_breadth"`
+	RiskOnBreadth      float64 `json:"risk_on_breadth"`
+	RiskOffATRSpike    float64 `json:"risk_off_atr_spike"`
+	RiskOffBTCDrawdown float64 `json:"risk_off_btc_drawdown"`
+	RiskOnBTCDrawdown  float64 `json:"risk_on_btc_drawdown"`
+}
+
+// Receivers
+
+// Classify returns risk-off when any measure crosses its risk-off threshold,
+// risk-on when both breadth and the BTC drawdown stay under their risk-on
+// threshold, and neutral otherwise.
+func (t RegimeThresholds) Classify(breadth float64, atrSpike float64, btcDrawdown float64) string {
+	if t.RiskOffBreadth > 0 && breadth >= t.RiskOffBreadth {
+		return constants.MarketRegimeRiskOff
+	}
+	if t.RiskOffATRSpike > 0 && atrSpike >= t.RiskOffATRSpike {
+		return constants.MarketRegimeRiskOff
+	}
+	if t.RiskOffBTCDrawdown > 0 && btcDrawdown >= t.RiskOffBTCDrawdown {
+		return constants.MarketRegimeRiskOff
+	}
+	if breadth <= t.RiskOnBreadth && btcDrawdown <= t.RiskOnBTCDrawdown {
+		return constants.MarketRegimeRiskOn
+	}
+	return constants.MarketRegimeNeutral
+}
@@ -0,0 +1,113 @@
+package valueobjects
+
+import "time"
+
+// NotificationMessage is what gets delivered through a channel, rendered in
+// the format the channel understands. Options are channel specific, like the
+// inline buttons of Telegram messages, and channels that don't understand
+// them ignore them.
+type NotificationMessage struct {
+	Kind    string        `json:"kind"`
+	Format  string        `json:"format"`
+	Subject string        `json:"subject"`
+	Text    string        `json:"text"`
+	Options []interface{} `json:"-"`
+}
+
+// TelegramCredentials are the bot token notifications go out with and the
+// chat they go to when the user hasn't paired one.
+type TelegramCredentials struct {
+	BotToken string `json:"bot_token"`
+	ChatID   int64  `json:"chat_id"`
+}
+
+// NotificationEvent is something to notify the user about. Data is what the
+// template of the kind renders.
+type NotificationEvent struct {
+	Kind    string
+	Data    interface{}
+	Options []interface{}
+}
+
+type TradeExecutedNotification struct {
+	FromSymbol       string
+	ToSymbol         string
+	EntryPrice       float64
+	Profit           float64
+	ProfitPercentage float64
+}
+
+type StopLossNotification struct {
+	Symbol         string
+	StopLossPrice  float64
+	Loss           float64
+	LossPercentage float64
+}
+
+type TakeProfitNotification struct {
+	Symbol           string
+	TargetPrice      float64
+	Quantity         float64
+	Profit           float64
+	ProfitPercentage float64
+}
+
+type PositionOpenedNotification struct {
+	Symbol     string
+	EntryPrice float64
+	Quantity   float64
+	Amount     float64
+}
+
+type TradeProposalNotification struct {
+	FromSymbol    string
+	ToSymbol      string
+	ProposedPrice float64
+	ExpiresAt     time.Time
+}
+
+// AlertNotification tells the value of the field that crossed the threshold
+// of an alert rule, at the close of the candle it was measured on.
+type AlertNotification struct {
+	Symbol    string
+	Field     string
+	Condition string
+	Threshold float64
+	Value     float64
+	Timestamp time.Time
+}
+
+// DigestNotification sums up the portfolio of the user over the period since
+// the previous digest. ValueChange is measured against the portfolio value
+// that digest reported.
+type DigestNotification struct {
+	Frequency             string
+	From                  time.Time
+	To                    time.Time
+	PortfolioValue        float64
+	ValueChange           float64
+	ValueChangePercentage float64
+	Positions             HoldingsPnL
+	UnrealisedPnL         float64
+	Trades                int
+	Fees                  float64
+	RealisedPnL           float64
+	Scores                SymbolScores
+	RiskEvents            []DigestRiskEvent
+}
+
+// DigestRiskEvent is a risk event within the period of a digest: the kill
+// switch tripping on Limit, or the market regime turning risk-off.
+type DigestRiskEvent struct {
+	Kind       string
+	Limit      string
+	OccurredAt time.Time
+}
+
+// Mail is an account email, such as an address verification, sent to a
+// user whether or not they set up notification channels.
+type Mail struct {
+	To      string
+	Subject string
+	Text    string
+}
@@ -1,5 +1,14 @@
 package valueobjects
 
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+)
+
 type SymbolScore struct {
 	Symbol  string
 	Score   float64
@@ -7,3 +16,190 @@ type SymbolScore struct {
 }
 
 type SymbolScores []SymbolScore
+
+// TakeProfitLevel sells SellFraction of the initial position once the price is
+// TargetPercentage above the entry price.
+type TakeProfitLevel struct {
+	TargetPercentage float64 `json:"target_percentage"`
+	SellFraction     float64 `json:"sell_fraction"`
+}
+
+type TakeProfitLadder []TakeProfitLevel
+
+type ProtectiveExitSignal struct {
+	Action       string  `json:"action"`
+	TriggerPrice float64 `json:"trigger_price"`
+	Quantity     float64 `json:"quantity"`
+	Levels       int     `json:"levels"`
+}
+
+// PositionAllocation is the amount of the ledger quote asset a new position
+// in the symbol is opened with.
+type PositionAllocation struct {
+	Symbol string  `json:"symbol"`
+	Score  float64 `json:"score"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+type PositionAllocations []PositionAllocation
+
+// RiskLimits bound the losses and the activity of a user. Percentages are of
+// the equity and zero disables a limit.
+type RiskLimits struct {
+	MaxDailyLossPercentage      float64 `json:"max_daily_loss_percentage"`
+	MaxDrawdownPercentage       float64 `json:"max_drawdown_percentage"`
+	MaxTradesPerDay             int     `json:"max_trades_per_day"`
+	MaxSymbolExposurePercentage float64 `json:"max_symbol_exposure_percentage"`
+}
+
+// RiskSnapshot is the state of a portfolio that risk limits are checked
+// against. Exposures are the value of the position in each symbol.
+type RiskSnapshot struct {
+	Equity           float64            `json:"equity"`
+	PeakEquity       float64            `json:"peak_equity"`
+	DailyRealisedPnL float64            `json:"daily_realised_pnl"`
+	TradesToday      int                `json:"trades_today"`
+	Exposures        map[string]float64 `json:"exposures"`
+}
+
+type RiskBreach struct {
+	Limit     string  `json:"limit"`
+	Symbol    string  `json:"symbol"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+type HoldingPnL struct {
+	HoldingID     uuid.UUID `json:"holding_id"`
+	Symbol        string    `json:"symbol"`
+	Status        string    `json:"status"`
+	Quantity      float64   `json:"quantity"`
+	CostBasis     float64   `json:"cost_basis"`
+	MarketValue   float64   `json:"market_value"`
+	Fees          float64   `json:"fees"`
+	RealisedPnL   float64   `json:"realised_pnl"`
+	UnrealisedPnL float64   `json:"unrealised_pnl"`
+}
+
+type HoldingsPnL []HoldingPnL
+
+type UserPnL struct {
+	UserID        uuid.UUID   `json:"user_id"`
+	Fees          float64     `json:"fees"`
+	RealisedPnL   float64     `json:"realised_pnl"`
+	UnrealisedPnL float64     `json:"unrealised_pnl"`
+	Holdings      HoldingsPnL `json:"holdings"`
+}
+
+// BookSummary sums up what was booked under an execution mode, so live and
+// simulated results can be compared side by side.
+type BookSummary struct {
+	Book            string  `json:"book"`
+	Orders          int     `json:"orders"`
+	OpenPositions   int     `json:"open_positions"`
+	ClosedPositions int     `json:"closed_positions"`
+	RealisedPnL     float64 `json:"realised_pnl"`
+	UnrealisedPnL   float64 `json:"unrealised_pnl"`
+}
+
+type BookSummaries []BookSummary
+
+// Receivers
+
+// Validate checks that targets increase level after level and that the ladder
+// never sells more than the whole position.
+func (l TakeProfitLadder) Validate() error {
+	previousTarget := 0.0
+	totalFraction := 0.0
+	for _, level := range l {
+		if level.TargetPercentage <= previousTarget {
+			return errors.ErrInvalidTakeProfitLadder
+		}
+		if level.SellFraction <= 0 || level.SellFraction > 1 {
+			return errors.ErrInvalidTakeProfitLadder
+		}
+		previousTarget = level.TargetPercentage
+		totalFraction += level.SellFraction
+	}
+	if totalFraction > 1+1e-9 {
+		return errors.ErrInvalidTakeProfitLadder
+	}
+	return nil
+}
+
+// Merge returns the strictest of both limits. A limit disabled on one side
+// is taken from the other.
+func (l RiskLimits) Merge(other RiskLimits) RiskLimits {
+	return RiskLimits{
+		MaxDailyLossPercentage:      strictestLimit(l.MaxDailyLossPercentage, other.MaxDailyLossPercentage),
+		MaxDrawdownPercentage:       strictestLimit(l.MaxDrawdownPercentage, other.MaxDrawdownPercentage),
+		MaxTradesPerDay:             int(strictestLimit(float64(l.MaxTradesPerDay), float64(other.MaxTradesPerDay))),
+		MaxSymbolExposurePercentage: strictestLimit(l.MaxSymbolExposurePercentage, other.MaxSymbolExposurePercentage),
+	}
+}
+
+// Check returns the first limit the snapshot breaches, or nil. The daily loss
+// is the realised loss of the day relative to the equity before it, and the
+// trade count breaches as soon as it reaches the limit.
+func (l RiskLimits) Check(snapshot RiskSnapshot) *RiskBreach {
+	startEquity := snapshot.Equity - snapshot.DailyRealisedPnL
+	if l.MaxDailyLossPercentage > 0 && snapshot.DailyRealisedPnL < 0 && startEquity > 0 {
+		loss := -snapshot.DailyRealisedPnL / startEquity * 100
+		if loss >= l.MaxDailyLossPercentage {
+			return &RiskBreach{
+				Limit:     constants.RiskLimitDailyLoss,
+				Value:     loss,
+				Threshold: l.MaxDailyLossPercentage,
+			}
+		}
+	}
+	if l.MaxDrawdownPercentage > 0 && snapshot.PeakEquity > 0 {
+		drawdown := (snapshot.PeakEquity - snapshot.Equity) / snapshot.PeakEquity * 100
+		if drawdown >= l.MaxDrawdownPercentage {
+			return &RiskBreach{
+				Limit:     constants.RiskLimitDrawdown,
+				Value:     drawdown,
+				Threshold: l.MaxDrawdownPercentage,
+			}
+		}
+	}
+	if l.MaxTradesPerDay > 0 && snapshot.TradesToday >= l.MaxTradesPerDay {
+		return &RiskBreach{
+			Limit:     constants.RiskLimitTradesPerDay,
+			Value:     float64(snapshot.TradesToday),
+			Threshold: float64(l.MaxTradesPerDay),
+		}
+	}
+	if l.MaxSymbolExposurePercentage > 0 && snapshot.Equity > 0 {
+		symbols := make([]string, 0, len(snapshot.Exposures))
+		for symbol := range snapshot.Exposures {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			exposure := snapshot.Exposures[symbol] / snapshot.Equity * 100
+			if exposure > l.MaxSymbolExposurePercentage {
+				return &RiskBreach{
+					Limit:     constants.RiskLimitSymbolExposure,
+					Symbol:    symbol,
+					Value:     exposure,
+					Threshold: l.MaxSymbolExposurePercentage,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Helpers
+
+func strictestLimit(a float64, b float64) float64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	return math.Min(a, b)
+}
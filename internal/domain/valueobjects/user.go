@@ -1,8 +1,11 @@
 package valueobjects
 
+// LoginCredentials carry a CAPTCHA token once the account or IP failed
+// enough logins to be asked for one.
 type LoginCredentials struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email        string `json:"email" validate:"required,email"`
+	Password     string `json:"password" validate:"required"`
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type RegisterCredentials struct {
@@ -12,7 +15,42 @@ type RegisterCredentials struct {
 	LastName  string `json:"last_name" validate:"required"`
 }
 
+// Tokens are either the tokens of a new session or, for users with two-factor
+// authentication, the MFA token to complete the login with.
 type Tokens struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type MFACredentials struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFAEnrollment is what an authenticator app needs to enrol a TOTP secret.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFARecoveryCodes are shown once, when issued; only their digests are kept.
+type MFARecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
+
+// JWK is the public half of a token signing key, as published in the JWKS
+// (RFC 7517, RFC 8037 for Ed25519 keys).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	X         string `json:"x"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
 }
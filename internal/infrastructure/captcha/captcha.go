@@ -0,0 +1,83 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+)
+
+// Verifier checks the token a client got for solving a CAPTCHA.
+type Verifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) error
+}
+
+// SiteVerifyClient verifies tokens against a siteverify endpoint, the API
+// hCaptcha, reCAPTCHA and Turnstile share.
+type SiteVerifyClient struct {
+	VerifyURL  string
+	Secret     string
+	HTTPClient *http.Client
+}
+
+type siteVerifyResponse struct {
+	Success bool `json:"success"`
+}
+
+// Factories
+
+func NewSiteVerifyClient(cfg *config.Config) *SiteVerifyClient {
+	return &SiteVerifyClient{
+		VerifyURL:  cfg.Captcha.VerifyURL,
+		Secret:     cfg.Captcha.Secret,
+		HTTPClient: &http.Client{Timeout: cfg.Captcha.HTTPTimeout},
+	}
+}
+
+// Verifier implementation
+
+// Verify returns errors.ErrInvalidCaptcha when the provider rejects the
+// token, any other error means it couldn't be asked.
+func (c *SiteVerifyClient) Verify(ctx context.Context, token string, remoteIP string) error {
+	if token == "" {
+		return errors.ErrInvalidCaptcha
+	}
+	form := url.Values{}
+	form.Set("secret", c.Secret)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.VerifyURL,
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verification failed: %s", response.Status)
+	}
+	var result siteVerifyResponse
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.ErrInvalidCaptcha
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ func NewSapiClient(
 		credentials.APISecret,
 		cfg.Binance.BaseURL,
 	)
+	client.HTTPClient = GetRateLimitGovernor().HTTPClient()
 	return client
 }
 
@@ -26,5 +27,19 @@ func NewGeneralClient(
 		cfg.Binance.APIKey,
 		cfg.Binance.APISecret,
 	)
+	client.HTTPClient = GetRateLimitGovernor().HTTPClient()
+	return client
+}
+
+func NewUserGeneralClient(
+	cfg *config.Config,
+	credentials *valueobjects.ExchangeCredentials,
+) *binance.Client {
+	client := binance.NewClient(
+		credentials.APIKey,
+		credentials.APISecret,
+	)
+	client.BaseURL = cfg.Binance.BaseURL
+	client.HTTPClient = GetRateLimitGovernor().HTTPClient()
 	return client
 }
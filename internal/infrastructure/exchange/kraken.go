@@ -0,0 +1,359 @@
+package exchange
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
+
+// krakenNonce is shared by every client, since Kraken rejects a nonce that
+// isn't greater than the last one seen for the key and a key may have several
+// clients. Seeding it from the clock keeps it increasing across restarts.
+var krakenNonce = newKrakenNonce()
+
+const krakenPermissionDenied = "EGeneral:Permission denied"
+
+// KrakenClient is a thin client for the Kraken spot REST API.
+type KrakenClient struct {
+	BaseURL    string
+	APIKey     string
+	APISecret  string
+	HTTPClient *http.Client
+	// Nonce returns a strictly increasing value for private requests
+	Nonce func() int64
+}
+
+// Responses
+
+type KrakenTicker struct {
+	Ask    []string `json:"a"`
+	Bid    []string `json:"b"`
+	Last   []string `json:"c"`
+	Volume []string `json:"v"`
+	Open   string   `json:"o"`
+}
+
+type KrakenBalance struct {
+	Balance   string `json:"balance"`
+	HoldTrade string `json:"hold_trade"`
+}
+
+type KrakenAssetPair struct {
+	Altname       string `json:"altname"`
+	WSName        string `json:"wsname"`
+	Base          string `json:"base"`
+	Quote         string `json:"quote"`
+	PairDecimals  int64  `json:"pair_decimals"`
+	LotDecimals   int64  `json:"lot_decimals"`
+	OrderMin      string `json:"ordermin"`
+	CostMin       string `json:"costmin"`
+	TickSize      string `json:"tick_size"`
+	Status        string `json:"status"`
+	CostDecimals  int64  `json:"cost_decimals"`
+	LotMultiplier int64  `json:"lot_multiplier"`
+}
+
+type KrakenOrderDescription struct {
+	Order string `json:"order"`
+}
+
+type KrakenAddOrderResult struct {
+	Description KrakenOrderDescription `json:"descr"`
+	TxIDs       []string               `json:"txid"`
+}
+
+type KrakenOrder struct {
+	Status         string `json:"status"`
+	Volume         string `json:"vol"`
+	VolumeExecuted string `json:"vol_exec"`
+	Cost           string `json:"cost"`
+	Fee            string `json:"fee"`
+}
+
+type KrakenWithdrawMethod struct {
+	Asset   string `json:"asset"`
+	Method  string `json:"method"`
+	Network string `json:"network"`
+}
+
+// KrakenError holds the errors Kraken answered a request with, telling them
+// apart from failures to reach it.
+type KrakenError struct {
+	Errors []string
+}
+
+type krakenResponse struct {
+	Error  []string        `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// Factories
+
+func NewKrakenClient(
+	cfg *config.Config,
+	credentials *valueobjects.ExchangeCredentials,
+) *KrakenClient {
+	return &KrakenClient{
+		BaseURL:    cfg.Kraken.BaseURL,
+		APIKey:     credentials.APIKey,
+		APISecret:  credentials.APISecret,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Nonce:      krakenNonce,
+	}
+}
+
+// Public endpoints
+
+func (c *KrakenClient) GetTicker(
+	ctx context.Context,
+	pair string,
+) (map[string]KrakenTicker, error) {
+	result := map[string]KrakenTicker{}
+	err := c.public(ctx, "Ticker", url.Values{"pair": {pair}}, &result)
+	return result, err
+}
+
+// GetAssetPairs returns the given pairs, or every pair when none is given.
+func (c *KrakenClient) GetAssetPairs(
+	ctx context.Context,
+	pairs ...string,
+) (map[string]KrakenAssetPair, error) {
+	result := map[string]KrakenAssetPair{}
+	params := url.Values{}
+	if len(pairs) > 0 {
+		params.Set("pair", strings.Join(pairs, ","))
+	}
+	err := c.public(ctx, "AssetPairs", params, &result)
+	return result, err
+}
+
+// GetOHLC returns the raw candles of a pair keyed by the pair name Kraken
+// answers with. Each candle is [time, open, high, low, close, vwap, volume, count].
+func (c *KrakenClient) GetOHLC(
+	ctx context.Context,
+	pair string,
+	interval int64,
+	since time.Time,
+) (map[string][][]interface{}, error) {
+	raw := map[string]json.RawMessage{}
+	params := url.Values{
+		"pair":     {pair},
+		"interval": {strconv.FormatInt(interval, 10)},
+	}
+	if !since.IsZero() {
+		params.Set("since", strconv.FormatInt(since.Unix(), 10))
+	}
+	err := c.public(ctx, "OHLC", params, &raw)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string][][]interface{}{}
+	for key, value := range raw {
+		if key == "last" {
+			continue
+		}
+		candles := [][]interface{}{}
+		if err := json.Unmarshal(value, &candles); err != nil {
+			return nil, err
+		}
+		result[key] = candles
+	}
+	return result, nil
+}
+
+// Private endpoints
+
+func (c *KrakenClient) GetExtendedBalance(
+	ctx context.Context,
+) (map[string]KrakenBalance, error) {
+	result := map[string]KrakenBalance{}
+	err := c.private(ctx, "BalanceEx", url.Values{}, &result)
+	return result, err
+}
+
+func (c *KrakenClient) AddMarketOrder(
+	ctx context.Context,
+	pair string,
+	side string,
+	volume string,
+) (*KrakenAddOrderResult, error) {
+	result := KrakenAddOrderResult{}
+	params := url.Values{
+		"ordertype": {"market"},
+		"type":      {side},
+		"volume":    {volume},
+		"pair":      {pair},
+	}
+	err := c.private(ctx, "AddOrder", params, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ValidateMarketOrder has Kraken check a market order without placing it.
+func (c *KrakenClient) ValidateMarketOrder(
+	ctx context.Context,
+	pair string,
+	side string,
+	volume string,
+) error {
+	result := KrakenAddOrderResult{}
+	params := url.Values{
+		"ordertype": {"market"},
+		"type":      {side},
+		"volume":    {volume},
+		"pair":      {pair},
+		"validate":  {"true"},
+	}
+	return c.private(ctx, "AddOrder", params, &result)
+}
+
+// QueryOrders returns the orders with the given transaction IDs, keyed by
+// them.
+func (c *KrakenClient) QueryOrders(
+	ctx context.Context,
+	txIDs ...string,
+) (map[string]KrakenOrder, error) {
+	result := map[string]KrakenOrder{}
+	params := url.Values{
+		"txid": {strings.Join(txIDs, ",")},
+	}
+	err := c.private(ctx, "QueryOrders", params, &result)
+	return result, err
+}
+
+func (c *KrakenClient) GetWithdrawMethods(
+	ctx context.Context,
+) ([]KrakenWithdrawMethod, error) {
+	result := []KrakenWithdrawMethod{}
+	err := c.private(ctx, "WithdrawMethods", url.Values{}, &result)
+	return result, err
+}
+
+// Signature computes the API-Sign header for a private request as
+// base64(HMAC-SHA512(path + SHA256(nonce + body), base64decode(secret))).
+func (c *KrakenClient) Signature(
+	path string,
+	nonce string,
+	body string,
+) (string, error) {
+	secret, err := base64.StdEncoding.DecodeString(c.APISecret)
+	if err != nil {
+		return "", err
+	}
+	sha := sha256.Sum256([]byte(nonce + body))
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(append([]byte(path), sha[:]...))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Errors
+
+func (e *KrakenError) Error() string {
+	return fmt.Sprintf("kraken: %s", strings.Join(e.Errors, ", "))
+}
+
+// IsPermissionDenied tells whether Kraken refused the request because the
+// key lacks the permission it needs.
+func (e *KrakenError) IsPermissionDenied() bool {
+	for _, message := range e.Errors {
+		if message == krakenPermissionDenied {
+			return true
+		}
+	}
+	return false
+}
+
+// Helpers
+
+func (c *KrakenClient) public(
+	ctx context.Context,
+	method string,
+	params url.Values,
+	result interface{},
+) error {
+	endpoint := fmt.Sprintf("%s/0/public/%s", c.BaseURL, method)
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(request, result)
+}
+
+func (c *KrakenClient) private(
+	ctx context.Context,
+	method string,
+	params url.Values,
+	result interface{},
+) error {
+	path := fmt.Sprintf("/0/private/%s", method)
+	nonce := strconv.FormatInt(c.Nonce(), 10)
+	params.Set("nonce", nonce)
+	body := params.Encode()
+	signature, err := c.Signature(path, nonce, body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.BaseURL+path,
+		strings.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("API-Key", c.APIKey)
+	request.Header.Set("API-Sign", signature)
+	return c.do(request, result)
+}
+
+func (c *KrakenClient) do(request *http.Request, result interface{}) error {
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	payload, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("kraken: unexpected status %d: %s", response.StatusCode, payload)
+	}
+	envelope := krakenResponse{}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return err
+	}
+	if len(envelope.Error) > 0 {
+		return &KrakenError{Errors: envelope.Error}
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
+
+func newKrakenNonce() func() int64 {
+	nonce := &atomic.Int64{}
+	nonce.Store(time.Now().UnixNano())
+	return func() int64 {
+		return nonce.Add(1)
+	}
+}
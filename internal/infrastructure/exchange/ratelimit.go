@@ -0,0 +1,432 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	domainErrors "github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
+
+const (
+	// Buckets
+	rateLimitBucketRequestWeight = "request_weight"
+	rateLimitBucketSapiIPWeight  = "sapi_ip_weight"
+	rateLimitBucketSapiUIDWeight = "sapi_uid_weight"
+	rateLimitBucketOrderCount    = "order_count"
+
+	rateLimitScopeIP = "ip"
+)
+
+// Request weights of the endpoints we call, as documented by Binance.
+// Anything missing weighs 1.
+var (
+	binanceRequestWeights = map[string]int64{
+		"/api/v3/account":      20,
+		"/api/v3/exchangeInfo": 20,
+		"/api/v3/klines":       2,
+		"/api/v3/ticker/24hr":  2,
+		"/api/v3/ticker/price": 2,
+		"/api/v3/depth":        5,
+	}
+	binanceSapiUIDWeights = map[string]int64{
+		"/sapi/v1/convert/getQuote":    200,
+		"/sapi/v1/convert/acceptQuote": 500,
+		"/sapi/v1/convert/orderStatus": 100,
+	}
+)
+
+var (
+	governor     *RateLimitGovernor
+	governorOnce sync.Once
+)
+
+// Structs
+
+type rateLimitBucket struct {
+	name   string
+	header string
+	limit  int64
+	window time.Duration
+	perUID bool
+}
+
+type rateLimitUsage struct {
+	used    int64
+	resetAt time.Time
+}
+
+// RateLimitGovernor keeps Binance requests within the IP and UID limits.
+// It reserves the documented weight of a request before sending it, trusts
+// the used weight reported in the response headers and stops all traffic
+// while a 429 or 418 back-off is in effect.
+type RateLimitGovernor struct {
+	buckets     map[string]rateLimitBucket
+	margin      float64
+	maxWait     time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	mutex       sync.Mutex
+	usage       map[string]*rateLimitUsage
+	bannedUntil time.Time
+	strikes     int64
+	throttled   int64
+	rejected    int64
+}
+
+type rateLimitTransport struct {
+	governor *RateLimitGovernor
+	next     http.RoundTripper
+}
+
+type rateLimitCost struct {
+	bucket rateLimitBucket
+	scope  string
+	weight int64
+}
+
+// Factories
+
+func NewRateLimitGovernor(cfg *config.Config) *RateLimitGovernor {
+	return &RateLimitGovernor{
+		buckets: map[string]rateLimitBucket{
+			rateLimitBucketRequestWeight: {
+				name:   rateLimitBucketRequestWeight,
+				header: "X-Mbx-Used-Weight-1m",
+				limit:  cfg.Binance.RequestWeightLimit,
+				window: time.Minute,
+			},
+			rateLimitBucketSapiIPWeight: {
+				name:   rateLimitBucketSapiIPWeight,
+				header: "X-Sapi-Used-Ip-Weight-1m",
+				limit:  cfg.Binance.SapiIPWeightLimit,
+				window: time.Minute,
+			},
+			rateLimitBucketSapiUIDWeight: {
+				name:   rateLimitBucketSapiUIDWeight,
+				header: "X-Sapi-Used-Uid-Weight-1m",
+				limit:  cfg.Binance.SapiUIDWeightLimit,
+				window: time.Minute,
+				perUID: true,
+			},
+			rateLimitBucketOrderCount: {
+				name:   rateLimitBucketOrderCount,
+				header: "X-Mbx-Order-Count-10s",
+				limit:  cfg.Binance.OrderCountLimit,
+				window: 10 * time.Second,
+				perUID: true,
+			},
+		},
+		margin:     cfg.Binance.RateLimitSafetyMargin,
+		maxWait:    cfg.Binance.RateLimitMaxWait,
+		minBackoff: cfg.Binance.RateLimitMinBackoff,
+		maxBackoff: cfg.Binance.RateLimitMaxBackoff,
+		usage:      make(map[string]*rateLimitUsage),
+	}
+}
+
+// GetRateLimitGovernor returns the governor shared by every Binance client
+// of the process, since IP limits apply to all users at once.
+func GetRateLimitGovernor() *RateLimitGovernor {
+	governorOnce.Do(func() {
+		governor = NewRateLimitGovernor(config.GetConfig())
+	})
+	return governor
+}
+
+// Receivers
+
+// HTTPClient returns a client whose requests go through the governor.
+func (g *RateLimitGovernor) HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: g.Transport(http.DefaultTransport),
+	}
+}
+
+func (g *RateLimitGovernor) Transport(next http.RoundTripper) http.RoundTripper {
+	return &rateLimitTransport{
+		governor: g,
+		next:     next,
+	}
+}
+
+// Acquire reserves the weight of a request. It waits up to the configured
+// maximum for budget to free up and rejects the request otherwise.
+func (g *RateLimitGovernor) Acquire(
+	ctx context.Context,
+	request *http.Request,
+) error {
+	logger := config.GetLogger()
+	costs := g.costs(request)
+	for {
+		g.mutex.Lock()
+		now := time.Now()
+		wait, err := g.wait(costs, now)
+		if wait == 0 {
+			for _, cost := range costs {
+				g.usageFor(cost.bucket, cost.scope, now).used += cost.weight
+			}
+			g.mutex.Unlock()
+			return nil
+		}
+		if wait > g.maxWait {
+			g.rejected++
+			g.mutex.Unlock()
+			logger.Warnf("Rejecting %s: %s for another %s", request.URL.Path, err, wait)
+			return err
+		}
+		g.throttled++
+		g.mutex.Unlock()
+		logger.Debugf("Throttling %s for %s", request.URL.Path, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Observe records the usage reported by Binance and starts a back-off when
+// the response says we went over a limit.
+func (g *RateLimitGovernor) Observe(
+	request *http.Request,
+	response *http.Response,
+) {
+	logger := config.GetLogger()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := time.Now()
+	scope := requestScope(request)
+	for _, bucket := range g.buckets {
+		value := response.Header.Get(bucket.header)
+		if value == "" {
+			continue
+		}
+		used, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		bucketScope := rateLimitScopeIP
+		if bucket.perUID {
+			// UID usage can only be attributed to a signed request
+			if scope == rateLimitScopeIP {
+				continue
+			}
+			bucketScope = scope
+		}
+		usage := g.usageFor(bucket, bucketScope, now)
+		if used > usage.used {
+			usage.used = used
+		}
+	}
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusTeapot {
+		if response.StatusCode < http.StatusBadRequest {
+			g.strikes = 0
+		}
+		return
+	}
+	g.strikes++
+	backoff := g.backoff(response)
+	if until := now.Add(backoff); until.After(g.bannedUntil) {
+		g.bannedUntil = until
+	}
+	logger.Warnf(
+		"Binance answered %d to %s. Backing off for %s",
+		response.StatusCode,
+		request.URL.Path,
+		backoff,
+	)
+}
+
+// Metrics returns the remaining budget of every bucket seen so far.
+func (g *RateLimitGovernor) Metrics() *valueobjects.ExchangeRateLimitMetrics {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := time.Now()
+	budgets := make([]valueobjects.ExchangeRateLimitBudget, 0, len(g.usage))
+	for key := range g.usage {
+		name, scope, _ := strings.Cut(key, "|")
+		bucket := g.buckets[name]
+		usage := g.usageFor(bucket, scope, now)
+		remaining := bucket.limit - usage.used
+		if remaining < 0 {
+			remaining = 0
+		}
+		budgets = append(budgets, valueobjects.ExchangeRateLimitBudget{
+			Bucket:    name,
+			Scope:     maskScope(scope),
+			Used:      usage.used,
+			Limit:     bucket.limit,
+			Remaining: remaining,
+			ResetAt:   usage.resetAt,
+		})
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		if budgets[i].Bucket != budgets[j].Bucket {
+			return budgets[i].Bucket < budgets[j].Bucket
+		}
+		return budgets[i].Scope < budgets[j].Scope
+	})
+	metrics := valueobjects.ExchangeRateLimitMetrics{
+		Budgets:   budgets,
+		Throttled: g.throttled,
+		Rejected:  g.rejected,
+	}
+	if now.Before(g.bannedUntil) {
+		metrics.BannedUntil = g.bannedUntil
+	}
+	return &metrics
+}
+
+func (t *rateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	err := t.governor.Acquire(request.Context(), request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	t.governor.Observe(request, response)
+	return response, nil
+}
+
+// RateLimitError returns the governor error wrapped in err, if any, so
+// callers can tell throttling apart from other exchange failures.
+func RateLimitError(err error) error {
+	if errors.Is(err, domainErrors.ErrRateLimitBanned) {
+		return domainErrors.ErrRateLimitBanned
+	}
+	if errors.Is(err, domainErrors.ErrRateLimitExceeded) {
+		return domainErrors.ErrRateLimitExceeded
+	}
+	return nil
+}
+
+// Helpers
+
+func (g *RateLimitGovernor) costs(request *http.Request) []rateLimitCost {
+	path := request.URL.Path
+	scope := requestScope(request)
+	costs := make([]rateLimitCost, 0, 2)
+	switch {
+	case strings.HasPrefix(path, "/sapi/"):
+		if weight, ok := binanceSapiUIDWeights[path]; ok {
+			costs = append(costs, rateLimitCost{
+				bucket: g.buckets[rateLimitBucketSapiUIDWeight],
+				scope:  scope,
+				weight: weight,
+			})
+			break
+		}
+		costs = append(costs, rateLimitCost{
+			bucket: g.buckets[rateLimitBucketSapiIPWeight],
+			scope:  rateLimitScopeIP,
+			weight: 1,
+		})
+	default:
+		weight, ok := binanceRequestWeights[path]
+		if !ok {
+			weight = 1
+		}
+		// The 24 hours ticker of every symbol is far heavier
+		if path == "/api/v3/ticker/24hr" && request.URL.Query().Get("symbol") == "" {
+			weight = 80
+		}
+		costs = append(costs, rateLimitCost{
+			bucket: g.buckets[rateLimitBucketRequestWeight],
+			scope:  rateLimitScopeIP,
+			weight: weight,
+		})
+		if path == "/api/v3/order" && request.Method == http.MethodPost {
+			costs = append(costs, rateLimitCost{
+				bucket: g.buckets[rateLimitBucketOrderCount],
+				scope:  scope,
+				weight: 1,
+			})
+		}
+	}
+	return costs
+}
+
+// wait returns how long the request has to wait for budget, together with
+// the error to return if that is longer than we are willing to wait.
+func (g *RateLimitGovernor) wait(
+	costs []rateLimitCost,
+	now time.Time,
+) (time.Duration, error) {
+	if now.Before(g.bannedUntil) {
+		return g.bannedUntil.Sub(now), domainErrors.ErrRateLimitBanned
+	}
+	wait := time.Duration(0)
+	for _, cost := range costs {
+		usage := g.usageFor(cost.bucket, cost.scope, now)
+		budget := int64(float64(cost.bucket.limit) * g.margin)
+		if usage.used+cost.weight <= budget {
+			continue
+		}
+		if untilReset := usage.resetAt.Sub(now); untilReset > wait {
+			wait = untilReset
+		}
+	}
+	return wait, domainErrors.ErrRateLimitExceeded
+}
+
+func (g *RateLimitGovernor) usageFor(
+	bucket rateLimitBucket,
+	scope string,
+	now time.Time,
+) *rateLimitUsage {
+	key := bucket.name + "|" + scope
+	usage, ok := g.usage[key]
+	if !ok {
+		usage = &rateLimitUsage{}
+		g.usage[key] = usage
+	}
+	// Binance windows are aligned to the clock
+	if !now.Before(usage.resetAt) {
+		usage.used = 0
+		usage.resetAt = now.Truncate(bucket.window).Add(bucket.window)
+	}
+	return usage
+}
+
+func (g *RateLimitGovernor) backoff(response *http.Response) time.Duration {
+	if retryAfter := response.Header.Get("Retry-After"); retryAfter != "" {
+		seconds, err := strconv.ParseInt(retryAfter, 10, 64)
+		if err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	backoff := g.minBackoff
+	for i := int64(1); i < g.strikes && backoff < g.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > g.maxBackoff {
+		return g.maxBackoff
+	}
+	return backoff
+}
+
+func requestScope(request *http.Request) string {
+	apiKey := request.Header.Get("X-MBX-APIKEY")
+	if apiKey == "" {
+		return rateLimitScopeIP
+	}
+	return apiKey
+}
+
+// maskScope keeps API keys out of the metrics.
+func maskScope(scope string) string {
+	if scope == rateLimitScopeIP || len(scope) <= 4 {
+		return scope
+	}
+	return "uid:" + scope[len(scope)-4:]
+}
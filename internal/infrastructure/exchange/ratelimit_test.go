@@ -0,0 +1,164 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRateLimitedServer answers every request with the given status and
+// headers, and a 24 hours ticker body when the status is OK.
+func newRateLimitedServer(
+	t *testing.T,
+	status int,
+	headers map[string]string,
+) (*httptest.Server, *int64) {
+	calls := int64(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"symbol":"BTCUSDT","lastPrice":"64250","volume":"1","priceChangePercent":"1"}`))
+			return
+		}
+		w.Write([]byte(`{"code":-1003,"msg":"Too many requests."}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestGovernor() *RateLimitGovernor {
+	cfg := *config.GetConfig()
+	cfg.Binance.RateLimitMaxWait = 20 * time.Millisecond
+	cfg.Binance.RateLimitMinBackoff = time.Minute
+	return NewRateLimitGovernor(&cfg)
+}
+
+// sendThroughGovernor sends a request to the server through the governor,
+// signed with the API key when one is given.
+func sendThroughGovernor(
+	server *httptest.Server,
+	governor *RateLimitGovernor,
+	method string,
+	path string,
+	apiKey string,
+) error {
+	client := &http.Client{Transport: governor.Transport(server.Client().Transport)}
+	request, err := http.NewRequestWithContext(context.Background(), method, server.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	if apiKey != "" {
+		request.Header.Set("X-MBX-APIKEY", apiKey)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func TestRateLimitGovernorTracksWeightFromHeaders(t *testing.T) {
+	// Arrange
+	server, _ := newRateLimitedServer(t, http.StatusOK, map[string]string{
+		"X-MBX-USED-WEIGHT-1M": "120",
+	})
+	governor := newTestGovernor()
+
+	// Act
+	err := sendThroughGovernor(server, governor, http.MethodGet, "/api/v3/ticker/24hr?symbol=BTCUSDT", "")
+	metrics := governor.Metrics()
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Len(t, metrics.Budgets, 1)
+	assert.Equal(t, "request_weight", metrics.Budgets[0].Bucket)
+	assert.Equal(t, "ip", metrics.Budgets[0].Scope)
+	assert.Equal(t, int64(120), metrics.Budgets[0].Used)
+	assert.Equal(t, int64(6000-120), metrics.Budgets[0].Remaining)
+}
+
+func TestRateLimitGovernorRejectsBeforeLimitIsHit(t *testing.T) {
+	// Arrange
+	server, calls := newRateLimitedServer(t, http.StatusOK, map[string]string{
+		"X-MBX-USED-WEIGHT-1M": "5399",
+	})
+	governor := newTestGovernor()
+	err := sendThroughGovernor(server, governor, http.MethodGet, "/api/v3/ticker/24hr?symbol=BTCUSDT", "")
+	assert.Nil(t, err)
+
+	// Act
+	err = sendThroughGovernor(server, governor, http.MethodGet, "/api/v3/ticker/24hr?symbol=BTCUSDT", "")
+
+	// Assert
+	assert.Equal(t, errors.ErrRateLimitExceeded, RateLimitError(err))
+	assert.Equal(t, int64(1), atomic.LoadInt64(calls))
+	assert.Equal(t, int64(1), governor.Metrics().Rejected)
+}
+
+func TestRateLimitGovernorBacksOffOnTooManyRequests(t *testing.T) {
+	// Arrange
+	server, calls := newRateLimitedServer(t, http.StatusTooManyRequests, map[string]string{
+		"Retry-After": "30",
+	})
+	governor := newTestGovernor()
+	err := sendThroughGovernor(server, governor, http.MethodGet, "/api/v3/ticker/24hr?symbol=BTCUSDT", "")
+	assert.Nil(t, err)
+
+	// Act
+	err = sendThroughGovernor(server, governor, http.MethodGet, "/api/v3/account", "user-api-key")
+	metrics := governor.Metrics()
+
+	// Assert
+	assert.Equal(t, errors.ErrRateLimitBanned, RateLimitError(err))
+	assert.Equal(t, int64(1), atomic.LoadInt64(calls))
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), metrics.BannedUntil, 2*time.Second)
+}
+
+func TestRateLimitGovernorBacksOffOnBan(t *testing.T) {
+	// Arrange
+	server, calls := newRateLimitedServer(t, http.StatusTeapot, nil)
+	governor := newTestGovernor()
+	_ = sendThroughGovernor(server, governor, http.MethodGet, "/api/v3/ticker/24hr?symbol=BTCUSDT", "")
+
+	// Act
+	err := sendThroughGovernor(server, governor, http.MethodPost, "/api/v3/order", "user-api-key")
+
+	// Assert
+	assert.Equal(t, errors.ErrRateLimitBanned, RateLimitError(err))
+	assert.Equal(t, int64(1), atomic.LoadInt64(calls))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), governor.Metrics().BannedUntil, 2*time.Second)
+}
+
+func TestRateLimitGovernorLimitsOrderCountPerUser(t *testing.T) {
+	// Arrange
+	server, _ := newRateLimitedServer(t, http.StatusOK, map[string]string{
+		"X-MBX-USED-WEIGHT-1M":  "10",
+		"X-MBX-ORDER-COUNT-10S": "44",
+	})
+	governor := newTestGovernor()
+	_ = sendThroughGovernor(server, governor, http.MethodPost, "/api/v3/order", "user-api-key")
+	_ = sendThroughGovernor(server, governor, http.MethodPost, "/api/v3/order", "user-api-key")
+
+	// Act
+	err := sendThroughGovernor(server, governor, http.MethodPost, "/api/v3/order", "user-api-key")
+	metrics := governor.Metrics()
+
+	// Assert
+	assert.Equal(t, errors.ErrRateLimitExceeded, RateLimitError(err))
+	assert.Len(t, metrics.Budgets, 2)
+	assert.Equal(t, "order_count", metrics.Budgets[0].Bucket)
+	assert.Equal(t, "uid:-key", metrics.Budgets[0].Scope)
+	assert.Equal(t, int64(45), metrics.Budgets[0].Used)
+	assert.Equal(t, int64(5), metrics.Budgets[0].Remaining)
+}
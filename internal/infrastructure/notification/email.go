@@ -0,0 +1,97 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
+
+// EmailClient delivers notifications and account emails through an SMTP
+// relay. STARTTLS is used whenever the relay offers it.
+type EmailClient struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Factories
+
+func NewEmailClient(cfg *config.Config) *EmailClient {
+	return &EmailClient{
+		Host:     cfg.Notifications.SMTPHost,
+		Port:     cfg.Notifications.SMTPPort,
+		Username: cfg.Notifications.SMTPUsername,
+		Password: cfg.Notifications.SMTPPassword,
+		From:     cfg.Notifications.SMTPFrom,
+	}
+}
+
+// Notifier implementation
+
+func (c *EmailClient) Notify(
+	ctx context.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) error {
+	return c.send(channel.Target, message)
+}
+
+// Mailer implementation
+
+func (c *EmailClient) Send(ctx context.Context, mail *valueobjects.Mail) error {
+	return c.send(mail.To, &valueobjects.NotificationMessage{
+		Subject: mail.Subject,
+		Text:    mail.Text,
+		Format:  constants.NotificationFormatText,
+	})
+}
+
+// Helpers
+
+func (c *EmailClient) send(to string, message *valueobjects.NotificationMessage) error {
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+	return smtp.SendMail(
+		net.JoinHostPort(c.Host, c.Port),
+		auth,
+		c.From,
+		[]string{to},
+		c.compose(to, message),
+	)
+}
+
+// compose builds the email. The subject is stripped of line breaks so it
+// can't inject headers.
+func (c *EmailClient) compose(
+	to string,
+	message *valueobjects.NotificationMessage,
+) []byte {
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(message.Subject)
+	contentType := "text/plain"
+	if message.Format == constants.NotificationFormatHTML {
+		contentType = "text/html"
+	}
+	headers := []string{
+		fmt.Sprintf("From: %s", c.From),
+		fmt.Sprintf("To: %s", to),
+		fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("utf-8", subject)),
+		fmt.Sprintf("Date: %s", time.Now().UTC().Format(time.RFC1123Z)),
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: %s; charset=UTF-8", contentType),
+	}
+	body := strings.ReplaceAll(message.Text, "\n", "\r\n")
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body + "\r\n")
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
+
+// Mailer sends account emails, the ones every user gets regardless of their
+// notification channels.
+type Mailer interface {
+	Send(ctx context.Context, mail *valueobjects.Mail) error
+}
+
+// CaptureMailer keeps emails in memory instead of sending them, standing in
+// for the SMTP relay in local environments and tests.
+type CaptureMailer struct {
+	mutex sync.Mutex
+	mails []valueobjects.Mail
+}
+
+// Factories
+
+// NewMailer returns the mailer the configuration asks for, SMTP by default.
+func NewMailer(cfg *config.Config) Mailer {
+	if cfg.Notifications.Mailer == constants.MailerCapture {
+		return NewCaptureMailer()
+	}
+	return NewEmailClient(cfg)
+}
+
+func NewCaptureMailer() *CaptureMailer {
+	return &CaptureMailer{mails: []valueobjects.Mail{}}
+}
+
+// Mailer implementation
+
+func (m *CaptureMailer) Send(ctx context.Context, mail *valueobjects.Mail) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.mails = append(m.mails, *mail)
+	config.GetLogger().Infof("Captured email to %s: %s", mail.To, mail.Subject)
+	return nil
+}
+
+// Receivers
+
+// Mails returns the emails captured so far, oldest first.
+func (m *CaptureMailer) Mails() []valueobjects.Mail {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	mails := make([]valueobjects.Mail, len(m.mails))
+	copy(mails, m.mails)
+	return mails
+}
+
+// Last returns the latest email captured for the address, if any.
+func (m *CaptureMailer) Last(to string) (*valueobjects.Mail, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i := len(m.mails) - 1; i >= 0; i-- {
+		if m.mails[i].To == to {
+			mail := m.mails[i]
+			return &mail, true
+		}
+	}
+	return nil, false
+}
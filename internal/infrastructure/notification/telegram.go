@@ -1,7 +1,14 @@
 package notification
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"gopkg.in/telebot.v3"
 )
 
@@ -19,6 +26,7 @@ func NewTelegramClient(
 	bot, err := telebot.NewBot(
 		telebot.Settings{
 			Token: token,
+			URL:   cfg.Telegram.APIURL,
 		},
 	)
 	if err != nil {
@@ -30,3 +38,23 @@ func NewTelegramClient(
 		ChatID: chatID,
 	}
 }
+
+// Notify sends the message to the chat in the channel target, as the bot
+// whose token is the channel secret.
+func (c *TelegramClient) Notify(
+	ctx context.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) error {
+	chatID, err := strconv.ParseInt(channel.Target, 10, 64)
+	if err != nil {
+		return errors.ErrInvalidNotificationTarget
+	}
+	c.Bot.Token = channel.Secret
+	options := message.Options
+	if message.Format == constants.NotificationFormatMarkdown {
+		options = append([]interface{}{telebot.ModeMarkdown}, options...)
+	}
+	_, err = c.Bot.Send(&telebot.Chat{ID: chatID}, message.Text, options...)
+	return err
+}
@@ -0,0 +1,127 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
+)
+
+// SlackClient posts notifications to Slack incoming webhooks.
+type SlackClient struct {
+	HTTPClient *http.Client
+}
+
+// WebhookClient posts notifications as JSON to user provided endpoints. Each
+// request is signed with the channel secret, see Sign.
+type WebhookClient struct {
+	HTTPClient *http.Client
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+type webhookPayload struct {
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Factories
+
+func NewSlackClient(cfg *config.Config) *SlackClient {
+	return &SlackClient{
+		HTTPClient: &http.Client{Timeout: cfg.Notifications.HTTPTimeout},
+	}
+}
+
+func NewWebhookClient(cfg *config.Config) *WebhookClient {
+	return &WebhookClient{
+		HTTPClient: &http.Client{Timeout: cfg.Notifications.HTTPTimeout},
+	}
+}
+
+// Notifier implementations
+
+func (c *SlackClient) Notify(
+	ctx context.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) error {
+	body, err := json.Marshal(slackPayload{Text: message.Text})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return post(c.HTTPClient, request)
+}
+
+func (c *WebhookClient) Notify(
+	ctx context.Context,
+	channel *entities.NotificationChannel,
+	message *valueobjects.NotificationMessage,
+) error {
+	now := time.Now().UTC()
+	body, err := json.Marshal(webhookPayload{
+		Kind:    message.Kind,
+		Subject: message.Subject,
+		Text:    message.Text,
+		SentAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(constants.WebhookTimestampHeader, timestamp)
+	request.Header.Set(constants.WebhookSignatureHeader, Sign(channel.Secret, timestamp, body))
+	return post(c.HTTPClient, request)
+}
+
+// Sign returns the signature of a webhook delivery: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the channel secret. Signing
+// the timestamp lets receivers refuse replayed deliveries.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Helpers
+
+func post(client *http.Client, request *http.Request) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", errors.ErrNotificationRejected, response.Status)
+	}
+	return nil
+}
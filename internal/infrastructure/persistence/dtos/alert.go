@@ -0,0 +1,69 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type AlertRule struct {
+	gorm.Model
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	Symbol          string     `gorm:"type:varchar(20);not null;index;"`
+	Field           string     `gorm:"type:varchar(30);not null;"`
+	Condition       string     `gorm:"type:varchar(10);not null;"`
+	Threshold       float64    `gorm:"type:decimal(30,10);not null;"`
+	CooldownMinutes int        `gorm:"type:integer;not null;default:0;"`
+	Enabled         bool       `gorm:"type:boolean;not null;default:false;index;"`
+	LastValue       *float64   `gorm:"type:decimal(30,10);"`
+	LastTriggeredAt *time.Time `gorm:"type:timestamp;"`
+	CreatedAt       time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt       time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type AlertRules []AlertRule
+
+// Receivers
+
+func (r *AlertRule) ToEntity() *entities.AlertRule {
+	return &entities.AlertRule{
+		ID:              r.ID,
+		UserID:          r.UserID,
+		Symbol:          r.Symbol,
+		Field:           r.Field,
+		Condition:       r.Condition,
+		Threshold:       r.Threshold,
+		CooldownMinutes: r.CooldownMinutes,
+		Enabled:         r.Enabled,
+		LastValue:       r.LastValue,
+		LastTriggeredAt: r.LastTriggeredAt,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+}
+
+func (r *AlertRule) FromEntity(rule *entities.AlertRule) {
+	r.ID = rule.ID
+	r.UserID = rule.UserID
+	r.Symbol = rule.Symbol
+	r.Field = rule.Field
+	r.Condition = rule.Condition
+	r.Threshold = rule.Threshold
+	r.CooldownMinutes = rule.CooldownMinutes
+	r.Enabled = rule.Enabled
+	r.LastValue = rule.LastValue
+	r.LastTriggeredAt = rule.LastTriggeredAt
+	r.CreatedAt = rule.CreatedAt
+	r.UpdatedAt = rule.UpdatedAt
+}
+
+func (r *AlertRules) ToEntities() *entities.AlertRules {
+	entities := make(entities.AlertRules, len(*r))
+	for i, rule := range *r {
+		entities[i] = *rule.ToEntity()
+	}
+	return &entities
+}
@@ -0,0 +1,86 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertRule_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	lastValue := 0.75
+	dto := &AlertRule{
+		ID:              uuid.New(),
+		UserID:          uuid.New(),
+		Symbol:          "ETHUSDT",
+		Field:           constants.AlertFieldScore,
+		Condition:       constants.AlertConditionAbove,
+		Threshold:       0.8,
+		CooldownMinutes: 60,
+		Enabled:         true,
+		LastValue:       &lastValue,
+		LastTriggeredAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, "ETHUSDT", entity.Symbol)
+	assert.Equal(t, constants.AlertFieldScore, entity.Field)
+	assert.Equal(t, constants.AlertConditionAbove, entity.Condition)
+	assert.Equal(t, 0.8, entity.Threshold)
+	assert.Equal(t, 60, entity.CooldownMinutes)
+	assert.True(t, entity.Enabled)
+	assert.Equal(t, &lastValue, entity.LastValue)
+	assert.Equal(t, &now, entity.LastTriggeredAt)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestAlertRule_FromEntity(t *testing.T) {
+	// Arrange
+	ruleFactory := &entities.AlertRuleFactory{}
+	entity := ruleFactory.NewAlertRule(uuid.New(), "BTCUSDT", constants.AlertFieldRSI6, constants.AlertConditionBelow, 20, 30)
+	dto := &AlertRule{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, "BTCUSDT", dto.Symbol)
+	assert.Equal(t, constants.AlertFieldRSI6, dto.Field)
+	assert.Equal(t, constants.AlertConditionBelow, dto.Condition)
+	assert.Equal(t, 20.0, dto.Threshold)
+	assert.Equal(t, 30, dto.CooldownMinutes)
+	assert.True(t, dto.Enabled)
+	assert.Nil(t, dto.LastValue)
+	assert.Nil(t, dto.LastTriggeredAt)
+}
+
+func TestAlertRules_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := AlertRules{
+		{ID: uuid.New(), Field: constants.AlertFieldClose},
+		{ID: uuid.New(), Field: constants.AlertFieldScore},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, dtos[0].ID, (*entities)[0].ID)
+	assert.Equal(t, constants.AlertFieldScore, (*entities)[1].Field)
+}
@@ -7,14 +7,24 @@ import (
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 )
 
+// Key and Secret are stored encrypted with a data key of the record's own,
+// kept wrapped by the master key it names. Rows without a master key ID
+// predate encryption and hold plaintext until they are re-encrypted.
 type ApiKey struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;"`
-	Service   string    `gorm:"type:varchar(100);not null;"`
-	Key       string    `gorm:"type:varchar(100);not null;"`
-	Secret    string    `gorm:"type:varchar(100);not null;"`
-	CreatedAt time.Time `gorm:"type:timestamp;not null;"`
-	UpdatedAt time.Time `gorm:"type:timestamp;not null;"`
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;"`
+	Service     string    `gorm:"type:varchar(100);not null;"`
+	Key         string    `gorm:"type:text;not null;"`
+	Secret      string    `gorm:"type:text;not null;"`
+	DataKey     string    `gorm:"type:text;"`
+	MasterKeyID string    `gorm:"type:varchar(50);index;"`
+	// Permissions verified on the exchange
+	CanTrade     bool       `gorm:"type:boolean;not null;default:false;"`
+	CanWithdraw  bool       `gorm:"type:boolean;not null;default:false;"`
+	IPRestricted bool       `gorm:"type:boolean;not null;default:false;"`
+	VerifiedAt   *time.Time `gorm:"type:timestamp;"`
+	CreatedAt    time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt    time.Time  `gorm:"type:timestamp;not null;"`
 }
 
 type ApiKeys []ApiKey
@@ -23,13 +33,17 @@ type ApiKeys []ApiKey
 
 func (a *ApiKey) ToEntity() *entities.ApiKey {
 	return &entities.ApiKey{
-		ID:        a.ID,
-		UserID:    a.UserID,
-		Service:   a.Service,
-		Key:       a.Key,
-		Secret:    a.Secret,
-		CreatedAt: a.CreatedAt,
-		UpdatedAt: a.UpdatedAt,
+		ID:           a.ID,
+		UserID:       a.UserID,
+		Service:      a.Service,
+		Key:          a.Key,
+		Secret:       a.Secret,
+		CanTrade:     a.CanTrade,
+		CanWithdraw:  a.CanWithdraw,
+		IPRestricted: a.IPRestricted,
+		VerifiedAt:   a.VerifiedAt,
+		CreatedAt:    a.CreatedAt,
+		UpdatedAt:    a.UpdatedAt,
 	}
 }
 
@@ -39,6 +53,10 @@ func (a *ApiKey) FromEntity(apiKey *entities.ApiKey) {
 	a.Service = apiKey.Service
 	a.Key = apiKey.Key
 	a.Secret = apiKey.Secret
+	a.CanTrade = apiKey.CanTrade
+	a.CanWithdraw = apiKey.CanWithdraw
+	a.IPRestricted = apiKey.IPRestricted
+	a.VerifiedAt = apiKey.VerifiedAt
 	a.CreatedAt = apiKey.CreatedAt
 	a.UpdatedAt = apiKey.UpdatedAt
 }
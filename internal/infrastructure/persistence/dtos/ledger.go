@@ -0,0 +1,96 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type LedgerEntry struct {
+	gorm.Model
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index;"`
+	FromHoldingID uuid.UUID `gorm:"type:uuid;index;"`
+	ToHoldingID   uuid.UUID `gorm:"type:uuid;index;"`
+	OrderID       uuid.UUID `gorm:"type:uuid;"`
+	Reference     string    `gorm:"type:varchar(64);"`
+	EntryType     string    `gorm:"type:varchar(20);not null;"`
+	FromAsset     string    `gorm:"type:varchar(20);not null;"`
+	FromDelta     float64   `gorm:"type:decimal(30,10);not null;"`
+	ToAsset       string    `gorm:"type:varchar(20);not null;"`
+	ToDelta       float64   `gorm:"type:decimal(30,10);not null;"`
+	Fee           float64   `gorm:"type:decimal(30,10);not null;default:0;"`
+	FeeAsset      string    `gorm:"type:varchar(20);"`
+	FeeValue      float64   `gorm:"type:decimal(30,10);not null;default:0;"`
+	ExpectedPrice float64   `gorm:"type:decimal(30,10);"`
+	ExecutedPrice float64   `gorm:"type:decimal(30,10);"`
+	Slippage      float64   `gorm:"type:decimal(20,10);"`
+	Proceeds      float64   `gorm:"type:decimal(30,10);"`
+	CostBasis     float64   `gorm:"type:decimal(30,10);"`
+	RealisedPnL   float64   `gorm:"type:decimal(30,10);"`
+	CreatedAt     time.Time `gorm:"type:timestamp;not null;"`
+}
+
+type LedgerEntries []LedgerEntry
+
+// Receivers
+
+func (l *LedgerEntry) ToEntity() *entities.LedgerEntry {
+	return &entities.LedgerEntry{
+		ID:            l.ID,
+		UserID:        l.UserID,
+		FromHoldingID: l.FromHoldingID,
+		ToHoldingID:   l.ToHoldingID,
+		OrderID:       l.OrderID,
+		Reference:     l.Reference,
+		EntryType:     l.EntryType,
+		FromAsset:     l.FromAsset,
+		FromDelta:     l.FromDelta,
+		ToAsset:       l.ToAsset,
+		ToDelta:       l.ToDelta,
+		Fee:           l.Fee,
+		FeeAsset:      l.FeeAsset,
+		FeeValue:      l.FeeValue,
+		ExpectedPrice: l.ExpectedPrice,
+		ExecutedPrice: l.ExecutedPrice,
+		Slippage:      l.Slippage,
+		Proceeds:      l.Proceeds,
+		CostBasis:     l.CostBasis,
+		RealisedPnL:   l.RealisedPnL,
+		CreatedAt:     l.CreatedAt,
+	}
+}
+
+func (l *LedgerEntry) FromEntity(entry *entities.LedgerEntry) {
+	l.ID = entry.ID
+	l.UserID = entry.UserID
+	l.FromHoldingID = entry.FromHoldingID
+	l.ToHoldingID = entry.ToHoldingID
+	l.OrderID = entry.OrderID
+	l.Reference = entry.Reference
+	l.EntryType = entry.EntryType
+	l.FromAsset = entry.FromAsset
+	l.FromDelta = entry.FromDelta
+	l.ToAsset = entry.ToAsset
+	l.ToDelta = entry.ToDelta
+	l.Fee = entry.Fee
+	l.FeeAsset = entry.FeeAsset
+	l.FeeValue = entry.FeeValue
+	l.ExpectedPrice = entry.ExpectedPrice
+	l.ExecutedPrice = entry.ExecutedPrice
+	l.Slippage = entry.Slippage
+	l.Proceeds = entry.Proceeds
+	l.CostBasis = entry.CostBasis
+	l.RealisedPnL = entry.RealisedPnL
+	l.CreatedAt = entry.CreatedAt
+}
+
+func (l *LedgerEntries) ToEntities() *entities.LedgerEntries {
+	entities := make(entities.LedgerEntries, len(*l))
+	for i, entry := range *l {
+		entities[i] = *entry.ToEntity()
+	}
+	return &entities
+}
@@ -0,0 +1,137 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedgerEntry_ToEntity(t *testing.T) {
+	// Arrange
+	id := uuid.New()
+	userID := uuid.New()
+	fromHoldingID := uuid.New()
+	toHoldingID := uuid.New()
+	orderID := uuid.New()
+	now := time.Now()
+
+	dto := &LedgerEntry{
+		ID:            id,
+		UserID:        userID,
+		FromHoldingID: fromHoldingID,
+		ToHoldingID:   toHoldingID,
+		OrderID:       orderID,
+		Reference:     "quote-1",
+		EntryType:     constants.LedgerEntryTypeConversion,
+		FromAsset:     "BTC",
+		FromDelta:     -0.5,
+		ToAsset:       "ETH",
+		ToDelta:       10,
+		Fee:           0.01,
+		FeeAsset:      "BNB",
+		FeeValue:      6,
+		ExpectedPrice: 60000,
+		ExecutedPrice: 59700,
+		Slippage:      0.005,
+		Proceeds:      29850,
+		CostBasis:     25000,
+		RealisedPnL:   4844,
+		CreatedAt:     now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.NotNil(t, entity)
+	assert.Equal(t, id, entity.ID)
+	assert.Equal(t, userID, entity.UserID)
+	assert.Equal(t, fromHoldingID, entity.FromHoldingID)
+	assert.Equal(t, toHoldingID, entity.ToHoldingID)
+	assert.Equal(t, orderID, entity.OrderID)
+	assert.Equal(t, "quote-1", entity.Reference)
+	assert.Equal(t, constants.LedgerEntryTypeConversion, entity.EntryType)
+	assert.Equal(t, "BTC", entity.FromAsset)
+	assert.Equal(t, -0.5, entity.FromDelta)
+	assert.Equal(t, "ETH", entity.ToAsset)
+	assert.Equal(t, 10.0, entity.ToDelta)
+	assert.Equal(t, 0.01, entity.Fee)
+	assert.Equal(t, "BNB", entity.FeeAsset)
+	assert.Equal(t, 6.0, entity.FeeValue)
+	assert.Equal(t, 60000.0, entity.ExpectedPrice)
+	assert.Equal(t, 59700.0, entity.ExecutedPrice)
+	assert.Equal(t, 0.005, entity.Slippage)
+	assert.Equal(t, 29850.0, entity.Proceeds)
+	assert.Equal(t, 25000.0, entity.CostBasis)
+	assert.Equal(t, 4844.0, entity.RealisedPnL)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestLedgerEntry_FromEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entity := &entities.LedgerEntry{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		FromHoldingID: uuid.New(),
+		ToHoldingID:   uuid.New(),
+		OrderID:       uuid.New(),
+		Reference:     "quote-1",
+		EntryType:     constants.LedgerEntryTypeConversion,
+		FromAsset:     "BTC",
+		FromDelta:     -0.5,
+		ToAsset:       "USDT",
+		ToDelta:       29850,
+		ExpectedPrice: 60000,
+		ExecutedPrice: 59700,
+		Slippage:      0.005,
+		Proceeds:      29850,
+		CostBasis:     25000,
+		RealisedPnL:   4850,
+		CreatedAt:     now,
+	}
+	dto := &LedgerEntry{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, entity.FromHoldingID, dto.FromHoldingID)
+	assert.Equal(t, entity.ToHoldingID, dto.ToHoldingID)
+	assert.Equal(t, entity.OrderID, dto.OrderID)
+	assert.Equal(t, entity.Reference, dto.Reference)
+	assert.Equal(t, entity.EntryType, dto.EntryType)
+	assert.Equal(t, entity.FromAsset, dto.FromAsset)
+	assert.Equal(t, entity.FromDelta, dto.FromDelta)
+	assert.Equal(t, entity.ToAsset, dto.ToAsset)
+	assert.Equal(t, entity.ToDelta, dto.ToDelta)
+	assert.Equal(t, entity.ExpectedPrice, dto.ExpectedPrice)
+	assert.Equal(t, entity.ExecutedPrice, dto.ExecutedPrice)
+	assert.Equal(t, entity.Slippage, dto.Slippage)
+	assert.Equal(t, entity.Proceeds, dto.Proceeds)
+	assert.Equal(t, entity.CostBasis, dto.CostBasis)
+	assert.Equal(t, entity.RealisedPnL, dto.RealisedPnL)
+	assert.Equal(t, now, dto.CreatedAt)
+}
+
+func TestLedgerEntries_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := LedgerEntries{
+		{ID: uuid.New(), FromAsset: "BTC", ToAsset: "USDT"},
+		{ID: uuid.New(), FromAsset: "USDT", ToAsset: "ETH"},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, dtos[0].ID, (*entities)[0].ID)
+	assert.Equal(t, dtos[1].ToAsset, (*entities)[1].ToAsset)
+}
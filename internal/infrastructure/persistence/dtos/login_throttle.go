@@ -0,0 +1,48 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+)
+
+// LoginThrottle rows are unique per scope and key, so instances counting
+// failures concurrently all increment the same row in place instead of
+// overwriting each other's count.
+type LoginThrottle struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	Scope          string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_login_throttle_scope_key;"`
+	Key            string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttle_scope_key;"`
+	FailedAttempts int        `gorm:"type:integer;not null;default:0;"`
+	LastFailedAt   time.Time  `gorm:"type:timestamp;not null;"`
+	LockedUntil    *time.Time `gorm:"type:timestamp;"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt      time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+// Receivers
+
+func (t *LoginThrottle) ToEntity() *entities.LoginThrottle {
+	return &entities.LoginThrottle{
+		ID:             t.ID,
+		Scope:          t.Scope,
+		Key:            t.Key,
+		FailedAttempts: t.FailedAttempts,
+		LastFailedAt:   t.LastFailedAt,
+		LockedUntil:    t.LockedUntil,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+	}
+}
+
+func (t *LoginThrottle) FromEntity(throttle *entities.LoginThrottle) {
+	t.ID = throttle.ID
+	t.Scope = throttle.Scope
+	t.Key = throttle.Key
+	t.FailedAttempts = throttle.FailedAttempts
+	t.LastFailedAt = throttle.LastFailedAt
+	t.LockedUntil = throttle.LockedUntil
+	t.CreatedAt = throttle.CreatedAt
+	t.UpdatedAt = throttle.UpdatedAt
+}
@@ -0,0 +1,63 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottle_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	lockedUntil := now.Add(time.Minute)
+	dto := &LoginThrottle{
+		ID:             uuid.New(),
+		Scope:          constants.LoginThrottleScopeAccount,
+		Key:            "test@test.com",
+		FailedAttempts: 5,
+		LastFailedAt:   now,
+		LockedUntil:    &lockedUntil,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, constants.LoginThrottleScopeAccount, entity.Scope)
+	assert.Equal(t, "test@test.com", entity.Key)
+	assert.Equal(t, 5, entity.FailedAttempts)
+	assert.Equal(t, now, entity.LastFailedAt)
+	assert.Equal(t, &lockedUntil, entity.LockedUntil)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestLoginThrottle_FromEntity(t *testing.T) {
+	// Arrange
+	factory := &entities.LoginThrottleFactory{}
+	entity := factory.NewLoginThrottle(constants.LoginThrottleScopeIP, "127.0.0.1")
+	now := time.Now().UTC()
+	entity.FailedAttempts = 1
+	entity.LastFailedAt = now
+	entity.Lock(now, 1, time.Minute, time.Hour)
+	dto := &LoginThrottle{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, constants.LoginThrottleScopeIP, dto.Scope)
+	assert.Equal(t, "127.0.0.1", dto.Key)
+	assert.Equal(t, 1, dto.FailedAttempts)
+	assert.Equal(t, entity.LastFailedAt, dto.LastFailedAt)
+	assert.Equal(t, entity.LockedUntil, dto.LockedUntil)
+	assert.Equal(t, entity.CreatedAt, dto.CreatedAt)
+}
@@ -55,6 +55,8 @@ type MarketData struct {
 	ADXIndex    *float64 `gorm:"type:decimal(10,2);"`
 	ADXPositive *float64 `gorm:"type:decimal(10,2);"`
 	ADXNegative *float64 `gorm:"type:decimal(10,2);"`
+	// Score
+	Score *float64 `gorm:"type:decimal(10,4);"`
 	// Meta
 	CreatedAt time.Time `gorm:"type:timestamp;not null;"`
 	UpdatedAt time.Time `gorm:"type:timestamp;not null;"`
@@ -62,6 +64,19 @@ type MarketData struct {
 
 type MarketDatas []MarketData
 
+type MarketRegime struct {
+	gorm.Model
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;"`
+	Regime          string    `gorm:"type:varchar(10);not null;"`
+	Breadth         float64   `gorm:"type:decimal(10,4);not null;"`
+	ATRSpike        float64   `gorm:"type:decimal(10,4);not null;"`
+	BTCDrawdown     float64   `gorm:"type:decimal(10,4);not null;"`
+	MarketsMeasured int       `gorm:"type:integer;not null;"`
+	CreatedAt       time.Time `gorm:"type:timestamp;not null;"`
+}
+
+type MarketRegimes []MarketRegime
+
 // Receivers
 
 func (m *Market) ToEntity() *entities.Market {
@@ -124,6 +139,7 @@ func (m *MarketData) ToEntity() *entities.MarketData {
 		ADXIndex:            m.ADXIndex,
 		ADXPositive:         m.ADXPositive,
 		ADXNegative:         m.ADXNegative,
+		Score:               m.Score,
 		CreatedAt:           m.CreatedAt,
 		UpdatedAt:           m.UpdatedAt,
 	}
@@ -158,6 +174,7 @@ func (m *MarketData) FromEntity(marketData *entities.MarketData) {
 	m.ADXIndex = marketData.ADXIndex
 	m.ADXPositive = marketData.ADXPositive
 	m.ADXNegative = marketData.ADXNegative
+	m.Score = marketData.Score
 	m.CreatedAt = marketData.CreatedAt
 	m.UpdatedAt = marketData.UpdatedAt
 }
@@ -169,3 +186,33 @@ func (m *MarketDatas) ToEntities() *entities.MarketDatas {
 	}
 	return &entities
 }
+
+func (m *MarketRegime) ToEntity() *entities.MarketRegime {
+	return &entities.MarketRegime{
+		ID:              m.ID,
+		Regime:          m.Regime,
+		Breadth:         m.Breadth,
+		ATRSpike:        m.ATRSpike,
+		BTCDrawdown:     m.BTCDrawdown,
+		MarketsMeasured: m.MarketsMeasured,
+		CreatedAt:       m.CreatedAt,
+	}
+}
+
+func (m *MarketRegime) FromEntity(regime *entities.MarketRegime) {
+	m.ID = regime.ID
+	m.Regime = regime.Regime
+	m.Breadth = regime.Breadth
+	m.ATRSpike = regime.ATRSpike
+	m.BTCDrawdown = regime.BTCDrawdown
+	m.MarketsMeasured = regime.MarketsMeasured
+	m.CreatedAt = regime.CreatedAt
+}
+
+func (m *MarketRegimes) ToEntities() *entities.MarketRegimes {
+	entities := make(entities.MarketRegimes, len(*m))
+	for i, regime := range *m {
+		entities[i] = *regime.ToEntity()
+	}
+	return &entities
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/stretchr/testify/assert"
 )
@@ -116,6 +117,7 @@ func TestMarketData_ToEntity(t *testing.T) {
 	bb := 3.45
 	obv := 1000.0
 	adx := 25.0
+	score := 0.85
 
 	dto := &MarketData{
 		ID:                  id,
@@ -145,6 +147,7 @@ func TestMarketData_ToEntity(t *testing.T) {
 		ADXIndex:            &adx,
 		ADXPositive:         &adx,
 		ADXNegative:         &adx,
+		Score:               &score,
 		CreatedAt:           now,
 		UpdatedAt:           now,
 	}
@@ -181,6 +184,7 @@ func TestMarketData_ToEntity(t *testing.T) {
 	assert.Equal(t, &adx, entity.ADXIndex)
 	assert.Equal(t, &adx, entity.ADXPositive)
 	assert.Equal(t, &adx, entity.ADXNegative)
+	assert.Equal(t, &score, entity.Score)
 	assert.Equal(t, now, entity.CreatedAt)
 	assert.Equal(t, now, entity.UpdatedAt)
 }
@@ -196,6 +200,7 @@ func TestMarketData_FromEntity(t *testing.T) {
 	bb := 3.45
 	obv := 1000.0
 	adx := 25.0
+	score := 0.85
 
 	entity := &entities.MarketData{
 		ID:                  id,
@@ -225,6 +230,7 @@ func TestMarketData_FromEntity(t *testing.T) {
 		ADXIndex:            &adx,
 		ADXPositive:         &adx,
 		ADXNegative:         &adx,
+		Score:               &score,
 		CreatedAt:           now,
 		UpdatedAt:           now,
 	}
@@ -262,6 +268,7 @@ func TestMarketData_FromEntity(t *testing.T) {
 	assert.Equal(t, &adx, dto.ADXIndex)
 	assert.Equal(t, &adx, dto.ADXPositive)
 	assert.Equal(t, &adx, dto.ADXNegative)
+	assert.Equal(t, &score, dto.Score)
 	assert.Equal(t, now, dto.CreatedAt)
 	assert.Equal(t, now, dto.UpdatedAt)
 }
@@ -316,6 +323,65 @@ func TestMarketData_WithNilPointers(t *testing.T) {
 	assert.Nil(t, entity.ADXIndex)
 	assert.Nil(t, entity.ADXPositive)
 	assert.Nil(t, entity.ADXNegative)
+	assert.Nil(t, entity.Score)
 	assert.Equal(t, now, entity.CreatedAt)
 	assert.Equal(t, now, entity.UpdatedAt)
 }
+
+func TestMarketRegime_ToEntity(t *testing.T) {
+	// Arrange
+	id := uuid.New()
+	now := time.Now()
+
+	dto := &MarketRegime{
+		ID:              id,
+		Regime:          constants.MarketRegimeRiskOff,
+		Breadth:         80,
+		ATRSpike:        2.5,
+		BTCDrawdown:     12,
+		MarketsMeasured: 10,
+		CreatedAt:       now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.NotNil(t, entity)
+	assert.Equal(t, id, entity.ID)
+	assert.Equal(t, constants.MarketRegimeRiskOff, entity.Regime)
+	assert.Equal(t, 80.0, entity.Breadth)
+	assert.Equal(t, 2.5, entity.ATRSpike)
+	assert.Equal(t, 12.0, entity.BTCDrawdown)
+	assert.Equal(t, 10, entity.MarketsMeasured)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestMarketRegime_FromEntity(t *testing.T) {
+	// Arrange
+	id := uuid.New()
+	now := time.Now()
+
+	dto := &MarketRegime{}
+	entity := &entities.MarketRegime{
+		ID:              id,
+		Regime:          constants.MarketRegimeNeutral,
+		Breadth:         50,
+		ATRSpike:        1.2,
+		BTCDrawdown:     7,
+		MarketsMeasured: 8,
+		CreatedAt:       now,
+	}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, id, dto.ID)
+	assert.Equal(t, constants.MarketRegimeNeutral, dto.Regime)
+	assert.Equal(t, 50.0, dto.Breadth)
+	assert.Equal(t, 1.2, dto.ATRSpike)
+	assert.Equal(t, 7.0, dto.BTCDrawdown)
+	assert.Equal(t, 8, dto.MarketsMeasured)
+	assert.Equal(t, now, dto.CreatedAt)
+}
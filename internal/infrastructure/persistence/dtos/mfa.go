@@ -0,0 +1,58 @@
+package dtos
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+)
+
+// Secret is stored encrypted with a data key of the record's own, kept
+// wrapped by the master key it names. Recovery codes are comma separated
+// digests.
+type MFAFactor struct {
+	ID                          uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID                      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex;"`
+	Secret                      string     `gorm:"type:text;not null;"`
+	DataKey                     string     `gorm:"type:text;"`
+	MasterKeyID                 string     `gorm:"type:varchar(50);index;"`
+	RecoveryCodes               string     `gorm:"type:text;"`
+	RequiredForSensitiveActions bool       `gorm:"type:boolean;not null;default:false;"`
+	LastUsedStep                int64      `gorm:"type:bigint;not null;default:0;"`
+	EnabledAt                   *time.Time `gorm:"type:timestamp;"`
+	CreatedAt                   time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt                   time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+// Receivers
+
+func (f *MFAFactor) ToEntity() *entities.MFAFactor {
+	recoveryCodes := []string{}
+	if f.RecoveryCodes != "" {
+		recoveryCodes = strings.Split(f.RecoveryCodes, ",")
+	}
+	return &entities.MFAFactor{
+		ID:                          f.ID,
+		UserID:                      f.UserID,
+		Secret:                      f.Secret,
+		RecoveryCodes:               recoveryCodes,
+		RequiredForSensitiveActions: f.RequiredForSensitiveActions,
+		LastUsedStep:                f.LastUsedStep,
+		EnabledAt:                   f.EnabledAt,
+		CreatedAt:                   f.CreatedAt,
+		UpdatedAt:                   f.UpdatedAt,
+	}
+}
+
+func (f *MFAFactor) FromEntity(factor *entities.MFAFactor) {
+	f.ID = factor.ID
+	f.UserID = factor.UserID
+	f.Secret = factor.Secret
+	f.RecoveryCodes = strings.Join(factor.RecoveryCodes, ",")
+	f.RequiredForSensitiveActions = factor.RequiredForSensitiveActions
+	f.LastUsedStep = factor.LastUsedStep
+	f.EnabledAt = factor.EnabledAt
+	f.CreatedAt = factor.CreatedAt
+	f.UpdatedAt = factor.UpdatedAt
+}
@@ -0,0 +1,73 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFAFactor_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &MFAFactor{
+		ID:                          uuid.New(),
+		UserID:                      uuid.New(),
+		Secret:                      "JBSWY3DPEHPK3PXP",
+		RecoveryCodes:               "first,second",
+		RequiredForSensitiveActions: true,
+		LastUsedStep:                42,
+		EnabledAt:                   &now,
+		CreatedAt:                   now,
+		UpdatedAt:                   now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", entity.Secret)
+	assert.Equal(t, []string{"first", "second"}, entity.RecoveryCodes)
+	assert.True(t, entity.RequiredForSensitiveActions)
+	assert.Equal(t, int64(42), entity.LastUsedStep)
+	assert.Equal(t, &now, entity.EnabledAt)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestMFAFactor_ToEntityWithoutRecoveryCodes(t *testing.T) {
+	// Arrange
+	dto := &MFAFactor{ID: uuid.New()}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Empty(t, entity.RecoveryCodes)
+}
+
+func TestMFAFactor_FromEntity(t *testing.T) {
+	// Arrange
+	factory := &entities.MFAFactorFactory{}
+	entity, err := factory.NewMFAFactor(uuid.New())
+	assert.NoError(t, err)
+	_, err = entity.IssueRecoveryCodes(2)
+	assert.NoError(t, err)
+	dto := &MFAFactor{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, entity.Secret, dto.Secret)
+	assert.Equal(t, entity.RecoveryCodes[0]+","+entity.RecoveryCodes[1], dto.RecoveryCodes)
+	assert.False(t, dto.RequiredForSensitiveActions)
+	assert.Nil(t, dto.EnabledAt)
+	assert.Equal(t, entity.CreatedAt, dto.CreatedAt)
+}
@@ -0,0 +1,267 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type TelegramChat struct {
+	gorm.Model
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex;"`
+	ChatID    int64     `gorm:"type:bigint;not null;uniqueIndex;"`
+	PairedAt  time.Time `gorm:"type:timestamp;not null;"`
+	CreatedAt time.Time `gorm:"type:timestamp;not null;"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null;"`
+}
+
+type PairingCode struct {
+	gorm.Model
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	CodeDigest string     `gorm:"type:varchar(64);not null;uniqueIndex;"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp;not null;"`
+	UsedAt     *time.Time `gorm:"type:timestamp;"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type NotificationChannel struct {
+	gorm.Model
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID      `gorm:"type:uuid;not null;index;"`
+	Type      string         `gorm:"type:varchar(20);not null;"`
+	Target    string         `gorm:"type:varchar(500);"`
+	Secret    string         `gorm:"type:varchar(255);"`
+	Kinds     pq.StringArray `gorm:"type:text[]"`
+	Enabled   bool           `gorm:"type:boolean;not null;default:false;"`
+	CreatedAt time.Time      `gorm:"type:timestamp;not null;"`
+	UpdatedAt time.Time      `gorm:"type:timestamp;not null;"`
+}
+
+type NotificationChannels []NotificationChannel
+
+type NotificationDelivery struct {
+	gorm.Model
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	ChannelID   uuid.UUID  `gorm:"type:uuid;"`
+	ChannelType string     `gorm:"type:varchar(20);not null;"`
+	Kind        string     `gorm:"type:varchar(30);not null;"`
+	Subject     string     `gorm:"type:varchar(255);"`
+	Status      string     `gorm:"type:varchar(20);not null;"`
+	Attempts    int        `gorm:"type:integer;not null;"`
+	Error       string     `gorm:"type:text;"`
+	DeliveredAt *time.Time `gorm:"type:timestamp;"`
+	CreatedAt   time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type NotificationDeliveries []NotificationDelivery
+
+type NotificationPreference struct {
+	gorm.Model
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;"`
+	UserID          uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex;"`
+	Locale          string         `gorm:"type:varchar(5);not null;default:'en';"`
+	Timezone        string         `gorm:"type:varchar(64);not null;default:'UTC';"`
+	Events          pq.StringArray `gorm:"type:text[]"`
+	QuietHoursStart string         `gorm:"type:varchar(5);"`
+	QuietHoursEnd   string         `gorm:"type:varchar(5);"`
+	CreatedAt       time.Time      `gorm:"type:timestamp;not null;"`
+	UpdatedAt       time.Time      `gorm:"type:timestamp;not null;"`
+}
+
+type DigestSchedule struct {
+	gorm.Model
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex;"`
+	Frequency  string     `gorm:"type:varchar(10);not null;"`
+	Time       string     `gorm:"type:varchar(5);not null;"`
+	Weekday    int        `gorm:"type:int;not null;default:0;"`
+	Enabled    bool       `gorm:"type:boolean;not null;default:false;index;"`
+	LastSentAt *time.Time `gorm:"type:timestamp;"`
+	LastValue  float64    `gorm:"type:float;not null;default:0;"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt  time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type DigestSchedules []DigestSchedule
+
+// Receivers
+
+func (c *TelegramChat) ToEntity() *entities.TelegramChat {
+	return &entities.TelegramChat{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		ChatID:    c.ChatID,
+		PairedAt:  c.PairedAt,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+func (c *TelegramChat) FromEntity(chat *entities.TelegramChat) {
+	c.ID = chat.ID
+	c.UserID = chat.UserID
+	c.ChatID = chat.ChatID
+	c.PairedAt = chat.PairedAt
+	c.CreatedAt = chat.CreatedAt
+	c.UpdatedAt = chat.UpdatedAt
+}
+
+func (p *PairingCode) ToEntity() *entities.PairingCode {
+	return &entities.PairingCode{
+		ID:         p.ID,
+		UserID:     p.UserID,
+		CodeDigest: p.CodeDigest,
+		ExpiresAt:  p.ExpiresAt,
+		UsedAt:     p.UsedAt,
+		CreatedAt:  p.CreatedAt,
+	}
+}
+
+func (p *PairingCode) FromEntity(code *entities.PairingCode) {
+	p.ID = code.ID
+	p.UserID = code.UserID
+	p.CodeDigest = code.CodeDigest
+	p.ExpiresAt = code.ExpiresAt
+	p.UsedAt = code.UsedAt
+	p.CreatedAt = code.CreatedAt
+}
+
+func (c *NotificationChannel) ToEntity() *entities.NotificationChannel {
+	return &entities.NotificationChannel{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		Type:      c.Type,
+		Target:    c.Target,
+		Secret:    c.Secret,
+		Kinds:     c.Kinds,
+		Enabled:   c.Enabled,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+func (c *NotificationChannel) FromEntity(channel *entities.NotificationChannel) {
+	c.ID = channel.ID
+	c.UserID = channel.UserID
+	c.Type = channel.Type
+	c.Target = channel.Target
+	c.Secret = channel.Secret
+	c.Kinds = channel.Kinds
+	c.Enabled = channel.Enabled
+	c.CreatedAt = channel.CreatedAt
+	c.UpdatedAt = channel.UpdatedAt
+}
+
+func (c *NotificationChannels) ToEntities() *entities.NotificationChannels {
+	entities := make(entities.NotificationChannels, len(*c))
+	for i, channel := range *c {
+		entities[i] = *channel.ToEntity()
+	}
+	return &entities
+}
+
+func (d *NotificationDelivery) ToEntity() *entities.NotificationDelivery {
+	return &entities.NotificationDelivery{
+		ID:          d.ID,
+		UserID:      d.UserID,
+		ChannelID:   d.ChannelID,
+		ChannelType: d.ChannelType,
+		Kind:        d.Kind,
+		Subject:     d.Subject,
+		Status:      d.Status,
+		Attempts:    d.Attempts,
+		Error:       d.Error,
+		DeliveredAt: d.DeliveredAt,
+		CreatedAt:   d.CreatedAt,
+	}
+}
+
+func (d *NotificationDelivery) FromEntity(delivery *entities.NotificationDelivery) {
+	d.ID = delivery.ID
+	d.UserID = delivery.UserID
+	d.ChannelID = delivery.ChannelID
+	d.ChannelType = delivery.ChannelType
+	d.Kind = delivery.Kind
+	d.Subject = delivery.Subject
+	d.Status = delivery.Status
+	d.Attempts = delivery.Attempts
+	d.Error = delivery.Error
+	d.DeliveredAt = delivery.DeliveredAt
+	d.CreatedAt = delivery.CreatedAt
+}
+
+func (d *NotificationDeliveries) ToEntities() *entities.NotificationDeliveries {
+	entities := make(entities.NotificationDeliveries, len(*d))
+	for i, delivery := range *d {
+		entities[i] = *delivery.ToEntity()
+	}
+	return &entities
+}
+
+func (p *NotificationPreference) ToEntity() *entities.NotificationPreference {
+	return &entities.NotificationPreference{
+		ID:              p.ID,
+		UserID:          p.UserID,
+		Locale:          p.Locale,
+		Timezone:        p.Timezone,
+		Events:          p.Events,
+		QuietHoursStart: p.QuietHoursStart,
+		QuietHoursEnd:   p.QuietHoursEnd,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+}
+
+func (p *NotificationPreference) FromEntity(preference *entities.NotificationPreference) {
+	p.ID = preference.ID
+	p.UserID = preference.UserID
+	p.Locale = preference.Locale
+	p.Timezone = preference.Timezone
+	p.Events = preference.Events
+	p.QuietHoursStart = preference.QuietHoursStart
+	p.QuietHoursEnd = preference.QuietHoursEnd
+	p.CreatedAt = preference.CreatedAt
+	p.UpdatedAt = preference.UpdatedAt
+}
+
+func (d *DigestSchedule) ToEntity() *entities.DigestSchedule {
+	return &entities.DigestSchedule{
+		ID:         d.ID,
+		UserID:     d.UserID,
+		Frequency:  d.Frequency,
+		Time:       d.Time,
+		Weekday:    time.Weekday(d.Weekday),
+		Enabled:    d.Enabled,
+		LastSentAt: d.LastSentAt,
+		LastValue:  d.LastValue,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
+}
+
+func (d *DigestSchedule) FromEntity(schedule *entities.DigestSchedule) {
+	d.ID = schedule.ID
+	d.UserID = schedule.UserID
+	d.Frequency = schedule.Frequency
+	d.Time = schedule.Time
+	d.Weekday = int(schedule.Weekday)
+	d.Enabled = schedule.Enabled
+	d.LastSentAt = schedule.LastSentAt
+	d.LastValue = schedule.LastValue
+	d.CreatedAt = schedule.CreatedAt
+	d.UpdatedAt = schedule.UpdatedAt
+}
+
+func (d *DigestSchedules) ToEntities() *entities.DigestSchedules {
+	entities := make(entities.DigestSchedules, len(*d))
+	for i, schedule := range *d {
+		entities[i] = *schedule.ToEntity()
+	}
+	return &entities
+}
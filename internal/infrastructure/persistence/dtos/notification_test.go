@@ -0,0 +1,302 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelegramChat_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &TelegramChat{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		ChatID:    424242,
+		PairedAt:  now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, int64(424242), entity.ChatID)
+	assert.Equal(t, now, entity.PairedAt)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestTelegramChat_FromEntity(t *testing.T) {
+	// Arrange
+	chatFactory := &entities.TelegramChatFactory{}
+	entity := chatFactory.NewTelegramChat(uuid.New(), 424242)
+	dto := &TelegramChat{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, int64(424242), dto.ChatID)
+	assert.Equal(t, entity.PairedAt, dto.PairedAt)
+}
+
+func TestPairingCode_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &PairingCode{
+		ID:         uuid.New(),
+		UserID:     uuid.New(),
+		CodeDigest: "digest",
+		ExpiresAt:  now.Add(10 * time.Minute),
+		UsedAt:     &now,
+		CreatedAt:  now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, "digest", entity.CodeDigest)
+	assert.Equal(t, dto.ExpiresAt, entity.ExpiresAt)
+	assert.Equal(t, &now, entity.UsedAt)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestPairingCode_FromEntity(t *testing.T) {
+	// Arrange
+	codeFactory := &entities.PairingCodeFactory{}
+	entity := codeFactory.NewPairingCode(uuid.New(), "digest", 10*time.Minute)
+	dto := &PairingCode{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, "digest", dto.CodeDigest)
+	assert.Equal(t, entity.ExpiresAt, dto.ExpiresAt)
+	assert.Nil(t, dto.UsedAt)
+}
+
+func TestNotificationChannel_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &NotificationChannel{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		Type:      "webhook",
+		Target:    "https://example.com/hooks",
+		Secret:    "signing-secret",
+		Kinds:     []string{"trade", "stop_loss"},
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, "webhook", entity.Type)
+	assert.Equal(t, "https://example.com/hooks", entity.Target)
+	assert.Equal(t, "signing-secret", entity.Secret)
+	assert.Equal(t, []string{"trade", "stop_loss"}, entity.Kinds)
+	assert.True(t, entity.Enabled)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestNotificationChannel_FromEntity(t *testing.T) {
+	// Arrange
+	channelFactory := &entities.NotificationChannelFactory{}
+	entity := channelFactory.NewNotificationChannel(uuid.New(), "email", "user@example.com", "", []string{"trade"})
+	dto := &NotificationChannel{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, "email", dto.Type)
+	assert.Equal(t, "user@example.com", dto.Target)
+	assert.Equal(t, []string{"trade"}, []string(dto.Kinds))
+	assert.True(t, dto.Enabled)
+}
+
+func TestNotificationChannels_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := NotificationChannels{
+		{ID: uuid.New(), Type: "email"},
+		{ID: uuid.New(), Type: "slack"},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, dtos[0].ID, (*entities)[0].ID)
+	assert.Equal(t, "slack", (*entities)[1].Type)
+}
+
+func TestNotificationDelivery_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &NotificationDelivery{
+		ID:          uuid.New(),
+		UserID:      uuid.New(),
+		ChannelID:   uuid.New(),
+		ChannelType: "slack",
+		Kind:        "trade",
+		Subject:     "Trade operation executed",
+		Status:      "delivered",
+		Attempts:    2,
+		DeliveredAt: &now,
+		CreatedAt:   now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.ChannelID, entity.ChannelID)
+	assert.Equal(t, "slack", entity.ChannelType)
+	assert.Equal(t, "trade", entity.Kind)
+	assert.Equal(t, "Trade operation executed", entity.Subject)
+	assert.Equal(t, 2, entity.Attempts)
+	assert.Equal(t, &now, entity.DeliveredAt)
+	assert.True(t, entity.IsDelivered())
+}
+
+func TestNotificationDelivery_FromEntity(t *testing.T) {
+	// Arrange
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(uuid.New(), "slack", "https://hooks.slack.com/services/x", "", nil)
+	deliveryFactory := &entities.NotificationDeliveryFactory{}
+	entity := deliveryFactory.NewNotificationDelivery(channel, "trade", "Trade operation executed", 3, assert.AnError)
+	dto := &NotificationDelivery{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, channel.ID, dto.ChannelID)
+	assert.Equal(t, "failed", dto.Status)
+	assert.Equal(t, 3, dto.Attempts)
+	assert.Equal(t, assert.AnError.Error(), dto.Error)
+	assert.Nil(t, dto.DeliveredAt)
+}
+
+func TestNotificationPreference_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &NotificationPreference{
+		ID:              uuid.New(),
+		UserID:          uuid.New(),
+		Locale:          "es",
+		Timezone:        "Europe/Madrid",
+		Events:          []string{"trade"},
+		QuietHoursStart: "22:00",
+		QuietHoursEnd:   "07:00",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, "es", entity.Locale)
+	assert.Equal(t, "Europe/Madrid", entity.Timezone)
+	assert.Equal(t, []string{"trade"}, entity.Events)
+	assert.Equal(t, "22:00", entity.QuietHoursStart)
+	assert.Equal(t, "07:00", entity.QuietHoursEnd)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestNotificationPreference_FromEntity(t *testing.T) {
+	// Arrange
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	entity := preferenceFactory.NewNotificationPreference(uuid.New())
+	entity.QuietHoursStart = "23:30"
+	entity.QuietHoursEnd = "06:00"
+	dto := &NotificationPreference{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, "en", dto.Locale)
+	assert.Equal(t, "UTC", dto.Timezone)
+	assert.Equal(t, "23:30", dto.QuietHoursStart)
+	assert.Equal(t, "06:00", dto.QuietHoursEnd)
+}
+
+func TestDigestSchedule_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &DigestSchedule{
+		ID:         uuid.New(),
+		UserID:     uuid.New(),
+		Frequency:  "weekly",
+		Time:       "08:30",
+		Weekday:    1,
+		Enabled:    true,
+		LastSentAt: &now,
+		LastValue:  1520.5,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, "weekly", entity.Frequency)
+	assert.Equal(t, "08:30", entity.Time)
+	assert.Equal(t, time.Monday, entity.Weekday)
+	assert.True(t, entity.Enabled)
+	assert.Equal(t, &now, entity.LastSentAt)
+	assert.Equal(t, 1520.5, entity.LastValue)
+}
+
+func TestDigestSchedule_FromEntity(t *testing.T) {
+	// Arrange
+	scheduleFactory := &entities.DigestScheduleFactory{}
+	entity := scheduleFactory.NewDigestSchedule(uuid.New(), "daily", "20:00", time.Sunday)
+	dto := &DigestSchedule{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, "daily", dto.Frequency)
+	assert.Equal(t, "20:00", dto.Time)
+	assert.Equal(t, 0, dto.Weekday)
+	assert.True(t, dto.Enabled)
+	assert.Nil(t, dto.LastSentAt)
+}
@@ -0,0 +1,75 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type TradeProposal struct {
+	gorm.Model
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	HoldingID     uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	FromSymbol    string     `gorm:"type:varchar(20);not null;"`
+	ToSymbol      string     `gorm:"type:varchar(20);not null;"`
+	ProposedPrice float64    `gorm:"type:decimal(30,10);not null;"`
+	WalletType    string     `gorm:"type:varchar(20);not null;"`
+	Status        string     `gorm:"type:varchar(10);not null;default:'pending';index;"`
+	DecisionPrice float64    `gorm:"type:decimal(30,10);not null;default:0;"`
+	Reason        string     `gorm:"type:varchar(255);"`
+	ExpiresAt     time.Time  `gorm:"type:timestamp;not null;"`
+	DecidedAt     *time.Time `gorm:"type:timestamp;"`
+	CreatedAt     time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt     time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type TradeProposals []TradeProposal
+
+// Receivers
+
+func (p *TradeProposal) ToEntity() *entities.TradeProposal {
+	return &entities.TradeProposal{
+		ID:            p.ID,
+		UserID:        p.UserID,
+		HoldingID:     p.HoldingID,
+		FromSymbol:    p.FromSymbol,
+		ToSymbol:      p.ToSymbol,
+		ProposedPrice: p.ProposedPrice,
+		WalletType:    p.WalletType,
+		Status:        p.Status,
+		DecisionPrice: p.DecisionPrice,
+		Reason:        p.Reason,
+		ExpiresAt:     p.ExpiresAt,
+		DecidedAt:     p.DecidedAt,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+}
+
+func (p *TradeProposal) FromEntity(proposal *entities.TradeProposal) {
+	p.ID = proposal.ID
+	p.UserID = proposal.UserID
+	p.HoldingID = proposal.HoldingID
+	p.FromSymbol = proposal.FromSymbol
+	p.ToSymbol = proposal.ToSymbol
+	p.ProposedPrice = proposal.ProposedPrice
+	p.WalletType = proposal.WalletType
+	p.Status = proposal.Status
+	p.DecisionPrice = proposal.DecisionPrice
+	p.Reason = proposal.Reason
+	p.ExpiresAt = proposal.ExpiresAt
+	p.DecidedAt = proposal.DecidedAt
+	p.CreatedAt = proposal.CreatedAt
+	p.UpdatedAt = proposal.UpdatedAt
+}
+
+func (p *TradeProposals) ToEntities() *entities.TradeProposals {
+	entities := make(entities.TradeProposals, len(*p))
+	for i, proposal := range *p {
+		entities[i] = *proposal.ToEntity()
+	}
+	return &entities
+}
@@ -0,0 +1,88 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradeProposal_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &TradeProposal{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		HoldingID:     uuid.New(),
+		FromSymbol:    "BTCUSDT",
+		ToSymbol:      "ETHUSDT",
+		ProposedPrice: 3000,
+		WalletType:    "spot",
+		Status:        constants.TradeProposalStatusFailed,
+		DecisionPrice: 3100,
+		Reason:        "price drifted",
+		ExpiresAt:     now.Add(15 * time.Minute),
+		DecidedAt:     &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, dto.HoldingID, entity.HoldingID)
+	assert.Equal(t, "BTCUSDT", entity.FromSymbol)
+	assert.Equal(t, "ETHUSDT", entity.ToSymbol)
+	assert.Equal(t, 3000.0, entity.ProposedPrice)
+	assert.Equal(t, "spot", entity.WalletType)
+	assert.Equal(t, constants.TradeProposalStatusFailed, entity.Status)
+	assert.Equal(t, 3100.0, entity.DecisionPrice)
+	assert.Equal(t, "price drifted", entity.Reason)
+	assert.Equal(t, dto.ExpiresAt, entity.ExpiresAt)
+	assert.Equal(t, &now, entity.DecidedAt)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestTradeProposal_FromEntity(t *testing.T) {
+	// Arrange
+	proposalFactory := &entities.TradeProposalFactory{}
+	entity := proposalFactory.NewTradeProposal(uuid.New(), uuid.New(), "BTCUSDT", "SOLUSDT", 150, "spot", time.Hour)
+	dto := &TradeProposal{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, entity.HoldingID, dto.HoldingID)
+	assert.Equal(t, "BTCUSDT", dto.FromSymbol)
+	assert.Equal(t, "SOLUSDT", dto.ToSymbol)
+	assert.Equal(t, 150.0, dto.ProposedPrice)
+	assert.Equal(t, constants.TradeProposalStatusPending, dto.Status)
+	assert.Equal(t, entity.ExpiresAt, dto.ExpiresAt)
+	assert.Nil(t, dto.DecidedAt)
+}
+
+func TestTradeProposals_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := TradeProposals{
+		{ID: uuid.New(), Status: constants.TradeProposalStatusPending},
+		{ID: uuid.New(), Status: constants.TradeProposalStatusApproved},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, dtos[0].ID, (*entities)[0].ID)
+	assert.Equal(t, constants.TradeProposalStatusApproved, (*entities)[1].Status)
+}
@@ -0,0 +1,66 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	gorm.Model
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	RefreshTokenID uuid.UUID  `gorm:"type:uuid;not null;"`
+	UserAgent      string     `gorm:"type:varchar(255);"`
+	IPAddress      string     `gorm:"type:varchar(45);"`
+	ExpiresAt      time.Time  `gorm:"type:timestamp;not null;"`
+	LastUsedAt     time.Time  `gorm:"type:timestamp;not null;"`
+	RevokedAt      *time.Time `gorm:"type:timestamp;"`
+	RevokedReason  string     `gorm:"type:varchar(30);"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt      time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type Sessions []Session
+
+// Receivers
+
+func (s *Session) ToEntity() *entities.Session {
+	return &entities.Session{
+		ID:             s.ID,
+		UserID:         s.UserID,
+		RefreshTokenID: s.RefreshTokenID,
+		UserAgent:      s.UserAgent,
+		IPAddress:      s.IPAddress,
+		ExpiresAt:      s.ExpiresAt,
+		LastUsedAt:     s.LastUsedAt,
+		RevokedAt:      s.RevokedAt,
+		RevokedReason:  s.RevokedReason,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+func (s *Session) FromEntity(session *entities.Session) {
+	s.ID = session.ID
+	s.UserID = session.UserID
+	s.RefreshTokenID = session.RefreshTokenID
+	s.UserAgent = session.UserAgent
+	s.IPAddress = session.IPAddress
+	s.ExpiresAt = session.ExpiresAt
+	s.LastUsedAt = session.LastUsedAt
+	s.RevokedAt = session.RevokedAt
+	s.RevokedReason = session.RevokedReason
+	s.CreatedAt = session.CreatedAt
+	s.UpdatedAt = session.UpdatedAt
+}
+
+func (s *Sessions) ToEntities() *entities.Sessions {
+	sessions := make(entities.Sessions, len(*s))
+	for i, session := range *s {
+		sessions[i] = *session.ToEntity()
+	}
+	return &sessions
+}
@@ -0,0 +1,81 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &Session{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		RefreshTokenID: uuid.New(),
+		UserAgent:      "test-agent",
+		IPAddress:      "127.0.0.1",
+		ExpiresAt:      now.Add(time.Hour),
+		LastUsedAt:     now,
+		RevokedAt:      &now,
+		RevokedReason:  constants.SessionRevokedLogout,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, dto.RefreshTokenID, entity.RefreshTokenID)
+	assert.Equal(t, "test-agent", entity.UserAgent)
+	assert.Equal(t, "127.0.0.1", entity.IPAddress)
+	assert.Equal(t, now.Add(time.Hour), entity.ExpiresAt)
+	assert.Equal(t, now, entity.LastUsedAt)
+	assert.Equal(t, &now, entity.RevokedAt)
+	assert.Equal(t, constants.SessionRevokedLogout, entity.RevokedReason)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestSession_FromEntity(t *testing.T) {
+	// Arrange
+	sessionFactory := &entities.SessionFactory{}
+	entity := sessionFactory.NewSession(uuid.New(), "test-agent", "127.0.0.1", time.Hour)
+	dto := &Session{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, entity.RefreshTokenID, dto.RefreshTokenID)
+	assert.Equal(t, "test-agent", dto.UserAgent)
+	assert.Equal(t, "127.0.0.1", dto.IPAddress)
+	assert.Equal(t, entity.ExpiresAt, dto.ExpiresAt)
+	assert.Nil(t, dto.RevokedAt)
+	assert.Equal(t, entity.CreatedAt, dto.CreatedAt)
+}
+
+func TestSessions_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := Sessions{
+		{ID: uuid.New(), UserID: uuid.New()},
+		{ID: uuid.New(), UserID: uuid.New()},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, dtos[0].ID, (*entities)[0].ID)
+	assert.Equal(t, dtos[1].ID, (*entities)[1].ID)
+}
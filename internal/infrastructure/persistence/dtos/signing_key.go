@@ -0,0 +1,54 @@
+package dtos
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+)
+
+// PublicKey is stored base64 encoded and PrivateKey sealed by the
+// repository, which is why ToEntity and FromEntity leave the latter alone,
+// along with the data key it is sealed with.
+type SigningKey struct {
+	ID          string     `gorm:"type:varchar(64);primary_key;"`
+	Algorithm   string     `gorm:"type:varchar(20);not null;"`
+	PublicKey   string     `gorm:"type:text;not null;"`
+	PrivateKey  string     `gorm:"type:text;not null;"`
+	DataKey     string     `gorm:"type:text;"`
+	MasterKeyID string     `gorm:"type:varchar(50);index;"`
+	CreatedAt   time.Time  `gorm:"type:timestamp;not null;index;"`
+	RetiredAt   *time.Time `gorm:"type:timestamp;"`
+}
+
+type SigningKeys []SigningKey
+
+// Receivers
+
+func (k *SigningKey) ToEntity() *entities.SigningKey {
+	publicKey, _ := base64.StdEncoding.DecodeString(k.PublicKey)
+	return &entities.SigningKey{
+		ID:        k.ID,
+		Algorithm: k.Algorithm,
+		PublicKey: ed25519.PublicKey(publicKey),
+		CreatedAt: k.CreatedAt,
+		RetiredAt: k.RetiredAt,
+	}
+}
+
+func (k *SigningKey) FromEntity(signingKey *entities.SigningKey) {
+	k.ID = signingKey.ID
+	k.Algorithm = signingKey.Algorithm
+	k.PublicKey = base64.StdEncoding.EncodeToString(signingKey.PublicKey)
+	k.CreatedAt = signingKey.CreatedAt
+	k.RetiredAt = signingKey.RetiredAt
+}
+
+func (k *SigningKeys) ToEntities() *entities.SigningKeys {
+	signingKeys := make(entities.SigningKeys, len(*k))
+	for i, signingKey := range *k {
+		signingKeys[i] = *signingKey.ToEntity()
+	}
+	return &signingKeys
+}
@@ -0,0 +1,72 @@
+package dtos
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKey_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	publicKey := make([]byte, 32)
+	publicKey[0] = 1
+	dto := &SigningKey{
+		ID:         "kid",
+		Algorithm:  constants.SigningAlgorithmEdDSA,
+		PublicKey:  base64.StdEncoding.EncodeToString(publicKey),
+		PrivateKey: "sealed",
+		CreatedAt:  now,
+		RetiredAt:  &now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, "kid", entity.ID)
+	assert.Equal(t, constants.SigningAlgorithmEdDSA, entity.Algorithm)
+	assert.Equal(t, publicKey, []byte(entity.PublicKey))
+	assert.Nil(t, entity.PrivateKey)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, &now, entity.RetiredAt)
+}
+
+func TestSigningKey_FromEntity(t *testing.T) {
+	// Arrange
+	factory := &entities.SigningKeyFactory{}
+	entity, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	dto := &SigningKey{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, constants.SigningAlgorithmEdDSA, dto.Algorithm)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(entity.PublicKey), dto.PublicKey)
+	assert.Empty(t, dto.PrivateKey)
+	assert.Equal(t, entity.CreatedAt, dto.CreatedAt)
+	assert.Nil(t, dto.RetiredAt)
+}
+
+func TestSigningKeys_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := SigningKeys{
+		{ID: "first"},
+		{ID: "second"},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, "first", (*entities)[0].ID)
+	assert.Equal(t, "second", (*entities)[1].ID)
+}
@@ -0,0 +1,127 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type TaxLot struct {
+	gorm.Model
+	ID                uuid.UUID `gorm:"type:uuid;primary_key;"`
+	UserID            uuid.UUID `gorm:"type:uuid;not null;index;"`
+	LedgerEntryID     uuid.UUID `gorm:"type:uuid;"`
+	Asset             string    `gorm:"type:varchar(20);not null;"`
+	Quantity          float64   `gorm:"type:decimal(30,10);not null;"`
+	RemainingQuantity float64   `gorm:"type:decimal(30,10);not null;"`
+	UnitCost          float64   `gorm:"type:decimal(30,10);not null;"`
+	AcquiredAt        time.Time `gorm:"type:timestamp;not null;"`
+	CreatedAt         time.Time `gorm:"type:timestamp;not null;"`
+	UpdatedAt         time.Time `gorm:"type:timestamp;not null;"`
+}
+
+type TaxLots []TaxLot
+
+type TaxLotDisposal struct {
+	gorm.Model
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index;"`
+	LotID         uuid.UUID `gorm:"type:uuid;"`
+	LedgerEntryID uuid.UUID `gorm:"type:uuid;not null;"`
+	Reference     string    `gorm:"type:varchar(64);"`
+	Asset         string    `gorm:"type:varchar(20);not null;"`
+	Method        string    `gorm:"type:varchar(10);not null;"`
+	Quantity      float64   `gorm:"type:decimal(30,10);not null;"`
+	Proceeds      float64   `gorm:"type:decimal(30,10);not null;"`
+	CostBasis     float64   `gorm:"type:decimal(30,10);not null;"`
+	Gain          float64   `gorm:"type:decimal(30,10);not null;"`
+	AcquiredAt    time.Time `gorm:"type:timestamp;"`
+	DisposedAt    time.Time `gorm:"type:timestamp;not null;index;"`
+	CreatedAt     time.Time `gorm:"type:timestamp;not null;"`
+}
+
+type TaxLotDisposals []TaxLotDisposal
+
+// Receivers
+
+func (l *TaxLot) ToEntity() *entities.TaxLot {
+	return &entities.TaxLot{
+		ID:                l.ID,
+		UserID:            l.UserID,
+		LedgerEntryID:     l.LedgerEntryID,
+		Asset:             l.Asset,
+		Quantity:          l.Quantity,
+		RemainingQuantity: l.RemainingQuantity,
+		UnitCost:          l.UnitCost,
+		AcquiredAt:        l.AcquiredAt,
+		CreatedAt:         l.CreatedAt,
+		UpdatedAt:         l.UpdatedAt,
+	}
+}
+
+func (l *TaxLot) FromEntity(lot *entities.TaxLot) {
+	l.ID = lot.ID
+	l.UserID = lot.UserID
+	l.LedgerEntryID = lot.LedgerEntryID
+	l.Asset = lot.Asset
+	l.Quantity = lot.Quantity
+	l.RemainingQuantity = lot.RemainingQuantity
+	l.UnitCost = lot.UnitCost
+	l.AcquiredAt = lot.AcquiredAt
+	l.CreatedAt = lot.CreatedAt
+	l.UpdatedAt = lot.UpdatedAt
+}
+
+func (l *TaxLots) ToEntities() *entities.TaxLots {
+	entities := make(entities.TaxLots, len(*l))
+	for i, lot := range *l {
+		entities[i] = *lot.ToEntity()
+	}
+	return &entities
+}
+
+func (d *TaxLotDisposal) ToEntity() *entities.TaxLotDisposal {
+	return &entities.TaxLotDisposal{
+		ID:            d.ID,
+		UserID:        d.UserID,
+		LotID:         d.LotID,
+		LedgerEntryID: d.LedgerEntryID,
+		Reference:     d.Reference,
+		Asset:         d.Asset,
+		Method:        d.Method,
+		Quantity:      d.Quantity,
+		Proceeds:      d.Proceeds,
+		CostBasis:     d.CostBasis,
+		Gain:          d.Gain,
+		AcquiredAt:    d.AcquiredAt,
+		DisposedAt:    d.DisposedAt,
+		CreatedAt:     d.CreatedAt,
+	}
+}
+
+func (d *TaxLotDisposal) FromEntity(disposal *entities.TaxLotDisposal) {
+	d.ID = disposal.ID
+	d.UserID = disposal.UserID
+	d.LotID = disposal.LotID
+	d.LedgerEntryID = disposal.LedgerEntryID
+	d.Reference = disposal.Reference
+	d.Asset = disposal.Asset
+	d.Method = disposal.Method
+	d.Quantity = disposal.Quantity
+	d.Proceeds = disposal.Proceeds
+	d.CostBasis = disposal.CostBasis
+	d.Gain = disposal.Gain
+	d.AcquiredAt = disposal.AcquiredAt
+	d.DisposedAt = disposal.DisposedAt
+	d.CreatedAt = disposal.CreatedAt
+}
+
+func (d *TaxLotDisposals) ToEntities() *entities.TaxLotDisposals {
+	entities := make(entities.TaxLotDisposals, len(*d))
+	for i, disposal := range *d {
+		entities[i] = *disposal.ToEntity()
+	}
+	return &entities
+}
@@ -0,0 +1,136 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaxLot_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &TaxLot{
+		ID:                uuid.New(),
+		UserID:            uuid.New(),
+		LedgerEntryID:     uuid.New(),
+		Asset:             "BTC",
+		Quantity:          1.5,
+		RemainingQuantity: 0.5,
+		UnitCost:          60000,
+		AcquiredAt:        now,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, dto.LedgerEntryID, entity.LedgerEntryID)
+	assert.Equal(t, "BTC", entity.Asset)
+	assert.Equal(t, 1.5, entity.Quantity)
+	assert.Equal(t, 0.5, entity.RemainingQuantity)
+	assert.Equal(t, 60000.0, entity.UnitCost)
+	assert.Equal(t, now, entity.AcquiredAt)
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+}
+
+func TestTaxLot_FromEntity(t *testing.T) {
+	// Arrange
+	lotFactory := &entities.TaxLotFactory{}
+	entity := lotFactory.NewTaxLot(uuid.New(), uuid.New(), "ETH", 10, 3000, time.Now())
+	dto := &TaxLot{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, entity.LedgerEntryID, dto.LedgerEntryID)
+	assert.Equal(t, "ETH", dto.Asset)
+	assert.Equal(t, 10.0, dto.Quantity)
+	assert.Equal(t, 10.0, dto.RemainingQuantity)
+	assert.Equal(t, 3000.0, dto.UnitCost)
+	assert.Equal(t, entity.AcquiredAt, dto.AcquiredAt)
+}
+
+func TestTaxLotDisposal_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &TaxLotDisposal{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		LotID:         uuid.New(),
+		LedgerEntryID: uuid.New(),
+		Reference:     "quote-1",
+		Asset:         "BTC",
+		Method:        constants.CostBasisMethodFIFO,
+		Quantity:      0.5,
+		Proceeds:      33000,
+		CostBasis:     30000,
+		Gain:          3000,
+		AcquiredAt:    now.AddDate(-1, 0, 0),
+		DisposedAt:    now,
+		CreatedAt:     now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, dto.LotID, entity.LotID)
+	assert.Equal(t, dto.LedgerEntryID, entity.LedgerEntryID)
+	assert.Equal(t, "quote-1", entity.Reference)
+	assert.Equal(t, "BTC", entity.Asset)
+	assert.Equal(t, constants.CostBasisMethodFIFO, entity.Method)
+	assert.Equal(t, 0.5, entity.Quantity)
+	assert.Equal(t, 33000.0, entity.Proceeds)
+	assert.Equal(t, 30000.0, entity.CostBasis)
+	assert.Equal(t, 3000.0, entity.Gain)
+	assert.Equal(t, dto.AcquiredAt, entity.AcquiredAt)
+	assert.Equal(t, now, entity.DisposedAt)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestTaxLotDisposal_FromEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	entity := &entities.TaxLotDisposal{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		LedgerEntryID: uuid.New(),
+		Reference:     "quote-2",
+		Asset:         "ETH",
+		Method:        constants.CostBasisMethodLIFO,
+		Quantity:      2,
+		Proceeds:      6000,
+		CostBasis:     6400,
+		Gain:          -400,
+		DisposedAt:    now,
+		CreatedAt:     now,
+	}
+	dto := &TaxLotDisposal{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, uuid.Nil, dto.LotID)
+	assert.Equal(t, "quote-2", dto.Reference)
+	assert.Equal(t, constants.CostBasisMethodLIFO, dto.Method)
+	assert.Equal(t, 2.0, dto.Quantity)
+	assert.Equal(t, -400.0, dto.Gain)
+	assert.True(t, dto.AcquiredAt.IsZero())
+	assert.Equal(t, now, dto.DisposedAt)
+}
@@ -6,38 +6,63 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"gorm.io/gorm"
 )
 
 type TradingPreference struct {
 	gorm.Model
-	ID                  uuid.UUID      `gorm:"type:uuid;primary_key;"`
-	UserID              uuid.UUID      `gorm:"type:uuid;not null;"`
-	Algorithm           string         `gorm:"type:varchar(20);not null;"`
-	Watchlist           pq.StringArray `gorm:"type:text[]"`
-	Operate             bool           `gorm:"type:boolean;not null;default:false;"`
-	StopLossEnabled     bool           `gorm:"type:boolean;not null;default:false;"`
-	StopLossExitEnabled bool           `gorm:"type:boolean;not null;default:false;"`
-	RiskLevel           string         `gorm:"type:varchar(20);not null;default:'low';"`
-	CreatedAt           time.Time      `gorm:"type:timestamp;not null;"`
-	UpdatedAt           time.Time      `gorm:"type:timestamp;not null;"`
+	ID                          uuid.UUID                     `gorm:"type:uuid;primary_key;"`
+	UserID                      uuid.UUID                     `gorm:"type:uuid;not null;"`
+	Algorithm                   string                        `gorm:"type:varchar(20);not null;"`
+	Watchlist                   pq.StringArray                `gorm:"type:text[]"`
+	Operate                     bool                          `gorm:"type:boolean;not null;default:false;"`
+	StopLossEnabled             bool                          `gorm:"type:boolean;not null;default:false;"`
+	StopLossExitEnabled         bool                          `gorm:"type:boolean;not null;default:false;"`
+	RiskLevel                   string                        `gorm:"type:varchar(20);not null;default:'low';"`
+	CostBasisMethod             string                        `gorm:"type:varchar(10);not null;default:'fifo';"`
+	ExecutionMode               string                        `gorm:"type:varchar(10);not null;default:'live';"`
+	StopLossPercentage          float64                       `gorm:"type:decimal(10,4);not null;default:0;"`
+	TrailingStopATRMultiple     float64                       `gorm:"type:decimal(10,4);not null;default:0;"`
+	TakeProfitLadder            valueobjects.TakeProfitLadder `gorm:"type:text;serializer:json;"`
+	PositionSizingMethod        string                        `gorm:"type:varchar(20);not null;default:'fixed_fraction';"`
+	PositionFraction            float64                       `gorm:"type:decimal(10,4);not null;default:100;"`
+	RiskPerTradePercentage      float64                       `gorm:"type:decimal(10,4);not null;default:1;"`
+	MaxConcurrentHoldings       int                           `gorm:"type:integer;not null;default:1;"`
+	MaxDailyLossPercentage      float64                       `gorm:"type:decimal(10,4);not null;default:0;"`
+	MaxDrawdownPercentage       float64                       `gorm:"type:decimal(10,4);not null;default:0;"`
+	MaxTradesPerDay             int                           `gorm:"type:integer;not null;default:0;"`
+	MaxSymbolExposurePercentage float64                       `gorm:"type:decimal(10,4);not null;default:0;"`
+	RiskOffAction               string                        `gorm:"type:varchar(20);not null;default:'freeze_entries';"`
+	RequireApproval             bool                          `gorm:"type:boolean;not null;default:false;"`
+	ApprovalTimeoutMinutes      int                           `gorm:"type:integer;not null;default:15;"`
+	MaxApprovalPriceDrift       float64                       `gorm:"type:decimal(10,4);not null;default:1;"`
+	PeakEquity                  float64                       `gorm:"type:decimal(30,10);not null;default:0;"`
+	KillSwitchReason            string                        `gorm:"type:varchar(20);"`
+	KillSwitchTriggeredAt       *time.Time                    `gorm:"type:timestamp;"`
+	CreatedAt                   time.Time                     `gorm:"type:timestamp;not null;"`
+	UpdatedAt                   time.Time                     `gorm:"type:timestamp;not null;"`
 }
 
 type TradingPreferences []TradingPreference
 
 type Holding struct {
 	gorm.Model
-	ID         uuid.UUID `gorm:"type:uuid;primary_key;"`
-	UserID     uuid.UUID `gorm:"type:uuid;not null;"`
-	Symbol     string    `gorm:"type:varchar(20);not null;"`
-	Quantity   float64   `gorm:"type:decimal(10,2);not null;"`
-	EntryPrice float64   `gorm:"type:decimal(10,2);not null;"`
-	ExitPrice  float64   `gorm:"type:decimal(10,2);"`
-	Profit     float64   `gorm:"type:decimal(10,2);"`
-	EntryScore float64   `gorm:"type:decimal(10,2);"`
-	Status     string    `gorm:"type:varchar(20);not null;default:'open';"`
-	CreatedAt  time.Time `gorm:"type:timestamp;not null;"`
-	UpdatedAt  time.Time `gorm:"type:timestamp;not null;"`
+	ID                  uuid.UUID `gorm:"type:uuid;primary_key;"`
+	UserID              uuid.UUID `gorm:"type:uuid;not null;"`
+	Symbol              string    `gorm:"type:varchar(20);not null;"`
+	Quantity            float64   `gorm:"type:decimal(10,2);not null;"`
+	EntryPrice          float64   `gorm:"type:decimal(10,2);not null;"`
+	ExitPrice           float64   `gorm:"type:decimal(10,2);"`
+	Profit              float64   `gorm:"type:decimal(10,2);"`
+	EntryScore          float64   `gorm:"type:decimal(10,2);"`
+	Status              string    `gorm:"type:varchar(20);not null;default:'open';"`
+	Book                string    `gorm:"type:varchar(10);not null;default:'live';"`
+	InitialQuantity     float64   `gorm:"type:decimal(30,10);not null;default:0;"`
+	HighWaterMark       float64   `gorm:"type:decimal(30,10);not null;default:0;"`
+	TakeProfitLevelsHit int       `gorm:"type:integer;not null;default:0;"`
+	CreatedAt           time.Time `gorm:"type:timestamp;not null;"`
+	UpdatedAt           time.Time `gorm:"type:timestamp;not null;"`
 }
 
 type Holdings []Holding
@@ -51,6 +76,7 @@ type Order struct {
 	Price     float64   `gorm:"type:decimal(10,2);not null;"`
 	Status    string    `gorm:"type:varchar(20);not null;"`
 	TradeType string    `gorm:"type:varchar(20);not null;"`
+	Book      string    `gorm:"type:varchar(10);not null;default:'live';"`
 	CreatedAt time.Time `gorm:"type:timestamp;not null;"`
 	UpdatedAt time.Time `gorm:"type:timestamp;not null;"`
 }
@@ -61,16 +87,36 @@ type Orders []Order
 
 func (t *TradingPreference) ToEntity() *entities.TradingPreference {
 	return &entities.TradingPreference{
-		ID:                  t.ID,
-		UserID:              t.UserID,
-		Algorithm:           t.Algorithm,
-		Watchlist:           t.Watchlist,
-		Operate:             t.Operate,
-		StopLossEnabled:     t.StopLossEnabled,
-		StopLossExitEnabled: t.StopLossExitEnabled,
-		RiskLevel:           t.RiskLevel,
-		CreatedAt:           t.CreatedAt,
-		UpdatedAt:           t.UpdatedAt,
+		ID:                          t.ID,
+		UserID:                      t.UserID,
+		Algorithm:                   t.Algorithm,
+		Watchlist:                   t.Watchlist,
+		Operate:                     t.Operate,
+		StopLossEnabled:             t.StopLossEnabled,
+		StopLossExitEnabled:         t.StopLossExitEnabled,
+		RiskLevel:                   t.RiskLevel,
+		CostBasisMethod:             t.CostBasisMethod,
+		ExecutionMode:               t.ExecutionMode,
+		StopLossPercentage:          t.StopLossPercentage,
+		TrailingStopATRMultiple:     t.TrailingStopATRMultiple,
+		TakeProfitLadder:            t.TakeProfitLadder,
+		PositionSizingMethod:        t.PositionSizingMethod,
+		PositionFraction:            t.PositionFraction,
+		RiskPerTradePercentage:      t.RiskPerTradePercentage,
+		MaxConcurrentHoldings:       t.MaxConcurrentHoldings,
+		MaxDailyLossPercentage:      t.MaxDailyLossPercentage,
+		MaxDrawdownPercentage:       t.MaxDrawdownPercentage,
+		MaxTradesPerDay:             t.MaxTradesPerDay,
+		MaxSymbolExposurePercentage: t.MaxSymbolExposurePercentage,
+		RiskOffAction:               t.RiskOffAction,
+		RequireApproval:             t.RequireApproval,
+		ApprovalTimeoutMinutes:      t.ApprovalTimeoutMinutes,
+		MaxApprovalPriceDrift:       t.MaxApprovalPriceDrift,
+		PeakEquity:                  t.PeakEquity,
+		KillSwitchReason:            t.KillSwitchReason,
+		KillSwitchTriggeredAt:       t.KillSwitchTriggeredAt,
+		CreatedAt:                   t.CreatedAt,
+		UpdatedAt:                   t.UpdatedAt,
 	}
 }
 
@@ -83,6 +129,26 @@ func (t *TradingPreference) FromEntity(tradingPreference *entities.TradingPrefer
 	t.StopLossEnabled = tradingPreference.StopLossEnabled
 	t.StopLossExitEnabled = tradingPreference.StopLossExitEnabled
 	t.RiskLevel = tradingPreference.RiskLevel
+	t.CostBasisMethod = tradingPreference.CostBasisMethod
+	t.ExecutionMode = tradingPreference.ExecutionMode
+	t.StopLossPercentage = tradingPreference.StopLossPercentage
+	t.TrailingStopATRMultiple = tradingPreference.TrailingStopATRMultiple
+	t.TakeProfitLadder = tradingPreference.TakeProfitLadder
+	t.PositionSizingMethod = tradingPreference.PositionSizingMethod
+	t.PositionFraction = tradingPreference.PositionFraction
+	t.RiskPerTradePercentage = tradingPreference.RiskPerTradePercentage
+	t.MaxConcurrentHoldings = tradingPreference.MaxConcurrentHoldings
+	t.MaxDailyLossPercentage = tradingPreference.MaxDailyLossPercentage
+	t.MaxDrawdownPercentage = tradingPreference.MaxDrawdownPercentage
+	t.MaxTradesPerDay = tradingPreference.MaxTradesPerDay
+	t.MaxSymbolExposurePercentage = tradingPreference.MaxSymbolExposurePercentage
+	t.RiskOffAction = tradingPreference.RiskOffAction
+	t.RequireApproval = tradingPreference.RequireApproval
+	t.ApprovalTimeoutMinutes = tradingPreference.ApprovalTimeoutMinutes
+	t.MaxApprovalPriceDrift = tradingPreference.MaxApprovalPriceDrift
+	t.PeakEquity = tradingPreference.PeakEquity
+	t.KillSwitchReason = tradingPreference.KillSwitchReason
+	t.KillSwitchTriggeredAt = tradingPreference.KillSwitchTriggeredAt
 	t.CreatedAt = tradingPreference.CreatedAt
 	t.UpdatedAt = tradingPreference.UpdatedAt
 }
@@ -97,17 +163,21 @@ func (t *TradingPreferences) ToEntities() *entities.TradingPreferences {
 
 func (h *Holding) ToEntity() *entities.Holding {
 	return &entities.Holding{
-		ID:         h.ID,
-		UserID:     h.UserID,
-		Symbol:     h.Symbol,
-		Quantity:   h.Quantity,
-		EntryPrice: h.EntryPrice,
-		ExitPrice:  h.ExitPrice,
-		Profit:     h.Profit,
-		EntryScore: h.EntryScore,
-		Status:     h.Status,
-		CreatedAt:  h.CreatedAt,
-		UpdatedAt:  h.UpdatedAt,
+		ID:                  h.ID,
+		UserID:              h.UserID,
+		Symbol:              h.Symbol,
+		Quantity:            h.Quantity,
+		EntryPrice:          h.EntryPrice,
+		ExitPrice:           h.ExitPrice,
+		Profit:              h.Profit,
+		EntryScore:          h.EntryScore,
+		Status:              h.Status,
+		Book:                h.Book,
+		InitialQuantity:     h.InitialQuantity,
+		HighWaterMark:       h.HighWaterMark,
+		TakeProfitLevelsHit: h.TakeProfitLevelsHit,
+		CreatedAt:           h.CreatedAt,
+		UpdatedAt:           h.UpdatedAt,
 	}
 }
 
@@ -121,6 +191,10 @@ func (h *Holding) FromEntity(holding *entities.Holding) {
 	h.Profit = holding.Profit
 	h.EntryScore = holding.EntryScore
 	h.Status = holding.Status
+	h.Book = holding.Book
+	h.InitialQuantity = holding.InitialQuantity
+	h.HighWaterMark = holding.HighWaterMark
+	h.TakeProfitLevelsHit = holding.TakeProfitLevelsHit
 	h.CreatedAt = holding.CreatedAt
 	h.UpdatedAt = holding.UpdatedAt
 }
@@ -142,6 +216,7 @@ func (o *Order) ToEntity() *entities.Order {
 		Price:     o.Price,
 		Status:    o.Status,
 		TradeType: o.TradeType,
+		Book:      o.Book,
 		CreatedAt: o.CreatedAt,
 		UpdatedAt: o.UpdatedAt,
 	}
@@ -155,6 +230,7 @@ func (o *Order) FromEntity(order *entities.Order) {
 	o.Price = order.Price
 	o.Status = order.Status
 	o.TradeType = order.TradeType
+	o.Book = order.Book
 	o.CreatedAt = order.CreatedAt
 	o.UpdatedAt = order.UpdatedAt
 }
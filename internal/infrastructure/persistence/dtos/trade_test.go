@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/valueobjects"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,18 +17,33 @@ func TestTradingPreference_ToEntity(t *testing.T) {
 	userId := uuid.New()
 	now := time.Now()
 	watchlist := []string{"BTCUSDT", "ETHUSDT"}
+	ladder := valueobjects.TakeProfitLadder{{TargetPercentage: 10, SellFraction: 0.5}}
 
 	dto := &TradingPreference{
-		ID:                  id,
-		UserID:              userId,
-		Algorithm:           "test-algorithm",
-		Watchlist:           watchlist,
-		Operate:             true,
-		StopLossEnabled:     true,
-		StopLossExitEnabled: true,
-		RiskLevel:           constants.TradingPreferenceRiskLevelLow,
-		CreatedAt:           now,
-		UpdatedAt:           now,
+		ID:                     id,
+		UserID:                 userId,
+		Algorithm:              "test-algorithm",
+		Watchlist:              watchlist,
+		Operate:                true,
+		StopLossEnabled:        true,
+		StopLossExitEnabled:    true,
+		RiskLevel:              constants.TradingPreferenceRiskLevelLow,
+		CostBasisMethod:        constants.CostBasisMethodHIFO,
+		ExecutionMode:          constants.ExecutionModeShadow,
+		StopLossPercentage:     5,
+		TakeProfitLadder:       ladder,
+		PositionSizingMethod:   constants.PositionSizingMethodRiskPerTrade,
+		PositionFraction:       25,
+		MaxConcurrentHoldings:  4,
+		MaxDrawdownPercentage:  20,
+		RiskOffAction:          constants.RiskOffActionExitToQuote,
+		RequireApproval:        true,
+		ApprovalTimeoutMinutes: 30,
+		MaxApprovalPriceDrift:  0.5,
+		KillSwitchReason:       constants.RiskLimitDrawdown,
+		KillSwitchTriggeredAt:  &now,
+		CreatedAt:              now,
+		UpdatedAt:              now,
 	}
 
 	// Act
@@ -43,6 +59,20 @@ func TestTradingPreference_ToEntity(t *testing.T) {
 	assert.True(t, entity.StopLossEnabled)
 	assert.True(t, entity.StopLossExitEnabled)
 	assert.Equal(t, constants.TradingPreferenceRiskLevelLow, entity.RiskLevel)
+	assert.Equal(t, constants.CostBasisMethodHIFO, entity.CostBasisMethod)
+	assert.Equal(t, constants.ExecutionModeShadow, entity.ExecutionMode)
+	assert.Equal(t, 5.0, entity.StopLossPercentage)
+	assert.Equal(t, ladder, entity.TakeProfitLadder)
+	assert.Equal(t, constants.PositionSizingMethodRiskPerTrade, entity.PositionSizingMethod)
+	assert.Equal(t, 25.0, entity.PositionFraction)
+	assert.Equal(t, 4, entity.MaxConcurrentHoldings)
+	assert.Equal(t, 20.0, entity.MaxDrawdownPercentage)
+	assert.Equal(t, constants.RiskOffActionExitToQuote, entity.RiskOffAction)
+	assert.True(t, entity.RequireApproval)
+	assert.Equal(t, 30, entity.ApprovalTimeoutMinutes)
+	assert.Equal(t, 0.5, entity.MaxApprovalPriceDrift)
+	assert.Equal(t, constants.RiskLimitDrawdown, entity.KillSwitchReason)
+	assert.Equal(t, &now, entity.KillSwitchTriggeredAt)
 	assert.Equal(t, now, entity.CreatedAt)
 	assert.Equal(t, now, entity.UpdatedAt)
 }
@@ -53,18 +83,33 @@ func TestTradingPreference_FromEntity(t *testing.T) {
 	userId := uuid.New()
 	now := time.Now()
 	watchlist := []string{"BTCUSDT", "ETHUSDT"}
+	ladder := valueobjects.TakeProfitLadder{{TargetPercentage: 10, SellFraction: 0.5}}
 
 	entity := &entities.TradingPreference{
-		ID:                  id,
-		UserID:              userId,
-		Algorithm:           "test-algorithm",
-		Watchlist:           watchlist,
-		Operate:             true,
-		StopLossEnabled:     true,
-		StopLossExitEnabled: true,
-		RiskLevel:           constants.TradingPreferenceRiskLevelLow,
-		CreatedAt:           now,
-		UpdatedAt:           now,
+		ID:                     id,
+		UserID:                 userId,
+		Algorithm:              "test-algorithm",
+		Watchlist:              watchlist,
+		Operate:                true,
+		StopLossEnabled:        true,
+		StopLossExitEnabled:    true,
+		RiskLevel:              constants.TradingPreferenceRiskLevelLow,
+		CostBasisMethod:        constants.CostBasisMethodHIFO,
+		ExecutionMode:          constants.ExecutionModeShadow,
+		StopLossPercentage:     5,
+		TakeProfitLadder:       ladder,
+		PositionSizingMethod:   constants.PositionSizingMethodRiskPerTrade,
+		PositionFraction:       25,
+		MaxConcurrentHoldings:  4,
+		MaxDrawdownPercentage:  20,
+		RiskOffAction:          constants.RiskOffActionExitToQuote,
+		RequireApproval:        true,
+		ApprovalTimeoutMinutes: 30,
+		MaxApprovalPriceDrift:  0.5,
+		KillSwitchReason:       constants.RiskLimitDrawdown,
+		KillSwitchTriggeredAt:  &now,
+		CreatedAt:              now,
+		UpdatedAt:              now,
 	}
 
 	dto := &TradingPreference{}
@@ -81,6 +126,20 @@ func TestTradingPreference_FromEntity(t *testing.T) {
 	assert.True(t, dto.StopLossEnabled)
 	assert.True(t, dto.StopLossExitEnabled)
 	assert.Equal(t, constants.TradingPreferenceRiskLevelLow, dto.RiskLevel)
+	assert.Equal(t, constants.CostBasisMethodHIFO, dto.CostBasisMethod)
+	assert.Equal(t, constants.ExecutionModeShadow, dto.ExecutionMode)
+	assert.Equal(t, 5.0, dto.StopLossPercentage)
+	assert.Equal(t, ladder, dto.TakeProfitLadder)
+	assert.Equal(t, constants.PositionSizingMethodRiskPerTrade, dto.PositionSizingMethod)
+	assert.Equal(t, 25.0, dto.PositionFraction)
+	assert.Equal(t, 4, dto.MaxConcurrentHoldings)
+	assert.Equal(t, 20.0, dto.MaxDrawdownPercentage)
+	assert.Equal(t, constants.RiskOffActionExitToQuote, dto.RiskOffAction)
+	assert.True(t, dto.RequireApproval)
+	assert.Equal(t, 30, dto.ApprovalTimeoutMinutes)
+	assert.Equal(t, 0.5, dto.MaxApprovalPriceDrift)
+	assert.Equal(t, constants.RiskLimitDrawdown, dto.KillSwitchReason)
+	assert.Equal(t, &now, dto.KillSwitchTriggeredAt)
 	assert.Equal(t, now, dto.CreatedAt)
 	assert.Equal(t, now, dto.UpdatedAt)
 }
@@ -160,16 +219,19 @@ func TestHolding_ToEntity(t *testing.T) {
 	now := time.Now()
 
 	dto := &Holding{
-		ID:         id,
-		UserID:     userId,
-		Symbol:     "BTCUSDT",
-		Quantity:   1.5,
-		EntryPrice: 50000.0,
-		ExitPrice:  51000.0,
-		Profit:     1500.0,
-		Status:     "closed",
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:                  id,
+		UserID:              userId,
+		Symbol:              "BTCUSDT",
+		Quantity:            1.5,
+		EntryPrice:          50000.0,
+		ExitPrice:           51000.0,
+		Profit:              1500.0,
+		InitialQuantity:     3,
+		HighWaterMark:       52000.0,
+		TakeProfitLevelsHit: 2,
+		Status:              "closed",
+		CreatedAt:           now,
+		UpdatedAt:           now,
 	}
 
 	// Act
@@ -184,6 +246,9 @@ func TestHolding_ToEntity(t *testing.T) {
 	assert.Equal(t, 50000.0, entity.EntryPrice)
 	assert.Equal(t, 51000.0, entity.ExitPrice)
 	assert.Equal(t, 1500.0, entity.Profit)
+	assert.Equal(t, 3.0, entity.InitialQuantity)
+	assert.Equal(t, 52000.0, entity.HighWaterMark)
+	assert.Equal(t, 2, entity.TakeProfitLevelsHit)
 	assert.Equal(t, "closed", entity.Status)
 	assert.Equal(t, now, entity.CreatedAt)
 	assert.Equal(t, now, entity.UpdatedAt)
@@ -196,16 +261,20 @@ func TestHolding_FromEntity(t *testing.T) {
 	now := time.Now()
 
 	entity := &entities.Holding{
-		ID:         id,
-		UserID:     userId,
-		Symbol:     "BTCUSDT",
-		Quantity:   1.5,
-		EntryPrice: 50000.0,
-		ExitPrice:  51000.0,
-		Profit:     1500.0,
-		Status:     "closed",
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:                  id,
+		UserID:              userId,
+		Symbol:              "BTCUSDT",
+		Quantity:            1.5,
+		EntryPrice:          50000.0,
+		ExitPrice:           51000.0,
+		Profit:              1500.0,
+		InitialQuantity:     3,
+		HighWaterMark:       52000.0,
+		TakeProfitLevelsHit: 2,
+		Status:              "closed",
+		Book:                constants.ExecutionModeShadow,
+		CreatedAt:           now,
+		UpdatedAt:           now,
 	}
 
 	dto := &Holding{}
@@ -221,7 +290,11 @@ func TestHolding_FromEntity(t *testing.T) {
 	assert.Equal(t, 50000.0, dto.EntryPrice)
 	assert.Equal(t, 51000.0, dto.ExitPrice)
 	assert.Equal(t, 1500.0, dto.Profit)
+	assert.Equal(t, 3.0, dto.InitialQuantity)
+	assert.Equal(t, 52000.0, dto.HighWaterMark)
+	assert.Equal(t, 2, dto.TakeProfitLevelsHit)
 	assert.Equal(t, "closed", dto.Status)
+	assert.Equal(t, constants.ExecutionModeShadow, dto.Book)
 	assert.Equal(t, now, dto.CreatedAt)
 	assert.Equal(t, now, dto.UpdatedAt)
 }
@@ -340,6 +413,7 @@ func TestOrder_FromEntity(t *testing.T) {
 		Price:     50000.0,
 		Status:    "filled",
 		TradeType: "buy",
+		Book:      constants.ExecutionModePaper,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -357,6 +431,7 @@ func TestOrder_FromEntity(t *testing.T) {
 	assert.Equal(t, 50000.0, dto.Price)
 	assert.Equal(t, "filled", dto.Status)
 	assert.Equal(t, "buy", dto.TradeType)
+	assert.Equal(t, constants.ExecutionModePaper, dto.Book)
 	assert.Equal(t, now, dto.CreatedAt)
 	assert.Equal(t, now, dto.UpdatedAt)
 }
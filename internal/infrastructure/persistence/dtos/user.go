@@ -10,17 +10,19 @@ import (
 
 type User struct {
 	gorm.Model
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;"`
-	Password  string    `gorm:"type:varchar(100)"`
-	Email     string    `gorm:"type:varchar(100);unique_index"`
-	FirstName string    `gorm:"type:varchar(100)"`
-	LastName  string    `gorm:"type:varchar(100)"`
-	Role      string    `gorm:"type:varchar(100)"`
-	LastLogin time.Time `gorm:"type:timestamp"`
-	LoggedIn  bool      `gorm:"type:boolean;default:false"`
-	Enabled   bool      `gorm:"type:boolean;default:false"`
-	CreatedAt time.Time `gorm:"type:timestamp;not null;"`
-	UpdatedAt time.Time `gorm:"type:timestamp;not null;"`
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	Password        string     `gorm:"type:varchar(100)"`
+	Email           string     `gorm:"type:varchar(100);unique_index"`
+	FirstName       string     `gorm:"type:varchar(100)"`
+	LastName        string     `gorm:"type:varchar(100)"`
+	Role            string     `gorm:"type:varchar(100)"`
+	LastLogin       time.Time  `gorm:"type:timestamp"`
+	LoggedIn        bool       `gorm:"type:boolean;default:false"`
+	Enabled         bool       `gorm:"type:boolean;default:false"`
+	EmailVerifiedAt *time.Time `gorm:"type:timestamp"`
+	DisabledAt      *time.Time `gorm:"type:timestamp"`
+	CreatedAt       time.Time  `gorm:"type:timestamp;not null;"`
+	UpdatedAt       time.Time  `gorm:"type:timestamp;not null;"`
 }
 
 type Users []User
@@ -29,17 +31,19 @@ type Users []User
 
 func (u *User) ToEntity() *entities.User {
 	return &entities.User{
-		ID:        u.ID,
-		Email:     u.Email,
-		Password:  u.Password,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Role:      u.Role,
-		LastLogin: u.LastLogin,
-		LoggedIn:  u.LoggedIn,
-		Enabled:   u.Enabled,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:              u.ID,
+		Email:           u.Email,
+		Password:        u.Password,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		Role:            u.Role,
+		LastLogin:       u.LastLogin,
+		LoggedIn:        u.LoggedIn,
+		Enabled:         u.Enabled,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		DisabledAt:      u.DisabledAt,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
 	}
 }
 
@@ -53,6 +57,8 @@ func (u *User) FromEntity(user *entities.User) {
 	u.LastLogin = user.LastLogin
 	u.LoggedIn = user.LoggedIn
 	u.Enabled = user.Enabled
+	u.EmailVerifiedAt = user.EmailVerifiedAt
+	u.DisabledAt = user.DisabledAt
 	u.CreatedAt = user.CreatedAt
 	u.UpdatedAt = user.UpdatedAt
 }
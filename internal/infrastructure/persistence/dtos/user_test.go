@@ -16,16 +16,18 @@ func TestUser_ToEntity(t *testing.T) {
 	lastLogin := now.Add(-24 * time.Hour)
 
 	dto := &User{
-		ID:        id,
-		Email:     "test@example.com",
-		Password:  "hashed_password",
-		FirstName: "John",
-		LastName:  "Doe",
-		LastLogin: lastLogin,
-		LoggedIn:  true,
-		Enabled:   true,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:              id,
+		Email:           "test@example.com",
+		Password:        "hashed_password",
+		FirstName:       "John",
+		LastName:        "Doe",
+		LastLogin:       lastLogin,
+		LoggedIn:        true,
+		Enabled:         true,
+		EmailVerifiedAt: &now,
+		DisabledAt:      &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
 	// Act
@@ -41,6 +43,8 @@ func TestUser_ToEntity(t *testing.T) {
 	assert.Equal(t, lastLogin, entity.LastLogin)
 	assert.True(t, entity.LoggedIn)
 	assert.True(t, entity.Enabled)
+	assert.Equal(t, &now, entity.EmailVerifiedAt)
+	assert.Equal(t, &now, entity.DisabledAt)
 	assert.Equal(t, now, entity.CreatedAt)
 	assert.Equal(t, now, entity.UpdatedAt)
 }
@@ -52,16 +56,18 @@ func TestUser_FromEntity(t *testing.T) {
 	lastLogin := now.Add(-24 * time.Hour)
 
 	entity := &entities.User{
-		ID:        id,
-		Email:     "test@example.com",
-		Password:  "hashed_password",
-		FirstName: "John",
-		LastName:  "Doe",
-		LastLogin: lastLogin,
-		LoggedIn:  true,
-		Enabled:   true,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:              id,
+		Email:           "test@example.com",
+		Password:        "hashed_password",
+		FirstName:       "John",
+		LastName:        "Doe",
+		LastLogin:       lastLogin,
+		LoggedIn:        true,
+		Enabled:         true,
+		EmailVerifiedAt: &now,
+		DisabledAt:      &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
 	dto := &User{}
@@ -78,6 +84,8 @@ func TestUser_FromEntity(t *testing.T) {
 	assert.Equal(t, lastLogin, dto.LastLogin)
 	assert.True(t, dto.LoggedIn)
 	assert.True(t, dto.Enabled)
+	assert.Equal(t, &now, dto.EmailVerifiedAt)
+	assert.Equal(t, &now, dto.DisabledAt)
 	assert.Equal(t, now, dto.CreatedAt)
 	assert.Equal(t, now, dto.UpdatedAt)
 }
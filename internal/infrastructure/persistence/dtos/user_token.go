@@ -0,0 +1,54 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"gorm.io/gorm"
+)
+
+type UserToken struct {
+	gorm.Model
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index;"`
+	Purpose     string     `gorm:"type:varchar(30);not null;"`
+	TokenDigest string     `gorm:"type:varchar(64);not null;uniqueIndex;"`
+	ExpiresAt   time.Time  `gorm:"type:timestamp;not null;"`
+	UsedAt      *time.Time `gorm:"type:timestamp;"`
+	CreatedAt   time.Time  `gorm:"type:timestamp;not null;"`
+}
+
+type UserTokens []UserToken
+
+// Receivers
+
+func (t *UserToken) ToEntity() *entities.UserToken {
+	return &entities.UserToken{
+		ID:          t.ID,
+		UserID:      t.UserID,
+		Purpose:     t.Purpose,
+		TokenDigest: t.TokenDigest,
+		ExpiresAt:   t.ExpiresAt,
+		UsedAt:      t.UsedAt,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+func (t *UserToken) FromEntity(token *entities.UserToken) {
+	t.ID = token.ID
+	t.UserID = token.UserID
+	t.Purpose = token.Purpose
+	t.TokenDigest = token.TokenDigest
+	t.ExpiresAt = token.ExpiresAt
+	t.UsedAt = token.UsedAt
+	t.CreatedAt = token.CreatedAt
+}
+
+func (t *UserTokens) ToEntities() *entities.UserTokens {
+	tokens := make(entities.UserTokens, len(*t))
+	for i, token := range *t {
+		tokens[i] = *token.ToEntity()
+	}
+	return &tokens
+}
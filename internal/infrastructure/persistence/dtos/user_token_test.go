@@ -0,0 +1,72 @@
+package dtos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserToken_ToEntity(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	dto := &UserToken{
+		ID:          uuid.New(),
+		UserID:      uuid.New(),
+		Purpose:     constants.UserTokenPurposePasswordReset,
+		TokenDigest: "digest",
+		ExpiresAt:   now.Add(time.Hour),
+		UsedAt:      &now,
+		CreatedAt:   now,
+	}
+
+	// Act
+	entity := dto.ToEntity()
+
+	// Assert
+	assert.Equal(t, dto.ID, entity.ID)
+	assert.Equal(t, dto.UserID, entity.UserID)
+	assert.Equal(t, constants.UserTokenPurposePasswordReset, entity.Purpose)
+	assert.Equal(t, "digest", entity.TokenDigest)
+	assert.Equal(t, now.Add(time.Hour), entity.ExpiresAt)
+	assert.Equal(t, &now, entity.UsedAt)
+	assert.Equal(t, now, entity.CreatedAt)
+}
+
+func TestUserToken_FromEntity(t *testing.T) {
+	// Arrange
+	factory := &entities.UserTokenFactory{}
+	entity := factory.NewUserToken(uuid.New(), constants.UserTokenPurposeEmailVerification, "digest", time.Hour)
+	dto := &UserToken{}
+
+	// Act
+	dto.FromEntity(entity)
+
+	// Assert
+	assert.Equal(t, entity.ID, dto.ID)
+	assert.Equal(t, entity.UserID, dto.UserID)
+	assert.Equal(t, constants.UserTokenPurposeEmailVerification, dto.Purpose)
+	assert.Equal(t, "digest", dto.TokenDigest)
+	assert.Equal(t, entity.ExpiresAt, dto.ExpiresAt)
+	assert.Nil(t, dto.UsedAt)
+	assert.Equal(t, entity.CreatedAt, dto.CreatedAt)
+}
+
+func TestUserTokens_ToEntities(t *testing.T) {
+	// Arrange
+	dtos := UserTokens{
+		{ID: uuid.New(), UserID: uuid.New()},
+		{ID: uuid.New(), UserID: uuid.New()},
+	}
+
+	// Act
+	entities := dtos.ToEntities()
+
+	// Assert
+	assert.Len(t, *entities, 2)
+	assert.Equal(t, dtos[0].ID, (*entities)[0].ID)
+	assert.Equal(t, dtos[1].ID, (*entities)[1].ID)
+}
@@ -3,9 +3,11 @@ package key
 import (
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gorm.io/gorm"
 )
 
@@ -13,12 +15,13 @@ import (
 
 type DefaultKeyRepository struct {
 	Connection *gorm.DB
+	Keyring    *lib.Keyring
 }
 
 // Factories
 
-func NewDefaultKeyRepository(connection *gorm.DB) *DefaultKeyRepository {
-	return &DefaultKeyRepository{Connection: connection}
+func NewDefaultKeyRepository(connection *gorm.DB, keyring *lib.Keyring) *DefaultKeyRepository {
+	return &DefaultKeyRepository{Connection: connection, Keyring: keyring}
 }
 
 // KeyRepository implementation
@@ -32,7 +35,7 @@ func (d *DefaultKeyRepository) GetByID(
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return key.ToEntity(), nil
+	return d.open(&key)
 }
 
 func (d *DefaultKeyRepository) GetAll(
@@ -48,7 +51,57 @@ func (d *DefaultKeyRepository) GetAll(
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return instances.ToEntities(), nil
+	return d.openAll(ctx, &instances), nil
+}
+
+// GetStale returns up to limit keys that are still plaintext or encrypted
+// with a previous master key. Keys of master keys no longer in the keyring
+// can't be read, so they are left out rather than failing every batch, and so
+// are rows that don't decrypt. Rows are walked in ID order past the ones left
+// out, so they never starve the readable ones.
+func (d *DefaultKeyRepository) GetStale(
+	ctx echo.Context,
+	limit int,
+) (*entities.ApiKeys, error) {
+	apiKeys := make(entities.ApiKeys, 0, limit)
+	// The empty ID matches plaintext rows and keeps the list from being empty
+	previousIDs := append(d.Keyring.PreviousIDs(), "")
+	var after *uuid.UUID
+	for len(apiKeys) < limit {
+		instances := dtos.ApiKeys{}
+		query := d.Connection.Where("master_key_id IS NULL OR master_key_id IN ?", previousIDs)
+		if after != nil {
+			query = query.Where("id > ?", *after)
+		}
+		result := query.Order("id asc").Limit(limit - len(apiKeys)).Find(&instances)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if len(instances) == 0 {
+			break
+		}
+		apiKeys = append(apiKeys, *d.openAll(ctx, &instances)...)
+		after = &instances[len(instances)-1].ID
+	}
+	return &apiKeys, nil
+}
+
+// GetLatestByService returns the most recently created key the user has for
+// the service.
+func (d *DefaultKeyRepository) GetLatestByService(
+	ctx echo.Context,
+	userID uuid.UUID,
+	service string,
+) (*entities.ApiKey, error) {
+	var key dtos.ApiKey
+	result := d.Connection.
+		Where("user_id = ? AND service = ?", userID, service).
+		Order("created_at desc").
+		First(&key)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return d.open(&key)
 }
 
 func (d *DefaultKeyRepository) Create(
@@ -57,11 +110,15 @@ func (d *DefaultKeyRepository) Create(
 ) (*entities.ApiKey, error) {
 	instance := dtos.ApiKey{}
 	instance.FromEntity(apiKey)
+	err := d.seal(&instance)
+	if err != nil {
+		return nil, err
+	}
 	result := d.Connection.Create(&instance)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return instance.ToEntity(), nil
+	return d.open(&instance)
 }
 
 func (d *DefaultKeyRepository) Update(
@@ -70,11 +127,15 @@ func (d *DefaultKeyRepository) Update(
 ) (*entities.ApiKey, error) {
 	instance := dtos.ApiKey{}
 	instance.FromEntity(apiKey)
+	err := d.seal(&instance)
+	if err != nil {
+		return nil, err
+	}
 	result := d.Connection.Save(&instance)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return instance.ToEntity(), nil
+	return d.open(&instance)
 }
 
 func (d *DefaultKeyRepository) Delete(ctx echo.Context, id uuid.UUID) error {
@@ -88,3 +149,64 @@ func (d *DefaultKeyRepository) Delete(ctx echo.Context, id uuid.UUID) error {
 	}
 	return nil
 }
+
+// Helpers
+
+// seal encrypts the key and secret with a new data key. The record ID is
+// authenticated along, so ciphertexts can't be swapped between rows.
+func (d *DefaultKeyRepository) seal(instance *dtos.ApiKey) error {
+	dataKey, wrapped, err := d.Keyring.NewDataKey()
+	if err != nil {
+		return err
+	}
+	key, err := lib.Seal(dataKey, []byte(instance.Key), instance.ID[:])
+	if err != nil {
+		return err
+	}
+	secret, err := lib.Seal(dataKey, []byte(instance.Secret), instance.ID[:])
+	if err != nil {
+		return err
+	}
+	instance.Key = key
+	instance.Secret = secret
+	instance.DataKey = wrapped
+	instance.MasterKeyID = d.Keyring.CurrentID()
+	return nil
+}
+
+func (d *DefaultKeyRepository) open(instance *dtos.ApiKey) (*entities.ApiKey, error) {
+	apiKey := instance.ToEntity()
+	if instance.MasterKeyID == "" {
+		return apiKey, nil
+	}
+	dataKey, err := d.Keyring.UnwrapDataKey(instance.MasterKeyID, instance.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := lib.Open(dataKey, instance.Key, instance.ID[:])
+	if err != nil {
+		return nil, err
+	}
+	secret, err := lib.Open(dataKey, instance.Secret, instance.ID[:])
+	if err != nil {
+		return nil, err
+	}
+	apiKey.Key = string(key)
+	apiKey.Secret = string(secret)
+	return apiKey, nil
+}
+
+// openAll opens the rows, logging and leaving out the ones that can't be
+// decrypted so a single bad row doesn't hide the rest.
+func (d *DefaultKeyRepository) openAll(ctx echo.Context, instances *dtos.ApiKeys) *entities.ApiKeys {
+	apiKeys := make(entities.ApiKeys, 0, len(*instances))
+	for i := range *instances {
+		apiKey, err := d.open(&(*instances)[i])
+		if err != nil {
+			config.GetLoggerFromContext(ctx).Errorf("Error opening api key %s: %s", (*instances)[i].ID, err)
+			continue
+		}
+		apiKeys = append(apiKeys, *apiKey)
+	}
+	return &apiKeys
+}
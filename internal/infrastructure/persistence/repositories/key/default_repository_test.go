@@ -1,14 +1,18 @@
 package key
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -312,3 +316,202 @@ func TestCreateApiKeyWithDifferentUsers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(*user2Keys))
 }
+
+func TestCreateApiKeyEncryptsSecretsAtRest(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+
+	// Act
+	createdApiKey, err := keyRepository.Create(ctx, apiKey)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "test-api-key", createdApiKey.Key)
+	assert.Equal(t, "test-api-secret", createdApiKey.Secret)
+	stored := dtos.ApiKey{}
+	database.Where("id = ?", apiKey.ID).First(&stored)
+	assert.Equal(t, "test", stored.MasterKeyID)
+	assert.NotEmpty(t, stored.DataKey)
+	assert.NotContains(t, stored.Key, "test-api-key")
+	assert.NotContains(t, stored.Secret, "test-api-secret")
+	foundApiKey, err := keyRepository.GetByID(ctx, apiKey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-api-key", foundApiKey.Key)
+	assert.Equal(t, "test-api-secret", foundApiKey.Secret)
+}
+
+func TestGetApiKeyByIDReturnsErrorIfMasterKeyUnknown(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	apiKey := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+	_, err := keyRepository.Create(ctx, apiKey)
+	assert.NoError(t, err)
+	database.Model(&dtos.ApiKey{}).Where("id = ?", apiKey.ID).Update("master_key_id", "retired")
+
+	// Act
+	_, err = keyRepository.GetByID(ctx, apiKey.ID)
+
+	// Assert
+	assert.Equal(t, errors.ErrEncryptionKeyUnknown, err)
+}
+
+func TestGetStaleApiKeysReturnsPlaintextAndRotatedKeys(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	plaintext := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"plain-api-key",
+		"plain-api-secret",
+	)
+	dto := dtos.ApiKey{}
+	dto.FromEntity(plaintext)
+	database.Create(&dto)
+	encrypted := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"test-api-key",
+		"test-api-secret",
+	)
+	_, err := keyRepository.Create(ctx, encrypted)
+	assert.NoError(t, err)
+	keyring, err := lib.NewKeyring("next", map[string][]byte{
+		"next": []byte("abcdef0123456789abcdef0123456789"),
+		"test": testMasterKey,
+	})
+	assert.NoError(t, err)
+	rotatedRepository := NewDefaultKeyRepository(database, keyring)
+
+	// Act
+	stale, err := rotatedRepository.GetStale(ctx, 1000)
+
+	// Assert
+	assert.NoError(t, err)
+	found := map[uuid.UUID]entities.ApiKey{}
+	for _, apiKey := range *stale {
+		found[apiKey.ID] = apiKey
+	}
+	assert.Equal(t, "plain-api-key", found[plaintext.ID].Key)
+	assert.Equal(t, "test-api-secret", found[encrypted.ID].Secret)
+	rewritten := found[encrypted.ID]
+	_, err = rotatedRepository.Update(ctx, &rewritten)
+	assert.NoError(t, err)
+	stored := dtos.ApiKey{}
+	database.Where("id = ?", encrypted.ID).First(&stored)
+	assert.Equal(t, "next", stored.MasterKeyID)
+}
+
+func TestGetStaleApiKeysSkipsRowsThatDontDecrypt(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	corrupted := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"corrupted-api-key",
+		"corrupted-api-secret",
+	)
+	readable := apiKeyFactory.NewApiKey(
+		uuid.New(),
+		constants.ApiKeyServiceTypeBinance,
+		"readable-api-key",
+		"readable-api-secret",
+	)
+	for _, apiKey := range []*entities.ApiKey{corrupted, readable} {
+		_, err := keyRepository.Create(ctx, apiKey)
+		assert.NoError(t, err)
+	}
+	database.Model(&dtos.ApiKey{}).Where("id = ?", corrupted.ID).Update("secret", "not-a-sealed-secret")
+	keyring, err := lib.NewKeyring("next", map[string][]byte{
+		"next": []byte("abcdef0123456789abcdef0123456789"),
+		"test": testMasterKey,
+	})
+	assert.NoError(t, err)
+	rotatedRepository := NewDefaultKeyRepository(database, keyring)
+
+	// Act
+	stale, err := rotatedRepository.GetStale(ctx, 1000)
+
+	// Assert
+	assert.NoError(t, err)
+	found := map[uuid.UUID]entities.ApiKey{}
+	for _, apiKey := range *stale {
+		found[apiKey.ID] = apiKey
+	}
+	assert.NotContains(t, found, corrupted.ID)
+	assert.Equal(t, "readable-api-secret", found[readable.ID].Secret)
+}
+
+func TestGetStaleApiKeysWalksPastRowsThatDontDecrypt(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	keyring, err := lib.NewKeyring("retired", map[string][]byte{
+		"retired": []byte("0123456789abcdef0123456789abcdef"),
+	})
+	assert.NoError(t, err)
+	retiredRepository := NewDefaultKeyRepository(database, keyring)
+	// The lowest IDs, so the bad rows come first
+	for i, key := range []string{"first-api-key", "second-api-key"} {
+		apiKey := apiKeyFactory.NewApiKey(uuid.New(), constants.ApiKeyServiceTypeBinance, key, "api-secret")
+		apiKey.ID = uuid.MustParse(fmt.Sprintf("00000000-0000-4000-8000-%012d", i+1))
+		_, err = retiredRepository.Create(ctx, apiKey)
+		assert.NoError(t, err)
+		database.Model(&dtos.ApiKey{}).Where("id = ?", apiKey.ID).Update("secret", "not-a-sealed-secret")
+	}
+	readable := apiKeyFactory.NewApiKey(uuid.New(), constants.ApiKeyServiceTypeBinance, "readable-api-key", "api-secret")
+	_, err = retiredRepository.Create(ctx, readable)
+	assert.NoError(t, err)
+	keyring, err = lib.NewKeyring("newer", map[string][]byte{
+		"newer":   []byte("abcdef0123456789abcdef0123456789"),
+		"retired": []byte("0123456789abcdef0123456789abcdef"),
+	})
+	assert.NoError(t, err)
+	rotatedRepository := NewDefaultKeyRepository(database, keyring)
+
+	// Act
+	stale, err := rotatedRepository.GetStale(ctx, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *stale, 1)
+	assert.NotEqual(t, "00000000-0000-4000-8000", (*stale)[0].ID.String()[:23])
+}
+
+func TestGetLatestApiKeyByServiceReturnsNewestKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	apiKeyFactory := &entities.ApiKeyFactory{}
+	older := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeBinance, "old-api-key", "old-api-secret")
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	_, err := keyRepository.Create(ctx, older)
+	assert.NoError(t, err)
+	latest := apiKeyFactory.NewApiKey(userID, constants.ApiKeyServiceTypeBinance, "test-api-key", "test-api-secret")
+	_, err = keyRepository.Create(ctx, latest)
+	assert.NoError(t, err)
+
+	// Act
+	foundApiKey, err := keyRepository.GetLatestByService(ctx, userID, constants.ApiKeyServiceTypeBinance)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, latest.ID, foundApiKey.ID)
+	assert.Equal(t, "test-api-secret", foundApiKey.Secret)
+	_, err = keyRepository.GetLatestByService(ctx, userID, constants.ApiKeyServiceTypeTelegram)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
@@ -13,4 +13,6 @@ type KeyRepository interface {
 	Create(ctx echo.Context, apiKey *entities.ApiKey) (*entities.ApiKey, error)
 	Update(ctx echo.Context, apiKey *entities.ApiKey) (*entities.ApiKey, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
+	GetStale(ctx echo.Context, limit int) (*entities.ApiKeys, error)
+	GetLatestByService(ctx echo.Context, userID uuid.UUID, service string) (*entities.ApiKey, error)
 }
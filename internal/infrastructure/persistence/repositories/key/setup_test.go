@@ -7,10 +7,13 @@ import (
 	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gorm.io/gorm"
 )
 
 var (
+	// Master key of the test keyring, known so tests can check the rows
+	testMasterKey = []byte("0123456789abcdef0123456789abcdef")
 	database      *gorm.DB
 	keyRepository *DefaultKeyRepository
 )
@@ -27,7 +30,11 @@ func TestMain(m *testing.M) {
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
 	logger.Info("Migrations completed. Running tests...")
-	keyRepository = NewDefaultKeyRepository(database)
+	keyring, err := lib.NewKeyring("test", map[string][]byte{"test": testMasterKey})
+	if err != nil {
+		logger.Fatalf("Error building test keyring: %s", err)
+	}
+	keyRepository = NewDefaultKeyRepository(database, keyring)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
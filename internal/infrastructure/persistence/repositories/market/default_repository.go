@@ -19,6 +19,14 @@ type DefaultMarketDataRepository struct {
 	Connection *gorm.DB
 }
 
+type DefaultMarketRegimeRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultAlertRuleRepository struct {
+	Connection *gorm.DB
+}
+
 // Factories
 
 func NewDefaultMarketRepository(connection *gorm.DB) *DefaultMarketRepository {
@@ -29,6 +37,14 @@ func NewDefaultMarketDataRepository(connection *gorm.DB) *DefaultMarketDataRepos
 	return &DefaultMarketDataRepository{Connection: connection}
 }
 
+func NewDefaultMarketRegimeRepository(connection *gorm.DB) *DefaultMarketRegimeRepository {
+	return &DefaultMarketRegimeRepository{Connection: connection}
+}
+
+func NewDefaultAlertRuleRepository(connection *gorm.DB) *DefaultAlertRuleRepository {
+	return &DefaultAlertRuleRepository{Connection: connection}
+}
+
 // MarketRepository implementation
 
 func (d *DefaultMarketRepository) GetByID(
@@ -129,10 +145,12 @@ func (d *DefaultMarketDataRepository) GetAll(
 ) (*entities.MarketDatas, error) {
 	instances := dtos.MarketDatas{}
 	query := filters.QueryFromFilter(d.Connection)
-	result := query.Find(&instances).
+	// Market data piles up by the minute, so the window is applied in the query
+	result := query.
 		Order(filters.GetOrdering()).
 		Offset(filters.GetPagination().Page).
-		Limit(filters.GetPagination().PageSize)
+		Limit(filters.GetPagination().PageSize).
+		Find(&instances)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -176,3 +194,142 @@ func (d *DefaultMarketDataRepository) Delete(ctx echo.Context, id uuid.UUID) err
 	}
 	return nil
 }
+
+// MarketRegimeRepository implementation
+
+func (d *DefaultMarketRegimeRepository) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.MarketRegime, error) {
+	var regime dtos.MarketRegime
+	result := d.Connection.Where("id = ?", id).First(&regime)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return regime.ToEntity(), nil
+}
+
+func (d *DefaultMarketRegimeRepository) GetLatest(
+	ctx echo.Context,
+) (*entities.MarketRegime, error) {
+	var regime dtos.MarketRegime
+	result := d.Connection.Order("created_at desc").First(&regime)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return regime.ToEntity(), nil
+}
+
+func (d *DefaultMarketRegimeRepository) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.MarketRegimes, error) {
+	instances := dtos.MarketRegimes{}
+	query := filters.QueryFromFilter(d.Connection)
+	result := query.
+		Order(filters.GetOrdering()).
+		Offset(filters.GetPagination().Page).
+		Limit(filters.GetPagination().PageSize).
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultMarketRegimeRepository) Create(
+	ctx echo.Context,
+	regime *entities.MarketRegime,
+) (*entities.MarketRegime, error) {
+	instance := dtos.MarketRegime{}
+	instance.FromEntity(regime)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// AlertRuleRepository implementation
+
+func (d *DefaultAlertRuleRepository) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.AlertRule, error) {
+	var rule dtos.AlertRule
+	result := d.Connection.Where("id = ?", id).First(&rule)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rule.ToEntity(), nil
+}
+
+func (d *DefaultAlertRuleRepository) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.AlertRules, error) {
+	instances := dtos.AlertRules{}
+	query := filters.QueryFromFilter(d.Connection)
+	result := query.
+		Order(filters.GetOrdering()).
+		Offset(filters.GetPagination().Page).
+		Limit(filters.GetPagination().PageSize).
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultAlertRuleRepository) GetEnabledBySymbol(
+	ctx echo.Context,
+	symbol string,
+) (*entities.AlertRules, error) {
+	instances := dtos.AlertRules{}
+	result := d.Connection.
+		Where("symbol = ? AND enabled = ?", symbol, true).
+		Order("created_at asc").
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultAlertRuleRepository) Create(
+	ctx echo.Context,
+	rule *entities.AlertRule,
+) (*entities.AlertRule, error) {
+	instance := dtos.AlertRule{}
+	instance.FromEntity(rule)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultAlertRuleRepository) Update(
+	ctx echo.Context,
+	rule *entities.AlertRule,
+) (*entities.AlertRule, error) {
+	instance := dtos.AlertRule{}
+	instance.FromEntity(rule)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultAlertRuleRepository) Delete(ctx echo.Context, id uuid.UUID) error {
+	_, err := d.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	result := d.Connection.Delete(&dtos.AlertRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
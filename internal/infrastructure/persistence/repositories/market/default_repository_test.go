@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
@@ -435,8 +436,44 @@ func TestGetAllMarketDataReturnsMarketData(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, foundMarketData)
 	assert.Equal(t, 2, len(*foundMarketData))
-	assert.Equal(t, marketData1.ID, (*foundMarketData)[0].ID)
-	assert.Equal(t, marketData2.ID, (*foundMarketData)[1].ID)
+	assert.Equal(t, marketData2.ID, (*foundMarketData)[0].ID)
+	assert.Equal(t, marketData1.ID, (*foundMarketData)[1].ID)
+}
+
+func TestGetAllMarketDataKeepsTheOrderedWindow(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	marketDataFactory := &entities.MarketDataFactory{}
+	start := time.Now().UTC().Add(-time.Hour)
+	ids := make([]uuid.UUID, 3)
+	for i := range ids {
+		marketData := marketDataFactory.NewRawMarketData(
+			uuid.New(),
+			"WNDWUSDT",
+			start.Add(time.Duration(i)*time.Minute),
+			100,
+			100,
+			100,
+			100,
+			1000,
+		)
+		dto := dtos.MarketData{}
+		dto.FromEntity(marketData)
+		database.Create(&dto)
+		ids[i] = marketData.ID
+	}
+
+	// Act
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"symbol": "WNDWUSDT",
+	}, "timestamp", "desc", 0, 2)
+	foundMarketData, err := marketDataRepository.GetAll(ctx, filters)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*foundMarketData))
+	assert.Equal(t, ids[2], (*foundMarketData)[0].ID)
+	assert.Equal(t, ids[1], (*foundMarketData)[1].ID)
 }
 
 func TestGetAllMarketDataWithUnmatchingSymbolReturnsEmpty(t *testing.T) {
@@ -632,3 +669,153 @@ func TestDeleteMarketDataDeletesMarketData(t *testing.T) {
 	assert.Equal(t, gorm.ErrRecordNotFound, err)
 	assert.Nil(t, foundMarketData)
 }
+
+// Market regime repository tests
+
+func TestGetMarketRegimeByIDReturnsError(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := regimeRepository.GetByID(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestCreateMarketRegimeReturnsMarketRegime(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MarketRegimeFactory{}
+	regime := factory.NewMarketRegime(constants.MarketRegimeRiskOff, 80, 2.5, 18, 12)
+
+	// Act
+	created, err := regimeRepository.Create(ctx, regime)
+	found, findErr := regimeRepository.GetByID(ctx, regime.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, findErr)
+	assert.Equal(t, regime.ID, created.ID)
+	assert.Equal(t, constants.MarketRegimeRiskOff, found.Regime)
+	assert.Equal(t, 80.0, found.Breadth)
+	assert.Equal(t, 2.5, found.ATRSpike)
+	assert.Equal(t, 18.0, found.BTCDrawdown)
+	assert.Equal(t, 12, found.MarketsMeasured)
+}
+
+func TestGetLatestMarketRegimeReturnsMostRecent(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MarketRegimeFactory{}
+	older := factory.NewMarketRegime(constants.MarketRegimeRiskOn, 20, 1, 2, 10)
+	older.CreatedAt = time.Now().UTC().Add(time.Hour)
+	newer := factory.NewMarketRegime(constants.MarketRegimeNeutral, 50, 1.5, 8, 10)
+	newer.CreatedAt = time.Now().UTC().Add(2 * time.Hour)
+	_, err := regimeRepository.Create(ctx, newer)
+	assert.NoError(t, err)
+	_, err = regimeRepository.Create(ctx, older)
+	assert.NoError(t, err)
+
+	// Act
+	latest, err := regimeRepository.GetLatest(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, newer.ID, latest.ID)
+	assert.Equal(t, constants.MarketRegimeNeutral, latest.Regime)
+}
+
+func TestGetAllMarketRegimesFiltersByRegime(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MarketRegimeFactory{}
+	regime := factory.NewMarketRegime(constants.MarketRegimeRiskOff, 90, 3, 25, 10)
+	regime.CreatedAt = time.Now().UTC().Add(-48 * time.Hour)
+	_, err := regimeRepository.Create(ctx, regime)
+	assert.NoError(t, err)
+
+	// Act
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"regime":         constants.MarketRegimeRiskOff,
+		"created_at__lt": time.Now().UTC().Add(-24 * time.Hour),
+	}, "created_at", "desc", 0, 10)
+	regimes, err := regimeRepository.GetAll(ctx, filters)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *regimes, 1)
+	assert.Equal(t, regime.ID, (*regimes)[0].ID)
+}
+
+func TestGetAllMarketRegimesKeepsTheOrderedWindow(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MarketRegimeFactory{}
+	start := time.Now().UTC().Add(-100 * 24 * time.Hour)
+	ids := make([]uuid.UUID, 3)
+	for i := range ids {
+		regime := factory.NewMarketRegime(constants.MarketRegimeNeutral, 50, 1, 5, 10)
+		regime.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		_, err := regimeRepository.Create(ctx, regime)
+		assert.NoError(t, err)
+		ids[i] = regime.ID
+	}
+
+	// Act
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"created_at__gt": start.Add(-time.Minute),
+		"created_at__lt": start.Add(time.Hour),
+	}, "created_at", "desc", 0, 2)
+	regimes, err := regimeRepository.GetAll(ctx, filters)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *regimes, 2)
+	assert.Equal(t, ids[2], (*regimes)[0].ID)
+	assert.Equal(t, ids[1], (*regimes)[1].ID)
+}
+
+// Alert rule repository tests
+
+func TestGetEnabledAlertRulesBySymbolSkipsDisabled(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.AlertRuleFactory{}
+	enabled := factory.NewAlertRule(uuid.New(), "ALERTAUSDT", constants.AlertFieldScore, constants.AlertConditionAbove, 0.8, 60)
+	disabled := factory.NewAlertRule(uuid.New(), "ALERTAUSDT", constants.AlertFieldRSI6, constants.AlertConditionBelow, 20, 0)
+	disabled.Enabled = false
+	other := factory.NewAlertRule(uuid.New(), "ALERTBUSDT", constants.AlertFieldClose, constants.AlertConditionAbove, 100, 0)
+	for _, rule := range []*entities.AlertRule{enabled, disabled, other} {
+		_, err := alertRuleRepository.Create(ctx, rule)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	rules, err := alertRuleRepository.GetEnabledBySymbol(ctx, "ALERTAUSDT")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *rules, 1)
+	assert.Equal(t, enabled.ID, (*rules)[0].ID)
+}
+
+func TestUpdateAlertRuleStoresLastObservation(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.AlertRuleFactory{}
+	rule := factory.NewAlertRule(uuid.New(), "ALERTCUSDT", constants.AlertFieldClose, constants.AlertConditionAbove, 100, 0)
+	_, err := alertRuleRepository.Create(ctx, rule)
+	assert.NoError(t, err)
+	rule.Observe(90, time.Now().UTC())
+
+	// Act
+	_, err = alertRuleRepository.Update(ctx, rule)
+	found, findErr := alertRuleRepository.GetByID(ctx, rule.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, findErr)
+	assert.Equal(t, 90.0, *found.LastValue)
+	assert.Nil(t, found.LastTriggeredAt)
+}
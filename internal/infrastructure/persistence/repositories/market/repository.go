@@ -23,3 +23,19 @@ type MarketDataRepository interface {
 	Update(ctx echo.Context, market *entities.MarketData) (*entities.MarketData, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
 }
+
+type MarketRegimeRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.MarketRegime, error)
+	GetLatest(ctx echo.Context) (*entities.MarketRegime, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.MarketRegimes, error)
+	Create(ctx echo.Context, regime *entities.MarketRegime) (*entities.MarketRegime, error)
+}
+
+type AlertRuleRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.AlertRule, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.AlertRules, error)
+	GetEnabledBySymbol(ctx echo.Context, symbol string) (*entities.AlertRules, error)
+	Create(ctx echo.Context, rule *entities.AlertRule) (*entities.AlertRule, error)
+	Update(ctx echo.Context, rule *entities.AlertRule) (*entities.AlertRule, error)
+	Delete(ctx echo.Context, id uuid.UUID) error
+}
@@ -14,6 +14,8 @@ var (
 	database             *gorm.DB
 	marketRepository     MarketRepository
 	marketDataRepository MarketDataRepository
+	regimeRepository     MarketRegimeRepository
+	alertRuleRepository  AlertRuleRepository
 )
 
 func TestMain(m *testing.M) {
@@ -25,12 +27,16 @@ func TestMain(m *testing.M) {
 	models := []interface{}{
 		&dtos.Market{},
 		&dtos.MarketData{},
+		&dtos.MarketRegime{},
+		&dtos.AlertRule{},
 	}
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
 	logger.Info("Migrations completed. Running tests...")
 	marketRepository = NewDefaultMarketRepository(database)
 	marketDataRepository = NewDefaultMarketDataRepository(database)
+	regimeRepository = NewDefaultMarketRegimeRepository(database)
+	alertRuleRepository = NewDefaultAlertRuleRepository(database)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
@@ -0,0 +1,367 @@
+package notification
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"gorm.io/gorm"
+)
+
+// Structs
+
+type DefaultTelegramChatRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultPairingCodeRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultNotificationChannelRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultNotificationDeliveryRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultNotificationPreferenceRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultDigestScheduleRepository struct {
+	Connection *gorm.DB
+}
+
+// Factories
+
+func NewDefaultTelegramChatRepository(connection *gorm.DB) *DefaultTelegramChatRepository {
+	return &DefaultTelegramChatRepository{Connection: connection}
+}
+
+func NewDefaultPairingCodeRepository(connection *gorm.DB) *DefaultPairingCodeRepository {
+	return &DefaultPairingCodeRepository{Connection: connection}
+}
+
+func NewDefaultNotificationChannelRepository(connection *gorm.DB) *DefaultNotificationChannelRepository {
+	return &DefaultNotificationChannelRepository{Connection: connection}
+}
+
+func NewDefaultNotificationDeliveryRepository(connection *gorm.DB) *DefaultNotificationDeliveryRepository {
+	return &DefaultNotificationDeliveryRepository{Connection: connection}
+}
+
+func NewDefaultNotificationPreferenceRepository(connection *gorm.DB) *DefaultNotificationPreferenceRepository {
+	return &DefaultNotificationPreferenceRepository{Connection: connection}
+}
+
+func NewDefaultDigestScheduleRepository(connection *gorm.DB) *DefaultDigestScheduleRepository {
+	return &DefaultDigestScheduleRepository{Connection: connection}
+}
+
+// TelegramChatRepository implementation
+
+func (d *DefaultTelegramChatRepository) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.TelegramChat, error) {
+	var chat dtos.TelegramChat
+	result := d.Connection.Where("user_id = ?", userID).First(&chat)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return chat.ToEntity(), nil
+}
+
+func (d *DefaultTelegramChatRepository) GetByChatID(
+	ctx echo.Context,
+	chatID int64,
+) (*entities.TelegramChat, error) {
+	var chat dtos.TelegramChat
+	result := d.Connection.Where("chat_id = ?", chatID).First(&chat)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return chat.ToEntity(), nil
+}
+
+func (d *DefaultTelegramChatRepository) Create(
+	ctx echo.Context,
+	chat *entities.TelegramChat,
+) (*entities.TelegramChat, error) {
+	instance := dtos.TelegramChat{}
+	instance.FromEntity(chat)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultTelegramChatRepository) Update(
+	ctx echo.Context,
+	chat *entities.TelegramChat,
+) (*entities.TelegramChat, error) {
+	instance := dtos.TelegramChat{}
+	instance.FromEntity(chat)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// Delete removes the link for good, so the user and the chat can be paired
+// again under the unique indexes.
+func (d *DefaultTelegramChatRepository) Delete(ctx echo.Context, id uuid.UUID) error {
+	result := d.Connection.Unscoped().Where("id = ?", id).Delete(&dtos.TelegramChat{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// PairingCodeRepository implementation
+
+func (d *DefaultPairingCodeRepository) GetByCodeDigest(
+	ctx echo.Context,
+	codeDigest string,
+) (*entities.PairingCode, error) {
+	var code dtos.PairingCode
+	result := d.Connection.Where("code_digest = ?", codeDigest).First(&code)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return code.ToEntity(), nil
+}
+
+func (d *DefaultPairingCodeRepository) Create(
+	ctx echo.Context,
+	code *entities.PairingCode,
+) (*entities.PairingCode, error) {
+	instance := dtos.PairingCode{}
+	instance.FromEntity(code)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultPairingCodeRepository) Update(
+	ctx echo.Context,
+	code *entities.PairingCode,
+) (*entities.PairingCode, error) {
+	instance := dtos.PairingCode{}
+	instance.FromEntity(code)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// NotificationChannelRepository implementation
+
+func (d *DefaultNotificationChannelRepository) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.NotificationChannel, error) {
+	var channel dtos.NotificationChannel
+	result := d.Connection.Where("id = ?", id).First(&channel)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return channel.ToEntity(), nil
+}
+
+func (d *DefaultNotificationChannelRepository) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.NotificationChannels, error) {
+	instances := dtos.NotificationChannels{}
+	result := d.Connection.Where("user_id = ?", userID).Order("created_at asc").Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultNotificationChannelRepository) Create(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+) (*entities.NotificationChannel, error) {
+	instance := dtos.NotificationChannel{}
+	instance.FromEntity(channel)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultNotificationChannelRepository) Update(
+	ctx echo.Context,
+	channel *entities.NotificationChannel,
+) (*entities.NotificationChannel, error) {
+	instance := dtos.NotificationChannel{}
+	instance.FromEntity(channel)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultNotificationChannelRepository) Delete(ctx echo.Context, id uuid.UUID) error {
+	_, err := d.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	result := d.Connection.Where("id = ?", id).Delete(&dtos.NotificationChannel{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// NotificationDeliveryRepository implementation
+
+func (d *DefaultNotificationDeliveryRepository) GetAll(
+	ctx echo.Context,
+	filters filtering.ComplexFilters,
+) (*entities.NotificationDeliveries, error) {
+	instances := dtos.NotificationDeliveries{}
+	query := filters.QueryFromFilter(d.Connection)
+	result := query.
+		Order(filters.GetOrdering()).
+		Offset(filters.GetPagination().Page).
+		Limit(filters.GetPagination().PageSize).
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultNotificationDeliveryRepository) Create(
+	ctx echo.Context,
+	delivery *entities.NotificationDelivery,
+) (*entities.NotificationDelivery, error) {
+	instance := dtos.NotificationDelivery{}
+	instance.FromEntity(delivery)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultNotificationDeliveryRepository) Update(
+	ctx echo.Context,
+	delivery *entities.NotificationDelivery,
+) (*entities.NotificationDelivery, error) {
+	instance := dtos.NotificationDelivery{}
+	instance.FromEntity(delivery)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// NotificationPreferenceRepository implementation
+
+func (d *DefaultNotificationPreferenceRepository) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.NotificationPreference, error) {
+	var preference dtos.NotificationPreference
+	result := d.Connection.Where("user_id = ?", userID).First(&preference)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return preference.ToEntity(), nil
+}
+
+func (d *DefaultNotificationPreferenceRepository) Create(
+	ctx echo.Context,
+	preference *entities.NotificationPreference,
+) (*entities.NotificationPreference, error) {
+	instance := dtos.NotificationPreference{}
+	instance.FromEntity(preference)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultNotificationPreferenceRepository) Update(
+	ctx echo.Context,
+	preference *entities.NotificationPreference,
+) (*entities.NotificationPreference, error) {
+	instance := dtos.NotificationPreference{}
+	instance.FromEntity(preference)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// DigestScheduleRepository implementation
+
+func (d *DefaultDigestScheduleRepository) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.DigestSchedule, error) {
+	var schedule dtos.DigestSchedule
+	result := d.Connection.Where("user_id = ?", userID).First(&schedule)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return schedule.ToEntity(), nil
+}
+
+func (d *DefaultDigestScheduleRepository) GetEnabled(
+	ctx echo.Context,
+) (*entities.DigestSchedules, error) {
+	instances := dtos.DigestSchedules{}
+	result := d.Connection.Where("enabled = ?", true).Order("created_at asc").Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultDigestScheduleRepository) Create(
+	ctx echo.Context,
+	schedule *entities.DigestSchedule,
+) (*entities.DigestSchedule, error) {
+	instance := dtos.DigestSchedule{}
+	instance.FromEntity(schedule)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultDigestScheduleRepository) Update(
+	ctx echo.Context,
+	schedule *entities.DigestSchedule,
+) (*entities.DigestSchedule, error) {
+	instance := dtos.DigestSchedule{}
+	instance.FromEntity(schedule)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
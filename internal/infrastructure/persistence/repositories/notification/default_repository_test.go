@@ -0,0 +1,329 @@
+package notification
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TelegramChat repository tests
+
+func TestGetTelegramChatByUserIDReturnsError(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := telegramChatRepository.GetByUserID(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestCreateTelegramChatReturnsChatByUserAndChatID(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	chatFactory := &entities.TelegramChatFactory{}
+	chat := chatFactory.NewTelegramChat(uuid.New(), rand.Int63())
+
+	// Act
+	_, err := telegramChatRepository.Create(ctx, chat)
+	byUser, userErr := telegramChatRepository.GetByUserID(ctx, chat.UserID)
+	byChat, chatErr := telegramChatRepository.GetByChatID(ctx, chat.ChatID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, userErr)
+	assert.NoError(t, chatErr)
+	assert.Equal(t, chat.ID, byUser.ID)
+	assert.Equal(t, chat.ID, byChat.ID)
+	assert.Equal(t, chat.ChatID, byUser.ChatID)
+}
+
+func TestCreateTelegramChatReturnsErrorIfChatAlreadyPaired(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	chatFactory := &entities.TelegramChatFactory{}
+	chatID := rand.Int63()
+	_, err := telegramChatRepository.Create(ctx, chatFactory.NewTelegramChat(uuid.New(), chatID))
+	assert.NoError(t, err)
+
+	// Act
+	_, err = telegramChatRepository.Create(ctx, chatFactory.NewTelegramChat(uuid.New(), chatID))
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestDeleteTelegramChatAllowsPairingAgain(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	chatFactory := &entities.TelegramChatFactory{}
+	chat := chatFactory.NewTelegramChat(uuid.New(), rand.Int63())
+	_, err := telegramChatRepository.Create(ctx, chat)
+	assert.NoError(t, err)
+
+	// Act
+	err = telegramChatRepository.Delete(ctx, chat.ID)
+	_, getErr := telegramChatRepository.GetByUserID(ctx, chat.UserID)
+	_, createErr := telegramChatRepository.Create(ctx, chatFactory.NewTelegramChat(chat.UserID, chat.ChatID))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, gorm.ErrRecordNotFound, getErr)
+	assert.NoError(t, createErr)
+}
+
+func TestDeleteTelegramChatReturnsErrorIfNotFound(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	err := telegramChatRepository.Delete(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+// PairingCode repository tests
+
+func TestGetPairingCodeByCodeDigestReturnsError(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := pairingCodeRepository.GetByCodeDigest(ctx, uuid.NewString())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestUpdatePairingCodeStoresRedemption(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	codeFactory := &entities.PairingCodeFactory{}
+	code := codeFactory.NewPairingCode(uuid.New(), uuid.NewString(), 10*time.Minute)
+	_, err := pairingCodeRepository.Create(ctx, code)
+	assert.NoError(t, err)
+
+	// Act
+	code.Redeem()
+	_, err = pairingCodeRepository.Update(ctx, code)
+	found, getErr := pairingCodeRepository.GetByCodeDigest(ctx, code.CodeDigest)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, code.ID, found.ID)
+	assert.NotNil(t, found.UsedAt)
+	assert.False(t, found.IsRedeemable(time.Now()))
+}
+
+// NotificationChannel repository tests
+
+func TestGetNotificationChannelsByUserIDReturnsChannels(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	channelFactory := &entities.NotificationChannelFactory{}
+	email := channelFactory.NewNotificationChannel(userID, constants.NotificationChannelEmail, "user@example.com", "", nil)
+	webhook := channelFactory.NewNotificationChannel(
+		userID,
+		constants.NotificationChannelWebhook,
+		"https://example.com/hooks",
+		"signing-secret",
+		[]string{constants.NotificationKindTrade},
+	)
+	webhook.CreatedAt = webhook.CreatedAt.Add(time.Second)
+	for _, channel := range []*entities.NotificationChannel{email, webhook} {
+		_, err := channelRepository.Create(ctx, channel)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	channels, err := channelRepository.GetByUserID(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *channels, 2)
+	assert.Equal(t, email.ID, (*channels)[0].ID)
+	assert.Equal(t, webhook.ID, (*channels)[1].ID)
+	assert.Equal(t, "signing-secret", (*channels)[1].Secret)
+	assert.Equal(t, []string{constants.NotificationKindTrade}, (*channels)[1].Kinds)
+}
+
+func TestDeleteNotificationChannelReturnsErrorIfNotFound(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	err := channelRepository.Delete(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestUpdateNotificationChannelDisablesChannel(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(uuid.New(), constants.NotificationChannelSlack, "https://hooks.slack.com/services/x", "", nil)
+	_, err := channelRepository.Create(ctx, channel)
+	assert.NoError(t, err)
+	channel.Enabled = false
+
+	// Act
+	_, err = channelRepository.Update(ctx, channel)
+	found, getErr := channelRepository.GetByID(ctx, channel.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.False(t, found.Enabled)
+}
+
+// NotificationDelivery repository tests
+
+func TestGetAllNotificationDeliveriesFiltersByStatus(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(userID, constants.NotificationChannelEmail, "user@example.com", "", nil)
+	deliveryFactory := &entities.NotificationDeliveryFactory{}
+	delivered := deliveryFactory.NewNotificationDelivery(channel, constants.NotificationKindTrade, "Trade", 1, nil)
+	failed := deliveryFactory.NewNotificationDelivery(channel, constants.NotificationKindTrade, "Trade", 3, assert.AnError)
+	for _, delivery := range []*entities.NotificationDelivery{delivered, failed} {
+		_, err := deliveryRepository.Create(ctx, delivery)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"user_id": userID,
+		"status":  constants.NotificationDeliveryStatusFailed,
+	}, "created_at", "desc", 0, 10)
+	deliveries, err := deliveryRepository.GetAll(ctx, filters)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *deliveries, 1)
+	assert.Equal(t, failed.ID, (*deliveries)[0].ID)
+	assert.Equal(t, 3, (*deliveries)[0].Attempts)
+}
+
+func TestUpdateNotificationDeliveryRecordsTheRetry(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	channelFactory := &entities.NotificationChannelFactory{}
+	channel := channelFactory.NewNotificationChannel(uuid.New(), constants.NotificationChannelEmail, "user@example.com", "", nil)
+	deliveryFactory := &entities.NotificationDeliveryFactory{}
+	delivery := deliveryFactory.NewNotificationDelivery(channel, constants.NotificationKindTrade, "Trade", 1, assert.AnError)
+	delivery.Retry()
+	_, err := deliveryRepository.Create(ctx, delivery)
+	assert.NoError(t, err)
+	delivery.RecordAttempt(nil)
+
+	// Act
+	updated, err := deliveryRepository.Update(ctx, delivery)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, updated.IsDelivered())
+	assert.Equal(t, 2, updated.Attempts)
+	assert.Empty(t, updated.Error)
+	assert.NotNil(t, updated.DeliveredAt)
+}
+
+// NotificationPreference repository tests
+
+func TestGetNotificationPreferenceByUserIDReturnsError(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	_, err := preferenceRepository.GetByUserID(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestUpdateNotificationPreferenceStoresQuietHours(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	preferenceFactory := &entities.NotificationPreferenceFactory{}
+	preference := preferenceFactory.NewNotificationPreference(uuid.New())
+	_, err := preferenceRepository.Create(ctx, preference)
+	assert.NoError(t, err)
+	preference.Locale = constants.NotificationLocaleSpanish
+	preference.Events = []string{constants.NotificationKindKillSwitch}
+	preference.QuietHoursStart = "22:00"
+	preference.QuietHoursEnd = "07:00"
+
+	// Act
+	_, err = preferenceRepository.Update(ctx, preference)
+	found, getErr := preferenceRepository.GetByUserID(ctx, preference.UserID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, preference.ID, found.ID)
+	assert.Equal(t, constants.NotificationLocaleSpanish, found.Locale)
+	assert.Equal(t, []string{constants.NotificationKindKillSwitch}, found.Events)
+	assert.Equal(t, "22:00", found.QuietHoursStart)
+	assert.Equal(t, "07:00", found.QuietHoursEnd)
+}
+
+func TestGetEnabledDigestSchedulesSkipsDisabledOnes(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	scheduleFactory := &entities.DigestScheduleFactory{}
+	enabled := scheduleFactory.NewDigestSchedule(uuid.New(), constants.DigestFrequencyDaily, "08:00", time.Sunday)
+	disabled := scheduleFactory.NewDigestSchedule(uuid.New(), constants.DigestFrequencyWeekly, "08:00", time.Monday)
+	disabled.Enabled = false
+	_, err := digestRepository.Create(ctx, enabled)
+	assert.NoError(t, err)
+	_, err = digestRepository.Create(ctx, disabled)
+	assert.NoError(t, err)
+
+	// Act
+	schedules, err := digestRepository.GetEnabled(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	ids := make([]uuid.UUID, 0, len(*schedules))
+	for _, schedule := range *schedules {
+		ids = append(ids, schedule.ID)
+	}
+	assert.Contains(t, ids, enabled.ID)
+	assert.NotContains(t, ids, disabled.ID)
+}
+
+func TestUpdateDigestScheduleStoresLastDigest(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	scheduleFactory := &entities.DigestScheduleFactory{}
+	schedule := scheduleFactory.NewDigestSchedule(uuid.New(), constants.DigestFrequencyWeekly, "18:30", time.Friday)
+	_, err := digestRepository.Create(ctx, schedule)
+	assert.NoError(t, err)
+	schedule.MarkSent(time.Now().UTC(), 2500)
+
+	// Act
+	_, err = digestRepository.Update(ctx, schedule)
+	found, getErr := digestRepository.GetByUserID(ctx, schedule.UserID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, schedule.ID, found.ID)
+	assert.Equal(t, time.Friday, found.Weekday)
+	assert.NotNil(t, found.LastSentAt)
+	assert.Equal(t, 2500.0, found.LastValue)
+}
@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+)
+
+type TelegramChatRepository interface {
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.TelegramChat, error)
+	GetByChatID(ctx echo.Context, chatID int64) (*entities.TelegramChat, error)
+	Create(ctx echo.Context, chat *entities.TelegramChat) (*entities.TelegramChat, error)
+	Update(ctx echo.Context, chat *entities.TelegramChat) (*entities.TelegramChat, error)
+	Delete(ctx echo.Context, id uuid.UUID) error
+}
+
+type PairingCodeRepository interface {
+	GetByCodeDigest(ctx echo.Context, codeDigest string) (*entities.PairingCode, error)
+	Create(ctx echo.Context, code *entities.PairingCode) (*entities.PairingCode, error)
+	Update(ctx echo.Context, code *entities.PairingCode) (*entities.PairingCode, error)
+}
+
+type NotificationChannelRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.NotificationChannel, error)
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.NotificationChannels, error)
+	Create(ctx echo.Context, channel *entities.NotificationChannel) (*entities.NotificationChannel, error)
+	Update(ctx echo.Context, channel *entities.NotificationChannel) (*entities.NotificationChannel, error)
+	Delete(ctx echo.Context, id uuid.UUID) error
+}
+
+type NotificationDeliveryRepository interface {
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.NotificationDeliveries, error)
+	Create(ctx echo.Context, delivery *entities.NotificationDelivery) (*entities.NotificationDelivery, error)
+	Update(ctx echo.Context, delivery *entities.NotificationDelivery) (*entities.NotificationDelivery, error)
+}
+
+type DigestScheduleRepository interface {
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.DigestSchedule, error)
+	GetEnabled(ctx echo.Context) (*entities.DigestSchedules, error)
+	Create(ctx echo.Context, schedule *entities.DigestSchedule) (*entities.DigestSchedule, error)
+	Update(ctx echo.Context, schedule *entities.DigestSchedule) (*entities.DigestSchedule, error)
+}
+
+type NotificationPreferenceRepository interface {
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.NotificationPreference, error)
+	Create(ctx echo.Context, preference *entities.NotificationPreference) (*entities.NotificationPreference, error)
+	Update(ctx echo.Context, preference *entities.NotificationPreference) (*entities.NotificationPreference, error)
+}
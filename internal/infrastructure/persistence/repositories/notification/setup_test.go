@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
+	"gorm.io/gorm"
+)
+
+var (
+	database               *gorm.DB
+	telegramChatRepository *DefaultTelegramChatRepository
+	pairingCodeRepository  *DefaultPairingCodeRepository
+	channelRepository      *DefaultNotificationChannelRepository
+	deliveryRepository     *DefaultNotificationDeliveryRepository
+	preferenceRepository   *DefaultNotificationPreferenceRepository
+	digestRepository       *DefaultDigestScheduleRepository
+)
+
+func TestMain(m *testing.M) {
+	logger := config.GetLogger()
+	logger.Info("Running notification repository tests...")
+	logger.Info("Instantiating test database...")
+	database = db.NewTestConnection()
+	logger.Info("Test DB connection established.")
+	models := []interface{}{
+		&dtos.TelegramChat{},
+		&dtos.PairingCode{},
+		&dtos.NotificationChannel{},
+		&dtos.NotificationDelivery{},
+		&dtos.NotificationPreference{},
+		&dtos.DigestSchedule{},
+	}
+	logger.Info("Attempting to run migrations on test database...")
+	db.Migrate(database, models)
+	logger.Info("Migrations completed. Running tests...")
+	telegramChatRepository = NewDefaultTelegramChatRepository(database)
+	pairingCodeRepository = NewDefaultPairingCodeRepository(database)
+	channelRepository = NewDefaultNotificationChannelRepository(database)
+	deliveryRepository = NewDefaultNotificationDeliveryRepository(database)
+	preferenceRepository = NewDefaultNotificationPreferenceRepository(database)
+	digestRepository = NewDefaultDigestScheduleRepository(database)
+	os.Exit(m.Run())
+	logger.Info("Tests completed.")
+}
@@ -2,9 +2,11 @@ package trade
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
@@ -25,6 +27,22 @@ type DefaultOrderRepository struct {
 	Connection *gorm.DB
 }
 
+type DefaultLedgerEntryRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultTaxLotRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultTaxLotDisposalRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultTradeProposalRepository struct {
+	Connection *gorm.DB
+}
+
 // Factories
 
 func NewDefaultTradingPreferenceRepository(connection *gorm.DB) *DefaultTradingPreferenceRepository {
@@ -39,6 +57,22 @@ func NewDefaultOrderRepository(connection *gorm.DB) *DefaultOrderRepository {
 	return &DefaultOrderRepository{Connection: connection}
 }
 
+func NewDefaultLedgerEntryRepository(connection *gorm.DB) *DefaultLedgerEntryRepository {
+	return &DefaultLedgerEntryRepository{Connection: connection}
+}
+
+func NewDefaultTaxLotRepository(connection *gorm.DB) *DefaultTaxLotRepository {
+	return &DefaultTaxLotRepository{Connection: connection}
+}
+
+func NewDefaultTaxLotDisposalRepository(connection *gorm.DB) *DefaultTaxLotDisposalRepository {
+	return &DefaultTaxLotDisposalRepository{Connection: connection}
+}
+
+func NewDefaultTradeProposalRepository(connection *gorm.DB) *DefaultTradeProposalRepository {
+	return &DefaultTradeProposalRepository{Connection: connection}
+}
+
 // TradingPreferenceRepository implementation
 
 func (dtr *DefaultTradingPreferenceRepository) GetByID(ctx echo.Context, id uuid.UUID) (*entities.TradingPreference, error) {
@@ -233,3 +267,230 @@ func (dor *DefaultOrderRepository) Delete(ctx echo.Context, id uuid.UUID) error
 	}
 	return dor.Connection.Delete(&dtos.Order{}, id).Error
 }
+
+// LedgerEntryRepository implementation
+
+func (dlr *DefaultLedgerEntryRepository) GetByID(ctx echo.Context, id uuid.UUID) (*entities.LedgerEntry, error) {
+	var entry dtos.LedgerEntry
+	result := dlr.Connection.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, result.Error
+	}
+	return entry.ToEntity(), nil
+}
+
+func (dlr *DefaultLedgerEntryRepository) GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.LedgerEntries, error) {
+	var entries dtos.LedgerEntries
+	result := dlr.Connection.Where("user_id = ?", userID).Order("created_at asc").Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return entries.ToEntities(), nil
+}
+
+func (dlr *DefaultLedgerEntryRepository) GetByHoldingID(ctx echo.Context, holdingID uuid.UUID) (*entities.LedgerEntries, error) {
+	var entries dtos.LedgerEntries
+	result := dlr.Connection.
+		Where("from_holding_id = ? OR to_holding_id = ?", holdingID, holdingID).
+		Order("created_at asc").
+		Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return entries.ToEntities(), nil
+}
+
+func (dlr *DefaultLedgerEntryRepository) GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.LedgerEntries, error) {
+	instances := dtos.LedgerEntries{}
+	query := filters.QueryFromFilter(dlr.Connection)
+	result := query.
+		Order(filters.GetOrdering()).
+		Offset(filters.GetPagination().Page).
+		Limit(filters.GetPagination().PageSize).
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (dlr *DefaultLedgerEntryRepository) Create(ctx echo.Context, entry *entities.LedgerEntry) (*entities.LedgerEntry, error) {
+	instance := dtos.LedgerEntry{}
+	instance.FromEntity(entry)
+	result := dlr.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// TaxLotRepository implementation
+
+func (dtl *DefaultTaxLotRepository) GetByID(ctx echo.Context, id uuid.UUID) (*entities.TaxLot, error) {
+	var lot dtos.TaxLot
+	result := dtl.Connection.Where("id = ?", id).First(&lot)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, result.Error
+	}
+	return lot.ToEntity(), nil
+}
+
+func (dtl *DefaultTaxLotRepository) GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.TaxLots, error) {
+	var lots dtos.TaxLots
+	result := dtl.Connection.Where("user_id = ?", userID).Order("acquired_at asc").Find(&lots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return lots.ToEntities(), nil
+}
+
+func (dtl *DefaultTaxLotRepository) GetOpenByAsset(ctx echo.Context, userID uuid.UUID, asset string) (*entities.TaxLots, error) {
+	var lots dtos.TaxLots
+	result := dtl.Connection.
+		Where("user_id = ? AND asset = ? AND remaining_quantity > 0", userID, asset).
+		Order("acquired_at asc").
+		Find(&lots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return lots.ToEntities(), nil
+}
+
+func (dtl *DefaultTaxLotRepository) Create(ctx echo.Context, lot *entities.TaxLot) (*entities.TaxLot, error) {
+	instance := dtos.TaxLot{}
+	instance.FromEntity(lot)
+	result := dtl.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (dtl *DefaultTaxLotRepository) Update(ctx echo.Context, lot *entities.TaxLot) (*entities.TaxLot, error) {
+	instance := dtos.TaxLot{}
+	instance.FromEntity(lot)
+	result := dtl.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// TaxLotDisposalRepository implementation
+
+func (dtd *DefaultTaxLotDisposalRepository) GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.TaxLotDisposals, error) {
+	var disposals dtos.TaxLotDisposals
+	result := dtd.Connection.Where("user_id = ?", userID).Order("disposed_at asc").Find(&disposals)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return disposals.ToEntities(), nil
+}
+
+func (dtd *DefaultTaxLotDisposalRepository) GetByPeriod(
+	ctx echo.Context,
+	userID uuid.UUID,
+	from time.Time,
+	to time.Time,
+) (*entities.TaxLotDisposals, error) {
+	var disposals dtos.TaxLotDisposals
+	result := dtd.Connection.
+		Where("user_id = ? AND disposed_at >= ? AND disposed_at < ?", userID, from, to).
+		Order("disposed_at asc").
+		Find(&disposals)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return disposals.ToEntities(), nil
+}
+
+func (dtd *DefaultTaxLotDisposalRepository) Create(ctx echo.Context, disposal *entities.TaxLotDisposal) (*entities.TaxLotDisposal, error) {
+	instance := dtos.TaxLotDisposal{}
+	instance.FromEntity(disposal)
+	result := dtd.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// TradeProposalRepository implementation
+
+func (dpr *DefaultTradeProposalRepository) GetByID(ctx echo.Context, id uuid.UUID) (*entities.TradeProposal, error) {
+	var proposal dtos.TradeProposal
+	result := dpr.Connection.Where("id = ?", id).First(&proposal)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, result.Error
+	}
+	return proposal.ToEntity(), nil
+}
+
+func (dpr *DefaultTradeProposalRepository) GetPending(ctx echo.Context, userID uuid.UUID) (*entities.TradeProposals, error) {
+	var proposals dtos.TradeProposals
+	result := dpr.Connection.
+		Where("user_id = ? AND status = ?", userID, constants.TradeProposalStatusPending).
+		Order("created_at asc").
+		Find(&proposals)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return proposals.ToEntities(), nil
+}
+
+func (dpr *DefaultTradeProposalRepository) GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.TradeProposals, error) {
+	instances := dtos.TradeProposals{}
+	query := filters.QueryFromFilter(dpr.Connection)
+	result := query.
+		Order(filters.GetOrdering()).
+		Offset(filters.GetPagination().Page).
+		Limit(filters.GetPagination().PageSize).
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (dpr *DefaultTradeProposalRepository) Create(ctx echo.Context, proposal *entities.TradeProposal) (*entities.TradeProposal, error) {
+	instance := dtos.TradeProposal{}
+	instance.FromEntity(proposal)
+	result := dpr.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (dpr *DefaultTradeProposalRepository) Update(ctx echo.Context, proposal *entities.TradeProposal) (*entities.TradeProposal, error) {
+	instance := dtos.TradeProposal{}
+	instance.FromEntity(proposal)
+	result := dpr.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// ClaimPending moves the proposal from pending to deciding in a single
+// statement, and tells whether this call was the one that claimed it.
+func (dpr *DefaultTradeProposalRepository) ClaimPending(ctx echo.Context, id uuid.UUID) (bool, error) {
+	result := dpr.Connection.Model(&dtos.TradeProposal{}).
+		Where("id = ? AND status = ?", id, constants.TradeProposalStatusPending).
+		Updates(map[string]interface{}{
+			"status":     constants.TradeProposalStatusDeciding,
+			"updated_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
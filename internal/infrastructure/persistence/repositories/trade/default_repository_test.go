@@ -3,6 +3,7 @@ package trade
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -759,3 +760,367 @@ func TestDeleteOrderDeletesOrder(t *testing.T) {
 	assert.Equal(t, gorm.ErrRecordNotFound, err)
 	assert.Nil(t, foundOrder)
 }
+
+// LedgerEntryRepository tests
+
+func newTestLedgerEntry(userID uuid.UUID, fromHoldingID uuid.UUID, toHoldingID uuid.UUID) *entities.LedgerEntry {
+	ledgerEntryFactory := &entities.LedgerEntryFactory{}
+	return ledgerEntryFactory.NewConversionEntry(
+		userID,
+		fromHoldingID,
+		toHoldingID,
+		uuid.New(),
+		&entities.ExchangeConversionQuote{
+			ID:         "quote-1",
+			FromAsset:  "BTC",
+			ToAsset:    "ETH",
+			FromAmount: 0.5,
+			ToAmount:   10,
+		},
+		60000.0,
+		2990.0,
+	)
+}
+
+func TestGetLedgerEntryByIDReturnsErrorIfNotExist(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	entry, err := ledgerEntryRepository.GetByID(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	assert.Nil(t, entry)
+}
+
+func TestCreateLedgerEntryReturnsLedgerEntry(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	entry := newTestLedgerEntry(uuid.New(), uuid.New(), uuid.New())
+
+	// Act
+	createdEntry, err := ledgerEntryRepository.Create(ctx, entry)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, createdEntry)
+	foundEntry, err := ledgerEntryRepository.GetByID(ctx, entry.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entry.ID, foundEntry.ID)
+	assert.Equal(t, -0.5, foundEntry.FromDelta)
+	assert.Equal(t, 10.0, foundEntry.ToDelta)
+	assert.Equal(t, 29900.0, foundEntry.Proceeds)
+}
+
+func TestGetLedgerEntriesByUserIDReturnsLedgerEntries(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	entry := newTestLedgerEntry(userID, uuid.New(), uuid.New())
+	dto := dtos.LedgerEntry{}
+	dto.FromEntity(entry)
+	database.Create(&dto)
+
+	// Act
+	entries, err := ledgerEntryRepository.GetByUserID(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*entries))
+	assert.Equal(t, entry.ID, (*entries)[0].ID)
+}
+
+func TestGetLedgerEntriesByHoldingIDReturnsBothLegs(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	holdingID := uuid.New()
+	opening := newTestLedgerEntry(userID, uuid.New(), holdingID)
+	closing := newTestLedgerEntry(userID, holdingID, uuid.New())
+	unrelated := newTestLedgerEntry(userID, uuid.New(), uuid.New())
+	for _, entry := range []*entities.LedgerEntry{opening, closing, unrelated} {
+		dto := dtos.LedgerEntry{}
+		dto.FromEntity(entry)
+		database.Create(&dto)
+	}
+
+	// Act
+	entries, err := ledgerEntryRepository.GetByHoldingID(ctx, holdingID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*entries))
+	assert.Equal(t, opening.ID, (*entries)[0].ID)
+	assert.Equal(t, closing.ID, (*entries)[1].ID)
+}
+
+func TestGetAllLedgerEntriesReturnsLedgerEntries(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	entry := newTestLedgerEntry(uuid.New(), uuid.New(), uuid.New())
+	entry.Reference = "quote-filter"
+	dto := dtos.LedgerEntry{}
+	dto.FromEntity(entry)
+	database.Create(&dto)
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"reference": "quote-filter",
+	}, "created_at", "desc", 0, 10)
+
+	// Act
+	entries, err := ledgerEntryRepository.GetAll(ctx, filters)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*entries))
+	assert.Equal(t, entry.ID, (*entries)[0].ID)
+}
+
+func TestGetAllLedgerEntriesKeepsChronologicalOrder(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	start := time.Now().UTC().Add(-time.Hour)
+	ids := make([]uuid.UUID, 3)
+	for _, i := range []int{2, 0, 1} {
+		entry := newTestLedgerEntry(userID, uuid.New(), uuid.New())
+		entry.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		dto := dtos.LedgerEntry{}
+		dto.FromEntity(entry)
+		database.Create(&dto)
+		ids[i] = entry.ID
+	}
+	filters := filtering.NewComplexFilter(ctx, map[string]interface{}{
+		"user_id": userID,
+	}, "created_at", "asc", 0, 2)
+
+	// Act
+	entries, err := ledgerEntryRepository.GetAll(ctx, filters)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*entries))
+	assert.Equal(t, ids[0], (*entries)[0].ID)
+	assert.Equal(t, ids[1], (*entries)[1].ID)
+}
+
+// TaxLotRepository tests
+
+func TestCreateTaxLotReturnsTaxLot(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	lotFactory := &entities.TaxLotFactory{}
+	lot := lotFactory.NewTaxLot(uuid.New(), uuid.New(), "BTC", 1, 60000, time.Now().UTC())
+
+	// Act
+	createdLot, err := taxLotRepository.Create(ctx, lot)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, createdLot)
+	foundLot, err := taxLotRepository.GetByID(ctx, lot.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, lot.ID, foundLot.ID)
+	assert.Equal(t, 1.0, foundLot.RemainingQuantity)
+}
+
+func TestGetTaxLotByIDReturnsErrorIfNotExist(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	lot, err := taxLotRepository.GetByID(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	assert.Nil(t, lot)
+}
+
+func TestGetOpenTaxLotsByAssetSkipsExhaustedLots(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	lotFactory := &entities.TaxLotFactory{}
+	openLot := lotFactory.NewTaxLot(userID, uuid.New(), "BTC", 1, 60000, time.Now().UTC())
+	exhaustedLot := lotFactory.NewTaxLot(userID, uuid.New(), "BTC", 1, 50000, time.Now().UTC())
+	exhaustedLot.RemainingQuantity = 0
+	otherAssetLot := lotFactory.NewTaxLot(userID, uuid.New(), "ETH", 1, 3000, time.Now().UTC())
+	for _, lot := range []*entities.TaxLot{openLot, exhaustedLot, otherAssetLot} {
+		dto := dtos.TaxLot{}
+		dto.FromEntity(lot)
+		database.Create(&dto)
+	}
+
+	// Act
+	lots, err := taxLotRepository.GetOpenByAsset(ctx, userID, "BTC")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*lots))
+	assert.Equal(t, openLot.ID, (*lots)[0].ID)
+}
+
+func TestUpdateTaxLotReturnsTaxLot(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	lotFactory := &entities.TaxLotFactory{}
+	lot := lotFactory.NewTaxLot(uuid.New(), uuid.New(), "BTC", 1, 60000, time.Now().UTC())
+	dto := dtos.TaxLot{}
+	dto.FromEntity(lot)
+	database.Create(&dto)
+	lot.Consume(0.4)
+
+	// Act
+	_, err := taxLotRepository.Update(ctx, lot)
+
+	// Assert
+	assert.NoError(t, err)
+	foundLot, err := taxLotRepository.GetByID(ctx, lot.ID)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.6, foundLot.RemainingQuantity, 0.0000001)
+}
+
+// TaxLotDisposalRepository tests
+
+func TestGetTaxLotDisposalsByPeriodReturnsDisposalsWithinPeriod(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	inside := &entities.TaxLotDisposal{
+		ID:            uuid.New(),
+		UserID:        userID,
+		LedgerEntryID: uuid.New(),
+		Asset:         "BTC",
+		Method:        constants.CostBasisMethodFIFO,
+		Quantity:      1,
+		DisposedAt:    time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:     time.Now().UTC(),
+	}
+	outside := &entities.TaxLotDisposal{
+		ID:            uuid.New(),
+		UserID:        userID,
+		LedgerEntryID: uuid.New(),
+		Asset:         "BTC",
+		Method:        constants.CostBasisMethodFIFO,
+		Quantity:      1,
+		DisposedAt:    time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:     time.Now().UTC(),
+	}
+	for _, disposal := range []*entities.TaxLotDisposal{inside, outside} {
+		_, err := taxLotDisposalRepository.Create(ctx, disposal)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	disposals, err := taxLotDisposalRepository.GetByPeriod(
+		ctx,
+		userID,
+		time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*disposals))
+	assert.Equal(t, inside.ID, (*disposals)[0].ID)
+	all, err := taxLotDisposalRepository.GetByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*all))
+}
+
+// TradeProposalRepository tests
+
+func TestCreateTradeProposalReturnsTradeProposal(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	proposalFactory := &entities.TradeProposalFactory{}
+	proposal := proposalFactory.NewTradeProposal(uuid.New(), uuid.New(), "BTCUSDT", "ETHUSDT", 3000, "spot", time.Hour)
+
+	// Act
+	createdProposal, err := tradeProposalRepository.Create(ctx, proposal)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, createdProposal)
+	foundProposal, err := tradeProposalRepository.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, proposal.ID, foundProposal.ID)
+	assert.Equal(t, constants.TradeProposalStatusPending, foundProposal.Status)
+}
+
+func TestGetTradeProposalByIDReturnsErrorIfNotExist(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+
+	// Act
+	proposal, err := tradeProposalRepository.GetByID(ctx, uuid.New())
+
+	// Assert
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	assert.Nil(t, proposal)
+}
+
+func TestGetPendingTradeProposalsSkipsDecidedOnes(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	proposalFactory := &entities.TradeProposalFactory{}
+	pending := proposalFactory.NewTradeProposal(userID, uuid.New(), "BTCUSDT", "ETHUSDT", 3000, "spot", time.Hour)
+	rejected := proposalFactory.NewTradeProposal(userID, uuid.New(), "BTCUSDT", "SOLUSDT", 150, "spot", time.Hour)
+	rejected.Decide(constants.TradeProposalStatusRejected, 0, "")
+	for _, proposal := range []*entities.TradeProposal{pending, rejected} {
+		_, err := tradeProposalRepository.Create(ctx, proposal)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	proposals, err := tradeProposalRepository.GetPending(ctx, userID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*proposals))
+	assert.Equal(t, pending.ID, (*proposals)[0].ID)
+}
+
+func TestClaimPendingTradeProposalOnlyOnce(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	proposalFactory := &entities.TradeProposalFactory{}
+	proposal := proposalFactory.NewTradeProposal(uuid.New(), uuid.New(), "BTCUSDT", "ETHUSDT", 3000, "spot", time.Hour)
+	_, err := tradeProposalRepository.Create(ctx, proposal)
+	assert.NoError(t, err)
+
+	// Act
+	first, firstErr := tradeProposalRepository.ClaimPending(ctx, proposal.ID)
+	second, secondErr := tradeProposalRepository.ClaimPending(ctx, proposal.ID)
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.True(t, first)
+	assert.False(t, second)
+	foundProposal, err := tradeProposalRepository.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusDeciding, foundProposal.Status)
+}
+
+func TestUpdateTradeProposalStoresDecision(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	proposalFactory := &entities.TradeProposalFactory{}
+	proposal := proposalFactory.NewTradeProposal(uuid.New(), uuid.New(), "BTCUSDT", "ETHUSDT", 3000, "spot", time.Hour)
+	_, err := tradeProposalRepository.Create(ctx, proposal)
+	assert.NoError(t, err)
+	proposal.Decide(constants.TradeProposalStatusApproved, 3010, "")
+
+	// Act
+	_, err = tradeProposalRepository.Update(ctx, proposal)
+
+	// Assert
+	assert.NoError(t, err)
+	foundProposal, err := tradeProposalRepository.GetByID(ctx, proposal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.TradeProposalStatusApproved, foundProposal.Status)
+	assert.Equal(t, 3010.0, foundProposal.DecisionPrice)
+	assert.NotNil(t, foundProposal.DecidedAt)
+}
@@ -1,6 +1,8 @@
 package trade
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
@@ -33,3 +35,35 @@ type OrderRepository interface {
 	Update(ctx echo.Context, order *entities.Order) (*entities.Order, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
 }
+
+// LedgerEntryRepository is append only, entries are never updated or deleted.
+type LedgerEntryRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.LedgerEntry, error)
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.LedgerEntries, error)
+	GetByHoldingID(ctx echo.Context, holdingID uuid.UUID) (*entities.LedgerEntries, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.LedgerEntries, error)
+	Create(ctx echo.Context, entry *entities.LedgerEntry) (*entities.LedgerEntry, error)
+}
+
+type TaxLotRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.TaxLot, error)
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.TaxLots, error)
+	GetOpenByAsset(ctx echo.Context, userID uuid.UUID, asset string) (*entities.TaxLots, error)
+	Create(ctx echo.Context, lot *entities.TaxLot) (*entities.TaxLot, error)
+	Update(ctx echo.Context, lot *entities.TaxLot) (*entities.TaxLot, error)
+}
+
+type TradeProposalRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.TradeProposal, error)
+	GetPending(ctx echo.Context, userID uuid.UUID) (*entities.TradeProposals, error)
+	GetAll(ctx echo.Context, filters filtering.ComplexFilters) (*entities.TradeProposals, error)
+	Create(ctx echo.Context, proposal *entities.TradeProposal) (*entities.TradeProposal, error)
+	Update(ctx echo.Context, proposal *entities.TradeProposal) (*entities.TradeProposal, error)
+	ClaimPending(ctx echo.Context, id uuid.UUID) (bool, error)
+}
+
+type TaxLotDisposalRepository interface {
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.TaxLotDisposals, error)
+	GetByPeriod(ctx echo.Context, userID uuid.UUID, from time.Time, to time.Time) (*entities.TaxLotDisposals, error)
+	Create(ctx echo.Context, disposal *entities.TaxLotDisposal) (*entities.TaxLotDisposal, error)
+}
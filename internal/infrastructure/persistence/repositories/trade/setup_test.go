@@ -15,6 +15,10 @@ var (
 	tradePreferenceRepository TradingPreferenceRepository
 	holdingRepository         HoldingRepository
 	orderRepository           OrderRepository
+	ledgerEntryRepository     LedgerEntryRepository
+	taxLotRepository          TaxLotRepository
+	taxLotDisposalRepository  TaxLotDisposalRepository
+	tradeProposalRepository   TradeProposalRepository
 )
 
 func TestMain(m *testing.M) {
@@ -27,6 +31,10 @@ func TestMain(m *testing.M) {
 		&dtos.TradingPreference{},
 		&dtos.Holding{},
 		&dtos.Order{},
+		&dtos.LedgerEntry{},
+		&dtos.TaxLot{},
+		&dtos.TaxLotDisposal{},
+		&dtos.TradeProposal{},
 	}
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
@@ -34,6 +42,10 @@ func TestMain(m *testing.M) {
 	tradePreferenceRepository = NewDefaultTradingPreferenceRepository(database)
 	holdingRepository = NewDefaultHoldingRepository(database)
 	orderRepository = NewDefaultOrderRepository(database)
+	ledgerEntryRepository = NewDefaultLedgerEntryRepository(database)
+	taxLotRepository = NewDefaultTaxLotRepository(database)
+	taxLotDisposalRepository = NewDefaultTaxLotDisposalRepository(database)
+	tradeProposalRepository = NewDefaultTradeProposalRepository(database)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
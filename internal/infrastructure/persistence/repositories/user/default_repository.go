@@ -1,14 +1,20 @@
 package user
 
 import (
+	"crypto/ed25519"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	domainerrors "github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Structs
@@ -17,12 +23,64 @@ type DefaultUserRepository struct {
 	Connection *gorm.DB
 }
 
+type DefaultSessionRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultUserTokenRepository struct {
+	Connection *gorm.DB
+}
+
+type DefaultLoginThrottleRepository struct {
+	Connection *gorm.DB
+}
+
+// DefaultSigningKeyRepository seals private keys the way api keys are, with
+// a data key of each record's own wrapped by the keyring master key.
+type DefaultSigningKeyRepository struct {
+	Connection *gorm.DB
+	Keyring    *lib.Keyring
+}
+
+// DefaultMFAFactorRepository seals TOTP secrets the way api keys are, with a
+// data key of each record's own wrapped by the keyring master key.
+type DefaultMFAFactorRepository struct {
+	Connection *gorm.DB
+	Keyring    *lib.Keyring
+}
+
 // Factories
 
 func NewDefaultUserRepository(connection *gorm.DB) *DefaultUserRepository {
 	return &DefaultUserRepository{Connection: connection}
 }
 
+func NewDefaultSessionRepository(connection *gorm.DB) *DefaultSessionRepository {
+	return &DefaultSessionRepository{Connection: connection}
+}
+
+func NewDefaultUserTokenRepository(connection *gorm.DB) *DefaultUserTokenRepository {
+	return &DefaultUserTokenRepository{Connection: connection}
+}
+
+func NewDefaultLoginThrottleRepository(connection *gorm.DB) *DefaultLoginThrottleRepository {
+	return &DefaultLoginThrottleRepository{Connection: connection}
+}
+
+func NewDefaultSigningKeyRepository(connection *gorm.DB, keyring *lib.Keyring) *DefaultSigningKeyRepository {
+	return &DefaultSigningKeyRepository{
+		Connection: connection,
+		Keyring:    keyring,
+	}
+}
+
+func NewDefaultMFAFactorRepository(connection *gorm.DB, keyring *lib.Keyring) *DefaultMFAFactorRepository {
+	return &DefaultMFAFactorRepository{
+		Connection: connection,
+		Keyring:    keyring,
+	}
+}
+
 // Repositories
 
 func (dur *DefaultUserRepository) GetByID(ctx echo.Context, id uuid.UUID) (*entities.User, error) {
@@ -104,3 +162,472 @@ func (dur *DefaultUserRepository) Delete(
 	}
 	return dur.Connection.Delete(&dtos.User{}, id).Error
 }
+
+// SessionRepository implementation
+
+func (d *DefaultSessionRepository) GetByID(
+	ctx echo.Context,
+	id uuid.UUID,
+) (*entities.Session, error) {
+	var session dtos.Session
+	result := d.Connection.Where("id = ?", id).First(&session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return session.ToEntity(), nil
+}
+
+// GetActiveByUserID returns the sessions of the user neither revoked nor
+// expired at the given time, most recently used first.
+func (d *DefaultSessionRepository) GetActiveByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+	now time.Time,
+) (*entities.Sessions, error) {
+	instances := dtos.Sessions{}
+	result := d.Connection.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at desc").
+		Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instances.ToEntities(), nil
+}
+
+func (d *DefaultSessionRepository) Create(
+	ctx echo.Context,
+	session *entities.Session,
+) (*entities.Session, error) {
+	instance := dtos.Session{}
+	instance.FromEntity(session)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultSessionRepository) Update(
+	ctx echo.Context,
+	session *entities.Session,
+) (*entities.Session, error) {
+	instance := dtos.Session{}
+	instance.FromEntity(session)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// Rotate stores the rotated session only if its refresh token is still the
+// previous one, in a single statement, and tells whether this call was the
+// one that swapped it.
+func (d *DefaultSessionRepository) Rotate(
+	ctx echo.Context,
+	session *entities.Session,
+	previousID uuid.UUID,
+) (bool, error) {
+	result := d.Connection.Model(&dtos.Session{}).
+		Where("id = ? AND refresh_token_id = ? AND revoked_at IS NULL", session.ID, previousID).
+		Updates(map[string]interface{}{
+			"refresh_token_id": session.RefreshTokenID,
+			"last_used_at":     session.LastUsedAt,
+			"expires_at":       session.ExpiresAt,
+			"updated_at":       session.UpdatedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UserTokenRepository implementation
+
+func (d *DefaultUserTokenRepository) GetByTokenDigest(
+	ctx echo.Context,
+	tokenDigest string,
+) (*entities.UserToken, error) {
+	var token dtos.UserToken
+	result := d.Connection.Where("token_digest = ?", tokenDigest).First(&token)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return token.ToEntity(), nil
+}
+
+// GetByUserID returns the tokens ever issued to the user for the purpose,
+// newest first.
+func (d *DefaultUserTokenRepository) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+	purpose string,
+) (*entities.UserTokens, error) {
+	var tokens dtos.UserTokens
+	result := d.Connection.
+		Where("user_id = ? AND purpose = ?", userID, purpose).
+		Order("created_at DESC").
+		Find(&tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return tokens.ToEntities(), nil
+}
+
+func (d *DefaultUserTokenRepository) Create(
+	ctx echo.Context,
+	token *entities.UserToken,
+) (*entities.UserToken, error) {
+	instance := dtos.UserToken{}
+	instance.FromEntity(token)
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+func (d *DefaultUserTokenRepository) Update(
+	ctx echo.Context,
+	token *entities.UserToken,
+) (*entities.UserToken, error) {
+	instance := dtos.UserToken{}
+	instance.FromEntity(token)
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return instance.ToEntity(), nil
+}
+
+// Redeem marks the token as used in a single statement, and tells whether
+// this call was the one that redeemed it.
+func (d *DefaultUserTokenRepository) Redeem(
+	ctx echo.Context,
+	id uuid.UUID,
+	now time.Time,
+) (bool, error) {
+	result := d.Connection.Model(&dtos.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RedeemByUserID marks the unused tokens of the user for the purpose as
+// used, so only the one issued next works.
+func (d *DefaultUserTokenRepository) RedeemByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+	purpose string,
+	now time.Time,
+) error {
+	result := d.Connection.Model(&dtos.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now)
+	return result.Error
+}
+
+// LoginThrottleRepository implementation
+
+func (d *DefaultLoginThrottleRepository) GetByScopeAndKey(
+	ctx echo.Context,
+	scope string,
+	key string,
+) (*entities.LoginThrottle, error) {
+	var throttle dtos.LoginThrottle
+	result := d.Connection.Where("scope = ? AND key = ?", scope, key).First(&throttle)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return throttle.ToEntity(), nil
+}
+
+// Fail counts a failed login at now in a single upsert, so failures counted
+// by several instances at once all add up. The count starts over when the
+// window passed since the last failure, or since the lockout it caused
+// ended. It returns the throttle as stored after the failure.
+func (d *DefaultLoginThrottleRepository) Fail(
+	ctx echo.Context,
+	throttle *entities.LoginThrottle,
+	now time.Time,
+	window time.Duration,
+) (*entities.LoginThrottle, error) {
+	instance := dtos.LoginThrottle{}
+	instance.FromEntity(throttle)
+	instance.FailedAttempts = 1
+	instance.LastFailedAt = now
+	instance.LockedUntil = nil
+	instance.UpdatedAt = now
+	staleSince := now.Add(-window)
+	stale := "login_throttles.last_failed_at < ? AND " +
+		"(login_throttles.locked_until IS NULL OR login_throttles.locked_until < ?)"
+	result := d.Connection.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failed_attempts": gorm.Expr(
+				"CASE WHEN "+stale+" THEN 1 ELSE login_throttles.failed_attempts + 1 END",
+				staleSince,
+				staleSince,
+			),
+			"locked_until": gorm.Expr(
+				"CASE WHEN "+stale+" THEN NULL ELSE login_throttles.locked_until END",
+				staleSince,
+				staleSince,
+			),
+			"last_failed_at": now,
+			"updated_at":     now,
+		}),
+	}).Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return d.GetByScopeAndKey(ctx, throttle.Scope, throttle.Key)
+}
+
+// Lock stores the lockout of the throttle unless another instance already
+// stored a longer one.
+func (d *DefaultLoginThrottleRepository) Lock(
+	ctx echo.Context,
+	throttle *entities.LoginThrottle,
+) error {
+	result := d.Connection.Model(&dtos.LoginThrottle{}).
+		Where(
+			"scope = ? AND key = ? AND (locked_until IS NULL OR locked_until < ?)",
+			throttle.Scope,
+			throttle.Key,
+			throttle.LockedUntil,
+		).
+		Updates(map[string]interface{}{
+			"locked_until": throttle.LockedUntil,
+			"updated_at":   throttle.UpdatedAt,
+		})
+	return result.Error
+}
+
+func (d *DefaultLoginThrottleRepository) Delete(
+	ctx echo.Context,
+	scope string,
+	key string,
+) error {
+	result := d.Connection.Where("scope = ? AND key = ?", scope, key).Delete(&dtos.LoginThrottle{})
+	return result.Error
+}
+
+// SigningKeyRepository implementation
+
+func (d *DefaultSigningKeyRepository) GetByID(
+	ctx echo.Context,
+	id string,
+) (*entities.SigningKey, error) {
+	var instance dtos.SigningKey
+	result := d.Connection.Where("id = ?", id).First(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return d.open(&instance)
+}
+
+// GetAll returns every stored signing key, newest first.
+func (d *DefaultSigningKeyRepository) GetAll(ctx echo.Context) (*entities.SigningKeys, error) {
+	instances := dtos.SigningKeys{}
+	result := d.Connection.Order("created_at desc").Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return d.openAll(ctx, &instances), nil
+}
+
+// GetStale returns the signing keys sealed with a previous master key.
+func (d *DefaultSigningKeyRepository) GetStale(ctx echo.Context) (*entities.SigningKeys, error) {
+	instances := dtos.SigningKeys{}
+	previousIDs := d.Keyring.PreviousIDs()
+	if len(previousIDs) == 0 {
+		return &entities.SigningKeys{}, nil
+	}
+	result := d.Connection.Where("master_key_id IN ?", previousIDs).Find(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return d.openAll(ctx, &instances), nil
+}
+
+func (d *DefaultSigningKeyRepository) Create(
+	ctx echo.Context,
+	signingKey *entities.SigningKey,
+) (*entities.SigningKey, error) {
+	instance := dtos.SigningKey{}
+	instance.FromEntity(signingKey)
+	err := d.seal(&instance, signingKey.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return signingKey, nil
+}
+
+func (d *DefaultSigningKeyRepository) Update(
+	ctx echo.Context,
+	signingKey *entities.SigningKey,
+) (*entities.SigningKey, error) {
+	instance := dtos.SigningKey{}
+	instance.FromEntity(signingKey)
+	err := d.seal(&instance, signingKey.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return signingKey, nil
+}
+
+func (d *DefaultSigningKeyRepository) Delete(ctx echo.Context, id string) error {
+	result := d.Connection.Where("id = ?", id).Delete(&dtos.SigningKey{})
+	return result.Error
+}
+
+// MFAFactorRepository implementation
+
+func (d *DefaultMFAFactorRepository) GetByUserID(
+	ctx echo.Context,
+	userID uuid.UUID,
+) (*entities.MFAFactor, error) {
+	var instance dtos.MFAFactor
+	result := d.Connection.Where("user_id = ?", userID).First(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return d.open(&instance)
+}
+
+func (d *DefaultMFAFactorRepository) Create(
+	ctx echo.Context,
+	factor *entities.MFAFactor,
+) (*entities.MFAFactor, error) {
+	instance := dtos.MFAFactor{}
+	instance.FromEntity(factor)
+	err := d.seal(&instance)
+	if err != nil {
+		return nil, err
+	}
+	result := d.Connection.Create(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return factor, nil
+}
+
+// Update reseals the secret, under the current master key.
+func (d *DefaultMFAFactorRepository) Update(
+	ctx echo.Context,
+	factor *entities.MFAFactor,
+) (*entities.MFAFactor, error) {
+	instance := dtos.MFAFactor{}
+	instance.FromEntity(factor)
+	err := d.seal(&instance)
+	if err != nil {
+		return nil, err
+	}
+	result := d.Connection.Save(&instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return factor, nil
+}
+
+func (d *DefaultMFAFactorRepository) Delete(ctx echo.Context, id uuid.UUID) error {
+	result := d.Connection.Where("id = ?", id).Delete(&dtos.MFAFactor{})
+	return result.Error
+}
+
+// Helpers
+
+// seal stores the seed of the private key under a new data key, bound to the
+// key ID so a sealed key can't be moved to another row.
+func (d *DefaultSigningKeyRepository) seal(instance *dtos.SigningKey, privateKey ed25519.PrivateKey) error {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return domainerrors.ErrSigningKeyInvalid
+	}
+	dataKey, wrapped, err := d.Keyring.NewDataKey()
+	if err != nil {
+		return err
+	}
+	sealed, err := lib.Seal(dataKey, privateKey.Seed(), []byte(instance.ID))
+	if err != nil {
+		return err
+	}
+	instance.PrivateKey = sealed
+	instance.DataKey = wrapped
+	instance.MasterKeyID = d.Keyring.CurrentID()
+	return nil
+}
+
+func (d *DefaultSigningKeyRepository) open(instance *dtos.SigningKey) (*entities.SigningKey, error) {
+	signingKey := instance.ToEntity()
+	dataKey, err := d.Keyring.UnwrapDataKey(instance.MasterKeyID, instance.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := lib.Open(dataKey, instance.PrivateKey, []byte(instance.ID))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize || len(signingKey.PublicKey) != ed25519.PublicKeySize {
+		return nil, domainerrors.ErrSigningKeyInvalid
+	}
+	signingKey.PrivateKey = ed25519.NewKeyFromSeed(seed)
+	return signingKey, nil
+}
+
+// openAll opens the rows, logging and leaving out the ones that can't be
+// decrypted, like keys of master keys no longer in the keyring, so tokens
+// keep being signed with the rest.
+func (d *DefaultSigningKeyRepository) openAll(ctx echo.Context, instances *dtos.SigningKeys) *entities.SigningKeys {
+	signingKeys := make(entities.SigningKeys, 0, len(*instances))
+	for i := range *instances {
+		signingKey, err := d.open(&(*instances)[i])
+		if err != nil {
+			config.GetLoggerFromContext(ctx).Errorf("Error opening signing key %s: %s", (*instances)[i].ID, err)
+			continue
+		}
+		signingKeys = append(signingKeys, *signingKey)
+	}
+	return &signingKeys
+}
+
+func (d *DefaultMFAFactorRepository) seal(instance *dtos.MFAFactor) error {
+	dataKey, wrapped, err := d.Keyring.NewDataKey()
+	if err != nil {
+		return err
+	}
+	secret, err := lib.Seal(dataKey, []byte(instance.Secret), instance.ID[:])
+	if err != nil {
+		return err
+	}
+	instance.Secret = secret
+	instance.DataKey = wrapped
+	instance.MasterKeyID = d.Keyring.CurrentID()
+	return nil
+}
+
+func (d *DefaultMFAFactorRepository) open(instance *dtos.MFAFactor) (*entities.MFAFactor, error) {
+	factor := instance.ToEntity()
+	dataKey, err := d.Keyring.UnwrapDataKey(instance.MasterKeyID, instance.DataKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := lib.Open(dataKey, instance.Secret, instance.ID[:])
+	if err != nil {
+		return nil, err
+	}
+	factor.Secret = string(secret)
+	return factor, nil
+}
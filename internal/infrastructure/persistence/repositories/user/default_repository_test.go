@@ -1,13 +1,17 @@
 package user
 
 import (
+	"encoding/base64"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/constants"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
+	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/filtering"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -281,3 +285,442 @@ func TestDeleteNonExistentUserReturnsError(t *testing.T) {
 }
 
 // Authentication tests
+
+// Session repository tests
+
+func TestGetActiveSessionsByUserIDSkipsRevokedAndExpired(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	factory := entities.SessionFactory{}
+	active := factory.NewSession(userID, "test-agent", "127.0.0.1", time.Hour)
+	revoked := factory.NewSession(userID, "test-agent", "127.0.0.1", time.Hour)
+	revoked.Revoke(constants.SessionRevokedByUser)
+	expired := factory.NewSession(userID, "test-agent", "127.0.0.1", -time.Minute)
+	for _, session := range []*entities.Session{active, revoked, expired} {
+		_, err := sessionRepository.Create(ctx, session)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	sessions, err := sessionRepository.GetActiveByUserID(ctx, userID, time.Now().UTC())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, *sessions, 1)
+	assert.Equal(t, active.ID, (*sessions)[0].ID)
+}
+
+func TestUpdateSessionKeepsRotatedRefreshToken(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SessionFactory{}
+	session, err := sessionRepository.Create(ctx, factory.NewSession(uuid.New(), "", "", time.Hour))
+	assert.NoError(t, err)
+	refreshTokenID := session.Rotate(time.Hour)
+
+	// Act
+	_, err = sessionRepository.Update(ctx, session)
+
+	// Assert
+	assert.NoError(t, err)
+	found, err := sessionRepository.GetByID(ctx, session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTokenID, found.RefreshTokenID)
+	assert.False(t, found.IsRevoked())
+}
+
+func TestRotateSessionOnlySwapsThePreviousRefreshToken(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SessionFactory{}
+	session, err := sessionRepository.Create(ctx, factory.NewSession(uuid.New(), "", "", time.Hour))
+	assert.NoError(t, err)
+	previousID := session.RefreshTokenID
+	first := *session
+	second := *session
+	firstID := first.Rotate(time.Hour)
+	second.Rotate(time.Hour)
+
+	// Act
+	firstSwapped, firstErr := sessionRepository.Rotate(ctx, &first, previousID)
+	secondSwapped, secondErr := sessionRepository.Rotate(ctx, &second, previousID)
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.True(t, firstSwapped)
+	assert.False(t, secondSwapped)
+	found, err := sessionRepository.GetByID(ctx, session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, firstID, found.RefreshTokenID)
+}
+
+// Signing key repository tests
+
+func TestCreateSigningKeySealsPrivateKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = signingKeyRepository.Create(ctx, signingKey)
+
+	// Assert
+	assert.NoError(t, err)
+	var instance dtos.SigningKey
+	result := database.Where("id = ?", signingKey.ID).First(&instance)
+	assert.NoError(t, result.Error)
+	assert.NotContains(t, instance.PrivateKey, base64.StdEncoding.EncodeToString(signingKey.PrivateKey.Seed()))
+	found, err := signingKeyRepository.GetByID(ctx, signingKey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, signingKey.PrivateKey, found.PrivateKey)
+	assert.Equal(t, signingKey.PublicKey, found.PublicKey)
+}
+
+func TestGetSigningKeyFailsWithAnotherMasterKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	_, err = signingKeyRepository.Create(ctx, signingKey)
+	assert.NoError(t, err)
+	keyring, err := lib.NewKeyring("test", map[string][]byte{"test": []byte("abcdef0123456789abcdef0123456789")})
+	assert.NoError(t, err)
+	other := NewDefaultSigningKeyRepository(database, keyring)
+
+	// Act
+	_, err = other.GetByID(ctx, signingKey.ID)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestGetStaleSigningKeysReturnsKeysOfPreviousMasterKeys(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	_, err = signingKeyRepository.Create(ctx, signingKey)
+	assert.NoError(t, err)
+	keyring, err := lib.NewKeyring("next", map[string][]byte{
+		"next": []byte("abcdef0123456789abcdef0123456789"),
+		"test": []byte("0123456789abcdef0123456789abcdef"),
+	})
+	assert.NoError(t, err)
+	rotatedRepository := NewDefaultSigningKeyRepository(database, keyring)
+
+	// Act
+	stale, err := rotatedRepository.GetStale(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	var found *entities.SigningKey
+	for i := range *stale {
+		if (*stale)[i].ID == signingKey.ID {
+			found = &(*stale)[i]
+		}
+	}
+	assert.NotNil(t, found)
+	assert.Equal(t, signingKey.PrivateKey, found.PrivateKey)
+	_, err = rotatedRepository.Update(ctx, found)
+	assert.NoError(t, err)
+	var instance dtos.SigningKey
+	database.Where("id = ?", signingKey.ID).First(&instance)
+	assert.Equal(t, "next", instance.MasterKeyID)
+	resealed, err := rotatedRepository.GetByID(ctx, signingKey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, signingKey.PrivateKey, resealed.PrivateKey)
+}
+
+func TestGetAllSigningKeysSkipsKeysThatDontOpen(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	_, err = signingKeyRepository.Create(ctx, signingKey)
+	assert.NoError(t, err)
+	database.Model(&dtos.SigningKey{}).Where("id = ?", signingKey.ID).Update("master_key_id", "retired")
+
+	// Act
+	signingKeys, err := signingKeyRepository.GetAll(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	for _, found := range *signingKeys {
+		assert.NotEqual(t, signingKey.ID, found.ID)
+	}
+}
+
+func TestGetAllSigningKeysReturnsNewestFirst(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	older, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	older.CreatedAt = time.Now().UTC().Add(365 * 24 * time.Hour)
+	newer, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	newer.CreatedAt = older.CreatedAt.Add(time.Hour)
+	for _, signingKey := range []*entities.SigningKey{older, newer} {
+		_, err = signingKeyRepository.Create(ctx, signingKey)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	signingKeys, err := signingKeyRepository.GetAll(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, newer.ID, (*signingKeys)[0].ID)
+	assert.Equal(t, older.ID, (*signingKeys)[1].ID)
+	for _, signingKey := range []*entities.SigningKey{older, newer} {
+		assert.NoError(t, signingKeyRepository.Delete(ctx, signingKey.ID))
+	}
+}
+
+func TestDeleteSigningKeyRemovesKey(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.SigningKeyFactory{}
+	signingKey, err := factory.NewSigningKey(constants.SigningAlgorithmEdDSA)
+	assert.NoError(t, err)
+	_, err = signingKeyRepository.Create(ctx, signingKey)
+	assert.NoError(t, err)
+
+	// Act
+	err = signingKeyRepository.Delete(ctx, signingKey.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = signingKeyRepository.GetByID(ctx, signingKey.ID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+// MFA factor repository tests
+
+func TestCreateMFAFactorSealsSecret(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MFAFactorFactory{}
+	factor, err := factory.NewMFAFactor(uuid.New())
+	assert.NoError(t, err)
+	_, err = factor.IssueRecoveryCodes(3)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = mfaFactorRepository.Create(ctx, factor)
+
+	// Assert
+	assert.NoError(t, err)
+	var instance dtos.MFAFactor
+	result := database.Where("id = ?", factor.ID).First(&instance)
+	assert.NoError(t, result.Error)
+	assert.NotEqual(t, factor.Secret, instance.Secret)
+	assert.Equal(t, "test", instance.MasterKeyID)
+	found, err := mfaFactorRepository.GetByUserID(ctx, factor.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, factor.Secret, found.Secret)
+	assert.Equal(t, factor.RecoveryCodes, found.RecoveryCodes)
+}
+
+func TestUpdateMFAFactorKeepsEnablement(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MFAFactorFactory{}
+	factor, err := factory.NewMFAFactor(uuid.New())
+	assert.NoError(t, err)
+	_, err = mfaFactorRepository.Create(ctx, factor)
+	assert.NoError(t, err)
+	factor.Enable(time.Now().UTC())
+	factor.RequiredForSensitiveActions = true
+
+	// Act
+	_, err = mfaFactorRepository.Update(ctx, factor)
+
+	// Assert
+	assert.NoError(t, err)
+	found, err := mfaFactorRepository.GetByUserID(ctx, factor.UserID)
+	assert.NoError(t, err)
+	assert.True(t, found.IsEnabled())
+	assert.True(t, found.RequiredForSensitiveActions)
+	assert.Equal(t, factor.Secret, found.Secret)
+}
+
+func TestDeleteMFAFactorRemovesFactor(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.MFAFactorFactory{}
+	factor, err := factory.NewMFAFactor(uuid.New())
+	assert.NoError(t, err)
+	_, err = mfaFactorRepository.Create(ctx, factor)
+	assert.NoError(t, err)
+
+	// Act
+	err = mfaFactorRepository.Delete(ctx, factor.ID)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = mfaFactorRepository.GetByUserID(ctx, factor.UserID)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+// User token repository tests
+
+func TestGetUserTokenByTokenDigestReturnsToken(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.UserTokenFactory{}
+	token := factory.NewUserToken(uuid.New(), constants.UserTokenPurposePasswordReset, "digest-lookup", time.Hour)
+	_, err := userTokenRepository.Create(ctx, token)
+	assert.NoError(t, err)
+
+	// Act
+	found, err := userTokenRepository.GetByTokenDigest(ctx, "digest-lookup")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	assert.Equal(t, constants.UserTokenPurposePasswordReset, found.Purpose)
+	assert.True(t, found.IsRedeemable(time.Now().UTC()))
+	_, err = userTokenRepository.GetByTokenDigest(ctx, "digest-unknown")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestRedeemUserTokensByUserIDOnlyRedeemsPurpose(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	userID := uuid.New()
+	factory := entities.UserTokenFactory{}
+	reset := factory.NewUserToken(userID, constants.UserTokenPurposePasswordReset, "digest-reset", time.Hour)
+	verification := factory.NewUserToken(userID, constants.UserTokenPurposeEmailVerification, "digest-verification", time.Hour)
+	for _, token := range []*entities.UserToken{reset, verification} {
+		_, err := userTokenRepository.Create(ctx, token)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	err := userTokenRepository.RedeemByUserID(ctx, userID, constants.UserTokenPurposePasswordReset, time.Now().UTC())
+
+	// Assert
+	assert.NoError(t, err)
+	found, err := userTokenRepository.GetByTokenDigest(ctx, "digest-reset")
+	assert.NoError(t, err)
+	assert.False(t, found.IsRedeemable(time.Now().UTC()))
+	found, err = userTokenRepository.GetByTokenDigest(ctx, "digest-verification")
+	assert.NoError(t, err)
+	assert.True(t, found.IsRedeemable(time.Now().UTC()))
+	tokens, err := userTokenRepository.GetByUserID(ctx, userID, constants.UserTokenPurposeEmailVerification)
+	assert.NoError(t, err)
+	assert.Len(t, *tokens, 1)
+}
+
+func TestRedeemUserTokenOnlyOnce(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.UserTokenFactory{}
+	token := factory.NewUserToken(uuid.New(), constants.UserTokenPurposePasswordReset, "digest-redeem-once", time.Hour)
+	_, err := userTokenRepository.Create(ctx, token)
+	assert.NoError(t, err)
+
+	// Act
+	first, firstErr := userTokenRepository.Redeem(ctx, token.ID, time.Now().UTC())
+	second, secondErr := userTokenRepository.Redeem(ctx, token.ID, time.Now().UTC())
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.True(t, first)
+	assert.False(t, second)
+	found, err := userTokenRepository.GetByTokenDigest(ctx, "digest-redeem-once")
+	assert.NoError(t, err)
+	assert.False(t, found.IsRedeemable(time.Now().UTC()))
+}
+
+// Login throttle repository tests
+
+func TestFailLoginThrottleAddsUpFailuresOfEveryInstance(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.LoginThrottleFactory{}
+	now := time.Now().UTC()
+	first := factory.NewLoginThrottle(constants.LoginThrottleScopeIP, "198.51.100.1")
+	second := factory.NewLoginThrottle(constants.LoginThrottleScopeIP, "198.51.100.1")
+	_, err := loginThrottleRepository.Fail(ctx, first, now, time.Hour)
+	assert.NoError(t, err)
+
+	// Act
+	stored, err := loginThrottleRepository.Fail(ctx, second, now, time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, stored.ID)
+	assert.Equal(t, 2, stored.FailedAttempts)
+	assert.WithinDuration(t, now, stored.LastFailedAt, time.Second)
+}
+
+func TestFailLoginThrottleStartsOverAfterTheWindow(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.LoginThrottleFactory{}
+	now := time.Now().UTC()
+	throttle := factory.NewLoginThrottle(constants.LoginThrottleScopeIP, "198.51.100.2")
+	for i := 0; i < 3; i++ {
+		_, err := loginThrottleRepository.Fail(ctx, throttle, now.Add(-2*time.Hour), time.Hour)
+		assert.NoError(t, err)
+	}
+
+	// Act
+	stored, err := loginThrottleRepository.Fail(ctx, throttle, now, time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.FailedAttempts)
+	assert.Nil(t, stored.LockedUntil)
+}
+
+func TestLockLoginThrottleKeepsALongerLockout(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.LoginThrottleFactory{}
+	now := time.Now().UTC()
+	throttle := factory.NewLoginThrottle(constants.LoginThrottleScopeIP, "198.51.100.3")
+	stored, err := loginThrottleRepository.Fail(ctx, throttle, now, time.Hour)
+	assert.NoError(t, err)
+	longer := *stored
+	assert.True(t, longer.Lock(now, 1, time.Hour, time.Hour))
+	err = loginThrottleRepository.Lock(ctx, &longer)
+	assert.NoError(t, err)
+	assert.True(t, stored.Lock(now, 1, time.Minute, time.Hour))
+
+	// Act
+	err = loginThrottleRepository.Lock(ctx, stored)
+
+	// Assert
+	assert.NoError(t, err)
+	found, err := loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeIP, "198.51.100.3")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, *longer.LockedUntil, *found.LockedUntil, time.Second)
+}
+
+func TestDeleteLoginThrottleRemovesThrottle(t *testing.T) {
+	// Arrange
+	ctx := echo.New().NewContext(nil, nil)
+	factory := entities.LoginThrottleFactory{}
+	throttle := factory.NewLoginThrottle(constants.LoginThrottleScopeAccount, "delete-throttle@test.com")
+	_, err := loginThrottleRepository.Fail(ctx, throttle, time.Now().UTC(), time.Hour)
+	assert.NoError(t, err)
+
+	// Act
+	err = loginThrottleRepository.Delete(ctx, constants.LoginThrottleScopeAccount, "delete-throttle@test.com")
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = loginThrottleRepository.GetByScopeAndKey(ctx, constants.LoginThrottleScopeAccount, "delete-throttle@test.com")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
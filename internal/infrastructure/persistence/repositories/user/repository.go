@@ -1,6 +1,8 @@
 package user
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sergiovirahonda/endurance-api/internal/domain/entities"
@@ -15,3 +17,43 @@ type UserRepository interface {
 	Update(ctx echo.Context, user *entities.User) (*entities.User, error)
 	Delete(ctx echo.Context, id uuid.UUID) error
 }
+
+type SessionRepository interface {
+	GetByID(ctx echo.Context, id uuid.UUID) (*entities.Session, error)
+	GetActiveByUserID(ctx echo.Context, userID uuid.UUID, now time.Time) (*entities.Sessions, error)
+	Create(ctx echo.Context, session *entities.Session) (*entities.Session, error)
+	Update(ctx echo.Context, session *entities.Session) (*entities.Session, error)
+	Rotate(ctx echo.Context, session *entities.Session, previousID uuid.UUID) (bool, error)
+}
+
+type SigningKeyRepository interface {
+	GetByID(ctx echo.Context, id string) (*entities.SigningKey, error)
+	GetAll(ctx echo.Context) (*entities.SigningKeys, error)
+	GetStale(ctx echo.Context) (*entities.SigningKeys, error)
+	Create(ctx echo.Context, signingKey *entities.SigningKey) (*entities.SigningKey, error)
+	Update(ctx echo.Context, signingKey *entities.SigningKey) (*entities.SigningKey, error)
+	Delete(ctx echo.Context, id string) error
+}
+
+type MFAFactorRepository interface {
+	GetByUserID(ctx echo.Context, userID uuid.UUID) (*entities.MFAFactor, error)
+	Create(ctx echo.Context, factor *entities.MFAFactor) (*entities.MFAFactor, error)
+	Update(ctx echo.Context, factor *entities.MFAFactor) (*entities.MFAFactor, error)
+	Delete(ctx echo.Context, id uuid.UUID) error
+}
+
+type UserTokenRepository interface {
+	GetByTokenDigest(ctx echo.Context, tokenDigest string) (*entities.UserToken, error)
+	GetByUserID(ctx echo.Context, userID uuid.UUID, purpose string) (*entities.UserTokens, error)
+	Create(ctx echo.Context, token *entities.UserToken) (*entities.UserToken, error)
+	Update(ctx echo.Context, token *entities.UserToken) (*entities.UserToken, error)
+	Redeem(ctx echo.Context, id uuid.UUID, now time.Time) (bool, error)
+	RedeemByUserID(ctx echo.Context, userID uuid.UUID, purpose string, now time.Time) error
+}
+
+type LoginThrottleRepository interface {
+	GetByScopeAndKey(ctx echo.Context, scope string, key string) (*entities.LoginThrottle, error)
+	Fail(ctx echo.Context, throttle *entities.LoginThrottle, now time.Time, window time.Duration) (*entities.LoginThrottle, error)
+	Lock(ctx echo.Context, throttle *entities.LoginThrottle) error
+	Delete(ctx echo.Context, scope string, key string) error
+}
@@ -7,12 +7,23 @@ import (
 	"github.com/sergiovirahonda/endurance-api/internal/config"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/db"
 	"github.com/sergiovirahonda/endurance-api/internal/infrastructure/persistence/dtos"
+	"github.com/sergiovirahonda/endurance-api/internal/lib"
 	"gorm.io/gorm"
 )
 
 var (
 	database   *gorm.DB
 	repository UserRepository
+	// Sessions
+	sessionRepository SessionRepository
+	// Signing keys
+	signingKeyRepository SigningKeyRepository
+	// Two-factor authentication
+	mfaFactorRepository MFAFactorRepository
+	// Account emails
+	userTokenRepository UserTokenRepository
+	// Login throttling
+	loginThrottleRepository LoginThrottleRepository
 )
 
 func TestMain(m *testing.M) {
@@ -23,11 +34,25 @@ func TestMain(m *testing.M) {
 	logger.Info("Test DB connection established.")
 	models := []interface{}{
 		&dtos.User{},
+		&dtos.Session{},
+		&dtos.SigningKey{},
+		&dtos.MFAFactor{},
+		&dtos.UserToken{},
+		&dtos.LoginThrottle{},
 	}
 	logger.Info("Attempting to run migrations on test database...")
 	db.Migrate(database, models)
 	logger.Info("Migrations completed. Running tests...")
+	keyring, err := lib.NewKeyring("test", map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		logger.Fatalf("Error building test keyring: %s", err)
+	}
+	mfaFactorRepository = NewDefaultMFAFactorRepository(database, keyring)
 	repository = NewDefaultUserRepository(database)
+	sessionRepository = NewDefaultSessionRepository(database)
+	signingKeyRepository = NewDefaultSigningKeyRepository(database, keyring)
+	userTokenRepository = NewDefaultUserTokenRepository(database)
+	loginThrottleRepository = NewDefaultLoginThrottleRepository(database)
 	os.Exit(m.Run())
 	logger.Info("Tests completed.")
 }
@@ -1,19 +1,96 @@
 package lib
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+	"github.com/sergiovirahonda/endurance-api/internal/domain/errors"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Alphabet of random codes meant to be typed, without look-alike characters
+const CodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Size in bytes of master and data keys, AES-256
+const EncryptionKeySize = 32
+
 // Structs
 
 type Hasher struct{}
 
+// Keyring holds the master keys wrapping the data keys of encrypted
+// records. New data keys are always wrapped with the current one; the rest
+// are kept to unwrap records written before a rotation.
+type Keyring struct {
+	currentID string
+	keys      map[string][]byte
+}
+
 // Factories
 
 func NewHasher() Hasher {
 	return Hasher{}
 }
 
+func NewKeyring(currentID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, errors.ErrEncryptionKeyMissing
+	}
+	for _, key := range keys {
+		if len(key) != EncryptionKeySize {
+			return nil, errors.ErrEncryptionKeyInvalid
+		}
+	}
+	return &Keyring{currentID: currentID, keys: keys}, nil
+}
+
+// LoadKeyring builds the keyring from the configuration. The current master
+// key is read from its file when one is set, from the environment otherwise.
+// Previous keys come as comma separated id:key pairs. Keys are base64.
+func LoadKeyring() (*Keyring, error) {
+	conf := config.GetConfig().Encryption
+	encoded := conf.MasterKey
+	if conf.MasterKeyFile != "" {
+		content, err := os.ReadFile(conf.MasterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(content)
+	}
+	if strings.TrimSpace(encoded) == "" {
+		return nil, errors.ErrEncryptionKeyMissing
+	}
+	keys := map[string][]byte{}
+	current, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	keys[conf.MasterKeyID] = current
+	for _, pair := range strings.Split(conf.PreviousMasterKeys, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, encoded, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, errors.ErrEncryptionKeyInvalid
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		keys[strings.TrimSpace(id)] = key
+	}
+	return NewKeyring(conf.MasterKeyID, keys)
+}
+
 // Receivers
 
 func (h Hasher) HashString(str string) (string, error) {
@@ -25,3 +102,126 @@ func (h Hasher) CheckStringHash(str, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(str))
 	return err == nil
 }
+
+// DigestString returns the hex encoded SHA-256 of the string. Unlike
+// HashString it is deterministic, so random tokens can be looked up by it.
+func (h Hasher) DigestString(str string) string {
+	digest := sha256.Sum256([]byte(str))
+	return hex.EncodeToString(digest[:])
+}
+
+func (k *Keyring) CurrentID() string {
+	return k.currentID
+}
+
+// PreviousIDs returns the IDs of the master keys kept only for reading.
+func (k *Keyring) PreviousIDs() []string {
+	ids := []string{}
+	for id := range k.keys {
+		if id != k.currentID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// NewDataKey returns a fresh data key along with it wrapped by the current
+// master key, ready to be stored next to the data it encrypts.
+func (k *Keyring) NewDataKey() ([]byte, string, error) {
+	dataKey, err := RandomKey()
+	if err != nil {
+		return nil, "", err
+	}
+	wrapped, err := Seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return nil, "", err
+	}
+	return dataKey, wrapped, nil
+}
+
+// UnwrapDataKey returns the data key wrapped by the given master key.
+func (k *Keyring) UnwrapDataKey(masterKeyID string, wrapped string) ([]byte, error) {
+	masterKey, ok := k.keys[masterKeyID]
+	if !ok {
+		return nil, errors.ErrEncryptionKeyUnknown
+	}
+	return Open(masterKey, wrapped, []byte(masterKeyID))
+}
+
+// Helpers
+
+// RandomKey returns a random key for AES-256.
+func RandomKey() ([]byte, error) {
+	key := make([]byte, EncryptionKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Seal encrypts the plaintext with AES-GCM under a random nonce and returns
+// both base64 encoded. The additional data is authenticated but not stored,
+// so the same has to be given to open it.
+func Seal(key []byte, plaintext []byte, additionalData []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts what Seal returned.
+func Open(key []byte, sealed string, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, errors.ErrDecryptionFailed
+	}
+	nonce, ciphertext := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.ErrEncryptionKeyInvalid
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != EncryptionKeySize {
+		return nil, errors.ErrEncryptionKeyInvalid
+	}
+	return key, nil
+}
+
+// RandomCode returns a code of the given length drawn from the alphabet with
+// a cryptographically secure source.
+func RandomCode(length int, alphabet string) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/sergiovirahonda/endurance-api/internal/config"
+)
+
+// NewIPExtractor returns how the server must resolve client addresses, to be
+// set as the IPExtractor of the echo instance. Login throttling is keyed on
+// them, so forwarding headers are only read when the request comes from one
+// of the configured trusted proxies. Without any, the address of the
+// connection is used, since anyone could send those headers.
+func NewIPExtractor() (echo.IPExtractor, error) {
+	ranges, err := config.GetConfig().TrustedProxyRanges()
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	// Echo trusts every private network by default, proxy or not
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipRange := range ranges {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
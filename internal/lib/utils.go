@@ -2,6 +2,9 @@ package lib
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/sergiovirahonda/endurance-api/internal/config"
@@ -35,3 +38,37 @@ func SliceContains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// Number helpers
+
+// FormatDecimal rounds the value to maxDecimals and drops trailing zeros down
+// to minDecimals, grouping the integer digits by thousands.
+func FormatDecimal(
+	value float64,
+	minDecimals int,
+	maxDecimals int,
+	groupSeparator string,
+	decimalSeparator string,
+) string {
+	formatted := strconv.FormatFloat(math.Abs(value), 'f', maxDecimals, 64)
+	integer, fraction, _ := strings.Cut(formatted, ".")
+	for len(fraction) > minDecimals && strings.HasSuffix(fraction, "0") {
+		fraction = fraction[:len(fraction)-1]
+	}
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteString(groupSeparator)
+		}
+		grouped.WriteRune(digit)
+	}
+	result := grouped.String()
+	if fraction != "" {
+		result += decimalSeparator + fraction
+	}
+	// Values rounding to zero are not signed
+	if value < 0 && strings.Trim(integer+fraction, "0") != "" {
+		result = "-" + result
+	}
+	return result
+}